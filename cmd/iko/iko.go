@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"github.com/kittycash/wallet/src/http"
 	"github.com/kittycash/wallet/src/iko"
+	"github.com/kittycash/wallet/src/rpc"
 	"github.com/kittycash/wallet/src/wallet"
 	"github.com/skycoin/skycoin/src/cipher"
 	"gopkg.in/sirupsen/logrus.v1"
@@ -16,16 +18,73 @@ const (
 
 	MemoryMode = "memory"
 
+	StateBackend = "state-backend"
+	StateDir     = "state-dir"
+
+	SnapshotDir      = "snapshot-dir"
+	SnapshotInterval = "snapshot-interval"
+	SnapshotKeep     = "snapshot-keep"
+	SnapshotMaxAge   = "snapshot-max-age"
+
+	VerifyState = "verify-state"
+
+	MempoolSize = "mempool-size"
+	MempoolTTL  = "mempool-ttl"
+
+	TxMaxMemoSize  = "tx-max-memo-size"
+	TxMaxKitties   = "tx-max-kitties"
+	TxMaxClockSkew = "tx-max-clock-skew"
+
+	TxRateLimit  = "tx-rate-limit"
+	TxRateWindow = "tx-rate-window"
+
+	KittyMetaFile = "kitty-meta-file"
+	KittySupply   = "kitty-supply"
+
+	TransferFee = "transfer-fee"
+	BurnCosign  = "burn-cosign"
+
+	DisableTxChecks = "disable-tx-checks"
+
 	TestMode           = "test"
 	TestSecretKey      = "test-secret-key"
 	TestInjectionCount = "test-injection-count"
 
+	WalletDir               = "wallet-dir"
+	RequireEncryptedWallets = "require-encrypted-wallets"
+	MinPasswordScore        = "min-password-score"
+	WalletReloadInterval    = "wallet-reload-interval"
+	MemoryWallets           = "memory-wallets"
+	VaultAddress            = "vault-address"
+	VaultToken              = "vault-token"
+	VaultMount              = "vault-mount"
+	LedgerAddresses         = "ledger-addresses"
+	AuditSecret             = "audit-secret"
+
 	HttpAddress = "http-address"
 	GUI         = "gui"
 	GUIDir      = "gui-dir"
 	TLS         = "tls"
 	TLSCert     = "tls-cert"
 	TLSKey      = "tls-key"
+	APIVersion  = "api-version"
+	APIKeyFile  = "api-key-file"
+	CSRF        = "csrf"
+
+	CORSOrigins     = "cors-origins"
+	CORSMethods     = "cors-methods"
+	CORSHeaders     = "cors-headers"
+	CORSCredentials = "cors-credentials"
+
+	RateLimits            = "rate-limits"
+	RateLimitForwardedFor = "rate-limit-forwarded-for"
+	LogRequests           = "log-requests"
+
+	SessionPasswordHash = "session-password-hash"
+	SessionTTL          = "session-ttl"
+	SessionRefreshTTL   = "session-refresh-ttl"
+
+	GrpcAddress = "grpc-address"
 )
 
 func Flag(flag string, short ...string) string {
@@ -58,6 +117,108 @@ func init() {
 			Name:  Flag(MemoryMode, "m"),
 			Usage: "whether to run in memory-only mode",
 		},
+		/*
+			<<< STATE BACKEND >>>
+		*/
+		cli.StringFlag{
+			Name:  Flag(StateBackend),
+			Usage: fmt.Sprintf("backend to store state with, one of %v", iko.StateDBBackends()),
+			Value: "memory",
+		},
+		cli.StringFlag{
+			Name:  Flag(StateDir),
+			Usage: "directory for state backends that persist to disk",
+		},
+		/*
+			<<< STATE SNAPSHOTS >>>
+		*/
+		cli.StringFlag{
+			Name:  Flag(SnapshotDir),
+			Usage: "directory to store state snapshots in, snapshots are disabled if empty",
+		},
+		cli.Uint64Flag{
+			Name:  Flag(SnapshotInterval),
+			Usage: "number of transactions between state snapshots",
+			Value: 1000,
+		},
+		cli.IntFlag{
+			Name:  Flag(SnapshotKeep),
+			Usage: "number of latest state snapshots to keep, 0 keeps all",
+		},
+		cli.DurationFlag{
+			Name:  Flag(SnapshotMaxAge),
+			Usage: "state snapshots older than this are deleted (e.g. 720h), 0 keeps all",
+		},
+		cli.BoolFlag{
+			Name:  Flag(VerifyState),
+			Usage: "whether to verify the state against the chain on startup, exits on mismatch",
+		},
+		/*
+			<<< MEMPOOL >>>
+		*/
+		cli.IntFlag{
+			Name:  Flag(MempoolSize),
+			Usage: "maximum number of transactions that wait in the mempool, 0 disables the mempool",
+			Value: 1000,
+		},
+		cli.DurationFlag{
+			Name:  Flag(MempoolTTL),
+			Usage: "duration that a transaction waits in the mempool before it is dropped",
+			Value: iko.DefaultMempoolTTL,
+		},
+		/*
+			<<< TX LIMITS >>>
+		*/
+		cli.IntFlag{
+			Name:  Flag(TxMaxMemoSize),
+			Usage: "maximum size of transaction memos in bytes",
+			Value: iko.TxMaxMemoSize,
+		},
+		cli.IntFlag{
+			Name:  Flag(TxMaxKitties),
+			Usage: "maximum number of kitties that a transaction transfers",
+			Value: iko.TxMaxKitties,
+		},
+		cli.DurationFlag{
+			Name:  Flag(TxMaxClockSkew),
+			Usage: "maximum duration that the timestamp of a transaction is ahead of the node's clock",
+			Value: iko.DefaultTxClockSkew,
+		},
+		/*
+			<<< RATE LIMIT >>>
+		*/
+		cli.IntFlag{
+			Name:  Flag(TxRateLimit),
+			Usage: "maximum number of transactions that an address injects per window, 0 disables rate limiting",
+		},
+		cli.DurationFlag{
+			Name:  Flag(TxRateWindow),
+			Usage: "sliding window of the rate limit for transactions",
+			Value: iko.DefaultTxRateWindow,
+		},
+		/*
+			<<< KITTY METADATA >>>
+		*/
+		cli.StringFlag{
+			Name:  Flag(KittyMetaFile),
+			Usage: "json file with kitty metadata signed by the master key, to load on startup",
+		},
+		cli.Uint64Flag{
+			Name:  Flag(KittySupply),
+			Usage: "number of kitties available in the IKO, used to list unclaimed kitties",
+		},
+		cli.Uint64Flag{
+			Name:  Flag(TransferFee),
+			Usage: "flat fee that every transfer pays to the master address, 0 disables fees",
+		},
+		cli.BoolFlag{
+			Name:  Flag(BurnCosign),
+			Usage: "whether burns of kitties need to be countersigned by the master key",
+		},
+		cli.StringSliceFlag{
+			Name:  Flag(DisableTxChecks),
+			Usage: "names of the stages of the transaction pipeline to skip (except 'link', 'sig', 'kind', 'nonce' and 'ownership')",
+		},
 		/*
 			<<< TEST MODE >>>
 		*/
@@ -73,6 +234,55 @@ func init() {
 			Name:  Flag(TestInjectionCount, "tc"),
 			Usage: "only valid in test mode, injects a number of initial transactions for testing",
 		},
+		/*
+			<<< WALLET >>>
+		*/
+		cli.StringFlag{
+			Name:   Flag(WalletDir),
+			Usage:  "directory of wallet files, defaults to the data directory of the user",
+			EnvVar: wallet.RootDirEnv,
+		},
+		cli.BoolFlag{
+			Name:  Flag(RequireEncryptedWallets),
+			Usage: "whether to refuse to load or create unencrypted wallet files",
+		},
+		cli.IntFlag{
+			Name:  Flag(MinPasswordScore),
+			Usage: "minimum score (0 to 4) for wallet passwords, where 0 accepts every password",
+		},
+		cli.StringFlag{
+			Name:   Flag(AuditSecret),
+			Usage:  "secret that the key of the wallet audit log is derived from, defaults to a random key kept in the wallet directory",
+			EnvVar: "KITTYCASH_AUDIT_SECRET",
+		},
+		cli.DurationFlag{
+			Name:  Flag(WalletReloadInterval),
+			Usage: "interval for polling the wallet directory for wallet files that are added or removed externally, where changes are not notified (as they are through inotify on linux)",
+			Value: wallet.DefaultReloadInterval,
+		},
+		cli.BoolFlag{
+			Name:  Flag(MemoryWallets),
+			Usage: "whether to keep wallets in memory only, so none of them is written to disk (always in test mode)",
+		},
+		cli.StringFlag{
+			Name:   Flag(VaultAddress),
+			Usage:  "address of a HashiCorp Vault to register as the 'vault' wallet keystore",
+			EnvVar: "VAULT_ADDR",
+		},
+		cli.StringFlag{
+			Name:   Flag(VaultToken),
+			Usage:  "token for the vault of the wallet keystore",
+			EnvVar: "VAULT_TOKEN",
+		},
+		cli.StringFlag{
+			Name:  Flag(VaultMount),
+			Usage: "mount of the KV (version 2) secrets engine in the vault of the wallet keystore",
+			Value: wallet.DefaultVaultMount,
+		},
+		cli.IntFlag{
+			Name:  Flag(LedgerAddresses),
+			Usage: "number of addresses on a connected Ledger device to list as the 'ledger' wallet, the device is not used if 0",
+		},
 		/*
 			<<< HTTP SERVER >>>
 		*/
@@ -102,10 +312,89 @@ func init() {
 			Name:  Flag(TLSKey),
 			Usage: "tls key file path",
 		},
+		cli.IntFlag{
+			Name:  Flag(APIVersion),
+			Usage: "version of the API served at '/api/' to requests that do not negotiate one",
+			Value: http.APIVersion1,
+		},
+		cli.BoolTFlag{
+			Name:  Flag(CSRF),
+			Usage: "whether state-changing requests from browsers require CSRF tokens",
+		},
+		cli.StringSliceFlag{
+			Name:  Flag(CORSOrigins),
+			Usage: "origins allowed to use the API from browsers (as 'https://explorer.example.com', 'https://*.example.com' or '*'), CORS is disabled if empty",
+		},
+		cli.StringSliceFlag{
+			Name:  Flag(CORSMethods),
+			Usage: "methods allowed for other origins (GET, HEAD and POST if empty)",
+		},
+		cli.StringSliceFlag{
+			Name:  Flag(CORSHeaders),
+			Usage: "request headers allowed for other origins (the headers of the API if empty)",
+		},
+		cli.BoolFlag{
+			Name:  Flag(CORSCredentials),
+			Usage: "whether requests from other origins may carry cookies (not with '*')",
+		},
+		cli.StringSliceFlag{
+			Name:  Flag(RateLimits),
+			Usage: "rate limits for route groups (read, write, wallet, auth or admin) per API key or IP address, as 'read=600/m', rate limits are disabled if empty",
+		},
+		cli.BoolFlag{
+			Name:  Flag(RateLimitForwardedFor),
+			Usage: "whether IP addresses for rate limits are read from the 'X-Forwarded-For' header, for nodes behind a proxy",
+		},
+		cli.BoolTFlag{
+			Name:  Flag(LogRequests),
+			Usage: "whether requests to the API are logged, with their request IDs",
+		},
+		cli.StringFlag{
+			Name:  Flag(APIKeyFile),
+			Usage: "file with the hashes of the API keys required by mutating routes, API keys are disabled if empty",
+		},
+		cli.StringFlag{
+			Name:   Flag(SessionPasswordHash),
+			Usage:  "bcrypt hash of the password for the sessions required by wallet routes (see 'ikotools hash-password'), sessions are disabled if empty",
+			EnvVar: "KITTYCASH_SESSION_PASSWORD_HASH",
+		},
+		cli.DurationFlag{
+			Name:  Flag(SessionTTL),
+			Usage: "duration that the access tokens of sessions are valid for",
+			Value: http.DefaultSessionTTL,
+		},
+		cli.DurationFlag{
+			Name:  Flag(SessionRefreshTTL),
+			Usage: "duration that the refresh tokens of sessions are valid for",
+			Value: http.DefaultSessionRefreshTTL,
+		},
+		/*
+			<<< GRPC SERVER >>>
+		*/
+		cli.StringFlag{
+			Name:  Flag(GrpcAddress),
+			Usage: "address to serve the grpc service on (over h2c), disabled if empty",
+		},
 	}
 	app.Action = cli.ActionFunc(action)
 }
 
+// walletRootDir obtains the wallet directory from the flag (or environment
+// variable), or the default for the OS. Nodes used to keep wallets in "wallet"
+// in the working directory, which is still used if it exists and no
+// directory is given.
+func walletRootDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if info, e := os.Stat("wallet"); e == nil && info.IsDir() {
+		log.Warningf("using wallet directory `wallet` in the working directory, "+
+			"move it to the default directory or give it with --%s", WalletDir)
+		return "wallet", nil
+	}
+	return wallet.DefaultRootDir()
+}
+
 func action(ctx *cli.Context) error {
 	quit := CatchInterrupt()
 
@@ -120,6 +409,7 @@ func action(ctx *cli.Context) error {
 	var (
 		chainDB iko.ChainDB
 		stateDB iko.StateDB
+		e       error
 	)
 
 	// Prepare ChainDB.
@@ -129,7 +419,12 @@ func action(ctx *cli.Context) error {
 	}
 
 	// Prepare StateDB.
-	stateDB = iko.NewMemoryState()
+	stateDB, e = iko.NewStateDB(ctx.String(StateBackend), &iko.StateDBConfig{
+		Dir: ctx.String(StateDir),
+	})
+	if e != nil {
+		return e
+	}
 
 	// Prepare blockchain config.
 	bcConfig := &iko.BlockChainConfig{
@@ -137,6 +432,32 @@ func action(ctx *cli.Context) error {
 		TxAction: func(tx *iko.Transaction) error {
 			return nil
 		},
+		MetaDB:           iko.NewMemoryMetaDB(),
+		KittySupply:      ctx.Uint64(KittySupply),
+		TransferFee:      ctx.Uint64(TransferFee),
+		BurnCosign:       ctx.Bool(BurnCosign),
+		DisabledTxChecks: ctx.StringSlice(DisableTxChecks),
+		SnapshotInterval: ctx.Uint64(SnapshotInterval),
+		SnapshotRetention: iko.SnapshotRetention{
+			KeepLast: ctx.Int(SnapshotKeep),
+			MaxAge:   ctx.Duration(SnapshotMaxAge),
+		},
+		MempoolSize: ctx.Int(MempoolSize),
+		MempoolTTL:  ctx.Duration(MempoolTTL),
+		TxLimits: iko.TxLimits{
+			MaxMemoSize:  ctx.Int(TxMaxMemoSize),
+			MaxKitties:   ctx.Int(TxMaxKitties),
+			MaxClockSkew: ctx.Duration(TxMaxClockSkew),
+		},
+		TxRateLimit:  ctx.Int(TxRateLimit),
+		TxRateWindow: ctx.Duration(TxRateWindow),
+	}
+
+	// Prepare snapshots.
+	if dir := ctx.String(SnapshotDir); dir != "" {
+		if bcConfig.SnapshotDB, e = iko.NewFileSnapshotDB(dir); e != nil {
+			return e
+		}
 	}
 
 	// Prepare blockchain.
@@ -147,6 +468,38 @@ func action(ctx *cli.Context) error {
 	defer bc.Close()
 	log.Info("finished preparing blockchain")
 
+	// Verify state.
+	if ctx.Bool(VerifyState) {
+		mismatches, e := bc.VerifyState()
+		if e != nil {
+			return e
+		}
+		for _, m := range mismatches {
+			log.WithField("kitty_id", m.KittyID).
+				Error("state does not match chain")
+		}
+		if len(mismatches) > 0 {
+			return fmt.Errorf("state verification failed with %d mismatched kitties",
+				len(mismatches))
+		}
+		log.Info("finished verifying state")
+	}
+
+	// Prepare kitty metadata.
+	if fPath := ctx.String(KittyMetaFile); fPath != "" {
+		metas, e := iko.LoadKittyMetaFile(fPath)
+		if e != nil {
+			return e
+		}
+		for _, m := range metas {
+			if e := bc.SetKittyMeta(m); e != nil {
+				return e
+			}
+		}
+		log.WithField("count", len(metas)).
+			Info("finished loading kitty metadata")
+	}
+
 	// Prepare test data.
 	if testMode {
 		var tx *iko.Transaction
@@ -163,23 +516,111 @@ func action(ctx *cli.Context) error {
 	}
 
 	// Prepare wallet.
-	os.MkdirAll("wallet", os.FileMode(0700))
-	wallet.SetRootDir("wallet")
-	walletManager, e := wallet.NewManager()
+	walletDir, e := walletRootDir(ctx.String(WalletDir))
 	if e != nil {
 		return e
 	}
+	if testMode || ctx.Bool(MemoryWallets) {
+		wallet.SetStore(wallet.NewMemoryStore())
+		log.Warning("keeping wallets in memory, they are gone once the node exits")
+	}
+	if e := wallet.SetRootDir(walletDir); e != nil {
+		return e
+	}
+	log.Infof("using wallet directory `%s`", walletDir)
+	if addr := ctx.String(VaultAddress); addr != "" {
+		wallet.RegisterKeystore(wallet.KeystoreVault,
+			wallet.NewVaultKeystore(addr, ctx.String(VaultToken), ctx.String(VaultMount)))
+		log.Infof("using vault `%s` as the wallet keystore '%s'", addr, wallet.KeystoreVault)
+	}
+	walletManager, e := wallet.NewManagerWithConfig(wallet.ManagerConfig{
+		RequireEncrypted: ctx.Bool(RequireEncryptedWallets),
+		MinPasswordScore: ctx.Int(MinPasswordScore),
+		AuditSecret:      []byte(ctx.String(AuditSecret)),
+	})
+	if e != nil {
+		return e
+	}
+	defer walletManager.Close()
+	walletManager.WatchChain(bc)
+	walletManager.WatchDir(ctx.Duration(WalletReloadInterval))
+	if n := ctx.Int(LedgerAddresses); n > 0 {
+		dev, e := wallet.OpenLedger()
+		if e != nil {
+			return e
+		}
+		ledger, e := wallet.NewLedgerSigner("ledger", dev, n)
+		if e != nil {
+			dev.Close()
+			return e
+		}
+		defer ledger.Close()
+		if e := walletManager.AddSigner(ledger); e != nil {
+			return e
+		}
+		log.Infof("using ledger device with %d addresses as the wallet 'ledger'", n)
+	}
+
+	// Prepare API keys.
+	var apiKeys *http.APIKeyStore
+	if fPath := ctx.String(APIKeyFile); fPath != "" {
+		if apiKeys, e = http.NewAPIKeyStore(fPath); e != nil {
+			return e
+		}
+		if !apiKeys.HasAdmin() {
+			key, _, e := apiKeys.Create("admin", true)
+			if e != nil {
+				return e
+			}
+			log.Warningf("created admin API key '%s', which is not shown again", key)
+		}
+	}
+
+	// Prepare sessions.
+	var sessions *http.SessionManager
+	if hash := ctx.String(SessionPasswordHash); hash != "" {
+		sessions, e = http.NewSessionManager(hash,
+			ctx.Duration(SessionTTL), ctx.Duration(SessionRefreshTTL))
+		if e != nil {
+			return e
+		}
+	}
+
+	// Prepare rate limits.
+	rateLimits := http.RateLimitConfig{
+		Limits:       make(map[string]http.RateLimit),
+		ForwardedFor: ctx.Bool(RateLimitForwardedFor),
+	}
+	for _, s := range ctx.StringSlice(RateLimits) {
+		group, limit, e := http.ParseRateLimit(s)
+		if e != nil {
+			return e
+		}
+		rateLimits.Limits[group] = limit
+	}
 
 	// Prepare http server.
 	httpServer, e := http.NewServer(
 		&http.ServerConfig{
-			Address:   ctx.String(HttpAddress),
-			EnableGUI: ctx.BoolT(GUI),
-			EnableTLS: false,
+			Address:    ctx.String(HttpAddress),
+			EnableGUI:  ctx.BoolT(GUI),
+			EnableTLS:  false,
+			APIVersion: ctx.Int(APIVersion),
+			EnableCSRF: ctx.BoolT(CSRF),
+			CORS: http.CORSConfig{
+				AllowedOrigins:   ctx.StringSlice(CORSOrigins),
+				AllowedMethods:   ctx.StringSlice(CORSMethods),
+				AllowedHeaders:   ctx.StringSlice(CORSHeaders),
+				AllowCredentials: ctx.Bool(CORSCredentials),
+			},
+			RateLimits:  rateLimits,
+			LogRequests: ctx.BoolT(LogRequests),
 		},
 		&http.Gateway{
-			IKO:    bc,
-			Wallet: walletManager,
+			IKO:      bc,
+			Wallet:   walletManager,
+			APIKeys:  apiKeys,
+			Sessions: sessions,
 		},
 	)
 	if e != nil {
@@ -187,6 +628,25 @@ func action(ctx *cli.Context) error {
 	}
 	defer httpServer.Close()
 
+	// Prepare grpc server.
+	if addr := ctx.String(GrpcAddress); addr != "" {
+		rpcConfig := &rpc.ServerConfig{
+			Address: addr,
+			APIKeys: apiKeys,
+		}
+		if rateLimits.Enabled() {
+			if rpcConfig.RateLimits, e = http.NewRateLimits(rateLimits, apiKeys); e != nil {
+				return e
+			}
+		}
+		rpcServer, e := rpc.NewServer(rpcConfig, bc)
+		if e != nil {
+			return e
+		}
+		defer rpcServer.Close()
+		log.Infof("serving grpc service '%s' on `%s`", rpc.ServiceName, rpcServer.Addr())
+	}
+
 	<-quit
 	return nil
 }
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/kittycash/wallet/legacy/ex24/store"
+	"github.com/kittycash/wallet/src/http"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/kittycash/wallet/src/wallet"
+	"github.com/skycoin/skycoin/src/cipher"
 	"gopkg.in/urfave/cli.v1"
 	"log"
 	"os"
+	"strings"
+	"time"
 )
 
 var app = cli.NewApp()
@@ -41,6 +51,245 @@ func init() {
 				},
 			},
 		},
+		cli.Command{
+			Name:  "tx",
+			Usage: "tools for signing transactions offline",
+			Subcommands: cli.Commands{
+				cli.Command{
+					Name:  "sign",
+					Usage: "sign an unsigned transaction (hex) and print the signed transaction (hex)",
+					Flags: cli.FlagsByName{
+						cli.StringFlag{
+							Name:  "raw, r",
+							Usage: "unsigned transaction, as obtained from '/api/iko/unsigned_transfer'",
+						},
+						cli.StringFlag{
+							Name:  "secret-key, sk",
+							Usage: "secret key to sign with (for the signature scheme of the transaction)",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						raw, e := hex.DecodeString(ctx.String("raw"))
+						if e != nil {
+							return e
+						}
+						tx, e := iko.DecodeTx(raw)
+						if e != nil {
+							return e
+						}
+						if tx.SigScheme() != iko.Secp256k1Scheme {
+							skRaw, e := hex.DecodeString(ctx.String("secret-key"))
+							if e != nil {
+								return e
+							}
+							if e := tx.SignScheme(skRaw); e != nil {
+								return e
+							}
+							fmt.Println(hex.EncodeToString(tx.Serialize()))
+							return nil
+						}
+						sk, e := cipher.SecKeyFromHex(ctx.String("secret-key"))
+						if e != nil {
+							return e
+						}
+						if e := tx.AttachSignature(cipher.SignHash(tx.SignatureHash(), sk)); e != nil {
+							return e
+						}
+						fmt.Println(hex.EncodeToString(tx.Serialize()))
+						return nil
+					},
+				},
+				cli.Command{
+					Name:  "explain",
+					Usage: "print a breakdown of a transaction (hex)",
+					Flags: cli.FlagsByName{
+						cli.StringFlag{
+							Name:  "raw, r",
+							Usage: "transaction to explain",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						raw, e := hex.DecodeString(ctx.String("raw"))
+						if e != nil {
+							return e
+						}
+						tx, e := iko.DecodeTx(raw)
+						if e != nil {
+							return e
+						}
+						fmt.Print(tx.Explain().String())
+						return nil
+					},
+				},
+				cli.Command{
+					Name:  "countersign",
+					Usage: "countersign a signed burn (hex) with the master key and print the countersigned transaction (hex)",
+					Flags: cli.FlagsByName{
+						cli.StringFlag{
+							Name:  "raw, r",
+							Usage: "burn transaction, signed by the owner of the kitties",
+						},
+						cli.StringFlag{
+							Name:  "secret-key, sk",
+							Usage: "master secret key to countersign with",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						sk, e := cipher.SecKeyFromHex(ctx.String("secret-key"))
+						if e != nil {
+							return e
+						}
+						raw, e := hex.DecodeString(ctx.String("raw"))
+						if e != nil {
+							return e
+						}
+						tx, e := iko.DecodeTx(raw)
+						if e != nil {
+							return e
+						}
+						if !tx.IsBurn() {
+							return errors.New("transaction is not a burn")
+						}
+						tx.Countersign(sk)
+						fmt.Println(hex.EncodeToString(tx.Serialize()))
+						return nil
+					},
+				},
+				cli.Command{
+					Name:  "delegate",
+					Usage: "authorize an operator to transfer a kitty once, and print the signed delegation (hex)",
+					Flags: cli.FlagsByName{
+						cli.Uint64Flag{
+							Name:  "kitty-id, k",
+							Usage: "kitty to authorize the transfer of",
+						},
+						cli.Uint64Flag{
+							Name:  "nonce, n",
+							Usage: "nonce of the transfer, as the 'next_nonce' of the owner",
+						},
+						cli.StringFlag{
+							Name:  "operator, o",
+							Usage: "public key of the operator",
+						},
+						cli.DurationFlag{
+							Name:  "valid-for",
+							Usage: "duration that the delegation is valid for, 0 never expires",
+						},
+						cli.StringFlag{
+							Name:  "secret-key, sk",
+							Usage: "secret key of the owner",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						sk, e := cipher.SecKeyFromHex(ctx.String("secret-key"))
+						if e != nil {
+							return e
+						}
+						operator, e := cipher.PubKeyFromHex(ctx.String("operator"))
+						if e != nil {
+							return e
+						}
+						d := iko.Delegation{
+							KittyID:  iko.KittyID(ctx.Uint64("kitty-id")),
+							Nonce:    ctx.Uint64("nonce"),
+							Operator: operator,
+						}
+						if validFor := ctx.Duration("valid-for"); validFor > 0 {
+							d.Expiry = time.Now().Add(validFor).UnixNano()
+						}
+						fmt.Println(hex.EncodeToString(d.Sign(sk).Serialize()))
+						return nil
+					},
+				},
+			},
+		},
+		cli.Command{
+			Name:  "paper",
+			Usage: "generate a keypair as a printable paper wallet, for offline storage",
+			Flags: cli.FlagsByName{
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "file to write the printable page (html) to, the paper wallet is printed to the terminal if empty",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				pw, e := wallet.NewPaperWallet()
+				if e != nil {
+					return e
+				}
+				if out := ctx.String("out"); out != "" {
+					f, e := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+					if e != nil {
+						return e
+					}
+					if e := pw.HTML(f); e != nil {
+						f.Close()
+						return e
+					}
+					if e := f.Close(); e != nil {
+						return e
+					}
+					fmt.Println(pw.Address)
+					return nil
+				}
+				fmt.Printf("address:    %s\n", pw.Address)
+				fmt.Print(pw.AddressQRCode().String())
+				fmt.Printf("secret key: %s\n", pw.SecKey)
+				fmt.Print(pw.SecKeyQRCode().String())
+				return nil
+			},
+		},
+		cli.Command{
+			Name:  "hash-password",
+			Usage: "hash the password (from the first line of stdin) for the sessions of wallet routes, for '--session-password-hash' of iko",
+			Action: func(ctx *cli.Context) error {
+				line, e := bufio.NewReader(os.Stdin).ReadString('\n')
+				if e != nil && line == "" {
+					return e
+				}
+				hash, e := http.HashSessionPassword(strings.TrimRight(line, "\r\n"))
+				if e != nil {
+					return e
+				}
+				fmt.Println(hash)
+				return nil
+			},
+		},
+		cli.Command{
+			Name:  "meta",
+			Usage: "tools for managing kitty metadata",
+			Subcommands: cli.Commands{
+				cli.Command{
+					Name:  "sign",
+					Usage: "sign a kitty metadata file with the master secret key",
+					Flags: cli.FlagsByName{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "kitty metadata file to sign (in place)",
+							Value: "kitty_meta.json",
+						},
+						cli.StringFlag{
+							Name:  "secret-key, sk",
+							Usage: "master secret key to sign with",
+						},
+					},
+					Action: func(ctx *cli.Context) error {
+						sk, e := cipher.SecKeyFromHex(ctx.String("secret-key"))
+						if e != nil {
+							return e
+						}
+						metas, e := iko.LoadKittyMetaFile(ctx.String("file"))
+						if e != nil {
+							return e
+						}
+						for i, m := range metas {
+							metas[i] = iko.NewSignedKittyMeta(m.KittyID, m.Meta, sk)
+						}
+						return iko.SaveKittyMetaFile(ctx.String("file"), metas)
+					},
+				},
+			},
+		},
 	}
 }
 
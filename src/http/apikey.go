@@ -0,0 +1,387 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kittycash/wallet/src/wallet"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API keys of a node are optional. If the gateway has an 'APIKeyStore', the
+// routes that mutate the chain or the wallets (and all wallet routes) require
+// a key, while the routes that read the chain stay public. Keys are sent in the
+// 'X-API-Key' header, or as 'Authorization: Bearer <key>'. Only the SHA-256
+// hashes of keys are stored, so a key is only shown once, as it is created.
+const (
+	APIKeyHeader     = "X-API-Key"
+	apiKeyPrefix     = "kc_"
+	apiKeyIDSize     = 8
+	apiKeySecretSize = 32
+)
+
+var (
+	ErrAPIKeyRequired = errors.New("an API key is required")
+	ErrAPIKeyInvalid  = errors.New("invalid API key")
+	ErrAPIKeyNotAdmin = errors.New("an admin API key is required")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey is a stored API key, with the hash of the key.
+type APIKey struct {
+	ID      string    `json:"id"`
+	Label   string    `json:"label"`
+	Hash    string    `json:"hash"` // Hex of the SHA-256 hash of the key.
+	Admin   bool      `json:"admin"`
+	Created time.Time `json:"created"`
+}
+
+// APIKeyReply is an API key without its hash.
+type APIKeyReply struct {
+	ID      string    `json:"id"`
+	Label   string    `json:"label"`
+	Admin   bool      `json:"admin"`
+	Created time.Time `json:"created"`
+}
+
+func NewAPIKeyReply(k APIKey) APIKeyReply {
+	return APIKeyReply{ID: k.ID, Label: k.Label, Admin: k.Admin, Created: k.Created}
+}
+
+// APIKeyStore stores the hashes of API keys in a file (or only in memory if
+// the path is empty).
+type APIKeyStore struct {
+	mux  sync.RWMutex
+	path string
+	keys []APIKey
+}
+
+// NewAPIKeyStore creates an APIKeyStore with the keys of the file, if it exists.
+func NewAPIKeyStore(fPath string) (*APIKeyStore, error) {
+	s := &APIKeyStore{path: fPath}
+	if fPath == "" {
+		return s, nil
+	}
+	raw, e := wallet.DirStore{}.ReadFile(fPath)
+	if os.IsNotExist(e) {
+		return s, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(raw, &s.keys); e != nil {
+		return nil, fmt.Errorf("invalid API key file '%s': %v", fPath, e)
+	}
+	return s, nil
+}
+
+// Create creates an API key with the label. The key is only returned here.
+func (s *APIKeyStore) Create(label string, admin bool) (string, APIKey, error) {
+	id, secret := make([]byte, apiKeyIDSize/2), make([]byte, apiKeySecretSize)
+	if _, e := rand.Read(id); e != nil {
+		return "", APIKey{}, e
+	}
+	if _, e := rand.Read(secret); e != nil {
+		return "", APIKey{}, e
+	}
+	var (
+		idHex = hex.EncodeToString(id)
+		key   = apiKeyPrefix + idHex + "_" + hex.EncodeToString(secret)
+		k     = APIKey{
+			ID:      idHex,
+			Label:   strings.TrimSpace(label),
+			Hash:    hashAPIKey(key),
+			Admin:   admin,
+			Created: time.Now().UTC(),
+		}
+	)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.keys = append(s.keys, k)
+	if e := s.save(); e != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return "", APIKey{}, e
+	}
+	return key, k, nil
+}
+
+// Revoke removes the API key with the ID. If the last admin key is revoked, a
+// new one is created as the node starts (see 'HasAdmin').
+func (s *APIKeyStore) Revoke(id string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i, k := range s.keys {
+		if k.ID != id {
+			continue
+		}
+		old := s.keys
+		s.keys = append(append([]APIKey(nil), old[:i]...), old[i+1:]...)
+		if e := s.save(); e != nil {
+			s.keys = old
+			return e
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: '%s'", ErrAPIKeyNotFound, id)
+}
+
+// List obtains the API keys, in the order of creation.
+func (s *APIKeyStore) List() []APIKey {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	out := append([]APIKey(nil), s.keys...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// HasAdmin determines whether there is an admin API key.
+func (s *APIKeyStore) HasAdmin() bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify obtains the stored API key that matches the key.
+func (s *APIKeyStore) Verify(key string) (APIKey, bool) {
+	hash := hashAPIKey(key)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+func (s *APIKeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := s.keys
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	raw, e := json.MarshalIndent(keys, "", "    ")
+	if e != nil {
+		return e
+	}
+	return wallet.DirStore{}.WriteFile(s.path, raw)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+	<<< MIDDLEWARE >>>
+*/
+
+type apiKeyScope int
+
+const (
+	apiKeyPublic apiKeyScope = iota
+	apiKeyWallet             // Needs a key, or a session (see 'requireSessions').
+	apiKeyWrite
+	apiKeyAdmin
+)
+
+// readOnlyPOSTs are 'POST' routes that do not mutate the chain or the
+// wallets. Methods of '/api/jsonrpc' have their own scopes (see
+// 'jsonrpcMethod.Mutating').
+var readOnlyPOSTs = map[string]bool{
+	"/api/graphql":         true,
+	"/api/jsonrpc":         true,
+	"/api/iko/simulate_tx": true,
+	"/api/iko/explain_tx":  true,
+}
+
+// apiKeyScopeOf obtains the API key scope that a route requires.
+func apiKeyScopeOf(method, p string) apiKeyScope {
+	switch {
+	case strings.HasPrefix(p, "/api/admin/"), strings.HasPrefix(p, "/api/iko/admin/"):
+		return apiKeyAdmin
+	case isWalletRoute(p):
+		return apiKeyWallet
+	case strings.HasPrefix(p, "/api/auth/"):
+		return apiKeyPublic // Sessions are checked by 'SessionManager'.
+	case method == http.MethodGet, method == http.MethodHead, method == http.MethodOptions:
+		return apiKeyPublic
+	case readOnlyPOSTs[p]:
+		return apiKeyPublic
+	default:
+		return apiKeyWrite
+	}
+}
+
+type apiKeyContextKey struct{}
+
+// apiKeyAuth is set in the context of requests by the API key middleware.
+type apiKeyAuth struct {
+	key *APIKey // Set for requests with a valid key.
+}
+
+// requireAPIKeys is the API key middleware for the keys of the store. Requests with an
+// invalid key are rejected on all routes.
+func requireAPIKeys(keys *APIKeyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := &apiKeyAuth{}
+		if key := APIKeyOfRequest(r); key != "" {
+			k, ok := keys.Verify(key)
+			if !ok {
+				sendJson(w, http.StatusUnauthorized, ErrAPIKeyInvalid.Error())
+				return
+			}
+			auth.key = &k
+		}
+
+		switch apiKeyScopeOf(r.Method, unversionedPath(r.URL.Path)) {
+		case apiKeyAdmin:
+			if auth.key == nil {
+				sendJson(w, http.StatusUnauthorized, ErrAPIKeyRequired.Error())
+				return
+			}
+			if !auth.key.Admin {
+				sendJson(w, http.StatusForbidden, ErrAPIKeyNotAdmin.Error())
+				return
+			}
+		case apiKeyWallet:
+			if auth.key == nil && !hasSession(r) {
+				sendJson(w, http.StatusUnauthorized, ErrAPIKeyRequired.Error())
+				return
+			}
+		case apiKeyWrite:
+			if auth.key == nil {
+				sendJson(w, http.StatusUnauthorized, ErrAPIKeyRequired.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, auth)))
+	})
+}
+
+// APIKeyOfRequest obtains the API key from the 'X-API-Key' header or from the
+// bearer token of a request, or "" if the request has no key.
+func APIKeyOfRequest(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return strings.TrimSpace(key)
+	}
+	// Bearer tokens are either API keys or session tokens (see 'SessionManager').
+	if token := bearerOfRequest(r); strings.HasPrefix(token, apiKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// apiKeyAuthorized determines whether a request may mutate, which is true for
+// requests with a valid key, or for all requests if API keys are disabled.
+func apiKeyAuthorized(r *http.Request) bool {
+	auth, ok := r.Context().Value(apiKeyContextKey{}).(*apiKeyAuth)
+	return !ok || auth.key != nil
+}
+
+/*
+	<<< ADMIN >>>
+*/
+
+func apiKeyGateway(mux *http.ServeMux, keys *APIKeyStore) error {
+
+	Handle(mux, "/api/admin/api_keys/list",
+		"GET", listAPIKeys(keys))
+
+	Handle(mux, "/api/admin/api_keys/new",
+		"POST", newAPIKey(keys))
+
+	Handle(mux, "/api/admin/api_keys/revoke",
+		"POST", revokeAPIKey(keys))
+
+	return nil
+}
+
+type APIKeysReply struct {
+	Keys []APIKeyReply `json:"keys"`
+}
+
+func listAPIKeys(keys *APIKeyStore) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		reply := APIKeysReply{Keys: []APIKeyReply{}}
+		for _, k := range keys.List() {
+			reply.Keys = append(reply.Keys, NewAPIKeyReply(k))
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+// CreatedAPIKeyReply holds the key, which is not shown again.
+type CreatedAPIKeyReply struct {
+	Key string `json:"key"`
+	APIKeyReply
+}
+
+func newAPIKey(keys *APIKeyStore) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		var (
+			label = r.PostFormValue("label")
+			admin = r.PostFormValue("admin") == "true"
+		)
+		if label == "" {
+			return sendJson(w, http.StatusBadRequest,
+				"'label' is required")
+		}
+		key, k, e := keys.Create(label, admin)
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, CreatedAPIKeyReply{Key: key, APIKeyReply: NewAPIKeyReply(k)})
+	}
+}
+
+func revokeAPIKey(keys *APIKeyStore) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		id := r.PostFormValue("id")
+		if id == "" {
+			return sendJson(w, http.StatusBadRequest,
+				"'id' is required")
+		}
+		if e := keys.Revoke(id); e != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(e, ErrAPIKeyNotFound) {
+				status = http.StatusNotFound
+			}
+			return sendJson(w, status,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyStore(t *testing.T) {
+	dir, e := ioutil.TempDir("", "api_keys")
+	require.Nil(t, e)
+	defer os.RemoveAll(dir)
+	fPath := filepath.Join(dir, "api_keys.json")
+
+	keys, e := NewAPIKeyStore(fPath)
+	require.Nil(t, e)
+	require.False(t, keys.HasAdmin())
+
+	key, k, e := keys.Create("admin", true)
+	require.Nil(t, e)
+	require.True(t, strings.HasPrefix(key, apiKeyPrefix+k.ID+"_"))
+
+	raw, e := ioutil.ReadFile(fPath)
+	require.Nil(t, e)
+	require.NotContains(t, string(raw), key, "keys should only be stored as their hashes")
+
+	keys, e = NewAPIKeyStore(fPath)
+	require.Nil(t, e)
+	require.True(t, keys.HasAdmin(), "keys should be loaded from the file")
+	got, ok := keys.Verify(key)
+	require.True(t, ok)
+	require.Equal(t, k.ID, got.ID)
+	_, ok = keys.Verify(key + "0")
+	require.False(t, ok)
+
+	require.Nil(t, keys.Revoke(k.ID))
+	require.Error(t, keys.Revoke(k.ID))
+	_, ok = keys.Verify(key)
+	require.False(t, ok, "revoked keys should not verify")
+}
+
+func TestRequireAPIKeys(t *testing.T) {
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	keys := &APIKeyStore{}
+	admin, _, e := keys.Create("admin", true)
+	require.Nil(t, e)
+	writer, _, e := keys.Create("writer", false)
+	require.Nil(t, e)
+
+	mux := http.NewServeMux()
+	require.Nil(t, (&Gateway{IKO: bc, APIKeys: keys}).host(mux, &ServerConfig{}))
+
+	do := func(method, target, key, body string) (int, string) {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if method == "POST" && !strings.HasPrefix(body, "{") {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if key != "" {
+			r.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, _ := do("GET", "/api/txs", "", "")
+	require.Equal(t, http.StatusOK, code, "read routes should be public")
+	code, _ = do("GET", "/api/txs", "invalid", "")
+	require.Equal(t, http.StatusUnauthorized, code, "invalid keys should be rejected on all routes")
+
+	code, _ = do("POST", "/api/iko/inject_tx", "", "{}")
+	require.Equal(t, http.StatusUnauthorized, code, "mutating routes should require a key")
+	code, _ = do("POST", "/api/v1/iko/inject_tx", writer, "{}")
+	require.Equal(t, http.StatusBadRequest, code, "mutating routes should require keys")
+
+	code, _ = do("GET", "/api/v1/iko/admin/verify_state", writer, "")
+	require.Equal(t, http.StatusForbidden, code, "admin routes should require an admin key")
+	code, _ = do("GET", "/api/iko/admin/verify_state", admin, "")
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do("POST", "/api/iko/admin/rollback", "", url.Values{"seq": {"0"}}.Encode())
+	require.Equal(t, http.StatusUnauthorized, code, "rollbacks should require an admin key")
+
+	open := http.NewServeMux()
+	require.Nil(t, (&Gateway{IKO: bc}).host(open, &ServerConfig{}))
+	rec := httptest.NewRecorder()
+	open.ServeHTTP(rec, httptest.NewRequest("POST", "/api/iko/admin/rollback?seq=0", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code, "admin routes should not be handled without API keys")
+
+	_, body := do("POST", "/api/jsonrpc", "", `[
+		{"jsonrpc": "2.0", "id": 1, "method": "getTxRange", "params": {}},
+		{"jsonrpc": "2.0", "id": 2, "method": "injectTx", "params": {"hex": "00"}}
+	]`)
+	var replies []JSONRPCResponse
+	require.Nil(t, json.Unmarshal([]byte(body), &replies), body)
+	require.Nil(t, replies[0].Error, "read methods should be public")
+	require.Equal(t, JSONRPCUnauthorized, replies[1].Error.Code, "mutating methods should require a key")
+
+	code, body = do("POST", "/api/admin/api_keys/new", admin, url.Values{"label": {"gui"}}.Encode())
+	require.Equal(t, http.StatusOK, code, body)
+	var created CreatedAPIKeyReply
+	require.Nil(t, json.Unmarshal([]byte(body), &created))
+	require.False(t, created.Admin)
+
+	code, _ = do("POST", "/api/iko/inject_tx", created.Key, "{}")
+	require.Equal(t, http.StatusBadRequest, code, "created keys should be accepted")
+	code, _ = do("POST", "/api/admin/api_keys/revoke", admin, url.Values{"id": {created.ID}}.Encode())
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do("POST", "/api/iko/inject_tx", created.Key, "{}")
+	require.Equal(t, http.StatusUnauthorized, code, "revoked keys should be rejected")
+
+	code, body = do("GET", "/api/admin/api_keys/list", "", "")
+	require.Equal(t, http.StatusUnauthorized, code, body)
+	code, body = do("GET", "/api/admin/api_keys/list", admin, "")
+	require.Equal(t, http.StatusOK, code, body)
+	require.NotContains(t, body, "hash", "listed keys should not include their hashes")
+}
@@ -20,7 +20,7 @@ type RespMeta struct {
 
 func GetKittyState(httpAddr string, kittyID iko.KittyID) (*iko.KittyState, *RespMeta) {
 	r, e := http.DefaultClient.Get(
-		path.Join(httpAddr, "/api/iko/kitty/", fmt.Sprintf("%d.enc", kittyID)),
+		path.Join(httpAddr, "/api/v1/iko/kitty/", fmt.Sprintf("%d.enc", kittyID)),
 	)
 	if e != nil {
 		return nil, &RespMeta{
@@ -48,7 +48,7 @@ func GetKittyState(httpAddr string, kittyID iko.KittyID) (*iko.KittyState, *Resp
 
 func GetAddressState(httpAddr string, address cipher.Address) (*iko.AddressState, *RespMeta) {
 	r, e := http.DefaultClient.Get(
-		path.Join(httpAddr, "/api/iko/address/", fmt.Sprintf("%s.enc", address.String())),
+		path.Join(httpAddr, "/api/v1/iko/address/", fmt.Sprintf("%s.enc", address.String())),
 	)
 	if e != nil {
 		return nil, &RespMeta{
@@ -74,9 +74,38 @@ func GetAddressState(httpAddr string, address cipher.Address) (*iko.AddressState
 	}
 }
 
+// GetAddressCount obtains the number of kitties owned by an address.
+func GetAddressCount(httpAddr string, address cipher.Address) (uint64, *RespMeta) {
+	r, e := http.DefaultClient.Get(
+		path.Join(httpAddr, "/api/v1/iko/address_count/", fmt.Sprintf("%s.enc", address.String())),
+	)
+	if e != nil {
+		return 0, &RespMeta{
+			false, -1, e,
+		}
+	}
+	raw, _ := ioutil.ReadAll(r.Body)
+	switch r.StatusCode {
+	case http.StatusOK:
+		var count uint64
+		if e := encoder.DeserializeRaw(raw, &count); e != nil {
+			return 0, &RespMeta{
+				true, r.StatusCode, e,
+			}
+		}
+		return count, &RespMeta{
+			true, r.StatusCode, nil,
+		}
+	default:
+		return 0, &RespMeta{
+			true, r.StatusCode, errors.New(string(raw)),
+		}
+	}
+}
+
 func GetTxOfHash(httpAddr string, txHash iko.TxHash) (*iko.Transaction, *RespMeta) {
 	r, e := http.DefaultClient.Get(
-		path.Join(httpAddr, "/api/iko/tx/", fmt.Sprintf("%s.enc?request=hash", txHash.Hex())),
+		path.Join(httpAddr, "/api/v1/iko/tx/", fmt.Sprintf("%s.enc?request=hash", txHash.Hex())),
 	)
 	if e != nil {
 		return nil, &RespMeta{
@@ -86,8 +115,8 @@ func GetTxOfHash(httpAddr string, txHash iko.TxHash) (*iko.Transaction, *RespMet
 	raw, _ := ioutil.ReadAll(r.Body)
 	switch r.StatusCode {
 	case http.StatusOK:
-		tx := new(iko.Transaction)
-		if e := encoder.DeserializeRaw(raw, tx); e != nil {
+		tx, e := iko.DecodeTx(raw)
+		if e != nil {
 			return nil, &RespMeta{
 				true, r.StatusCode, e,
 			}
@@ -104,7 +133,7 @@ func GetTxOfHash(httpAddr string, txHash iko.TxHash) (*iko.Transaction, *RespMet
 
 func GetTxOfSeq(httpAddr string, txSeq uint64) (*iko.Transaction, *RespMeta) {
 	r, e := http.DefaultClient.Get(
-		path.Join(httpAddr, "/api/iko/tx/", fmt.Sprintf("%d.enc?request=seq", txSeq)),
+		path.Join(httpAddr, "/api/v1/iko/tx/", fmt.Sprintf("%d.enc?request=seq", txSeq)),
 	)
 	if e != nil {
 		return nil, &RespMeta{
@@ -114,8 +143,8 @@ func GetTxOfSeq(httpAddr string, txSeq uint64) (*iko.Transaction, *RespMeta) {
 	raw, _ := ioutil.ReadAll(r.Body)
 	switch r.StatusCode {
 	case http.StatusOK:
-		tx := new(iko.Transaction)
-		if e := encoder.DeserializeRaw(raw, tx); e != nil {
+		tx, e := iko.DecodeTx(raw)
+		if e != nil {
 			return nil, &RespMeta{
 				true, r.StatusCode, e,
 			}
@@ -133,7 +162,7 @@ func GetTxOfSeq(httpAddr string, txSeq uint64) (*iko.Transaction, *RespMeta) {
 // GetHeadTx obtains the head transaction.
 func GetHeadTx(httpAddr string) (*iko.Transaction, *RespMeta) {
 	r, e := http.DefaultClient.Get(
-		path.Join(httpAddr, "/api/iko/head_tx.enc"),
+		path.Join(httpAddr, "/api/v1/iko/head_tx.enc"),
 	)
 	if e != nil {
 		return nil, &RespMeta{
@@ -143,8 +172,8 @@ func GetHeadTx(httpAddr string) (*iko.Transaction, *RespMeta) {
 	raw, _ := ioutil.ReadAll(r.Body)
 	switch r.StatusCode {
 	case http.StatusOK:
-		tx := new(iko.Transaction)
-		if e := encoder.DeserializeRaw(raw, tx); e != nil {
+		tx, e := iko.DecodeTx(raw)
+		if e != nil {
 			return nil, &RespMeta{
 				true, r.StatusCode, e,
 			}
@@ -163,7 +192,7 @@ func GetHeadTx(httpAddr string) (*iko.Transaction, *RespMeta) {
 // Note that the transaction needs to be signed and the fields need to be correct. (eg. seq, prev)
 func InjectTx(httpAddr string, tx *iko.Transaction) *RespMeta {
 	r, e := http.DefaultClient.Post(
-		path.Join(httpAddr, "/api/iko/inject_tx"),
+		path.Join(httpAddr, "/api/v1/iko/inject_tx"),
 		"application/octet-stream",
 		bytes.NewReader(tx.Serialize()),
 	)
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS allows frontends and explorer sites on other origins to use the API
+// without a proxy. It is disabled (so the same-origin policy of browsers applies) if
+// no origins are allowed.
+var (
+	DefaultCORSMethods = []string{"GET", "HEAD", "POST"}
+	DefaultCORSHeaders = []string{
+		"Accept", "Content-Type", "Authorization",
+		APIKeyHeader, APIVersionHeader, CSRFHeader,
+	}
+	DefaultCORSMaxAge = 10 * time.Minute
+
+	// corsExposedHeaders are the headers of replies that scripts on other
+	// origins can read.
+	corsExposedHeaders = []string{APIVersionHeader, RequestIDHeader, "Retry-After"}
+)
+
+// CORSConfig is the CORS policy of the API.
+type CORSConfig struct {
+	AllowedOrigins   []string      // Such as 'https://explorer.example.com', 'https://*.example.com', or '*' for all.
+	AllowedMethods   []string      // 'DefaultCORSMethods' if empty.
+	AllowedHeaders   []string      // 'DefaultCORSHeaders' if empty.
+	AllowCredentials bool          // Whether requests may carry cookies, which is not allowed with '*'.
+	MaxAge           time.Duration // For preflight replies, 'DefaultCORSMaxAge' if 0.
+}
+
+// Enabled determines whether any origin is allowed.
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// cors is the CORS policy of a config, with lower case methods and headers.
+type cors struct {
+	all         bool
+	origins     map[string]bool
+	suffixes    []string // For origins of subdomains, such as 'https://' and '.example.com'.
+	schemes     []string
+	methods     map[string]bool
+	headers     map[string]bool
+	credentials bool
+	maxAge      string
+	allow       struct{ methods, headers string }
+}
+
+func newCORS(c CORSConfig) (*cors, error) {
+	out := &cors{
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: c.AllowCredentials,
+	}
+	for _, origin := range c.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		if origin == "*" {
+			out.all = true
+			continue
+		}
+		u, e := url.Parse(origin)
+		if e != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return nil, fmt.Errorf("invalid CORS origin '%s', expected the form 'https://example.com'", origin)
+		}
+		if strings.HasPrefix(u.Host, "*.") {
+			out.schemes = append(out.schemes, u.Scheme+"://")
+			out.suffixes = append(out.suffixes, strings.TrimPrefix(u.Host, "*"))
+			continue
+		}
+		out.origins[origin] = true
+	}
+	if out.all && out.credentials {
+		return nil, errors.New("CORS credentials can not be allowed for all origins ('*')")
+	}
+
+	methods, headers := c.AllowedMethods, c.AllowedHeaders
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+	methods = append([]string(nil), methods...)
+	for i, m := range methods {
+		methods[i] = strings.ToUpper(strings.TrimSpace(m))
+		out.methods[methods[i]] = true
+	}
+	for _, h := range headers {
+		out.headers[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	out.allow.methods = strings.Join(methods, ", ")
+	out.allow.headers = strings.Join(headers, ", ")
+
+	maxAge := c.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultCORSMaxAge
+	}
+	out.maxAge = strconv.Itoa(int(maxAge / time.Second))
+	return out, nil
+}
+
+func (c *cors) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	if c.all || c.origins[origin] {
+		return true
+	}
+	for i, suffix := range c.suffixes {
+		if strings.HasPrefix(origin, c.schemes[i]) && strings.HasSuffix(origin, suffix) &&
+			len(origin) > len(c.schemes[i])+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireCORS is the CORS policy middleware. Preflight requests are
+// answered by the policy, and other requests are served with the headers of the
+// policy if they come from an allowed origin.
+func requireCORS(c *cors, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		var (
+			allowed   = c.allowed(origin)
+			preflight = r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		)
+		if preflight {
+			c.preflight(w, r, origin, allowed)
+			return
+		}
+		if allowed {
+			c.setOrigin(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request, origin string, allowed bool) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	if !allowed {
+		sendJson(w, http.StatusForbidden,
+			fmt.Sprintf("origin '%s' is not allowed", origin))
+		return
+	}
+	if method := r.Header.Get("Access-Control-Request-Method"); !c.methods[strings.ToUpper(method)] {
+		sendJson(w, http.StatusForbidden,
+			fmt.Sprintf("method '%s' is not allowed for other origins", method))
+		return
+	}
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" && !c.headers[h] {
+			sendJson(w, http.StatusForbidden,
+				fmt.Sprintf("header '%s' is not allowed for other origins", h))
+			return
+		}
+	}
+	c.setOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", c.allow.methods)
+	w.Header().Set("Access-Control-Allow-Headers", c.allow.headers)
+	w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.all {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireCORS(t *testing.T) {
+	policy, e := newCORS(CORSConfig{
+		AllowedOrigins: []string{"https://explorer.example.com/", "https://*.kittycash.com"},
+	})
+	require.Nil(t, e)
+
+	mux := http.NewServeMux()
+	Handle(mux, "/api/txs", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, true)
+	})
+	handler := requireCORS(policy, mux)
+
+	do := func(method, origin string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/txs", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do("GET", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), "requests without an origin should not get CORS headers")
+
+	for _, origin := range []string{"https://explorer.example.com", "https://gui.kittycash.com", "HTTPS://A.B.KITTYCASH.COM"} {
+		rec = do("GET", origin)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"), "origin '%s' should be allowed", origin)
+		require.Equal(t, APIVersionHeader+", "+RequestIDHeader+", Retry-After", rec.Header().Get("Access-Control-Expose-Headers"))
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	}
+	for _, origin := range []string{"https://evil.example.com", "http://gui.kittycash.com", "https://kittycash.com", "null"} {
+		rec = do("GET", origin)
+		require.Equal(t, http.StatusOK, rec.Code, "requests from other origins should be served (the same-origin policy applies)")
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), "origin '%s' should not be allowed", origin)
+	}
+
+	rec = do("OPTIONS", "https://explorer.example.com",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "content-type, x-api-key")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	require.Equal(t, "GET, HEAD, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	require.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), APIKeyHeader)
+	require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = do("OPTIONS", "https://explorer.example.com", "Access-Control-Request-Method", "DELETE")
+	require.Equal(t, http.StatusForbidden, rec.Code, "preflights for other methods should be rejected")
+	rec = do("OPTIONS", "https://explorer.example.com",
+		"Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "x-other")
+	require.Equal(t, http.StatusForbidden, rec.Code, "preflights for other headers should be rejected")
+	rec = do("OPTIONS", "https://evil.example.com", "Access-Control-Request-Method", "GET")
+	require.Equal(t, http.StatusForbidden, rec.Code, "preflights from other origins should be rejected")
+
+	policy, e = newCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	require.Nil(t, e)
+	rec = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/txs", nil)
+	r.Header.Set("Origin", "https://any.example.org")
+	requireCORS(policy, mux).ServeHTTP(rec, r)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	for _, c := range []CORSConfig{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"explorer.example.com"}},
+		{AllowedOrigins: []string{"https://example.com/path"}},
+	} {
+		_, e = newCORS(c)
+		require.Error(t, e, "config with origins '%v' should be invalid", c.AllowedOrigins)
+	}
+}
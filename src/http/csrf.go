@@ -0,0 +1,166 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// CSRF tokens protect the state-changing routes of the API from requests by
+// browsers (such as from the GUI), with signed double-submit cookies. The token
+// is issued by '/api/csrf_token' as both a cookie and the reply, and requests
+// from browsers are to carry the token as the 'X-CSRF-Token' header (or the
+// 'csrf_token' field of forms), with the same value as the cookie.
+//
+// Requests are from browsers if they have the 'Origin', 'Referer' or 'Sec-Fetch-Site'
+// headers, or the cookie. Requests with an API key or a session (in headers
+// that other origins can not set) are not checked.
+const (
+	CSRFHeader     = "X-CSRF-Token"
+	CSRFCookieName = "kittycash_csrf"
+	CSRFFormField  = "csrf_token"
+
+	csrfNonceSize = 32
+)
+
+var ErrCSRFToken = errors.New("invalid CSRF token, expected '" + CSRFHeader + "' and the cookie from '/api/csrf_token'")
+
+// CSRF issues and verifies CSRF tokens, signed with a secret of the process.
+type CSRF struct {
+	secret []byte
+}
+
+// NewCSRF creates a CSRF with a random secret.
+func NewCSRF() (*CSRF, error) {
+	secret := make([]byte, 32)
+	if _, e := rand.Read(secret); e != nil {
+		return nil, e
+	}
+	return &CSRF{secret: secret}, nil
+}
+
+// Token creates a token from a random nonce and its signature.
+func (c *CSRF) Token() (string, error) {
+	nonce := make([]byte, csrfNonceSize)
+	if _, e := rand.Read(nonce); e != nil {
+		return "", e
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." +
+		base64.RawURLEncoding.EncodeToString(c.mac(nonce)), nil
+}
+
+// Valid determines whether a token is signed with the secret.
+func (c *CSRF) Valid(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	nonce, e := base64.RawURLEncoding.DecodeString(parts[0])
+	if e != nil || len(nonce) != csrfNonceSize {
+		return false
+	}
+	sig, e := base64.RawURLEncoding.DecodeString(parts[1])
+	return e == nil && hmac.Equal(sig, c.mac(nonce))
+}
+
+func (c *CSRF) mac(nonce []byte) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write(nonce)
+	return h.Sum(nil)
+}
+
+// verify determines whether a request has a valid token, with the same value
+// as the cookie.
+func (c *CSRF) verify(r *http.Request) bool {
+	cookie, e := r.Cookie(CSRFCookieName)
+	if e != nil || !c.Valid(cookie.Value) {
+		return false
+	}
+	token := r.Header.Get(CSRFHeader)
+	if token == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		token = r.PostFormValue(CSRFFormField)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// requireCSRF is the CSRF token middleware for state-changing requests from
+// browsers.
+func requireCSRF(c *CSRF, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStateChanging(r.Method) && isOfBrowser(r) && !hasCredentialHeader(r) && !c.verify(r) {
+			sendJson(w, http.StatusForbidden, ErrCSRFToken.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isStateChanging(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+func isOfBrowser(r *http.Request) bool {
+	if r.Header.Get("Origin") != "" || r.Header.Get("Referer") != "" || r.Header.Get("Sec-Fetch-Site") != "" {
+		return true
+	}
+	_, e := r.Cookie(CSRFCookieName)
+	return e == nil
+}
+
+// hasCredentialHeader determines whether a request has an API key or a
+// session, which are sent in headers that pages of other origins can not set
+// (except for the origins allowed by 'CORSConfig'). Browsers may send 'Authorization' on
+// their own (such as for basic auth), so only bearer tokens count.
+func hasCredentialHeader(r *http.Request) bool {
+	return r.Header.Get(APIKeyHeader) != "" || bearerOfRequest(r) != ""
+}
+
+/*
+	<<< ACTIONS >>>
+*/
+
+func csrfGateway(mux *http.ServeMux, c *CSRF) error {
+
+	Handle(mux, "/api/csrf_token",
+		"GET", getCSRFToken(c))
+
+	return nil
+}
+
+type CSRFTokenReply struct {
+	Token string `json:"csrf_token"`
+}
+
+// getCSRFToken issues a token for the cookie of the request if it is valid, or
+// else for a new cookie.
+func getCSRFToken(c *CSRF) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if cookie, e := r.Cookie(CSRFCookieName); e == nil && c.Valid(cookie.Value) {
+			return sendJson(w, http.StatusOK, CSRFTokenReply{Token: cookie.Value})
+		}
+		token, e := c.Token()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				e.Error())
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     CSRFCookieName,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		return sendJson(w, http.StatusOK, CSRFTokenReply{Token: token})
+	}
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequireCSRF(t *testing.T) {
+	csrf, e := NewCSRF()
+	require.Nil(t, e)
+
+	mux := http.NewServeMux()
+	require.Nil(t, csrfGateway(mux, csrf))
+	Handle(mux, "/api/wallets/new", "POST", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, r.PostFormValue("label"))
+	})
+	handler := requireCSRF(csrf, mux)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/csrf_token", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var reply CSRFTokenReply
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, reply.Token, cookies[0].Value)
+	require.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	post := func(form url.Values, header ...string) int {
+		r := httptest.NewRequest("POST", "/api/wallets/new", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for i := 0; i+1 < len(header); i += 2 {
+			if header[i] == "Cookie" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: header[i+1]})
+				continue
+			}
+			r.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	form := url.Values{"label": {"a"}}
+	origin := "http://127.0.0.1:8080"
+
+	require.Equal(t, http.StatusOK, post(form), "requests not from browsers should not be checked")
+	require.Equal(t, http.StatusForbidden, post(form, "Origin", origin),
+		"requests from browsers should require a token")
+	require.Equal(t, http.StatusForbidden, post(form, "Origin", origin, CSRFHeader, reply.Token),
+		"tokens should match the cookie")
+	require.Equal(t, http.StatusOK, post(form, "Origin", origin, "Cookie", reply.Token, CSRFHeader, reply.Token))
+	require.Equal(t, http.StatusOK, post(url.Values{"label": {"a"}, CSRFFormField: {reply.Token}},
+		"Sec-Fetch-Site", "same-origin", "Cookie", reply.Token), "tokens should be read from forms")
+
+	other, e := NewCSRF()
+	require.Nil(t, e)
+	forged, e := other.Token()
+	require.Nil(t, e)
+	require.Equal(t, http.StatusForbidden, post(form, "Origin", origin, "Cookie", forged, CSRFHeader, forged),
+		"tokens with other secrets should be invalid")
+	require.Equal(t, http.StatusForbidden, post(form, "Cookie", reply.Token, CSRFHeader, forged),
+		"requests with the cookie should be checked")
+
+	require.Equal(t, http.StatusOK, post(form, "Origin", origin, APIKeyHeader, "kc_key"),
+		"requests with API keys should not be checked")
+	require.Equal(t, http.StatusForbidden, post(form, "Origin", origin, "Authorization", "Basic YTpi"),
+		"requests with basic auth should be checked")
+}
@@ -8,14 +8,24 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 )
 
 type Gateway struct {
-	IKO    *iko.BlockChain
-	Wallet *wallet.Manager
+	IKO      *iko.BlockChain
+	Wallet   *wallet.Manager
+	APIKeys  *APIKeyStore    // API keys are required for mutating routes, if not nil.
+	Sessions *SessionManager // Sessions are required for wallet routes, if not nil.
 }
 
-func (g *Gateway) host(mux *http.ServeMux) error {
+func (g *Gateway) host(root *http.ServeMux, c *ServerConfig) error {
+	var (
+		api = NewAPIMux(LatestAPIVersion, c.NegotiateAPIVersion)
+		mux = api.Version(APIVersion1)
+	)
+	if v := c.DefaultAPIVersion(); v < 1 || v > api.Latest() {
+		return fmt.Errorf("unsupported API version '%d', expected versions up to '%d'", v, api.Latest())
+	}
 
 	if g.IKO != nil {
 		if e := ikoGateway(mux, g.IKO); e != nil {
@@ -29,6 +39,84 @@ func (g *Gateway) host(mux *http.ServeMux) error {
 		}
 	}
 
+	if g.IKO != nil && g.Wallet != nil {
+		if e := walletChainGateway(mux, g.IKO, g.Wallet); e != nil {
+			return e
+		}
+	}
+
+	if g.IKO != nil {
+		if e := graphqlGateway(mux, g.IKO, g.Wallet); e != nil {
+			return e
+		}
+	}
+
+	if g.APIKeys != nil {
+		if e := apiKeyGateway(mux, g.APIKeys); e != nil {
+			return e
+		}
+	}
+
+	// Admin routes are not handled without API keys, as they would then be
+	// open to all clients.
+	if g.IKO != nil && g.APIKeys != nil {
+		if e := ikoAdminGateway(mux, g.IKO); e != nil {
+			return e
+		}
+	}
+
+	if g.Sessions != nil {
+		if e := sessionGateway(mux, g.Sessions); e != nil {
+			return e
+		}
+	}
+
+	var csrf *CSRF
+	if c.EnableCSRF {
+		var e error
+		if csrf, e = NewCSRF(); e != nil {
+			return e
+		}
+		if e := csrfGateway(mux, csrf); e != nil {
+			return e
+		}
+	}
+
+	for version := APIVersion1; version <= api.Latest(); version++ {
+		Handle(api.Version(version), "/api/spec.json",
+			"GET", getOpenAPI(api, version))
+	}
+
+	var handler http.Handler = api
+	if g.APIKeys != nil {
+		handler = requireAPIKeys(g.APIKeys, handler)
+	}
+	if c.RateLimits.Enabled() {
+		limits, e := NewRateLimits(c.RateLimits, g.APIKeys)
+		if e != nil {
+			return e
+		}
+		handler = requireRateLimits(limits, handler)
+	}
+	if g.Sessions != nil {
+		handler = requireSessions(g.Sessions, handler)
+	}
+	if csrf != nil {
+		handler = requireCSRF(csrf, handler)
+	}
+	if c.CORS.Enabled() {
+		policy, e := newCORS(c.CORS)
+		if e != nil {
+			return e
+		}
+		handler = requireCORS(policy, handler)
+	}
+	if c.LogRequests {
+		handler = logRequests(log, handler)
+	} else {
+		handler = logRequests(nil, handler)
+	}
+	root.Handle("/api/", handler)
 	return nil
 }
 
@@ -39,6 +127,7 @@ func (g *Gateway) host(mux *http.ServeMux) error {
 type HandlerFunc func(w http.ResponseWriter, r *http.Request, p *Path) error
 
 func Handle(mux *http.ServeMux, pattern, method string, handler HandlerFunc) {
+	recordRoute(mux, pattern, method)
 	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
 
 		if r.Method != method {
@@ -70,6 +159,37 @@ func SwitchExtension(w http.ResponseWriter, p *Path, jsonAction, encAction func(
 	}
 }
 
+// Route is a route handled by a mux.
+type Route struct {
+	Pattern string
+	Method  string
+}
+
+// routes are the routes handled by each mux, in the order they are handled,
+// from which the API spec is generated (see 'NewOpenAPI').
+var routes = struct {
+	sync.Mutex
+	of map[*http.ServeMux][]Route
+}{of: make(map[*http.ServeMux][]Route)}
+
+// recordRoute records a route of the mux, for routes that are not added with 'Handle'.
+func recordRoute(mux *http.ServeMux, pattern string, methods ...string) {
+	routes.Lock()
+	defer routes.Unlock()
+
+	for _, method := range methods {
+		routes.of[mux] = append(routes.of[mux], Route{Pattern: pattern, Method: method})
+	}
+}
+
+// RoutesOf obtains the routes handled by the mux.
+func RoutesOf(mux *http.ServeMux) []Route {
+	routes.Lock()
+	defer routes.Unlock()
+
+	return append([]Route(nil), routes.of[mux]...)
+}
+
 /*
 	<<< RETURN SPECIFICATIONS >>>
 */
@@ -0,0 +1,531 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/kittycash/wallet/src/wallet"
+	"github.com/skycoin/skycoin/src/cipher"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// GraphQLSchema is the schema of '/api/graphql', in the schema language.
+// Wallet fields are only served by gateways that host wallets.
+const GraphQLSchema = `type Query {
+	head: Transaction
+	tx(hash: String, seq: Int): Transaction
+	txs(startSeq: Int, pageSize: Int, desc: Boolean): [Transaction!]!
+	kitty(id: Int!): Kitty
+	address(address: String!): Address
+	wallets: [Wallet!]!
+	wallet(label: String!): Wallet
+}
+
+type Transaction {
+	hash: String!
+	seq: Int!
+	time: Int!
+	version: Int!
+	prevHash: String!
+	kitty: Kitty
+	kitties: [Kitty!]!
+	from: Address!
+	to: Address!
+	nonce: Int!
+	fee: Int!
+	memo: String!
+	expiry: Int!
+}
+
+type Kitty {
+	id: Int!
+	owner: Address!
+	burned: Boolean!
+	lastTx: Transaction!
+	transactions: [Transaction!]!
+	history: [KittyTransition!]!
+	parents: [Kitty!]!
+	children: [Kitty!]!
+	meta: KittyMeta
+}
+
+type KittyTransition {
+	from: Address
+	owner: Address!
+	seq: Int!
+	memo: String!
+	tx: Transaction!
+}
+
+type KittyMeta {
+	name: String!
+	breed: String!
+	attributes: [KittyAttribute!]!
+	imageHash: String!
+	mintBatch: Int!
+}
+
+type KittyAttribute {
+	name: String!
+	value: String!
+}
+
+type Address {
+	address: String!
+	kittyCount: Int!
+	kitties(page: Int, perPage: Int): [Kitty!]!
+	transactions: [Transaction!]!
+	nextNonce: Int!
+	feesPaid: Int!
+	feesReceived: Int!
+}
+
+type Wallet {
+	label: String!
+	name: String
+	encrypted: Boolean!
+	locked: Boolean!
+	watchOnly: Boolean!
+	hardware: Boolean!
+	addresses: [Address!]!
+}
+`
+
+// GraphQLMaxRequestSize is the maximum size of the body of 'POST' requests.
+const GraphQLMaxRequestSize = 1 << 20
+
+func graphqlGateway(mux *http.ServeMux, g *iko.BlockChain, m *wallet.Manager) error {
+	var (
+		schema = graphql(newChainSchema(g, m))
+		public = graphql(newChainSchema(g, nil)) // For requests that may not use wallets.
+	)
+	recordRoute(mux, "/api/graphql", "GET", "POST")
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		handler := schema
+		if !walletsAuthorized(r) {
+			handler = public
+		}
+		if e := handler(w, r, NewPath(r)); e != nil {
+			fmt.Println(e)
+		}
+	})
+	return nil
+}
+
+// graphql executes queries from either 'POST' (with a JSON 'GraphQLRequest') or
+// 'GET' (with the 'query', 'operationName' and 'variables' query parameters).
+func graphql(schema *gqlSchema) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req GraphQLRequest
+		switch r.Method {
+		case "GET":
+			q := r.URL.Query()
+			req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+			if s := q.Get("variables"); s != "" {
+				if e := decodeGqlVariables(s, &req.Variables); e != nil {
+					return sendGqlError(w, http.StatusBadRequest,
+						fmt.Sprintf("invalid variables: %v", e))
+				}
+			}
+		case "POST":
+			dec := json.NewDecoder(io.LimitReader(r.Body, GraphQLMaxRequestSize))
+			dec.UseNumber()
+			if e := dec.Decode(&req); e != nil {
+				return sendGqlError(w, http.StatusBadRequest,
+					fmt.Sprintf("invalid request: %v", e))
+			}
+		default:
+			return sendGqlError(w, http.StatusBadRequest,
+				fmt.Sprintf("invalid method type of '%s', expected '%s'",
+					r.Method, []string{"GET", "POST"}))
+		}
+		reply, e := schema.Execute(req)
+		if e != nil {
+			return sendGqlError(w, http.StatusBadRequest,
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+func decodeGqlVariables(s string, v *map[string]interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func sendGqlError(w http.ResponseWriter, status int, msg string) error {
+	return sendJson(w, status, GraphQLReply{
+		Errors: []GraphQLError{{Message: msg}},
+	})
+}
+
+// gqlTransition is a transition in the history of a kitty, with the owner
+// before it.
+type gqlTransition struct {
+	iko.KittyTransition
+	From *cipher.Address
+}
+
+// newChainSchema creates the schema of 'GraphQLSchema'. Transactions resolve
+// from 'iko.Transaction', kitties from 'iko.KittyID', addresses from
+// 'cipher.Address' and wallets from 'wallet.Stat'.
+func newChainSchema(g *iko.BlockChain, m *wallet.Manager) *gqlSchema {
+	var (
+		txOfHash = func(txHash iko.TxHash) (interface{}, error) {
+			return g.GetTxOfHash(txHash)
+		}
+		txsOfHashes = func(txHashes iko.TxHashes) (interface{}, error) {
+			out := make([]iko.Transaction, len(txHashes))
+			for i, txHash := range txHashes {
+				tx, e := g.GetTxOfHash(txHash)
+				if e != nil {
+					return nil, e
+				}
+				out[i] = tx
+			}
+			return out, nil
+		}
+		kittyState = func(src interface{}) (*iko.KittyState, error) {
+			kittyID := src.(iko.KittyID)
+			kState, ok := g.GetKittyState(kittyID)
+			if !ok {
+				return nil, fmt.Errorf("kitty of id '%d' not found", kittyID)
+			}
+			return kState, nil
+		}
+		kittyMeta = func(src interface{}) *iko.KittyMeta {
+			return src.(*iko.KittyMeta)
+		}
+		wallets = func() (*wallet.Manager, error) {
+			if m == nil {
+				return nil, errors.New("wallets are not hosted by this gateway")
+			}
+			return m, nil
+		}
+		txField = func(typ string, resolve func(tx iko.Transaction) (interface{}, error)) *gqlField {
+			return &gqlField{Type: typ, Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+				return resolve(src.(iko.Transaction))
+			}}
+		}
+		kittyField = func(typ string, resolve func(kState *iko.KittyState) (interface{}, error)) *gqlField {
+			return &gqlField{Type: typ, Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+				kState, e := kittyState(src)
+				if e != nil {
+					return nil, e
+				}
+				return resolve(kState)
+			}}
+		}
+		addressField = func(typ string, resolve func(aState *iko.AddressState) interface{}) *gqlField {
+			return &gqlField{Type: typ, Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+				return resolve(g.GetAddressState(src.(cipher.Address))), nil
+			}}
+		}
+		statField = func(typ string, resolve func(stat wallet.Stat) interface{}) *gqlField {
+			return &gqlField{Type: typ, Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+				return resolve(src.(wallet.Stat)), nil
+			}}
+		}
+	)
+
+	query := &gqlObject{Name: "Query", Fields: map[string]*gqlField{
+		"head": {Type: "Transaction", Resolve: func(_ interface{}, _ gqlArgs) (interface{}, error) {
+			return g.GetHeadTx()
+		}},
+		"tx": {Type: "Transaction", Args: map[string]string{"hash": "String", "seq": "Int"},
+			Resolve: func(_ interface{}, args gqlArgs) (interface{}, error) {
+				hash, hasHash := args.string("hash")
+				seq, hasSeq := args.int("seq")
+				switch {
+				case hasHash == hasSeq:
+					return nil, errors.New("either 'hash' or 'seq' is required")
+				case hasHash:
+					txHash, e := cipher.SHA256FromHex(hash)
+					if e != nil {
+						return nil, e
+					}
+					return txOfHash(iko.TxHash(txHash))
+				case seq < 0:
+					return nil, fmt.Errorf("invalid seq '%d'", seq)
+				default:
+					return g.GetTxOfSeq(uint64(seq))
+				}
+			}},
+		"txs": {Type: "[Transaction!]!", Args: map[string]string{"startSeq": "Int", "pageSize": "Int", "desc": "Boolean"},
+			Resolve: func(_ interface{}, args gqlArgs) (interface{}, error) {
+				var (
+					desc     = args.bool("desc")
+					startSeq = uint64(0)
+					pageSize = uint64(iko.DefaultTxRangeSize)
+				)
+				if desc {
+					startSeq = math.MaxUint64
+				}
+				if n, ok := args.int("startSeq"); ok {
+					if n < 0 {
+						return nil, fmt.Errorf("invalid startSeq '%d'", n)
+					}
+					startSeq = uint64(n)
+				}
+				if n, ok := args.int("pageSize"); ok {
+					if n < 0 {
+						return nil, fmt.Errorf("invalid pageSize '%d'", n)
+					}
+					pageSize = uint64(n)
+				}
+				txs, e := g.GetTxRange(startSeq, pageSize, desc)
+				return txs.Transactions, e
+			}},
+		"kitty": {Type: "Kitty", Args: map[string]string{"id": "Int!"},
+			Resolve: func(_ interface{}, args gqlArgs) (interface{}, error) {
+				id, _ := args.int("id")
+				if id < 0 {
+					return nil, fmt.Errorf("invalid kitty id '%d'", id)
+				}
+				if _, ok := g.GetKittyState(iko.KittyID(id)); !ok {
+					return nil, nil
+				}
+				return iko.KittyID(id), nil
+			}},
+		"address": {Type: "Address", Args: map[string]string{"address": "String!"},
+			Resolve: func(_ interface{}, args gqlArgs) (interface{}, error) {
+				s, _ := args.string("address")
+				return cipher.DecodeBase58Address(s)
+			}},
+		"wallets": {Type: "[Wallet!]!", Resolve: func(_ interface{}, _ gqlArgs) (interface{}, error) {
+			m, e := wallets()
+			if e != nil {
+				return nil, e
+			}
+			return m.ListWallets(), nil
+		}},
+		"wallet": {Type: "Wallet", Args: map[string]string{"label": "String!"},
+			Resolve: func(_ interface{}, args gqlArgs) (interface{}, error) {
+				m, e := wallets()
+				if e != nil {
+					return nil, e
+				}
+				label, _ := args.string("label")
+				for _, stat := range m.ListWallets() {
+					if stat.Label == label {
+						return stat, nil
+					}
+				}
+				return nil, nil
+			}},
+	}}
+
+	transaction := &gqlObject{Name: "Transaction", Fields: map[string]*gqlField{
+		"hash": txField("String!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Hash().Hex(), nil
+		}),
+		"seq": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Seq, nil
+		}),
+		"time": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.TS, nil
+		}),
+		"version": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Version, nil
+		}),
+		"prevHash": txField("String!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Prev.Hex(), nil
+		}),
+		"kitty": txField("Kitty", func(tx iko.Transaction) (interface{}, error) {
+			if _, ok := g.GetKittyState(tx.KittyID); !ok {
+				return nil, nil
+			}
+			return tx.KittyID, nil
+		}),
+		"kitties": txField("[Kitty!]!", func(tx iko.Transaction) (interface{}, error) {
+			return []iko.KittyID(tx.Kitties()), nil
+		}),
+		"from": txField("Address!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.From, nil
+		}),
+		"to": txField("Address!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.To, nil
+		}),
+		"nonce": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Nonce, nil
+		}),
+		"fee": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Fee, nil
+		}),
+		"memo": txField("String!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Memo, nil
+		}),
+		"expiry": txField("Int!", func(tx iko.Transaction) (interface{}, error) {
+			return tx.Expiry, nil
+		}),
+	}}
+
+	kitty := &gqlObject{Name: "Kitty", Fields: map[string]*gqlField{
+		"id": {Type: "Int!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src, nil
+		}},
+		"owner": kittyField("Address!", func(kState *iko.KittyState) (interface{}, error) {
+			return kState.Address, nil
+		}),
+		"burned": kittyField("Boolean!", func(kState *iko.KittyState) (interface{}, error) {
+			return kState.Address == iko.BurnAddress, nil
+		}),
+		"lastTx": kittyField("Transaction!", func(kState *iko.KittyState) (interface{}, error) {
+			return txOfHash(kState.LastTx.Hash)
+		}),
+		"transactions": kittyField("[Transaction!]!", func(kState *iko.KittyState) (interface{}, error) {
+			return txsOfHashes(kState.Transactions)
+		}),
+		"history": {Type: "[KittyTransition!]!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			history, e := g.GetKittyHistory(src.(iko.KittyID))
+			if e != nil {
+				return nil, e
+			}
+			out := make([]gqlTransition, len(history))
+			for i, t := range history {
+				out[i].KittyTransition = t
+				if i > 0 {
+					out[i].From = &history[i-1].Owner
+				}
+			}
+			return out, nil
+		}},
+		"parents": kittyField("[Kitty!]!", func(kState *iko.KittyState) (interface{}, error) {
+			return []iko.KittyID(kState.Parents), nil
+		}),
+		"children": kittyField("[Kitty!]!", func(kState *iko.KittyState) (interface{}, error) {
+			return []iko.KittyID(kState.Children), nil
+		}),
+		"meta": {Type: "KittyMeta", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			meta, _ := g.GetKittyMeta(src.(iko.KittyID))
+			return meta, nil
+		}},
+	}}
+
+	transition := &gqlObject{Name: "KittyTransition", Fields: map[string]*gqlField{
+		"from": {Type: "Address", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			if from := src.(gqlTransition).From; from != nil {
+				return *from, nil
+			}
+			return nil, nil
+		}},
+		"owner": {Type: "Address!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(gqlTransition).Owner, nil
+		}},
+		"seq": {Type: "Int!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(gqlTransition).Seq, nil
+		}},
+		"memo": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(gqlTransition).Memo, nil
+		}},
+		"tx": {Type: "Transaction!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return txOfHash(src.(gqlTransition).TxHash)
+		}},
+	}}
+
+	meta := &gqlObject{Name: "KittyMeta", Fields: map[string]*gqlField{
+		"name": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return kittyMeta(src).Name, nil
+		}},
+		"breed": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return kittyMeta(src).Breed, nil
+		}},
+		"attributes": {Type: "[KittyAttribute!]!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return kittyMeta(src).Attributes, nil
+		}},
+		"imageHash": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return kittyMeta(src).ImageHash.Hex(), nil
+		}},
+		"mintBatch": {Type: "Int!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return kittyMeta(src).MintBatch, nil
+		}},
+	}}
+
+	attribute := &gqlObject{Name: "KittyAttribute", Fields: map[string]*gqlField{
+		"name": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(iko.KittyAttribute).Name, nil
+		}},
+		"value": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(iko.KittyAttribute).Value, nil
+		}},
+	}}
+
+	address := &gqlObject{Name: "Address", Fields: map[string]*gqlField{
+		"address": {Type: "String!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return src.(cipher.Address).String(), nil
+		}},
+		"kittyCount": {Type: "Int!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return g.CountOfAddress(src.(cipher.Address)), nil
+		}},
+		"kitties": {Type: "[Kitty!]!", Args: map[string]string{"page": "Int", "perPage": "Int"},
+			Resolve: func(src interface{}, args gqlArgs) (interface{}, error) {
+				page, _ := args.int("page")
+				perPage, ok := args.int("perPage")
+				if !ok {
+					perPage = DefaultKittiesPageSize
+				}
+				if page < 0 || perPage < 0 {
+					return nil, fmt.Errorf("invalid page '%d' of '%d' per page", page, perPage)
+				}
+				paginated, e := g.GetKittiesOfAddress(src.(cipher.Address), uint64(page), uint64(perPage))
+				return []iko.KittyID(paginated.Kitties), e
+			}},
+		"transactions": {Type: "[Transaction!]!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			return txsOfHashes(g.GetAddressState(src.(cipher.Address)).Transactions)
+		}},
+		"nextNonce": addressField("Int!", func(aState *iko.AddressState) interface{} {
+			return aState.Nonce + 1
+		}),
+		"feesPaid": addressField("Int!", func(aState *iko.AddressState) interface{} {
+			return aState.FeesPaid
+		}),
+		"feesReceived": addressField("Int!", func(aState *iko.AddressState) interface{} {
+			return aState.FeesReceived
+		}),
+	}}
+
+	walletType := &gqlObject{Name: "Wallet", Fields: map[string]*gqlField{
+		"label": statField("String!", func(stat wallet.Stat) interface{} {
+			return stat.Label
+		}),
+		"name": statField("String", func(stat wallet.Stat) interface{} {
+			if stat.Name == "" {
+				return nil
+			}
+			return stat.Name
+		}),
+		"encrypted": statField("Boolean!", func(stat wallet.Stat) interface{} {
+			return stat.Encrypted
+		}),
+		"locked": statField("Boolean!", func(stat wallet.Stat) interface{} {
+			return stat.Locked != nil && *stat.Locked
+		}),
+		"watchOnly": statField("Boolean!", func(stat wallet.Stat) interface{} {
+			return stat.WatchOnly
+		}),
+		"hardware": statField("Boolean!", func(stat wallet.Stat) interface{} {
+			return stat.Hardware
+		}),
+		"addresses": {Type: "[Address!]!", Resolve: func(src interface{}, _ gqlArgs) (interface{}, error) {
+			fw, e := m.GetWallet(src.(wallet.Stat).Label)
+			if e != nil {
+				return nil, e
+			}
+			out := make([]cipher.Address, len(fw.Entries))
+			for i, entry := range fw.Entries {
+				if out[i], e = cipher.DecodeBase58Address(entry.Address); e != nil {
+					return nil, e
+				}
+			}
+			return out, nil
+		}},
+	}}
+
+	return newGqlSchema("Query",
+		query, transaction, kitty, transition, meta, attribute, address, walletType)
+}
@@ -1,15 +1,20 @@
 package http
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/kittycash/wallet/src/iko"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func ikoGateway(mux *http.ServeMux, g *iko.BlockChain) error {
@@ -20,27 +25,139 @@ func ikoGateway(mux *http.ServeMux, g *iko.BlockChain) error {
 	Handle(mux, "/api/iko/address/",
 		"GET", getAddress(g))
 
+	Handle(mux, "/api/iko/address_count/",
+		"GET", getAddressCount(g))
+
 	Handle(mux, "/api/iko/tx/",
 		"GET", getTx(g))
 
 	Handle(mux, "/api/iko/head_tx", "GET", getHeadTx(g))
 
+	Handle(mux, "/api/kitty/",
+		"GET", getKittyOwner(g))
+
+	Handle(mux, "/api/address/",
+		"GET", getAddressKitties(g))
+
+	Handle(mux, "/api/ws/txs",
+		"GET", streamTxs(g))
+
+	Handle(mux, "/api/sse/txs",
+		"GET", txEvents(g))
+
+	Handle(mux, "/api/txs",
+		"GET", getTxRange(g))
+
+	Handle(mux, "/api/tx/hash/",
+		"GET", lookupTxOfHash(g))
+
+	Handle(mux, "/api/tx/seq/",
+		"GET", lookupTxOfSeq(g))
+
+	Handle(mux, "/api/jsonrpc",
+		"POST", jsonrpc(g))
+
 	MultiHandle(mux, []string{
 		"/api/iko/txs",
 		"/api/iko/txs.json",
 		"/api/iko/txs.enc",
 	}, "GET", getPaginatedTxs(g))
 
+	MultiHandle(mux, []string{
+		"/api/iko/addresses",
+		"/api/iko/addresses.json",
+	}, "GET", getPaginatedAddresses(g))
+
+	Handle(mux, "/api/iko/kitties/",
+		"GET", getPaginatedKittiesOfStatus(g))
+
+	Handle(mux, "/api/iko/metrics",
+		"GET", getMetrics(g))
+
+	Handle(mux, "/api/iko/inject_kitty_meta",
+		"POST", injectKittyMeta(g))
+
+	Handle(mux, "/api/iko/unsigned_transfer",
+		"GET", getUnsignedTransfer(g))
+
+	Handle(mux, "/api/iko/simulate_tx",
+		"POST", simulateTx(g))
+
+	Handle(mux, "/api/iko/explain_tx",
+		"POST", explainTx(g))
+
 	Handle(mux, "/api/iko/inject_tx",
 		"POST", injectTx(g))
 
+	Handle(mux, "/api/iko/inject_tx_group",
+		"POST", injectTxGroup(g))
+
+	Handle(mux, "/api/iko/submit_tx",
+		"POST", submitTx(g))
+
+	Handle(mux, "/api/iko/pending_txs",
+		"GET", getPendingTxs(g))
+
+	return nil
+}
+
+// ikoAdminGateway handles the admin routes of the chain. These routes require
+// an admin API key, so they are only to be handled when API keys are enabled.
+func ikoAdminGateway(mux *http.ServeMux, g *iko.BlockChain) error {
+
+	Handle(mux, "/api/iko/admin/reserve",
+		"POST", reserveKitty(g))
+
+	Handle(mux, "/api/iko/admin/unreserve",
+		"POST", unreserveKitty(g))
+
+	Handle(mux, "/api/iko/admin/verify_state",
+		"GET", verifyState(g))
+
+	MultiHandle(mux, []string{
+		"/api/iko/admin/export.json",
+		"/api/iko/admin/export.csv",
+	}, "GET", exportState(g))
+
+	Handle(mux, "/api/iko/admin/rollback",
+		"POST", rollback(g))
+
 	return nil
 }
 
 type KittyReply struct {
-	KittyID      iko.KittyID `json:"kitty_id"`
-	Address      string      `json:"address"`
-	Transactions []string    `json:"transactions"`
+	KittyID      iko.KittyID     `json:"kitty_id"`
+	Address      string          `json:"address"`
+	Transactions []string        `json:"transactions"`
+	LastTxHash   string          `json:"last_tx_hash"`
+	LastTxSeq    uint64          `json:"last_tx_seq"`
+	LastTxTime   int64           `json:"last_tx_time"`
+	Burned       bool            `json:"burned,omitempty"`
+	Parents      iko.KittyIDs    `json:"parents,omitempty"`
+	Children     iko.KittyIDs    `json:"children,omitempty"`
+	Mint         *TxMint         `json:"mint,omitempty"`
+	Meta         *KittyMetaReply `json:"meta,omitempty"`
+}
+
+type KittyMetaReply struct {
+	Name       string               `json:"name"`
+	Breed      string               `json:"breed"`
+	Attributes []iko.KittyAttribute `json:"attributes"`
+	ImageHash  string               `json:"image_hash"`
+	MintBatch  uint64               `json:"mint_batch"`
+}
+
+func NewKittyMetaReply(meta *iko.KittyMeta) *KittyMetaReply {
+	if meta == nil {
+		return nil
+	}
+	return &KittyMetaReply{
+		Name:       meta.Name,
+		Breed:      meta.Breed,
+		Attributes: meta.Attributes,
+		ImageHash:  meta.ImageHash.Hex(),
+		MintBatch:  meta.MintBatch,
+	}
 }
 
 func getKitty(g *iko.BlockChain) HandlerFunc {
@@ -53,8 +170,14 @@ func getKitty(g *iko.BlockChain) HandlerFunc {
 		kState, ok := g.GetKittyState(kittyID)
 		if !ok {
 			return sendJson(w, http.StatusNotFound,
-				fmt.Sprintf("kitty of id '%s' not found", kittyID))
+				fmt.Sprintf("kitty of id '%d' not found", kittyID))
 		}
+		mint, e := g.GetMintMeta(kittyID)
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				e.Error())
+		}
+		meta, _ := g.GetKittyMeta(kittyID)
 		return SwitchExtension(w, p,
 			func() error {
 				return sendJson(w, http.StatusOK,
@@ -62,6 +185,14 @@ func getKitty(g *iko.BlockChain) HandlerFunc {
 						KittyID:      kittyID,
 						Address:      kState.Address.String(),
 						Transactions: kState.Transactions.ToStringArray(),
+						LastTxHash:   kState.LastTx.Hash.Hex(),
+						LastTxSeq:    kState.LastTx.Seq,
+						LastTxTime:   kState.LastTx.TS,
+						Burned:       kState.Address == iko.BurnAddress,
+						Parents:      kState.Parents,
+						Children:     kState.Children,
+						Mint:         NewTxMint(mint),
+						Meta:         NewKittyMetaReply(meta),
 					})
 			},
 			func() error {
@@ -72,10 +203,69 @@ func getKitty(g *iko.BlockChain) HandlerFunc {
 	}
 }
 
+// KittyOwnerReply is the ownership of a kitty, as a summary of 'KittyReply'.
+type KittyOwnerReply struct {
+	KittyID    iko.KittyID     `json:"kitty_id"`
+	Owner      string          `json:"owner"`
+	LastTxHash string          `json:"last_tx_hash"`
+	LastTxSeq  uint64          `json:"last_tx_seq"`
+	LastTxTime int64           `json:"last_tx_time"`
+	Burned     bool            `json:"burned,omitempty"`
+	Meta       *KittyMetaReply `json:"meta,omitempty"`
+}
+
+// getKittyOwner resolves the owner of a kitty from its last transaction.
+func getKittyOwner(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		kittyID, e := iko.KittyIDFromString(p.Base)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		reply, ok := newKittyOwnerReply(g, kittyID)
+		if !ok {
+			return sendJson(w, http.StatusNotFound,
+				fmt.Sprintf("kitty of id '%d' not found", kittyID))
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+func newKittyOwnerReply(g *iko.BlockChain, kittyID iko.KittyID) (KittyOwnerReply, bool) {
+	kState, ok := g.GetKittyState(kittyID)
+	if !ok {
+		return KittyOwnerReply{}, false
+	}
+	meta, _ := g.GetKittyMeta(kittyID)
+	return KittyOwnerReply{
+		KittyID:    kittyID,
+		Owner:      kState.Address.String(),
+		LastTxHash: kState.LastTx.Hash.Hex(),
+		LastTxSeq:  kState.LastTx.Seq,
+		LastTxTime: kState.LastTx.TS,
+		Burned:     kState.Address == iko.BurnAddress,
+		Meta:       NewKittyMetaReply(meta),
+	}, true
+}
+
 type AddressReply struct {
 	Address      string       `json:"address"`
 	Kitties      iko.KittyIDs `json:"kitties"`
 	Transactions []string     `json:"transactions"`
+	NextNonce    uint64       `json:"next_nonce"`
+	FeesPaid     uint64       `json:"fees_paid,omitempty"`
+	FeesReceived uint64       `json:"fees_received,omitempty"`
+}
+
+func NewAddressReply(address cipher.Address, aState *iko.AddressState) AddressReply {
+	return AddressReply{
+		Address:      address.String(),
+		Kitties:      aState.Kitties,
+		Transactions: aState.Transactions.ToStringArray(),
+		NextNonce:    aState.Nonce + 1,
+		FeesPaid:     aState.FeesPaid,
+		FeesReceived: aState.FeesReceived,
+	}
 }
 
 func getAddress(g *iko.BlockChain) HandlerFunc {
@@ -89,11 +279,7 @@ func getAddress(g *iko.BlockChain) HandlerFunc {
 		return SwitchExtension(w, p,
 			func() error {
 				return sendJson(w, http.StatusOK,
-					AddressReply{
-						Address:      address.String(),
-						Kitties:      aState.Kitties,
-						Transactions: aState.Transactions.ToStringArray(),
-					})
+					NewAddressReply(address, aState))
 			},
 			func() error {
 				return sendBin(w, http.StatusOK,
@@ -103,19 +289,139 @@ func getAddress(g *iko.BlockChain) HandlerFunc {
 	}
 }
 
+type AddressCountReply struct {
+	Address string `json:"address"`
+	Count   uint64 `json:"count"`
+}
+
+// DefaultKittiesPageSize is the page size of 'getAddressKitties' for clients
+// that do not specify one.
+const DefaultKittiesPageSize = 50
+
+// AddressKittiesReply is a page of the kitties owned by an address.
+type AddressKittiesReply struct {
+	Address        string       `json:"address"`
+	Count          uint64       `json:"count"`
+	Page           uint64       `json:"page"`
+	PerPage        uint64       `json:"per_page"`
+	TotalPageCount uint64       `json:"total_page_count"`
+	Kitties        iko.KittyIDs `json:"kitties"`
+}
+
+// getAddressKitties obtains a page of the kitties owned by an address, for
+// the path "/api/address/{address}/kitties".
+func getAddressKitties(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if len(p.SplitPath) != 5 || p.SplitPath[4] != "kitties" {
+			return sendJson(w, http.StatusNotFound,
+				fmt.Sprintf("invalid path '%s', expected '/api/address/{address}/kitties'", p.EscapedPath))
+		}
+		address, e := cipher.DecodeBase58Address(p.SplitPath[3])
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		reply := AddressKittiesReply{
+			Address: address.String(),
+			PerPage: DefaultKittiesPageSize,
+		}
+		q := r.URL.Query()
+		for key, v := range map[string]*uint64{"page": &reply.Page, "per_page": &reply.PerPage} {
+			if s := q.Get(key); s != "" {
+				n, e := strconv.ParseUint(s, 10, 64)
+				if e != nil {
+					return sendJson(w, http.StatusBadRequest,
+						fmt.Sprintf("invalid %s '%s'", key, s))
+				}
+				*v = n
+			}
+		}
+		paginated, e := g.GetKittiesOfAddress(address, reply.Page, reply.PerPage)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		reply.Count = g.CountOfAddress(address)
+		reply.TotalPageCount = paginated.TotalPageCount
+		reply.Kitties = paginated.Kitties
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+func getAddressCount(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		address, e := cipher.DecodeBase58Address(p.Base)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		count := g.CountOfAddress(address)
+		return SwitchExtension(w, p,
+			func() error {
+				return sendJson(w, http.StatusOK,
+					AddressCountReply{
+						Address: address.String(),
+						Count:   count,
+					})
+			},
+			func() error {
+				return sendBin(w, http.StatusOK,
+					encoder.Serialize(count))
+			},
+		)
+	}
+}
+
 type TxMeta struct {
 	Hash string `json:"hash"`
 	Raw  string `json:"raw"`
 }
 
 type Tx struct {
-	PrevHash string      `json:"prev_hash"`
-	Seq      uint64      `json:"seq"`
-	TS       int64       `json:"time"`
-	KittyID  iko.KittyID `json:"kitty_id"`
-	From     string      `json:"from"`
-	To       string      `json:"to"`
-	Sig      string      `json:"sig"`
+	Version  uint8        `json:"version"`
+	PrevHash string       `json:"prev_hash"`
+	Seq      uint64       `json:"seq"`
+	TS       int64        `json:"time"`
+	KittyID  iko.KittyID  `json:"kitty_id"`
+	KittyIDs iko.KittyIDs `json:"kitty_ids"`
+	Nonce    uint64       `json:"nonce"`
+	Fee      uint64       `json:"fee,omitempty"`
+	Group    string       `json:"group,omitempty"`
+	Delegate *TxDelegate  `json:"delegation,omitempty"`
+	Mint     *TxMint      `json:"mint,omitempty"`
+	Parents  iko.KittyIDs `json:"parents,omitempty"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Memo     string       `json:"memo,omitempty"`
+	Expiry   int64        `json:"expiry,omitempty"`
+	Sig      string       `json:"sig"`
+	Multisig *TxMultisig  `json:"multisig,omitempty"`
+	Cosig    string       `json:"cosig,omitempty"`
+	Scheme   *TxScheme    `json:"scheme,omitempty"`
+}
+
+type TxMultisig struct {
+	Threshold uint8    `json:"threshold"`
+	PubKeys   []string `json:"public_keys"`
+	Sigs      []string `json:"sigs"`
+}
+
+func NewTxMultisig(tx iko.Transaction) *TxMultisig {
+	if tx.Signers.IsZero() {
+		return nil
+	}
+	out := &TxMultisig{
+		Threshold: tx.Signers.Threshold,
+		PubKeys:   make([]string, len(tx.Signers.PubKeys)),
+		Sigs:      make([]string, len(tx.Sigs)),
+	}
+	for i, pk := range tx.Signers.PubKeys {
+		out.PubKeys[i] = pk.Hex()
+	}
+	for i, sig := range tx.Sigs {
+		out.Sigs[i] = sig.Hex()
+	}
+	return out
 }
 
 type TxReply struct {
@@ -130,17 +436,97 @@ func NewTxReplyOfTransaction(tx iko.Transaction) TxReply {
 			Raw:  hex.EncodeToString(tx.Serialize()),
 		},
 		Tx: Tx{
+			Version:  tx.Version,
 			PrevHash: tx.Prev.Hex(),
 			Seq:      tx.Seq,
 			TS:       tx.TS,
 			KittyID:  tx.KittyID,
+			KittyIDs: tx.Kitties(),
+			Nonce:    tx.Nonce,
+			Fee:      tx.Fee,
+			Group:    txGroup(tx),
+			Delegate: NewTxDelegate(tx),
+			Mint:     NewTxMint(tx.Mint),
+			Parents:  tx.Parents,
 			From:     tx.From.String(),
 			To:       tx.To.String(),
+			Memo:     tx.Memo,
+			Expiry:   tx.Expiry,
 			Sig:      tx.Sig.Hex(),
+			Multisig: NewTxMultisig(tx),
+			Cosig:    txCosig(tx),
+			Scheme:   NewTxScheme(tx),
 		},
 	}
 }
 
+type TxDelegate struct {
+	KittyID  iko.KittyID `json:"kitty_id"`
+	Nonce    uint64      `json:"nonce"`
+	Operator string      `json:"operator"`
+	Expiry   int64       `json:"expiry,omitempty"`
+	Sig      string      `json:"sig"`
+}
+
+func NewTxDelegate(tx iko.Transaction) *TxDelegate {
+	if !tx.IsDelegated() {
+		return nil
+	}
+	return &TxDelegate{
+		KittyID:  tx.Delegate.KittyID,
+		Nonce:    tx.Delegate.Nonce,
+		Operator: tx.Delegate.Operator.Hex(),
+		Expiry:   tx.Delegate.Expiry,
+		Sig:      tx.Delegate.Sig.Hex(),
+	}
+}
+
+type TxMint struct {
+	AttrHash string `json:"attr_hash"`
+	URI      string `json:"uri,omitempty"`
+}
+
+func NewTxMint(mint iko.MintMeta) *TxMint {
+	if mint.IsZero() {
+		return nil
+	}
+	return &TxMint{
+		AttrHash: mint.AttrHash.Hex(),
+		URI:      mint.URI,
+	}
+}
+
+type TxScheme struct {
+	Name   string `json:"name"`
+	PubKey string `json:"public_key"`
+	Sig    string `json:"sig"`
+}
+
+func NewTxScheme(tx iko.Transaction) *TxScheme {
+	if tx.SigScheme() == iko.Secp256k1Scheme {
+		return nil
+	}
+	return &TxScheme{
+		Name:   tx.SigScheme().Name(),
+		PubKey: hex.EncodeToString(tx.SchemeKey),
+		Sig:    hex.EncodeToString(tx.SchemeSig),
+	}
+}
+
+func txCosig(tx iko.Transaction) string {
+	if tx.Cosig == (cipher.Sig{}) {
+		return ""
+	}
+	return tx.Cosig.Hex()
+}
+
+func txGroup(tx iko.Transaction) string {
+	if !tx.IsGrouped() {
+		return ""
+	}
+	return tx.Group.Hex()
+}
+
 func getTx(g *iko.BlockChain) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
 		var tx iko.Transaction
@@ -170,16 +556,125 @@ func getTx(g *iko.BlockChain) HandlerFunc {
 				fmt.Sprintf("invalid request query value of '%s', expected '%s'",
 					reqVal, []string{"", "hash", "seq"}))
 		}
-		return SwitchExtension(w, p,
-			func() error {
-				return sendJson(w, http.StatusOK, NewTxReplyOfTransaction(tx))
-			},
-			func() error {
-				return sendBin(w, http.StatusOK,
-					tx.Serialize())
-			},
-		)
+		return sendTx(w, p, tx)
+	}
+}
+
+// sendTx replies with the transaction in the format of the URL extension.
+// Besides the formats of 'SwitchExtension', the '.pb' extension replies with
+// the protobuf encoding of the transaction (see 'src/iko/transaction.proto').
+func sendTx(w http.ResponseWriter, p *Path, tx iko.Transaction) error {
+	if p.Extension == ".pb" {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		_, e := w.Write(tx.MarshalProto())
+		return e
+	}
+	return SwitchExtension(w, p,
+		func() error {
+			return sendJson(w, http.StatusOK, NewTxReplyOfTransaction(tx))
+		},
+		func() error {
+			return sendBin(w, http.StatusOK,
+				tx.Serialize())
+		},
+	)
+}
+
+const (
+	TxConfirmed = "confirmed" // Of a transaction in the chain.
+	TxPending   = "pending"   // Of a transaction in the mempool.
+)
+
+// TxLookupReply is the transaction (in its canonical JSON encoding) found by a
+// lookup, with its status in the chain.
+type TxLookupReply struct {
+	Seq           uint64          `json:"seq"`
+	Hash          string          `json:"hash"`
+	Status        string          `json:"status"`
+	Confirmations uint64          `json:"confirmations"` // Counted to the head, 1 if it is the head.
+	Tx            iko.Transaction `json:"transaction"`
+}
+
+// lookupTxOfHash looks up a transaction in the chain, or in the mempool, by
+// its hash.
+func lookupTxOfHash(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		txHash, e := cipher.SHA256FromHex(p.Base)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		tx, e := g.GetTxOfHash(iko.TxHash(txHash))
+		if e == nil {
+			return sendTxLookup(w, g, tx)
+		}
+		if reply, ok := pendingTxLookup(g, iko.TxHash(txHash)); ok {
+			return sendJson(w, http.StatusOK, reply)
+		}
+		return sendJson(w, http.StatusNotFound,
+			e.Error())
+	}
+}
+
+// pendingTxLookup looks up a transaction in the mempool.
+func pendingTxLookup(g *iko.BlockChain, txHash iko.TxHash) (TxLookupReply, bool) {
+	for _, pTx := range g.PendingTxs() {
+		if pTx.Tx.Hash() == txHash {
+			return TxLookupReply{
+				Seq:    pTx.Tx.Seq,
+				Hash:   pTx.Tx.Hash().Hex(),
+				Status: TxPending,
+				Tx:     pTx.Tx,
+			}, true
+		}
+	}
+	return TxLookupReply{}, false
+}
+
+// lookupTxOfSeq looks up a transaction in the chain by its sequence.
+func lookupTxOfSeq(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		seq, e := strconv.ParseUint(p.Base, 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		tx, e := g.GetTxOfSeq(seq)
+		if e != nil {
+			return sendJson(w, http.StatusNotFound,
+				e.Error())
+		}
+		return sendTxLookup(w, g, tx)
+	}
+}
+
+// sendTxLookup replies with a transaction in the chain, with its confirmations
+// counted to the head.
+func sendTxLookup(w http.ResponseWriter, g *iko.BlockChain, tx iko.Transaction) error {
+	reply, e := confirmedTxLookup(g, tx)
+	if e != nil {
+		return sendJson(w, http.StatusInternalServerError,
+			e.Error())
 	}
+	return sendJson(w, http.StatusOK, reply)
+}
+
+func confirmedTxLookup(g *iko.BlockChain, tx iko.Transaction) (TxLookupReply, error) {
+	head, e := g.GetHeadTx()
+	if e != nil {
+		return TxLookupReply{}, e
+	}
+	reply := TxLookupReply{
+		Seq:    tx.Seq,
+		Hash:   tx.Hash().Hex(),
+		Status: TxConfirmed,
+		Tx:     tx,
+	}
+	if head.Seq >= tx.Seq {
+		reply.Confirmations = head.Seq - tx.Seq + 1
+	}
+	return reply, nil
 }
 
 type HeadHashReply struct {
@@ -194,57 +689,206 @@ func getHeadTx(g *iko.BlockChain) HandlerFunc {
 			return sendJson(w, http.StatusNotFound,
 				e.Error())
 		}
-		return SwitchExtension(w, p,
-			func() error {
-				return sendJson(w, http.StatusOK, NewTxReplyOfTransaction(tx))
-			},
-			func() error {
-				return sendBin(w, http.StatusOK,
-					tx.Serialize())
-			},
-		)
+		return sendTx(w, p, tx)
 	}
 }
 
+// InjectTxRequest holds either the serialized transaction in hex, or the
+// transaction in the canonical JSON encoding (see 'iko.Transaction.MarshalJSON').
 type InjectTxRequest struct {
-	Hex string `json:"hex"`
+	Hex string           `json:"hex,omitempty"`
+	Tx  *iko.Transaction `json:"transaction,omitempty"`
+}
+
+// readTx reads a transaction from the request body, as either JSON (with the
+// serialized transaction in hex) or binary content.
+func readTx(r *http.Request) (*iko.Transaction, error) {
+	txRaw, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		return nil, e
+	}
+	var raw []byte
+	switch contentType := r.Header.Get("Content-Type"); contentType {
+	case "application/json":
+		req := new(InjectTxRequest)
+		if e := json.Unmarshal(txRaw, req); e != nil {
+			return nil, e
+		}
+		if req.Tx != nil {
+			return req.Tx, nil
+		}
+		if raw, e = hex.DecodeString(req.Hex); e != nil {
+			return nil, e
+		}
+	case "application/octet-stream":
+		raw = txRaw
+	case "application/x-protobuf":
+		return iko.UnmarshalTxProto(txRaw)
+	default:
+		return nil, fmt.Errorf("content type '%s' is not supported, expecting '%s'",
+			contentType, []string{"application/json", "application/octet-stream", "application/x-protobuf"})
+	}
+	return iko.DecodeTx(raw)
+}
+
+// AcceptedTxReply is the reply of injecting a transaction that is already
+// accepted, so that clients that retry injections can treat it as success.
+type AcceptedTxReply struct {
+	TxHash    string `json:"tx_hash"`
+	Seq       uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+func NewAcceptedTxReply(e *iko.TxAcceptedError) AcceptedTxReply {
+	return AcceptedTxReply{
+		TxHash:    e.Hash.Hex(),
+		Seq:       e.Seq,
+		Duplicate: true,
+	}
+}
+
+// txErrorStatus obtains the status code for an error from injecting a
+// transaction.
+func txErrorStatus(e error) int {
+	switch {
+	case errors.Is(e, iko.ErrBadSignature):
+		return http.StatusUnauthorized
+	case errors.Is(e, iko.ErrNotOwner):
+		return http.StatusForbidden
+	case errors.Is(e, iko.ErrKittyUnknown):
+		return http.StatusNotFound
+	case errors.Is(e, iko.ErrDuplicateTx):
+		return http.StatusConflict
+	case errors.Is(e, iko.ErrExpired):
+		return http.StatusGone
+	case errors.Is(e, iko.ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 func injectTx(g *iko.BlockChain) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
-		txRaw, e := ioutil.ReadAll(r.Body)
+		tx, e := readTx(r)
 		if e != nil {
 			return sendJson(w, http.StatusBadRequest,
 				e.Error())
 		}
-		var tx = new(iko.Transaction)
-		switch contentType := r.Header.Get("Content-Type"); contentType {
-		case "application/json":
-			req := new(InjectTxRequest)
-			if e := json.Unmarshal(txRaw, req); e != nil {
-				return sendJson(w, http.StatusBadRequest,
-					e.Error())
+		if e := g.InjectTx(tx); e != nil {
+			if accepted, ok := e.(*iko.TxAcceptedError); ok {
+				return sendJson(w, http.StatusOK,
+					NewAcceptedTxReply(accepted))
 			}
-			hexRaw, e := hex.DecodeString(req.Hex)
+			return sendJson(w, txErrorStatus(e),
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK,
+			true)
+	}
+}
+
+type InjectTxGroupRequest struct {
+	Hexes []string `json:"txs"`
+}
+
+// injectTxGroup injects an atomic group of txs, of which either all or none
+// are accepted.
+func injectTxGroup(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		req := new(InjectTxGroupRequest)
+		if e := json.NewDecoder(r.Body).Decode(req); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		txs := make([]*iko.Transaction, len(req.Hexes))
+		for i, txHex := range req.Hexes {
+			raw, e := hex.DecodeString(txHex)
 			if e != nil {
 				return sendJson(w, http.StatusBadRequest,
-					e.Error())
+					fmt.Sprintf("tx %d: %v", i, e))
 			}
-			if e := encoder.DeserializeRaw(hexRaw, tx); e != nil {
+			if txs[i], e = iko.DecodeTx(raw); e != nil {
 				return sendJson(w, http.StatusBadRequest,
-					e.Error())
+					fmt.Sprintf("tx %d: %v", i, e))
 			}
-		case "application/octet-stream":
-			if e := encoder.DeserializeRaw(txRaw, tx); e != nil {
-				return sendJson(w, http.StatusBadRequest,
-					e.Error())
+		}
+		if e := g.InjectTxGroup(txs); e != nil {
+			return sendJson(w, txErrorStatus(e),
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK,
+			true)
+	}
+}
+
+type SubmitTxReply struct {
+	TxHash    string  `json:"tx_hash"`
+	Pending   bool    `json:"pending"`
+	Duplicate bool    `json:"duplicate,omitempty"`
+	Seq       *uint64 `json:"seq,omitempty"` // Seq of the accepted tx (duplicates only).
+}
+
+// submitTx injects a tx, or holds it in the mempool if its prerequisites
+// are not yet met.
+func submitTx(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		tx, e := readTx(r)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		pending, e := g.SubmitTx(tx)
+		if accepted, ok := e.(*iko.TxAcceptedError); ok {
+			return sendJson(w, http.StatusOK, SubmitTxReply{
+				TxHash:    tx.Hash().Hex(),
+				Duplicate: true,
+				Seq:       &accepted.Seq,
+			})
+		}
+		if e != nil {
+			return sendJson(w, txErrorStatus(e),
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK, SubmitTxReply{
+			TxHash:  tx.Hash().Hex(),
+			Pending: pending,
+		})
+	}
+}
+
+type PendingTxReply struct {
+	Received int64   `json:"received"`
+	Tx       TxReply `json:"tx"`
+}
+
+func getPendingTxs(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		txs := g.PendingTxs()
+		out := make([]PendingTxReply, len(txs))
+		for i, pTx := range txs {
+			out[i] = PendingTxReply{
+				Received: pTx.Received.UnixNano(),
+				Tx:       NewTxReplyOfTransaction(pTx.Tx),
 			}
-		default:
+		}
+		return sendJson(w, http.StatusOK, out)
+	}
+}
+
+func injectKittyMeta(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var fm iko.FloatingKittyMeta
+		if e := json.NewDecoder(r.Body).Decode(&fm); e != nil {
 			return sendJson(w, http.StatusBadRequest,
-				fmt.Sprintf("content type '%s' is not supported, expecting '%s'",
-					contentType, []string{"application/json", "application/octet-stream"}))
+				e.Error())
 		}
-		if e := g.InjectTx(tx); e != nil {
+		m, e := fm.ToSigned()
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		if e := g.SetKittyMeta(m); e != nil {
 			return sendJson(w, http.StatusBadRequest,
 				e.Error())
 		}
@@ -253,6 +897,126 @@ func injectTx(g *iko.BlockChain) HandlerFunc {
 	}
 }
 
+type SimulateTxReply struct {
+	TxHash  string             `json:"tx_hash"`
+	Changes []KittyChangeReply `json:"changes"`
+}
+
+type KittyChangeReply struct {
+	KittyID iko.KittyID `json:"kitty_id"`
+	From    string      `json:"from,omitempty"`
+	To      string      `json:"to"`
+}
+
+func simulateTx(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		tx, e := readTx(r)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		diffs, e := g.SimulateTx(tx)
+		if e != nil {
+			return sendJson(w, txErrorStatus(e),
+				e.Error())
+		}
+		reply := SimulateTxReply{
+			TxHash:  tx.Hash().Hex(),
+			Changes: make([]KittyChangeReply, len(diffs)),
+		}
+		for i, diff := range diffs {
+			reply.Changes[i] = KittyChangeReply{
+				KittyID: diff.KittyID,
+				To:      diff.To.String(),
+			}
+			if diff.From != (cipher.Address{}) {
+				reply.Changes[i].From = diff.From.String()
+			}
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+// explainTx replies with a breakdown of a transaction, for debugging. The
+// transaction does not need to be valid.
+func explainTx(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		tx, e := readTx(r)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK, g.ExplainTx(tx))
+	}
+}
+
+type UnsignedTransferReply struct {
+	Raw           string `json:"raw"`
+	SignatureHash string `json:"signature_hash"`
+}
+
+// getUnsignedTransfer builds an unsigned transfer on top of the head tx, so
+// that it can be signed offline and injected with 'inject_tx'.
+func getUnsignedTransfer(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var (
+			q       = r.URL.Query()
+			kitties iko.KittyIDs
+		)
+		for _, idStr := range strings.Split(q.Get("kitty_ids"), ",") {
+			kittyID, e := iko.KittyIDFromString(idStr)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+			kitties = append(kitties, kittyID)
+		}
+		from, e := cipher.DecodeBase58Address(q.Get("from"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		to, e := cipher.DecodeBase58Address(q.Get("to"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		head, e := g.GetHeadTx()
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		tx := iko.NewUnsignedTransfer(&head, kitties, from, to, g.NextNonce(from))
+		if fee := g.TransferFee(); fee != 0 {
+			tx.PayFee(fee)
+		}
+
+		// Delegated transfers are signed by the operator of the delegation.
+		if dHex := q.Get("delegation"); dHex != "" {
+			raw, e := hex.DecodeString(dHex)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+			d, e := iko.DecodeDelegation(raw)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+			tx.UseDelegation(d)
+		}
+
+		// Senders of other signature schemes sign with the scheme's version.
+		if name := q.Get("scheme"); name != "" {
+			scheme, e := iko.SigSchemeFromName(name)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+			if e := tx.UseScheme(scheme); e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+		}
+		return sendJson(w, http.StatusOK, UnsignedTransferReply{
+			Raw:           hex.EncodeToString(tx.Serialize()),
+			SignatureHash: tx.SignatureHash().Hex(),
+		})
+	}
+}
+
 type PaginatedTxsReply struct {
 	TotalPageCount uint64    `json:"total_page_count"`
 	TxReplies      []TxReply `json:"transactions"`
@@ -279,8 +1043,490 @@ func getPaginatedTxs(g *iko.BlockChain) HandlerFunc {
 		}
 		paginatedTxsReply := PaginatedTxsReply{
 			TotalPageCount: paginated.TotalPageCount,
-			TxReplies: txReplies,
+			TxReplies:      txReplies,
 		}
 		return sendJson(w, http.StatusOK, paginatedTxsReply)
 	}
 }
+
+// TxStreamBufferSize is the number of transactions that a stream of
+// transactions buffers, before it misses transactions.
+const TxStreamBufferSize = 64
+
+// TxStreamFilter is the subscription message of a stream of transactions.
+// Transactions match any of the addresses (as sender or recipient), or any
+// of the kitties; empty fields match every transaction.
+type TxStreamFilter struct {
+	Addresses []string     `json:"addresses"`
+	Kitties   iko.KittyIDs `json:"kitties"`
+}
+
+// txFilterOf obtains the filter of a subscription message.
+func txFilterOf(f TxStreamFilter) (iko.TxFilter, error) {
+	out := iko.TxFilter{Kitties: f.Kitties}
+	for _, s := range f.Addresses {
+		addr, e := cipher.DecodeBase58Address(s)
+		if e != nil {
+			return iko.TxFilter{}, fmt.Errorf("invalid address '%s': %v", s, e)
+		}
+		out.Addresses = append(out.Addresses, addr)
+	}
+	return out, nil
+}
+
+// TxStreamError is sent on the stream of transactions when a subscription
+// message is rejected. The stream continues with the previous filter.
+type TxStreamError struct {
+	Error string `json:"error"`
+}
+
+// streamTxs upgrades to a WebSocket, and streams every transaction that is
+// accepted into the chain (as a 'TxReply'), until the client disconnects.
+// Clients can send a 'TxStreamFilter' at any time to change what is streamed.
+func streamTxs(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		// Subscribed before the upgrade, so that no transaction is missed
+		// once the client is upgraded.
+		sub := g.Subscribe(TxStreamBufferSize)
+		defer sub.Close()
+		ws, e := upgradeWebSocket(w, r)
+		if e != nil {
+			return e
+		}
+		defer ws.Close(wsCloseNormal)
+
+		var (
+			filters = make(chan iko.TxFilter)
+			done    = make(chan error, 1)
+		)
+		go func() {
+			for {
+				op, data, e := ws.ReadMessage()
+				if e != nil {
+					done <- e
+					return
+				}
+				if op != wsOpText {
+					ws.Close(wsCloseUnsupported)
+					done <- errors.New("websocket subscription messages should be text")
+					return
+				}
+				var f TxStreamFilter
+				e = json.Unmarshal(data, &f)
+				var filter iko.TxFilter
+				if e == nil {
+					filter, e = txFilterOf(f)
+				}
+				if e != nil {
+					raw, _ := json.Marshal(TxStreamError{Error: e.Error()})
+					if e := ws.WriteMessage(wsOpText, raw); e != nil {
+						done <- e
+						return
+					}
+					continue
+				}
+				select {
+				case filters <- filter:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}()
+
+		var filter iko.TxFilter
+		for {
+			select {
+			case e := <-done:
+				if e == errWSClosed || e == io.EOF {
+					return nil
+				}
+				return e
+			case filter = <-filters:
+			case tx, ok := <-sub.C():
+				if !ok {
+					return nil
+				}
+				if !filter.Match(tx) {
+					continue
+				}
+				raw, e := json.Marshal(NewTxReplyOfTransaction(*tx))
+				if e != nil {
+					return e
+				}
+				if e := ws.WriteMessage(wsOpText, raw); e != nil {
+					return e
+				}
+			}
+		}
+	}
+}
+
+// SSERetry is the reconnection delay (in milliseconds) that clients of the
+// server-sent events are told.
+const SSERetry = 3000
+
+// txEvents streams every transaction that is accepted into the chain as
+// server-sent events, until the client disconnects. Each transaction is a
+// 'tx' event followed by a 'head' event, both with the seq of the transaction
+// as the event ID. Clients that reconnect with a 'Last-Event-ID' (or with the
+// 'last_event_id' query, as browsers can not set it for the first
+// connection) are replayed the transactions after it.
+func txEvents(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return sendJson(w, http.StatusInternalServerError,
+				"streaming is not supported")
+		}
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		var (
+			next   uint64
+			replay = lastID != ""
+		)
+		if replay {
+			seq, e := strconv.ParseUint(lastID, 10, 64)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("invalid last event id '%s'", lastID))
+			}
+			next = seq + 1
+		}
+		// Subscribed before the replay, so that no transaction is missed
+		// between the replay and the stream.
+		sub := g.Subscribe(TxStreamBufferSize)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if _, e := fmt.Fprintf(w, "retry: %d\n\n", SSERetry); e != nil {
+			return e
+		}
+		send := func(tx *iko.Transaction) error {
+			data, e := json.Marshal(NewTxReplyOfTransaction(*tx))
+			if e != nil {
+				return e
+			}
+			head, _ := json.Marshal(HeadHashReply{Seq: tx.Seq, Hash: tx.Hash().Hex()})
+			if _, e := fmt.Fprintf(w, "id: %d\nevent: tx\ndata: %s\n\nid: %d\nevent: head\ndata: %s\n\n",
+				tx.Seq, data, tx.Seq, head); e != nil {
+				return e
+			}
+			next = tx.Seq + 1
+			return nil
+		}
+		if replay {
+			for {
+				txs, e := g.GetTxRange(next, iko.MaxTxRangeSize, false)
+				if e != nil {
+					return e
+				}
+				for i := range txs.Transactions {
+					if e := send(&txs.Transactions[i]); e != nil {
+						return e
+					}
+				}
+				if !txs.HasMore {
+					break
+				}
+			}
+		} else if head, e := g.GetHeadTx(); e == nil {
+			// New clients are told of the head, and stream from it.
+			data, _ := json.Marshal(HeadHashReply{Seq: head.Seq, Hash: head.Hash().Hex()})
+			if _, e := fmt.Fprintf(w, "id: %d\nevent: head\ndata: %s\n\n", head.Seq, data); e != nil {
+				return e
+			}
+			next = head.Seq + 1
+		}
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+			case tx, ok := <-sub.C():
+				if !ok {
+					return nil
+				}
+				if tx.Seq < next {
+					// Already replayed.
+					continue
+				}
+				if e := send(tx); e != nil {
+					return e
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// TxRangeReply is a page of transactions with consecutive sequences. Explorers
+// request the next page from 'next_seq' (as the 'start_seq'), until 'has_more'
+// is false.
+type TxRangeReply struct {
+	StartSeq     uint64    `json:"start_seq"`
+	PageSize     uint64    `json:"page_size"`
+	Dir          string    `json:"dir"`
+	HeadSeq      uint64    `json:"head_seq"`
+	NextSeq      *uint64   `json:"next_seq"` // Null for the last page.
+	HasMore      bool      `json:"has_more"`
+	Transactions []TxReply `json:"transactions"`
+}
+
+func getTxRange(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		q := r.URL.Query()
+		reply := TxRangeReply{
+			PageSize: iko.DefaultTxRangeSize,
+			Dir:      q.Get("dir"),
+		}
+		switch reply.Dir {
+		case "":
+			reply.Dir = "asc"
+		case "asc", "desc":
+		default:
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("invalid dir '%s', expected '%s'",
+					reply.Dir, []string{"asc", "desc"}))
+		}
+		desc := reply.Dir == "desc"
+		if desc {
+			reply.StartSeq = math.MaxUint64
+		}
+		for key, v := range map[string]*uint64{"start_seq": &reply.StartSeq, "page_size": &reply.PageSize} {
+			if s := q.Get(key); s != "" {
+				n, e := strconv.ParseUint(s, 10, 64)
+				if e != nil {
+					return sendJson(w, http.StatusBadRequest,
+						fmt.Sprintf("invalid %s '%s'", key, s))
+				}
+				*v = n
+			}
+		}
+		if e := fillTxRange(g, &reply); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+// fillTxRange fills the reply with the range given by its start seq, page size and
+// dir.
+func fillTxRange(g *iko.BlockChain, reply *TxRangeReply) error {
+	desc := reply.Dir == "desc"
+	txs, e := g.GetTxRange(reply.StartSeq, reply.PageSize, desc)
+	if e != nil {
+		return e
+	}
+	if desc && reply.StartSeq > txs.HeadSeq {
+		reply.StartSeq = txs.HeadSeq
+	}
+	reply.HeadSeq, reply.HasMore = txs.HeadSeq, txs.HasMore
+	if txs.HasMore {
+		reply.NextSeq = &txs.NextSeq
+	}
+	reply.Transactions = make([]TxReply, len(txs.Transactions))
+	for i, tx := range txs.Transactions {
+		reply.Transactions[i] = NewTxReplyOfTransaction(tx)
+	}
+	return nil
+}
+
+type PaginatedAddressesReply struct {
+	TotalPageCount uint64   `json:"total_page_count"`
+	Addresses      []string `json:"addresses"`
+}
+
+func getPaginatedAddresses(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		perPage, e := strconv.ParseUint(r.URL.Query().Get("per_page"), 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		currentPage, e := strconv.ParseUint(
+			r.URL.Query().Get("current_page"), 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		paginated, e := g.GetAddresses(currentPage, perPage)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		addresses := make([]string, len(paginated.Addresses))
+		for i, address := range paginated.Addresses {
+			addresses[i] = address.String()
+		}
+		return sendJson(w, http.StatusOK, PaginatedAddressesReply{
+			TotalPageCount: paginated.TotalPageCount,
+			Addresses:      addresses,
+		})
+	}
+}
+
+type PaginatedKittiesReply struct {
+	Status         iko.KittyStatus `json:"status"`
+	Count          uint64          `json:"count"`
+	TotalPageCount uint64          `json:"total_page_count"`
+	Kitties        iko.KittyIDs    `json:"kitties"`
+}
+
+func getPaginatedKittiesOfStatus(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		status, e := iko.KittyStatusFromString(p.Base)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		perPage, e := strconv.ParseUint(r.URL.Query().Get("per_page"), 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		currentPage, e := strconv.ParseUint(
+			r.URL.Query().Get("current_page"), 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		paginated, e := g.GetKittiesOfStatus(status, currentPage, perPage)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		return sendJson(w, http.StatusOK, PaginatedKittiesReply{
+			Status:         status,
+			Count:          g.CountOfStatus(status),
+			TotalPageCount: paginated.TotalPageCount,
+			Kitties:        paginated.Kitties,
+		})
+	}
+}
+
+func reserveKitty(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		kittyID, e := iko.KittyIDFromString(r.URL.Query().Get("kitty_id"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		if e := g.ReserveKitty(kittyID); e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+func unreserveKitty(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		kittyID, e := iko.KittyIDFromString(r.URL.Query().Get("kitty_id"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		if e := g.UnreserveKitty(kittyID); e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+// getMetrics writes the metrics of the IKO in the prometheus text format.
+func getMetrics(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		topN := 10
+		if v := r.URL.Query().Get("top"); v != "" {
+			n, e := strconv.Atoi(v)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest, e.Error())
+			}
+			topN = n
+		}
+		stats := g.Stats(topN, iko.MintRateWindow)
+
+		buf := new(bytes.Buffer)
+		fmt.Fprintln(buf, "# HELP iko_kitties_total Number of minted kitties.")
+		fmt.Fprintln(buf, "# TYPE iko_kitties_total gauge")
+		fmt.Fprintf(buf, "iko_kitties_total %d\n", stats.TotalKitties)
+		fmt.Fprintln(buf, "# HELP iko_owners_total Number of addresses owning at least one kitty.")
+		fmt.Fprintln(buf, "# TYPE iko_owners_total gauge")
+		fmt.Fprintf(buf, "iko_owners_total %d\n", stats.OwnerCount)
+		fmt.Fprintln(buf, "# HELP iko_recent_mints Number of kitties minted in the last 24 hours.")
+		fmt.Fprintln(buf, "# TYPE iko_recent_mints gauge")
+		fmt.Fprintf(buf, "iko_recent_mints %d\n", stats.RecentMints)
+		fmt.Fprintln(buf, "# HELP iko_mint_rate Kitties minted per hour over the last 24 hours.")
+		fmt.Fprintln(buf, "# TYPE iko_mint_rate gauge")
+		fmt.Fprintf(buf, "iko_mint_rate %g\n", stats.MintRate)
+		fmt.Fprintln(buf, "# HELP iko_holder_kitties Number of kitties owned by the largest holders.")
+		fmt.Fprintln(buf, "# TYPE iko_holder_kitties gauge")
+		for i, holder := range stats.TopHolders {
+			fmt.Fprintf(buf, "iko_holder_kitties{rank=\"%d\",address=\"%s\"} %d\n",
+				i+1, holder.Address.String(), holder.Count)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		_, e := w.Write(buf.Bytes())
+		return e
+	}
+}
+
+func exportState(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		switch p.Extension {
+		case ".json":
+			w.Header().Set("Content-Type", "application/json")
+			return g.Export(w, iko.ExportJSON)
+		case ".csv":
+			w.Header().Set("Content-Type", "text/csv")
+			return g.Export(w, iko.ExportCSV)
+		default:
+			return sendJson(w, http.StatusMethodNotAllowed,
+				fmt.Sprintf("invalid URL extension '%s'", p.Extension))
+		}
+	}
+}
+
+func rollback(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		seq, e := strconv.ParseUint(r.URL.Query().Get("seq"), 10, 64)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		if e := g.Rollback(seq); e != nil {
+			return sendJson(w, http.StatusBadRequest, e.Error())
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+type StateMismatchReply struct {
+	KittyID         iko.KittyID `json:"kitty_id"`
+	ExpectedAddress string      `json:"expected_address,omitempty"`
+	ActualAddress   string      `json:"actual_address,omitempty"`
+}
+
+type VerifyStateReply struct {
+	OK         bool                 `json:"ok"`
+	Mismatches []StateMismatchReply `json:"mismatches"`
+}
+
+func verifyState(g *iko.BlockChain) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		mismatches, e := g.VerifyState()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError, e.Error())
+		}
+		reply := VerifyStateReply{
+			OK:         len(mismatches) == 0,
+			Mismatches: make([]StateMismatchReply, len(mismatches)),
+		}
+		for i, m := range mismatches {
+			reply.Mismatches[i].KittyID = m.KittyID
+			if m.Expected != nil {
+				reply.Mismatches[i].ExpectedAddress = m.Expected.Address.String()
+			}
+			if m.Actual != nil {
+				reply.Mismatches[i].ActualAddress = m.Actual.Address.String()
+			}
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
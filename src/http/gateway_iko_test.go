@@ -0,0 +1,407 @@
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSecKey is the key of the creator of the blockchains in the tests.
+var testSecKey = cipher.SecKey([32]byte{
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+})
+
+// newTestBlockChain creates a blockchain in memory, whose creator is
+// 'testSecKey'.
+func newTestBlockChain(t *testing.T, config iko.BlockChainConfig) *iko.BlockChain {
+	config.CreatorPK = cipher.PubKeyFromSecKey(testSecKey)
+	bc, e := iko.NewBlockChain(&config, iko.NewMemoryChain(10), iko.NewMemoryState())
+	require.Nil(t, e, "failed to create blockchain")
+	return bc
+}
+
+// wsTestClient is a minimal WebSocket client for the tests.
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWSTest(t *testing.T, srv *httptest.Server, path string) *wsTestClient {
+	conn, e := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.Nil(t, e, "failed to dial server")
+	_, e = io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+		"Host: test\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	require.Nil(t, e, "failed to send upgrade")
+	br := bufio.NewReader(conn)
+	res, e := http.ReadResponse(br, nil)
+	require.Nil(t, e, "failed to read upgrade")
+	require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"),
+		"accept key should follow RFC 6455")
+	return &wsTestClient{conn: conn, br: br}
+}
+
+func (c *wsTestClient) write(t *testing.T, v interface{}) {
+	payload, e := json.Marshal(v)
+	require.Nil(t, e)
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | wsOpText, 0x80 | 126, 0, 0}
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, e = c.conn.Write(frame)
+	require.Nil(t, e, "failed to write message")
+}
+
+func (c *wsTestClient) read(t *testing.T, v interface{}) {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var head [2]byte
+	_, e := io.ReadFull(c.br, head[:])
+	require.Nil(t, e, "failed to read message")
+	require.Equal(t, byte(0x80|wsOpText), head[0], "messages should be text")
+	n := uint64(head[1])
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	_, e = io.ReadFull(c.br, payload)
+	require.Nil(t, e, "failed to read message")
+	require.Nil(t, json.Unmarshal(payload, v), "messages should be JSON")
+}
+
+func TestIkoGateway_StreamTxs(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	mux := http.NewServeMux()
+	require.Nil(t, ikoGateway(mux, bc))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	all := dialWSTest(t, srv, "/api/ws/txs")
+	defer all.conn.Close()
+	filtered := dialWSTest(t, srv, "/api/ws/txs")
+	defer filtered.conn.Close()
+
+	filtered.write(t, TxStreamFilter{Addresses: []string{"invalid"}})
+	var reject TxStreamError
+	filtered.read(t, &reject)
+	require.Contains(t, reject.Error, "invalid address", "invalid filters should be rejected")
+	filtered.write(t, TxStreamFilter{Kitties: iko.KittyIDs{2}})
+	// The filter is applied to the stream asynchronously.
+	time.Sleep(50 * time.Millisecond)
+
+	tx1 := iko.NewGenTx(nil, 1, sk)
+	require.Nil(t, bc.InjectTx(tx1), "failed to inject gen tx")
+	tx2 := iko.NewGenTx(tx1, 2, sk)
+	require.Nil(t, bc.InjectTx(tx2), "failed to inject gen tx")
+
+	for _, tx := range []*iko.Transaction{tx1, tx2} {
+		var reply TxReply
+		all.read(t, &reply)
+		require.Equal(t, tx.Hash().Hex(), reply.Meta.Hash, "every transaction should be streamed")
+	}
+	var reply TxReply
+	filtered.read(t, &reply)
+	require.Equal(t, tx2.Hash().Hex(), reply.Meta.Hash, "only transactions matching the filter should be streamed")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/ws/txs", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code, "requests that are not upgrades should be rejected")
+}
+
+func TestIkoGateway_TxEvents(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	var prev *iko.Transaction
+	for i := 1; i <= 3; i++ {
+		prev = iko.NewGenTx(prev, iko.KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(prev), "failed to inject gen tx")
+	}
+
+	mux := http.NewServeMux()
+	require.Nil(t, ikoGateway(mux, bc))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// readEvent reads the next event, as its id and name.
+	readEvent := func(br *bufio.Reader) (id, event string) {
+		for {
+			line, e := br.ReadString('\n')
+			require.Nil(t, e, "failed to read event")
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				if event != "" {
+					return id, event
+				}
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			}
+		}
+	}
+
+	req, e := http.NewRequest("GET", srv.URL+"/api/sse/txs", nil)
+	require.Nil(t, e)
+	req.Header.Set("Last-Event-ID", "0")
+	res, e := http.DefaultClient.Do(req)
+	require.Nil(t, e, "failed to connect")
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	br := bufio.NewReader(res.Body)
+	for _, seq := range []string{"1", "2"} {
+		id, event := readEvent(br)
+		require.Equal(t, []string{seq, "tx"}, []string{id, event}, "transactions after the last event should be replayed")
+		id, event = readEvent(br)
+		require.Equal(t, []string{seq, "head"}, []string{id, event}, "transactions should be followed by the head")
+	}
+
+	res2, e := http.Get(srv.URL + "/api/sse/txs")
+	require.Nil(t, e, "failed to connect")
+	defer res2.Body.Close()
+	br2 := bufio.NewReader(res2.Body)
+	id, event := readEvent(br2)
+	require.Equal(t, []string{"2", "head"}, []string{id, event}, "new clients should be told of the head")
+
+	tx := iko.NewGenTx(prev, 4, sk)
+	require.Nil(t, bc.InjectTx(tx), "failed to inject gen tx")
+	for _, br := range []*bufio.Reader{br, br2} {
+		id, event := readEvent(br)
+		require.Equal(t, []string{"3", "tx"}, []string{id, event}, "new transactions should be streamed")
+	}
+
+	res3, e := http.Get(srv.URL + "/api/sse/txs?last_event_id=x")
+	require.Nil(t, e)
+	res3.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res3.StatusCode, "invalid event ids should be rejected")
+}
+
+func TestIkoGateway_JSONRPC(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	mux := http.NewServeMux()
+	require.Nil(t, ikoGateway(mux, bc))
+	call := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/jsonrpc", strings.NewReader(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	gen := iko.NewGenTx(nil, 1, sk)
+	code, body := call(`{"jsonrpc": "2.0", "id": 1, "method": "injectTx", "params": {"hex": "` +
+		hex.EncodeToString(gen.Serialize()) + `"}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "id": 1, "result": {"tx_hash": "`+gen.Hash().Hex()+`", "seq": 0, "duplicate": false}}`, body)
+
+	code, body = call(`[
+		{"jsonrpc": "2.0", "id": "a", "method": "getTxOfSeq", "params": [0]},
+		{"jsonrpc": "2.0", "id": "b", "method": "getKitty", "params": {"kitty_id": 9}},
+		{"jsonrpc": "2.0", "method": "getHeadTx"},
+		{"jsonrpc": "2.0", "id": "c", "method": "unknown"},
+		{"jsonrpc": "2.0", "id": "d", "method": "injectTx", "params": {"hex": "` + hex.EncodeToString(gen.Serialize()) + `"}},
+		{"id": "e", "method": "getHeadTx"}
+	]`)
+	require.Equal(t, http.StatusOK, code)
+	var replies []JSONRPCResponse
+	require.Nil(t, json.Unmarshal([]byte(body), &replies))
+	require.Len(t, replies, 5, "notifications should not be replied")
+
+	var lookup TxLookupReply
+	require.Equal(t, `"a"`, string(replies[0].ID))
+	require.Nil(t, replies[0].Error)
+	require.Nil(t, json.Unmarshal(replies[0].Result, &lookup))
+	require.Equal(t, gen.Hash().Hex(), lookup.Hash, "positional params should be in their order")
+	require.Equal(t, uint64(1), lookup.Confirmations)
+
+	for i, code := range []int{JSONRPCNotFound, JSONRPCMethodNotFound} {
+		require.NotNil(t, replies[i+1].Error)
+		require.Equal(t, code, replies[i+1].Error.Code)
+		require.Nil(t, replies[i+1].Result, "errors should not have results")
+	}
+	require.Nil(t, replies[3].Error, "retried injections should succeed")
+	require.Contains(t, string(replies[3].Result), `"duplicate":true`)
+	require.Equal(t, JSONRPCInvalidRequest, replies[4].Error.Code, "requests should have version '2.0'")
+
+	code, body = call(`{"jsonrpc": "2.0", "id": 2, "method": "getAddress", "params": {"address": "invalid"}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, `"code":-32602`, "invalid params should be rejected")
+
+	_, body = call(`{"jsonrpc": "2.0", "id": 3, "method": "getTxRange", "params": {"bogus": 1}}`)
+	require.Contains(t, body, `"code":-32602`, "unknown params should be rejected")
+
+	code, body = call(`{"jsonrpc": "2.0", "id": 4`)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "id": null, "error": {"code": -32700, "message": "invalid request: unexpected end of JSON input"}}`, body)
+
+	code, _ = call(`{"jsonrpc": "2.0", "method": "getHeadTx"}`)
+	require.Equal(t, http.StatusNoContent, code, "notifications should not be replied")
+
+	_, body = call(`[]`)
+	require.Contains(t, body, `"code":-32600`, "empty batches should be rejected")
+}
+
+func TestIkoGateway_Lookups(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{MempoolSize: 10})
+	defer bc.Close()
+
+	var (
+		creator  = cipher.AddressFromSecKey(sk)
+		_, other = cipher.GenerateKeyPair()
+		to       = cipher.AddressFromSecKey(other)
+		txs      []*iko.Transaction
+		prev     *iko.Transaction
+	)
+	for i := 1; i <= 4; i++ {
+		prev = iko.NewGenTx(prev, iko.KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(prev), "failed to inject gen tx")
+		txs = append(txs, prev)
+	}
+	transfer := iko.NewTransferTx(prev, 2, to, bc.NextNonce(creator), sk)
+	require.Nil(t, bc.InjectTx(transfer), "failed to inject transfer tx")
+	txs = append(txs, transfer)
+	orphan := iko.NewGenTx(iko.NewGenTx(transfer, 10, sk), 11, sk)
+	pending, e := bc.SubmitTx(orphan)
+	require.Nil(t, e, "failed to submit tx")
+	require.True(t, pending, "tx should be pending")
+
+	mux := http.NewServeMux()
+	require.Nil(t, ikoGateway(mux, bc))
+	get := func(target string, v interface{}) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if v != nil && rec.Code == http.StatusOK {
+			require.Nil(t, json.Unmarshal(rec.Body.Bytes(), v), "replies should be JSON")
+		}
+		return rec.Code
+	}
+
+	t.Run("TxOfHash", func(t *testing.T) {
+		var reply TxLookupReply
+		require.Equal(t, http.StatusOK, get("/api/tx/hash/"+txs[1].Hash().Hex(), &reply))
+		require.Equal(t, TxConfirmed, reply.Status)
+		require.Equal(t, txs[1].Seq, reply.Seq)
+		require.Equal(t, txs[1].Hash().Hex(), reply.Hash)
+		require.Equal(t, uint64(4), reply.Confirmations, "confirmations should count up to the head")
+		require.Equal(t, txs[1].Hash(), reply.Tx.Hash())
+
+		reply = TxLookupReply{}
+		require.Equal(t, http.StatusOK, get("/api/tx/hash/"+transfer.Hash().Hex(), &reply))
+		require.Equal(t, uint64(1), reply.Confirmations, "the head should have one confirmation")
+
+		reply = TxLookupReply{}
+		require.Equal(t, http.StatusOK, get("/api/tx/hash/"+orphan.Hash().Hex(), &reply))
+		require.Equal(t, TxPending, reply.Status, "txs in the mempool should be pending")
+		require.Equal(t, orphan.Seq, reply.Seq)
+		require.Equal(t, uint64(0), reply.Confirmations)
+		require.Equal(t, orphan.Hash(), reply.Tx.Hash())
+
+		require.Equal(t, http.StatusNotFound, get("/api/tx/hash/"+cipher.SumSHA256([]byte("none")).Hex(), nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/tx/hash/invalid", nil))
+	})
+
+	t.Run("TxOfSeq", func(t *testing.T) {
+		var reply TxLookupReply
+		require.Equal(t, http.StatusOK, get("/api/tx/seq/0", &reply))
+		require.Equal(t, TxConfirmed, reply.Status)
+		require.Equal(t, txs[0].Hash().Hex(), reply.Hash)
+		require.Equal(t, uint64(5), reply.Confirmations)
+
+		require.Equal(t, http.StatusNotFound, get("/api/tx/seq/99", nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/tx/seq/x", nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/tx/seq/-1", nil))
+	})
+
+	t.Run("KittyOwner", func(t *testing.T) {
+		var reply KittyOwnerReply
+		require.Equal(t, http.StatusOK, get("/api/kitty/2", &reply))
+		require.Equal(t, iko.KittyID(2), reply.KittyID)
+		require.Equal(t, to.String(), reply.Owner, "the owner should be the receiver of the last tx")
+		require.Equal(t, transfer.Hash().Hex(), reply.LastTxHash)
+		require.Equal(t, transfer.Seq, reply.LastTxSeq)
+		require.Equal(t, transfer.TS, reply.LastTxTime)
+		require.False(t, reply.Burned)
+
+		reply = KittyOwnerReply{}
+		require.Equal(t, http.StatusOK, get("/api/kitty/3", &reply))
+		require.Equal(t, creator.String(), reply.Owner)
+		require.Equal(t, txs[2].Seq, reply.LastTxSeq)
+
+		require.Equal(t, http.StatusNotFound, get("/api/kitty/99", nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/kitty/x", nil))
+	})
+
+	t.Run("AddressKitties", func(t *testing.T) {
+		var reply AddressKittiesReply
+		require.Equal(t, http.StatusOK, get("/api/address/"+creator.String()+"/kitties", &reply))
+		require.Equal(t, creator.String(), reply.Address)
+		require.Equal(t, uint64(3), reply.Count)
+		require.Equal(t, uint64(0), reply.Page)
+		require.Equal(t, uint64(DefaultKittiesPageSize), reply.PerPage, "pages should have the default size")
+		require.Equal(t, uint64(1), reply.TotalPageCount)
+		require.Equal(t, iko.KittyIDs{1, 3, 4}, reply.Kitties)
+
+		reply = AddressKittiesReply{}
+		require.Equal(t, http.StatusOK, get("/api/address/"+creator.String()+"/kitties?page=1&per_page=2", &reply))
+		require.Equal(t, uint64(3), reply.Count, "the count should cover all pages")
+		require.Equal(t, uint64(1), reply.Page)
+		require.Equal(t, uint64(2), reply.PerPage)
+		require.Equal(t, uint64(2), reply.TotalPageCount)
+		require.Equal(t, iko.KittyIDs{4}, reply.Kitties)
+
+		reply = AddressKittiesReply{}
+		require.Equal(t, http.StatusOK, get("/api/address/"+to.String()+"/kitties", &reply))
+		require.Equal(t, uint64(1), reply.Count)
+		require.Equal(t, iko.KittyIDs{2}, reply.Kitties)
+
+		reply = AddressKittiesReply{}
+		_, unused := cipher.GenerateKeyPair()
+		require.Equal(t, http.StatusOK, get("/api/address/"+cipher.AddressFromSecKey(unused).String()+"/kitties", &reply))
+		require.Equal(t, uint64(0), reply.Count)
+		require.Equal(t, uint64(0), reply.TotalPageCount)
+		require.Empty(t, reply.Kitties, "unused addresses should have no kitties")
+
+		require.Equal(t, http.StatusBadRequest, get("/api/address/invalid/kitties", nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/address/"+creator.String()+"/kitties?page=x", nil))
+		require.Equal(t, http.StatusBadRequest, get("/api/address/"+creator.String()+"/kitties?per_page=0", nil))
+		require.Equal(t, http.StatusNotFound, get("/api/address/"+creator.String()+"/other", nil))
+	})
+}
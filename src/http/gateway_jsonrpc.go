@@ -0,0 +1,427 @@
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"io"
+	"math"
+	"net/http"
+)
+
+// The JSON-RPC 2.0 interface of the chain is served at '/api/jsonrpc', with the
+// same operations (and replies) as the gateway. Requests are either single,
+// or batches (arrays) of requests. Requests without an id are notifications,
+// which are executed without a reply.
+
+const (
+	JSONRPCVersion        = "2.0"
+	JSONRPCMaxRequestSize = 1 << 20
+	JSONRPCMaxBatchSize   = 100
+)
+
+// Error codes of JSON-RPC 2.0, and of the server (in the range reserved to
+// implementations).
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+	JSONRPCTxRejected     = -32000 // With the kind of the rejection as data (see 'iko.ErrNotOwner').
+	JSONRPCNotFound       = -32001
+	JSONRPCUnauthorized   = -32002 // For mutating methods, without an API key.
+)
+
+type JSONRPCRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// JSONRPCResponse has either a result, or an error. The id is null for
+// requests that could not be read.
+type JSONRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
+
+func jsonrpcErrorf(code int, format string, a ...interface{}) *JSONRPCError {
+	return &JSONRPCError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// jsonrpcMethod is a method with its parameters, in order of their position.
+type jsonrpcMethod struct {
+	Params   []string
+	Mutating bool // Requires an API key, if API keys are enabled.
+	Call     func(params jsonrpcParams) (interface{}, error)
+}
+
+type jsonrpcParams map[string]json.RawMessage
+
+// get decodes a parameter, which is left untouched if absent (or null) and
+// not required.
+func (p jsonrpcParams) get(name string, v interface{}, required bool) error {
+	raw, ok := p[name]
+	if !ok || bytes.Equal(raw, []byte("null")) {
+		if required {
+			return jsonrpcErrorf(JSONRPCInvalidParams, "param '%s' is required", name)
+		}
+		return nil
+	}
+	if e := json.Unmarshal(raw, v); e != nil {
+		return jsonrpcErrorf(JSONRPCInvalidParams, "invalid param '%s': %v", name, e)
+	}
+	return nil
+}
+
+func (p jsonrpcParams) address(name string) (cipher.Address, error) {
+	var s string
+	if e := p.get(name, &s, true); e != nil {
+		return cipher.Address{}, e
+	}
+	address, e := cipher.DecodeBase58Address(s)
+	if e != nil {
+		return cipher.Address{}, jsonrpcErrorf(JSONRPCInvalidParams, "invalid param '%s': %v", name, e)
+	}
+	return address, nil
+}
+
+func jsonrpc(g *iko.BlockChain) HandlerFunc {
+	methods := jsonrpcMethods(g)
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		raw, e := io.ReadAll(io.LimitReader(r.Body, JSONRPCMaxRequestSize))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				e.Error())
+		}
+		raw = bytes.TrimSpace(raw)
+		authorized := apiKeyAuthorized(r)
+		if len(raw) == 0 || raw[0] != '[' {
+			res, ok := callJSONRPC(methods, raw, authorized)
+			if !ok {
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			}
+			return sendJson(w, http.StatusOK, res)
+		}
+
+		var batch []json.RawMessage
+		if e := json.Unmarshal(raw, &batch); e != nil {
+			return sendJson(w, http.StatusOK, JSONRPCResponse{
+				Version: JSONRPCVersion,
+				Error:   jsonrpcErrorf(JSONRPCParseError, "parse error: %v", e),
+				ID:      json.RawMessage("null"),
+			})
+		}
+		if len(batch) == 0 || len(batch) > JSONRPCMaxBatchSize {
+			return sendJson(w, http.StatusOK, JSONRPCResponse{
+				Version: JSONRPCVersion,
+				Error: jsonrpcErrorf(JSONRPCInvalidRequest,
+					"batches need to have 1 to %d requests", JSONRPCMaxBatchSize),
+				ID: json.RawMessage("null"),
+			})
+		}
+		replies := make([]JSONRPCResponse, 0, len(batch))
+		for _, req := range batch {
+			if res, ok := callJSONRPC(methods, req, authorized); ok {
+				replies = append(replies, res)
+			}
+		}
+		if len(replies) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		return sendJson(w, http.StatusOK, replies)
+	}
+}
+
+// callJSONRPC calls the method of a request. Not ok is for notifications,
+// which are not replied. Mutating methods are only called if authorized.
+func callJSONRPC(methods map[string]*jsonrpcMethod, raw []byte, authorized bool) (JSONRPCResponse, bool) {
+	res := JSONRPCResponse{Version: JSONRPCVersion, ID: json.RawMessage("null")}
+	var req JSONRPCRequest
+	if e := json.Unmarshal(raw, &req); e != nil {
+		code := JSONRPCInvalidRequest
+		if !json.Valid(raw) {
+			code = JSONRPCParseError
+		}
+		res.Error = jsonrpcErrorf(code, "invalid request: %v", e)
+		return res, true
+	}
+	if req.ID != nil {
+		res.ID = req.ID
+	}
+	if req.Version != JSONRPCVersion || req.Method == "" {
+		res.Error = jsonrpcErrorf(JSONRPCInvalidRequest,
+			"invalid request, expected 'jsonrpc' '%s' and a 'method'", JSONRPCVersion)
+		return res, true
+	}
+	result, e := callJSONRPCMethod(methods, req, authorized)
+	if req.ID == nil {
+		return res, false
+	}
+	if e == nil {
+		if res.Result, e = json.Marshal(result); e == nil {
+			return res, true
+		}
+	}
+	if res.Error, _ = e.(*JSONRPCError); res.Error == nil {
+		res.Error = jsonrpcErrorf(JSONRPCInternalError, "%v", e)
+	}
+	return res, true
+}
+
+func callJSONRPCMethod(methods map[string]*jsonrpcMethod, req JSONRPCRequest, authorized bool) (interface{}, error) {
+	method, ok := methods[req.Method]
+	if !ok {
+		return nil, jsonrpcErrorf(JSONRPCMethodNotFound, "method '%s' not found", req.Method)
+	}
+	if method.Mutating && !authorized {
+		return nil, jsonrpcErrorf(JSONRPCUnauthorized, "method '%s' requires an API key", req.Method)
+	}
+	params := make(jsonrpcParams)
+	switch raw := bytes.TrimSpace(req.Params); {
+	case len(raw) == 0:
+	case raw[0] == '[':
+		var list []json.RawMessage
+		if e := json.Unmarshal(raw, &list); e != nil {
+			return nil, jsonrpcErrorf(JSONRPCInvalidParams, "invalid params: %v", e)
+		}
+		if len(list) > len(method.Params) {
+			return nil, jsonrpcErrorf(JSONRPCInvalidParams,
+				"method '%s' has at most %d params", req.Method, len(method.Params))
+		}
+		for i, v := range list {
+			params[method.Params[i]] = v
+		}
+	case raw[0] == '{':
+		if e := json.Unmarshal(raw, &params); e != nil {
+			return nil, jsonrpcErrorf(JSONRPCInvalidParams, "invalid params: %v", e)
+		}
+		for name := range params {
+			if !containsString(method.Params, name) {
+				return nil, jsonrpcErrorf(JSONRPCInvalidParams,
+					"unknown param '%s' for method '%s'", name, req.Method)
+			}
+		}
+	default:
+		return nil, jsonrpcErrorf(JSONRPCInvalidParams, "params need to be an array or an object")
+	}
+	return method.Call(params)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonrpcMethods are the methods of the chain, with the replies of the gateway:
+//
+//	getHeadTx()                                -> TxLookupReply
+//	getTxOfHash(hash)                          -> TxLookupReply
+//	getTxOfSeq(seq)                            -> TxLookupReply
+//	getTxRange(start_seq, page_size, desc)     -> TxRangeReply
+//	injectTx(hex, transaction)                 -> AcceptedTxReply
+//	getKitty(kitty_id)                         -> KittyOwnerReply
+//	getAddress(address)                        -> AddressReply
+//	getAddressKitties(address, page, per_page) -> AddressKittiesReply
+func jsonrpcMethods(g *iko.BlockChain) map[string]*jsonrpcMethod {
+	return map[string]*jsonrpcMethod{
+		"getHeadTx": {
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				tx, e := g.GetHeadTx()
+				if e != nil {
+					return nil, jsonrpcErrorf(JSONRPCNotFound, "%v", e)
+				}
+				return confirmedTxLookup(g, tx)
+			},
+		},
+		"getTxOfHash": {
+			Params: []string{"hash"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				var s string
+				if e := params.get("hash", &s, true); e != nil {
+					return nil, e
+				}
+				txHash, e := cipher.SHA256FromHex(s)
+				if e != nil {
+					return nil, jsonrpcErrorf(JSONRPCInvalidParams, "invalid param 'hash': %v", e)
+				}
+				tx, e := g.GetTxOfHash(iko.TxHash(txHash))
+				if e == nil {
+					return confirmedTxLookup(g, tx)
+				}
+				if reply, ok := pendingTxLookup(g, iko.TxHash(txHash)); ok {
+					return reply, nil
+				}
+				return nil, jsonrpcErrorf(JSONRPCNotFound, "%v", e)
+			},
+		},
+		"getTxOfSeq": {
+			Params: []string{"seq"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				var seq uint64
+				if e := params.get("seq", &seq, true); e != nil {
+					return nil, e
+				}
+				tx, e := g.GetTxOfSeq(seq)
+				if e != nil {
+					return nil, jsonrpcErrorf(JSONRPCNotFound, "%v", e)
+				}
+				return confirmedTxLookup(g, tx)
+			},
+		},
+		"getTxRange": {
+			Params: []string{"start_seq", "page_size", "desc"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				var desc bool
+				if e := params.get("desc", &desc, false); e != nil {
+					return nil, e
+				}
+				reply := TxRangeReply{PageSize: iko.DefaultTxRangeSize, Dir: "asc"}
+				if desc {
+					reply.StartSeq, reply.Dir = math.MaxUint64, "desc"
+				}
+				if e := params.get("start_seq", &reply.StartSeq, false); e != nil {
+					return nil, e
+				}
+				if e := params.get("page_size", &reply.PageSize, false); e != nil {
+					return nil, e
+				}
+				if e := fillTxRange(g, &reply); e != nil {
+					return nil, jsonrpcErrorf(JSONRPCInvalidParams, "%v", e)
+				}
+				return reply, nil
+			},
+		},
+		"injectTx": {
+			Params:   []string{"hex", "transaction"},
+			Mutating: true,
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				var req InjectTxRequest
+				if e := params.get("hex", &req.Hex, false); e != nil {
+					return nil, e
+				}
+				if e := params.get("transaction", &req.Tx, false); e != nil {
+					return nil, e
+				}
+				tx := req.Tx
+				if tx == nil {
+					if req.Hex == "" {
+						return nil, jsonrpcErrorf(JSONRPCInvalidParams,
+							"either param 'hex' or 'transaction' is required")
+					}
+					raw, e := hex.DecodeString(req.Hex)
+					if e == nil {
+						tx, e = iko.DecodeTx(raw)
+					}
+					if e != nil {
+						return nil, jsonrpcErrorf(JSONRPCInvalidParams, "invalid param 'hex': %v", e)
+					}
+				}
+				if e := g.InjectTx(tx); e != nil {
+					if accepted, ok := e.(*iko.TxAcceptedError); ok {
+						return NewAcceptedTxReply(accepted), nil
+					}
+					return nil, &JSONRPCError{
+						Code:    JSONRPCTxRejected,
+						Message: e.Error(),
+						Data:    txErrorKind(e),
+					}
+				}
+				return AcceptedTxReply{TxHash: tx.Hash().Hex(), Seq: tx.Seq}, nil
+			},
+		},
+		"getKitty": {
+			Params: []string{"kitty_id"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				var kittyID iko.KittyID
+				if e := params.get("kitty_id", &kittyID, true); e != nil {
+					return nil, e
+				}
+				reply, ok := newKittyOwnerReply(g, kittyID)
+				if !ok {
+					return nil, jsonrpcErrorf(JSONRPCNotFound, "kitty of id '%d' not found", kittyID)
+				}
+				return reply, nil
+			},
+		},
+		"getAddress": {
+			Params: []string{"address"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				address, e := params.address("address")
+				if e != nil {
+					return nil, e
+				}
+				return NewAddressReply(address, g.GetAddressState(address)), nil
+			},
+		},
+		"getAddressKitties": {
+			Params: []string{"address", "page", "per_page"},
+			Call: func(params jsonrpcParams) (interface{}, error) {
+				address, e := params.address("address")
+				if e != nil {
+					return nil, e
+				}
+				reply := AddressKittiesReply{
+					Address: address.String(),
+					PerPage: DefaultKittiesPageSize,
+				}
+				if e := params.get("page", &reply.Page, false); e != nil {
+					return nil, e
+				}
+				if e := params.get("per_page", &reply.PerPage, false); e != nil {
+					return nil, e
+				}
+				paginated, e := g.GetKittiesOfAddress(address, reply.Page, reply.PerPage)
+				if e != nil {
+					return nil, jsonrpcErrorf(JSONRPCInvalidParams, "%v", e)
+				}
+				reply.Count = g.CountOfAddress(address)
+				reply.TotalPageCount = paginated.TotalPageCount
+				reply.Kitties = paginated.Kitties
+				return reply, nil
+			},
+		},
+	}
+}
+
+// txErrorKind obtains the message of the kind of an error from injecting a
+// transaction, if any.
+func txErrorKind(e error) interface{} {
+	for _, kind := range []error{
+		iko.ErrBadSignature,
+		iko.ErrNotOwner,
+		iko.ErrKittyUnknown,
+		iko.ErrDuplicateTx,
+		iko.ErrExpired,
+		iko.ErrRateLimited,
+	} {
+		if errors.Is(e, kind) {
+			return kind.Error()
+		}
+	}
+	return nil
+}
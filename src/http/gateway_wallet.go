@@ -1,10 +1,18 @@
 package http
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/kittycash/wallet/src/iko"
 	"github.com/kittycash/wallet/src/wallet"
+	"github.com/skycoin/skycoin/src/cipher"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func walletGateway(mux *http.ServeMux, g *wallet.Manager) error {
@@ -15,118 +23,1239 @@ func walletGateway(mux *http.ServeMux, g *wallet.Manager) error {
 	Handle(mux, "/api/wallets/list",
 		"GET", listWallets(g))
 
+	Handle(mux, "/api/wallets/verify",
+		"POST", verifyWallets(g))
+
 	Handle(mux, "/api/wallets/new",
 		"POST", newWallet(g))
 
+	Handle(mux, "/api/wallets/get",
+		"POST", getWallet(g))
+
+	Handle(mux, "/api/wallets/unlock",
+		"POST", unlockWallet(g))
+
+	Handle(mux, "/api/wallets/lock",
+		"POST", lockWallet(g))
+
+	Handle(mux, "/api/wallets/change_password",
+		"POST", changeWalletPassword(g))
+
+	Handle(mux, "/api/wallets/delete_token",
+		"POST", deleteWalletToken(g))
+
+	Handle(mux, "/api/wallets/delete",
+		"POST", deleteWallet(g))
+
+	Handle(mux, "/api/wallets/trash/list",
+		"GET", listTrash(g))
+
+	Handle(mux, "/api/wallets/trash/recover",
+		"POST", recoverWallet(g))
+
+	Handle(mux, "/api/wallets/trash/purge",
+		"POST", purgeTrash(g))
+
+	Handle(mux, "/api/wallets/watch_address",
+		"POST", watchWalletAddress(g))
+
+	Handle(mux, "/api/wallets/backup",
+		"POST", backupWallets(g))
+
+	Handle(mux, "/api/wallets/restore",
+		"POST", restoreWallets(g))
+
+	MultiHandle(mux, []string{
+		"/api/wallets/export.json",
+		"/api/wallets/export.csv",
+	}, "POST", exportWallets(g))
+
+	MultiHandle(mux, []string{
+		"/api/wallets/paper_wallet.json",
+		"/api/wallets/paper_wallet.html",
+	}, "POST", newPaperWallet(g))
+
+	Handle(mux, "/api/wallets/new_addresses",
+		"POST", newWalletAddresses(g))
+
+	Handle(mux, "/api/wallets/import_key",
+		"POST", importWalletKey(g))
+
+	Handle(mux, "/api/wallets/import_skycoin",
+		"POST", importSkycoinWallet(g))
+
+	Handle(mux, "/api/wallets/split_seed",
+		"POST", splitWalletSeed(g))
+
+	Handle(mux, "/api/wallets/combine_seed",
+		"POST", combineSeed())
+
+	Handle(mux, "/api/wallets/audit",
+		"GET", getAuditLog(g))
+
+	Handle(mux, "/api/wallets/password_strength",
+		"POST", checkPassword(g))
+
+	Handle(mux, "/api/wallets/duplicates",
+		"GET", findDuplicates(g))
+
+	Handle(mux, "/api/wallets/set_name",
+		"POST", setWalletName(g))
+
+	Handle(mux, "/api/wallets/set_meta",
+		"POST", setWalletMeta(g))
+
+	Handle(mux, "/api/wallets/keystores",
+		"GET", listKeystores())
+
+	Handle(mux, "/api/wallets/set_keystore",
+		"POST", setWalletKeystore(g))
+
+	Handle(mux, "/api/wallets/set_hold",
+		"POST", setWalletHold(g))
+
+	Handle(mux, "/api/wallets/set_kitty_note",
+		"POST", setKittyNote(g))
+
+	Handle(mux, "/api/wallets/add_account",
+		"POST", addAccount(g))
+
+	Handle(mux, "/api/wallets/new_account_address",
+		"POST", newAccountAddress(g))
+
+	Handle(mux, "/api/address_book/list",
+		"GET", listContacts(g))
+
+	Handle(mux, "/api/address_book/put",
+		"POST", putContact(g))
+
+	Handle(mux, "/api/address_book/remove",
+		"POST", removeContact(g))
+
+	Handle(mux, "/api/address_book/resolve",
+		"GET", resolveContact(g))
+
+	return nil
+}
+
+// walletChainGateway hosts the endpoints for the kitties of wallets, which
+// need both the chain and the wallet manager.
+func walletChainGateway(mux *http.ServeMux, bc *iko.BlockChain, g *wallet.Manager) error {
+
+	Handle(mux, "/api/wallets/scan_holdings",
+		"POST", scanHoldings(bc, g))
+
+	Handle(mux, "/api/wallets/holdings",
+		"GET", getHoldings(g))
+
+	Handle(mux, "/api/wallets/sign_transfer",
+		"POST", signTransfer(bc, g))
+
+	Handle(mux, "/api/wallets/events",
+		"GET", walletEvents(g))
+
+	Handle(mux, "/api/wallets/discover_addresses",
+		"POST", discoverAddresses(bc, g))
+
+	Handle(mux, "/api/wallets/history",
+		"GET", walletHistory(bc, g))
+
 	return nil
 }
 
+// walletErrorStatus obtains the status code for an error from the wallet
+// manager.
+func walletErrorStatus(e error) int {
+	switch {
+	case errors.Is(e, wallet.ErrWalletNotFound),
+		errors.Is(e, wallet.ErrAccountNotFound),
+		errors.Is(e, wallet.ErrContactNotFound):
+		return http.StatusNotFound
+	case errors.Is(e, wallet.ErrWalletLocked),
+		errors.Is(e, wallet.ErrPasswordRequired),
+		errors.Is(e, wallet.ErrInvalidPassword):
+		return http.StatusUnauthorized
+	case errors.Is(e, wallet.ErrInvalidDeleteToken),
+		errors.Is(e, wallet.ErrUnencryptedWallet),
+		errors.Is(e, wallet.ErrWalletHeld):
+		return http.StatusForbidden
+	case errors.Is(e, wallet.ErrLabelAlreadyExists),
+		errors.Is(e, wallet.ErrDuplicateAddress):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 func refreshWallets(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
-		// TODO: implement.
-		// CALLS: 'g.Refresh()'.
-		// RESPONSE:
-		// - 'Content-Type': 'application/json'.
-		// - true & 200   : on success.
-		// - string & 500 : of the error on failure.
-
-		// Send json response with 500 status code if error
 		if e := g.Refresh(); e != nil {
-			sendJson(w, http.StatusInternalServerError,
+			return sendJson(w, http.StatusInternalServerError,
 				fmt.Sprintf("Message: '%s'", e))
 		}
-
-		// Send json response with 200 status code if error is nil
 		return sendJson(w, http.StatusOK, true)
 	}
 }
 
+type VerifyReply struct {
+	Quarantined []wallet.QuarantinedFile `json:"quarantined"`
+}
+
+func verifyWallets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		quarantined, e := g.VerifyAll()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, VerifyReply{
+			Quarantined: quarantined,
+		})
+	}
+}
+
 type WalletsReply struct {
-	Wallets []wallet.Stat `json:"wallets"`
+	Wallets        []wallet.Stat `json:"wallets"`
+	Total          int           `json:"total"`
+	TotalPageCount uint64        `json:"total_page_count"`
 }
 
 func listWallets(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
-		// TODO: implement.
-		// CALLS: 'g.ListWallets()'.
-		// RESPONSE:
-		// - 'Content-Type': 'application/json'.
-		// - json representation of 'WalletsReply'.
+		q := r.URL.Query()
+		opts := wallet.ListOptions{
+			Filter: q.Get("filter"),
+			Sort:   wallet.WalletSort(q.Get("sort")),
+		}
+		switch order := q.Get("order"); order {
+		case "", "asc":
+		case "desc":
+			opts.Desc = true
+		default:
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid order '%s'", order))
+		}
+		for key, v := range map[string]*uint64{"page": &opts.Page, "per_page": &opts.PerPage} {
+			if s := q.Get(key); s != "" {
+				n, e := strconv.ParseUint(s, 10, 64)
+				if e != nil {
+					return sendJson(w, http.StatusBadRequest,
+						fmt.Sprintf("Error: invalid %s '%s'", key, s))
+				}
+				*v = n
+			}
+		}
+		page, e := g.ListWalletsPage(opts)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, WalletsReply{
+			Wallets:        page.Wallets,
+			Total:          page.Total,
+			TotalPageCount: page.TotalPageCount,
+		})
+	}
+}
+
+func newWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+
+		opts := wallet.Options{
+			Label:    r.PostFormValue("label"),
+			Name:     r.PostFormValue("name"),
+			Seed:     r.PostFormValue("seed"),
+			Password: r.PostFormValue("password"),
+			Keystore: r.PostFormValue("keystore"),
+		}
+		var e error
+		if opts.Encrypted, e = parseFormBool(r, "encrypted"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if opts.SeedPhrase, e = parseFormBool(r, "seed_phrase"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if opts.WatchOnly, e = parseFormBool(r, "watch_only"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if v := r.PostFormValue("watch_addresses"); v != "" {
+			opts.WatchAddresses = strings.Split(v, ",")
+		}
+		if v := r.PostFormValue("addresses"); v != "" {
+			if opts.Addresses, e = strconv.Atoi(v); e != nil {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid addresses '%s'", v))
+			}
+		}
+		if v := r.PostFormValue("hold_until"); v != "" {
+			until, e := time.Parse(time.RFC3339, v)
+			if e != nil {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid hold_until '%s'", v))
+			}
+			opts.HoldUntil = until.UnixNano()
+		}
+		if e := opts.Verify(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+
+		fw, e := g.CreateWallet(&opts)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
 
-		// Get list of listWall
-		var listWall WalletsReply
-		listWall.Wallets = g.ListWallets()
+func getWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
 
-		if listWall.Wallets != nil {
-			// Send json response, status= 200 to user if content was found
-			return sendJson(w, http.StatusOK, listWall)
+		var (
+			label    = r.PostFormValue("label")
+			password = r.PostFormValue("password")
+			fw       *wallet.FloatingWallet
+			e        error
+		)
+		if password == "" {
+			fw, e = g.GetWallet(label)
 		} else {
-			// Send json response, status= 204 to user if no content was found
-			return sendJson(w, http.StatusNoContent, listWall)
+			fw, e = g.DisplayWallet(label, password)
+		}
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
 		}
+		return sendJson(w, http.StatusOK, fw)
 	}
 }
 
-func newWallet(g *wallet.Manager) HandlerFunc {
+func unlockWallet(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
-		// TODO: implement.
-		// CALLS: 'g.NewWallet()'.
-		// REQUEST:
-		// - 'Content-Type': 'application/x-www-form-urlencoded'.
-		// - Check actual function for all key-values.
-		// RESPONSE:
-		// - 'Content-Type': 'application/json'.
-		// - true & 200   : on success.
-		// - string & http status code : of the error on failure.
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		ttl := wallet.DefaultUnlockTTL
+		if v := r.PostFormValue("ttl"); v != "" {
+			var e error
+			if ttl, e = time.ParseDuration(v); e != nil {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid ttl '%s'", v))
+			}
+		}
+		if e := g.Unlock(r.PostFormValue("label"), r.PostFormValue("password"), ttl); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
 
-		// Send json response if body is nil
-		if r.Body == nil {
+func lockWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
 			return sendJson(w, http.StatusBadRequest,
-					fmt.Sprint("Request body missing"))
+				fmt.Sprintf("Error: %s", e))
+		}
+		if e := g.Lock(r.PostFormValue("label")); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
 		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
 
+func changeWalletPassword(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
 		if e := r.ParseForm(); e != nil {
 			return sendJson(w, http.StatusBadRequest,
-					fmt.Sprintf("Error: %s", e))
+				fmt.Sprintf("Error: %s", e))
+		}
+		e := g.ChangeWalletPassword(r.PostFormValue("label"),
+			r.PostFormValue("old_password"), r.PostFormValue("new_password"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+func deleteWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		e := g.DeleteWallet(r.PostFormValue("label"),
+			r.PostFormValue("confirm_token"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+type DeleteTokenReply struct {
+	ConfirmToken string `json:"confirm_token"`
+}
+
+func deleteWalletToken(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		token, e := g.DeleteToken(r.PostFormValue("label"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
 		}
+		return sendJson(w, http.StatusOK, DeleteTokenReply{
+			ConfirmToken: token,
+		})
+	}
+}
 
-		encrypted, err := strconv.ParseBool(r.PostFormValue("encrypted"))
+type TrashReply struct {
+	Wallets []wallet.TrashedWallet `json:"wallets"`
+}
 
-		if err != nil {
-			sendJson(w, http.StatusBadRequest,
-			fmt.Sprintf("Error: %s", err))
+func listTrash(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		list, e := g.ListTrash()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
 		}
-		// Options to pass to g.NewWallet()
-		opts := wallet.Options{
-			Label: r.PostFormValue("label"),
-			Seed: r.PostFormValue("seed"),
-			Encrypted: encrypted,
-			Password: r.PostFormValue("password"),
+		return sendJson(w, http.StatusOK, TrashReply{
+			Wallets: list,
+		})
+	}
+}
+
+func recoverWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		tw, e := g.RecoverWallet(r.PostFormValue("id"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, tw)
+	}
+}
+
+// purgeTrash purges the deleted wallets in the trash that are past the grace
+// period, or every deleted wallet if 'all'.
+func purgeTrash(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		all, e := parseFormBool(r, "all")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		secure, e := parseFormBool(r, "secure")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		before := time.Now().Add(-wallet.TrashPeriod)
+		if all {
+			before = time.Now()
+		}
+		purged, e := g.PurgeTrash(before, secure)
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, TrashReply{
+			Wallets: purged,
+		})
+	}
+}
+
+func watchWalletAddress(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		addr, e := cipher.DecodeBase58Address(r.PostFormValue("address"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid address: %s", e))
+		}
+		fw, e := g.WatchWalletAddress(r.PostFormValue("label"), addr)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+func importWalletKey(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		duplicates, ok := duplicatePolicies[r.PostFormValue("duplicates")]
+		if !ok {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid duplicates '%s'", r.PostFormValue("duplicates")))
+		}
+		res, e := g.ImportWalletKeyWithPolicy(r.PostFormValue("label"),
+			r.PostFormValue("secret_key"), r.PostFormValue("key_label"), duplicates)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, res)
+	}
+}
+
+type DuplicatesReply struct {
+	Duplicates []wallet.DuplicateAddress `json:"duplicates"`
+}
+
+type AuditReply struct {
+	Head    string              `json:"head"` // MAC of the latest entry in the whole log.
+	Entries []wallet.AuditEntry `json:"entries"`
+}
+
+func getAuditLog(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		q := r.URL.Query()
+		query := wallet.AuditQuery{
+			Label:  q.Get("label"),
+			Action: wallet.AuditAction(q.Get("action")),
+		}
+		for key, v := range map[string]*int64{"since": &query.Since, "until": &query.Until} {
+			if s := q.Get(key); s != "" {
+				t, e := time.Parse(time.RFC3339, s)
+				if e != nil {
+					return sendJson(w, http.StatusBadRequest,
+						fmt.Sprintf("Error: invalid %s '%s'", key, s))
+				}
+				*v = t.UnixNano()
+			}
+		}
+		if s := q.Get("limit"); s != "" {
+			n, e := strconv.Atoi(s)
+			if e != nil || n < 0 {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid limit '%s'", s))
+			}
+			query.Limit = n
+		}
+		head, e := g.VerifyAuditLog()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		entries, e := g.AuditLog(query)
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
 		}
+		return sendJson(w, http.StatusOK, AuditReply{
+			Head:    head,
+			Entries: entries,
+		})
+	}
+}
 
-		/**
-		 * Verify that all values are correct
-		 * Respond if options are not correct
-		 */
-		if e := opts.Verify; e != nil {
-			sendJson(w, http.StatusBadRequest,
-				fmt.Sprintf("Message: %s", err))
+// checkPassword estimates the strength of a password, such as from the GUI as
+// the password for a new wallet is typed. The label and name of the wallet
+// count as guessable words in the password.
+func checkPassword(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
 		}
+		return sendJson(w, http.StatusOK, g.CheckPassword(r.PostFormValue("password"),
+			r.PostFormValue("label"), r.PostFormValue("name")))
+	}
+}
 
-		// Get addresses and convert it to int
-		addr, err := strconv.Atoi(r.PostFormValue("addresses"))
+func findDuplicates(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, DuplicatesReply{
+			Duplicates: g.FindDuplicates(),
+		})
+	}
+}
 
-		// Don't allow anything other than int
-		if err != nil || addr < 1 {
-			sendJson(w, http.StatusNotAcceptable,
-				fmt.Sprintf("Error: %s", err))
+func setWalletName(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
 		}
+		fw, e := g.SetWalletName(r.PostFormValue("label"), r.PostFormValue("name"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
 
-		if e := g.NewWallet(&opts, addr); e != nil {
-			sendJson(w, http.StatusInternalServerError,
+type SeedSharesReply struct {
+	Shares []string `json:"shares"`
+}
+
+// importSkycoinWallet creates a wallet from the uploaded Skycoin wallet file,
+// or imports its keys into the existing wallet with the label.
+func importSkycoinWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		f, _, e := r.FormFile("wlt")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
 				fmt.Sprintf("Error: %s", e))
 		}
+		defer f.Close()
 
-		return sendJson(w, http.StatusOK, true)
+		sw, e := wallet.ParseSkycoinWallet(f)
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		existing, e := parseFormBool(r, "existing")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if existing {
+			duplicates, ok := duplicatePolicies[r.FormValue("duplicates")]
+			if !ok {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid duplicates '%s'", r.FormValue("duplicates")))
+			}
+			res, e := g.ImportSkycoinKeys(r.FormValue("label"), sw, duplicates)
+			if e != nil {
+				return sendJson(w, walletErrorStatus(e),
+					fmt.Sprintf("Error: %s", e))
+			}
+			return sendJson(w, http.StatusOK, res)
+		}
+
+		opts := wallet.Options{
+			Label:    r.FormValue("label"),
+			Name:     r.FormValue("name"),
+			Password: r.FormValue("password"),
+			Keystore: r.FormValue("keystore"),
+		}
+		if opts.Name == "" {
+			opts.Name = sw.Label()
+		}
+		if opts.Encrypted, e = parseFormBool(r, "encrypted"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		res, e := g.CreateSkycoinWallet(sw, &opts)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, res)
+	}
+}
+
+func splitWalletSeed(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		n, e := strconv.Atoi(r.PostFormValue("shares"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid shares '%s'", r.PostFormValue("shares")))
+		}
+		k, e := strconv.Atoi(r.PostFormValue("threshold"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid threshold '%s'", r.PostFormValue("threshold")))
+		}
+		shares, e := g.SplitWalletSeed(r.PostFormValue("label"), n, k)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, SeedSharesReply{
+			Shares: shares,
+		})
+	}
+}
+
+type SeedReply struct {
+	Seed string `json:"seed"`
+}
+
+func combineSeed() HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		seed, e := wallet.CombineSeed(strings.Split(r.PostFormValue("shares"), ","))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, SeedReply{
+			Seed: seed,
+		})
+	}
+}
+
+type KeystoresReply struct {
+	Keystores []string `json:"keystores"`
+}
+
+func listKeystores() HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, KeystoresReply{
+			Keystores: wallet.Keystores(),
+		})
+	}
+}
+
+func setWalletKeystore(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		fw, e := g.SetWalletKeystore(r.PostFormValue("label"), r.PostFormValue("keystore"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+func setWalletMeta(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		fw, e := g.SetWalletMeta(r.PostFormValue("label"),
+			r.PostFormValue("key"), r.PostFormValue("value"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+func setWalletHold(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		until, e := time.Parse(time.RFC3339, r.PostFormValue("until"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid until '%s'", r.PostFormValue("until")))
+		}
+		fw, e := g.SetWalletHold(r.PostFormValue("label"), until)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+func setKittyNote(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		kittyID, e := iko.KittyIDFromString(r.PostFormValue("kitty_id"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		fw, e := g.SetKittyNote(r.PostFormValue("label"),
+			kittyID, r.PostFormValue("note"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+func addAccount(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		fw, e := g.AddWalletAccount(r.PostFormValue("label"),
+			r.PostFormValue("name"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+type NewAccountAddressReply struct {
+	Account string `json:"account"`
+	Address string `json:"address"`
+}
+
+func newAccountAddress(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		account := r.PostFormValue("account")
+		addr, e := g.NewWalletAccountAddress(r.PostFormValue("label"), account)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		if account == "" {
+			account = wallet.DefaultAccountName
+		}
+		return sendJson(w, http.StatusOK, NewAccountAddressReply{
+			Account: account,
+			Address: addr.String(),
+		})
+	}
+}
+
+type NewAddressesReply struct {
+	Addresses []string `json:"addresses"`
+}
+
+func newWalletAddresses(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		n, e := strconv.Atoi(r.PostFormValue("n"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid n '%s'", r.PostFormValue("n")))
+		}
+		addrs, e := g.NewWalletAddresses(r.PostFormValue("label"), n)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		reply := NewAddressesReply{
+			Addresses: make([]string, len(addrs)),
+		}
+		for i, addr := range addrs {
+			reply.Addresses[i] = addr.String()
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+func backupWallets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		var buf bytes.Buffer
+		if e := g.Backup(&buf, r.PostFormValue("password")); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		w.Header().Set("Content-Disposition",
+			`attachment; filename="wallets.kcwb"`)
+		return sendBin(w, http.StatusOK, buf.Bytes())
+	}
+}
+
+// restorePolicies are the names of the policies for restoring wallets.
+var restorePolicies = map[string]wallet.RestorePolicy{
+	"":        wallet.RestoreAbort,
+	"abort":   wallet.RestoreAbort,
+	"merge":   wallet.RestoreMerge,
+	"replace": wallet.RestoreReplace,
+}
+
+// duplicatePolicies are the names of the policies for addresses of other
+// wallets.
+var duplicatePolicies = map[string]wallet.DuplicatePolicy{
+	"":       wallet.DuplicateReject,
+	"reject": wallet.DuplicateReject,
+	"merge":  wallet.DuplicateMerge,
+	"allow":  wallet.DuplicateAllow,
+}
+
+func restoreWallets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		f, _, e := r.FormFile("archive")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		defer f.Close()
+
+		policy, ok := restorePolicies[r.FormValue("policy")]
+		if !ok {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid policy '%s'", r.FormValue("policy")))
+		}
+		duplicates, ok := duplicatePolicies[r.FormValue("duplicates")]
+		if !ok {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: invalid duplicates '%s'", r.FormValue("duplicates")))
+		}
+		res, e := g.RestoreWithPolicy(f, r.FormValue("password"), policy, duplicates)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, res)
+	}
+}
+
+func exportWallets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		opts := wallet.ExportOptions{
+			Confirm: r.PostFormValue("confirm"),
+		}
+		var contentType string
+		switch p.Extension {
+		case ".json":
+			opts.Format, contentType = wallet.ExportJSON, "application/json"
+		case ".csv":
+			opts.Format, contentType = wallet.ExportCSV, "text/csv"
+		}
+		var e error
+		if opts.SecretKeys, e = parseFormBool(r, "secret_keys"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if opts.PublicOnly, e = parseFormBool(r, "public_only"); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		var labels []string
+		if v := r.PostFormValue("labels"); v != "" {
+			labels = strings.Split(v, ",")
+		}
+
+		var buf bytes.Buffer
+		if e := g.Export(&buf, labels, opts); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		_, e = w.Write(buf.Bytes())
+		return e
+	}
+}
+
+func newPaperWallet(_ *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		pw, e := wallet.NewPaperWallet()
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if p.Extension != ".html" {
+			return sendJson(w, http.StatusOK, pw)
+		}
+		var buf bytes.Buffer
+		if e := pw.HTML(&buf); e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, e = w.Write(buf.Bytes())
+		return e
+	}
+}
+
+type HoldingsReply struct {
+	Holdings []wallet.Holdings `json:"holdings"`
+}
+
+func scanHoldings(bc *iko.BlockChain, g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		holdings, e := g.ScanHoldings(bc)
+		if e != nil {
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, HoldingsReply{
+			Holdings: holdings,
+		})
+	}
+}
+
+func getHoldings(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		holdings, e := g.Holdings(r.URL.Query().Get("label"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, holdings)
+	}
+}
+
+type SignedTransferReply struct {
+	Raw      string `json:"raw"`
+	Hash     string `json:"hash"`
+	Injected bool   `json:"injected"`
+}
+
+// discoverAddresses ensures the entries of a wallet up to the last address
+// that is used on the chain (see 'Manager.DiscoverAddresses').
+func discoverAddresses(bc *iko.BlockChain, g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		var gapLimit int
+		if v := r.PostFormValue("gap_limit"); v != "" {
+			var e error
+			if gapLimit, e = strconv.Atoi(v); e != nil {
+				return sendJson(w, http.StatusBadRequest,
+					fmt.Sprintf("Error: invalid gap_limit '%s'", v))
+			}
+		}
+		fw, e := g.DiscoverAddresses(bc, r.PostFormValue("label"), gapLimit)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, fw)
+	}
+}
+
+// walletHistory obtains a page of the sends and receives of kitties by a
+// wallet, newest first.
+func walletHistory(bc *iko.BlockChain, g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		q := r.URL.Query()
+		var page, perPage uint64
+		for key, v := range map[string]*uint64{"page": &page, "per_page": &perPage} {
+			if s := q.Get(key); s != "" {
+				n, e := strconv.ParseUint(s, 10, 64)
+				if e != nil {
+					return sendJson(w, http.StatusBadRequest,
+						fmt.Sprintf("Error: invalid %s '%s'", key, s))
+				}
+				*v = n
+			}
+		}
+		history, e := g.History(bc, q.Get("label"), page, perPage)
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, history)
+	}
+}
+
+// signTransfer signs a transfer of a kitty with the key of the wallet that
+// owns it, and optionally injects it.
+func signTransfer(bc *iko.BlockChain, g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		kittyID, e := iko.KittyIDFromString(r.PostFormValue("kitty_id"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		to, e := cipher.DecodeBase58Address(r.PostFormValue("to"))
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		inject, e := parseFormBool(r, "inject")
+		if e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		tx, e := g.SignTransfer(bc, r.PostFormValue("label"), kittyID, to)
+		if e != nil {
+			status := walletErrorStatus(e)
+			if status == http.StatusBadRequest {
+				status = txErrorStatus(e)
+			}
+			return sendJson(w, status,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if inject {
+			if e := bc.InjectTx(tx); e != nil {
+				return sendJson(w, txErrorStatus(e),
+					fmt.Sprintf("Error: %s", e))
+			}
+		}
+		return sendJson(w, http.StatusOK, SignedTransferReply{
+			Raw:      hex.EncodeToString(tx.Serialize()),
+			Hash:     tx.Hash().Hex(),
+			Injected: inject,
+		})
+	}
+}
+
+type ContactsReply struct {
+	Contacts []wallet.Contact `json:"contacts"`
+}
+
+func listContacts(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, ContactsReply{
+			Contacts: g.AddressBook().List(),
+		})
+	}
+}
+
+func putContact(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		c := wallet.Contact{
+			Name:    r.PostFormValue("name"),
+			Address: r.PostFormValue("address"),
+			Note:    r.PostFormValue("note"),
+		}
+		if e := g.AddressBook().Put(c); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+func removeContact(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		if e := g.AddressBook().Remove(r.PostFormValue("name")); e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
+
+type ResolveReply struct {
+	Address string `json:"address"`
+}
+
+func resolveContact(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		addr, e := g.AddressBook().Resolve(r.URL.Query().Get("name"))
+		if e != nil {
+			return sendJson(w, walletErrorStatus(e),
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, ResolveReply{
+			Address: addr.String(),
+		})
+	}
+}
+
+// parseFormBool parses an optional boolean form value (false if empty).
+func parseFormBool(r *http.Request, key string) (bool, error) {
+	v := r.PostFormValue(key)
+	if v == "" {
+		return false, nil
+	}
+	b, e := strconv.ParseBool(v)
+	if e != nil {
+		return false, fmt.Errorf("invalid %s '%s'", key, v)
+	}
+	return b, nil
+}
+
+// walletEvents streams the events of the wallets (see 'Manager.WatchChain')
+// as server-sent events, until the client disconnects.
+func walletEvents(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return sendJson(w, http.StatusInternalServerError,
+				"Error: streaming is not supported")
+		}
+		sub := g.SubscribeEvents(wallet.DefaultEventBufferSize)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+			case ev, ok := <-sub.C():
+				if !ok {
+					return nil
+				}
+				data, e := json.Marshal(ev)
+				if e != nil {
+					return e
+				}
+				if _, e := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data); e != nil {
+					return e
+				}
+				flusher.Flush()
+			}
+		}
 	}
 }
@@ -1,12 +1,18 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	_"errors"
 	"github.com/skycoin/skycoin/src/cipher"
-	_"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/require"
 	_"github.com/kittycash/wallet/src/iko"
 	"github.com/kittycash/wallet/src/wallet"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"net/http"
 	_"sync"
@@ -73,3 +79,71 @@ func TestWalletGateway(t *testing.T) {
 	}
 	*/
 }
+
+func TestWalletGateway_CRUD(t *testing.T) {
+	// None of the wallets is written to disk.
+	wallet.SetStore(wallet.NewMemoryStore())
+	defer wallet.SetStore(wallet.DirStore{})
+	require.Nil(t, wallet.SetRootDir(filepath.Join(os.TempDir(), "kittycash_test")), "failed to set root dir")
+
+	m, e := wallet.NewManager()
+	require.Nil(t, e, "failed to create manager")
+	mux := http.NewServeMux()
+	require.Nil(t, walletGateway(mux, m), "failed to host wallet gateway")
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("/api/wallets/new", url.Values{
+		"label": {"one"}, "seed": {"one seed"}, "addresses": {"2"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var created wallet.FloatingWallet
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &created), "failed to decode wallet")
+	require.Len(t, created.Entries, 2, "wallet should have its addresses")
+
+	w = post("/api/wallets/new", url.Values{"label": {"one"}, "seed": {"other seed"}})
+	require.Equal(t, http.StatusConflict, w.Code, "labels should be unique")
+
+	w = post("/api/wallets/new", url.Values{"label": {"two"}, "seed": {"seed"}, "encrypted": {"maybe"}})
+	require.Equal(t, http.StatusBadRequest, w.Code, "invalid booleans should fail")
+
+	w = post("/api/wallets/new", url.Values{
+		"label": {"two"}, "seed": {"two seed"}, "encrypted": {"true"}, "password": {"pw"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	r := httptest.NewRequest("GET", "/api/wallets/list", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	var list WalletsReply
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list), "failed to decode list")
+	require.Len(t, list.Wallets, 2, "both wallets should be listed")
+
+	w = post("/api/wallets/get", url.Values{"label": {"one"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.Nil(t, m.Refresh(), "failed to refresh")
+	w = post("/api/wallets/get", url.Values{"label": {"two"}})
+	require.Equal(t, http.StatusUnauthorized, w.Code, "locked wallets need a password")
+	w = post("/api/wallets/get", url.Values{"label": {"two"}, "password": {"wrong"}})
+	require.Equal(t, http.StatusUnauthorized, w.Code, "wrong passwords should fail")
+	w = post("/api/wallets/get", url.Values{"label": {"two"}, "password": {"pw"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = post("/api/wallets/delete", url.Values{"label": {"one"}})
+	require.Equal(t, http.StatusForbidden, w.Code, "deleting needs a confirmation token")
+	w = post("/api/wallets/delete_token", url.Values{"label": {"one"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var token DeleteTokenReply
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &token), "failed to decode token")
+	w = post("/api/wallets/delete", url.Values{"label": {"one"}, "confirm_token": {token.ConfirmToken}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = post("/api/wallets/get", url.Values{"label": {"one"}})
+	require.Equal(t, http.StatusNotFound, w.Code, "deleted wallets should not be found")
+}
@@ -0,0 +1,948 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The query language of GraphQL is implemented here only as far as the schema
+// of the gateway needs it: queries with fields, aliases, arguments, variables,
+// fragments (named and inline) and '__typename'. Mutations, subscriptions,
+// directives and introspection are not supported.
+
+const (
+	gqlMaxDepth     = 16
+	gqlMaxQuerySize = 64 << 10
+)
+
+/*
+	<<< SCHEMA >>>
+*/
+
+// gqlArgs are the coerced arguments of a field: 'Int' arguments are int64,
+// 'String' arguments are string and 'Boolean' arguments are bool. Arguments
+// that are absent (or null) are not set.
+type gqlArgs map[string]interface{}
+
+func (a gqlArgs) int(name string) (int64, bool) {
+	v, ok := a[name].(int64)
+	return v, ok
+}
+
+func (a gqlArgs) string(name string) (string, bool) {
+	v, ok := a[name].(string)
+	return v, ok
+}
+
+func (a gqlArgs) bool(name string) bool {
+	v, _ := a[name].(bool)
+	return v
+}
+
+// gqlField is a field of an object type. The type is in the schema language
+// (as '[Kitty!]!'), and the resolved value is completed by it: scalars are
+// sent as resolved, lists need to be slices, and objects are the source of
+// the fields of their sub-selection.
+type gqlField struct {
+	Type    string
+	Args    map[string]string
+	Resolve func(src interface{}, args gqlArgs) (interface{}, error)
+}
+
+type gqlObject struct {
+	Name   string
+	Fields map[string]*gqlField
+}
+
+type gqlSchema struct {
+	Query *gqlObject
+	Types map[string]*gqlObject
+}
+
+func newGqlSchema(query string, types ...*gqlObject) *gqlSchema {
+	s := &gqlSchema{Types: make(map[string]*gqlObject)}
+	for _, t := range types {
+		s.Types[t.Name] = t
+	}
+	s.Query = s.Types[query]
+	return s
+}
+
+func gqlIsScalar(name string) bool {
+	switch name {
+	case "Int", "Float", "String", "Boolean", "ID":
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+	<<< REQUEST >>>
+*/
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// GraphQLReply is the reply to a query. 'Data' is absent if the query could not be
+// executed, and null if a non-null field of the query resolved to null.
+type GraphQLReply struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// Execute parses and executes the query of the request. The error is for
+// queries that could not be executed, in which case no data is replied.
+func (s *gqlSchema) Execute(req GraphQLRequest) (*GraphQLReply, error) {
+	if len(req.Query) > gqlMaxQuerySize {
+		return nil, fmt.Errorf("query exceeds the maximum size of %d bytes", gqlMaxQuerySize)
+	}
+	doc, e := parseGql(req.Query)
+	if e != nil {
+		return nil, e
+	}
+	op, e := doc.operation(req.OperationName)
+	if e != nil {
+		return nil, e
+	}
+	vars, e := op.coerceVariables(req.Variables)
+	if e != nil {
+		return nil, e
+	}
+	ex := &gqlExecutor{schema: s, doc: doc, vars: vars}
+	reply := &GraphQLReply{Data: json.RawMessage("null")}
+	if data, ok := ex.object(s.Query, nil, op.Selections, nil); ok {
+		reply.Data = data
+	}
+	reply.Errors = ex.errors
+	return reply, nil
+}
+
+/*
+	<<< EXECUTION >>>
+*/
+
+type gqlExecutor struct {
+	schema *gqlSchema
+	doc    *gqlDocument
+	vars   map[string]interface{}
+	errors []GraphQLError
+}
+
+func (ex *gqlExecutor) errorf(path []interface{}, format string, a ...interface{}) {
+	ex.errors = append(ex.errors, GraphQLError{
+		Message: fmt.Sprintf(format, a...),
+		Path:    append([]interface{}(nil), path...),
+	})
+}
+
+// object executes the selections of an object. Not ok is for an error in a
+// non-null field, where the object is null because of it.
+func (ex *gqlExecutor) object(t *gqlObject, src interface{}, sels []gqlSelection, path []interface{}) (*gqlMap, bool) {
+	if gqlDepth(path) > gqlMaxDepth {
+		ex.errorf(path, "query exceeds the maximum depth of %d", gqlMaxDepth)
+		return nil, false
+	}
+	fields, e := ex.collect(t, sels, nil, make(map[string]bool))
+	if e != nil {
+		ex.errorf(path, "%v", e)
+		return nil, false
+	}
+	out := &gqlMap{values: make(map[string]interface{}, len(fields.keys))}
+	for _, key := range fields.keys {
+		var (
+			nodes     = fields.values[key].([]*gqlFieldNode)
+			node      = nodes[0]
+			fieldPath = append(path[:len(path):len(path)], key)
+		)
+		if node.Name == "__typename" {
+			out.set(key, t.Name)
+			continue
+		}
+		field, ok := t.Fields[node.Name]
+		if !ok {
+			ex.errorf(fieldPath, "unknown field '%s' of type '%s'", node.Name, t.Name)
+			return nil, false
+		}
+		var subSels []gqlSelection
+		for _, n := range nodes {
+			subSels = append(subSels, n.Selections...)
+		}
+		v, ok := ex.field(field, src, node, subSels, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		out.set(key, v)
+	}
+	return out, true
+}
+
+func (ex *gqlExecutor) field(field *gqlField, src interface{}, node *gqlFieldNode, sels []gqlSelection, path []interface{}) (interface{}, bool) {
+	nonNull := strings.HasSuffix(field.Type, "!")
+	args, e := ex.coerceArgs(field, node)
+	if e != nil {
+		ex.errorf(path, "%v", e)
+		return nil, !nonNull
+	}
+	v, e := field.Resolve(src, args)
+	if e != nil {
+		ex.errorf(path, "%v", e)
+		return nil, !nonNull
+	}
+	return ex.complete(field.Type, v, sels, path)
+}
+
+// complete completes a resolved value of its type.
+func (ex *gqlExecutor) complete(typ string, v interface{}, sels []gqlSelection, path []interface{}) (interface{}, bool) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if gqlIsNil(v) {
+		if nonNull {
+			ex.errorf(path, "non-null field resolved to null")
+			return nil, false
+		}
+		return nil, true
+	}
+	if strings.HasPrefix(typ, "[") {
+		items := reflect.ValueOf(v)
+		if items.Kind() != reflect.Slice {
+			ex.errorf(path, "list field resolved to '%T'", v)
+			return nil, !nonNull
+		}
+		out := make([]interface{}, items.Len())
+		for i := range out {
+			item, ok := ex.complete(typ[1:len(typ)-1], items.Index(i).Interface(), sels,
+				append(path[:len(path):len(path)], i))
+			if !ok {
+				return nil, !nonNull
+			}
+			out[i] = item
+		}
+		return out, true
+	}
+	if gqlIsScalar(typ) {
+		if len(sels) > 0 {
+			ex.errorf(path, "field of scalar type '%s' can not have a selection", typ)
+			return nil, !nonNull
+		}
+		return v, true
+	}
+	t, ok := ex.schema.Types[typ]
+	if !ok {
+		panic(fmt.Sprintf("graphql: unknown type '%s'", typ))
+	}
+	if len(sels) == 0 {
+		ex.errorf(path, "field of type '%s' needs a selection", typ)
+		return nil, !nonNull
+	}
+	obj, ok := ex.object(t, v, sels, path)
+	if !ok {
+		return nil, !nonNull
+	}
+	return obj, true
+}
+
+// collect collects the fields of the selections (and of fragments that apply to
+// the type), by their response keys.
+func (ex *gqlExecutor) collect(t *gqlObject, sels []gqlSelection, out *gqlMap, visited map[string]bool) (*gqlMap, error) {
+	if out == nil {
+		out = &gqlMap{values: make(map[string]interface{})}
+	}
+	for _, sel := range sels {
+		var frag *gqlFragment
+		switch {
+		case sel.Field != nil:
+			key := sel.Field.Alias
+			if key == "" {
+				key = sel.Field.Name
+			}
+			nodes, _ := out.values[key].([]*gqlFieldNode)
+			if len(nodes) > 0 && nodes[0].Name != sel.Field.Name {
+				return nil, fmt.Errorf("response key '%s' is used by both '%s' and '%s'",
+					key, nodes[0].Name, sel.Field.Name)
+			}
+			out.set(key, append(nodes, sel.Field))
+			continue
+		case sel.Spread != "":
+			if visited[sel.Spread] {
+				continue
+			}
+			visited[sel.Spread] = true
+			var ok bool
+			if frag, ok = ex.doc.Fragments[sel.Spread]; !ok {
+				return nil, fmt.Errorf("unknown fragment '%s'", sel.Spread)
+			}
+		default:
+			frag = sel.Inline
+		}
+		if frag.TypeCond != "" && frag.TypeCond != t.Name {
+			continue
+		}
+		if _, e := ex.collect(t, frag.Selections, out, visited); e != nil {
+			return nil, e
+		}
+	}
+	return out, nil
+}
+
+func (ex *gqlExecutor) coerceArgs(field *gqlField, node *gqlFieldNode) (gqlArgs, error) {
+	args := make(gqlArgs, len(field.Args))
+	for name := range node.Args {
+		if _, ok := field.Args[name]; !ok {
+			return nil, fmt.Errorf("unknown argument '%s' of field '%s'", name, node.Name)
+		}
+	}
+	for name, typ := range field.Args {
+		raw, ok := node.Args[name]
+		if v, isVar := raw.(gqlVariable); isVar {
+			raw, ok = ex.vars[string(v)]
+		}
+		v, e := gqlCoerce(typ, raw, ok)
+		if e != nil {
+			return nil, fmt.Errorf("argument '%s': %v", name, e)
+		}
+		if v != nil {
+			args[name] = v
+		}
+	}
+	return args, nil
+}
+
+// gqlCoerce coerces a value (from the query or from the variables) to a scalar
+// type.
+func gqlCoerce(typ string, v interface{}, ok bool) (interface{}, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if !ok || v == nil {
+		if nonNull {
+			return nil, fmt.Errorf("value of type '%s!' is required", typ)
+		}
+		return nil, nil
+	}
+	switch typ {
+	case "Int":
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case json.Number:
+			if i, e := n.Int64(); e == nil {
+				return i, nil
+			}
+		case float64:
+			if n == float64(int64(n)) {
+				return int64(n), nil
+			}
+		}
+	case "String", "ID":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported input type '%s'", typ)
+	}
+	return nil, fmt.Errorf("invalid value '%v' of type '%s'", v, typ)
+}
+
+func gqlIsNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// gqlDepth is the number of fields in the path, as list indices are not.
+func gqlDepth(path []interface{}) int {
+	n := 0
+	for _, p := range path {
+		if _, ok := p.(string); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// gqlMap is a JSON object that keeps the order of its keys, as replies are in
+// the order of the query.
+type gqlMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *gqlMap) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+func (m *gqlMap) MarshalJSON() ([]byte, error) {
+	out := []byte{'{'}
+	for i, key := range m.keys {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		k, _ := json.Marshal(key)
+		v, e := json.Marshal(m.values[key])
+		if e != nil {
+			return nil, e
+		}
+		out = append(append(append(out, k...), ':'), v...)
+	}
+	return append(out, '}'), nil
+}
+
+/*
+	<<< DOCUMENT >>>
+*/
+
+type gqlDocument struct {
+	Operations []*gqlOperation
+	Fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	Name       string
+	Variables  []gqlVariableDef
+	Selections []gqlSelection
+}
+
+type gqlVariableDef struct {
+	Name       string
+	Type       string
+	Default    interface{}
+	HasDefault bool
+}
+
+// gqlSelection is either a field, a fragment spread or an inline fragment.
+type gqlSelection struct {
+	Field  *gqlFieldNode
+	Spread string
+	Inline *gqlFragment
+}
+
+type gqlFieldNode struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Selections []gqlSelection
+}
+
+type gqlFragment struct {
+	TypeCond   string
+	Selections []gqlSelection
+}
+
+// gqlVariable is a reference to a variable, as a value of the query.
+type gqlVariable string
+
+func (d *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, errors.New("operation name is required for documents with multiple operations")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation '%s'", name)
+}
+
+func (op *gqlOperation) coerceVariables(values map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(op.Variables))
+	for _, def := range op.Variables {
+		v, ok := values[def.Name]
+		if !ok && def.HasDefault {
+			v, ok = def.Default, true
+		}
+		v, e := gqlCoerce(def.Type, v, ok)
+		if e != nil {
+			return nil, fmt.Errorf("variable '$%s': %v", def.Name, e)
+		}
+		if v != nil {
+			out[def.Name] = v
+		}
+	}
+	return out, nil
+}
+
+/*
+	<<< PARSER >>>
+*/
+
+const (
+	gqlTokenEOF = iota
+	gqlTokenPunct
+	gqlTokenName
+	gqlTokenInt
+	gqlTokenFloat
+	gqlTokenString
+)
+
+type gqlToken struct {
+	Kind  int
+	Value string
+	Pos   int
+}
+
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+}
+
+func parseGql(src string) (*gqlDocument, error) {
+	p := &gqlParser{src: src}
+	doc := &gqlDocument{Fragments: make(map[string]*gqlFragment)}
+	if e := p.next(); e != nil {
+		return nil, e
+	}
+	for p.tok.Kind != gqlTokenEOF {
+		switch {
+		case p.peek(gqlTokenPunct, "{"):
+			sels, e := p.selectionSet()
+			if e != nil {
+				return nil, e
+			}
+			doc.Operations = append(doc.Operations, &gqlOperation{Selections: sels})
+		case p.peek(gqlTokenName, "query"):
+			op, e := p.operation()
+			if e != nil {
+				return nil, e
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peek(gqlTokenName, "fragment"):
+			name, frag, e := p.fragment()
+			if e != nil {
+				return nil, e
+			}
+			if _, ok := doc.Fragments[name]; ok {
+				return nil, fmt.Errorf("duplicate fragment '%s'", name)
+			}
+			doc.Fragments[name] = frag
+		case p.peek(gqlTokenName, "mutation"), p.peek(gqlTokenName, "subscription"):
+			return nil, fmt.Errorf("unsupported operation type '%s'", p.tok.Value)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, errors.New("document has no operations")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	if e := p.next(); e != nil {
+		return nil, e
+	}
+	op := new(gqlOperation)
+	if p.tok.Kind == gqlTokenName {
+		op.Name = p.tok.Value
+		if e := p.next(); e != nil {
+			return nil, e
+		}
+	}
+	if p.peek(gqlTokenPunct, "(") {
+		if e := p.next(); e != nil {
+			return nil, e
+		}
+		for !p.peek(gqlTokenPunct, ")") {
+			def, e := p.variableDef()
+			if e != nil {
+				return nil, e
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		if e := p.next(); e != nil {
+			return nil, e
+		}
+	}
+	sels, e := p.selectionSet()
+	op.Selections = sels
+	return op, e
+}
+
+func (p *gqlParser) variableDef() (def gqlVariableDef, e error) {
+	if e = p.expect(gqlTokenPunct, "$"); e != nil {
+		return
+	}
+	if def.Name, e = p.name(); e != nil {
+		return
+	}
+	if e = p.expect(gqlTokenPunct, ":"); e != nil {
+		return
+	}
+	if def.Type, e = p.typeRef(); e != nil {
+		return
+	}
+	if p.peek(gqlTokenPunct, "=") {
+		if e = p.next(); e != nil {
+			return
+		}
+		def.HasDefault = true
+		def.Default, e = p.value(true)
+	}
+	return
+}
+
+func (p *gqlParser) typeRef() (string, error) {
+	var typ string
+	if p.peek(gqlTokenPunct, "[") {
+		if e := p.next(); e != nil {
+			return "", e
+		}
+		inner, e := p.typeRef()
+		if e != nil {
+			return "", e
+		}
+		if e := p.expect(gqlTokenPunct, "]"); e != nil {
+			return "", e
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, e := p.name()
+		if e != nil {
+			return "", e
+		}
+		typ = name
+	}
+	if p.peek(gqlTokenPunct, "!") {
+		if e := p.next(); e != nil {
+			return "", e
+		}
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *gqlParser) fragment() (string, *gqlFragment, error) {
+	if e := p.next(); e != nil {
+		return "", nil, e
+	}
+	name, e := p.name()
+	if e != nil {
+		return "", nil, e
+	}
+	if name == "on" {
+		return "", nil, errors.New("fragments can not be named 'on'")
+	}
+	if e := p.expect(gqlTokenName, "on"); e != nil {
+		return "", nil, e
+	}
+	frag := new(gqlFragment)
+	if frag.TypeCond, e = p.name(); e != nil {
+		return "", nil, e
+	}
+	frag.Selections, e = p.selectionSet()
+	return name, frag, e
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if e := p.expect(gqlTokenPunct, "{"); e != nil {
+		return nil, e
+	}
+	var sels []gqlSelection
+	for !p.peek(gqlTokenPunct, "}") {
+		sel, e := p.selection()
+		if e != nil {
+			return nil, e
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, fmt.Errorf("empty selection at %d", p.tok.Pos)
+	}
+	return sels, p.next()
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	if p.peek(gqlTokenPunct, "...") {
+		if e := p.next(); e != nil {
+			return gqlSelection{}, e
+		}
+		frag := new(gqlFragment)
+		switch {
+		case p.peek(gqlTokenName, "on"):
+			if e := p.next(); e != nil {
+				return gqlSelection{}, e
+			}
+			name, e := p.name()
+			if e != nil {
+				return gqlSelection{}, e
+			}
+			frag.TypeCond = name
+		case p.tok.Kind == gqlTokenName:
+			name, e := p.name()
+			return gqlSelection{Spread: name}, e
+		}
+		sels, e := p.selectionSet()
+		frag.Selections = sels
+		return gqlSelection{Inline: frag}, e
+	}
+	if p.peek(gqlTokenPunct, "@") {
+		return gqlSelection{}, errors.New("directives are not supported")
+	}
+
+	field := new(gqlFieldNode)
+	name, e := p.name()
+	if e != nil {
+		return gqlSelection{}, e
+	}
+	if p.peek(gqlTokenPunct, ":") {
+		if e := p.next(); e != nil {
+			return gqlSelection{}, e
+		}
+		field.Alias = name
+		if name, e = p.name(); e != nil {
+			return gqlSelection{}, e
+		}
+	}
+	field.Name = name
+	if p.peek(gqlTokenPunct, "(") {
+		if e := p.next(); e != nil {
+			return gqlSelection{}, e
+		}
+		field.Args = make(map[string]interface{})
+		for !p.peek(gqlTokenPunct, ")") {
+			arg, e := p.name()
+			if e != nil {
+				return gqlSelection{}, e
+			}
+			if e := p.expect(gqlTokenPunct, ":"); e != nil {
+				return gqlSelection{}, e
+			}
+			if field.Args[arg], e = p.value(false); e != nil {
+				return gqlSelection{}, e
+			}
+		}
+		if e := p.next(); e != nil {
+			return gqlSelection{}, e
+		}
+	}
+	if p.peek(gqlTokenPunct, "@") {
+		return gqlSelection{}, errors.New("directives are not supported")
+	}
+	if p.peek(gqlTokenPunct, "{") {
+		if field.Selections, e = p.selectionSet(); e != nil {
+			return gqlSelection{}, e
+		}
+	}
+	return gqlSelection{Field: field}, nil
+}
+
+// value parses a value. Constant values (for variable defaults) can not be
+// variables.
+func (p *gqlParser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case tok.Kind == gqlTokenPunct && tok.Value == "$" && !constant:
+		if e := p.next(); e != nil {
+			return nil, e
+		}
+		name, e := p.name()
+		return gqlVariable(name), e
+	case tok.Kind == gqlTokenPunct && tok.Value == "[":
+		if e := p.next(); e != nil {
+			return nil, e
+		}
+		list := []interface{}{}
+		for !p.peek(gqlTokenPunct, "]") {
+			v, e := p.value(constant)
+			if e != nil {
+				return nil, e
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case tok.Kind == gqlTokenInt:
+		n, e := strconv.ParseInt(tok.Value, 10, 64)
+		if e != nil {
+			return nil, fmt.Errorf("invalid int '%s' at %d", tok.Value, tok.Pos)
+		}
+		return n, p.next()
+	case tok.Kind == gqlTokenFloat:
+		f, e := strconv.ParseFloat(tok.Value, 64)
+		if e != nil {
+			return nil, fmt.Errorf("invalid float '%s' at %d", tok.Value, tok.Pos)
+		}
+		return f, p.next()
+	case tok.Kind == gqlTokenString:
+		return tok.Value, p.next()
+	case tok.Kind == gqlTokenName && (tok.Value == "true" || tok.Value == "false"):
+		return tok.Value == "true", p.next()
+	case tok.Kind == gqlTokenName && tok.Value == "null":
+		return nil, p.next()
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+func (p *gqlParser) peek(kind int, value string) bool {
+	return p.tok.Kind == kind && p.tok.Value == value
+}
+
+func (p *gqlParser) expect(kind int, value string) error {
+	if !p.peek(kind, value) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.Kind != gqlTokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.Value
+	return name, p.next()
+}
+
+func (p *gqlParser) unexpected() error {
+	if p.tok.Kind == gqlTokenEOF {
+		return errors.New("syntax error: unexpected end of document")
+	}
+	return fmt.Errorf("syntax error: unexpected '%s' at %d", p.tok.Value, p.tok.Pos)
+}
+
+// next lexes the next token, skipping whitespace, commas and comments.
+func (p *gqlParser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{Kind: gqlTokenEOF, Pos: start}
+		return nil
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{Kind: gqlTokenPunct, Value: "...", Pos: start}
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{Kind: gqlTokenPunct, Value: string(c), Pos: start}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && gqlIsNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = gqlToken{Kind: gqlTokenName, Value: p.src[start:p.pos], Pos: start}
+	case c == '-' || c >= '0' && c <= '9':
+		kind := gqlTokenInt
+		p.pos++
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-' {
+				kind = gqlTokenFloat
+			} else if c < '0' || c > '9' {
+				break
+			}
+			p.pos++
+		}
+		p.tok = gqlToken{Kind: kind, Value: p.src[start:p.pos], Pos: start}
+	case c == '"':
+		s, e := p.string()
+		if e != nil {
+			return e
+		}
+		p.tok = gqlToken{Kind: gqlTokenString, Value: s, Pos: start}
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return fmt.Errorf("syntax error: unexpected character '%c' at %d", r, start)
+	}
+	return nil
+}
+
+func (p *gqlParser) string() (string, error) {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			return "", fmt.Errorf("syntax error: unterminated string at %d", start)
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return s, nil
+	}
+	var out strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return out.String(), nil
+		case c == '\n':
+			return "", fmt.Errorf("syntax error: unterminated string at %d", start)
+		case c != '\\':
+			out.WriteByte(c)
+		case p.pos+1 >= len(p.src):
+			return "", fmt.Errorf("syntax error: unterminated string at %d", start)
+		default:
+			p.pos++
+			switch p.src[p.pos] {
+			case '"', '\\', '/':
+				out.WriteByte(p.src[p.pos])
+			case 'b':
+				out.WriteByte('\b')
+			case 'f':
+				out.WriteByte('\f')
+			case 'n':
+				out.WriteByte('\n')
+			case 'r':
+				out.WriteByte('\r')
+			case 't':
+				out.WriteByte('\t')
+			case 'u':
+				if p.pos+5 > len(p.src) {
+					return "", fmt.Errorf("syntax error: invalid escape at %d", p.pos)
+				}
+				r, e := strconv.ParseUint(p.src[p.pos+1:p.pos+5], 16, 16)
+				if e != nil {
+					return "", fmt.Errorf("syntax error: invalid escape at %d", p.pos)
+				}
+				out.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				return "", fmt.Errorf("syntax error: invalid escape at %d", p.pos)
+			}
+		}
+	}
+	return "", fmt.Errorf("syntax error: unterminated string at %d", start)
+}
+
+func gqlIsNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	var (
+		creator = cipher.AddressFromSecKey(sk)
+		to      = cipher.AddressFromSecKey(cipher.SecKey([32]byte{1}))
+	)
+	gen := iko.NewGenTx(nil, 1, sk)
+	require.Nil(t, bc.InjectTx(gen), "failed to inject gen tx")
+	transfer := iko.NewTransferTx(gen, 1, to, bc.NextNonce(creator), sk)
+	transfer.SetMemo("gift", sk)
+	require.Nil(t, bc.InjectTx(transfer), "failed to inject transfer")
+
+	mux := http.NewServeMux()
+	require.Nil(t, graphqlGateway(mux, bc, nil))
+
+	query := func(req GraphQLRequest) (int, string) {
+		body, e := json.Marshal(req)
+		require.Nil(t, e)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/graphql", bytes.NewReader(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := query(GraphQLRequest{
+		Query: `query Kitty($id: Int!) {
+			kitty(id: $id) {
+				id
+				owner { address kittyCount }
+				history { ...transition }
+			}
+			unknown: kitty(id: 9) { id }
+		}
+		fragment transition on KittyTransition {
+			from { address }
+			owner { address }
+			memo
+			tx { seq __typename }
+		}`,
+		Variables: map[string]interface{}{"id": 1},
+	})
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"data": {
+		"kitty": {
+			"id": 1,
+			"owner": {"address": "`+to.String()+`", "kittyCount": 1},
+			"history": [
+				{"from": null, "owner": {"address": "`+creator.String()+`"}, "memo": "",
+					"tx": {"seq": 0, "__typename": "Transaction"}},
+				{"from": {"address": "`+creator.String()+`"}, "owner": {"address": "`+to.String()+`"}, "memo": "gift",
+					"tx": {"seq": 1, "__typename": "Transaction"}}
+			]
+		},
+		"unknown": null
+	}}`, body, "nested selections should be resolved from the chain")
+
+	code, body = query(GraphQLRequest{Query: `{ txs(desc: true, pageSize: 1) { hash } a: head { seq } b: head { seq } }`})
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"data": {"txs": [{"hash": "`+transfer.Hash().Hex()+`"}], "a": {"seq": 1}, "b": {"seq": 1}}}`, body,
+		"aliases should be under their own keys")
+
+	code, body = query(GraphQLRequest{Query: `{ head { seq } address(address: "invalid") { address } wallets { label } }`})
+	require.Equal(t, http.StatusOK, code, body)
+	var reply struct {
+		Data   map[string]interface{}
+		Errors []GraphQLError
+	}
+	require.Nil(t, json.Unmarshal([]byte(body), &reply))
+	require.Nil(t, reply.Data, "errors in non-null fields should null the parent")
+	require.Len(t, reply.Errors, 2)
+	require.Equal(t, []interface{}{"address"}, reply.Errors[0].Path, "errors should have their path")
+
+	for _, q := range []string{
+		`{ kitty(id: 1) { name } }`,
+		`{ kitty(id: 1) }`,
+		`{ kitty { id } }`,
+		`{ head { ...missing } }`,
+	} {
+		code, body = query(GraphQLRequest{Query: q})
+		require.Equal(t, http.StatusOK, code, body)
+		require.Contains(t, body, `"errors"`, "invalid fields in '%s' should be errors", q)
+	}
+
+	for _, q := range []string{`{ kitty(id: 1) { id }`, `mutation { kitty(id: 1) { id } }`} {
+		code, body = query(GraphQLRequest{Query: q})
+		require.Equal(t, http.StatusBadRequest, code, body)
+		require.NotContains(t, body, `"data"`, "rejected queries should not have data")
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/graphql?query="+
+		url.QueryEscape(`query($seq: Int) { tx(seq: $seq) { memo } }`)+"&variables="+url.QueryEscape(`{"seq": 1}`), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"data": {"tx": {"memo": "gift"}}}`, rec.Body.String(), "queries should be served over 'GET'")
+}
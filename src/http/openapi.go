@@ -0,0 +1,810 @@
+package http
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/kittycash/wallet/src/wallet"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The OpenAPI 3 document of the gateway is generated from the routes that are
+// handled (see 'Handle'), with the documentation of 'routeDocs'. Schemas are
+// generated from the Go types of the requests and replies, so the document
+// follows the actual handlers.
+
+const (
+	OpenAPIVersion = "3.0.3"
+)
+
+// RouteParam is a parameter of a route, in the query ("query"), in the path
+// ("path"), in the form of the body ("form") or a file in a multipart form
+// ("file").
+type RouteParam struct {
+	Name     string
+	In       string
+	Type     string // JSON schema type, "string" if empty.
+	Required bool
+}
+
+func queryParam(name, typ string) RouteParam { return RouteParam{Name: name, In: "query", Type: typ} }
+func pathParam(name, typ string) RouteParam {
+	return RouteParam{Name: name, In: "path", Type: typ, Required: true}
+}
+func formParam(name, typ string) RouteParam { return RouteParam{Name: name, In: "form", Type: typ} }
+func fileParam(name string) RouteParam      { return RouteParam{Name: name, In: "file", Required: true} }
+
+func (p RouteParam) required() RouteParam {
+	p.Required = true
+	return p
+}
+
+// RouteDoc documents a route. 'Body' and 'Reply' are values of the types of
+// the JSON body and of the reply, from which the schemas are generated. Routes
+// with URL extensions (as '.csv') reply with the content type of the extension.
+type RouteDoc struct {
+	Path    string // OpenAPI path for subtree patterns, as '/api/kitty/{kitty_id}'.
+	Summary string
+	Params  []RouteParam
+	Body    interface{}
+	Reply   interface{}
+	Content string // Content type for replies that are not JSON.
+	Status  int    // Status on success, 200 if 0.
+}
+
+// NewOpenAPI generates the OpenAPI document for the routes of a version of the
+// API, with their versioned paths. Routes without a 'RouteDoc' are documented by
+// their method and path only.
+func NewOpenAPI(api *APIMux, version int) map[string]interface{} {
+	var (
+		schemas = newSchemaSet()
+		paths   = make(map[string]map[string]interface{})
+	)
+	for _, route := range api.RoutesOf(version) {
+		doc, _ := routeDocOf(route)
+		p := doc.Path
+		if p == "" {
+			p = route.Pattern
+		}
+		p = VersionPath(version, p)
+		if paths[p] == nil {
+			paths[p] = make(map[string]interface{})
+		}
+		paths[p][strings.ToLower(route.Method)] = newOperation(route, doc, schemas)
+	}
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "Kittycash Wallet API",
+			"version": fmt.Sprintf("v%d", version),
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey":  map[string]interface{}{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+				"session": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func getOpenAPI(api *APIMux, version int) HandlerFunc {
+	var (
+		once sync.Once
+		spec map[string]interface{}
+	)
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		// Routes are all handled by the time of the first request.
+		once.Do(func() { spec = NewOpenAPI(api, version) })
+		return sendJson(w, http.StatusOK, spec)
+	}
+}
+
+// routeDocOf obtains the documentation of a route, where routes with URL
+// extensions fall back to the documentation of the route without it.
+func routeDocOf(route Route) (RouteDoc, bool) {
+	if doc, ok := routeDocs[route.Method+" "+route.Pattern]; ok {
+		return doc, true
+	}
+	ext := path.Ext(route.Pattern)
+	doc, ok := routeDocs[route.Method+" "+strings.TrimSuffix(route.Pattern, ext)]
+	if ok && ext != "" {
+		doc.Path = ""
+		switch ext {
+		case ".enc", ".bin":
+			doc.Content = "application/octet-stream"
+		case ".csv":
+			doc.Content = "text/csv"
+		case ".html":
+			doc.Content = "text/html"
+		}
+	}
+	return doc, ok
+}
+
+var operationIDChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+func newOperation(route Route, doc RouteDoc, schemas *schemaSet) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": strings.ToLower(route.Method) +
+			strings.TrimRight(operationIDChars.ReplaceAllString(route.Pattern, "_"), "_"),
+		"tags": []string{strings.SplitN(strings.TrimPrefix(route.Pattern, "/api/"), "/", 2)[0]},
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	switch apiKeyScopeOf(route.Method, route.Pattern) {
+	case apiKeyPublic:
+	case apiKeyWallet:
+		// For nodes with sessions or API keys (see 'Gateway').
+		op["security"] = []interface{}{
+			map[string]interface{}{"session": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		}
+	default:
+		op["security"] = []interface{}{map[string]interface{}{"apiKey": []string{}}}
+	}
+
+	var (
+		params    []interface{}
+		formProps = make(map[string]interface{})
+		formReq   []string
+		multipart bool
+	)
+	for _, p := range doc.Params {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		schema := map[string]interface{}{"type": typ}
+		switch p.In {
+		case "form", "file":
+			if p.In == "file" {
+				schema["format"], multipart = "binary", true
+			}
+			formProps[p.Name] = schema
+			if p.Required {
+				formReq = append(formReq, p.Name)
+			}
+		default:
+			params = append(params, map[string]interface{}{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.Required,
+				"schema":   schema,
+			})
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	switch {
+	case doc.Body != nil:
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(doc.Body))},
+			},
+		}
+	case len(formProps) > 0:
+		contentType := "application/x-www-form-urlencoded"
+		if multipart {
+			contentType = "multipart/form-data"
+		}
+		schema := map[string]interface{}{"type": "object", "properties": formProps}
+		if len(formReq) > 0 {
+			schema["required"] = formReq
+		}
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{contentType: map[string]interface{}{"schema": schema}},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case doc.Content != "":
+		success["content"] = map[string]interface{}{doc.Content: map[string]interface{}{}}
+	case doc.Reply != nil:
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(doc.Reply))},
+		}
+	case status == http.StatusOK:
+		success["content"] = map[string]interface{}{"application/json": map[string]interface{}{}}
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(status): success,
+		"default": map[string]interface{}{
+			"description": "Error, with its message.",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		},
+	}
+	return op
+}
+
+/*
+	<<< SCHEMAS >>>
+*/
+
+// schemaOverrides are the schemas for types with their own JSON encoding.
+var schemaOverrides = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(iko.Transaction{}): {
+		"type":        "object",
+		"description": "Transaction in its canonical JSON encoding, with decimal strings for 64-bit integers.",
+	},
+	reflect.TypeOf(time.Time{}):             {"type": "string", "format": "date-time"},
+	reflect.TypeOf(json.RawMessage{}):       {},
+	reflect.TypeOf(new(interface{})).Elem(): {},
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf(new(json.Marshaler)).Elem()
+	textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+)
+
+// schemaSet generates schemas, where named structs are components.
+type schemaSet struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{
+		components: make(map[string]interface{}),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+func (s *schemaSet) of(t reflect.Type) map[string]interface{} {
+	if schema, ok := schemaOverrides[t]; ok {
+		return schema
+	}
+	if t.Kind() == reflect.Ptr {
+		return s.of(t.Elem())
+	}
+	switch {
+	case t.Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	case t.Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = s.componentName(t)
+			s.names[t] = name
+			s.components[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// componentName obtains the name of the component of a struct, which is
+// qualified by its package if the name is taken by another struct.
+func (s *schemaSet) componentName(t reflect.Type) string {
+	name := t.Name()
+	for other, otherName := range s.names {
+		if otherName == name && other != t {
+			return path.Base(t.PkgPath()) + "." + name
+		}
+	}
+	return name
+}
+
+func (s *schemaSet) object(t reflect.Type) map[string]interface{} {
+	var (
+		props    = make(map[string]interface{})
+		required []string
+	)
+	s.fields(t, props, &required)
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields collects the properties of the fields of a struct, by the rules of
+// 'encoding/json'. Embedded structs without a name are inlined.
+func (s *schemaSet) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(ft, props, required)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.of(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+/*
+	<<< ROUTES >>>
+*/
+
+var routeDocs = map[string]RouteDoc{
+	// Chain.
+	"GET /api/iko/kitty/": {
+		Path:    "/api/iko/kitty/{kitty_id}",
+		Summary: "Get the state of a kitty (as '.json' or '.enc').",
+		Params:  []RouteParam{pathParam("kitty_id", "integer")},
+		Reply:   KittyReply{},
+	},
+	"GET /api/iko/address/": {
+		Path:    "/api/iko/address/{address}",
+		Summary: "Get the state of an address (as '.json' or '.enc').",
+		Params:  []RouteParam{pathParam("address", "string")},
+		Reply:   AddressReply{},
+	},
+	"GET /api/iko/address_count/": {
+		Path:    "/api/iko/address_count/{address}",
+		Summary: "Count the kitties of an address.",
+		Params:  []RouteParam{pathParam("address", "string")},
+		Reply:   AddressCountReply{},
+	},
+	"GET /api/iko/tx/": {
+		Path:    "/api/iko/tx/{tx}",
+		Summary: "Get a transaction by its hash, or by its seq (with 'request=seq').",
+		Params:  []RouteParam{pathParam("tx", "string"), queryParam("request", "string")},
+		Reply:   TxReply{},
+	},
+	"GET /api/iko/head_tx": {
+		Summary: "Get the head transaction.",
+		Reply:   TxReply{},
+	},
+	"GET /api/kitty/": {
+		Path:    "/api/kitty/{kitty_id}",
+		Summary: "Get the owner of a kitty.",
+		Params:  []RouteParam{pathParam("kitty_id", "integer")},
+		Reply:   KittyOwnerReply{},
+	},
+	"GET /api/address/": {
+		Path:    "/api/address/{address}/kitties",
+		Summary: "List the kitties of an address.",
+		Params:  []RouteParam{pathParam("address", "string"), queryParam("page", "integer"), queryParam("per_page", "integer")},
+		Reply:   AddressKittiesReply{},
+	},
+	"GET /api/ws/txs": {
+		Summary: "Stream accepted transactions over a WebSocket, filtered by 'TxStreamFilter' messages.",
+		Status:  http.StatusSwitchingProtocols,
+	},
+	"GET /api/sse/txs": {
+		Summary: "Stream accepted transactions as server-sent events.",
+		Params:  []RouteParam{queryParam("last_event_id", "integer")},
+		Content: "text/event-stream",
+	},
+	"GET /api/txs": {
+		Summary: "List a range of transactions.",
+		Params:  []RouteParam{queryParam("start_seq", "integer"), queryParam("page_size", "integer"), queryParam("dir", "string")},
+		Reply:   TxRangeReply{},
+	},
+	"GET /api/tx/hash/": {
+		Path:    "/api/tx/hash/{hash}",
+		Summary: "Look up a transaction by its hash, in the chain or the mempool.",
+		Params:  []RouteParam{pathParam("hash", "string")},
+		Reply:   TxLookupReply{},
+	},
+	"GET /api/tx/seq/": {
+		Path:    "/api/tx/seq/{seq}",
+		Summary: "Look up a transaction by its seq.",
+		Params:  []RouteParam{pathParam("seq", "integer")},
+		Reply:   TxLookupReply{},
+	},
+	"POST /api/jsonrpc": {
+		Summary: "Call a JSON-RPC 2.0 method (or a batch of calls).",
+		Body:    JSONRPCRequest{},
+		Reply:   JSONRPCResponse{},
+	},
+	"GET /api/graphql": {
+		Summary: "Execute a GraphQL query (with 'query', 'operationName' and 'variables').",
+		Params:  []RouteParam{queryParam("query", "string").required(), queryParam("operationName", "string"), queryParam("variables", "string")},
+		Reply:   GraphQLReply{},
+	},
+	"POST /api/graphql": {
+		Summary: "Execute a GraphQL query.",
+		Body:    GraphQLRequest{},
+		Reply:   GraphQLReply{},
+	},
+	"GET /api/iko/txs": {
+		Summary: "List a page of transactions (as '.json' or '.enc').",
+		Params:  []RouteParam{queryParam("per_page", "integer").required(), queryParam("current_page", "integer").required()},
+		Reply:   PaginatedTxsReply{},
+	},
+	"GET /api/iko/addresses": {
+		Summary: "List a page of the addresses of kitties.",
+		Params:  []RouteParam{queryParam("per_page", "integer").required(), queryParam("current_page", "integer").required()},
+		Reply:   PaginatedAddressesReply{},
+	},
+	"GET /api/iko/kitties/": {
+		Path:    "/api/iko/kitties/{status}",
+		Summary: "List a page of the kitties with a status.",
+		Params: []RouteParam{pathParam("status", "string"),
+			queryParam("per_page", "integer").required(), queryParam("current_page", "integer").required()},
+		Reply: PaginatedKittiesReply{},
+	},
+	"POST /api/iko/admin/reserve": {
+		Summary: "Reserve a kitty.",
+		Params:  []RouteParam{queryParam("kitty_id", "integer").required()},
+		Reply:   true,
+	},
+	"POST /api/iko/admin/unreserve": {
+		Summary: "Unreserve a kitty.",
+		Params:  []RouteParam{queryParam("kitty_id", "integer").required()},
+		Reply:   true,
+	},
+	"GET /api/iko/metrics": {
+		Summary: "Get the metrics of the chain, in the Prometheus text format.",
+		Params:  []RouteParam{queryParam("top", "integer")},
+		Content: "text/plain",
+	},
+	"GET /api/iko/admin/verify_state": {
+		Summary: "Verify the state of the chain, by a replay of the transactions.",
+		Reply:   VerifyStateReply{},
+	},
+	"GET /api/iko/admin/export": {
+		Summary: "Export the state of the chain (as '.json' or '.csv').",
+	},
+	"POST /api/iko/admin/rollback": {
+		Summary: "Roll back the chain to a seq.",
+		Params:  []RouteParam{queryParam("seq", "integer").required()},
+		Reply:   true,
+	},
+	"POST /api/iko/inject_kitty_meta": {
+		Summary: "Inject the signed metadata of a kitty.",
+		Body:    iko.FloatingKittyMeta{},
+		Reply:   true,
+	},
+	"GET /api/iko/unsigned_transfer": {
+		Summary: "Build an unsigned transfer, with the hash to sign.",
+		Params: []RouteParam{queryParam("kitty_ids", "string").required(), queryParam("from", "string").required(),
+			queryParam("to", "string").required(), queryParam("delegation", "string"), queryParam("scheme", "string")},
+		Reply: UnsignedTransferReply{},
+	},
+	"POST /api/iko/simulate_tx": {
+		Summary: "Simulate a transaction, without injecting it.",
+		Body:    InjectTxRequest{},
+		Reply:   SimulateTxReply{},
+	},
+	"POST /api/iko/explain_tx": {
+		Summary: "Explain the effects of a transaction.",
+		Body:    InjectTxRequest{},
+		Reply:   iko.TxExplanation{},
+	},
+	"POST /api/iko/inject_tx": {
+		Summary: "Inject a transaction (from JSON, binary or protobuf bodies), replying 'true' or the already accepted transaction.",
+		Body:    InjectTxRequest{},
+		Reply:   AcceptedTxReply{},
+	},
+	"POST /api/iko/inject_tx_group": {
+		Summary: "Inject an atomic group of transactions.",
+		Body:    InjectTxGroupRequest{},
+		Reply:   true,
+	},
+	"POST /api/iko/submit_tx": {
+		Summary: "Submit a transaction to the mempool.",
+		Body:    InjectTxRequest{},
+		Reply:   SubmitTxReply{},
+	},
+	"GET /api/iko/pending_txs": {
+		Summary: "List the transactions in the mempool.",
+		Reply:   []PendingTxReply{},
+	},
+	"GET /api/spec.json": {
+		Summary: "Get the OpenAPI document of the API.",
+	},
+	"GET /api/csrf_token": {
+		Summary: "Obtain the CSRF token for state-changing requests from browsers, with its cookie.",
+		Reply:   CSRFTokenReply{},
+	},
+	"POST /api/auth/login": {
+		Summary: "Log in with the password of the node, for the tokens of a session for wallet routes.",
+		Params:  []RouteParam{formParam("password", "string").required()},
+		Reply:   SessionReply{},
+	},
+	"POST /api/auth/refresh": {
+		Summary: "Obtain new tokens for a session with its refresh token, which is only used once.",
+		Params:  []RouteParam{formParam("refresh_token", "string").required()},
+		Reply:   SessionReply{},
+	},
+	"POST /api/auth/logout": {
+		Summary: "End a session, by its refresh token (or by 'Authorization: Bearer <access_token>').",
+		Params:  []RouteParam{formParam("refresh_token", "string")},
+		Reply:   true,
+	},
+	"GET /api/admin/api_keys/list": {
+		Summary: "List the API keys.",
+		Reply:   APIKeysReply{},
+	},
+	"POST /api/admin/api_keys/new": {
+		Summary: "Create an API key, which is only shown in the reply.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("admin", "boolean")},
+		Reply:   CreatedAPIKeyReply{},
+	},
+	"POST /api/admin/api_keys/revoke": {
+		Summary: "Revoke an API key.",
+		Params:  []RouteParam{formParam("id", "string").required()},
+		Reply:   true,
+	},
+
+	// Wallets.
+	"GET /api/wallets/refresh": {
+		Summary: "Reload the wallets from the wallet directory.",
+		Reply:   true,
+	},
+	"GET /api/wallets/list": {
+		Summary: "List the wallets.",
+		Params: []RouteParam{queryParam("filter", "string"), queryParam("sort", "string"), queryParam("order", "string"),
+			queryParam("page", "integer"), queryParam("per_page", "integer")},
+		Reply: WalletsReply{},
+	},
+	"POST /api/wallets/verify": {
+		Summary: "Verify the wallet files, quarantining bad files.",
+		Reply:   VerifyReply{},
+	},
+	"POST /api/wallets/new": {
+		Summary: "Create a wallet.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("name", "string"), formParam("seed", "string"),
+			formParam("seed_phrase", "boolean"), formParam("encrypted", "boolean"), formParam("password", "string"),
+			formParam("keystore", "string"), formParam("watch_only", "boolean"), formParam("watch_addresses", "string"),
+			formParam("addresses", "integer"), formParam("hold_until", "string")},
+		Reply: wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/get": {
+		Summary: "Get a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("password", "string")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/unlock": {
+		Summary: "Unlock an encrypted wallet for signing.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("password", "string").required(), formParam("ttl", "string")},
+		Reply:   true,
+	},
+	"POST /api/wallets/lock": {
+		Summary: "Lock a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required()},
+		Reply:   true,
+	},
+	"POST /api/wallets/change_password": {
+		Summary: "Change the password of a wallet.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("old_password", "string"),
+			formParam("new_password", "string")},
+		Reply: true,
+	},
+	"POST /api/wallets/delete_token": {
+		Summary: "Obtain the token for confirming the deletion of a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required()},
+		Reply:   DeleteTokenReply{},
+	},
+	"POST /api/wallets/delete": {
+		Summary: "Delete a wallet into the trash.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("confirm_token", "string").required()},
+		Reply:   true,
+	},
+	"GET /api/wallets/trash/list": {
+		Summary: "List the deleted wallets in the trash.",
+		Reply:   TrashReply{},
+	},
+	"POST /api/wallets/trash/recover": {
+		Summary: "Recover a deleted wallet from the trash.",
+		Params:  []RouteParam{formParam("id", "string").required()},
+		Reply:   wallet.TrashedWallet{},
+	},
+	"POST /api/wallets/trash/purge": {
+		Summary: "Purge the expired wallets from the trash.",
+		Reply:   TrashReply{},
+	},
+	"POST /api/wallets/watch_address": {
+		Summary: "Add an address to a watch-only wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("address", "string").required()},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/backup": {
+		Summary: "Back up the wallets into an encrypted archive.",
+		Params:  []RouteParam{formParam("password", "string").required()},
+		Content: "application/octet-stream",
+	},
+	"POST /api/wallets/restore": {
+		Summary: "Restore the wallets from an archive.",
+		Params: []RouteParam{fileParam("archive"), formParam("password", "string").required(),
+			formParam("policy", "string"), formParam("duplicates", "string")},
+		Reply: wallet.RestoreResult{},
+	},
+	"POST /api/wallets/export": {
+		Summary: "Export the addresses of wallets (as '.json' or '.csv').",
+		Params: []RouteParam{formParam("labels", "string"), formParam("public_only", "boolean"),
+			formParam("secret_keys", "boolean"), formParam("confirm", "string")},
+	},
+	"POST /api/wallets/paper_wallet": {
+		Summary: "Generate a paper wallet (as '.json' or '.html').",
+		Reply:   wallet.PaperWallet{},
+	},
+	"POST /api/wallets/new_addresses": {
+		Summary: "Generate addresses for a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("n", "integer").required()},
+		Reply:   NewAddressesReply{},
+	},
+	"POST /api/wallets/import_key": {
+		Summary: "Import a secret key into a wallet.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("secret_key", "string").required(),
+			formParam("key_label", "string"), formParam("duplicates", "string")},
+		Reply: wallet.ImportResult{},
+	},
+	"POST /api/wallets/import_skycoin": {
+		Summary: "Import the keys of a Skycoin wallet file.",
+		Params: []RouteParam{fileParam("wlt"), formParam("label", "string"), formParam("name", "string"),
+			formParam("password", "string"), formParam("keystore", "string"), formParam("duplicates", "string")},
+		Reply: wallet.SkycoinImport{},
+	},
+	"POST /api/wallets/split_seed": {
+		Summary: "Split the seed of a wallet into shares.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("shares", "integer").required(),
+			formParam("threshold", "integer").required()},
+		Reply: SeedSharesReply{},
+	},
+	"POST /api/wallets/combine_seed": {
+		Summary: "Combine shares into a seed.",
+		Params:  []RouteParam{formParam("shares", "string").required()},
+		Reply:   SeedReply{},
+	},
+	"GET /api/wallets/audit": {
+		Summary: "Query the audit log of the wallets.",
+		Params: []RouteParam{queryParam("label", "string"), queryParam("action", "string"), queryParam("since", "integer"),
+			queryParam("until", "integer"), queryParam("limit", "integer")},
+		Reply: AuditReply{},
+	},
+	"POST /api/wallets/password_strength": {
+		Summary: "Check the strength of a password.",
+		Params:  []RouteParam{formParam("password", "string").required(), formParam("label", "string"), formParam("name", "string")},
+		Reply:   wallet.PasswordStrength{},
+	},
+	"GET /api/wallets/duplicates": {
+		Summary: "Find addresses in multiple wallets.",
+		Reply:   DuplicatesReply{},
+	},
+	"POST /api/wallets/set_name": {
+		Summary: "Set the display name of a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("name", "string")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/set_meta": {
+		Summary: "Set a metadata value on a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("key", "string").required(), formParam("value", "string")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"GET /api/wallets/keystores": {
+		Summary: "List the keystores of wallets.",
+		Reply:   KeystoresReply{},
+	},
+	"POST /api/wallets/set_keystore": {
+		Summary: "Set the keystore for a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("keystore", "string")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/set_hold": {
+		Summary: "Put a wallet on hold until a time (in RFC 3339), before which it can not sign.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("until", "string").required()},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/set_kitty_note": {
+		Summary: "Set the note on a kitty in a wallet.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("kitty_id", "integer").required(),
+			formParam("note", "string")},
+		Reply: wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/add_account": {
+		Summary: "Add an account to a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("name", "string")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"POST /api/wallets/new_account_address": {
+		Summary: "Generate an address for an account of a wallet.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("account", "string").required()},
+		Reply:   NewAccountAddressReply{},
+	},
+	"GET /api/address_book/list": {
+		Summary: "List the contacts of the address book.",
+		Reply:   ContactsReply{},
+	},
+	"POST /api/address_book/put": {
+		Summary: "Add or replace a contact in the address book.",
+		Params:  []RouteParam{formParam("name", "string").required(), formParam("address", "string").required(), formParam("note", "string")},
+		Reply:   true,
+	},
+	"POST /api/address_book/remove": {
+		Summary: "Remove a contact from the address book.",
+		Params:  []RouteParam{formParam("name", "string").required()},
+		Reply:   true,
+	},
+	"GET /api/address_book/resolve": {
+		Summary: "Resolve the address of a contact.",
+		Params:  []RouteParam{queryParam("name", "string").required()},
+		Reply:   ResolveReply{},
+	},
+
+	// Wallets on the chain.
+	"POST /api/wallets/scan_holdings": {
+		Summary: "Scan the kitties of the addresses of the wallets.",
+		Reply:   HoldingsReply{},
+	},
+	"GET /api/wallets/holdings": {
+		Summary: "Get the kitties of a wallet, from the last scan.",
+		Params:  []RouteParam{queryParam("label", "string").required()},
+		Reply:   wallet.Holdings{},
+	},
+	"POST /api/wallets/sign_transfer": {
+		Summary: "Sign a transfer of a kitty in a wallet.",
+		Params: []RouteParam{formParam("label", "string").required(), formParam("kitty_id", "integer").required(),
+			formParam("to", "string").required()},
+		Reply: SignedTransferReply{},
+	},
+	"GET /api/wallets/events": {
+		Summary: "Stream the events of the wallets as server-sent events.",
+		Content: "text/event-stream",
+	},
+	"POST /api/wallets/discover_addresses": {
+		Summary: "Discover the used addresses of a wallet, within a gap limit.",
+		Params:  []RouteParam{formParam("label", "string").required(), formParam("gap_limit", "integer")},
+		Reply:   wallet.FloatingWallet{},
+	},
+	"GET /api/wallets/history": {
+		Summary: "Get a page of the transaction history of a wallet.",
+		Params: []RouteParam{queryParam("label", "string").required(), queryParam("page", "integer"),
+			queryParam("per_page", "integer")},
+		Reply: wallet.HistoryPage{},
+	},
+}
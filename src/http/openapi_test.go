@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	defer bc.Close()
+
+	var (
+		api = NewAPIMux(LatestAPIVersion, new(ServerConfig).NegotiateAPIVersion)
+		mux = api.Version(APIVersion1)
+	)
+	require.Nil(t, ikoGateway(mux, bc))
+	require.Nil(t, ikoAdminGateway(mux, bc))
+	require.Nil(t, walletGateway(mux, nil))
+	require.Nil(t, walletChainGateway(mux, bc, nil))
+	require.Nil(t, graphqlGateway(mux, bc, nil))
+	require.Nil(t, apiKeyGateway(mux, &APIKeyStore{}))
+	require.Nil(t, sessionGateway(mux, &SessionManager{}))
+	require.Nil(t, csrfGateway(mux, &CSRF{}))
+	Handle(mux, "/api/spec.json", "GET", getOpenAPI(api, APIVersion1))
+
+	for _, route := range api.RoutesOf(APIVersion1) {
+		_, ok := routeDocOf(route)
+		require.True(t, ok, "route '%s %s' should be documented", route.Method, route.Pattern)
+	}
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/spec.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+	require.Equal(t, OpenAPIVersion, spec.OpenAPI)
+
+	require.Contains(t, spec.Paths, "/api/v1/kitty/{kitty_id}", "subtree patterns should be under their documented path")
+	require.Contains(t, spec.Paths["/api/v1/graphql"], "get")
+	require.Contains(t, spec.Paths["/api/v1/graphql"], "post")
+	require.Contains(t, spec.Paths["/api/v1/wallets/restore"]["post"]["requestBody"].(map[string]interface{})["content"],
+		"multipart/form-data", "file params should be in multipart forms")
+	require.Contains(t, spec.Paths["/api/v1/iko/admin/export.csv"]["get"]["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"],
+		"text/csv", "URL extensions should reply with their content type")
+
+	lookup, ok := spec.Components.Schemas["TxLookupReply"]
+	require.True(t, ok, "replies should be schemas in components")
+	require.Contains(t, lookup.Properties, "transaction")
+	require.Contains(t, lookup.Required, "status")
+
+	gql := spec.Components.Schemas["GraphQLReply"]
+	require.Contains(t, gql.Properties, "data")
+	require.NotContains(t, gql.Required, "data", "omitempty fields should not be required")
+}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limits protect public nodes from scraping and abuse. Each route group has
+// an optional limit, enforced with token buckets for each API key (for requests with a
+// valid key) or else for each IP address. Requests that exceed the limit are
+// answered with '429 Too Many Requests' and the 'Retry-After' header.
+const (
+	RateGroupRead   = "read"   // Routes that read the chain.
+	RateGroupWrite  = "write"  // Routes that mutate the chain.
+	RateGroupWallet = "wallet" // Wallet routes.
+	RateGroupAuth   = "auth"   // Session routes, such as logins.
+	RateGroupAdmin  = "admin"  // Admin routes.
+
+	// rateLimitPruneInterval is the interval at which idle buckets are dropped.
+	rateLimitPruneInterval = time.Minute
+)
+
+var rateGroups = []string{RateGroupRead, RateGroupWrite, RateGroupWallet, RateGroupAuth, RateGroupAdmin}
+
+// RateLimit is a limit on the number of requests per period. Buckets hold
+// 'Requests' tokens, refilled evenly over the period, so bursts of up to
+// 'Requests' are allowed.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// ParseRateLimit parses a limit for a route group, in the form
+// '<group>=<requests>/<period>', where the period is 's', 'm', 'h' or a
+// duration (as '10s').
+func ParseRateLimit(s string) (string, RateLimit, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit '%s', expected the form '<group>=<requests>/<period>'", s)
+	}
+	group := strings.ToLower(strings.TrimSpace(parts[0]))
+	rate := strings.SplitN(parts[1], "/", 2)
+	if len(rate) != 2 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit '%s', expected the form '<group>=<requests>/<period>'", s)
+	}
+	requests, e := strconv.Atoi(strings.TrimSpace(rate[0]))
+	if e != nil {
+		return "", RateLimit{}, fmt.Errorf("invalid requests in rate limit '%s': %v", s, e)
+	}
+	var per time.Duration
+	switch period := strings.TrimSpace(rate[1]); period {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		if per, e = time.ParseDuration(period); e != nil {
+			return "", RateLimit{}, fmt.Errorf("invalid period in rate limit '%s': %v", s, e)
+		}
+	}
+	return group, RateLimit{Requests: requests, Per: per}, nil
+}
+
+// RateLimitConfig holds the rate limits of the API.
+type RateLimitConfig struct {
+	Limits       map[string]RateLimit // Key: route group, groups without a limit are not limited.
+	ForwardedFor bool                 // Whether IP addresses are taken from 'X-Forwarded-For', for nodes behind a proxy.
+}
+
+// Enabled determines whether any route group is limited.
+func (c RateLimitConfig) Enabled() bool {
+	return len(c.Limits) > 0
+}
+
+// RateLimits are the limiters of the route groups of a config. They are
+// shared with the gRPC service (see 'rpc.ServerConfig'), so that the same
+// limits apply to its calls.
+type RateLimits struct {
+	groups       map[string]*rateLimiter
+	keys         *APIKeyStore // Requests with a valid key are limited by the key, if not nil.
+	forwardedFor bool
+	now          func() time.Time
+}
+
+// NewRateLimits creates the limiters of a config. Requests with a valid API key
+// from the store (which may be nil) are limited by the key.
+func NewRateLimits(c RateLimitConfig, keys *APIKeyStore) (*RateLimits, error) {
+	out := &RateLimits{
+		groups:       make(map[string]*rateLimiter),
+		keys:         keys,
+		forwardedFor: c.ForwardedFor,
+		now:          time.Now,
+	}
+	for group, limit := range c.Limits {
+		if !isRateGroup(group) {
+			return nil, fmt.Errorf("unknown rate limit group '%s', expected one of '%s'",
+				group, strings.Join(rateGroups, "', '"))
+		}
+		if limit.Requests < 1 || limit.Per <= 0 {
+			return nil, fmt.Errorf("invalid rate limit '%s' for group '%s'", limit, group)
+		}
+		out.groups[group] = newRateLimiter(limit)
+	}
+	return out, nil
+}
+
+func isRateGroup(group string) bool {
+	for _, g := range rateGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// rateGroupOf obtains the route group of a route, from the API key scope of
+// it.
+func rateGroupOf(method, p string) string {
+	switch apiKeyScopeOf(method, p) {
+	case apiKeyAdmin:
+		return RateGroupAdmin
+	case apiKeyWallet:
+		return RateGroupWallet
+	case apiKeyWrite:
+		return RateGroupWrite
+	}
+	if strings.HasPrefix(p, "/api/auth/") {
+		return RateGroupAuth
+	}
+	return RateGroupRead
+}
+
+// Allow takes a token from the bucket of the client of a request in a route
+// group, or else returns the time to wait for the next token. Groups without
+// a limit are always allowed.
+func (l *RateLimits) Allow(group string, r *http.Request) (time.Duration, bool) {
+	limiter, ok := l.groups[group]
+	if !ok {
+		return 0, true
+	}
+	return limiter.allow(l.clientOf(r), l.now())
+}
+
+// clientOf obtains the client of the buckets of a request. This is the API
+// key of the request if the key is valid, or else the IP address, so requests
+// with invalid keys are limited by their address before they are rejected.
+func (l *RateLimits) clientOf(r *http.Request) string {
+	if key := APIKeyOfRequest(r); key != "" && l.keys != nil {
+		if k, ok := l.keys.Verify(key); ok {
+			return "key:" + k.ID
+		}
+	}
+	if l.forwardedFor {
+		// The last address is the one added by the proxy, as the others are
+		// set by the client.
+		if hops := strings.Split(r.Header.Get("X-Forwarded-For"), ","); hops[len(hops)-1] != "" {
+			return "ip:" + strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// requireRateLimits is the rate limit middleware. It is to be outside
+// of 'requireAPIKeys', so that requests with invalid keys are limited as well.
+func requireRateLimits(l *RateLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := rateGroupOf(r.Method, unversionedPath(r.URL.Path))
+		if wait, ok := l.Allow(group, r); !ok {
+			limit := l.groups[group].limit
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			sendJson(w, http.StatusTooManyRequests,
+				fmt.Sprintf("rate limit of '%d' requests per '%s' for route group '%s' exceeded",
+					limit.Requests, limit.Per, group))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter holds the token buckets of the clients of a route group.
+type rateLimiter struct {
+	limit   RateLimit
+	rate    float64 // Tokens per second.
+	mux     sync.Mutex
+	buckets map[string]*tokenBucket // key: client
+	pruned  time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		rate:    float64(limit.Requests) / limit.Per.Seconds(),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket of the client at the specified time, or
+// else returns the time to wait for the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if now.Sub(l.pruned) >= rateLimitPruneInterval {
+		l.prune(now)
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.limit.Requests), last: now}
+		l.buckets[client] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		if wait < time.Second {
+			wait = time.Second // 'Retry-After' is in whole seconds.
+		}
+		return wait, false
+	}
+	b.tokens--
+	return 0, true
+}
+
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.limit.Requests), b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+}
+
+// prune drops the buckets that are full, as they are in the same state as new
+// buckets.
+func (l *rateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if l.refill(b, now); b.tokens >= float64(l.limit.Requests) {
+			delete(l.buckets, client)
+		}
+	}
+	l.pruned = now
+}
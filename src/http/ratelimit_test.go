@@ -0,0 +1,104 @@
+package http
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	group, limit, e := ParseRateLimit("Read=600/m")
+	require.Nil(t, e)
+	require.Equal(t, RateGroupRead, group)
+	require.Equal(t, RateLimit{Requests: 600, Per: time.Minute}, limit)
+
+	_, limit, e = ParseRateLimit("auth=5/30s")
+	require.Nil(t, e)
+	require.Equal(t, RateLimit{Requests: 5, Per: 30 * time.Second}, limit)
+
+	for _, s := range []string{"read", "read=600", "read=x/m", "read=600/x"} {
+		_, _, e = ParseRateLimit(s)
+		require.Error(t, e, "rate limit '%s' should be invalid", s)
+	}
+	_, e = NewRateLimits(RateLimitConfig{Limits: map[string]RateLimit{"other": {Requests: 1, Per: time.Second}}}, nil)
+	require.Error(t, e, "groups should be known")
+	_, e = NewRateLimits(RateLimitConfig{Limits: map[string]RateLimit{RateGroupRead: {Requests: 0, Per: time.Second}}}, nil)
+	require.Error(t, e, "limits should allow requests")
+}
+
+func TestRequireRateLimits(t *testing.T) {
+	keys, e := NewAPIKeyStore("")
+	require.Nil(t, e)
+	key, _, e := keys.Create("a", false)
+	require.Nil(t, e)
+
+	limits, e := NewRateLimits(RateLimitConfig{
+		Limits: map[string]RateLimit{
+			RateGroupRead:  {Requests: 2, Per: time.Minute},
+			RateGroupWrite: {Requests: 1, Per: time.Minute},
+		},
+	}, keys)
+	require.Nil(t, e)
+	now := time.Now()
+	limits.now = func() time.Time { return now }
+
+	mux := http.NewServeMux()
+	Handle(mux, "/api/txs", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, true)
+	})
+	Handle(mux, "/api/iko/inject_tx", "POST", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, true)
+	})
+	handler := requireRateLimits(limits, requireAPIKeys(keys, mux))
+
+	do := func(method, target, addr string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r.RemoteAddr = addr
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.1:1000").Code)
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.1:1001").Code)
+	rec := do("GET", "/api/txs", "10.0.0.1:1002")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code, "limits should be by the IP address")
+	require.Equal(t, "30", rec.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.2:1000").Code,
+		"limits should apply to each IP address")
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.1:1000", APIKeyHeader, key).Code,
+		"limits should be by the API key, not by the IP address")
+	require.Equal(t, http.StatusOK, do("POST", "/api/iko/inject_tx", "10.0.0.1:1000", APIKeyHeader, key).Code,
+		"limits should apply to each route group")
+	rec = do("POST", "/api/iko/inject_tx", "10.0.0.3:1000", APIKeyHeader, key)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code, "limits of API keys should span all IP addresses")
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusUnauthorized, do("GET", "/api/txs", "10.0.0.5:1000", APIKeyHeader, "invalid").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, do("GET", "/api/txs", "10.0.0.5:1000", APIKeyHeader, "invalid").Code,
+		"requests with invalid keys should be limited by the IP address")
+
+	now = now.Add(30 * time.Second)
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.1:1000").Code, "buckets should be refilled")
+	require.Equal(t, http.StatusTooManyRequests, do("GET", "/api/txs", "10.0.0.1:1000").Code)
+
+	now = now.Add(time.Hour)
+	require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.1:1000").Code)
+	require.Len(t, limits.groups[RateGroupRead].buckets, 1, "idle buckets should be pruned")
+
+	limits.forwardedFor = true
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, do("GET", "/api/txs", "10.0.0.9:1000", "X-Forwarded-For", "1.1.1.1, 10.0.0.4").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests,
+		do("GET", "/api/txs", "10.0.0.9:1000", "X-Forwarded-For", "2.2.2.2, 10.0.0.4").Code,
+		"addresses should be the last address, which is added by the proxy")
+}
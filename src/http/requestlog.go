@@ -0,0 +1,146 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gopkg.in/sirupsen/logrus.v1"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Each request to the API gets a request ID, which is returned in the
+// 'X-Request-ID' header and logged with the request, so that errors reported
+// by users can be correlated with the logs of the node. Requests with a valid
+// 'X-Request-ID' (such as from a proxy) keep their ID.
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDSize    = 16
+	maxRequestIDSize = 64
+)
+
+var log = logrus.New()
+
+type requestIDContextKey struct{}
+
+// RequestIDOf obtains the request ID of a request, or "" if the request is
+// not served through the request ID middleware.
+func RequestIDOf(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	id := make([]byte, requestIDSize)
+	if _, e := rand.Read(id); e != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// validRequestID determines whether a request ID from a client can be kept,
+// which needs printable characters that do not break logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDSize {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// logRequests is the request ID middleware, which logs the method, path,
+// status and latency of each request to the logger, as structured fields.
+// Requests only get IDs if the logger is nil.
+func logRequests(logger *logrus.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id))
+		if logger == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		entry := logger.WithFields(logrus.Fields{
+			"request_id": id,
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"status":     sw.status,
+			"latency":    time.Since(start).String(),
+			"bytes":      sw.bytes,
+			"remote":     r.RemoteAddr,
+		})
+		switch {
+		case sw.status >= http.StatusInternalServerError:
+			entry.Error("request failed")
+		case sw.status >= http.StatusBadRequest:
+			entry.Warning("request rejected")
+		default:
+			entry.Info("request served")
+		}
+	})
+}
+
+// statusWriter records the status and the size of a reply. It passes
+// 'http.Flusher' and 'http.Hijacker' through to the underlying writer, for streams
+// and websockets.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, e := w.ResponseWriter.Write(data)
+	w.bytes += n
+	return n, e
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not implement 'http.Hijacker'")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
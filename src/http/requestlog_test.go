@@ -0,0 +1,85 @@
+package http
+
+import (
+	"github.com/stretchr/testify/require"
+	"gopkg.in/sirupsen/logrus.v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// entryHook records the entries of a logger.
+type entryHook struct {
+	entries []*logrus.Entry
+}
+
+func (h *entryHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *entryHook) Fire(e *logrus.Entry) error {
+	h.entries = append(h.entries, e)
+	return nil
+}
+
+func (h *entryHook) LastEntry() *logrus.Entry {
+	return h.entries[len(h.entries)-1]
+}
+
+func TestLogRequests(t *testing.T) {
+	var (
+		logger = logrus.New()
+		hook   = &entryHook{}
+	)
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	mux := http.NewServeMux()
+	Handle(mux, "/api/txs", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, RequestIDOf(r))
+	})
+	Handle(mux, "/api/fail", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusInternalServerError, "failed")
+	})
+	handler := logRequests(logger, mux)
+
+	do := func(target, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		if id != "" {
+			r.Header.Set(RequestIDHeader, id)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do("/api/txs", "")
+	id := rec.Header().Get(RequestIDHeader)
+	require.Len(t, id, 2*requestIDSize)
+	require.Equal(t, `"`+id+`"`, rec.Body.String(), "handlers should see the request ID")
+	entry := hook.LastEntry()
+	require.Equal(t, logrus.InfoLevel, entry.Level)
+	require.Equal(t, id, entry.Data["request_id"])
+	require.Equal(t, "GET", entry.Data["method"])
+	require.Equal(t, "/api/txs", entry.Data["path"])
+	require.Equal(t, http.StatusOK, entry.Data["status"])
+	require.NotEmpty(t, entry.Data["latency"])
+
+	require.NotEqual(t, id, do("/api/txs", "").Header().Get(RequestIDHeader), "request IDs should be unique")
+	require.Equal(t, "proxy-42", do("/api/txs", "proxy-42").Header().Get(RequestIDHeader),
+		"request IDs from clients should be kept")
+	require.Len(t, do("/api/txs", "a b\n").Header().Get(RequestIDHeader), 2*requestIDSize,
+		"invalid request IDs from clients should be replaced")
+
+	do("/api/fail", "")
+	require.Equal(t, http.StatusInternalServerError, hook.LastEntry().Data["status"])
+	require.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+	do("/api/other", "")
+	require.Equal(t, http.StatusNotFound, hook.LastEntry().Data["status"])
+	require.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+
+	n := len(hook.entries)
+	rec = httptest.NewRecorder()
+	logRequests(nil, mux).ServeHTTP(rec, httptest.NewRequest("GET", "/api/txs", nil))
+	require.NotEmpty(t, rec.Header().Get(RequestIDHeader), "requests should get IDs if not logged")
+	require.Len(t, hook.entries, n)
+}
@@ -19,6 +19,11 @@ type ServerConfig struct {
 	EnableTLS   bool
 	TLSCertFile string
 	TLSKeyFile  string
+	APIVersion  int  // Version for requests to '/api/' that do not negotiate one, 'APIVersion1' if 0.
+	EnableCSRF  bool // Whether state-changing requests from browsers require CSRF tokens.
+	CORS        CORSConfig
+	RateLimits  RateLimitConfig
+	LogRequests bool // Whether requests to the API are logged (with their request IDs).
 }
 
 type Server struct {
@@ -76,7 +81,7 @@ func (s *Server) prepareMux() error {
 			return e
 		}
 	}
-	return s.api.host(s.mux)
+	return s.api.host(s.mux, s.c)
 }
 
 func (s *Server) prepareGUI() error {
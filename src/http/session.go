@@ -0,0 +1,425 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sessions for wallet routes are optional. If the gateway has a
+// 'SessionManager', the wallet routes ('/api/wallets/' and
+// '/api/address_book/') require the access token of a session, sent as
+// 'Authorization: Bearer <token>'. Sessions start with a login with the password of
+// the node, which issues a short-lived access token and a refresh token. Tokens are JWTs
+// (HS256) signed with a secret of the process, so sessions end as the node exits.
+const (
+	DefaultSessionTTL        = 15 * time.Minute
+	DefaultSessionRefreshTTL = 24 * time.Hour
+
+	// Logins are locked for 'SessionLoginLockout' after 'SessionMaxLoginFailures'
+	// failed logins in a row.
+	SessionMaxLoginFailures = 5
+	SessionLoginLockout     = time.Minute
+
+	sessionTokenAccess  = "access"
+	sessionTokenRefresh = "refresh"
+)
+
+var (
+	ErrSessionRequired     = errors.New("a session is required, with 'Authorization: Bearer <access_token>'")
+	ErrSessionInvalidToken = errors.New("invalid session token")
+	ErrSessionExpired      = errors.New("session token has expired")
+	ErrSessionLogin        = errors.New("invalid password")
+	ErrSessionLoginLocked  = errors.New("login is locked after too many failed logins, try again later")
+)
+
+// SessionClaims are the claims of session tokens.
+type SessionClaims struct {
+	Session   string `json:"sid"`
+	ID        string `json:"jti"`
+	Kind      string `json:"kind"` // Either "access" or "refresh".
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SessionReply holds the tokens of a session.
+type SessionReply struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // Seconds until the access token expires.
+}
+
+// SessionManager manages sessions, checking logins against the bcrypt hash of the password.
+type SessionManager struct {
+	mux          sync.Mutex
+	passwordHash []byte
+	secret       []byte
+	ttl          time.Duration
+	refreshTTL   time.Duration
+	refreshes    map[string]string    // Valid refresh token IDs of each session.
+	expiries     map[string]time.Time // Expiry of each session, from its last refresh.
+	failures     int
+	lockedUntil  time.Time
+	now          func() time.Time
+}
+
+// HashSessionPassword hashes the password for sessions with bcrypt.
+func HashSessionPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("session password can not be empty")
+	}
+	hash, e := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), e
+}
+
+// NewSessionManager creates a SessionManager for the bcrypt hash of the
+// password. TTLs of 0 use the defaults.
+func NewSessionManager(passwordHash string, ttl, refreshTTL time.Duration) (*SessionManager, error) {
+	if _, e := bcrypt.Cost([]byte(passwordHash)); e != nil {
+		return nil, fmt.Errorf("invalid bcrypt hash of session password: %v", e)
+	}
+	secret := make([]byte, 32)
+	if _, e := rand.Read(secret); e != nil {
+		return nil, e
+	}
+	if ttl == 0 {
+		ttl = DefaultSessionTTL
+	}
+	if refreshTTL == 0 {
+		refreshTTL = DefaultSessionRefreshTTL
+	}
+	return &SessionManager{
+		passwordHash: []byte(passwordHash),
+		secret:       secret,
+		ttl:          ttl,
+		refreshTTL:   refreshTTL,
+		refreshes:    make(map[string]string),
+		expiries:     make(map[string]time.Time),
+		now:          time.Now,
+	}, nil
+}
+
+// Login starts a session with the password.
+func (s *SessionManager) Login(password string) (SessionReply, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := s.now()
+	if now.Before(s.lockedUntil) {
+		return SessionReply{}, ErrSessionLoginLocked
+	}
+	if bcrypt.CompareHashAndPassword(s.passwordHash, []byte(password)) != nil {
+		if s.failures++; s.failures >= SessionMaxLoginFailures {
+			s.failures, s.lockedUntil = 0, now.Add(SessionLoginLockout)
+		}
+		return SessionReply{}, ErrSessionLogin
+	}
+	s.failures = 0
+	s.prune(now)
+	return s.issue(randomHex(16), now)
+}
+
+// Refresh obtains new tokens for a refresh token. Refresh tokens are only used
+// once, and the session ends if a used refresh token is used again.
+func (s *SessionManager) Refresh(refreshToken string) (SessionReply, error) {
+	claims, e := s.verify(refreshToken, sessionTokenRefresh)
+	if e != nil {
+		return SessionReply{}, e
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.refreshes[claims.Session] != claims.ID {
+		s.end(claims.Session)
+		return SessionReply{}, ErrSessionInvalidToken
+	}
+	return s.issue(claims.Session, s.now())
+}
+
+// Logout ends the session of a token (either access or refresh).
+func (s *SessionManager) Logout(token string) error {
+	claims, e := s.verify(token, "")
+	if e != nil {
+		return e
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.end(claims.Session)
+	return nil
+}
+
+// Verify obtains the claims of an access token for a session that has not
+// ended.
+func (s *SessionManager) Verify(accessToken string) (SessionClaims, error) {
+	claims, e := s.verify(accessToken, sessionTokenAccess)
+	if e != nil {
+		return SessionClaims{}, e
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.refreshes[claims.Session]; !ok {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *SessionManager) issue(session string, now time.Time) (SessionReply, error) {
+	var (
+		access = SessionClaims{
+			Session:   session,
+			ID:        randomHex(16),
+			Kind:      sessionTokenAccess,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.ttl).Unix(),
+		}
+		refresh = SessionClaims{
+			Session:   session,
+			ID:        randomHex(16),
+			Kind:      sessionTokenRefresh,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.refreshTTL).Unix(),
+		}
+	)
+	accessToken, e := s.sign(access)
+	if e != nil {
+		return SessionReply{}, e
+	}
+	refreshToken, e := s.sign(refresh)
+	if e != nil {
+		return SessionReply{}, e
+	}
+	s.refreshes[session] = refresh.ID
+	s.expiries[session] = now.Add(s.refreshTTL)
+	return SessionReply{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.ttl / time.Second),
+	}, nil
+}
+
+func (s *SessionManager) end(session string) {
+	delete(s.refreshes, session)
+	delete(s.expiries, session)
+}
+
+// prune ends the sessions whose refresh tokens expired.
+func (s *SessionManager) prune(now time.Time) {
+	for session, exp := range s.expiries {
+		if now.After(exp) {
+			s.end(session)
+		}
+	}
+}
+
+/*
+	<<< TOKENS >>>
+*/
+
+// jwtHeader is the header of session tokens, in the base64url encoding of
+// '{"alg":"HS256","typ":"JWT"}'.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (s *SessionManager) sign(claims SessionClaims) (string, error) {
+	raw, e := json.Marshal(claims)
+	if e != nil {
+		return "", e
+	}
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(raw)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(s.mac(signed)), nil
+}
+
+// verify obtains the claims of a token of the kind (or any kind if empty).
+// Only tokens with the header 'jwtHeader' are valid.
+func (s *SessionManager) verify(token, kind string) (SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	sig, e := base64.RawURLEncoding.DecodeString(parts[2])
+	if e != nil || !hmac.Equal(sig, s.mac(parts[0]+"."+parts[1])) {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	raw, e := base64.RawURLEncoding.DecodeString(parts[1])
+	if e != nil {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	var claims SessionClaims
+	if e := json.Unmarshal(raw, &claims); e != nil {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	if kind != "" && claims.Kind != kind {
+		return SessionClaims{}, ErrSessionInvalidToken
+	}
+	if s.now().Unix() >= claims.ExpiresAt {
+		return SessionClaims{}, ErrSessionExpired
+	}
+	return claims, nil
+}
+
+func (s *SessionManager) mac(signed string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, e := rand.Read(b); e != nil {
+		panic(e)
+	}
+	return hex.EncodeToString(b)
+}
+
+/*
+	<<< MIDDLEWARE >>>
+*/
+
+type sessionContextKey struct{}
+
+// sessionAuth is set in the context of requests by the session middleware.
+type sessionAuth struct {
+	claims *SessionClaims // Set for requests with a valid access token.
+}
+
+// isWalletRoute determines whether a route is in the wallet route group.
+func isWalletRoute(p string) bool {
+	return strings.HasPrefix(p, "/api/wallets/") || strings.HasPrefix(p, "/api/address_book/")
+}
+
+// requireSessions is the session middleware for the wallet route group.
+// Invalid tokens are only rejected on wallet routes.
+func requireSessions(sessions *SessionManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			auth   = &sessionAuth{}
+			wallet = isWalletRoute(unversionedPath(r.URL.Path))
+			e      = ErrSessionRequired
+		)
+		if token := bearerOfRequest(r); token != "" && !strings.HasPrefix(token, apiKeyPrefix) {
+			var claims SessionClaims
+			if claims, e = sessions.Verify(token); e == nil {
+				auth.claims = &claims
+			}
+		}
+		if wallet && auth.claims == nil {
+			sendJson(w, http.StatusUnauthorized, e.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, auth)))
+	})
+}
+
+// hasSession determines whether a request has a session (see
+// 'requireSessions').
+func hasSession(r *http.Request) bool {
+	auth, ok := r.Context().Value(sessionContextKey{}).(*sessionAuth)
+	return ok && auth.claims != nil
+}
+
+// walletsAuthorized determines whether a request may use wallets outside of
+// the wallet route group (such as through GraphQL), by the rules of the group.
+func walletsAuthorized(r *http.Request) bool {
+	if auth, ok := r.Context().Value(sessionContextKey{}).(*sessionAuth); ok {
+		return auth.claims != nil
+	}
+	return apiKeyAuthorized(r)
+}
+
+func bearerOfRequest(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+	return ""
+}
+
+/*
+	<<< ACTIONS >>>
+*/
+
+func sessionGateway(mux *http.ServeMux, sessions *SessionManager) error {
+
+	Handle(mux, "/api/auth/login",
+		"POST", login(sessions))
+
+	Handle(mux, "/api/auth/refresh",
+		"POST", refreshSession(sessions))
+
+	Handle(mux, "/api/auth/logout",
+		"POST", logout(sessions))
+
+	return nil
+}
+
+func login(sessions *SessionManager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		reply, e := sessions.Login(r.PostFormValue("password"))
+		switch e {
+		case nil:
+			return sendJson(w, http.StatusOK, reply)
+		case ErrSessionLogin:
+			return sendJson(w, http.StatusUnauthorized,
+				fmt.Sprintf("Error: %s", e))
+		case ErrSessionLoginLocked:
+			return sendJson(w, http.StatusTooManyRequests,
+				fmt.Sprintf("Error: %s", e))
+		default:
+			return sendJson(w, http.StatusInternalServerError,
+				fmt.Sprintf("Error: %s", e))
+		}
+	}
+}
+
+func refreshSession(sessions *SessionManager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		reply, e := sessions.Refresh(r.PostFormValue("refresh_token"))
+		if e != nil {
+			return sendJson(w, http.StatusUnauthorized,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, reply)
+	}
+}
+
+func logout(sessions *SessionManager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		if e := r.ParseForm(); e != nil {
+			return sendJson(w, http.StatusBadRequest,
+				fmt.Sprintf("Error: %s", e))
+		}
+		token := r.PostFormValue("refresh_token")
+		if token == "" {
+			token = bearerOfRequest(r)
+		}
+		if e := sessions.Logout(token); e != nil {
+			return sendJson(w, http.StatusUnauthorized,
+				fmt.Sprintf("Error: %s", e))
+		}
+		return sendJson(w, http.StatusOK, true)
+	}
+}
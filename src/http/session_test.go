@@ -0,0 +1,121 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionManager(t *testing.T) {
+	hash, e := HashSessionPassword("correct horse")
+	require.Nil(t, e)
+	sessions, e := NewSessionManager(hash, time.Minute, time.Hour)
+	require.Nil(t, e)
+	now := time.Now()
+	sessions.now = func() time.Time { return now }
+
+	_, e = sessions.Login("wrong")
+	require.Equal(t, ErrSessionLogin, e)
+
+	reply, e := sessions.Login("correct horse")
+	require.Nil(t, e)
+	require.Equal(t, int64(60), reply.ExpiresIn)
+	_, e = sessions.Verify(reply.AccessToken)
+	require.Nil(t, e)
+	_, e = sessions.Verify(reply.RefreshToken)
+	require.Equal(t, ErrSessionInvalidToken, e, "refresh tokens should not be access tokens")
+	_, e = sessions.Verify(reply.AccessToken + "A")
+	require.Equal(t, ErrSessionInvalidToken, e, "tokens with other signatures should be invalid")
+
+	now = now.Add(2 * time.Minute)
+	_, e = sessions.Verify(reply.AccessToken)
+	require.Equal(t, ErrSessionExpired, e)
+
+	refreshed, e := sessions.Refresh(reply.RefreshToken)
+	require.Nil(t, e)
+	_, e = sessions.Verify(refreshed.AccessToken)
+	require.Nil(t, e)
+
+	_, e = sessions.Refresh(reply.RefreshToken)
+	require.Equal(t, ErrSessionInvalidToken, e, "refresh tokens should only be used once")
+	_, e = sessions.Verify(refreshed.AccessToken)
+	require.Equal(t, ErrSessionInvalidToken, e, "reused refresh tokens should end the session")
+
+	reply, e = sessions.Login("correct horse")
+	require.Nil(t, e)
+	require.Nil(t, sessions.Logout(reply.RefreshToken))
+	_, e = sessions.Verify(reply.AccessToken)
+	require.Equal(t, ErrSessionInvalidToken, e, "logouts should end the session")
+
+	for i := 0; i < SessionMaxLoginFailures; i++ {
+		_, e = sessions.Login("wrong")
+		require.Equal(t, ErrSessionLogin, e)
+	}
+	_, e = sessions.Login("correct horse")
+	require.Equal(t, ErrSessionLoginLocked, e, "logins should be locked after failed logins")
+	now = now.Add(SessionLoginLockout)
+	_, e = sessions.Login("correct horse")
+	require.Nil(t, e)
+}
+
+func TestRequireSessions(t *testing.T) {
+	hash, e := HashSessionPassword("correct horse")
+	require.Nil(t, e)
+	sessions, e := NewSessionManager(hash, 0, 0)
+	require.Nil(t, e)
+
+	mux := http.NewServeMux()
+	require.Nil(t, sessionGateway(mux, sessions))
+	Handle(mux, "/api/wallets/list", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, true)
+	})
+	Handle(mux, "/api/txs", "GET", func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, walletsAuthorized(r))
+	})
+	handler := requireSessions(sessions, mux)
+
+	do := func(method, target, token string, form url.Values) (int, string) {
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, _ := do("GET", "/api/wallets/list", "", nil)
+	require.Equal(t, http.StatusUnauthorized, code, "wallet routes should require a session")
+	code, body := do("GET", "/api/txs", "invalid", nil)
+	require.Equal(t, http.StatusOK, code, "other routes should not require a session")
+	require.Equal(t, "false", body)
+
+	code, _ = do("POST", "/api/auth/login", "", url.Values{"password": {"wrong"}})
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, body = do("POST", "/api/auth/login", "", url.Values{"password": {"correct horse"}})
+	require.Equal(t, http.StatusOK, code, body)
+	var reply SessionReply
+	require.Nil(t, json.Unmarshal([]byte(body), &reply))
+
+	code, _ = do("GET", "/api/wallets/list", reply.AccessToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do("GET", "/api/v1/wallets/list", reply.RefreshToken, nil)
+	require.Equal(t, http.StatusUnauthorized, code, "refresh tokens should not authorize wallet routes")
+	_, body = do("GET", "/api/txs", reply.AccessToken, nil)
+	require.Equal(t, "true", body, "sessions should authorize wallets outside of wallet routes")
+
+	code, body = do("POST", "/api/auth/refresh", "", url.Values{"refresh_token": {reply.RefreshToken}})
+	require.Equal(t, http.StatusOK, code, body)
+	require.Nil(t, json.Unmarshal([]byte(body), &reply))
+
+	code, _ = do("POST", "/api/auth/logout", reply.AccessToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do("GET", "/api/wallets/list", reply.AccessToken, nil)
+	require.Equal(t, http.StatusUnauthorized, code, "sessions should end on logouts")
+}
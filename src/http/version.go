@@ -0,0 +1,203 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Routes under '/api/vN/' serve version N of the API, and routes under '/api/' serve
+// the version negotiated by the request (see 'NegotiateAPIVersion'). Breaking
+// changes to replies ship as a new version, so the versions before it stay
+// stable. Each version serves the routes of the version before it that it does
+// not handle itself.
+const (
+	APIVersion1      = 1
+	LatestAPIVersion = APIVersion1
+
+	// APIVersionHeader is the header with the version of requests to '/api/', and
+	// with the version of the reply.
+	APIVersionHeader = "X-API-Version"
+
+	apiMediaTypePrefix = "application/vnd.kittycash.v"
+	apiMediaTypeSuffix = "+json"
+)
+
+// APIMux serves the versions of the API, with a mux for each version. Routes in
+// the mux of a version are under '/api/', as in version 1.
+type APIMux struct {
+	versions  []*http.ServeMux
+	negotiate func(r *http.Request) (int, error)
+}
+
+// NewAPIMux creates an APIMux with versions up to 'latest', where requests to
+// '/api/' are served the version chosen by 'negotiate'.
+func NewAPIMux(latest int, negotiate func(r *http.Request) (int, error)) *APIMux {
+	versions := make([]*http.ServeMux, latest)
+	for i := range versions {
+		versions[i] = http.NewServeMux()
+	}
+	return &APIMux{versions: versions, negotiate: negotiate}
+}
+
+// Latest obtains the latest version of the API.
+func (a *APIMux) Latest() int {
+	return len(a.versions)
+}
+
+// Version obtains the mux of the routes of a version.
+func (a *APIMux) Version(version int) *http.ServeMux {
+	return a.versions[version-1]
+}
+
+// RoutesOf obtains the routes of a version, including the routes it inherits.
+func (a *APIMux) RoutesOf(version int) []Route {
+	var (
+		out   []Route
+		index = make(map[Route]int)
+	)
+	for v := 1; v <= version; v++ {
+		for _, route := range RoutesOf(a.Version(v)) {
+			if i, ok := index[route]; ok {
+				out[i] = route
+				continue
+			}
+			index[route] = len(out)
+			out = append(out, route)
+		}
+	}
+	return out
+}
+
+func (a *APIMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version, prefix, ok := versionOfPath(r.URL.Path)
+	if ok {
+		if version < 1 || version > a.Latest() {
+			sendJson(w, http.StatusNotFound,
+				fmt.Sprintf("unsupported API version '%d'", version))
+			return
+		}
+		r = withoutPathPrefix(r, prefix)
+
+	} else {
+		var e error
+		if version, e = a.negotiate(r); e == nil && version > a.Latest() {
+			e = fmt.Errorf("unsupported API version '%d', expected versions up to '%d'",
+				version, a.Latest())
+		}
+		if e != nil {
+			sendJson(w, http.StatusNotAcceptable, e.Error())
+			return
+		}
+	}
+	w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+	a.handler(version, r).ServeHTTP(w, r)
+}
+
+// handler obtains the handler for the request in the version, or in the
+// versions before it if it is not handled by the version.
+func (a *APIMux) handler(version int, r *http.Request) http.Handler {
+	for v := version; v > 1; v-- {
+		if h, pattern := a.Version(v).Handler(r); pattern != "" {
+			return h
+		}
+	}
+	h, _ := a.Version(APIVersion1).Handler(r)
+	return h
+}
+
+// VersionPath obtains the path of a route under '/api/' in a version.
+func VersionPath(version int, p string) string {
+	return fmt.Sprintf("/api/v%d/%s", version, strings.TrimPrefix(p, "/api/"))
+}
+
+// versionOfPath obtains the version of paths under '/api/vN/', and the prefix of
+// it, '/api/vN'.
+func versionOfPath(p string) (int, string, bool) {
+	if !strings.HasPrefix(p, "/api/v") {
+		return 0, "", false
+	}
+	seg := strings.SplitN(strings.TrimPrefix(p, "/api/"), "/", 2)[0]
+	version, e := strconv.Atoi(seg[1:])
+	if e != nil {
+		return 0, "", false
+	}
+	return version, "/api/" + seg, true
+}
+
+// unversionedPath obtains the path under '/api/' for a path under '/api/vN/'.
+func unversionedPath(p string) string {
+	if _, prefix, ok := versionOfPath(p); ok {
+		return "/api" + strings.TrimPrefix(p, prefix)
+	}
+	return p
+}
+
+// withoutPathPrefix obtains a shallow copy of the request, with the prefix of
+// the version removed from its path.
+func withoutPathPrefix(r *http.Request, prefix string) *http.Request {
+	u := *r.URL
+	u.Path = "/api" + strings.TrimPrefix(u.Path, prefix)
+	if u.RawPath != "" {
+		u.RawPath = "/api" + strings.TrimPrefix(u.RawPath, prefix)
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = &u
+	return r2
+}
+
+/*
+	<<< NEGOTIATION >>>
+*/
+
+// DefaultAPIVersion obtains the version for requests to '/api/' that do not
+// negotiate one.
+func (c *ServerConfig) DefaultAPIVersion() int {
+	if c.APIVersion == 0 {
+		return APIVersion1
+	}
+	return c.APIVersion
+}
+
+// NegotiateAPIVersion obtains the version of a request to '/api/', from the
+// 'X-API-Version' header, or from a media type in 'Accept' (such as
+// 'application/vnd.kittycash.v1+json'), or else the default of the config.
+func (c *ServerConfig) NegotiateAPIVersion(r *http.Request) (int, error) {
+	version, ok := 0, false
+	if v := r.Header.Get(APIVersionHeader); v != "" {
+		var e error
+		if version, e = strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(v), "v")); e != nil {
+			return 0, fmt.Errorf("invalid API version '%s'", v)
+		}
+		ok = true
+	} else {
+		version, ok = apiVersionOfAccept(r.Header.Get("Accept"))
+	}
+	if !ok {
+		return c.DefaultAPIVersion(), nil
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("unsupported API version '%d'", version)
+	}
+	return version, nil
+}
+
+// apiVersionOfAccept obtains the version of the first media type of the API
+// in an 'Accept' header.
+func apiVersionOfAccept(accept string) (int, bool) {
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType = strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0])
+		if !strings.HasPrefix(mediaType, apiMediaTypePrefix) ||
+			!strings.HasSuffix(mediaType, apiMediaTypeSuffix) {
+			continue
+		}
+		version, e := strconv.Atoi(strings.TrimSuffix(
+			strings.TrimPrefix(mediaType, apiMediaTypePrefix), apiMediaTypeSuffix))
+		if e == nil {
+			return version, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIMux(t *testing.T) {
+	c := &ServerConfig{}
+	api := NewAPIMux(2, c.NegotiateAPIVersion)
+	reply := func(v string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+			return sendJson(w, http.StatusOK, v+" "+p.Segment(3))
+		}
+	}
+	Handle(api.Version(1), "/api/kitty/", "GET", reply("v1"))
+	Handle(api.Version(1), "/api/head", "GET", reply("v1"))
+	Handle(api.Version(2), "/api/kitty/", "GET", reply("v2"))
+
+	get := func(target string, header ...string) (int, string, string) {
+		r := httptest.NewRequest("GET", target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, r)
+		return rec.Code, rec.Header().Get(APIVersionHeader), rec.Body.String()
+	}
+
+	for _, tc := range []struct {
+		target, header, value string
+		status                int
+		version, body         string
+	}{
+		{"/api/v1/kitty/9", "", "", http.StatusOK, "1", `"v1 9"`},
+		{"/api/v2/kitty/9", "", "", http.StatusOK, "2", `"v2 9"`},
+		{"/api/v2/head", "", "", http.StatusOK, "2", `"v1 "`},
+		{"/api/kitty/9", "", "", http.StatusOK, "1", `"v1 9"`},
+		{"/api/kitty/9", APIVersionHeader, "2", http.StatusOK, "2", `"v2 9"`},
+		{"/api/kitty/9", "Accept", "text/plain, application/vnd.kittycash.v2+json", http.StatusOK, "2", `"v2 9"`},
+		{"/api/kitty/9", APIVersionHeader, "3", http.StatusNotAcceptable, "", ""},
+		{"/api/kitty/9", APIVersionHeader, "two", http.StatusNotAcceptable, "", ""},
+		{"/api/v3/kitty/9", "", "", http.StatusNotFound, "", ""},
+	} {
+		status, version, body := get(tc.target, tc.header, tc.value)
+		require.Equal(t, tc.status, status, "status of '%s' (%s: %s)", tc.target, tc.header, tc.value)
+		require.Equal(t, tc.version, version, "version of '%s' (%s: %s)", tc.target, tc.header, tc.value)
+		if tc.body != "" {
+			require.Equal(t, tc.body, body, "reply of '%s' (%s: %s)", tc.target, tc.header, tc.value)
+		}
+	}
+
+	require.Equal(t, []Route{{"/api/kitty/", "GET"}, {"/api/head", "GET"}}, api.RoutesOf(2),
+		"routes of versions should include the routes they inherit")
+
+	c.APIVersion = 2
+	_, version, body := get("/api/kitty/9")
+	require.Equal(t, "2", version, "requests should get the default version of the config")
+	require.Equal(t, `"v2 9"`, body)
+}
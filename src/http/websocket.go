@@ -0,0 +1,251 @@
+package http
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The WebSocket protocol (RFC 6455) is implemented here for the server side
+// only, and only as far as the streams of the gateway need it: messages are
+// written unfragmented, and read messages are limited in size.
+
+const (
+	wsGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessageSize = 64 << 10
+	wsWriteTimeout   = 10 * time.Second
+)
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// Close codes of RFC 6455.
+const (
+	wsCloseNormal      = 1000
+	wsCloseProtocol    = 1002
+	wsCloseUnsupported = 1003
+	wsCloseTooBig      = 1009
+)
+
+var errWSClosed = errors.New("websocket is closed")
+
+// wsConn is a WebSocket connection on the server side. Writes are safe for
+// concurrent use, reads are not.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmux sync.Mutex
+}
+
+// upgradeWebSocket upgrades the request to a WebSocket connection. If it
+// fails, a reply is already sent.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	switch {
+	case !headerContains(r.Header, "Connection", "upgrade"),
+		!headerContains(r.Header, "Upgrade", "websocket"):
+		e := errors.New("expected websocket upgrade")
+		sendJson(w, http.StatusBadRequest, e.Error())
+		return nil, e
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		e := fmt.Errorf("unsupported websocket version '%s', expected '13'",
+			r.Header.Get("Sec-WebSocket-Version"))
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		sendJson(w, http.StatusUpgradeRequired, e.Error())
+		return nil, e
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if raw, e := base64.StdEncoding.DecodeString(key); e != nil || len(raw) != 16 {
+		e := fmt.Errorf("invalid websocket key '%s'", key)
+		sendJson(w, http.StatusBadRequest, e.Error())
+		return nil, e
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		e := errors.New("websocket is not supported")
+		sendJson(w, http.StatusInternalServerError, e.Error())
+		return nil, e
+	}
+	conn, rw, e := hijacker.Hijack()
+	if e != nil {
+		return nil, e
+	}
+	// Deadlines of the server are meant for requests, not for streams.
+	conn.SetDeadline(time.Time{})
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if _, e := fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:])); e != nil {
+		conn.Close()
+		return nil, e
+	}
+	if e := rw.Flush(); e != nil {
+		conn.Close()
+		return nil, e
+	}
+	return &wsConn{conn: conn, br: rw.Reader}, nil
+}
+
+// ReadMessage reads the next text or binary message. Pings are answered
+// while reading, and a close from the client is answered and fails with
+// 'errWSClosed'.
+func (c *wsConn) ReadMessage() (opcode byte, data []byte, e error) {
+	for {
+		fin, op, payload, e := c.readFrame()
+		if e != nil {
+			return 0, nil, e
+		}
+		switch op {
+		case wsOpPing:
+			if e := c.writeFrame(wsOpPong, payload); e != nil {
+				return 0, nil, e
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.Close(wsCloseNormal)
+			return 0, nil, errWSClosed
+		case wsOpText, wsOpBinary:
+			if opcode != 0 {
+				c.Close(wsCloseProtocol)
+				return 0, nil, errors.New("websocket message is interrupted")
+			}
+			opcode = op
+		case wsOpContinuation:
+			if opcode == 0 {
+				c.Close(wsCloseProtocol)
+				return 0, nil, errors.New("websocket continuation does not continue a message")
+			}
+		default:
+			c.Close(wsCloseProtocol)
+			return 0, nil, fmt.Errorf("invalid websocket opcode '%d'", op)
+		}
+		if len(data)+len(payload) > wsMaxMessageSize {
+			c.Close(wsCloseTooBig)
+			return 0, nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessageSize)
+		}
+		data = append(data, payload...)
+		if fin {
+			return opcode, data, nil
+		}
+	}
+}
+
+// WriteMessage writes a text or binary message.
+func (c *wsConn) WriteMessage(opcode byte, data []byte) error {
+	return c.writeFrame(opcode, data)
+}
+
+// Close sends a close with the code, and closes the connection.
+func (c *wsConn) Close(code uint16) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, code)
+	c.writeFrame(wsOpClose, payload)
+	return c.conn.Close()
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, e error) {
+	var head [2]byte
+	if _, e := io.ReadFull(c.br, head[:]); e != nil {
+		return false, 0, nil, e
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0F
+	if head[0]&0x70 != 0 {
+		c.Close(wsCloseProtocol)
+		return false, 0, nil, errors.New("websocket extensions are not supported")
+	}
+	if head[1]&0x80 == 0 {
+		c.Close(wsCloseProtocol)
+		return false, 0, nil, errors.New("websocket frames from clients should be masked")
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, e := io.ReadFull(c.br, ext[:]); e != nil {
+			return false, 0, nil, e
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, e := io.ReadFull(c.br, ext[:]); e != nil {
+			return false, 0, nil, e
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxMessageSize {
+		c.Close(wsCloseTooBig)
+		return false, 0, nil, fmt.Errorf("websocket frame exceeds %d bytes", wsMaxMessageSize)
+	}
+	var mask [4]byte
+	if _, e := io.ReadFull(c.br, mask[:]); e != nil {
+		return false, 0, nil, e
+	}
+	payload = make([]byte, n)
+	if _, e := io.ReadFull(c.br, payload); e != nil {
+		return false, 0, nil, e
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	head := make([]byte, 2, 10)
+	head[0] = 0x80 | op
+	switch n := len(payload); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xFFFF:
+		head[1] = 126
+		head = head[:4]
+		binary.BigEndian.PutUint16(head[2:], uint16(n))
+	default:
+		head[1] = 127
+		head = head[:10]
+		binary.BigEndian.PutUint64(head[2:], uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, e := c.conn.Write(append(head, payload...)); e != nil {
+		return e
+	}
+	return nil
+}
+
+// headerContains determines whether the comma separated values of the header
+// contain the token, ignoring case.
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(key)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,15 +1,85 @@
 package iko
 
 import (
+	"errors"
+	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
 	"gopkg.in/sirupsen/logrus.v1"
+	"io"
+	"math"
 	"os"
 	"sync"
+	"time"
 )
 
 type BlockChainConfig struct {
 	CreatorPK cipher.PubKey
 	TxAction  TxAction
+
+	// TxHooks are run after each transaction is committed, after 'TxAction'
+	// (which is the hook named "tx_action", with the abort policy). More hooks
+	// can be added with 'BlockChain.AddTxHook'.
+	TxHooks []TxHook
+
+	// SnapshotDB is where state snapshots are stored (nil disables snapshots).
+	// On initialization, the state is loaded from the nearest snapshot and
+	// only the remaining transactions are replayed.
+	SnapshotDB SnapshotDB
+
+	// MetaDB is where kitty metadata is stored (nil disables metadata).
+	MetaDB MetaDB
+
+	// KittySupply is the number of kitties (with IDs from 0 to 'KittySupply'-1)
+	// available in the IKO. Kitties in this range that are neither minted nor
+	// reserved are listed as unclaimed (0 lists no kitties as unclaimed).
+	KittySupply uint64
+
+	// TransferFee is the flat fee that every transfer needs to pay to the
+	// master address (0 disables fees). Fees are recorded in transactions and
+	// accounted in state. Changing the fee invalidates existing transfers of
+	// a different fee when the chain is replayed.
+	TransferFee uint64
+
+	// BurnCosign determines that burns (transfers to 'BurnAddress') need to be
+	// countersigned by the master key, in addition to the sender's signature.
+	BurnCosign bool
+
+	// SnapshotInterval determines that a snapshot is taken every
+	// 'SnapshotInterval' transactions (0 disables taking snapshots).
+	SnapshotInterval uint64
+
+	// SnapshotRetention determines which snapshots are kept when the
+	// snapshots are pruned (a zero value disables pruning).
+	SnapshotRetention SnapshotRetention
+
+	// SnapshotPruneInterval is the duration between prunes of snapshots.
+	SnapshotPruneInterval time.Duration
+
+	// MempoolSize is the maximum number of transactions that wait in the
+	// mempool (0 disables the mempool).
+	MempoolSize int
+
+	// MempoolTTL is the duration that a transaction waits in the mempool
+	// before it is dropped.
+	MempoolTTL time.Duration
+
+	// TxLimits are the structural limits for new transactions (zero limits are
+	// taken from 'DefaultTxLimits').
+	TxLimits TxLimits
+
+	// TxRateLimit is the maximum number of transactions that an address can
+	// inject within 'TxRateWindow' (0 disables rate limiting). Gen
+	// transactions by the master key are not limited.
+	TxRateLimit int
+
+	// TxRateWindow is the sliding window of 'TxRateLimit'.
+	TxRateWindow time.Duration
+
+	// DisabledTxChecks are the names of the stages of the transaction
+	// pipeline that are skipped (see 'TxCheckNames'). The stages are also
+	// skipped when the chain is replayed. The link, sig, kind, nonce and
+	// ownership stages can not be disabled.
+	DisabledTxChecks []string
 }
 
 func (cc *BlockChainConfig) Prepare() error {
@@ -18,9 +88,24 @@ func (cc *BlockChainConfig) Prepare() error {
 			return nil
 		}
 	}
+	if cc.SnapshotPruneInterval <= 0 {
+		cc.SnapshotPruneInterval = time.Hour
+	}
+	if cc.MempoolTTL <= 0 {
+		cc.MempoolTTL = DefaultMempoolTTL
+	}
+	if cc.TxRateWindow <= 0 {
+		cc.TxRateWindow = DefaultTxRateWindow
+	}
 	if e := cc.CreatorPK.Verify(); e != nil {
 		return e
 	}
+	if e := cc.TxLimits.Prepare(); e != nil {
+		return e
+	}
+	if e := checkTxChecks(cc.DisabledTxChecks); e != nil {
+		return e
+	}
 	return nil
 }
 
@@ -28,6 +113,10 @@ type BlockChain struct {
 	c     *BlockChainConfig
 	chain ChainDB
 	state StateDB
+	hub   *TxHub
+	hooks TxHooks
+	pool  *Mempool     // nil if disabled
+	rate  *RateLimiter // nil if disabled
 	log   *logrus.Logger
 	mux   sync.RWMutex
 
@@ -43,6 +132,7 @@ func NewBlockChain(config *BlockChainConfig, chainDB ChainDB, stateDB StateDB) (
 		c:     config,
 		chain: chainDB,
 		state: stateDB,
+		hub:   NewTxHub(),
 		log: &logrus.Logger{
 			Out:       os.Stderr,
 			Formatter: new(logrus.TextFormatter),
@@ -51,6 +141,23 @@ func NewBlockChain(config *BlockChainConfig, chainDB ChainDB, stateDB StateDB) (
 		},
 		quit: make(chan struct{}),
 	}
+	hooks := append([]TxHook{{
+		Name:   "tx_action",
+		Order:  math.MinInt32,
+		Policy: TxHookAbort,
+		Action: config.TxAction,
+	}}, config.TxHooks...)
+	for _, hook := range hooks {
+		if e := bc.hooks.Add(hook); e != nil {
+			return nil, e
+		}
+	}
+	if config.MempoolSize > 0 {
+		bc.pool = NewMempool(config.MempoolSize, config.MempoolTTL)
+	}
+	if config.TxRateLimit > 0 {
+		bc.rate = NewRateLimiter(config.TxRateLimit, config.TxRateWindow)
+	}
 
 	if e := bc.InitState(); e != nil {
 		return nil, e
@@ -59,39 +166,177 @@ func NewBlockChain(config *BlockChainConfig, chainDB ChainDB, stateDB StateDB) (
 	bc.wg.Add(1)
 	go bc.service()
 
+	if config.SnapshotDB != nil && !config.SnapshotRetention.IsZero() {
+		bc.wg.Add(1)
+		go bc.pruneService()
+	}
+
 	return bc, nil
 }
 
 func (bc *BlockChain) InitState() error {
 
-	var prev *Transaction
-	for i := uint64(1); i < bc.chain.Len(); i++ {
+	prev, e := bc.loadSnapshot()
+	if e != nil {
+		return e
+	}
+
+	return bc.replayTxs(bc.state, prev, func(tx *Transaction) {
+		bc.log.WithField("tx", tx.String()).Debugf("InitState (%d)", tx.Seq)
+		bc.saveSnapshot(tx)
+	})
+}
+
+// replayTxs verifies and applies the transactions of the chain to the specified
+// state, starting after the transaction 'prev' (or from genesis if nil).
+// 'action' (if not nil) is called after each transaction is applied.
+func (bc *BlockChain) replayTxs(state StateDB, prev *Transaction, action func(tx *Transaction)) error {
+
+	start := uint64(0)
+	if prev != nil {
+		start = prev.Seq + 1
+	}
+
+	for i := start; i < bc.chain.Len(); i++ {
 
 		// Get transaction.
 		tx, e := bc.chain.GetTxOfSeq(i)
 		if e != nil {
 			return e
 		}
-		bc.log.WithField("tx", tx.String()).Debugf("InitState (%d)", i)
 
-		// Check hash, seq and sig of tx.
-		if e := tx.Verify(prev); e != nil {
+		// Check hash, seq and sig of tx. If tx is to structured to create a
+		// kitty, attempt to add to state. Otherwise, attempt to transfer its
+		// ownership in the state.
+		if e := bc.txPipeline(state, prev, false).Check(&tx); e != nil {
 			return e
 		}
+		if action != nil {
+			action(&tx)
+		}
+		prev = &tx
+	}
+	return nil
+}
 
-		// If tx is to structured to create a kitty, attempt to add to state.
-		// Otherwise, attempt to transfer it's ownership in the state.
-		if tx.IsKittyGen(bc.c.CreatorPK) {
-			if e := bc.state.AddKitty(tx.Hash(), tx.KittyID, tx.To); e != nil {
-				return e
-			}
-		} else {
-			if e := bc.state.MoveKitty(tx.Hash(), tx.KittyID, tx.From, tx.To); e != nil {
-				return e
+// loadSnapshot loads the state from the nearest snapshot to the chain head,
+// and returns the transaction that the snapshot was taken at.
+// Nil is returned if no valid snapshot is found.
+func (bc *BlockChain) loadSnapshot() (*Transaction, error) {
+	if bc.c.SnapshotDB == nil || bc.chain.Len() == 0 {
+		return nil, nil
+	}
+	snap, ok, e := bc.c.SnapshotDB.Nearest(bc.chain.HeadSeq())
+	if e != nil || !ok {
+		return nil, e
+	}
+	tx, e := bc.chain.GetTxOfSeq(snap.Seq)
+	if e != nil {
+		return nil, e
+	}
+	if tx.Hash() != snap.Head {
+		bc.log.
+			WithField("seq", snap.Seq).
+			Warn("snapshot does not match chain, replaying whole chain")
+		return nil, nil
+	}
+	if e := bc.state.LoadSnapshot(snap.State); e != nil {
+		return nil, e
+	}
+	bc.log.
+		WithField("seq", snap.Seq).
+		Info("loaded state snapshot")
+	return &tx, nil
+}
+
+// saveSnapshot takes a snapshot of the state if the snapshot interval is reached
+// at the specified transaction. Failing to save a snapshot is not fatal.
+func (bc *BlockChain) saveSnapshot(tx *Transaction) {
+	interval := bc.c.SnapshotInterval
+	if bc.c.SnapshotDB == nil || interval == 0 || (tx.Seq+1)%interval != 0 {
+		return
+	}
+	raw, e := bc.state.Snapshot()
+	if e == nil {
+		e = bc.c.SnapshotDB.Save(StateSnapshot{
+			Seq:   tx.Seq,
+			Head:  tx.Hash(),
+			State: raw,
+		})
+	}
+	if e != nil {
+		bc.log.
+			WithField("seq", tx.Seq).
+			WithError(e).
+			Error("failed to save state snapshot")
+	}
+}
+
+// pruneService periodically deletes the snapshots that are outside of the
+// snapshot retention policy, so that the disk usage of snapshots is bounded.
+func (bc *BlockChain) pruneService() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(bc.c.SnapshotPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-bc.quit:
+			return
+
+		case <-ticker.C:
+			count, e := bc.c.SnapshotDB.Prune(bc.c.SnapshotRetention)
+			if e != nil {
+				bc.log.
+					WithError(e).
+					Error("failed to prune state snapshots")
+			} else if count > 0 {
+				bc.log.
+					WithField("count", count).
+					Info("pruned state snapshots")
 			}
 		}
 	}
-	return nil
+}
+
+// txChanges obtains the changes of ownership by a transaction.
+func (bc *BlockChain) txChanges(tx *Transaction) []OwnershipChange {
+	var (
+		ref     = tx.Ref()
+		isGen   = tx.IsKittyGen(bc.c.CreatorPK)
+		kitties = tx.Kitties()
+		out     = make([]OwnershipChange, len(kitties))
+	)
+	for i, kittyID := range kitties {
+		out[i] = OwnershipChange{
+			Tx:      ref,
+			KittyID: kittyID,
+			To:      tx.To,
+		}
+		if !isGen {
+			out[i].From = tx.From
+		}
+	}
+	if isGen {
+		out[0].Parents = tx.Parents
+	}
+	if !isGen && tx.Fee != 0 {
+		out[0].Fee = tx.Fee
+		out[0].FeeTo = cipher.AddressFromPubKey(bc.c.CreatorPK)
+	}
+	return out
+}
+
+// AddTxHook registers a hook that is run after each transaction is committed.
+func (bc *BlockChain) AddTxHook(hook TxHook) error {
+	return bc.hooks.Add(hook)
+}
+
+// RemoveTxHook removes the hook with the name. It returns false if there is no
+// such hook.
+func (bc *BlockChain) RemoveTxHook(name string) bool {
+	return bc.hooks.Remove(name)
 }
 
 func (bc *BlockChain) Close() {
@@ -107,13 +352,17 @@ func (bc *BlockChain) service() {
 			return
 
 		case tx := <-bc.chain.TxChan():
-			if e := bc.c.TxAction(tx); e != nil {
-				panic(e)
-			}
+			bc.hooks.Run(tx, bc.log)
+			bc.hub.Broadcast(tx)
 		}
 	}
 }
 
+// Subscribe subscribes to transactions that are accepted into the chain.
+func (bc *BlockChain) Subscribe(bufferSize int) *TxSubscription {
+	return bc.hub.Subscribe(bufferSize)
+}
+
 func (bc *BlockChain) GetHeadTx() (Transaction, error) {
 	bc.mux.RLock()
 	defer bc.mux.RUnlock()
@@ -142,6 +391,81 @@ func (bc *BlockChain) GetKittyState(kittyID KittyID) (*KittyState, bool) {
 	return bc.state.GetKittyState(kittyID)
 }
 
+// GetKittyMeta obtains the metadata of a kitty.
+// It returns false if there is no metadata for the kitty, or metadata is disabled.
+func (bc *BlockChain) GetKittyMeta(kittyID KittyID) (*KittyMeta, bool) {
+	if bc.c.MetaDB == nil {
+		return nil, false
+	}
+	return bc.c.MetaDB.GetKittyMeta(kittyID)
+}
+
+// SetKittyMeta sets the metadata of a kitty.
+// The metadata needs to be signed by the master key. If the kitty is minted
+// with an attribute hash, the metadata also needs to have that hash.
+func (bc *BlockChain) SetKittyMeta(m *SignedKittyMeta) error {
+	if bc.c.MetaDB == nil {
+		return errors.New("kitty metadata is disabled")
+	}
+	if e := m.Verify(bc.c.CreatorPK); e != nil {
+		return fmt.Errorf("metadata of kitty of id '%d' is not signed by master: %v",
+			m.KittyID, e)
+	}
+	mint, e := bc.GetMintMeta(m.KittyID)
+	if e != nil {
+		return e
+	}
+	if mint.AttrHash != (cipher.SHA256{}) && mint.AttrHash != m.Meta.Hash() {
+		return fmt.Errorf("metadata of kitty of id '%d' does not match minted hash '%s'",
+			m.KittyID, mint.AttrHash.Hex())
+	}
+	return bc.c.MetaDB.SetKittyMeta(m.KittyID, m.Meta)
+}
+
+// GetMintMeta obtains the metadata recorded by the gen transaction of a kitty.
+// It returns empty metadata if the kitty does not exist, or is minted without.
+func (bc *BlockChain) GetMintMeta(kittyID KittyID) (MintMeta, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	kState, ok := bc.state.GetKittyState(kittyID)
+	if !ok || len(kState.Transactions) == 0 {
+		return MintMeta{}, nil
+	}
+	tx, e := bc.chain.GetTxOfHash(kState.Transactions[0])
+	if e != nil {
+		return MintMeta{}, e
+	}
+	return tx.Mint, nil
+}
+
+// GetKittyHistory obtains the ownership transitions of a kitty,
+// ordered from the kitty's creation to its latest transfer.
+func (bc *BlockChain) GetKittyHistory(kittyID KittyID) ([]KittyTransition, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	kState, ok := bc.state.GetKittyState(kittyID)
+	if !ok {
+		return nil, kindErrorf(ErrKittyUnknown, "kitty of id '%d' does not exist", kittyID)
+	}
+
+	out := make([]KittyTransition, len(kState.Transactions))
+	for i, txHash := range kState.Transactions {
+		tx, e := bc.chain.GetTxOfHash(txHash)
+		if e != nil {
+			return nil, e
+		}
+		out[i] = KittyTransition{
+			Owner:  tx.To,
+			TxHash: txHash,
+			Seq:    tx.Seq,
+			Memo:   tx.Memo,
+		}
+	}
+	return out, nil
+}
+
 func (bc *BlockChain) GetAddressState(address cipher.Address) *AddressState {
 	bc.mux.RLock()
 	defer bc.mux.RUnlock()
@@ -149,41 +473,326 @@ func (bc *BlockChain) GetAddressState(address cipher.Address) *AddressState {
 	return bc.state.GetAddressState(address)
 }
 
+// TransferFee obtains the fee that transfers need to pay.
+func (bc *BlockChain) TransferFee() uint64 {
+	return bc.c.TransferFee
+}
+
+// NextNonce obtains the nonce that the next transfer from an address needs.
+func (bc *BlockChain) NextNonce(address cipher.Address) uint64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.GetNonce(address) + 1
+}
+
+func (bc *BlockChain) CountOfAddress(address cipher.Address) uint64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.CountOfAddress(address)
+}
+
+func (bc *BlockChain) GetKittiesOfAddress(address cipher.Address, currentPage, perPage uint64) (PaginatedKitties, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	kitties, e := bc.state.GetKittiesOfAddress(address, currentPage, perPage)
+	if e != nil {
+		return PaginatedKitties{}, e
+	}
+	return PaginatedKitties{
+		TotalPageCount: totalPageCount(bc.state.CountOfAddress(address), perPage),
+		Kitties:        kitties,
+	}, nil
+}
+
+func (bc *BlockChain) GetAddresses(currentPage, perPage uint64) (PaginatedAddresses, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	addresses, e := bc.state.Addresses(currentPage, perPage)
+	if e != nil {
+		return PaginatedAddresses{}, e
+	}
+	return PaginatedAddresses{
+		TotalPageCount: totalPageCount(bc.state.CountOfAddresses(), perPage),
+		Addresses:      addresses,
+	}, nil
+}
+
+// ReserveKitty holds back a kitty that is not yet minted from sale.
+func (bc *BlockChain) ReserveKitty(kittyID KittyID) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	return bc.state.ReserveKitty(kittyID)
+}
+
+func (bc *BlockChain) UnreserveKitty(kittyID KittyID) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	return bc.state.UnreserveKitty(kittyID)
+}
+
+func (bc *BlockChain) GetKittyStatus(kittyID KittyID) KittyStatus {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.GetKittyStatus(kittyID)
+}
+
+func (bc *BlockChain) CountOfStatus(status KittyStatus) uint64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.CountOfStatus(status, bc.c.KittySupply)
+}
+
+func (bc *BlockChain) GetKittiesOfStatus(status KittyStatus, currentPage, perPage uint64) (PaginatedKitties, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	kitties, e := bc.state.KittiesOfStatus(status, bc.c.KittySupply, currentPage, perPage)
+	if e != nil {
+		return PaginatedKitties{}, e
+	}
+	return PaginatedKitties{
+		TotalPageCount: totalPageCount(bc.state.CountOfStatus(status, bc.c.KittySupply), perPage),
+		Kitties:        kitties,
+	}, nil
+}
+
+func (bc *BlockChain) Stats(topN int, window time.Duration) StateStats {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.Stats(topN, window)
+}
+
+func (bc *BlockChain) Diff(fromSeq, toSeq uint64) ([]KittyDiff, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.Diff(fromSeq, toSeq)
+}
+
+func (bc *BlockChain) Export(w io.Writer, format ExportFormat) error {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	return bc.state.Export(w, format)
+}
+
+// InjectTx checks and injects a transaction into the chain. If the same
+// transaction is already accepted, a '*TxAcceptedError' with its seq is
+// returned.
 func (bc *BlockChain) InjectTx(tx *Transaction) error {
+	if tx.IsGrouped() {
+		return errGroupedTx
+	}
+
 	bc.mux.Lock()
 	defer bc.mux.Unlock()
 
-	var check = TxChecker(func(tx *Transaction) error {
-		var prev *Transaction
-		if temp, e := bc.chain.Head(); e == nil {
-			prev = &temp
+	if e := bc.injectTx(tx); e != nil {
+		return e
+	}
+	bc.promoteTxs()
+	return nil
+}
+
+// SubmitTx injects a transaction into the chain. If the transaction can not
+// yet be accepted, it waits in the mempool and is injected automatically once
+// its prerequisites are met. It returns true if the transaction is pending.
+func (bc *BlockChain) SubmitTx(tx *Transaction) (bool, error) {
+	if tx.IsGrouped() {
+		return false, errGroupedTx
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if bc.pool == nil {
+		return false, bc.injectTx(tx)
+	}
+	hash := tx.SignatureHash()
+	p, pending := bc.pool.txs[hash]
+	if pending {
+		// Merge signatures with the pending transaction, so that it can
+		// be injected once the threshold of signatures is reached.
+		if tx.Signers.IsZero() {
+			return true, nil
 		}
-		if e := tx.Verify(prev); e != nil {
-			return e
+		if e := p.Tx.mergeSigs(tx.Sigs); e != nil {
+			return false, e
+		}
+		tx = &p.Tx
+	}
+	e := bc.injectTx(tx)
+	if e == nil {
+		bc.pool.Remove(hash)
+		bc.promoteTxs()
+		return false, nil
+	}
+	if errors.Is(e, ErrDuplicateTx) {
+		return false, e
+	}
+	bc.log.
+		WithField("tx", tx.String()).
+		WithError(e).
+		Debug("tx is pending")
+	if pending {
+		return true, nil
+	}
+	if e := bc.pool.Add(*tx, time.Now()); e != nil {
+		return false, e
+	}
+	return true, nil
+}
+
+// PendingTxs obtains the transactions that wait in the mempool.
+func (bc *BlockChain) PendingTxs() []PendingTx {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if bc.pool == nil {
+		return nil
+	}
+	bc.pool.Prune(time.Now())
+	return bc.pool.Txs()
+}
+
+// promoteTxs injects the pending transactions that are able to be accepted,
+// until no more can be injected.
+func (bc *BlockChain) promoteTxs() {
+	if bc.pool == nil {
+		return
+	}
+	bc.pool.Prune(time.Now())
+	for promoted := true; promoted; {
+		promoted = false
+		for _, p := range bc.pool.Txs() {
+			if e := bc.injectTx(&p.Tx); e != nil {
+				continue
+			}
+			bc.pool.Remove(p.Tx.SignatureHash())
+			bc.log.
+				WithField("tx", p.Tx.String()).
+				Debug("promoted pending tx")
+			promoted = true
 		}
+	}
+}
+
+func (bc *BlockChain) injectTx(tx *Transaction) error {
+	if e := bc.addTx(tx); e != nil {
+		return e
+	}
+	bc.recordRate(tx)
+	return nil
+}
+
+// addTx adds a transaction to the chain, without recording it against the
+// rate limit of the sender.
+func (bc *BlockChain) addTx(tx *Transaction) error {
+	var check = TxChecker(func(tx *Transaction) error {
 		if tx.IsKittyGen(bc.c.CreatorPK) {
 			bc.log.
-				WithField("kitty_id", tx.KittyID).
+				WithField("kitty_ids", tx.Kitties()).
 				WithField("address", tx.To.String()).
 				Debug("gen_tx")
-
-			if e := bc.state.AddKitty(tx.Hash(), tx.KittyID, tx.To); e != nil {
-				return e
-			}
 		} else {
 			bc.log.
-				WithField("kitty_id", tx.KittyID).
+				WithField("kitty_ids", tx.Kitties()).
 				WithField("from_address", tx.From.String()).
 				WithField("to_address", tx.To.String()).
 				Debug("move_tx")
-			if e := bc.state.MoveKitty(tx.Hash(), tx.KittyID, tx.From, tx.To); e != nil {
-				return e
-			}
 		}
-		return nil
+		return bc.checkTx(bc.state, bc.head(), tx)
 	})
 
-	return bc.chain.AddTx(*tx, check)
+	if accepted, e := bc.chain.GetTxOfHash(tx.Hash()); e == nil {
+		return &TxAcceptedError{Hash: tx.Hash(), Seq: accepted.Seq}
+	}
+	if e := bc.chain.AddTx(*tx, check); e != nil {
+		return e
+	}
+	bc.saveSnapshot(tx)
+	return nil
+}
+
+// recordRate records an injected transaction against the rate limit of the
+// sender.
+func (bc *BlockChain) recordRate(tx *Transaction) {
+	if bc.rate != nil && !tx.IsKittyGen(bc.c.CreatorPK) {
+		bc.rate.Record(tx.From, time.Now())
+	}
+}
+
+// head obtains the head transaction of the chain (nil if the chain is empty).
+func (bc *BlockChain) head() *Transaction {
+	if tx, e := bc.chain.Head(); e == nil {
+		return &tx
+	}
+	return nil
+}
+
+// checkTx verifies a new transaction against the previous transaction (the
+// head of the chain, or nil for genesis), and applies it to the specified state.
+// The stage that rejects the transaction is reported as a '*TxCheckError'.
+func (bc *BlockChain) checkTx(state StateDB, prev, tx *Transaction) error {
+	return bc.txPipeline(state, prev, true).Check(tx)
+}
+
+// Rollback rolls back the chain and state to right after the transaction at
+// the specified sequence, discarding every transaction at a higher sequence.
+// This is used after fork resolution or to recover from operator error.
+// The state is restored if the chain fails to be truncated, and pending
+// transactions and the rate limits of the discarded transactions are dropped.
+func (bc *BlockChain) Rollback(seq uint64) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if e := bc.rollback(seq); e != nil {
+		return e
+	}
+	if bc.pool != nil {
+		bc.pool.Clear()
+	}
+	if bc.rate != nil {
+		bc.rate.Reset()
+	}
+	bc.log.
+		WithField("seq", seq).
+		Warn("rolled back chain and state")
+	return nil
+}
+
+// rollback rolls back the chain and state to right after the transaction at
+// the specified sequence, and restores the state if either fails.
+func (bc *BlockChain) rollback(seq uint64) error {
+	if seq >= bc.chain.Len() {
+		return fmt.Errorf("block of sequence '%d' does not exist", seq)
+	}
+	raw, e := bc.state.Snapshot()
+	if e != nil {
+		return fmt.Errorf("failed to snapshot state: %v", e)
+	}
+	if e := bc.state.Rollback(seq); e != nil {
+		if e2 := bc.state.LoadSnapshot(raw); e2 != nil {
+			return fmt.Errorf("%v, and failed to restore state: %v", e, e2)
+		}
+		return e
+	}
+	if e := bc.chain.Truncate(seq); e != nil {
+		if e2 := bc.state.LoadSnapshot(raw); e2 != nil {
+			return fmt.Errorf("%v, and failed to restore state: %v", e, e2)
+		}
+		return e
+	}
+	return nil
 }
 
 type PaginatedTransactions struct {
@@ -191,6 +800,16 @@ type PaginatedTransactions struct {
 	Transactions   []Transaction
 }
 
+type PaginatedKitties struct {
+	TotalPageCount uint64
+	Kitties        KittyIDs
+}
+
+type PaginatedAddresses struct {
+	TotalPageCount uint64
+	Addresses      []cipher.Address
+}
+
 // totalPageCount is a helper function for calculating the number of pages given the number of transactions and the number of transactions per page
 func totalPageCount(len, pageSize uint64) uint64 {
 	if len % pageSize == 0 {
@@ -213,3 +832,70 @@ func (bc *BlockChain) GetTransactionPage(currentPage, perPage uint64) (Paginated
 		Transactions: transactions,
 	}, nil
 }
+
+const (
+	DefaultTxRangeSize = 50   // Page size for clients that do not specify one.
+	MaxTxRangeSize     = 1000 // Maximum page size for 'GetTxRange'.
+)
+
+// TxRange is a page of transactions with consecutive sequences (see
+// 'BlockChain.GetTxRange').
+type TxRange struct {
+	Transactions []Transaction
+	HeadSeq      uint64
+	NextSeq      uint64 // Start of the next page, only if 'HasMore'.
+	HasMore      bool
+}
+
+// GetTxRange obtains up to page size transactions from the start sequence, in
+// ascending order, or in descending order (from the start sequence down to
+// 0) if desc. Start sequences past the head start at the head, so that pages in
+// descending order can start from the latest transaction without knowing it.
+func (bc *BlockChain) GetTxRange(startSeq, pageSize uint64, desc bool) (TxRange, error) {
+	if pageSize == 0 || pageSize > MaxTxRangeSize {
+		return TxRange{}, fmt.Errorf("invalid page size '%d', expected 1 to %d", pageSize, MaxTxRangeSize)
+	}
+
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	n := bc.chain.Len()
+	if n == 0 {
+		return TxRange{Transactions: []Transaction{}}, nil
+	}
+	out := TxRange{HeadSeq: n - 1}
+	if !desc {
+		if startSeq >= n {
+			out.Transactions = []Transaction{}
+			return out, nil
+		}
+		txs, e := bc.chain.GetTxsOfSeqRange(startSeq, pageSize)
+		if e != nil {
+			return TxRange{}, e
+		}
+		out.Transactions = txs
+		if end := startSeq + uint64(len(txs)); end < n {
+			out.NextSeq, out.HasMore = end, true
+		}
+		return out, nil
+	}
+	if startSeq > out.HeadSeq {
+		startSeq = out.HeadSeq
+	}
+	from := uint64(0)
+	if startSeq+1 > pageSize {
+		from = startSeq + 1 - pageSize
+	}
+	txs, e := bc.chain.GetTxsOfSeqRange(from, startSeq-from+1)
+	if e != nil {
+		return TxRange{}, e
+	}
+	for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+		txs[i], txs[j] = txs[j], txs[i]
+	}
+	out.Transactions = txs
+	if from > 0 {
+		out.NextSeq, out.HasMore = from-1, true
+	}
+	return out, nil
+}
@@ -1,11 +1,17 @@
 package iko
 
 import (
-	"testing"
+	"crypto/ed25519"
+	"errors"
+	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/stretchr/testify/require"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
 )
 
-func TesttotalPageCount(t *testing.T) {
+func TestTotalPageCount(t *testing.T) {
 	require.Equal(t, totalPageCount(1, 2), uint64(1),
 		"One item, two items per page, equals one page")
 	require.Equal(t, totalPageCount(0, 2), uint64(0),
@@ -15,3 +21,1226 @@ func TesttotalPageCount(t *testing.T) {
 	require.Equal(t, totalPageCount(4, 2), uint64(2),
 		"Four items, two items per page, equals two pages")
 }
+
+// testSecKey is the key of the creator of the blockchains in the tests, and
+// testSecKey2 the key of another owner.
+var (
+	testSecKey = cipher.SecKey([32]byte{
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+	})
+	testSecKey2 = cipher.SecKey([32]byte{
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+	})
+)
+
+// newTestBlockChain creates a blockchain in memory, whose creator is
+// 'testSecKey'.
+func newTestBlockChain(t *testing.T, config BlockChainConfig) *BlockChain {
+	config.CreatorPK = cipher.PubKeyFromSecKey(testSecKey)
+	bc, err := NewBlockChain(&config, NewMemoryChain(10), NewMemoryState())
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	return bc
+}
+
+func TestBlockChain_GetKittyHistory(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	sk2 := testSecKey2
+	ownerAddress := cipher.AddressFromSecKey(sk2)
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	kID := KittyID(1)
+
+	t.Run("GetKittyHistory_NoSuchKitty", func(t *testing.T) {
+		_, err := bc.GetKittyHistory(kID)
+		require.NotNil(t, err, "Kitty doesn't exist yet")
+	})
+
+	genTx := NewGenTx(nil, kID, sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+	transferTx := NewTransferTx(genTx, kID, ownerAddress, bc.NextNonce(creatorAddress), sk)
+	transferTx.SetMemo("order #1", sk)
+	require.Nil(t, bc.InjectTx(transferTx), "Injecting the transfer tx should succeed")
+
+	t.Run("GetKittyHistory_Success", func(t *testing.T) {
+		history, err := bc.GetKittyHistory(kID)
+		require.Nil(t, err, "Kitty should have a history")
+		require.Equal(t, []KittyTransition{
+			{Owner: creatorAddress, TxHash: genTx.Hash(), Seq: 0},
+			{Owner: ownerAddress, TxHash: transferTx.Hash(), Seq: 1, Memo: "order #1"},
+		}, history, "History should be ordered from creation to latest transfer")
+	})
+}
+
+func TestBlockChain_InitState_Snapshot(t *testing.T) {
+	sk := testSecKey
+
+	config := &BlockChainConfig{
+		CreatorPK:        cipher.PubKeyFromSecKey(sk),
+		SnapshotDB:       NewMemorySnapshotDB(),
+		SnapshotInterval: 2,
+	}
+	chainDB := NewMemoryChain(10)
+
+	bc, err := NewBlockChain(config, chainDB, NewMemoryState())
+	require.Nil(t, err, "We should be able to create a BlockChain")
+
+	var tx *Transaction
+	for i := 0; i < 3; i++ {
+		tx = NewGenTx(tx, KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(tx), "Injecting gen tx should succeed")
+	}
+	bc.Close()
+
+	snap, ok, err := config.SnapshotDB.Nearest(chainDB.HeadSeq())
+	require.Nil(t, err, "Shouldn't have an error")
+	require.True(t, ok, "A snapshot should be taken after the second tx")
+	require.Equal(t, uint64(1), snap.Seq, "Snapshot should be at the second tx")
+
+	bc, err = NewBlockChain(config, chainDB, NewMemoryState())
+	require.Nil(t, err, "We should be able to restart the BlockChain from a snapshot")
+	defer bc.Close()
+
+	for i := 0; i < 3; i++ {
+		_, ok := bc.GetKittyState(KittyID(i))
+		require.True(t, ok, "Every kitty should exist in the restored state")
+	}
+	require.Equal(t, uint64(3), bc.CountOfAddress(cipher.AddressFromSecKey(sk)),
+		"Creator should own every kitty")
+}
+
+func TestBlockChain_VerifyState(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	stateDB := NewMemoryState()
+	bc, err := NewBlockChain(
+		&BlockChainConfig{CreatorPK: cipher.PubKeyFromSecKey(sk)},
+		NewMemoryChain(10),
+		stateDB,
+	)
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	defer bc.Close()
+
+	var tx *Transaction
+	for i := 0; i < 2; i++ {
+		tx = NewGenTx(tx, KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(tx), "Injecting gen tx should succeed")
+	}
+
+	t.Run("VerifyState_Match", func(t *testing.T) {
+		mismatches, err := bc.VerifyState()
+		require.Nil(t, err, "Verifying state should succeed")
+		require.Len(t, mismatches, 0, "State should match the chain")
+	})
+
+	t.Run("VerifyState_Mismatch", func(t *testing.T) {
+		err := stateDB.AddKitty(TxRef{Seq: 2}, KittyID(5), creatorAddress)
+		require.Nil(t, err, "Tampering with the state should succeed")
+
+		mismatches, err := bc.VerifyState()
+		require.Nil(t, err, "Verifying state should succeed")
+		require.Len(t, mismatches, 1, "The tampered kitty should be reported")
+		require.Equal(t, KittyID(5), mismatches[0].KittyID, "The tampered kitty should be reported")
+		require.Nil(t, mismatches[0].Expected, "The tampered kitty should not exist")
+		require.NotNil(t, mismatches[0].Actual, "The tampered kitty exists in live state")
+	})
+}
+
+func TestBlockChain_WatchAddresses(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	sk2 := testSecKey2
+	ownerAddress := cipher.AddressFromSecKey(sk2)
+
+	bc, err := NewBlockChain(
+		&BlockChainConfig{CreatorPK: cipher.PubKeyFromSecKey(sk)},
+		NewMemoryChain(10),
+		NewMemoryState(),
+	)
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	defer bc.Close()
+
+	watch := bc.WatchAddresses([]cipher.Address{creatorAddress, ownerAddress}, 10)
+	defer watch.Close()
+
+	kID := KittyID(1)
+	genTx := NewGenTx(nil, kID, sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+	transferTx := NewTransferTx(genTx, kID, ownerAddress, bc.NextNonce(creatorAddress), sk)
+	transferTx.SetMemo("order #1", sk)
+	require.Nil(t, bc.InjectTx(transferTx), "Injecting the transfer tx should succeed")
+
+	expected := []WatchEvent{
+		{Address: creatorAddress, KittyID: kID, Gained: true, Tx: genTx.Ref()},
+		{Address: creatorAddress, KittyID: kID, Gained: false, Tx: transferTx.Ref()},
+		{Address: ownerAddress, KittyID: kID, Gained: true, Tx: transferTx.Ref()},
+	}
+	for _, event := range expected {
+		select {
+		case got := <-watch.C():
+			require.Equal(t, event, got, "Events should be received in order")
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for watch event")
+		}
+	}
+
+	// Closing a watch that is no longer read drops the event that it is
+	// blocked on, so that the channel is closed.
+	stuck := bc.WatchAddresses([]cipher.Address{creatorAddress}, 1)
+	genTx2 := NewGenTx(transferTx, KittyID(2), sk)
+	require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, bc.InjectTx(NewGenTx(genTx2, KittyID(3), sk)), "Injecting the gen tx should succeed")
+	time.Sleep(10 * time.Millisecond)
+	stuck.Close()
+
+	events := 0
+	for range stuck.C() {
+		events++
+	}
+	require.Equal(t, 1, events, "Only the buffered event should be received")
+}
+
+func TestBlockChain_MultiTransfer(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	var tx *Transaction
+	for i := 0; i < 3; i++ {
+		tx = NewGenTx(tx, KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(tx), "Injecting gen tx should succeed")
+	}
+
+	t.Run("InjectTx_NotOwned", func(t *testing.T) {
+		badTx := NewMultiTransferTx(tx, KittyIDs{0, 1, 5}, ownerAddress, 1, sk)
+		require.NotNil(t, bc.InjectTx(badTx), "Transferring a kitty that does not exist should fail")
+		require.Equal(t, uint64(3), bc.CountOfAddress(creatorAddress),
+			"None of the kitties should be transferred")
+	})
+
+	t.Run("InjectTx_Expired", func(t *testing.T) {
+		expiredTx := NewMultiTransferTx(tx, KittyIDs{0}, ownerAddress, 1, sk)
+		expiredTx.SetExpiry(expiredTx.TS+1, sk)
+		time.Sleep(time.Millisecond)
+
+		var expiredErr *TxExpiredError
+		require.True(t, errors.As(bc.InjectTx(expiredTx), &expiredErr), "Injecting an expired tx should fail")
+	})
+
+	t.Run("InjectTx_Success", func(t *testing.T) {
+		multiTx := NewMultiTransferTx(tx, KittyIDs{0, 2}, ownerAddress, bc.NextNonce(creatorAddress), sk)
+		require.Nil(t, bc.InjectTx(multiTx), "Injecting the multi transfer tx should succeed")
+		require.Equal(t, uint64(2), bc.NextNonce(creatorAddress),
+			"A multi transfer should count as a single transaction")
+
+		replayTx := NewMultiTransferTx(multiTx, KittyIDs{1}, ownerAddress, 1, sk)
+		require.NotNil(t, bc.InjectTx(replayTx), "Reusing a nonce should fail")
+
+		require.Equal(t, KittyIDs{0, 2}, bc.GetAddressState(ownerAddress).Kitties,
+			"Every kitty in the transaction should be transferred")
+		require.Equal(t, KittyIDs{1}, bc.GetAddressState(creatorAddress).Kitties,
+			"Kitties not in the transaction should remain")
+	})
+}
+
+func TestBlockChain_Multisig(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	account, err := NewMultisigAccount(2, []cipher.PubKey{
+		cipher.PubKeyFromSecKey(sk),
+		cipher.PubKeyFromSecKey(sk2),
+	})
+	require.Nil(t, err, "A 2-of-2 account should succeed")
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+	depositTx := NewTransferTx(genTx, KittyID(1), account.Address(), bc.NextNonce(creatorAddress), sk)
+	require.Nil(t, bc.InjectTx(depositTx), "Transferring to a multisig address should succeed")
+
+	withdrawTx := NewMultisigTransferTx(depositTx, KittyIDs{1}, account, creatorAddress)
+	withdrawTx.Nonce = bc.NextNonce(account.Address())
+	withdrawTx.MultiSign(sk)
+	require.NotNil(t, bc.InjectTx(withdrawTx), "Transferring below the threshold should fail")
+
+	withdrawTx.MultiSign(sk2)
+	require.Nil(t, bc.InjectTx(withdrawTx), "Transferring with the threshold of signatures should succeed")
+
+	kState, ok := bc.GetKittyState(KittyID(1))
+	require.True(t, ok, "Kitty should exist")
+	require.Equal(t, creatorAddress, kState.Address, "Kitty should be transferred out of the multisig address")
+}
+
+func TestBlockChain_SimulateTx(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+	t.Run("SimulateTx_Invalid", func(t *testing.T) {
+		badTx := NewTransferTx(genTx, KittyID(2), ownerAddress, 1, sk)
+		_, err := bc.SimulateTx(badTx)
+		require.NotNil(t, err, "Simulating the transfer of a kitty that does not exist should fail")
+	})
+
+	t.Run("SimulateTx_Success", func(t *testing.T) {
+		transferTx := NewTransferTx(genTx, KittyID(1), ownerAddress, 1, sk)
+
+		diffs, err := bc.SimulateTx(transferTx)
+		require.Nil(t, err, "Simulating a valid transfer should succeed")
+		require.Equal(t, []KittyDiff{
+			{KittyID: KittyID(1), From: creatorAddress, To: ownerAddress},
+		}, diffs, "Simulation should have the resulting ownership")
+
+		kState, _ := bc.GetKittyState(KittyID(1))
+		require.Equal(t, creatorAddress, kState.Address, "Simulating should not change the state")
+		require.Equal(t, uint64(1), bc.NextNonce(creatorAddress), "Simulating should not change the nonce")
+
+		require.Nil(t, bc.InjectTx(transferTx), "The simulated transfer should still be injectable")
+	})
+}
+
+func TestBlockChain_Mempool(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	creatorAddress := cipher.AddressFromSecKey(sk)
+
+	bc := newTestBlockChain(t, BlockChainConfig{
+		MempoolSize: 2,
+	})
+	defer bc.Close()
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		genTx1 := NewGenTx(nil, KittyID(1), sk)
+		genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+
+		pending, err := bc.SubmitTx(genTx2)
+		require.Nil(t, err, "Submitting a tx with a future seq should succeed")
+		require.True(t, pending, "Tx with a future seq should be pending")
+		require.Len(t, bc.PendingTxs(), 1, "Mempool should have the tx")
+
+		pending, err = bc.SubmitTx(genTx1)
+		require.Nil(t, err, "Submitting the prerequisite tx should succeed")
+		require.False(t, pending, "Prerequisite tx should be injected")
+		require.Len(t, bc.PendingTxs(), 0, "Pending tx should be promoted")
+
+		_, ok := bc.GetKittyState(KittyID(2))
+		require.True(t, ok, "Kitty from the promoted tx should exist")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		head, err := bc.GetHeadTx()
+		require.Nil(t, err, "Head tx should exist")
+
+		tx := NewGenTx(&head, KittyID(3), sk)
+		tx.Sig = cipher.Sig{}
+		_, err = bc.SubmitTx(tx)
+		require.NotNil(t, err, "Submitting a tx with an invalid signature should fail")
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		head, err := bc.GetHeadTx()
+		require.Nil(t, err, "Head tx should exist")
+
+		tx1 := NewGenTx(&head, KittyID(3), sk)
+		tx2 := NewGenTx(tx1, KittyID(4), sk)
+		tx3 := NewGenTx(tx2, KittyID(5), sk)
+		tx4 := NewGenTx(tx3, KittyID(6), sk)
+
+		for _, tx := range []*Transaction{tx2, tx3} {
+			_, err := bc.SubmitTx(tx)
+			require.Nil(t, err, "Submitting within the mempool size should succeed")
+		}
+		_, err = bc.SubmitTx(tx4)
+		require.NotNil(t, err, "Submitting to a full mempool should fail")
+
+		_, err = bc.SubmitTx(tx1)
+		require.Nil(t, err, "Submitting the prerequisite tx should succeed")
+		require.Len(t, bc.PendingTxs(), 0, "Pending txs should be promoted")
+	})
+
+	t.Run("CoSignature", func(t *testing.T) {
+		account, err := NewMultisigAccount(2, []cipher.PubKey{
+			cipher.PubKeyFromSecKey(sk),
+			cipher.PubKeyFromSecKey(sk2),
+		})
+		require.Nil(t, err, "A 2-of-2 account should succeed")
+
+		head, err := bc.GetHeadTx()
+		require.Nil(t, err, "Head tx should exist")
+
+		depositTx := NewTransferTx(&head, KittyID(1), account.Address(), bc.NextNonce(creatorAddress), sk)
+		require.Nil(t, bc.InjectTx(depositTx), "Transferring to a multisig address should succeed")
+
+		withdrawTx := NewMultisigTransferTx(depositTx, KittyIDs{1}, account, creatorAddress)
+		withdrawTx.Nonce = bc.NextNonce(account.Address())
+		coSignedTx := *withdrawTx
+
+		withdrawTx.MultiSign(sk2)
+		pending, err := bc.SubmitTx(withdrawTx)
+		require.Nil(t, err, "Submitting a partially signed tx should succeed")
+		require.True(t, pending, "Partially signed tx should be pending")
+
+		coSignedTx.MultiSign(sk)
+		pending, err = bc.SubmitTx(&coSignedTx)
+		require.Nil(t, err, "Submitting the co-signature should succeed")
+		require.False(t, pending, "Co-signed tx should be injected")
+
+		kState, ok := bc.GetKittyState(KittyID(1))
+		require.True(t, ok, "Kitty should exist")
+		require.Equal(t, creatorAddress, kState.Address, "Kitty should be transferred out of the multisig address")
+	})
+}
+
+func TestBlockChain_TransferFee(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	ownerAddress := cipher.AddressFromSecKey(sk2)
+
+	bc := newTestBlockChain(t, BlockChainConfig{
+		TransferFee: 5,
+	})
+	defer bc.Close()
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+	t.Run("InvalidFee", func(t *testing.T) {
+		tx := NewTransferTx(genTx, KittyID(1), ownerAddress, bc.NextNonce(creatorAddress), sk)
+		require.NotNil(t, bc.InjectTx(tx), "Transfers without the fee should fail")
+
+		tx.SetFee(4, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Transfers with another fee should fail")
+
+		tx.Fee = 5
+		tx.Version = TxVersionLegacy
+		tx.Sig = tx.Sign(sk)
+		require.NotNil(t, bc.InjectTx(tx), "Only the extended version should pay a fee")
+
+		gen2Tx := NewGenTx(genTx, KittyID(2), sk)
+		gen2Tx.SetFee(5, sk)
+		require.NotNil(t, bc.InjectTx(gen2Tx), "Gen txs should not pay a fee")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		tx := NewTransferTx(genTx, KittyID(1), ownerAddress, bc.NextNonce(creatorAddress), sk)
+		tx.SetFee(5, sk)
+		require.Equal(t, TxVersionExtended, tx.Version, "Paying a fee should change the version")
+		require.Nil(t, bc.InjectTx(tx), "Transfers with the fee should succeed")
+
+		backTx := NewTransferTx(tx, KittyID(1), creatorAddress, bc.NextNonce(ownerAddress), sk2)
+		backTx.SetFee(5, sk2)
+		require.Nil(t, bc.InjectTx(backTx), "Transfers with the fee should succeed")
+
+		require.Equal(t, uint64(5), bc.GetAddressState(ownerAddress).FeesPaid,
+			"Fees paid should be accounted")
+		require.Equal(t, uint64(10), bc.GetAddressState(creatorAddress).FeesReceived,
+			"Fees should be received by the master address")
+
+		decoded, err := DecodeTx(backTx.Serialize())
+		require.Nil(t, err, "Decoding a fee tx should succeed")
+		require.Equal(t, uint64(5), decoded.Fee, "Fee should be encoded")
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		require.Nil(t, bc.state.Rollback(1), "Rollback should succeed")
+		require.Equal(t, uint64(0), bc.GetAddressState(ownerAddress).FeesPaid,
+			"Fees paid should be rolled back")
+		require.Equal(t, uint64(5), bc.GetAddressState(creatorAddress).FeesReceived,
+			"Fees received should be rolled back")
+	})
+}
+
+func TestBlockChain_LegacyTransfers(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+	config := &BlockChainConfig{CreatorPK: cipher.PubKeyFromSecKey(sk)}
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	legacyTx := NewTransferTx(genTx, KittyID(1), ownerAddress, 0, sk)
+	require.Equal(t, TxVersionLegacy, legacyTx.Version, "Transfers without a nonce should be legacy")
+
+	t.Run("Live", func(t *testing.T) {
+		bc, err := NewBlockChain(config, NewMemoryChain(10), NewMemoryState())
+		require.Nil(t, err, "We should be able to create a BlockChain")
+		defer bc.Close()
+
+		require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+		var checkErr *TxCheckError
+		require.True(t, errors.As(bc.InjectTx(legacyTx), &checkErr), "New legacy transfers should be rejected")
+		require.Equal(t, TxCheckNonce, checkErr.Stage, "New legacy transfers should need a nonce")
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		chain := NewMemoryChain(10)
+		approve := func(*Transaction) error { return nil }
+		require.Nil(t, chain.AddTx(*genTx, approve))
+		require.Nil(t, chain.AddTx(*legacyTx, approve))
+
+		bc, err := NewBlockChain(config, chain, NewMemoryState())
+		require.Nil(t, err, "Chains with legacy transfers should be replayed")
+		defer bc.Close()
+
+		kState, ok := bc.GetKittyState(KittyID(1))
+		require.True(t, ok, "Kitty should exist")
+		require.Equal(t, ownerAddress, kState.Address, "Legacy transfers should be applied")
+		require.Equal(t, uint64(2), bc.NextNonce(creatorAddress), "Legacy transfers should be counted")
+	})
+}
+
+func TestBlockChain_TxPipeline(t *testing.T) {
+	sk := testSecKey
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+
+	newBlockChain := func(disabled ...string) (*BlockChain, error) {
+		return NewBlockChain(
+			&BlockChainConfig{
+				CreatorPK:        cipher.PubKeyFromSecKey(sk),
+				TransferFee:      5,
+				DisabledTxChecks: disabled,
+			},
+			NewMemoryChain(10),
+			NewMemoryState(),
+		)
+	}
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		_, err := newBlockChain("unknown")
+		require.NotNil(t, err, "Disabling an unknown check should fail")
+		for _, name := range []string{TxCheckLink, TxCheckSig, TxCheckKind, TxCheckNonce, TxCheckOwnership} {
+			_, err = newBlockChain(name)
+			require.NotNil(t, err, "Disabling the %s check should fail", name)
+		}
+	})
+
+	t.Run("Stage", func(t *testing.T) {
+		bc, err := newBlockChain()
+		require.Nil(t, err, "We should be able to create a BlockChain")
+		defer bc.Close()
+
+		genTx := NewGenTx(nil, KittyID(1), sk)
+		require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+		var checkErr *TxCheckError
+		tx := NewTransferTx(genTx, KittyID(1), ownerAddress, 2, sk)
+		require.True(t, errors.As(bc.InjectTx(tx), &checkErr), "Invalid txs should be rejected by a stage")
+		require.Equal(t, TxCheckNonce, checkErr.Stage, "Stage of the invalid nonce should be reported")
+
+		tx.SetNonce(1, sk)
+		require.True(t, errors.As(bc.InjectTx(tx), &checkErr), "Invalid txs should be rejected by a stage")
+		require.Equal(t, TxCheckFee, checkErr.Stage, "Stage of the invalid fee should be reported")
+
+		tx.Sig = cipher.Sig{}
+		require.True(t, errors.As(bc.InjectTx(tx), &checkErr), "Invalid txs should be rejected by a stage")
+		require.Equal(t, TxCheckSig, checkErr.Stage, "Stage of the invalid sig should be reported")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		bc, err := newBlockChain(TxCheckFee)
+		require.Nil(t, err, "We should be able to create a BlockChain")
+		defer bc.Close()
+
+		genTx := NewGenTx(nil, KittyID(1), sk)
+		require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+		tx := NewTransferTx(genTx, KittyID(1), ownerAddress, 1, sk)
+		require.Nil(t, bc.InjectTx(tx), "Transfers should not be checked by a disabled stage")
+		kState, ok := bc.GetKittyState(KittyID(1))
+		require.True(t, ok, "Kitty should exist")
+		require.Equal(t, ownerAddress, kState.Address, "Kitty should be transferred")
+	})
+}
+
+func TestBlockChain_TxRateLimit(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+
+	bc := newTestBlockChain(t, BlockChainConfig{
+		TxRateLimit:  2,
+		TxRateWindow: time.Hour,
+	})
+	defer bc.Close()
+
+	var prev *Transaction
+	for i := 0; i < 3; i++ {
+		tx := NewGenTx(prev, KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(tx), "Gen txs should not be rate limited")
+		prev = tx
+	}
+
+	for i := 0; i < 2; i++ {
+		tx := NewTransferTx(prev, KittyID(i), ownerAddress, bc.NextNonce(creatorAddress), sk)
+		require.Nil(t, bc.InjectTx(tx), "Transfers within the rate limit should succeed")
+		prev = tx
+	}
+
+	tx := NewTransferTx(prev, KittyID(2), ownerAddress, bc.NextNonce(creatorAddress), sk)
+	var checkErr *TxCheckError
+	require.True(t, errors.As(bc.InjectTx(tx), &checkErr), "Transfers over the rate limit should fail")
+	require.Equal(t, TxCheckRate, checkErr.Stage, "Stage of the rate limit should be reported")
+
+	limiter := NewRateLimiter(1, time.Minute)
+	now := time.Now()
+	limiter.Record(ownerAddress, now)
+	require.NotNil(t, limiter.Allow(ownerAddress, 1, now), "Address should be limited within the window")
+	require.Nil(t, limiter.Allow(ownerAddress, 1, now.Add(time.Minute)), "Address should not be limited after the window")
+	require.Nil(t, limiter.Allow(creatorAddress, 1, now), "Other addresses should not be limited")
+}
+
+// truncateFailChain is a chain that fails to be truncated while 'fail' is set.
+type truncateFailChain struct {
+	*MemoryChain
+	fail *atomic.Bool
+}
+
+func (c truncateFailChain) Truncate(seq uint64) error {
+	if c.fail.Load() {
+		return errors.New("truncate failed")
+	}
+	return c.MemoryChain.Truncate(seq)
+}
+
+func TestBlockChain_Rollback(t *testing.T) {
+	sk := testSecKey
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	ownerAddress := cipher.AddressFromSecKey(testSecKey2)
+
+	chain := truncateFailChain{MemoryChain: NewMemoryChain(10), fail: new(atomic.Bool)}
+	chain.fail.Store(true)
+	bc, err := NewBlockChain(
+		&BlockChainConfig{
+			CreatorPK:    cipher.PubKeyFromSecKey(sk),
+			MempoolSize:  2,
+			TxRateLimit:  1,
+			TxRateWindow: time.Hour,
+		},
+		chain,
+		NewMemoryState(),
+	)
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	defer bc.Close()
+
+	genTx1 := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx1), "Injecting the gen tx should succeed")
+	genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+	require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+	sendTx := NewTransferTx(genTx2, KittyID(1), ownerAddress, 1, sk)
+	require.Nil(t, bc.InjectTx(sendTx), "Transferring the kitty should succeed")
+
+	pending, err := bc.SubmitTx(NewGenTx(NewGenTx(sendTx, KittyID(3), sk), KittyID(4), sk))
+	require.Nil(t, err, "Submitting a tx with a future seq should succeed")
+	require.True(t, pending, "Tx with a future seq should be pending")
+
+	t.Run("TruncateFailed", func(t *testing.T) {
+		require.NotNil(t, bc.Rollback(genTx2.Seq), "Rollback should fail if the chain is not truncated")
+		require.Equal(t, uint64(1), bc.CountOfAddress(ownerAddress), "State should be restored")
+		require.Equal(t, uint64(2), bc.NextNonce(creatorAddress), "Nonces should be restored")
+		require.Len(t, bc.PendingTxs(), 1, "Pending txs should be kept")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		chain.fail.Store(false)
+		require.Nil(t, bc.Rollback(genTx2.Seq), "Rollback should succeed")
+		require.Equal(t, genTx2.Seq, bc.chain.HeadSeq(), "Chain should be truncated")
+		require.Equal(t, uint64(0), bc.CountOfAddress(ownerAddress), "State should be rolled back")
+		require.Len(t, bc.PendingTxs(), 0, "Pending txs should be dropped")
+
+		tx := NewTransferTx(genTx2, KittyID(2), ownerAddress, 1, sk)
+		require.Nil(t, bc.InjectTx(tx), "Rate limits for discarded txs should be dropped")
+	})
+}
+
+func TestBlockChain_TxHooks(t *testing.T) {
+	sk := testSecKey
+
+	var ran []string
+	hook := func(name string, order int, e error) TxHook {
+		return TxHook{
+			Name:   name,
+			Order:  order,
+			Policy: TxHookContinue,
+			Action: func(tx *Transaction) error {
+				ran = append(ran, name)
+				return e
+			},
+		}
+	}
+
+	bc, err := NewBlockChain(
+		&BlockChainConfig{
+			CreatorPK: cipher.PubKeyFromSecKey(sk),
+			TxHooks: []TxHook{
+				hook("metrics", 2, nil),
+				hook("webhook", 1, errors.New("webhook is down")),
+			},
+		},
+		NewMemoryChain(10),
+		NewMemoryState(),
+	)
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	defer bc.Close()
+
+	require.Nil(t, bc.AddTxHook(hook("indexer", 1, nil)), "Adding a hook should succeed")
+	require.NotNil(t, bc.AddTxHook(hook("indexer", 3, nil)), "Adding a hook with an existing name should fail")
+	require.NotNil(t, bc.AddTxHook(TxHook{Name: "empty"}), "Adding a hook without an action should fail")
+
+	sub := bc.Subscribe(1)
+	defer sub.Close()
+
+	require.Nil(t, bc.InjectTx(NewGenTx(nil, KittyID(1), sk)), "Injecting the gen tx should succeed")
+	select {
+	case <-sub.C():
+	case <-time.After(time.Second):
+		t.Fatal("Tx should be broadcast after the hooks")
+	}
+	require.Equal(t, []string{"webhook", "indexer", "metrics"}, ran,
+		"Hooks should run in order, and continue after a failing hook")
+
+	require.True(t, bc.RemoveTxHook("webhook"), "Removing a hook should succeed")
+	require.False(t, bc.RemoveTxHook("webhook"), "Removing a removed hook should fail")
+}
+
+func TestBlockChain_InjectTxErrors(t *testing.T) {
+	sk := testSecKey
+	otherSK := testSecKey2
+	otherAddress := cipher.AddressFromSecKey(otherSK)
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+	require.True(t, errors.Is(bc.InjectTx(genTx), ErrDuplicateTx),
+		"Injecting a tx twice should be a duplicate")
+
+	var accepted *TxAcceptedError
+	require.True(t, errors.As(bc.InjectTx(genTx), &accepted),
+		"Injecting a tx twice should report that it is accepted")
+	require.Equal(t, genTx.Hash(), accepted.Hash, "Hash of the accepted tx should be reported")
+	require.Equal(t, uint64(0), accepted.Seq, "Seq of the accepted tx should be reported")
+
+	tx := NewTransferTx(genTx, KittyID(1), otherAddress, 1, sk)
+	tx.Sig = tx.Sign(otherSK)
+	require.True(t, errors.Is(bc.InjectTx(tx), ErrBadSignature),
+		"Transfers not signed by the sender should fail with a bad signature")
+
+	tx = NewTransferTx(genTx, KittyID(1), cipher.AddressFromSecKey(sk), 1, otherSK)
+	require.True(t, errors.Is(bc.InjectTx(tx), ErrNotOwner),
+		"Transfers of kitties owned by another address should fail as not the owner")
+
+	tx = NewTransferTx(genTx, KittyID(2), otherAddress, 1, sk)
+	require.True(t, errors.Is(bc.InjectTx(tx), ErrKittyUnknown),
+		"Transfers of kitties that do not exist should fail with an unknown kitty")
+
+	tx = NewTransferTx(genTx, KittyID(1), otherAddress, 1, sk)
+	tx.SetExpiry(tx.TS+1, sk)
+	time.Sleep(time.Millisecond)
+	require.True(t, errors.Is(bc.InjectTx(tx), ErrExpired),
+		"Expired transfers should be expired")
+}
+
+// addFailChain is a chain that fails to add the transaction at a sequence.
+type addFailChain struct {
+	*MemoryChain
+	seq uint64
+}
+
+func (c addFailChain) AddTx(tx Transaction, check TxChecker) error {
+	if tx.Seq == c.seq {
+		return errors.New("add failed")
+	}
+	return c.MemoryChain.AddTx(tx, check)
+}
+
+func TestBlockChain_InjectTxGroup(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	ownerAddress := cipher.AddressFromSecKey(sk2)
+
+	genTx1 := NewGenTx(nil, KittyID(1), sk)
+	genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+	giveTx := NewTransferTx(genTx2, KittyID(2), ownerAddress, 1, sk)
+
+	// newGroupBlockChain creates a BlockChain on the chain, where kitty 1 is
+	// owned by the creator, and kitty 2 is owned by the owner. The creator
+	// has one transfer left within the rate limit.
+	newGroupBlockChain := func(chain ChainDB) *BlockChain {
+		bc, err := NewBlockChain(
+			&BlockChainConfig{CreatorPK: cipher.PubKeyFromSecKey(sk), TxRateLimit: 2},
+			chain,
+			NewMemoryState(),
+		)
+		require.Nil(t, err, "We should be able to create a BlockChain")
+		require.Nil(t, bc.InjectTx(genTx1), "Injecting the gen tx should succeed")
+		require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+		require.Nil(t, bc.InjectTx(giveTx), "Transferring to the owner should succeed")
+		return bc
+	}
+	bc := newGroupBlockChain(NewMemoryChain(10))
+	defer bc.Close()
+
+	// newSwap creates the txs where kitty 1 goes to the owner, and kitty 2
+	// goes to the creator.
+	newSwap := func(bc *BlockChain, ownerNonce uint64) (*Transaction, *Transaction) {
+		creatorNonce := bc.NextNonce(creatorAddress)
+		group := GroupHash([]TxIntent{
+			{Kitties: KittyIDs{1}, Nonce: creatorNonce, From: creatorAddress, To: ownerAddress},
+			{Kitties: KittyIDs{2}, Nonce: ownerNonce, From: ownerAddress, To: creatorAddress},
+		})
+		head, err := bc.GetHeadTx()
+		require.Nil(t, err, "Head tx should exist")
+
+		tx1 := NewTransferTx(&head, KittyID(1), ownerAddress, creatorNonce, sk)
+		tx1.SetGroup(group, sk)
+
+		tx2 := NewTransferTx(tx1, KittyID(2), creatorAddress, ownerNonce, sk2)
+		tx2.SetGroup(group, sk2)
+		return tx1, tx2
+	}
+
+	t.Run("Alone", func(t *testing.T) {
+		tx1, _ := newSwap(bc, bc.NextNonce(ownerAddress))
+		require.NotNil(t, bc.InjectTx(tx1), "Injecting a grouped tx alone should fail")
+		_, err := bc.SubmitTx(tx1)
+		require.NotNil(t, err, "Submitting a grouped tx alone should fail")
+		require.NotNil(t, bc.InjectTxGroup([]*Transaction{tx1}), "Injecting an incomplete group should fail")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		headSeq := bc.chain.HeadSeq()
+		tx1, tx2 := newSwap(bc, bc.NextNonce(ownerAddress)+1)
+		require.NotNil(t, bc.InjectTxGroup([]*Transaction{tx1, tx2}),
+			"Injecting a group with an invalid tx should fail")
+		require.Equal(t, headSeq, bc.chain.HeadSeq(), "No tx in the group should be injected")
+
+		kState, _ := bc.GetKittyState(KittyID(1))
+		require.Equal(t, creatorAddress, kState.Address, "Kitty should not be transferred")
+	})
+
+	t.Run("Undone", func(t *testing.T) {
+		chain := NewMemoryChain(10)
+		bc := newGroupBlockChain(addFailChain{MemoryChain: chain, seq: giveTx.Seq + 2})
+		defer bc.Close()
+
+		headSeq := chain.HeadSeq()
+		ownerNonce := bc.NextNonce(ownerAddress)
+		tx1, tx2 := newSwap(bc, ownerNonce)
+
+		require.NotNil(t, bc.InjectTxGroup([]*Transaction{tx1, tx2}),
+			"Injecting a group should fail if the chain fails to add a tx")
+		require.Equal(t, headSeq, bc.chain.HeadSeq(), "Injected txs in the group should be undone")
+		kState, _ := bc.GetKittyState(KittyID(1))
+		require.Equal(t, creatorAddress, kState.Address, "Kitty should not be transferred")
+		require.Equal(t, ownerNonce, bc.NextNonce(ownerAddress), "Nonces should not be used")
+		require.Nil(t, bc.rate.Allow(creatorAddress, 1, time.Now()), "Rate limits should not be used")
+	})
+
+	t.Run("Swap", func(t *testing.T) {
+		tx1, tx2 := newSwap(bc, bc.NextNonce(ownerAddress))
+		require.NotNil(t, bc.InjectTxGroup([]*Transaction{tx2, tx1}),
+			"Injecting a group out of order should fail")
+		require.Nil(t, bc.InjectTxGroup([]*Transaction{tx1, tx2}),
+			"Injecting the swap should succeed")
+
+		kState, _ := bc.GetKittyState(KittyID(1))
+		require.Equal(t, ownerAddress, kState.Address, "Kitty 1 should be swapped")
+		kState, _ = bc.GetKittyState(KittyID(2))
+		require.Equal(t, creatorAddress, kState.Address, "Kitty 2 should be swapped")
+
+		decoded, err := DecodeTx(tx2.Serialize())
+		require.Nil(t, err, "Decoding a grouped tx should succeed")
+		require.Equal(t, tx2.Group, decoded.Group, "Group should be encoded")
+	})
+}
+
+func TestBlockChain_DelegatedTransfer(t *testing.T) {
+	sk := testSecKey
+	operatorSK := testSecKey2
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	buyerAddress := cipher.AddressFromSecKey(operatorSK)
+
+	bc, err := NewBlockChain(
+		&BlockChainConfig{CreatorPK: cipher.PubKeyFromSecKey(sk)},
+		NewMemoryChain(10),
+		NewMemoryState(),
+	)
+	require.Nil(t, err, "We should be able to create a BlockChain")
+	defer bc.Close()
+
+	genTx1 := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx1), "Injecting the gen tx should succeed")
+	genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+	require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+
+	d := Delegation{
+		KittyID:  KittyID(1),
+		Nonce:    bc.NextNonce(creatorAddress),
+		Operator: cipher.PubKeyFromSecKey(operatorSK),
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		tx := NewDelegatedTransferTx(genTx2, d.Sign(operatorSK), creatorAddress, buyerAddress, operatorSK)
+		require.NotNil(t, bc.InjectTx(tx), "Delegations not signed by the owner should fail")
+
+		tx = NewDelegatedTransferTx(genTx2, d.Sign(sk), creatorAddress, buyerAddress, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Delegated txs not signed by the operator should fail")
+
+		tx = NewDelegatedTransferTx(genTx2, d.Sign(sk), creatorAddress, buyerAddress, operatorSK)
+		tx.KittyID = KittyID(2)
+		tx.Sig = tx.Sign(operatorSK)
+		require.NotNil(t, bc.InjectTx(tx), "Transferring another kitty than delegated should fail")
+
+		expired := d
+		expired.Expiry = time.Now().Add(-time.Minute).UnixNano()
+		tx = NewDelegatedTransferTx(genTx2, expired.Sign(sk), creatorAddress, buyerAddress, operatorSK)
+		require.NotNil(t, bc.InjectTx(tx), "Expired delegations should fail")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		tx := NewDelegatedTransferTx(genTx2, d.Sign(sk), creatorAddress, buyerAddress, operatorSK)
+		require.Nil(t, bc.InjectTx(tx), "Delegated transfers should succeed")
+
+		kState, ok := bc.GetKittyState(KittyID(1))
+		require.True(t, ok, "Kitty should exist")
+		require.Equal(t, buyerAddress, kState.Address, "Kitty should be transferred by the operator")
+
+		decoded, err := DecodeTx(tx.Serialize())
+		require.Nil(t, err, "Decoding a delegated tx should succeed")
+		require.Equal(t, tx.Delegate, decoded.Delegate, "Delegation should be encoded")
+
+		// Return the kitty, so that the delegation would otherwise apply again.
+		backTx := NewTransferTx(tx, KittyID(1), creatorAddress, bc.NextNonce(buyerAddress), operatorSK)
+		require.Nil(t, bc.InjectTx(backTx), "Transferring back should succeed")
+
+		replayTx := NewDelegatedTransferTx(backTx, d.Sign(sk), creatorAddress, buyerAddress, operatorSK)
+		require.NotNil(t, bc.InjectTx(replayTx), "Delegations should only be usable once")
+	})
+}
+
+func TestBlockChain_MintMeta(t *testing.T) {
+	sk := testSecKey
+	otherSK := testSecKey2
+	otherAddress := cipher.AddressFromSecKey(otherSK)
+
+	bc := newTestBlockChain(t, BlockChainConfig{
+		MetaDB: NewMemoryMetaDB(),
+	})
+	defer bc.Close()
+
+	meta := KittyMeta{Name: "Fluffy", Breed: "Persian", MintBatch: 1}
+	mint := MintMeta{AttrHash: meta.Hash(), URI: "ipfs://fluffy"}
+
+	genTx := NewGenTxWithMeta(nil, KittyID(1), mint, sk)
+	require.Equal(t, TxVersionExtended, genTx.Version, "Gen txs with metadata should use the extended version")
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+
+	t.Run("Encoding", func(t *testing.T) {
+		decoded, err := DecodeTx(genTx.Serialize())
+		require.Nil(t, err, "Decoding a mint tx should succeed")
+		require.Equal(t, mint, decoded.Mint, "Mint metadata should be encoded")
+
+		decoded, err = UnmarshalTxProto(genTx.MarshalProto())
+		require.Nil(t, err, "Decoding a protobuf mint tx should succeed")
+		require.Equal(t, genTx.Hash(), decoded.Hash(), "Mint metadata should be encoded in protobuf")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tx := NewTransferTx(genTx, KittyID(1), otherAddress, bc.NextNonce(cipher.AddressFromSecKey(sk)), sk)
+		tx.Mint = mint
+		tx.extend()
+		tx.Sig = tx.Sign(sk)
+		require.NotNil(t, bc.InjectTx(tx), "Transfers carrying mint metadata should fail")
+
+		tx = NewGenTxWithMeta(genTx, KittyID(2), MintMeta{URI: strings.Repeat("a", TxMaxMintURISize+1)}, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Mint uris larger than the maximum should fail")
+	})
+
+	t.Run("KittyMeta", func(t *testing.T) {
+		got, err := bc.GetMintMeta(KittyID(1))
+		require.Nil(t, err, "Obtaining mint metadata should succeed")
+		require.Equal(t, mint, got, "Mint metadata should be from the gen tx")
+
+		other := meta
+		other.Breed = "Sphynx"
+		require.NotNil(t, bc.SetKittyMeta(NewSignedKittyMeta(KittyID(1), other, sk)),
+			"Metadata that does not match the minted hash should fail")
+		require.Nil(t, bc.SetKittyMeta(NewSignedKittyMeta(KittyID(1), meta, sk)),
+			"Metadata with the minted hash should succeed")
+	})
+}
+
+func TestBlockChain_Burn(t *testing.T) {
+	sk := testSecKey
+	otherSK := testSecKey2
+	otherAddress := cipher.AddressFromSecKey(otherSK)
+
+	bc := newTestBlockChain(t, BlockChainConfig{
+		BurnCosign: true,
+	})
+	defer bc.Close()
+
+	genTx1 := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx1), "Injecting the gen tx should succeed")
+	genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+	require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+	sendTx := NewTransferTx(genTx2, KittyID(1), otherAddress, 1, sk)
+	require.Nil(t, bc.InjectTx(sendTx), "Transferring the kitty should succeed")
+
+	burnTx := NewBurnTx(sendTx, KittyIDs{1}, 1, otherSK)
+	require.Equal(t, TxVersionExtended, burnTx.Version, "Burns should use the extended version")
+
+	t.Run("Invalid", func(t *testing.T) {
+		require.NotNil(t, bc.InjectTx(burnTx), "Burns that are not countersigned should fail")
+
+		tx := *burnTx
+		tx.Countersign(otherSK)
+		require.NotNil(t, bc.InjectTx(&tx), "Burns not countersigned by master should fail")
+
+		tx = *NewTransferTx(sendTx, KittyID(2), otherAddress, 2, sk)
+		tx.Countersign(sk)
+		require.NotNil(t, bc.InjectTx(&tx), "Countersigning transfers that are not burns should fail")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		burnTx.Countersign(sk)
+		require.Nil(t, bc.InjectTx(burnTx), "Countersigned burns should succeed")
+
+		require.Equal(t, KittyBurned, bc.GetKittyStatus(KittyID(1)), "Kitty should be burned")
+		require.Equal(t, uint64(1), bc.CountOfStatus(KittyBurned), "One kitty should be burned")
+		require.Equal(t, uint64(1), bc.CountOfStatus(KittyMinted), "One kitty should be in circulation")
+		require.Equal(t, uint64(0), bc.CountOfAddress(otherAddress), "Burned kitties should not count for the owner")
+
+		history, err := bc.GetKittyHistory(KittyID(1))
+		require.Nil(t, err, "History of burned kitties should be kept")
+		require.Len(t, history, 3, "History should include the burn")
+		require.Equal(t, BurnAddress, history[2].Owner, "Burn should be the last transition")
+
+		decoded, err := DecodeTx(burnTx.Serialize())
+		require.Nil(t, err, "Decoding a burn should succeed")
+		require.Equal(t, burnTx.Cosig, decoded.Cosig, "Countersignature should be encoded")
+
+		err = bc.state.Apply([]OwnershipChange{{
+			Tx:      TxRef{Seq: 4},
+			KittyID: KittyID(1),
+			From:    BurnAddress,
+			To:      otherAddress,
+		}})
+		require.NotNil(t, err, "Moving burned kitties should fail")
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		require.Nil(t, bc.Rollback(sendTx.Seq), "Rolling back the burn should succeed")
+		require.Equal(t, KittyMinted, bc.GetKittyStatus(KittyID(1)), "Kitty should be minted again")
+		require.Equal(t, uint64(1), bc.CountOfAddress(otherAddress), "Kitty should belong to the owner again")
+	})
+}
+
+func TestBlockChain_Breed(t *testing.T) {
+	sk := testSecKey
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	genTx1 := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx1), "Injecting the gen tx should succeed")
+	genTx2 := NewGenTx(genTx1, KittyID(2), sk)
+	require.Nil(t, bc.InjectTx(genTx2), "Injecting the gen tx should succeed")
+
+	t.Run("Invalid", func(t *testing.T) {
+		tx := NewBreedTx(genTx2, KittyID(3), KittyIDs{1, 4}, MintMeta{}, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Breeding from parents that do not exist should fail")
+
+		tx = NewBreedTx(genTx2, KittyID(3), KittyIDs{1, 1}, MintMeta{}, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Breeding from the same parent twice should fail")
+
+		tx = NewBreedTx(genTx2, KittyID(3), KittyIDs{1}, MintMeta{}, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Breeding from one parent should fail")
+
+		tx = NewBreedTx(genTx2, KittyID(3), KittyIDs{1, 3}, MintMeta{}, sk)
+		require.NotNil(t, bc.InjectTx(tx), "Kitties can not be their own parent")
+	})
+
+	breedTx := NewBreedTx(genTx2, KittyID(3), KittyIDs{1, 2}, MintMeta{}, sk)
+
+	t.Run("Success", func(t *testing.T) {
+		require.Nil(t, bc.InjectTx(breedTx), "Breeding should succeed")
+
+		child, ok := bc.GetKittyState(KittyID(3))
+		require.True(t, ok, "Child kitty should exist")
+		require.Equal(t, KittyIDs{1, 2}, child.Parents, "Child should record its parents")
+		for _, parentID := range breedTx.Parents {
+			parent, _ := bc.GetKittyState(parentID)
+			require.Equal(t, KittyIDs{3}, parent.Children, "Parents should record their child")
+		}
+
+		decoded, err := UnmarshalTxProto(breedTx.MarshalProto())
+		require.Nil(t, err, "Decoding a protobuf breed tx should succeed")
+		require.Equal(t, breedTx.Hash(), decoded.Hash(), "Parents should be encoded in protobuf")
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		require.Nil(t, bc.Rollback(genTx2.Seq), "Rolling back the breed should succeed")
+		_, ok := bc.GetKittyState(KittyID(3))
+		require.False(t, ok, "Child kitty should no longer exist")
+		parent, _ := bc.GetKittyState(KittyID(1))
+		require.Empty(t, parent.Children, "Parents should no longer record the child")
+	})
+}
+
+func TestBlockChain_Ed25519(t *testing.T) {
+	sk := testSecKey
+	edSeed := []byte{
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+	}
+	creatorAddress := cipher.AddressFromSecKey(sk)
+	edKey := ed25519.NewKeyFromSeed(edSeed)
+	edAddress, err := Ed25519Address(edKey.Public().(ed25519.PublicKey))
+	require.Nil(t, err, "Obtaining the address of an ed25519 key should succeed")
+
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Nil(t, bc.InjectTx(genTx), "Injecting the gen tx should succeed")
+	sendTx := NewTransferTx(genTx, KittyID(1), edAddress, 1, sk)
+	require.Nil(t, bc.InjectTx(sendTx), "Transferring to an ed25519 address should succeed")
+
+	newTx := func() *Transaction {
+		tx := NewUnsignedTransfer(sendTx, KittyIDs{1}, edAddress, creatorAddress, bc.NextNonce(edAddress))
+		require.Nil(t, tx.UseScheme(Ed25519Scheme), "Changing to the ed25519 scheme should succeed")
+		require.Equal(t, Ed25519Scheme.ID(), tx.Scheme, "Tx should use the ed25519 scheme")
+		require.Equal(t, TxVersionExtended, tx.Version, "Tx should use the extended version")
+		return tx
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		tx := newTx()
+		require.NotNil(t, tx.SignScheme(edSeed[:16]), "Signing with an invalid key should fail")
+		require.NotNil(t, tx.AttachSignature(tx.Sign(sk)), "Attaching secp256k1 signatures should fail")
+
+		otherSeed := append([]byte{}, edSeed...)
+		otherSeed[0]++
+		pk, sig, err := Ed25519Scheme.Sign(tx.SignatureHash(), otherSeed)
+		require.Nil(t, err, "Signing with ed25519 should succeed")
+		require.NotNil(t, tx.AttachSchemeSignature(pk, sig), "Signatures by other keys should fail")
+
+		tx.SchemeKey, tx.SchemeSig = pk, sig
+		require.NotNil(t, bc.InjectTx(tx), "Txs signed by other keys should fail")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		tx := newTx()
+		require.Nil(t, tx.SignScheme(edSeed), "Signing with ed25519 should succeed")
+
+		decoded, err := DecodeTx(tx.Serialize())
+		require.Nil(t, err, "Decoding an ed25519 tx should succeed")
+		require.Nil(t, decoded.Verify(sendTx), "Decoded ed25519 tx should verify")
+
+		require.Nil(t, bc.InjectTx(tx), "Injecting an ed25519 tx should succeed")
+		kState, _ := bc.GetKittyState(KittyID(1))
+		require.Equal(t, creatorAddress, kState.Address, "Kitty should be transferred by the ed25519 key")
+	})
+}
+
+func TestBlockChain_GetTxRange(t *testing.T) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, BlockChainConfig{})
+	defer bc.Close()
+
+	txRange, err := bc.GetTxRange(0, 2, false)
+	require.Nil(t, err, "An empty chain should have an empty range")
+	require.Empty(t, txRange.Transactions)
+	require.False(t, txRange.HasMore)
+
+	var prev *Transaction
+	for i := 1; i <= 5; i++ {
+		prev = NewGenTx(prev, KittyID(i), sk)
+		require.Nil(t, bc.InjectTx(prev), "Injecting the gen tx should succeed")
+	}
+	seqs := func(txs []Transaction) []uint64 {
+		out := make([]uint64, len(txs))
+		for i, tx := range txs {
+			out[i] = tx.Seq
+		}
+		return out
+	}
+
+	txRange, err = bc.GetTxRange(0, 2, false)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{0, 1}, seqs(txRange.Transactions))
+	require.Equal(t, uint64(4), txRange.HeadSeq)
+	require.True(t, txRange.HasMore)
+	require.Equal(t, uint64(2), txRange.NextSeq, "The next page should follow the last transaction")
+
+	txRange, err = bc.GetTxRange(3, 2, false)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{3, 4}, seqs(txRange.Transactions))
+	require.False(t, txRange.HasMore, "The page with the head should be the last")
+
+	txRange, err = bc.GetTxRange(5, 2, false)
+	require.Nil(t, err)
+	require.Empty(t, txRange.Transactions, "Pages past the head should be empty")
+
+	txRange, err = bc.GetTxRange(100, 2, true)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{4, 3}, seqs(txRange.Transactions), "Descending pages should start at the head")
+	require.True(t, txRange.HasMore)
+	require.Equal(t, uint64(2), txRange.NextSeq)
+
+	txRange, err = bc.GetTxRange(1, 2, true)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{1, 0}, seqs(txRange.Transactions))
+	require.False(t, txRange.HasMore, "The page with the first transaction should be the last")
+
+	_, err = bc.GetTxRange(0, 0, false)
+	require.NotNil(t, err, "Page sizes of 0 should be rejected")
+	_, err = bc.GetTxRange(0, MaxTxRangeSize+1, false)
+	require.NotNil(t, err, "Page sizes over the maximum should be rejected")
+}
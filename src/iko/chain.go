@@ -49,13 +49,19 @@ type ChainDB interface {
 	// It will return an error if the pageSize is zero
 	// It will also return an error if startSeq is invalid
 	GetTxsOfSeqRange(startSeq uint64, pageSize uint64) ([]Transaction, error)
+
+	// Truncate should discard all transactions of sequence higher than the
+	// specified sequence, so that the transaction of 'seq' becomes the head.
+	// It should return an error if the sequence given is invalid.
+	Truncate(seq uint64) error
 }
 
 type MemoryChain struct {
 	sync.RWMutex
-	txs    []Transaction
-	byHash map[TxHash]*Transaction
-	txChan chan *Transaction
+	txs      []Transaction
+	byHash   map[TxHash]*Transaction
+	txChan   chan *Transaction
+	lastSent chan struct{} // closed when the last added tx is sent through 'txChan'
 }
 
 func NewMemoryChain(bufferSize int) *MemoryChain {
@@ -99,8 +105,16 @@ func (c *MemoryChain) AddTx(tx Transaction, check TxChecker) error {
 
 	c.txs = append(c.txs, tx)
 	c.byHash[tx.Hash()] = &c.txs[len(c.txs)-1]
+
+	// Send through 'txChan' in the order that txs are added.
+	prevSent, sent := c.lastSent, make(chan struct{})
+	c.lastSent = sent
 	go func() {
+		if prevSent != nil {
+			<-prevSent
+		}
 		c.txChan <- &tx
+		close(sent)
 	}()
 	return nil
 }
@@ -154,3 +168,17 @@ func (c *MemoryChain) GetTxsOfSeqRange(startSeq uint64, pageSize uint64) ([]Tran
 
 	return result, nil
 }
+
+func (c *MemoryChain) Truncate(seq uint64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if seq >= uint64(len(c.txs)) {
+		return fmt.Errorf("block of sequence '%d' does not exist", seq)
+	}
+	for _, tx := range c.txs[seq+1:] {
+		delete(c.byHash, tx.Hash())
+	}
+	c.txs = c.txs[:seq+1]
+	return nil
+}
@@ -131,7 +131,7 @@ func runChainDBTest(t *testing.T, chainDB ChainDB) {
 		})
 
 		secondTransaction := NewTransferTx(
-			firstTransaction, kittyID, secondOwnerAddress, firstSecKey)
+			firstTransaction, kittyID, secondOwnerAddress, 1, firstSecKey)
 
 		err = chainDB.AddTx(*secondTransaction, addTxAlwaysApprove)
 
@@ -208,7 +208,7 @@ func runChainDBTest(t *testing.T, chainDB ChainDB) {
 
 		// adding a third transaction for an odd number of transactions
 		thirdTransaction := NewTransferTx(
-			secondTransaction, kittyID, firstOwnerAddress, secondSecKey)
+			secondTransaction, kittyID, firstOwnerAddress, 1, secondSecKey)
 
 		err = chainDB.AddTx(*thirdTransaction, addTxAlwaysApprove)
 
@@ -232,6 +232,24 @@ func runChainDBTest(t *testing.T, chainDB ChainDB) {
 		})
 
 		testChainDBPagination(t, chainDB, 2)
+
+		t.Run("Truncate_BadSeq", func(t *testing.T) {
+			require.NotNil(t, chainDB.Truncate(5),
+				"We should get an error for a bad sequence index")
+		})
+
+		t.Run("Truncate_Success", func(t *testing.T) {
+			require.Nil(t, chainDB.Truncate(1), "Truncating should succeed")
+			require.Equal(t, uint64(2), chainDB.Len(), "We should have two transactions left")
+
+			transaction, err := chainDB.Head()
+			require.Nil(t, err, "Should not give us an error")
+			require.Equal(t, *secondTransaction, transaction,
+				"The second transaction should become the head")
+
+			_, err = chainDB.GetTxOfHash(thirdTransaction.Hash())
+			require.NotNil(t, err, "The discarded transaction should no longer exist")
+		})
 	})
 }
 
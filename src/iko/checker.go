@@ -0,0 +1,219 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"time"
+)
+
+// Names of the stages in the transaction pipeline of the blockchain, in the
+// order that they run.
+const (
+	TxCheckContent   = "content"   // Version, kitties, memo and expiry.
+	TxCheckLimits    = "limits"    // Structural limits of the node (new txs only).
+	TxCheckLink      = "link"      // Prev hash, seq and timestamp.
+	TxCheckSig       = "sig"       // Signatures of the sender.
+	TxCheckExpiry    = "expiry"    // Expiry against the current time (new txs only).
+	TxCheckRate      = "rate"      // Injection rate of the sender (new txs only).
+	TxCheckKind      = "kind"      // Rules for gen txs and transfers.
+	TxCheckNonce     = "nonce"     // Nonce of the sender.
+	TxCheckFee       = "fee"       // Transfer fee.
+	TxCheckCosig     = "cosig"     // Countersignature of burns.
+	TxCheckOwnership = "ownership" // Ownership of kitties, applies the tx to state.
+)
+
+// TxCheckNames are the names of every stage of the transaction pipeline.
+var TxCheckNames = []string{
+	TxCheckContent,
+	TxCheckLimits,
+	TxCheckLink,
+	TxCheckSig,
+	TxCheckExpiry,
+	TxCheckRate,
+	TxCheckKind,
+	TxCheckNonce,
+	TxCheckFee,
+	TxCheckCosig,
+	TxCheckOwnership,
+}
+
+// txRequiredChecks are the stages that can not be disabled, as the chain is
+// only valid with them: every tx links to the previous tx, is signed by the
+// sender, follows the rules of its kind, has the next nonce of the sender,
+// and is applied to state. The chain is replayed through the same stages, so
+// txs that skipped them would be accepted by one node and rejected by others.
+var txRequiredChecks = []string{
+	TxCheckLink,
+	TxCheckSig,
+	TxCheckKind,
+	TxCheckNonce,
+	TxCheckOwnership,
+}
+
+// TxStage is a named checker in a transaction pipeline.
+type TxStage struct {
+	Name  string
+	Check TxChecker
+}
+
+// NewTxStage creates a named stage from a checker.
+func NewTxStage(name string, check TxChecker) TxStage {
+	return TxStage{Name: name, Check: check}
+}
+
+// TxCheckError is returned by a pipeline when a stage rejects a transaction.
+type TxCheckError struct {
+	Stage string
+	Err   error
+}
+
+func (e *TxCheckError) Error() string {
+	return fmt.Sprintf("tx rejected at stage '%s': %v", e.Stage, e.Err)
+}
+
+// Unwrap obtains the error of the stage.
+func (e *TxCheckError) Unwrap() error {
+	return e.Err
+}
+
+// TxPipeline runs stages in order, until one rejects the transaction.
+type TxPipeline []TxStage
+
+// CheckAll creates a pipeline from the stages.
+func CheckAll(stages ...TxStage) TxPipeline {
+	return TxPipeline(stages)
+}
+
+// Without obtains the pipeline without the stages with the names.
+func (p TxPipeline) Without(names ...string) TxPipeline {
+	out := make(TxPipeline, 0, len(p))
+	for _, stage := range p {
+		if !containsString(names, stage.Name) {
+			out = append(out, stage)
+		}
+	}
+	return out
+}
+
+// Check runs the transaction through every stage. The error of the stage that
+// rejects the transaction is returned as a '*TxCheckError'.
+func (p TxPipeline) Check(tx *Transaction) error {
+	for _, stage := range p {
+		if e := stage.Check(tx); e != nil {
+			return &TxCheckError{Stage: stage.Name, Err: e}
+		}
+	}
+	return nil
+}
+
+// checkTxChecks checks the names of stages that are disabled.
+func checkTxChecks(names []string) error {
+	for _, name := range names {
+		switch {
+		case containsString(txRequiredChecks, name):
+			return fmt.Errorf("tx check '%s' can not be disabled", name)
+		case !containsString(TxCheckNames, name):
+			return fmt.Errorf("invalid tx check '%s'", name)
+		}
+	}
+	return nil
+}
+
+func containsString(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// txPipeline obtains the pipeline that checks a transaction against the
+// previous transaction (nil for genesis), and applies it to the specified
+// state. The limits, expiry and rate of transactions are only checked for new
+// transactions ('live'), as replayed transactions were valid when injected.
+func (bc *BlockChain) txPipeline(state StateDB, prev *Transaction, live bool) TxPipeline {
+	p := CheckAll(
+		NewTxStage(TxCheckContent, func(tx *Transaction) error {
+			return tx.verifyContent()
+		}),
+		NewTxStage(TxCheckLimits, func(tx *Transaction) error {
+			return bc.c.TxLimits.Check(tx, time.Now().UnixNano())
+		}),
+		NewTxStage(TxCheckLink, func(tx *Transaction) error {
+			return tx.verifyLink(prev)
+		}),
+		NewTxStage(TxCheckSig, func(tx *Transaction) error {
+			return withKind(ErrBadSignature, tx.verifySig())
+		}),
+		NewTxStage(TxCheckExpiry, func(tx *Transaction) error {
+			return tx.CheckExpiry(time.Now().UnixNano())
+		}),
+		NewTxStage(TxCheckRate, func(tx *Transaction) error {
+			if bc.rate == nil || tx.IsKittyGen(bc.c.CreatorPK) {
+				return nil
+			}
+			return withKind(ErrRateLimited, bc.rate.Allow(tx.From, 1, time.Now()))
+		}),
+		NewTxStage(TxCheckKind, bc.checkKind),
+		NewTxStage(TxCheckNonce, func(tx *Transaction) error {
+			if tx.IsKittyGen(bc.c.CreatorPK) {
+				return nil
+			}
+			// Legacy transfers are from chains before nonces, so they are
+			// only accepted when replayed.
+			if !live && tx.Version == TxVersionLegacy {
+				return nil
+			}
+			if expected := state.GetNonce(tx.From) + 1; tx.Nonce != expected {
+				return fmt.Errorf("invalid nonce '%d' for address '%s', expected '%d'",
+					tx.Nonce, tx.From.String(), expected)
+			}
+			return nil
+		}),
+		NewTxStage(TxCheckFee, func(tx *Transaction) error {
+			if tx.IsKittyGen(bc.c.CreatorPK) || tx.Fee == bc.c.TransferFee {
+				return nil
+			}
+			return fmt.Errorf("invalid fee '%d', expected '%d'",
+				tx.Fee, bc.c.TransferFee)
+		}),
+		NewTxStage(TxCheckCosig, func(tx *Transaction) error {
+			if !bc.c.BurnCosign || !tx.IsBurn() || tx.IsKittyGen(bc.c.CreatorPK) {
+				return nil
+			}
+			if e := cipher.VerifySignature(bc.c.CreatorPK, tx.Cosig, tx.SignatureHash()); e != nil {
+				return fmt.Errorf("burn is not countersigned by master: %v", e)
+			}
+			return nil
+		}),
+		NewTxStage(TxCheckOwnership, func(tx *Transaction) error {
+			return state.Apply(bc.txChanges(tx))
+		}),
+	)
+	if !live {
+		p = p.Without(TxCheckLimits, TxCheckExpiry, TxCheckRate)
+	}
+	return p.Without(bc.c.DisabledTxChecks...)
+}
+
+// checkKind checks the rules that only apply to either gen txs or transfers.
+func (bc *BlockChain) checkKind(tx *Transaction) error {
+	if tx.IsKittyGen(bc.c.CreatorPK) {
+		if tx.Fee != 0 {
+			return errors.New("gen tx can not pay a fee")
+		}
+		if tx.IsDelegated() {
+			return errors.New("gen tx can not be delegated")
+		}
+		return nil
+	}
+	if !tx.Mint.IsZero() {
+		return errors.New("only gen tx can carry mint metadata")
+	}
+	if len(tx.Parents) != 0 {
+		return errors.New("only gen tx can breed kitties")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"time"
+)
+
+// delegationDomain separates the hashes of delegations from those of
+// transactions, so that signatures of one can not be used as the other.
+var delegationDomain = []byte("kittycash-delegation")
+
+// Delegation authorizes the key of an operator (such as a marketplace) to
+// transfer a kitty on behalf of its owner, once. The delegation is bound to
+// the nonce of the transfer, so it can not be used again after the transfer
+// is applied (or after any other transfer by the owner).
+type Delegation struct {
+	KittyID  KittyID
+	Nonce    uint64        // Nonce of the delegated transfer.
+	Operator cipher.PubKey // Key that signs the delegated transfer.
+	Expiry   int64         // Timestamp after which the delegation is not valid (0 never expires).
+}
+
+// SignedDelegation is a delegation signed by the owner of the kitty.
+type SignedDelegation struct {
+	Delegation
+	Sig cipher.Sig
+}
+
+// Hash obtains the hash that the owner signs.
+func (d Delegation) Hash() cipher.SHA256 {
+	raw := append(append([]byte{}, delegationDomain...), encoder.Serialize(d)...)
+	return cipher.SumSHA256(raw)
+}
+
+// Sign signs the delegation with the secret key of the owner.
+func (d Delegation) Sign(sk cipher.SecKey) SignedDelegation {
+	return SignedDelegation{
+		Delegation: d,
+		Sig:        cipher.SignHash(d.Hash(), sk),
+	}
+}
+
+// IsZero returns true if there is no delegation.
+func (d SignedDelegation) IsZero() bool {
+	return d == SignedDelegation{}
+}
+
+// Verify checks that the delegation is signed by the owner address.
+func (d SignedDelegation) Verify(owner cipher.Address) error {
+	if e := d.Operator.Verify(); e != nil {
+		return fmt.Errorf("invalid operator in delegation: %v", e)
+	}
+	if e := cipher.ChkSig(owner, d.Hash(), d.Sig); e != nil {
+		return fmt.Errorf("delegation is not signed by owner '%s': %v", owner.String(), e)
+	}
+	return nil
+}
+
+func (d SignedDelegation) Serialize() []byte {
+	return encoder.Serialize(d)
+}
+
+// DecodeDelegation deserializes a signed delegation.
+func DecodeDelegation(raw []byte) (SignedDelegation, error) {
+	var d SignedDelegation
+	e := encoder.DeserializeRaw(raw, &d)
+	return d, e
+}
+
+// NewDelegatedTransferTx creates a transfer of the kitty in the delegation,
+// from the owner to the specified address, signed by the operator.
+func NewDelegatedTransferTx(prev *Transaction, d SignedDelegation, owner, to cipher.Address, operatorSK cipher.SecKey) *Transaction {
+	tx := &Transaction{
+		Prev:    prev.Hash(),
+		Seq:     prev.Seq + 1,
+		TS:      time.Now().UnixNano(),
+		KittyID: d.KittyID,
+		From:    owner,
+		To:      to,
+	}
+	tx.UseDelegation(d)
+	tx.Sig = tx.Sign(operatorSK)
+	return tx
+}
+
+// UseDelegation sets the delegation (and hence the nonce) of an unsigned
+// transaction, and changes it to the extended version. The transaction then
+// needs to be signed by the operator of the delegation.
+func (tx *Transaction) UseDelegation(d SignedDelegation) {
+	tx.Delegate = d
+	tx.Nonce = d.Nonce
+	tx.extend()
+}
+
+// IsDelegated returns true if the transaction is signed by an operator on
+// behalf of the sender.
+func (tx Transaction) IsDelegated() bool {
+	return !tx.Delegate.IsZero()
+}
+
+// verifyDelegation checks the parts of a delegated transaction that do not
+// depend on the chain.
+func (tx Transaction) verifyDelegation() error {
+	if !tx.Signers.IsZero() {
+		return errors.New("transfers from multisig addresses can not be delegated")
+	}
+	if len(tx.Extra) != 0 || tx.KittyID != tx.Delegate.KittyID {
+		return fmt.Errorf("delegated tx needs to only transfer kitty of id '%d'", tx.Delegate.KittyID)
+	}
+	if tx.Nonce != tx.Delegate.Nonce {
+		return fmt.Errorf("delegated tx needs to have nonce '%d'", tx.Delegate.Nonce)
+	}
+	return nil
+}
+
+// verifyDelegatedSig checks the delegation chain of a transaction: the
+// delegation is signed by the sender, and the transaction by the operator.
+func (tx Transaction) verifyDelegatedSig() error {
+	if len(tx.Sigs) != 0 {
+		return errors.New("delegated tx has multisig signatures")
+	}
+	if e := tx.Delegate.Verify(tx.From); e != nil {
+		return e
+	}
+	return cipher.VerifySignature(tx.Delegate.Operator, tx.Sig, tx.HashInner())
+}
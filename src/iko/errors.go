@@ -0,0 +1,83 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors from injecting transactions. Errors returned by 'InjectTx',
+// 'SubmitTx', 'InjectTxGroup' and 'SimulateTx' match these with 'errors.Is',
+// while keeping their detailed messages.
+var (
+	// ErrNotOwner is returned when a kitty is not owned by the sender.
+	ErrNotOwner = errors.New("kitty is not owned by sender")
+
+	// ErrBadSignature is returned when the signatures of a transaction are
+	// invalid.
+	ErrBadSignature = errors.New("bad signature")
+
+	// ErrDuplicateTx is returned when a transaction is already in the chain
+	// (or already waits in the mempool). Transactions already in the chain
+	// are a '*TxAcceptedError'.
+	ErrDuplicateTx = errors.New("duplicate tx")
+
+	// ErrKittyUnknown is returned when a kitty (or parent kitty) does not exist.
+	ErrKittyUnknown = errors.New("kitty does not exist")
+
+	// ErrExpired is returned when a transaction (or its delegation) is
+	// expired. The error is a '*TxExpiredError'.
+	ErrExpired = errors.New("tx expired")
+
+	// ErrRateLimited is returned when the sender exceeds the injection rate
+	// of the node.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// TxAcceptedError is returned when injecting a transaction that is already
+// accepted into the chain. Clients that retry injections can treat it as
+// success.
+type TxAcceptedError struct {
+	Hash TxHash
+	Seq  uint64
+}
+
+func (e *TxAcceptedError) Error() string {
+	return fmt.Sprintf("tx of hash '%s' is already accepted at seq '%d'", e.Hash.Hex(), e.Seq)
+}
+
+// Is matches 'ErrDuplicateTx'.
+func (e *TxAcceptedError) Is(target error) bool {
+	return target == ErrDuplicateTx
+}
+
+// kindError is an error of one of the kinds above, with the message of the
+// underlying error.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// withKind marks an error as a kind (nil stays nil).
+func withKind(kind, e error) error {
+	if e == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: e}
+}
+
+// kindErrorf formats an error with a kind.
+func kindErrorf(kind error, format string, a ...interface{}) error {
+	return withKind(kind, fmt.Errorf(format, a...))
+}
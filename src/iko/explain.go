@@ -0,0 +1,156 @@
+package iko
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Types of transactions, as explained by 'Transaction.Explain'.
+const (
+	TxTypeGen       = "gen"
+	TxTypeBreed     = "breed"
+	TxTypeTransfer  = "transfer"
+	TxTypeDelegated = "delegated_transfer"
+	TxTypeMultisig  = "multisig_transfer"
+	TxTypeBurn      = "burn"
+)
+
+// TxExplanation is a human readable breakdown of a transaction, for debugging
+// and support tooling.
+type TxExplanation struct {
+	Type          string   `json:"type"`
+	Version       uint8    `json:"version"`
+	Hash          string   `json:"hash"`
+	SignatureHash string   `json:"signature_hash"`
+	Seq           uint64   `json:"seq"`
+	Time          string   `json:"time"`
+	Prev          string   `json:"prev"`
+	KittyIDs      KittyIDs `json:"kitty_ids"`
+	Parents       KittyIDs `json:"parents,omitempty"`
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Nonce         uint64   `json:"nonce"`
+	Fee           uint64   `json:"fee,omitempty"`
+	Memo          string   `json:"memo,omitempty"`
+	Expiry        string   `json:"expiry,omitempty"`
+	Group         string   `json:"group,omitempty"`
+	Scheme        string   `json:"scheme"`
+	SigValid      bool     `json:"sig_valid"`
+	SigError      string   `json:"sig_error,omitempty"`
+	ContentError  string   `json:"content_error,omitempty"`
+	Committed     bool     `json:"committed"` // Only set by 'BlockChain.ExplainTx'.
+}
+
+// Explain obtains a breakdown of the transaction. Whether the sender of a gen
+// transaction is the master key depends on the chain, so the type of a
+// transaction with the same from and to addresses is always 'TxTypeGen' (or
+// 'TxTypeBreed'). The signature and content are checked without the chain.
+func (tx Transaction) Explain() TxExplanation {
+	x := TxExplanation{
+		Type:          tx.explainType(),
+		Version:       tx.Version,
+		Hash:          tx.Hash().Hex(),
+		SignatureHash: tx.SignatureHash().Hex(),
+		Seq:           tx.Seq,
+		Time:          time.Unix(0, tx.TS).UTC().Format(time.RFC3339Nano),
+		Prev:          tx.Prev.Hex(),
+		KittyIDs:      tx.Kitties(),
+		Parents:       tx.Parents,
+		From:          tx.From.String(),
+		To:            tx.To.String(),
+		Nonce:         tx.Nonce,
+		Fee:           tx.Fee,
+		Memo:          tx.Memo,
+		Scheme:        tx.SigScheme().Name(),
+	}
+	if tx.Expiry != 0 {
+		x.Expiry = time.Unix(0, tx.Expiry).UTC().Format(time.RFC3339Nano)
+	}
+	if tx.IsGrouped() {
+		x.Group = tx.Group.Hex()
+	}
+	if e := tx.verifyContent(); e != nil {
+		x.ContentError = e.Error()
+	}
+	if e := tx.verifySig(); e != nil {
+		x.SigError = e.Error()
+	} else {
+		x.SigValid = true
+	}
+	return x
+}
+
+// ExplainTx obtains a breakdown of the transaction, where the type of gen
+// transactions is checked against the master key, and whether the
+// transaction is committed to the chain is included.
+func (bc *BlockChain) ExplainTx(tx *Transaction) TxExplanation {
+	x := tx.Explain()
+	if (x.Type == TxTypeGen || x.Type == TxTypeBreed) && !tx.IsKittyGen(bc.c.CreatorPK) {
+		x.Type = TxTypeTransfer
+	}
+	_, e := bc.GetTxOfHash(tx.Hash())
+	x.Committed = e == nil
+	return x
+}
+
+func (tx Transaction) explainType() string {
+	switch {
+	case tx.From == tx.To && len(tx.Parents) != 0:
+		return TxTypeBreed
+	case tx.From == tx.To:
+		return TxTypeGen
+	case tx.IsBurn():
+		return TxTypeBurn
+	case tx.IsDelegated():
+		return TxTypeDelegated
+	case !tx.Signers.IsZero():
+		return TxTypeMultisig
+	default:
+		return TxTypeTransfer
+	}
+}
+
+// String renders the explanation as aligned lines of fields.
+func (x TxExplanation) String() string {
+	var buf bytes.Buffer
+	line := func(name string, v interface{}) {
+		fmt.Fprintf(&buf, "%-15s %v\n", name+":", v)
+	}
+	line("type", x.Type)
+	line("version", x.Version)
+	line("hash", x.Hash)
+	line("signature_hash", x.SignatureHash)
+	line("seq", x.Seq)
+	line("time", x.Time)
+	line("prev", x.Prev)
+	line("kitty_ids", x.KittyIDs)
+	if len(x.Parents) != 0 {
+		line("parents", x.Parents)
+	}
+	line("from", x.From)
+	line("to", x.To)
+	line("nonce", x.Nonce)
+	if x.Fee != 0 {
+		line("fee", x.Fee)
+	}
+	if x.Memo != "" {
+		line("memo", fmt.Sprintf("%q", x.Memo))
+	}
+	if x.Expiry != "" {
+		line("expiry", x.Expiry)
+	}
+	if x.Group != "" {
+		line("group", x.Group)
+	}
+	line("scheme", x.Scheme)
+	if x.SigValid {
+		line("sig", "valid")
+	} else {
+		line("sig", "invalid: "+x.SigError)
+	}
+	if x.ContentError != "" {
+		line("content", "invalid: "+x.ContentError)
+	}
+	return buf.String()
+}
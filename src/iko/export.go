@@ -0,0 +1,67 @@
+package iko
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportFormat determines the format of a state export.
+type ExportFormat string
+
+const (
+	// ExportJSON exports the state as a JSON array of 'ExportEntry' objects.
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV exports the state as CSV with a header row of
+	// "kitty_id,address,last_tx_hash,last_tx_seq,last_tx_time".
+	ExportCSV ExportFormat = "csv"
+)
+
+// ExportEntry is a row of the kitty to owner table of a state export.
+type ExportEntry struct {
+	KittyID    KittyID `json:"kitty_id"`
+	Address    string  `json:"address"`
+	LastTxHash string  `json:"last_tx_hash"`
+	LastTxSeq  uint64  `json:"last_tx_seq"`
+	LastTxTime int64   `json:"last_tx_time"`
+}
+
+// NewExportEntry creates an export entry for a kitty with the given state.
+func NewExportEntry(kittyID KittyID, kState *KittyState) ExportEntry {
+	return ExportEntry{
+		KittyID:    kittyID,
+		Address:    kState.Address.String(),
+		LastTxHash: kState.LastTx.Hash.Hex(),
+		LastTxSeq:  kState.LastTx.Seq,
+		LastTxTime: kState.LastTx.TS,
+	}
+}
+
+// WriteExport writes the export entries to 'w' in the specified format.
+func WriteExport(w io.Writer, format ExportFormat, entries []ExportEntry) error {
+	switch format {
+	case ExportJSON:
+		return json.NewEncoder(w).Encode(entries)
+
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"kitty_id", "address", "last_tx_hash", "last_tx_seq", "last_tx_time"})
+		for _, entry := range entries {
+			cw.Write([]string{
+				strconv.FormatUint(uint64(entry.KittyID), 10),
+				entry.Address,
+				entry.LastTxHash,
+				strconv.FormatUint(entry.LastTxSeq, 10),
+				strconv.FormatInt(entry.LastTxTime, 10),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return fmt.Errorf("invalid export format '%s'", format)
+	}
+}
@@ -0,0 +1,141 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"time"
+)
+
+// TxMaxGroupSize is the maximum number of transactions in an atomic group.
+const TxMaxGroupSize = 16
+
+var errGroupedTx = errors.New("tx is part of a group, and needs to be injected with its group")
+
+// TxIntent is what a transaction in an atomic group transfers. The hash of the
+// intents of a group is committed to by every transaction in the group, so
+// that no transaction in the group can be injected without the others.
+// As intents do not depend on the chain, the group hash is known before any
+// of the transactions are created.
+type TxIntent struct {
+	Kitties KittyIDs
+	Nonce   uint64
+	From    cipher.Address
+	To      cipher.Address
+}
+
+// Intent obtains the intent of the transaction.
+func (tx Transaction) Intent() TxIntent {
+	return TxIntent{
+		Kitties: tx.Kitties(),
+		Nonce:   tx.Nonce,
+		From:    tx.From,
+		To:      tx.To,
+	}
+}
+
+// GroupHash obtains the hash of an atomic group from the intents, in the order
+// that the transactions are to be injected.
+func GroupHash(intents []TxIntent) cipher.SHA256 {
+	return cipher.SumSHA256(encoder.Serialize(intents))
+}
+
+// InjectTxGroup injects an atomic group of transactions, in order. Either every
+// transaction is accepted into the chain, or none are. This enables swaps of
+// kitties, where the transfers of each party only apply together.
+// The first transaction needs to be on top of the head of the chain, and
+// every other transaction on top of the transaction before it. Every
+// transaction needs to be in the group with the hash of the intents of the
+// transactions.
+func (bc *BlockChain) InjectTxGroup(txs []*Transaction) error {
+	if len(txs) == 0 || len(txs) > TxMaxGroupSize {
+		return fmt.Errorf("tx group needs between 1 and %d transactions", TxMaxGroupSize)
+	}
+	intents := make([]TxIntent, len(txs))
+	for i, tx := range txs {
+		intents[i] = tx.Intent()
+	}
+	group := GroupHash(intents)
+	for i, tx := range txs {
+		if tx.Group != group {
+			return fmt.Errorf("tx %d is not in group '%s'", i, group.Hex())
+		}
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	// The head is kept to undo the group, as the chain can not be rolled back
+	// to empty.
+	head := bc.head()
+	if head == nil {
+		return errors.New("tx group can not be injected into an empty chain")
+	}
+
+	// Check the whole group against a copy of the state first, so that the
+	// group is only injected if every transaction is valid.
+	raw, e := bc.state.Snapshot()
+	if e != nil {
+		return e
+	}
+	scratch := NewMemoryState()
+	if e := scratch.LoadSnapshot(raw); e != nil {
+		return e
+	}
+	if e := bc.checkGroupRate(txs); e != nil {
+		return e
+	}
+	prev := head
+	for i, tx := range txs {
+		if e := bc.checkTx(scratch, prev, tx); e != nil {
+			return fmt.Errorf("tx %d in group is invalid: %w", i, e)
+		}
+		prev = tx
+	}
+
+	// The chain may still fail to add a checked transaction, in which case the
+	// transactions in the group that are already injected are undone. The
+	// transactions only count towards the rate limits once the whole group is
+	// injected.
+	for i, tx := range txs {
+		if e := bc.addTx(tx); e != nil {
+			if i > 0 {
+				if e2 := bc.rollback(head.Seq); e2 != nil {
+					return fmt.Errorf("checked tx %d in group failed: %v, and failed to undo the group: %v", i, e, e2)
+				}
+			}
+			return fmt.Errorf("checked tx %d in group failed: %w", i, e)
+		}
+	}
+	for _, tx := range txs {
+		bc.recordRate(tx)
+	}
+	bc.log.
+		WithField("group", group.Hex()).
+		WithField("count", len(txs)).
+		Info("injected tx group")
+	bc.promoteTxs()
+	return nil
+}
+
+// checkGroupRate checks that the senders of the group are able to inject all
+// of their transactions in the group within the rate limit.
+func (bc *BlockChain) checkGroupRate(txs []*Transaction) error {
+	if bc.rate == nil || containsString(bc.c.DisabledTxChecks, TxCheckRate) {
+		return nil
+	}
+	counts := make(map[cipher.Address]int)
+	for _, tx := range txs {
+		if !tx.IsKittyGen(bc.c.CreatorPK) {
+			counts[tx.From]++
+		}
+	}
+	now := time.Now()
+	for address, n := range counts {
+		if e := bc.rate.Allow(address, n, now); e != nil {
+			return &TxCheckError{Stage: TxCheckRate, Err: withKind(ErrRateLimited, e)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/sirupsen/logrus.v1"
+	"sort"
+	"sync"
+)
+
+// TxHookPolicy determines what happens when a hook fails.
+type TxHookPolicy uint8
+
+const (
+	// TxHookContinue logs the error from the hook, and continues with the
+	// remaining hooks.
+	TxHookContinue TxHookPolicy = iota
+
+	// TxHookAbort stops the node (panics), as hooks that need to see every
+	// transaction (such as indexers) can not continue consistently. This is
+	// the policy of 'BlockChainConfig.TxAction'.
+	TxHookAbort
+)
+
+// TxHook is an action that is run after a transaction is committed to the
+// chain.
+type TxHook struct {
+	Name   string
+	Order  int // Hooks run in ascending order, and in order of registration within the same order.
+	Policy TxHookPolicy
+	Action TxAction
+}
+
+// TxHooks is an ordered list of hooks.
+type TxHooks struct {
+	mux   sync.RWMutex
+	hooks []TxHook
+}
+
+// Add registers a hook. Names of hooks need to be unique.
+func (h *TxHooks) Add(hook TxHook) error {
+	if hook.Name == "" {
+		return errors.New("tx hook needs a name")
+	}
+	if hook.Action == nil {
+		return fmt.Errorf("tx hook '%s' has no action", hook.Name)
+	}
+	if hook.Policy > TxHookAbort {
+		return fmt.Errorf("tx hook '%s' has invalid policy '%d'", hook.Name, hook.Policy)
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for _, v := range h.hooks {
+		if v.Name == hook.Name {
+			return fmt.Errorf("tx hook '%s' already exists", hook.Name)
+		}
+	}
+	h.hooks = append(h.hooks, hook)
+	sort.SliceStable(h.hooks, func(i, j int) bool {
+		return h.hooks[i].Order < h.hooks[j].Order
+	})
+	return nil
+}
+
+// Remove removes the hook with the name. It returns false if there is no such
+// hook.
+func (h *TxHooks) Remove(name string) bool {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for i, v := range h.hooks {
+		if v.Name == name {
+			h.hooks = append(h.hooks[:i], h.hooks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Names obtains the names of the hooks, in the order they run.
+func (h *TxHooks) Names() []string {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	out := make([]string, len(h.hooks))
+	for i, v := range h.hooks {
+		out[i] = v.Name
+	}
+	return out
+}
+
+// Run runs every hook on the transaction, applying the policy of hooks that
+// fail.
+func (h *TxHooks) Run(tx *Transaction, log logrus.FieldLogger) {
+	h.mux.RLock()
+	hooks := append([]TxHook{}, h.hooks...)
+	h.mux.RUnlock()
+
+	for _, hook := range hooks {
+		e := hook.Action(tx)
+		if e == nil {
+			continue
+		}
+		if hook.Policy == TxHookAbort {
+			panic(fmt.Errorf("tx hook '%s' failed: %v", hook.Name, e))
+		}
+		log.
+			WithField("hook", hook.Name).
+			WithField("tx", tx.String()).
+			WithError(e).
+			Warn("tx hook failed")
+	}
+}
@@ -0,0 +1,97 @@
+package iko
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"sync"
+)
+
+// TxHub broadcasts transactions that are accepted into the chain to subscribers.
+// Broadcasting never blocks: a subscriber that falls behind by more than the
+// buffer size of its subscription misses transactions.
+type TxHub struct {
+	mux  sync.RWMutex
+	subs map[*TxSubscription]struct{}
+}
+
+func NewTxHub() *TxHub {
+	return &TxHub{
+		subs: make(map[*TxSubscription]struct{}),
+	}
+}
+
+// TxSubscription receives transactions broadcast by a 'TxHub'.
+type TxSubscription struct {
+	hub *TxHub
+	c   chan *Transaction
+}
+
+// Subscribe creates a subscription with the specified buffer size.
+func (h *TxHub) Subscribe(bufferSize int) *TxSubscription {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	sub := &TxSubscription{
+		hub: h,
+		c:   make(chan *Transaction, bufferSize),
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Broadcast sends the transaction to all subscribers.
+func (h *TxHub) Broadcast(tx *Transaction) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.c <- tx:
+		default:
+		}
+	}
+}
+
+// C obtains the channel where broadcast transactions are received.
+// The channel is closed when the subscription is closed.
+func (s *TxSubscription) C() <-chan *Transaction {
+	return s.c
+}
+
+// Close unsubscribes from the hub.
+func (s *TxSubscription) Close() {
+	s.hub.mux.Lock()
+	defer s.hub.mux.Unlock()
+
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.c)
+	}
+}
+
+// TxFilter matches transactions with any of the addresses (as sender or
+// recipient), or with any of the kitties. Empty filters match every
+// transaction.
+type TxFilter struct {
+	Addresses []cipher.Address
+	Kitties   KittyIDs
+}
+
+// Match determines whether the transaction matches the filter.
+func (f TxFilter) Match(tx *Transaction) bool {
+	if len(f.Addresses) == 0 && len(f.Kitties) == 0 {
+		return true
+	}
+	for _, addr := range f.Addresses {
+		if tx.From == addr || tx.To == addr {
+			return true
+		}
+	}
+	for _, kittyID := range tx.Kitties() {
+		for _, want := range f.Kitties {
+			if kittyID == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package iko
 
 import (
+	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"sort"
@@ -36,18 +37,89 @@ func (ids *KittyIDs) Remove(id KittyID) {
 	}
 }
 
+// KittyStatus represents where a kitty is in its lifecycle.
+type KittyStatus string
+
+const (
+	// KittyUnclaimed is a kitty that is neither minted nor reserved.
+	KittyUnclaimed KittyStatus = "unclaimed"
+
+	// KittyReserved is a kitty that is held back from sale, but is not yet minted.
+	KittyReserved KittyStatus = "reserved"
+
+	// KittyMinted is a kitty that is created in the chain.
+	KittyMinted KittyStatus = "minted"
+
+	// KittyBurned is a kitty that is permanently retired from circulation by
+	// a transfer to 'BurnAddress'. It's history is kept.
+	KittyBurned KittyStatus = "burned"
+)
+
+func KittyStatusFromString(statusStr string) (KittyStatus, error) {
+	switch status := KittyStatus(statusStr); status {
+	case KittyUnclaimed, KittyReserved, KittyMinted, KittyBurned:
+		return status, nil
+	default:
+		return "", fmt.Errorf("invalid kitty status '%s'", statusStr)
+	}
+}
+
 type KittyState struct {
 	Address      cipher.Address
 	Transactions TxHashes
+	LastTx       TxRef    // The transaction that gave the kitty to 'Address'.
+	Parents      KittyIDs // Parents of the kitty, if it is bred.
+	Children     KittyIDs // Kitties bred from the kitty, in ascending order.
 }
 
 func (s KittyState) Serialize() []byte {
 	return encoder.Serialize(s)
 }
 
+// KittyTransition represents a change of ownership of a kitty.
+type KittyTransition struct {
+	Owner  cipher.Address
+	TxHash TxHash
+	Seq    uint64
+	Memo   string
+}
+
+// OwnershipChange represents a change of ownership of a kitty by a transaction.
+// 'From' is empty when the kitty is created.
+type OwnershipChange struct {
+	Tx      TxRef
+	KittyID KittyID
+	From    cipher.Address
+	To      cipher.Address
+	Fee     uint64         // Transfer fee paid by 'From' (once per transaction).
+	FeeTo   cipher.Address // Recipient of the fee.
+	Parents KittyIDs       // Parents of the created kitty (breeding only).
+}
+
+// IsCreation returns true if the change creates the kitty.
+func (c OwnershipChange) IsCreation() bool {
+	return c.From == (cipher.Address{})
+}
+
+// KittyDiff represents the difference in ownership of a kitty between two states.
+// 'From' is empty if the kitty did not exist in the earlier state.
+type KittyDiff struct {
+	KittyID KittyID
+	From    cipher.Address
+	To      cipher.Address
+}
+
 type AddressState struct {
 	Kitties      KittyIDs
 	Transactions TxHashes
+	Nonce        uint64 // Number of transactions sent by the address.
+	FeesPaid     uint64 // Total transfer fees paid by the address.
+	FeesReceived uint64 // Total transfer fees received by the address.
+}
+
+func (a AddressState) isEmpty() bool {
+	return len(a.Kitties) == 0 && len(a.Transactions) == 0 &&
+		a.FeesPaid == 0 && a.FeesReceived == 0
 }
 
 func NewAddressState() *AddressState {
@@ -0,0 +1,84 @@
+package iko
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultTxClockSkew is the default duration that the timestamp of a
+	// transaction can be ahead of the current time.
+	DefaultTxClockSkew = time.Minute
+)
+
+// DefaultTxLimits are the limits for transactions when none are configured.
+// These are also the maximum limits of the protocol.
+var DefaultTxLimits = TxLimits{
+	MaxMemoSize:  TxMaxMemoSize,
+	MaxKitties:   TxMaxKitties,
+	MaxClockSkew: DefaultTxClockSkew,
+}
+
+// Names of the limits in 'TxLimits', as reported by 'TxLimitError'.
+const (
+	TxLimitMemoSize  = "memo_size"
+	TxLimitKitties   = "kitties"
+	TxLimitClockSkew = "clock_skew"
+)
+
+// TxLimits are the structural limits for transactions that a node accepts.
+// The memo and kitty limits can be lower than those of the protocol, but not
+// higher.
+type TxLimits struct {
+	MaxMemoSize  int           // Maximum size of the memo in bytes.
+	MaxKitties   int           // Maximum number of kitties transferred.
+	MaxClockSkew time.Duration // Maximum duration that the timestamp is ahead of now.
+}
+
+// TxLimitError is returned when a transaction exceeds a limit in 'TxLimits'.
+type TxLimitError struct {
+	Limit string // Name of the limit (such as 'TxLimitMemoSize').
+	Value int64
+	Max   int64
+}
+
+func (e *TxLimitError) Error() string {
+	return fmt.Sprintf("tx %s '%d' exceeds limit '%d'", e.Limit, e.Value, e.Max)
+}
+
+// Prepare replaces zero limits with the defaults, and checks that the
+// limits are within those of the protocol.
+func (l *TxLimits) Prepare() error {
+	if l.MaxMemoSize <= 0 {
+		l.MaxMemoSize = DefaultTxLimits.MaxMemoSize
+	}
+	if l.MaxKitties <= 0 {
+		l.MaxKitties = DefaultTxLimits.MaxKitties
+	}
+	if l.MaxClockSkew <= 0 {
+		l.MaxClockSkew = DefaultTxLimits.MaxClockSkew
+	}
+	if l.MaxMemoSize > TxMaxMemoSize {
+		return fmt.Errorf("max memo size can not be more than %d bytes", TxMaxMemoSize)
+	}
+	if l.MaxKitties > TxMaxKitties {
+		return fmt.Errorf("max kitties can not be more than %d", TxMaxKitties)
+	}
+	return nil
+}
+
+// Check checks the transaction against the limits, where 'now' is the
+// current time in unix nanoseconds. A '*TxLimitError' is returned for the
+// first limit that is exceeded.
+func (l TxLimits) Check(tx *Transaction, now int64) error {
+	if size := len(tx.Memo); size > l.MaxMemoSize {
+		return &TxLimitError{Limit: TxLimitMemoSize, Value: int64(size), Max: int64(l.MaxMemoSize)}
+	}
+	if count := len(tx.Extra) + 1; count > l.MaxKitties {
+		return &TxLimitError{Limit: TxLimitKitties, Value: int64(count), Max: int64(l.MaxKitties)}
+	}
+	if skew := tx.TS - now; skew > int64(l.MaxClockSkew) {
+		return &TxLimitError{Limit: TxLimitClockSkew, Value: skew, Max: int64(l.MaxClockSkew)}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultMempoolTTL is the default duration that a transaction waits in
+	// the mempool before it is dropped.
+	DefaultMempoolTTL = 10 * time.Minute
+)
+
+// PendingTx is a transaction that waits in the mempool.
+type PendingTx struct {
+	Tx       Transaction
+	Received time.Time
+}
+
+// Mempool holds transactions that are syntactically valid, but are not yet
+// able to be accepted into the chain. For example, a transaction may refer
+// to a previous transaction that is not yet received, or a multisig transfer
+// may still wait for signatures (such as the master co-signature).
+// Mempool is not safe for concurrent use.
+type Mempool struct {
+	size int
+	ttl  time.Duration
+	txs  map[cipher.SHA256]*PendingTx // key: signature hash
+}
+
+// NewMempool creates a mempool with a maximum number of transactions, where
+// transactions are dropped after the specified ttl.
+func NewMempool(size int, ttl time.Duration) *Mempool {
+	return &Mempool{
+		size: size,
+		ttl:  ttl,
+		txs:  make(map[cipher.SHA256]*PendingTx),
+	}
+}
+
+// Add checks the parts of the transaction that do not depend on the chain,
+// and adds it to the mempool. If a multisig transaction with the same signature
+// hash is already pending, the signatures are merged instead.
+func (m *Mempool) Add(tx Transaction, now time.Time) error {
+	if tx.IsGrouped() {
+		return errGroupedTx
+	}
+	if e := tx.verifyContent(); e != nil {
+		return e
+	}
+	if e := tx.CheckExpiry(now.UnixNano()); e != nil {
+		return e
+	}
+	if e := tx.verifyPartialSig(); e != nil {
+		return e
+	}
+	hash := tx.SignatureHash()
+	if p, ok := m.txs[hash]; ok {
+		if tx.Signers.IsZero() {
+			return kindErrorf(ErrDuplicateTx, "tx with signature hash '%s' is already pending", hash.Hex())
+		}
+		return p.Tx.mergeSigs(tx.Sigs)
+	}
+	m.Prune(now)
+	if len(m.txs) >= m.size {
+		return fmt.Errorf("mempool is full (%d transactions)", m.size)
+	}
+	m.txs[hash] = &PendingTx{
+		Tx:       tx,
+		Received: now,
+	}
+	return nil
+}
+
+// Remove removes a transaction with the signature hash from the mempool.
+func (m *Mempool) Remove(hash cipher.SHA256) {
+	delete(m.txs, hash)
+}
+
+// Clear drops every pending transaction.
+func (m *Mempool) Clear() {
+	m.txs = make(map[cipher.SHA256]*PendingTx)
+}
+
+// Prune drops the transactions that waited for longer than the ttl, or that
+// have expired. It returns the number of dropped transactions.
+func (m *Mempool) Prune(now time.Time) int {
+	count := 0
+	for hash, p := range m.txs {
+		if now.Sub(p.Received) > m.ttl || p.Tx.CheckExpiry(now.UnixNano()) != nil {
+			delete(m.txs, hash)
+			count++
+		}
+	}
+	return count
+}
+
+// Txs obtains the pending transactions ordered by seq, then by the time that
+// they are received.
+func (m *Mempool) Txs() []PendingTx {
+	out := make([]PendingTx, 0, len(m.txs))
+	for _, p := range m.txs {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tx.Seq != out[j].Tx.Seq {
+			return out[i].Tx.Seq < out[j].Tx.Seq
+		}
+		return out[i].Received.Before(out[j].Received)
+	})
+	return out
+}
+
+// Len obtains the number of pending transactions.
+func (m *Mempool) Len() int {
+	return len(m.txs)
+}
+
+// verifyPartialSig checks that the signatures of a transaction are valid,
+// without requiring the threshold of signatures for multisig addresses.
+func (tx Transaction) verifyPartialSig() error {
+	if tx.Signers.IsZero() {
+		return tx.verifySig()
+	}
+	if e := tx.Signers.Verify(); e != nil {
+		return e
+	}
+	if tx.Signers.Address() != tx.From {
+		return errors.New("multisig account does not match from address")
+	}
+	_, e := tx.signedBy()
+	return e
+}
+
+// signedBy obtains the public keys of the multisig account that signed the
+// transaction.
+func (tx Transaction) signedBy() (map[cipher.PubKey]struct{}, error) {
+	var (
+		hash   = tx.SignatureHash()
+		signed = make(map[cipher.PubKey]struct{}, len(tx.Sigs))
+	)
+	for _, sig := range tx.Sigs {
+		pk, e := cipher.PubKeyFromSig(sig, hash)
+		if e != nil {
+			return nil, e
+		}
+		if !tx.Signers.has(pk) {
+			return nil, fmt.Errorf("signature of public key '%s' is not from multisig account", pk.Hex())
+		}
+		if _, ok := signed[pk]; ok {
+			return nil, fmt.Errorf("public key '%s' signed more than once", pk.Hex())
+		}
+		signed[pk] = struct{}{}
+	}
+	return signed, nil
+}
+
+// mergeSigs adds the signatures from signers that have not yet signed.
+func (tx *Transaction) mergeSigs(sigs []cipher.Sig) error {
+	signed, e := tx.signedBy()
+	if e != nil {
+		return e
+	}
+	hash := tx.SignatureHash()
+	for _, sig := range sigs {
+		pk, e := cipher.PubKeyFromSig(sig, hash)
+		if e != nil {
+			return e
+		}
+		if _, ok := signed[pk]; ok {
+			continue
+		}
+		tx.Sigs = append(tx.Sigs, sig)
+		signed[pk] = struct{}{}
+	}
+	return nil
+}
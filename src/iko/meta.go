@@ -0,0 +1,203 @@
+package iko
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"io/ioutil"
+	"sync"
+)
+
+// KittyAttribute is a named attribute of a kitty's breed.
+type KittyAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// KittyMeta represents the descriptive metadata of a kitty.
+type KittyMeta struct {
+	Name       string
+	Breed      string
+	Attributes []KittyAttribute
+	ImageHash  cipher.SHA256
+	MintBatch  uint64
+}
+
+// Hash obtains the hash of the metadata, as recorded by gen transactions.
+func (m KittyMeta) Hash() cipher.SHA256 {
+	return cipher.SumSHA256(encoder.Serialize(m))
+}
+
+// MintMeta is what a gen transaction records about the kitty that it creates.
+type MintMeta struct {
+	AttrHash cipher.SHA256 // Hash of the kitty's metadata (see 'KittyMeta.Hash').
+	URI      string        // Where the kitty's metadata is published (optional).
+}
+
+// IsZero returns true if there is no mint metadata.
+func (m MintMeta) IsZero() bool {
+	return m == MintMeta{}
+}
+
+// SignedKittyMeta is kitty metadata signed by the master key.
+// Only metadata signed by the master key is accepted by the blockchain.
+type SignedKittyMeta struct {
+	KittyID KittyID
+	Meta    KittyMeta
+	Sig     cipher.Sig
+}
+
+// NewSignedKittyMeta signs the metadata of a kitty with the master secret key.
+func NewSignedKittyMeta(kittyID KittyID, meta KittyMeta, sk cipher.SecKey) *SignedKittyMeta {
+	m := &SignedKittyMeta{
+		KittyID: kittyID,
+		Meta:    meta,
+	}
+	m.Sig = cipher.SignHash(m.HashInner(), sk)
+	return m
+}
+
+// HashInner obtains the hash of the signed content.
+func (m SignedKittyMeta) HashInner() cipher.SHA256 {
+	return cipher.SumSHA256(encoder.Serialize(struct {
+		KittyID KittyID
+		Meta    KittyMeta
+	}{m.KittyID, m.Meta}))
+}
+
+// Verify checks that the metadata is signed by the specified public key.
+func (m SignedKittyMeta) Verify(pk cipher.PubKey) error {
+	return cipher.VerifySignature(pk, m.Sig, m.HashInner())
+}
+
+// MetaDB records the metadata of kitties.
+type MetaDB interface {
+
+	// GetKittyMeta obtains the metadata of a kitty.
+	// It should return false if there is no metadata for the kitty.
+	GetKittyMeta(kittyID KittyID) (*KittyMeta, bool)
+
+	// SetKittyMeta sets the metadata of a kitty, replacing any previous metadata.
+	// Checks on whether the metadata is allowed shouldn't happen here.
+	SetKittyMeta(kittyID KittyID, meta KittyMeta) error
+}
+
+type MemoryMetaDB struct {
+	sync.RWMutex
+	metas map[KittyID]KittyMeta
+}
+
+func NewMemoryMetaDB() *MemoryMetaDB {
+	return &MemoryMetaDB{
+		metas: make(map[KittyID]KittyMeta),
+	}
+}
+
+func (db *MemoryMetaDB) GetKittyMeta(kittyID KittyID) (*KittyMeta, bool) {
+	db.RLock()
+	defer db.RUnlock()
+
+	meta, ok := db.metas[kittyID]
+	if !ok {
+		return nil, false
+	}
+	return &meta, true
+}
+
+func (db *MemoryMetaDB) SetKittyMeta(kittyID KittyID, meta KittyMeta) error {
+	db.Lock()
+	defer db.Unlock()
+
+	db.metas[kittyID] = meta
+	return nil
+}
+
+/*
+	<<< SIDECAR FILE >>>
+*/
+
+// FloatingKittyMeta is the readable representation of signed kitty metadata.
+// A metadata sidecar file is a JSON array of these.
+type FloatingKittyMeta struct {
+	KittyID    KittyID          `json:"kitty_id"`
+	Name       string           `json:"name"`
+	Breed      string           `json:"breed"`
+	Attributes []KittyAttribute `json:"attributes"`
+	ImageHash  string           `json:"image_hash"`
+	MintBatch  uint64           `json:"mint_batch"`
+	Sig        string           `json:"sig,omitempty"`
+}
+
+func (m SignedKittyMeta) ToFloating() FloatingKittyMeta {
+	return FloatingKittyMeta{
+		KittyID:    m.KittyID,
+		Name:       m.Meta.Name,
+		Breed:      m.Meta.Breed,
+		Attributes: m.Meta.Attributes,
+		ImageHash:  m.Meta.ImageHash.Hex(),
+		MintBatch:  m.Meta.MintBatch,
+		Sig:        m.Sig.Hex(),
+	}
+}
+
+// ToSigned converts the readable representation back to signed metadata.
+// An empty signature is allowed, so that unsigned files can be signed.
+func (fm FloatingKittyMeta) ToSigned() (*SignedKittyMeta, error) {
+	m := &SignedKittyMeta{
+		KittyID: fm.KittyID,
+		Meta: KittyMeta{
+			Name:       fm.Name,
+			Breed:      fm.Breed,
+			Attributes: fm.Attributes,
+			MintBatch:  fm.MintBatch,
+		},
+	}
+	if fm.ImageHash != "" {
+		hash, e := cipher.SHA256FromHex(fm.ImageHash)
+		if e != nil {
+			return nil, fmt.Errorf("kitty of id '%d' has invalid image hash: %v", fm.KittyID, e)
+		}
+		m.Meta.ImageHash = hash
+	}
+	if fm.Sig != "" {
+		sig, e := cipher.SigFromHex(fm.Sig)
+		if e != nil {
+			return nil, fmt.Errorf("kitty of id '%d' has invalid sig: %v", fm.KittyID, e)
+		}
+		m.Sig = sig
+	}
+	return m, nil
+}
+
+// LoadKittyMetaFile reads a metadata sidecar file.
+func LoadKittyMetaFile(fPath string) ([]*SignedKittyMeta, error) {
+	raw, e := ioutil.ReadFile(fPath)
+	if e != nil {
+		return nil, e
+	}
+	var fms []FloatingKittyMeta
+	if e := json.Unmarshal(raw, &fms); e != nil {
+		return nil, e
+	}
+	out := make([]*SignedKittyMeta, len(fms))
+	for i, fm := range fms {
+		if out[i], e = fm.ToSigned(); e != nil {
+			return nil, e
+		}
+	}
+	return out, nil
+}
+
+// SaveKittyMetaFile writes a metadata sidecar file.
+func SaveKittyMetaFile(fPath string, metas []*SignedKittyMeta) error {
+	fms := make([]FloatingKittyMeta, len(metas))
+	for i, m := range metas {
+		fms[i] = m.ToFloating()
+	}
+	raw, e := json.MarshalIndent(fms, "", "    ")
+	if e != nil {
+		return e
+	}
+	return ioutil.WriteFile(fPath, raw, 0600)
+}
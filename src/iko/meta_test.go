@@ -0,0 +1,60 @@
+package iko
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignedKittyMeta_Verify(t *testing.T) {
+	sk := testSecKey
+	pk := cipher.PubKeyFromSecKey(sk)
+
+	m := NewSignedKittyMeta(KittyID(3), KittyMeta{
+		Name:       "Fluffy",
+		Breed:      "Persian",
+		Attributes: []KittyAttribute{{Name: "eyes", Value: "blue"}},
+		ImageHash:  cipher.SumSHA256([]byte("fluffy.png")),
+		MintBatch:  1,
+	}, sk)
+
+	require.Nil(t, m.Verify(pk), "Metadata should be signed by the master key")
+
+	m.Meta.Name = "Scruffy"
+	require.NotNil(t, m.Verify(pk), "Changing the metadata should invalidate the signature")
+}
+
+func TestKittyMetaFile(t *testing.T) {
+	sk := testSecKey
+
+	dir, err := ioutil.TempDir("", "kittycash_test")
+	require.Nil(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	fPath := filepath.Join(dir, "kitty_meta.json")
+	metas := []*SignedKittyMeta{
+		NewSignedKittyMeta(KittyID(1), KittyMeta{Name: "Tom", MintBatch: 1}, sk),
+		NewSignedKittyMeta(KittyID(2), KittyMeta{Name: "Kit", ImageHash: cipher.SumSHA256([]byte{2})}, sk),
+	}
+	require.Nil(t, SaveKittyMetaFile(fPath, metas), "Saving the metadata file should succeed")
+
+	loaded, err := LoadKittyMetaFile(fPath)
+	require.Nil(t, err, "Loading the metadata file should succeed")
+	require.Equal(t, metas, loaded, "Loaded metadata should match saved metadata")
+
+	bc := newTestBlockChain(t, BlockChainConfig{MetaDB: NewMemoryMetaDB()})
+	defer bc.Close()
+
+	for _, m := range loaded {
+		require.Nil(t, bc.SetKittyMeta(m), "Setting signed metadata should succeed")
+	}
+	meta, ok := bc.GetKittyMeta(KittyID(1))
+	require.True(t, ok, "Metadata should exist")
+	require.Equal(t, "Tom", meta.Name, "Metadata should match")
+
+	unsigned := &SignedKittyMeta{KittyID: KittyID(3), Meta: KittyMeta{Name: "Stray"}}
+	require.NotNil(t, bc.SetKittyMeta(unsigned), "Unsigned metadata should be rejected")
+}
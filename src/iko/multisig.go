@@ -0,0 +1,113 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// TxMaxSigners is the maximum number of public keys in a multisig account.
+const TxMaxSigners = 16
+
+// multisigDomain separates the address hashes of multisig accounts from
+// those of single public keys.
+var multisigDomain = []byte("kittycash-multisig")
+
+// MultisigAccount is a set of public keys of which 'Threshold' signatures
+// are needed to transfer the kitties owned by the account's address.
+type MultisigAccount struct {
+	Threshold uint8
+	PubKeys   []cipher.PubKey
+}
+
+// NewMultisigAccount creates an m-of-n multisig account.
+func NewMultisigAccount(threshold uint8, pks []cipher.PubKey) (MultisigAccount, error) {
+	a := MultisigAccount{
+		Threshold: threshold,
+		PubKeys:   pks,
+	}
+	return a, a.Verify()
+}
+
+// IsZero returns true if the account is empty (the tx is from a single signer).
+func (a MultisigAccount) IsZero() bool {
+	return a.Threshold == 0 && len(a.PubKeys) == 0
+}
+
+// Verify checks that the threshold and public keys are valid.
+func (a MultisigAccount) Verify() error {
+	if len(a.PubKeys) == 0 || len(a.PubKeys) > TxMaxSigners {
+		return fmt.Errorf("multisig account needs between 1 and %d public keys", TxMaxSigners)
+	}
+	if a.Threshold == 0 || int(a.Threshold) > len(a.PubKeys) {
+		return fmt.Errorf("invalid multisig threshold '%d' for %d public keys",
+			a.Threshold, len(a.PubKeys))
+	}
+	seen := make(map[cipher.PubKey]struct{}, len(a.PubKeys))
+	for _, pk := range a.PubKeys {
+		if e := pk.Verify(); e != nil {
+			return e
+		}
+		if _, ok := seen[pk]; ok {
+			return fmt.Errorf("public key '%s' is in multisig account more than once", pk.Hex())
+		}
+		seen[pk] = struct{}{}
+	}
+	return nil
+}
+
+// Address obtains the address that the kitties of the account are owned under.
+// The order of the public keys matters.
+func (a MultisigAccount) Address() cipher.Address {
+	raw := append(append([]byte{}, multisigDomain...), encoder.Serialize(a)...)
+	r1 := cipher.SumSHA256(raw)
+	r2 := cipher.SumSHA256(r1[:])
+	return cipher.Address{
+		Key: cipher.HashRipemd160(r2[:]),
+	}
+}
+
+// VerifySigs checks that the hash is signed by at least 'Threshold' of the
+// account's distinct public keys.
+func (a MultisigAccount) VerifySigs(hash cipher.SHA256, sigs []cipher.Sig) error {
+	if e := a.Verify(); e != nil {
+		return e
+	}
+	index := make(map[cipher.PubKey]bool, len(a.PubKeys))
+	for _, pk := range a.PubKeys {
+		index[pk] = false
+	}
+	count := 0
+	for _, sig := range sigs {
+		pk, e := cipher.PubKeyFromSig(sig, hash)
+		if e != nil {
+			return e
+		}
+		signed, ok := index[pk]
+		if !ok {
+			return fmt.Errorf("signature of public key '%s' is not from multisig account", pk.Hex())
+		}
+		if signed {
+			return fmt.Errorf("public key '%s' signed more than once", pk.Hex())
+		}
+		if e := cipher.VerifySignature(pk, sig, hash); e != nil {
+			return e
+		}
+		index[pk] = true
+		count++
+	}
+	if count < int(a.Threshold) {
+		return errors.New("not enough signatures for multisig threshold")
+	}
+	return nil
+}
+
+func (a MultisigAccount) has(pk cipher.PubKey) bool {
+	for _, v := range a.PubKeys {
+		if v == pk {
+			return true
+		}
+	}
+	return false
+}
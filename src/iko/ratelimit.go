@@ -0,0 +1,79 @@
+package iko
+
+import (
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTxRateWindow is the default window of the rate limit for
+	// injected transactions.
+	DefaultTxRateWindow = time.Minute
+)
+
+// RateLimiter limits the number of transactions that each address injects
+// within a sliding window of time.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+	mux    sync.Mutex
+	txs    map[cipher.Address][]time.Time // key: sender address, value: times of injection
+}
+
+// NewRateLimiter creates a rate limiter with a maximum number of transactions
+// per address within the window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		txs:    make(map[cipher.Address][]time.Time),
+	}
+}
+
+// Allow returns an error if the address can not inject 'n' more transactions
+// at the specified time.
+func (r *RateLimiter) Allow(address cipher.Address, n int, now time.Time) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if count := len(r.prune(address, now)); count+n > r.limit {
+		return fmt.Errorf("address '%s' exceeded the rate of '%d' txs per '%s'",
+			address.String(), r.limit, r.window)
+	}
+	return nil
+}
+
+// Record records that the address injected a transaction at the specified
+// time.
+func (r *RateLimiter) Record(address cipher.Address, now time.Time) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.txs[address] = append(r.prune(address, now), now)
+}
+
+// Reset forgets the injections by every address.
+func (r *RateLimiter) Reset() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.txs = make(map[cipher.Address][]time.Time)
+}
+
+// prune drops the times for the address that are out of the window, and
+// returns the remaining times.
+func (r *RateLimiter) prune(address cipher.Address, now time.Time) []time.Time {
+	times := r.txs[address]
+	i := 0
+	for i < len(times) && !times[i].After(now.Add(-r.window)) {
+		i++
+	}
+	if times = times[i:]; len(times) == 0 {
+		delete(r.txs, address)
+		return nil
+	}
+	r.txs[address] = times
+	return times
+}
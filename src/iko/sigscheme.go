@@ -0,0 +1,253 @@
+package iko
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// SigScheme creates and verifies the signatures of the senders of
+// transactions. The scheme of a transaction is determined by its 'Scheme'
+// field (see 'TxSigScheme').
+type SigScheme interface {
+
+	// ID obtains the ID of the scheme, as recorded in transactions.
+	ID() uint8
+
+	// Name obtains the name of the scheme.
+	Name() string
+
+	// Sign signs the hash with a secret key for the scheme. It returns the
+	// public key to be carried by the transaction (nil for schemes where the
+	// public key is recovered from the signature), and the signature.
+	Sign(hash cipher.SHA256, sk []byte) (pk, sig []byte, e error)
+
+	// Verify checks that the signature of the hash is by the key of the
+	// address. 'pk' is the public key carried by the transaction.
+	Verify(address cipher.Address, hash cipher.SHA256, pk, sig []byte) error
+
+	// Address obtains the address of a public key for the scheme.
+	Address(pk []byte) (cipher.Address, error)
+}
+
+var (
+	// Secp256k1Scheme is the default scheme for transactions, with ID 0.
+	Secp256k1Scheme SigScheme = secp256k1Scheme{}
+
+	// Ed25519Scheme is the ed25519 scheme for transactions, with ID 1. Only
+	// transactions of the extended version can use it.
+	Ed25519Scheme SigScheme = ed25519Scheme{}
+)
+
+// sigSchemes are the known signature schemes, indexed by ID.
+var sigSchemes = []SigScheme{Secp256k1Scheme, Ed25519Scheme}
+
+// TxSigScheme obtains the signature scheme with an ID. Unknown IDs get a
+// scheme that never signs nor verifies.
+func TxSigScheme(id uint8) SigScheme {
+	if int(id) < len(sigSchemes) {
+		return sigSchemes[id]
+	}
+	return unknownScheme(id)
+}
+
+// SigSchemeFromName obtains the signature scheme with a name.
+func SigSchemeFromName(name string) (SigScheme, error) {
+	for _, scheme := range sigSchemes {
+		if scheme.Name() == name {
+			return scheme, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid signature scheme '%s'", name)
+}
+
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) ID() uint8 {
+	return 0
+}
+
+func (secp256k1Scheme) Name() string {
+	return "secp256k1"
+}
+
+func (secp256k1Scheme) Sign(hash cipher.SHA256, sk []byte) ([]byte, []byte, error) {
+	if len(sk) != len(cipher.SecKey{}) {
+		return nil, nil, fmt.Errorf("invalid secp256k1 secret key length '%d'", len(sk))
+	}
+	sig := cipher.SignHash(hash, cipher.NewSecKey(sk))
+	return nil, sig[:], nil
+}
+
+func (secp256k1Scheme) Verify(address cipher.Address, hash cipher.SHA256, pk, sig []byte) error {
+	if len(pk) != 0 {
+		return errors.New("secp256k1 signatures do not carry a public key")
+	}
+	if len(sig) != len(cipher.Sig{}) {
+		return fmt.Errorf("invalid secp256k1 signature length '%d'", len(sig))
+	}
+	return cipher.ChkSig(address, hash, cipher.NewSig(sig))
+}
+
+func (secp256k1Scheme) Address(pk []byte) (cipher.Address, error) {
+	if len(pk) != len(cipher.PubKey{}) {
+		return cipher.Address{}, fmt.Errorf("invalid secp256k1 public key length '%d'", len(pk))
+	}
+	return cipher.AddressFromPubKey(cipher.NewPubKey(pk)), nil
+}
+
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) ID() uint8 {
+	return 1
+}
+
+func (ed25519Scheme) Name() string {
+	return "ed25519"
+}
+
+// Sign accepts either the 32 byte seed or the 64 byte private key.
+func (ed25519Scheme) Sign(hash cipher.SHA256, sk []byte) ([]byte, []byte, error) {
+	var key ed25519.PrivateKey
+	switch len(sk) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(sk)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(sk)
+	default:
+		return nil, nil, fmt.Errorf("invalid ed25519 secret key length '%d'", len(sk))
+	}
+	pk := key.Public().(ed25519.PublicKey)
+	return []byte(pk), ed25519.Sign(key, hash[:]), nil
+}
+
+func (s ed25519Scheme) Verify(address cipher.Address, hash cipher.SHA256, pk, sig []byte) error {
+	pkAddress, e := s.Address(pk)
+	if e != nil {
+		return e
+	}
+	if pkAddress != address {
+		return errors.New("ed25519 public key does not match address")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pk), hash[:], sig) {
+		return errors.New("invalid ed25519 signature")
+	}
+	return nil
+}
+
+// Address derives the address as for secp256k1 keys, as
+// ripemd160(sha256(sha256(pk))).
+func (ed25519Scheme) Address(pk []byte) (cipher.Address, error) {
+	if len(pk) != ed25519.PublicKeySize {
+		return cipher.Address{}, fmt.Errorf("invalid ed25519 public key length '%d'", len(pk))
+	}
+	h := cipher.SumSHA256(pk)
+	h = cipher.SumSHA256(h[:])
+	return cipher.Address{Key: cipher.HashRipemd160(h[:])}, nil
+}
+
+// unknownScheme is the scheme for transactions with an unrecognized scheme ID.
+type unknownScheme uint8
+
+func (s unknownScheme) ID() uint8 {
+	return uint8(s)
+}
+
+func (s unknownScheme) Name() string {
+	return fmt.Sprintf("unknown(%d)", uint8(s))
+}
+
+func (s unknownScheme) Sign(cipher.SHA256, []byte) ([]byte, []byte, error) {
+	return nil, nil, fmt.Errorf("invalid signature scheme '%d'", uint8(s))
+}
+
+func (s unknownScheme) Verify(cipher.Address, cipher.SHA256, []byte, []byte) error {
+	return fmt.Errorf("invalid signature scheme '%d'", uint8(s))
+}
+
+func (s unknownScheme) Address([]byte) (cipher.Address, error) {
+	return cipher.Address{}, fmt.Errorf("invalid signature scheme '%d'", uint8(s))
+}
+
+// Ed25519Address obtains the address of an ed25519 public key.
+func Ed25519Address(pk ed25519.PublicKey) (cipher.Address, error) {
+	return Ed25519Scheme.Address(pk)
+}
+
+// SigScheme obtains the signature scheme of the transaction.
+func (tx Transaction) SigScheme() SigScheme {
+	return TxSigScheme(tx.Scheme)
+}
+
+// UseScheme changes the signature scheme of an unsigned transaction. Schemes
+// other than secp256k1 change it to the extended version.
+func (tx *Transaction) UseScheme(scheme SigScheme) error {
+	if _, ok := scheme.(unknownScheme); ok {
+		return fmt.Errorf("tx can not be signed with scheme '%s'", scheme.Name())
+	}
+	tx.Scheme = scheme.ID()
+	if scheme != Secp256k1Scheme {
+		tx.extend()
+	}
+	return nil
+}
+
+// SignScheme signs the transaction with a secret key for its scheme (the
+// scheme needs to be set with 'UseScheme' before signing). Transactions from
+// multisig addresses or delegations are signed with 'MultiSign' and 'Sign'.
+func (tx *Transaction) SignScheme(sk []byte) error {
+	pk, sig, e := tx.SigScheme().Sign(tx.SignatureHash(), sk)
+	if e != nil {
+		return e
+	}
+	return tx.AttachSchemeSignature(pk, sig)
+}
+
+// AttachSchemeSignature attaches a signature (and public key, if the scheme
+// carries it) for the scheme of the transaction, that is produced offline.
+func (tx *Transaction) AttachSchemeSignature(pk, sig []byte) error {
+	scheme := tx.SigScheme()
+	if e := scheme.Verify(tx.From, tx.SignatureHash(), pk, sig); e != nil {
+		return e
+	}
+	if scheme == Secp256k1Scheme {
+		copy(tx.Sig[:], sig)
+		return nil
+	}
+	tx.SchemeKey = append([]byte{}, pk...)
+	tx.SchemeSig = append([]byte{}, sig...)
+	return nil
+}
+
+// schemeSig obtains the public key and signature of the sender, in the
+// encoding of the scheme of the transaction.
+func (tx Transaction) schemeSig() ([]byte, []byte) {
+	if tx.SigScheme() == Secp256k1Scheme {
+		return nil, tx.Sig[:]
+	}
+	return tx.SchemeKey, tx.SchemeSig
+}
+
+// verifyScheme checks the signature scheme of a transaction, which does not
+// depend on the chain. Only single signer transfers can use schemes other
+// than secp256k1.
+func (tx Transaction) verifyScheme() error {
+	scheme := tx.SigScheme()
+	if scheme == Secp256k1Scheme {
+		if len(tx.SchemeKey) != 0 || len(tx.SchemeSig) != 0 {
+			return errors.New("secp256k1 tx can not carry a scheme key or signature")
+		}
+		return nil
+	}
+	if _, ok := scheme.(unknownScheme); ok {
+		return fmt.Errorf("invalid signature scheme '%d'", tx.Scheme)
+	}
+	if !tx.Signers.IsZero() || tx.IsDelegated() || tx.Cosig != (cipher.Sig{}) {
+		return fmt.Errorf("%s tx can only be signed by its sender", scheme.Name())
+	}
+	if tx.Sig != (cipher.Sig{}) {
+		return fmt.Errorf("%s tx has a secp256k1 signature", scheme.Name())
+	}
+	return nil
+}
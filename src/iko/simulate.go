@@ -0,0 +1,37 @@
+package iko
+
+// SimulateTx runs the transaction through the same checks as 'InjectTx', and
+// applies it to a copy of the state. Nothing is persisted. The resulting
+// ownership of every kitty in the transaction is returned, in the order of
+// the kitties in the transaction.
+func (bc *BlockChain) SimulateTx(tx *Transaction) ([]KittyDiff, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	raw, e := bc.state.Snapshot()
+	if e != nil {
+		return nil, e
+	}
+	scratch := NewMemoryState()
+	if e := scratch.LoadSnapshot(raw); e != nil {
+		return nil, e
+	}
+
+	if tx.IsGrouped() {
+		return nil, errGroupedTx
+	}
+	if e := bc.checkTx(scratch, bc.head(), tx); e != nil {
+		return nil, e
+	}
+
+	changes := bc.txChanges(tx)
+	out := make([]KittyDiff, len(changes))
+	for i, c := range changes {
+		out[i] = KittyDiff{
+			KittyID: c.KittyID,
+			From:    c.From,
+			To:      c.To,
+		}
+	}
+	return out, nil
+}
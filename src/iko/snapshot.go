@@ -0,0 +1,243 @@
+package iko
+
+import (
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/util/file"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotExt is the file extension of state snapshots saved by 'FileSnapshotDB'.
+const SnapshotExt = ".snap"
+
+// StateSnapshot is a full copy of the state, taken after the transaction at
+// sequence 'Seq' (and hash 'Head') is applied.
+type StateSnapshot struct {
+	Seq   uint64
+	Head  TxHash
+	State []byte
+}
+
+// SnapshotDB represents where state snapshots are stored.
+type SnapshotDB interface {
+
+	// Save should store the snapshot.
+	// A previous snapshot at the same sequence should be replaced.
+	Save(snap StateSnapshot) error
+
+	// Nearest should obtain the snapshot at the highest sequence that is
+	// not greater than the specified sequence.
+	// It should return false if there is no such snapshot.
+	Nearest(seq uint64) (StateSnapshot, bool, error)
+
+	// Prune should delete the snapshots that are outside of the retention
+	// policy, and return the number of snapshots deleted.
+	// The snapshot at the highest sequence should never be deleted.
+	Prune(policy SnapshotRetention) (int, error)
+}
+
+// SnapshotRetention determines which snapshots are kept when pruning.
+type SnapshotRetention struct {
+	KeepLast int           // Number of latest snapshots to keep (0 keeps all).
+	MaxAge   time.Duration // Snapshots saved earlier than this are deleted (0 keeps all).
+}
+
+// IsZero returns true if the policy keeps every snapshot.
+func (p SnapshotRetention) IsZero() bool {
+	return p.KeepLast <= 0 && p.MaxAge <= 0
+}
+
+// expired determines whether a snapshot should be deleted, given its rank
+// (0 being the snapshot at the highest sequence) and when it was saved.
+func (p SnapshotRetention) expired(rank int, saved, now time.Time) bool {
+	if rank == 0 {
+		return false
+	}
+	if p.KeepLast > 0 && rank >= p.KeepLast {
+		return true
+	}
+	if p.MaxAge > 0 && now.Sub(saved) > p.MaxAge {
+		return true
+	}
+	return false
+}
+
+type MemorySnapshotDB struct {
+	sync.RWMutex
+	snaps map[uint64]StateSnapshot
+	saved map[uint64]time.Time
+}
+
+func NewMemorySnapshotDB() *MemorySnapshotDB {
+	return &MemorySnapshotDB{
+		snaps: make(map[uint64]StateSnapshot),
+		saved: make(map[uint64]time.Time),
+	}
+}
+
+func (db *MemorySnapshotDB) Save(snap StateSnapshot) error {
+	db.Lock()
+	defer db.Unlock()
+
+	db.snaps[snap.Seq] = snap
+	db.saved[snap.Seq] = time.Now()
+	return nil
+}
+
+func (db *MemorySnapshotDB) Prune(policy SnapshotRetention) (int, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	seqs := make([]uint64, 0, len(db.snaps))
+	for seq := range db.snaps {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] > seqs[j]
+	})
+
+	var (
+		now   = time.Now()
+		count int
+	)
+	for rank, seq := range seqs {
+		if policy.expired(rank, db.saved[seq], now) {
+			delete(db.snaps, seq)
+			delete(db.saved, seq)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *MemorySnapshotDB) Nearest(seq uint64) (StateSnapshot, bool, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	var (
+		out   StateSnapshot
+		found bool
+	)
+	for s, snap := range db.snaps {
+		if s <= seq && (!found || s > out.Seq) {
+			out, found = snap, true
+		}
+	}
+	return out, found, nil
+}
+
+// FileSnapshotDB stores each snapshot as a file under a directory,
+// named after the sequence of the snapshot.
+type FileSnapshotDB struct {
+	mux sync.Mutex
+	dir string
+}
+
+func NewFileSnapshotDB(dir string) (*FileSnapshotDB, error) {
+	dir, e := filepath.Abs(dir)
+	if e != nil {
+		return nil, e
+	}
+	if e := os.MkdirAll(dir, os.FileMode(0700)); e != nil {
+		return nil, e
+	}
+	return &FileSnapshotDB{dir: dir}, nil
+}
+
+func (db *FileSnapshotDB) Save(snap StateSnapshot) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return file.SaveBinary(db.seqPath(snap.Seq), encoder.Serialize(snap), os.FileMode(0600))
+}
+
+func (db *FileSnapshotDB) Nearest(seq uint64) (StateSnapshot, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	seqs, e := db.seqs()
+	if e != nil {
+		return StateSnapshot{}, false, e
+	}
+	i := sort.Search(len(seqs), func(i int) bool {
+		return seqs[i] > seq
+	})
+	if i == 0 {
+		return StateSnapshot{}, false, nil
+	}
+	raw, e := ioutil.ReadFile(db.seqPath(seqs[i-1]))
+	if e != nil {
+		return StateSnapshot{}, false, e
+	}
+	var snap StateSnapshot
+	if e := encoder.DeserializeRaw(raw, &snap); e != nil {
+		return StateSnapshot{}, false, e
+	}
+	return snap, true, nil
+}
+
+// Prune uses the modification time of snapshot files as when they were saved.
+func (db *FileSnapshotDB) Prune(policy SnapshotRetention) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	seqs, e := db.seqs()
+	if e != nil {
+		return 0, e
+	}
+
+	var (
+		now   = time.Now()
+		count int
+	)
+	for i := len(seqs) - 1; i >= 0; i-- {
+		path := db.seqPath(seqs[i])
+		info, e := os.Stat(path)
+		if e != nil {
+			return count, e
+		}
+		if !policy.expired(len(seqs)-1-i, info.ModTime(), now) {
+			continue
+		}
+		if e := os.Remove(path); e != nil {
+			return count, e
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (db *FileSnapshotDB) seqPath(seq uint64) string {
+	return filepath.Join(db.dir, fmt.Sprintf("%020d%s", seq, SnapshotExt))
+}
+
+// seqs obtains the sequences of the stored snapshots in ascending order.
+func (db *FileSnapshotDB) seqs() ([]uint64, error) {
+	list, e := ioutil.ReadDir(db.dir)
+	if e != nil {
+		return nil, e
+	}
+	var out []uint64
+	for _, info := range list {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, SnapshotExt) {
+			continue
+		}
+		seq, e := strconv.ParseUint(strings.TrimSuffix(name, SnapshotExt), 10, 64)
+		if e != nil {
+			continue
+		}
+		out = append(out, seq)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out, nil
+}
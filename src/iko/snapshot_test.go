@@ -0,0 +1,114 @@
+package iko
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func runSnapshotDBTest(t *testing.T, snapshotDB SnapshotDB) {
+	t.Run("Nearest_NoSnapshots", func(t *testing.T) {
+		_, ok, err := snapshotDB.Nearest(10)
+
+		require.Nil(t, err, "Shouldn't have an error")
+		require.False(t, ok, "There are no snapshots yet")
+	})
+
+	snaps := []StateSnapshot{
+		{Seq: 9, Head: TxHash(cipher.SumSHA256([]byte{9})), State: []byte{9}},
+		{Seq: 19, Head: TxHash(cipher.SumSHA256([]byte{19})), State: []byte{1, 9}},
+	}
+	for _, snap := range snaps {
+		require.Nil(t, snapshotDB.Save(snap), "Saving a snapshot should succeed")
+	}
+
+	t.Run("Nearest_BeforeFirst", func(t *testing.T) {
+		_, ok, err := snapshotDB.Nearest(8)
+
+		require.Nil(t, err, "Shouldn't have an error")
+		require.False(t, ok, "There are no snapshots at or before seq 8")
+	})
+
+	t.Run("Nearest_Success", func(t *testing.T) {
+		cases := map[uint64]StateSnapshot{
+			9:   snaps[0],
+			18:  snaps[0],
+			19:  snaps[1],
+			100: snaps[1],
+		}
+		for seq, expected := range cases {
+			snap, ok, err := snapshotDB.Nearest(seq)
+
+			require.Nil(t, err, "Shouldn't have an error")
+			require.True(t, ok, "Should find a snapshot")
+			require.Equal(t, expected, snap, "Should obtain the nearest snapshot")
+		}
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		for _, seq := range []uint64{29, 39} {
+			require.Nil(t, snapshotDB.Save(StateSnapshot{Seq: seq}), "Saving a snapshot should succeed")
+		}
+
+		count, err := snapshotDB.Prune(SnapshotRetention{})
+		require.Nil(t, err, "Shouldn't have an error")
+		require.Equal(t, 0, count, "A zero policy should keep every snapshot")
+
+		count, err = snapshotDB.Prune(SnapshotRetention{KeepLast: 2})
+		require.Nil(t, err, "Shouldn't have an error")
+		require.Equal(t, 2, count, "All but the two latest snapshots should be deleted")
+
+		_, ok, err := snapshotDB.Nearest(28)
+		require.Nil(t, err, "Shouldn't have an error")
+		require.False(t, ok, "Earlier snapshots should be deleted")
+
+		snap, ok, err := snapshotDB.Nearest(30)
+		require.Nil(t, err, "Shouldn't have an error")
+		require.True(t, ok, "Latest snapshots should be kept")
+		require.Equal(t, uint64(29), snap.Seq, "Latest snapshots should be kept")
+
+		count, err = snapshotDB.Prune(SnapshotRetention{MaxAge: time.Nanosecond})
+		require.Nil(t, err, "Shouldn't have an error")
+		require.Equal(t, 1, count, "Old snapshots should be deleted")
+
+		snap, ok, err = snapshotDB.Nearest(100)
+		require.Nil(t, err, "Shouldn't have an error")
+		require.True(t, ok, "The latest snapshot should never be deleted")
+		require.Equal(t, uint64(39), snap.Seq, "The latest snapshot should never be deleted")
+	})
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	var (
+		now   = time.Now()
+		fresh = now.Add(-time.Hour)
+		stale = now.Add(-48 * time.Hour)
+	)
+
+	policy := SnapshotRetention{KeepLast: 3, MaxAge: 24 * time.Hour}
+	require.False(t, policy.expired(0, stale, now), "The latest snapshot should never expire")
+	require.False(t, policy.expired(2, fresh, now), "Snapshots within both limits should be kept")
+	require.True(t, policy.expired(3, fresh, now), "Snapshots beyond 'KeepLast' should expire")
+	require.True(t, policy.expired(1, stale, now), "Snapshots older than 'MaxAge' should expire")
+
+	require.True(t, SnapshotRetention{}.IsZero(), "A zero policy should keep all")
+	require.False(t, SnapshotRetention{}.expired(10, stale, now), "A zero policy should keep all")
+}
+
+func TestSnapshotDB_MemorySnapshotDB(t *testing.T) {
+	runSnapshotDBTest(t, NewMemorySnapshotDB())
+}
+
+func TestSnapshotDB_FileSnapshotDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kittycash_test")
+	require.Nil(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	snapshotDB, err := NewFileSnapshotDB(dir)
+	require.Nil(t, err, "We should be able to create a FileSnapshotDB")
+
+	runSnapshotDBTest(t, snapshotDB)
+}
@@ -1,9 +1,14 @@
 package iko
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"io"
+	"sort"
 	"sync"
+	"time"
 )
 
 // StateDB records the state of the blockchain.
@@ -13,6 +18,7 @@ type StateDB interface {
 	// This consists of:
 	//		- The address that the kitty resides under.
 	//		- Transactions associated with the kitty.
+	//		- The seq, hash and timestamp of the last transfer (or creation) of the kitty.
 	// It should return false if kitty of specified ID does not exist.
 	GetKittyState(kittyID KittyID) (*KittyState, bool)
 
@@ -23,29 +29,135 @@ type StateDB interface {
 	// The array of kitty IDs should be in ascending sequential order, from smallest index to highest.
 	GetAddressState(address cipher.Address) *AddressState
 
+	// GetNonce obtains the number of transactions sent by an address.
+	// The next transaction sent by the address needs a nonce of one higher.
+	// A kitty moved as part of the same transaction as the previously
+	// applied move from the same address does not count as another
+	// transaction.
+	GetNonce(address cipher.Address) uint64
+
+	// GetKittiesOfAddress obtains a paginated portion of the kitties owned by an address.
+	// Kitty IDs are in ascending sequential order, and pages start from 0.
+	// It will return an error if the pageSize is zero.
+	// A page beyond the last page returns an empty result.
+	GetKittiesOfAddress(address cipher.Address, page, pageSize uint64) (KittyIDs, error)
+
+	// CountOfAddress obtains the number of kitties owned by an address.
+	// It should return 0 if the address does not exist in state.
+	CountOfAddress(address cipher.Address) uint64
+
+	// Addresses obtains a paginated portion of every address that has ever
+	// appeared in a transaction. Addresses are sorted by their raw bytes, so
+	// pages are stable as long as no new addresses are recorded.
+	// It will return an error if the pageSize is zero.
+	// A page beyond the last page returns an empty result.
+	Addresses(page, pageSize uint64) ([]cipher.Address, error)
+
+	// CountOfAddresses obtains the number of addresses that have ever
+	// appeared in a transaction.
+	CountOfAddresses() uint64
+
 	// AddKitty adds a kitty to the state under the specified address.
 	// This should fail if:
 	// 		- kitty of specified ID already exists in state.
-	AddKitty(tx TxHash, kittyID KittyID, address cipher.Address) error
+	AddKitty(tx TxRef, kittyID KittyID, address cipher.Address) error
 
 	// MoveKitty moves a kitty from one address to another.
 	// This should fail if:
 	//		- kitty of specified ID already belongs to the address ('from' and 'to' addresses are the same).
 	//		- kitty of specified ID does not exist.
 	//		- kitty of specified ID does not originally belong to the 'from' address.
-	MoveKitty(tx TxHash, kittyID KittyID, from, to cipher.Address) error
+	MoveKitty(tx TxRef, kittyID KittyID, from, to cipher.Address) error
+
+	// Apply applies the changes of ownership in order, as a single atomic
+	// operation. Changes with an empty 'From' address add the kitty, and are
+	// otherwise moves of the kitty. The conditions for failure are the same
+	// as those of 'AddKitty' and 'MoveKitty', taking the earlier changes of
+	// the batch into account. If any of the changes fail, none are applied.
+	// Moves to 'BurnAddress' burn the kitty: it is kept with its history,
+	// but is not in any address state and can not be moved again.
+	// Changes that add a kitty with parents breed the kitty; the parents need
+	// to exist (and not be burned), and the kitty is added to their children.
+	// The fee of a change is added to the fees paid by 'From', and to the fees
+	// received by 'FeeTo'. Changes that add a kitty can not pay a fee.
+	Apply(changes []OwnershipChange) error
+
+	// ReserveKitty marks a kitty that is not yet minted as reserved, so that it
+	// is no longer listed as unclaimed. The reservation is removed once the
+	// kitty is added. Reservations are not recorded in the chain, and hence
+	// are not part of snapshots.
+	// This should fail if:
+	//		- kitty of specified ID already exists in state.
+	//		- kitty of specified ID is already reserved.
+	ReserveKitty(kittyID KittyID) error
+
+	// UnreserveKitty removes the reservation of a kitty.
+	// This should fail if:
+	//		- kitty of specified ID is not reserved.
+	UnreserveKitty(kittyID KittyID) error
+
+	// GetKittyStatus obtains whether a kitty is minted, burned, reserved or unclaimed.
+	GetKittyStatus(kittyID KittyID) KittyStatus
+
+	// KittiesOfStatus obtains a paginated portion of the kitties with a status.
+	// Kitty IDs are in ascending sequential order, and pages start from 0.
+	// Unclaimed kitties are those with IDs lower than 'supply' that are neither
+	// created nor reserved ('supply' is ignored for other statuses).
+	// It will return an error if the pageSize is zero or the status is invalid.
+	// A page beyond the last page returns an empty result.
+	KittiesOfStatus(status KittyStatus, supply, page, pageSize uint64) (KittyIDs, error)
+
+	// CountOfStatus obtains the number of kitties with a status.
+	// 'supply' is used as in 'KittiesOfStatus'.
+	CountOfStatus(status KittyStatus, supply uint64) uint64
+
+	// Stats obtains aggregate metrics of the state, with the 'topN' addresses
+	// owning the most kitties (ties are ordered by the raw bytes of the
+	// addresses), and the mint rate measured over the 'window' leading up to
+	// the current time.
+	Stats(topN int, window time.Duration) StateStats
+
+	// Diff obtains the kitties that changed owner between the state at 'fromSeq'
+	// and the state at 'toSeq' (changes by transactions with sequences in the range of
+	// 'fromSeq' (exclusive) to 'toSeq' (inclusive)).
+	// Kitties that returned to their original owner within the range are not included.
+	// The result is in ascending order of kitty ID.
+	// It will return an error if 'fromSeq' is greater than 'toSeq'.
+	Diff(fromSeq, toSeq uint64) ([]KittyDiff, error)
+
+	// Rollback reverts the state to that of right after the transaction at
+	// the specified sequence is applied, discarding the changes by every
+	// transaction at a higher sequence.
+	// Rolling back to a sequence at or beyond the latest change does nothing.
+	Rollback(seq uint64) error
+
+	// Export writes the complete kitty to owner table to 'w' in the
+	// specified format, in ascending order of kitty ID.
+	Export(w io.Writer, format ExportFormat) error
+
+	// Snapshot obtains a serialized copy of the full state.
+	Snapshot() ([]byte, error)
+
+	// LoadSnapshot replaces the full state with that of a serialized copy
+	// obtained from 'Snapshot'.
+	LoadSnapshot(raw []byte) error
 }
 
 type MemoryState struct {
 	sync.Mutex
 	kitties   map[KittyID]*KittyState
 	addresses map[cipher.Address]*AddressState
+	addrIndex []cipher.Address // sorted with 'addressLess'
+	reserved  map[KittyID]struct{}
+	claimed   KittyIDs          // sorted IDs of created or reserved kitties
+	changes   []OwnershipChange // in ascending order of seq
 }
 
 func NewMemoryState() *MemoryState {
 	return &MemoryState{
 		kitties:   make(map[KittyID]*KittyState),
 		addresses: make(map[cipher.Address]*AddressState),
+		reserved:  make(map[KittyID]struct{}),
 	}
 }
 
@@ -57,7 +169,7 @@ func (s *MemoryState) GetKittyState(kittyID KittyID) (*KittyState, bool) {
 	return kState, ok
 }
 
-func (s MemoryState) GetAddressState(address cipher.Address) *AddressState {
+func (s *MemoryState) GetAddressState(address cipher.Address) *AddressState {
 	s.Lock()
 	defer s.Unlock()
 
@@ -68,58 +180,166 @@ func (s MemoryState) GetAddressState(address cipher.Address) *AddressState {
 	return aState
 }
 
-func (s *MemoryState) AddKitty(tx TxHash, kittyID KittyID, address cipher.Address) error {
+func (s *MemoryState) GetNonce(address cipher.Address) uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	if aState, ok := s.addresses[address]; ok {
+		return aState.Nonce
+	}
+	return 0
+}
+
+func (s *MemoryState) GetKittiesOfAddress(address cipher.Address, page, pageSize uint64) (KittyIDs, error) {
+	if pageSize == 0 {
+		return nil, fmt.Errorf("invalid pageSize: %d", pageSize)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
+	aState, ok := s.addresses[address]
+	if !ok {
+		return KittyIDs{}, nil
+	}
+
+	count := uint64(len(aState.Kitties))
+	if page >= totalPageCount(count, pageSize) {
+		return KittyIDs{}, nil
+	}
+
+	start := page * pageSize
+	end := start + pageSize
+	if end > count {
+		end = count
+	}
+
+	out := make(KittyIDs, end-start)
+	copy(out, aState.Kitties[start:end])
+	return out, nil
+}
+
+func (s *MemoryState) CountOfAddress(address cipher.Address) uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	aState, ok := s.addresses[address]
+	if !ok {
+		return 0
+	}
+	return uint64(len(aState.Kitties))
+}
+
+func (s *MemoryState) Addresses(page, pageSize uint64) ([]cipher.Address, error) {
+	if pageSize == 0 {
+		return nil, fmt.Errorf("invalid pageSize: %d", pageSize)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	count := uint64(len(s.addrIndex))
+	if page >= totalPageCount(count, pageSize) {
+		return []cipher.Address{}, nil
+	}
+
+	start := page * pageSize
+	end := start + pageSize
+	if end > count {
+		end = count
+	}
+
+	out := make([]cipher.Address, end-start)
+	copy(out, s.addrIndex[start:end])
+	return out, nil
+}
+
+func (s *MemoryState) CountOfAddresses() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return uint64(len(s.addrIndex))
+}
+
+func (s *MemoryState) AddKitty(tx TxRef, kittyID KittyID, address cipher.Address) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.addKitty(tx, kittyID, address)
+}
+
+func (s *MemoryState) addKitty(tx TxRef, kittyID KittyID, address cipher.Address) error {
 	if _, ok := s.kitties[kittyID]; ok {
 		return fmt.Errorf("kitty of id '%d' already exists",
 			kittyID)
 	}
 
+	delete(s.reserved, kittyID)
+	s.claimKitty(kittyID)
+
 	if kState, ok := s.kitties[kittyID]; !ok {
 		s.kitties[kittyID] = &KittyState{
 			Address:      address,
-			Transactions: TxHashes{tx},
+			Transactions: TxHashes{tx.Hash},
+			LastTx:       tx,
 		}
 	} else {
 		kState.Address = address
-		kState.Transactions = append(kState.Transactions, tx)
+		kState.Transactions = append(kState.Transactions, tx.Hash)
+		kState.LastTx = tx
 	}
 
 	if aState, ok := s.addresses[address]; !ok {
 		s.addresses[address] = &AddressState{
 			Kitties:      KittyIDs{kittyID},
-			Transactions: TxHashes{tx},
+			Transactions: TxHashes{tx.Hash},
 		}
+		s.indexAddress(address)
 	} else {
 		aState.Kitties.Add(kittyID)
-		aState.Transactions = append(aState.Transactions, tx)
+		aState.Transactions = append(aState.Transactions, tx.Hash)
 	}
 
+	s.changes = append(s.changes, OwnershipChange{
+		Tx:      tx,
+		KittyID: kittyID,
+		To:      address,
+	})
 	return nil
 }
 
-func (s *MemoryState) MoveKitty(tx TxHash, kittyID KittyID, from, to cipher.Address) error {
+func (s *MemoryState) MoveKitty(tx TxRef, kittyID KittyID, from, to cipher.Address) error {
 	s.Lock()
 	defer s.Unlock()
 
+	return s.moveKitty(tx, kittyID, from, to)
+}
+
+func (s *MemoryState) moveKitty(tx TxRef, kittyID KittyID, from, to cipher.Address) error {
 	if from == to {
 		return fmt.Errorf("kitty of id '%d' already belongs to address '%s'",
 			kittyID, from)
 
 	} else if kState, ok := s.kitties[kittyID]; !ok {
-		return fmt.Errorf("kitty of id '%d' does not exist",
+		return kindErrorf(ErrKittyUnknown, "kitty of id '%d' does not exist",
 			kittyID)
 
 	} else if kState.Address != from {
-		return fmt.Errorf("kitty of id '%d' does not belong to address '%s'",
+		return kindErrorf(ErrNotOwner, "kitty of id '%d' does not belong to address '%s'",
 			kittyID, from)
 	}
 
 	kState := s.kitties[kittyID]
 	kState.Address = to
-	kState.Transactions = append(kState.Transactions, tx)
+	kState.Transactions = append(kState.Transactions, tx.Hash)
+	kState.LastTx = tx
+
+	c := OwnershipChange{
+		Tx:      tx,
+		KittyID: kittyID,
+		From:    from,
+		To:      to,
+	}
 
 	if fromState, ok := s.addresses[from]; !ok {
 		panic(fmt.Errorf(
@@ -127,17 +347,576 @@ func (s *MemoryState) MoveKitty(tx TxHash, kittyID KittyID, from, to cipher.Addr
 			from.String()))
 	} else {
 		fromState.Kitties.Remove(kittyID)
-		fromState.Transactions = append(fromState.Transactions, tx)
+		fromState.Transactions = append(fromState.Transactions, tx.Hash)
+		if isNewSend(c, s.changes) {
+			fromState.Nonce++
+		}
 	}
 
-	if toState, ok := s.addresses[to]; !ok {
+	if to == BurnAddress {
+		// Burned kitties are not in any address.
+	} else if toState, ok := s.addresses[to]; !ok {
 		s.addresses[to] = &AddressState{
 			Kitties:      KittyIDs{kittyID},
-			Transactions: TxHashes{tx},
+			Transactions: TxHashes{tx.Hash},
 		}
+		s.indexAddress(to)
 	} else {
 		toState.Kitties.Add(kittyID)
-		toState.Transactions = append(toState.Transactions, tx)
+		toState.Transactions = append(toState.Transactions, tx.Hash)
+	}
+
+	s.changes = append(s.changes, c)
+	return nil
+}
+
+// isNewSend determines whether a change is the first move of a transaction
+// from its sender, given the changes applied before it.
+func isNewSend(c OwnershipChange, earlier []OwnershipChange) bool {
+	if c.IsCreation() {
+		return false
+	}
+	if len(earlier) == 0 {
+		return true
+	}
+	last := earlier[len(earlier)-1]
+	return last.Tx.Hash != c.Tx.Hash || last.From != c.From
+}
+
+func (s *MemoryState) Apply(changes []OwnershipChange) error {
+	s.Lock()
+	defer s.Unlock()
+
+	// Check every change against the state as it would be after the
+	// earlier changes in the batch, before modifying anything.
+	owners := make(map[KittyID]cipher.Address, len(changes))
+	for _, c := range changes {
+		owner, ok := owners[c.KittyID]
+		if !ok {
+			if kState, exists := s.kitties[c.KittyID]; exists {
+				owner, ok = kState.Address, true
+			}
+		}
+		if c.IsCreation() {
+			if ok {
+				return fmt.Errorf("kitty of id '%d' already exists",
+					c.KittyID)
+			}
+			if c.Fee != 0 {
+				return fmt.Errorf("creation of kitty of id '%d' can not pay a fee",
+					c.KittyID)
+			}
+			for _, parent := range c.Parents {
+				parentOwner, exists := owners[parent]
+				if !exists {
+					if kState, ok := s.kitties[parent]; ok {
+						parentOwner, exists = kState.Address, true
+					}
+				}
+				if !exists {
+					return kindErrorf(ErrKittyUnknown, "parent kitty of id '%d' does not exist",
+						parent)
+				}
+				if parentOwner == BurnAddress {
+					return fmt.Errorf("parent kitty of id '%d' is burned",
+						parent)
+				}
+			}
+		} else if c.From == c.To {
+			return fmt.Errorf("kitty of id '%d' already belongs to address '%s'",
+				c.KittyID, c.From)
+		} else if ok && owner == BurnAddress {
+			return fmt.Errorf("kitty of id '%d' is burned",
+				c.KittyID)
+		} else if !ok {
+			return kindErrorf(ErrKittyUnknown, "kitty of id '%d' does not exist",
+				c.KittyID)
+		} else if owner != c.From {
+			return kindErrorf(ErrNotOwner, "kitty of id '%d' does not belong to address '%s'",
+				c.KittyID, c.From)
+		}
+		owners[c.KittyID] = c.To
+	}
+
+	for _, c := range changes {
+		var e error
+		if c.IsCreation() {
+			e = s.addKitty(c.Tx, c.KittyID, c.To)
+		} else {
+			e = s.moveKitty(c.Tx, c.KittyID, c.From, c.To)
+		}
+		if e != nil {
+			panic(fmt.Errorf("checked change of kitty of id '%d' failed: %v",
+				c.KittyID, e))
+		}
+		if c.Fee != 0 {
+			s.payFee(c)
+		}
+		if len(c.Parents) != 0 {
+			s.breed(c)
+		}
+	}
+	return nil
+}
+
+// breed records the lineage of a bred kitty, right after it is added.
+func (s *MemoryState) breed(c OwnershipChange) {
+	s.changes[len(s.changes)-1].Parents = c.Parents
+
+	s.kitties[c.KittyID].Parents = append(KittyIDs{}, c.Parents...)
+	for _, parent := range c.Parents {
+		s.kitties[parent].Children.Add(c.KittyID)
+	}
+}
+
+// payFee accounts the fee of a change, right after the change is applied.
+// The fee is recorded with the applied change, so that it is undone with it.
+func (s *MemoryState) payFee(c OwnershipChange) {
+	last := &s.changes[len(s.changes)-1]
+	last.Fee, last.FeeTo = c.Fee, c.FeeTo
+
+	s.addresses[c.From].FeesPaid += c.Fee
+	toState, ok := s.addresses[c.FeeTo]
+	if !ok {
+		toState = NewAddressState()
+		s.addresses[c.FeeTo] = toState
+		s.indexAddress(c.FeeTo)
+	}
+	toState.FeesReceived += c.Fee
+}
+
+func (s *MemoryState) ReserveKitty(kittyID KittyID) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.kitties[kittyID]; ok {
+		return fmt.Errorf("kitty of id '%d' already exists",
+			kittyID)
+	}
+	if _, ok := s.reserved[kittyID]; ok {
+		return fmt.Errorf("kitty of id '%d' is already reserved",
+			kittyID)
+	}
+	s.reserved[kittyID] = struct{}{}
+	s.claimKitty(kittyID)
+	return nil
+}
+
+func (s *MemoryState) UnreserveKitty(kittyID KittyID) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.reserved[kittyID]; !ok {
+		return fmt.Errorf("kitty of id '%d' is not reserved",
+			kittyID)
+	}
+	delete(s.reserved, kittyID)
+	s.unclaimKitty(kittyID)
+	return nil
+}
+
+func (s *MemoryState) GetKittyStatus(kittyID KittyID) KittyStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.kittyStatus(kittyID)
+}
+
+func (s *MemoryState) kittyStatus(kittyID KittyID) KittyStatus {
+	if kState, ok := s.kitties[kittyID]; ok {
+		if kState.Address == BurnAddress {
+			return KittyBurned
+		}
+		return KittyMinted
+	}
+	if _, ok := s.reserved[kittyID]; ok {
+		return KittyReserved
+	}
+	return KittyUnclaimed
+}
+
+func (s *MemoryState) KittiesOfStatus(status KittyStatus, supply, page, pageSize uint64) (KittyIDs, error) {
+	if pageSize == 0 {
+		return nil, fmt.Errorf("invalid pageSize: %d", pageSize)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	var ids KittyIDs
+	switch status {
+	case KittyMinted, KittyBurned:
+		ids = make(KittyIDs, 0, len(s.kitties))
+		for kittyID := range s.kitties {
+			if s.kittyStatus(kittyID) == status {
+				ids = append(ids, kittyID)
+			}
+		}
+		ids.Sort()
+
+	case KittyReserved:
+		ids = make(KittyIDs, 0, len(s.reserved))
+		for kittyID := range s.reserved {
+			ids = append(ids, kittyID)
+		}
+		ids.Sort()
+
+	case KittyUnclaimed:
+		if page >= totalPageCount(s.countUnclaimed(supply), pageSize) {
+			return KittyIDs{}, nil
+		}
+		return s.unclaimedKitties(supply, page*pageSize, pageSize), nil
+
+	default:
+		return nil, fmt.Errorf("invalid kitty status '%s'", status)
+	}
+
+	count := uint64(len(ids))
+	if page >= totalPageCount(count, pageSize) {
+		return KittyIDs{}, nil
+	}
+
+	start := page * pageSize
+	end := start + pageSize
+	if end > count {
+		end = count
+	}
+	return ids[start:end], nil
+}
+
+func (s *MemoryState) CountOfStatus(status KittyStatus, supply uint64) uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	switch status {
+	case KittyMinted, KittyBurned:
+		var count uint64
+		for kittyID := range s.kitties {
+			if s.kittyStatus(kittyID) == status {
+				count++
+			}
+		}
+		return count
+
+	case KittyReserved:
+		return uint64(len(s.reserved))
+
+	case KittyUnclaimed:
+		return s.countUnclaimed(supply)
+
+	default:
+		return 0
+	}
+}
+
+func (s *MemoryState) Stats(topN int, window time.Duration) StateStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := StateStats{
+		TotalKitties: uint64(len(s.kitties)),
+	}
+
+	holders := make([]HolderCount, 0, len(s.addrIndex))
+	for _, address := range s.addrIndex {
+		if count := uint64(len(s.addresses[address].Kitties)); count > 0 {
+			holders = append(holders, HolderCount{Address: address, Count: count})
+		}
+	}
+	stats.OwnerCount = uint64(len(holders))
+	sort.SliceStable(holders, func(i, j int) bool {
+		return holders[i].Count > holders[j].Count
+	})
+	if topN < 0 {
+		topN = 0
+	}
+	if topN < len(holders) {
+		holders = holders[:topN]
+	}
+	stats.TopHolders = holders
+
+	since := time.Now().Add(-window).UnixNano()
+	for i := len(s.changes) - 1; i >= 0 && s.changes[i].Tx.TS >= since; i-- {
+		if s.changes[i].IsCreation() {
+			stats.RecentMints++
+		}
+	}
+	stats.MintRate = mintRate(stats.RecentMints, window)
+	return stats
+}
+
+// indexAddress inserts a newly seen address into the sorted address index.
+func (s *MemoryState) indexAddress(address cipher.Address) {
+	i := sort.Search(len(s.addrIndex), func(i int) bool {
+		return !addressLess(s.addrIndex[i], address)
+	})
+	s.addrIndex = append(s.addrIndex, cipher.Address{})
+	copy(s.addrIndex[i+1:], s.addrIndex[i:])
+	s.addrIndex[i] = address
+}
+
+// claimKitty adds a created or reserved kitty to the sorted claimed index.
+func (s *MemoryState) claimKitty(kittyID KittyID) {
+	i := sort.Search(len(s.claimed), func(i int) bool {
+		return s.claimed[i] >= kittyID
+	})
+	if i < len(s.claimed) && s.claimed[i] == kittyID {
+		return
+	}
+	s.claimed = append(s.claimed, 0)
+	copy(s.claimed[i+1:], s.claimed[i:])
+	s.claimed[i] = kittyID
+}
+
+// unclaimKitty removes a kitty from the sorted claimed index.
+func (s *MemoryState) unclaimKitty(kittyID KittyID) {
+	i := sort.Search(len(s.claimed), func(i int) bool {
+		return s.claimed[i] >= kittyID
+	})
+	if i < len(s.claimed) && s.claimed[i] == kittyID {
+		s.claimed = append(s.claimed[:i], s.claimed[i+1:]...)
+	}
+}
+
+// countUnclaimed obtains the number of unclaimed kitties with IDs lower than
+// the supply.
+func (s *MemoryState) countUnclaimed(supply uint64) uint64 {
+	n := sort.Search(len(s.claimed), func(i int) bool {
+		return uint64(s.claimed[i]) >= supply
+	})
+	return supply - uint64(n)
+}
+
+// unclaimedKitties obtains up to 'n' unclaimed kitties with IDs lower than the
+// supply, after the first 'skip' unclaimed kitties. As 'claimed[i]-i'
+// unclaimed kitties precede 'claimed[i]', the first kitty of the page is
+// found with a binary search in the claimed index, rather than by walking
+// the supply.
+func (s *MemoryState) unclaimedKitties(supply, skip, n uint64) KittyIDs {
+	j := sort.Search(len(s.claimed), func(i int) bool {
+		return uint64(s.claimed[i])-uint64(i) > skip
+	})
+	out := KittyIDs{}
+	for id := skip + uint64(j); id < supply && uint64(len(out)) < n; id++ {
+		if j < len(s.claimed) && uint64(s.claimed[j]) == id {
+			j++
+			continue
+		}
+		out = append(out, KittyID(id))
+	}
+	return out
+}
+
+// unindexAddress removes an address from state and the sorted address index.
+func (s *MemoryState) unindexAddress(address cipher.Address) {
+	delete(s.addresses, address)
+	i := sort.Search(len(s.addrIndex), func(i int) bool {
+		return !addressLess(s.addrIndex[i], address)
+	})
+	if i < len(s.addrIndex) && s.addrIndex[i] == address {
+		s.addrIndex = append(s.addrIndex[:i], s.addrIndex[i+1:]...)
+	}
+}
+
+// addressLess orders addresses by their raw bytes.
+func addressLess(a, b cipher.Address) bool {
+	if c := bytes.Compare(a.Key[:], b.Key[:]); c != 0 {
+		return c < 0
+	}
+	return a.Version < b.Version
+}
+
+func (s *MemoryState) Diff(fromSeq, toSeq uint64) ([]KittyDiff, error) {
+	if fromSeq > toSeq {
+		return nil, fmt.Errorf("invalid sequence range: %d > %d", fromSeq, toSeq)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	var (
+		diffs = make(map[KittyID]*KittyDiff)
+		i     = sort.Search(len(s.changes), func(i int) bool {
+			return s.changes[i].Tx.Seq > fromSeq
+		})
+	)
+	for ; i < len(s.changes) && s.changes[i].Tx.Seq <= toSeq; i++ {
+		c := s.changes[i]
+		if diff, ok := diffs[c.KittyID]; ok {
+			diff.To = c.To
+		} else {
+			diffs[c.KittyID] = &KittyDiff{
+				KittyID: c.KittyID,
+				From:    c.From,
+				To:      c.To,
+			}
+		}
+	}
+
+	out := make([]KittyDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		if diff.From != diff.To {
+			out = append(out, *diff)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].KittyID < out[j].KittyID
+	})
+	return out, nil
+}
+
+func (s *MemoryState) Rollback(seq uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	i := sort.Search(len(s.changes), func(i int) bool {
+		return s.changes[i].Tx.Seq > seq
+	})
+	for j := len(s.changes) - 1; j >= i; j-- {
+		s.undo(s.changes[j], s.changes[:j])
+	}
+	s.changes = s.changes[:i]
+	return nil
+}
+
+// undo reverse-applies a change, where 'earlier' are the changes before it.
+// As changes are undone from the latest, the change's tx hash is always the
+// last of the associated transaction lists.
+func (s *MemoryState) undo(c OwnershipChange, earlier []OwnershipChange) {
+	if c.Fee != 0 {
+		s.addresses[c.From].FeesPaid -= c.Fee
+		feeState := s.addresses[c.FeeTo]
+		feeState.FeesReceived -= c.Fee
+		if feeState.isEmpty() {
+			s.unindexAddress(c.FeeTo)
+		}
+	}
+
+	kState := s.kitties[c.KittyID]
+	if c.IsCreation() {
+		for _, parent := range c.Parents {
+			s.kitties[parent].Children.Remove(c.KittyID)
+		}
+		delete(s.kitties, c.KittyID)
+		s.unclaimKitty(c.KittyID)
+	} else {
+		kState.Address = c.From
+		kState.Transactions = kState.Transactions[:len(kState.Transactions)-1]
+		for i := len(earlier) - 1; i >= 0; i-- {
+			if earlier[i].KittyID == c.KittyID {
+				kState.LastTx = earlier[i].Tx
+				break
+			}
+		}
+		fromState := s.addresses[c.From]
+		fromState.Kitties.Add(c.KittyID)
+		fromState.Transactions = fromState.Transactions[:len(fromState.Transactions)-1]
+		if isNewSend(c, earlier) {
+			fromState.Nonce--
+		}
+	}
+
+	if c.To == BurnAddress {
+		return
+	}
+	toState := s.addresses[c.To]
+	toState.Kitties.Remove(c.KittyID)
+	toState.Transactions = toState.Transactions[:len(toState.Transactions)-1]
+	if toState.isEmpty() {
+		s.unindexAddress(c.To)
+	}
+}
+
+func (s *MemoryState) Export(w io.Writer, format ExportFormat) error {
+	s.Lock()
+	ids := make(KittyIDs, 0, len(s.kitties))
+	for kittyID := range s.kitties {
+		ids = append(ids, kittyID)
+	}
+	ids.Sort()
+	entries := make([]ExportEntry, len(ids))
+	for i, kittyID := range ids {
+		entries[i] = NewExportEntry(kittyID, s.kitties[kittyID])
+	}
+	s.Unlock()
+
+	return WriteExport(w, format, entries)
+}
+
+type kittyStateEntry struct {
+	KittyID KittyID
+	State   KittyState
+}
+
+type addressStateEntry struct {
+	Address cipher.Address
+	State   AddressState
+}
+
+// memoryStateFile is the serialized representation of 'MemoryState'.
+// Entries are sorted so that snapshots of the same state are identical.
+type memoryStateFile struct {
+	Kitties   []kittyStateEntry
+	Addresses []addressStateEntry
+	Changes   []OwnershipChange
+}
+
+func (s *MemoryState) Snapshot() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	f := memoryStateFile{
+		Kitties:   make([]kittyStateEntry, 0, len(s.kitties)),
+		Addresses: make([]addressStateEntry, 0, len(s.addresses)),
+		Changes:   s.changes,
+	}
+	for kittyID, kState := range s.kitties {
+		f.Kitties = append(f.Kitties, kittyStateEntry{kittyID, *kState})
+	}
+	for address, aState := range s.addresses {
+		f.Addresses = append(f.Addresses, addressStateEntry{address, *aState})
+	}
+	sort.Slice(f.Kitties, func(i, j int) bool {
+		return f.Kitties[i].KittyID < f.Kitties[j].KittyID
+	})
+	sort.Slice(f.Addresses, func(i, j int) bool {
+		return f.Addresses[i].Address.String() < f.Addresses[j].Address.String()
+	})
+	return encoder.Serialize(f), nil
+}
+
+func (s *MemoryState) LoadSnapshot(raw []byte) error {
+	var f memoryStateFile
+	if e := encoder.DeserializeRaw(raw, &f); e != nil {
+		return e
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.kitties = make(map[KittyID]*KittyState, len(f.Kitties))
+	for i := range f.Kitties {
+		s.kitties[f.Kitties[i].KittyID] = &f.Kitties[i].State
+	}
+	s.addresses = make(map[cipher.Address]*AddressState, len(f.Addresses))
+	s.addrIndex = make([]cipher.Address, len(f.Addresses))
+	for i := range f.Addresses {
+		s.addresses[f.Addresses[i].Address] = &f.Addresses[i].State
+		s.addrIndex[i] = f.Addresses[i].Address
+	}
+	sort.Slice(s.addrIndex, func(i, j int) bool {
+		return addressLess(s.addrIndex[i], s.addrIndex[j])
+	})
+	s.claimed = make(KittyIDs, 0, len(s.kitties)+len(s.reserved))
+	for kittyID := range s.kitties {
+		s.claimed = append(s.claimed, kittyID)
+	}
+	for kittyID := range s.reserved {
+		if _, ok := s.kitties[kittyID]; !ok {
+			s.claimed = append(s.claimed, kittyID)
+		}
 	}
+	s.claimed.Sort()
+	s.changes = f.Changes
 	return nil
 }
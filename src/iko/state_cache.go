@@ -0,0 +1,120 @@
+package iko
+
+import (
+	"container/list"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sync"
+)
+
+// CachedState is a read-through cache for a 'StateDB' implementation.
+// Results of 'GetKittyState' (including those for kitties that do not exist)
+// are cached, so that validating transactions does not hit the underlying
+// StateDB (potentially on disk) for every injection.
+// Methods that are not cached are passed straight to the underlying StateDB.
+type CachedState struct {
+	StateDB
+	mux   sync.Mutex
+	size  int
+	order *list.List // of KittyID, most recently used at front
+	items map[KittyID]*list.Element
+}
+
+type kittyCacheItem struct {
+	kittyID KittyID
+	kState  *KittyState
+	ok      bool
+}
+
+// NewCachedState wraps a StateDB with a cache for the specified number of kitties.
+func NewCachedState(db StateDB, size int) *CachedState {
+	if size < 1 {
+		size = 1
+	}
+	return &CachedState{
+		StateDB: db,
+		size:    size,
+		order:   list.New(),
+		items:   make(map[KittyID]*list.Element, size),
+	}
+}
+
+func (s *CachedState) GetKittyState(kittyID KittyID) (*KittyState, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if el, ok := s.items[kittyID]; ok {
+		s.order.MoveToFront(el)
+		item := el.Value.(*kittyCacheItem)
+		return item.kState, item.ok
+	}
+
+	kState, ok := s.StateDB.GetKittyState(kittyID)
+	s.items[kittyID] = s.order.PushFront(&kittyCacheItem{
+		kittyID: kittyID,
+		kState:  kState,
+		ok:      ok,
+	})
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*kittyCacheItem).kittyID)
+	}
+	return kState, ok
+}
+
+func (s *CachedState) AddKitty(tx TxRef, kittyID KittyID, address cipher.Address) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.evict(kittyID)
+	return s.StateDB.AddKitty(tx, kittyID, address)
+}
+
+func (s *CachedState) MoveKitty(tx TxRef, kittyID KittyID, from, to cipher.Address) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.evict(kittyID)
+	return s.StateDB.MoveKitty(tx, kittyID, from, to)
+}
+
+func (s *CachedState) Apply(changes []OwnershipChange) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, c := range changes {
+		s.evict(c.KittyID)
+		for _, parent := range c.Parents {
+			s.evict(parent) // Children of the parents change.
+		}
+	}
+	return s.StateDB.Apply(changes)
+}
+
+func (s *CachedState) Rollback(seq uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.flush()
+	return s.StateDB.Rollback(seq)
+}
+
+func (s *CachedState) LoadSnapshot(raw []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.flush()
+	return s.StateDB.LoadSnapshot(raw)
+}
+
+func (s *CachedState) evict(kittyID KittyID) {
+	if el, ok := s.items[kittyID]; ok {
+		s.order.Remove(el)
+		delete(s.items, kittyID)
+	}
+}
+
+func (s *CachedState) flush() {
+	s.order.Init()
+	s.items = make(map[KittyID]*list.Element, s.size)
+}
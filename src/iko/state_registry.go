@@ -0,0 +1,72 @@
+package iko
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StateDBConfig is passed to a 'StateDBFactory' when creating a StateDB.
+type StateDBConfig struct {
+	Dir string // Directory for StateDB implementations that persist to disk.
+}
+
+// StateDBFactory creates a StateDB for a registered backend.
+type StateDBFactory func(config *StateDBConfig) (StateDB, error)
+
+var (
+	stateDBMux       sync.RWMutex
+	stateDBFactories = make(map[string]StateDBFactory)
+)
+
+func init() {
+	RegisterStateDB("memory", func(_ *StateDBConfig) (StateDB, error) {
+		return NewMemoryState(), nil
+	})
+}
+
+// RegisterStateDB makes a StateDB backend available under the specified name,
+// so that it can be selected with 'NewStateDB'.
+// It panics if the factory is nil, or a backend with the same name is already
+// registered.
+func RegisterStateDB(name string, factory StateDBFactory) {
+	stateDBMux.Lock()
+	defer stateDBMux.Unlock()
+
+	if factory == nil {
+		panic(fmt.Errorf("nil factory for state backend '%s'", name))
+	}
+	if _, ok := stateDBFactories[name]; ok {
+		panic(fmt.Errorf("state backend '%s' is already registered", name))
+	}
+	stateDBFactories[name] = factory
+}
+
+// NewStateDB creates a StateDB for the backend registered under the specified name.
+func NewStateDB(name string, config *StateDBConfig) (StateDB, error) {
+	stateDBMux.RLock()
+	factory, ok := stateDBFactories[name]
+	stateDBMux.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("state backend '%s' is not registered", name)
+	}
+	if config == nil {
+		config = new(StateDBConfig)
+	}
+	return factory(config)
+}
+
+// StateDBBackends obtains the names of the registered StateDB backends in
+// alphabetical order.
+func StateDBBackends() []string {
+	stateDBMux.RLock()
+	defer stateDBMux.RUnlock()
+
+	out := make([]string, 0, len(stateDBFactories))
+	for name := range stateDBFactories {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,45 @@
+package iko
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStateDBRegistry(t *testing.T) {
+	t.Run("NewStateDB_Memory", func(t *testing.T) {
+		stateDB, err := NewStateDB("memory", nil)
+		require.Nil(t, err, "The memory backend should be registered")
+		require.IsType(t, &MemoryState{}, stateDB, "The memory backend should create a MemoryState")
+	})
+
+	t.Run("NewStateDB_Unregistered", func(t *testing.T) {
+		_, err := NewStateDB("no_such_backend", nil)
+		require.NotNil(t, err, "Unregistered backends should fail")
+	})
+
+	t.Run("RegisterStateDB", func(t *testing.T) {
+		var dir string
+		RegisterStateDB("test_backend", func(config *StateDBConfig) (StateDB, error) {
+			dir = config.Dir
+			return NewCachedState(NewMemoryState(), 10), nil
+		})
+		defer func() {
+			stateDBMux.Lock()
+			delete(stateDBFactories, "test_backend")
+			stateDBMux.Unlock()
+		}()
+
+		require.Contains(t, StateDBBackends(), "test_backend", "Registered backends should be listed")
+
+		stateDB, err := NewStateDB("test_backend", &StateDBConfig{Dir: "state"})
+		require.Nil(t, err, "Registered backends should be created")
+		require.IsType(t, &CachedState{}, stateDB, "The registered factory should be used")
+		require.Equal(t, "state", dir, "The config should be passed to the factory")
+
+		require.Panics(t, func() {
+			RegisterStateDB("test_backend", func(_ *StateDBConfig) (StateDB, error) {
+				return NewMemoryState(), nil
+			})
+		}, "Registering a backend twice should panic")
+	})
+}
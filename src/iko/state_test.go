@@ -1,9 +1,13 @@
 package iko
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
+	"time"
 )
 
 func runStateDBTest(t *testing.T, stateDB StateDB) {
@@ -37,19 +41,23 @@ func runStateDBTest(t *testing.T, stateDB StateDB) {
 		require.Len(t, addressState.Transactions, 0, "Address does not have any transactions yet either")
 	})
 
+	t.Run("CountOfAddress_NoKittiesAvailable", func(t *testing.T) {
+		require.Equal(t, uint64(0), stateDB.CountOfAddress(anAddress), "Address does not have any kitties yet")
+	})
+
 	t.Run("AddKitty_Success", func(t *testing.T) {
 		// let's add some kitties
 		txHash := TxHash(cipher.SumSHA256([]byte{3, 4, 5, 6}))
 		kID := KittyID(3)
 		noSuchKID := KittyID(6)
 
-		err := stateDB.AddKitty(txHash, kID, anAddress)
+		err := stateDB.AddKitty(TxRef{Hash: txHash, Seq: 0}, kID, anAddress)
 
 		require.Nil(t, err, "Adding our first kitty works")
 
 		t.Run("AddKitty_Failure", func(t *testing.T) {
 			// but trying to add that same kitty twice shouldn't work
-			err = stateDB.AddKitty(txHash, kID, anAddress)
+			err = stateDB.AddKitty(TxRef{Hash: txHash, Seq: 0}, kID, anAddress)
 
 			require.NotNil(t, err, "Adding a kitty twice should fail")
 		})
@@ -62,13 +70,14 @@ func runStateDBTest(t *testing.T, stateDB StateDB) {
 
 			require.Equal(t, kittyState.Address, anAddress, "Address matches up")
 			require.Equal(t, kittyState.Transactions, TxHashes{txHash}, "Transaction hashes match up")
+			require.Equal(t, kittyState.LastTx, TxRef{Hash: txHash, Seq: 0}, "Last transaction matches up")
 		})
 
 		t.Run("GetAddressState_Success", func(t *testing.T) {
 			// in preparation, let's add another kitty
 			secondTxHash := TxHash(cipher.SumSHA256([]byte{7, 8, 9, 10}))
 			secondKID := KittyID(2)
-			err := stateDB.AddKitty(secondTxHash, secondKID, anAddress)
+			err := stateDB.AddKitty(TxRef{Hash: secondTxHash, Seq: 1}, secondKID, anAddress)
 
 			require.Nil(t, err, "Adding a second kitty should succeed")
 
@@ -83,6 +92,27 @@ func runStateDBTest(t *testing.T, stateDB StateDB) {
 			require.Contains(t, addressState.Transactions, secondTxHash, "Address should have both transactions")
 		})
 
+		t.Run("GetKittiesOfAddress", func(t *testing.T) {
+			_, err := stateDB.GetKittiesOfAddress(anAddress, 0, 0)
+			require.NotNil(t, err, "A page size of zero should fail")
+
+			kitties, err := stateDB.GetKittiesOfAddress(anAddress, 0, 1)
+			require.Nil(t, err, "Fetching the first page should succeed")
+			require.Equal(t, KittyIDs{KittyID(2)}, kitties, "First page should hold the smallest kitty ID")
+
+			kitties, err = stateDB.GetKittiesOfAddress(anAddress, 1, 1)
+			require.Nil(t, err, "Fetching the second page should succeed")
+			require.Equal(t, KittyIDs{kID}, kitties, "Second page should hold the next kitty ID")
+
+			kitties, err = stateDB.GetKittiesOfAddress(anAddress, 2, 1)
+			require.Nil(t, err, "Fetching beyond the last page should not fail")
+			require.Len(t, kitties, 0, "Pages beyond the last page should be empty")
+
+			kitties, err = stateDB.GetKittiesOfAddress(anAddress, 0, 5)
+			require.Nil(t, err, "Fetching a large page should succeed")
+			require.Equal(t, KittyIDs{KittyID(2), kID}, kitties, "Large page should hold every kitty")
+		})
+
 		// now let's shuffle some kitties around
 		secondTxHash := TxHash(cipher.SumSHA256([]byte{7, 8, 9, 10}))
 		anotherAddress := cipher.AddressFromSecKey(
@@ -99,27 +129,262 @@ func runStateDBTest(t *testing.T, stateDB StateDB) {
 			}))
 
 		t.Run("MoveKitty_AlreadyOwned", func(t *testing.T) {
-			err = stateDB.MoveKitty(secondTxHash, kID, anAddress, anAddress)
+			err = stateDB.MoveKitty(TxRef{Hash: secondTxHash, Seq: 2}, kID, anAddress, anAddress)
 
 			require.NotNil(t, err, "You can't transfer a kitty to yourself")
 		})
 
 		t.Run("MoveKitty_KittyNapping", func(t *testing.T) {
-			err = stateDB.MoveKitty(secondTxHash, kID, anotherAddress, anAddress)
+			err = stateDB.MoveKitty(TxRef{Hash: secondTxHash, Seq: 2}, kID, anotherAddress, anAddress)
 
 			require.NotNil(t, err, "Kidnapping is not allowed")
 		})
 
 		t.Run("MoveKitty_NoSuchKitty", func(t *testing.T) {
-			err = stateDB.MoveKitty(secondTxHash, noSuchKID, anAddress, anotherAddress)
+			err = stateDB.MoveKitty(TxRef{Hash: secondTxHash, Seq: 2}, noSuchKID, anAddress, anotherAddress)
 
 			require.NotNil(t, err, "No such kitty")
 		})
 
 		t.Run("MoveKitty_Success", func(t *testing.T) {
-			err = stateDB.MoveKitty(secondTxHash, kID, anAddress, anotherAddress)
+			err = stateDB.MoveKitty(TxRef{Hash: secondTxHash, Seq: 2}, kID, anAddress, anotherAddress)
 
 			require.Nil(t, err, "Successfully transferred kitty")
+
+			kittyState, _ := stateDB.GetKittyState(kID)
+			require.Equal(t, kittyState.LastTx, TxRef{Hash: secondTxHash, Seq: 2}, "Last transaction should be the transfer")
+			require.Equal(t, uint64(1), stateDB.GetNonce(anAddress), "Sender should have sent one transaction")
+			require.Equal(t, uint64(0), stateDB.GetNonce(anotherAddress), "Recipient should not have sent any transactions")
+		})
+
+		t.Run("CountOfAddress_AfterMove", func(t *testing.T) {
+			require.Equal(t, uint64(1), stateDB.CountOfAddress(anAddress), "Sender should have one kitty left")
+			require.Equal(t, uint64(1), stateDB.CountOfAddress(anotherAddress), "Recipient should own the moved kitty")
+		})
+
+		t.Run("Addresses", func(t *testing.T) {
+			_, err := stateDB.Addresses(0, 0)
+			require.NotNil(t, err, "A page size of zero should fail")
+
+			require.Equal(t, uint64(2), stateDB.CountOfAddresses(), "Both addresses should be recorded")
+
+			addresses, err := stateDB.Addresses(0, 5)
+			require.Nil(t, err, "Fetching the first page should succeed")
+			require.Len(t, addresses, 2, "Both addresses should be on the first page")
+			require.Contains(t, addresses, anAddress, "Sender should be recorded")
+			require.Contains(t, addresses, anotherAddress, "Recipient should be recorded")
+
+			first, err := stateDB.Addresses(0, 1)
+			require.Nil(t, err, "Fetching the first page should succeed")
+			second, err := stateDB.Addresses(1, 1)
+			require.Nil(t, err, "Fetching the second page should succeed")
+			require.Equal(t, addresses, append(first, second...), "Pages should be in a stable order")
+
+			addresses, err = stateDB.Addresses(2, 1)
+			require.Nil(t, err, "Fetching beyond the last page should not fail")
+			require.Len(t, addresses, 0, "Pages beyond the last page should be empty")
+		})
+
+		t.Run("Diff", func(t *testing.T) {
+			_, err := stateDB.Diff(2, 1)
+			require.NotNil(t, err, "An inverted sequence range should fail")
+
+			diffs, err := stateDB.Diff(0, 2)
+			require.Nil(t, err, "Diff should succeed")
+			require.Equal(t, []KittyDiff{
+				{KittyID: KittyID(2), To: anAddress},
+				{KittyID: kID, From: anAddress, To: anotherAddress},
+			}, diffs, "Diff should have the created and moved kitties")
+
+			diffs, err = stateDB.Diff(1, 2)
+			require.Nil(t, err, "Diff should succeed")
+			require.Equal(t, []KittyDiff{
+				{KittyID: kID, From: anAddress, To: anotherAddress},
+			}, diffs, "Diff should only have the moved kitty")
+
+			diffs, err = stateDB.Diff(2, 2)
+			require.Nil(t, err, "Diff should succeed")
+			require.Len(t, diffs, 0, "An empty range has no changes")
+		})
+
+		t.Run("Snapshot_LoadSnapshot", func(t *testing.T) {
+			raw, err := stateDB.Snapshot()
+			require.Nil(t, err, "Taking a snapshot should succeed")
+
+			restored := NewMemoryState()
+			require.Nil(t, restored.LoadSnapshot(raw), "Loading a snapshot should succeed")
+
+			for _, kittyID := range []KittyID{kID, KittyID(2)} {
+				expected, _ := stateDB.GetKittyState(kittyID)
+				kittyState, ok := restored.GetKittyState(kittyID)
+				require.True(t, ok, "Restored state should have the kitty")
+				require.Equal(t, expected, kittyState, "Restored kitty state should match")
+			}
+			for _, address := range []cipher.Address{anAddress, anotherAddress} {
+				require.Equal(t, stateDB.GetAddressState(address), restored.GetAddressState(address),
+					"Restored address state should match")
+			}
+
+			rawAgain, err := restored.Snapshot()
+			require.Nil(t, err, "Taking a snapshot of the restored state should succeed")
+			require.Equal(t, raw, rawAgain, "Snapshots of the same state should be identical")
+		})
+
+		t.Run("Export", func(t *testing.T) {
+			kittyState, _ := stateDB.GetKittyState(kID)
+
+			buf := new(bytes.Buffer)
+			require.Nil(t, stateDB.Export(buf, ExportCSV), "Exporting as CSV should succeed")
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 3, "CSV should have a header and a row per kitty")
+			require.Equal(t, "kitty_id,address,last_tx_hash,last_tx_seq,last_tx_time", lines[0], "CSV header should match")
+			require.True(t, strings.HasPrefix(lines[2], "3,"+anotherAddress.String()+","+kittyState.LastTx.Hash.Hex()),
+				"CSV rows should be in ascending order of kitty ID")
+
+			buf.Reset()
+			require.Nil(t, stateDB.Export(buf, ExportJSON), "Exporting as JSON should succeed")
+			var entries []ExportEntry
+			require.Nil(t, json.Unmarshal(buf.Bytes(), &entries), "JSON export should be valid")
+			require.Equal(t, []ExportEntry{
+				{KittyID: 2, Address: anAddress.String(), LastTxHash: secondTxHash.Hex(), LastTxSeq: 1},
+				NewExportEntry(kID, kittyState),
+			}, entries, "JSON entries should match the state")
+
+			require.NotNil(t, stateDB.Export(buf, ExportFormat("xml")), "Unknown formats should fail")
+		})
+
+		t.Run("Rollback", func(t *testing.T) {
+			require.Nil(t, stateDB.Rollback(1), "Rolling back should succeed")
+
+			kittyState, ok := stateDB.GetKittyState(kID)
+			require.True(t, ok, "Kitty should still exist")
+			require.Equal(t, anAddress, kittyState.Address, "Kitty should be back with the sender")
+			require.Equal(t, TxHashes{txHash}, kittyState.Transactions, "Transfer should be discarded")
+			require.Equal(t, TxRef{Hash: txHash, Seq: 0}, kittyState.LastTx, "Last transaction should be the creation")
+			require.Equal(t, uint64(2), stateDB.CountOfAddress(anAddress), "Sender should own both kitties again")
+			require.Equal(t, uint64(1), stateDB.CountOfAddresses(), "Recipient should no longer be recorded")
+			require.Equal(t, uint64(0), stateDB.GetNonce(anAddress), "Nonce from the discarded transfer should be reverted")
+
+			require.Nil(t, stateDB.Rollback(0), "Rolling back should succeed")
+
+			_, ok = stateDB.GetKittyState(KittyID(2))
+			require.False(t, ok, "Kitty created after seq 0 should no longer exist")
+			require.Equal(t, KittyIDs{kID}, stateDB.GetAddressState(anAddress).Kitties, "Sender should own one kitty")
+		})
+
+		t.Run("KittyStatus", func(t *testing.T) {
+			supply := uint64(6)
+
+			require.NotNil(t, stateDB.ReserveKitty(kID), "Reserving a minted kitty should fail")
+			require.Nil(t, stateDB.ReserveKitty(KittyID(4)), "Reserving an unclaimed kitty should succeed")
+			require.NotNil(t, stateDB.ReserveKitty(KittyID(4)), "Reserving a kitty twice should fail")
+
+			require.Equal(t, KittyMinted, stateDB.GetKittyStatus(kID), "Kitty should be minted")
+			require.Equal(t, KittyReserved, stateDB.GetKittyStatus(KittyID(4)), "Kitty should be reserved")
+			require.Equal(t, KittyUnclaimed, stateDB.GetKittyStatus(KittyID(5)), "Kitty should be unclaimed")
+
+			require.Equal(t, uint64(1), stateDB.CountOfStatus(KittyMinted, supply), "One kitty should be minted")
+			require.Equal(t, uint64(1), stateDB.CountOfStatus(KittyReserved, supply), "One kitty should be reserved")
+			require.Equal(t, uint64(4), stateDB.CountOfStatus(KittyUnclaimed, supply), "The rest should be unclaimed")
+
+			_, err := stateDB.KittiesOfStatus(KittyUnclaimed, supply, 0, 0)
+			require.NotNil(t, err, "A page size of zero should fail")
+			_, err = stateDB.KittiesOfStatus(KittyStatus("lost"), supply, 0, 1)
+			require.NotNil(t, err, "An invalid status should fail")
+
+			kitties, err := stateDB.KittiesOfStatus(KittyUnclaimed, supply, 0, 3)
+			require.Nil(t, err, "Fetching the first page should succeed")
+			require.Equal(t, KittyIDs{0, 1, 2}, kitties, "First page should hold the smallest unclaimed kitties")
+			kitties, err = stateDB.KittiesOfStatus(KittyUnclaimed, supply, 1, 3)
+			require.Nil(t, err, "Fetching the second page should succeed")
+			require.Equal(t, KittyIDs{5}, kitties, "Second page should skip minted and reserved kitties")
+
+			kitties, err = stateDB.KittiesOfStatus(KittyMinted, supply, 0, 3)
+			require.Nil(t, err, "Fetching minted kitties should succeed")
+			require.Equal(t, KittyIDs{kID}, kitties, "Only the remaining kitty should be minted")
+			kitties, err = stateDB.KittiesOfStatus(KittyReserved, supply, 1, 3)
+			require.Nil(t, err, "Fetching beyond the last page should not fail")
+			require.Len(t, kitties, 0, "Pages beyond the last page should be empty")
+
+			require.Nil(t, stateDB.UnreserveKitty(KittyID(4)), "Removing a reservation should succeed")
+			require.NotNil(t, stateDB.UnreserveKitty(KittyID(4)), "Removing a reservation twice should fail")
+
+			require.Nil(t, stateDB.ReserveKitty(KittyID(5)), "Reserving an unclaimed kitty should succeed")
+			require.Nil(t, stateDB.AddKitty(TxRef{Hash: txHash, Seq: 1}, KittyID(5), anAddress), "Adding a reserved kitty should succeed")
+			require.Equal(t, KittyMinted, stateDB.GetKittyStatus(KittyID(5)), "Added kitty should no longer be reserved")
+			require.Equal(t, uint64(0), stateDB.CountOfStatus(KittyReserved, supply), "No kitties should be reserved")
+
+			kitties, err = stateDB.KittiesOfStatus(KittyUnclaimed, supply, 1, 2)
+			require.Nil(t, err, "Fetching a later page should succeed")
+			require.Equal(t, KittyIDs{2, 4}, kitties, "Pages should skip the claimed kitties between them")
+			kitties, err = stateDB.KittiesOfStatus(KittyUnclaimed, supply, 2, 2)
+			require.Nil(t, err, "Fetching beyond the last page should not fail")
+			require.Len(t, kitties, 0, "Pages beyond the last page should be empty")
+		})
+
+		t.Run("UnclaimedPages", func(t *testing.T) {
+			// A large supply is paged without walking it.
+			supply := uint64(1) << 40
+			require.Nil(t, stateDB.ReserveKitty(KittyID(supply-2)), "Reserving an unclaimed kitty should succeed")
+			count := stateDB.CountOfStatus(KittyUnclaimed, supply)
+			require.Equal(t, supply-3, count, "All but the claimed kitties should be unclaimed")
+			var kitties KittyIDs
+			for page := count - 3; page < count+1; page++ {
+				pageKitties, err := stateDB.KittiesOfStatus(KittyUnclaimed, supply, page, 1)
+				require.Nil(t, err, "Fetching the last pages should succeed")
+				kitties = append(kitties, pageKitties...)
+			}
+			require.Equal(t, KittyIDs{KittyID(supply - 4), KittyID(supply - 3), KittyID(supply - 1)}, kitties,
+				"The last pages should skip the reserved kitty")
+			require.Nil(t, stateDB.UnreserveKitty(KittyID(supply-2)), "Removing a reservation should succeed")
+			require.Equal(t, supply-2, stateDB.CountOfStatus(KittyUnclaimed, supply), "Removed reservations should be unclaimed")
+		})
+
+		t.Run("Apply", func(t *testing.T) {
+			tx := TxRef{Hash: secondTxHash, Seq: 2}
+
+			err := stateDB.Apply([]OwnershipChange{
+				{Tx: tx, KittyID: KittyID(7), To: anotherAddress},
+				{Tx: tx, KittyID: kID, From: anAddress, To: anotherAddress},
+				{Tx: tx, KittyID: KittyID(7), From: anAddress, To: anotherAddress},
+			})
+			require.NotNil(t, err, "A batch with an invalid change should fail")
+
+			_, ok := stateDB.GetKittyState(KittyID(7))
+			require.False(t, ok, "No changes in a failed batch should be applied")
+			kittyState, _ := stateDB.GetKittyState(kID)
+			require.Equal(t, anAddress, kittyState.Address, "No changes in a failed batch should be applied")
+
+			err = stateDB.Apply([]OwnershipChange{
+				{Tx: tx, KittyID: KittyID(7), To: anotherAddress},
+				{Tx: tx, KittyID: KittyID(7), From: anotherAddress, To: anAddress},
+				{Tx: tx, KittyID: kID, From: anAddress, To: anotherAddress},
+			})
+			require.Nil(t, err, "A valid batch should succeed")
+
+			kittyState, ok = stateDB.GetKittyState(KittyID(7))
+			require.True(t, ok, "Created kitty should exist")
+			require.Equal(t, anAddress, kittyState.Address, "Later changes should build on earlier changes")
+			kittyState, _ = stateDB.GetKittyState(kID)
+			require.Equal(t, anotherAddress, kittyState.Address, "Every change in the batch should be applied")
+			require.Equal(t, uint64(1), stateDB.GetNonce(anAddress), "Each sender in the batch should send one transaction")
+			require.Equal(t, uint64(1), stateDB.GetNonce(anotherAddress), "Each sender in the batch should send one transaction")
+		})
+
+		t.Run("Stats", func(t *testing.T) {
+			stats := stateDB.Stats(1, time.Hour)
+			require.Equal(t, uint64(3), stats.TotalKitties, "Three kitties should be minted")
+			require.Equal(t, uint64(2), stats.OwnerCount, "Both addresses should own kitties")
+			require.Equal(t, []HolderCount{{Address: anAddress, Count: 2}}, stats.TopHolders,
+				"The address owning the most kitties should be the top holder")
+			require.Equal(t, uint64(0), stats.RecentMints, "No kitties should be minted within the last hour")
+
+			// A window reaching back to the unix epoch contains every mint.
+			window := time.Duration(time.Now().UnixNano()) + time.Hour
+			stats = stateDB.Stats(5, window)
+			require.Len(t, stats.TopHolders, 2, "Only addresses that own kitties should be listed")
+			require.Equal(t, uint64(3), stats.RecentMints, "Every mint should be within the window")
+			require.InDelta(t, 3/window.Hours(), stats.MintRate, 1e-12, "Mint rate should be per hour")
 		})
 	})
 }
@@ -131,3 +396,11 @@ func TestStateDB_MemoryState(t *testing.T) {
 
 	runStateDBTest(t, stateDB)
 }
+
+func TestStateDB_CachedState(t *testing.T) {
+	stateDB := NewCachedState(NewMemoryState(), 1)
+
+	require.NotNil(t, stateDB, "We should be able to create an empty CachedState")
+
+	runStateDBTest(t, stateDB)
+}
@@ -0,0 +1,33 @@
+package iko
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"time"
+)
+
+// MintRateWindow is the default window over which the mint rate is measured.
+const MintRateWindow = 24 * time.Hour
+
+// StateStats holds aggregate metrics of the state.
+type StateStats struct {
+	TotalKitties uint64        // Number of minted kitties.
+	OwnerCount   uint64        // Number of addresses that own at least one kitty.
+	TopHolders   []HolderCount // Addresses owning the most kitties, in descending order.
+	RecentMints  uint64        // Number of kitties minted within the window.
+	MintRate     float64       // Kitties minted per hour within the window.
+}
+
+// HolderCount is the number of kitties owned by an address.
+type HolderCount struct {
+	Address cipher.Address
+	Count   uint64
+}
+
+// mintRate obtains the number of kitties minted per hour, given the number
+// of kitties minted within the window.
+func mintRate(recent uint64, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(recent) / window.Hours()
+}
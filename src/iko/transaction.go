@@ -4,9 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
-	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"log"
 	"time"
+	"unicode/utf8"
 )
 
 type TxHash cipher.SHA256
@@ -25,11 +25,51 @@ func (h TxHashes) ToStringArray() []string {
 	return out
 }
 
+// TxRef references the transaction that causes a change in state.
+type TxRef struct {
+	Hash TxHash
+	Seq  uint64
+	TS   int64
+}
+
 type TxAction func(tx *Transaction) error
 
+const (
+	// TxVersionLegacy is the transaction version of the original format,
+	// that transfers a single kitty and carries no optional fields. It is
+	// serialized without a version byte, so that transactions of existing
+	// chains keep their hashes.
+	TxVersionLegacy uint8 = 0
+
+	// TxVersionExtended is the transaction version that carries any of the
+	// optional fields of 'TxFeatures', such as multiple kitties, fees and
+	// delegations. New fields are added as features of this version.
+	TxVersionExtended uint8 = 1
+
+	// TxBreedParents is the number of parents of a bred kitty.
+	TxBreedParents = 2
+
+	// TxMaxKitties is the maximum number of kitties a transaction can transfer.
+	TxMaxKitties = 256
+
+	// TxMaxMemoSize is the maximum size of a transaction memo in bytes.
+	TxMaxMemoSize = 128
+
+	// TxMaxMintURISize is the maximum size of the metadata URI of a gen
+	// transaction in bytes.
+	TxMaxMintURISize = 256
+)
+
+// BurnAddress is the address that kitties are transferred to when they are
+// burned. As it belongs to no key, burned kitties can never be transferred
+// again, but their history is kept.
+var BurnAddress = cipher.Address{}
+
 // Transaction represents a kitty transaction.
 // For IKO, transaction and block are combined to formed one entity.
 type Transaction struct {
+	Version uint8 // Determines the format of the transaction.
+
 	Prev TxHash
 	Seq  uint64 // Each transaction has a sequence.
 	TS   int64  // Timestamp.
@@ -38,10 +78,49 @@ type Transaction struct {
 	From    cipher.Address
 	To      cipher.Address
 	Sig     cipher.Sig
+
+	// Optional fields (see 'TxFeatures').
+	Extra     KittyIDs         // Kitties transferred in addition to 'KittyID'.
+	Nonce     uint64           // Number of txs sent by 'From' including this one (transfers only).
+	Fee       uint64           // Transfer fee paid to the master address.
+	Group     cipher.SHA256    // Hash of the atomic group of the tx, if any.
+	Memo      string           // Optional annotation (such as an order ID), covered by 'Sig'.
+	Expiry    int64            // Timestamp after which the tx is not valid (0 never expires).
+	Signers   MultisigAccount  // Key set of 'From' if it is a multisig address.
+	Sigs      []cipher.Sig     // Signatures of 'Signers' (multisig only).
+	Delegate  SignedDelegation // Authorization of the operator that signs for 'From'.
+	Mint      MintMeta         // Metadata of the created kitty (gen txs only).
+	Parents   KittyIDs         // Parents of the created kitty (gen txs only).
+	Cosig     cipher.Sig       // Countersignature of the master (burns only).
+	Scheme    uint8            // Signature scheme of 'From' (see 'TxSigScheme').
+	SchemeKey []byte           // Public key of 'From' (schemes other than secp256k1 only).
+	SchemeSig []byte           // Signature of 'From' (schemes other than secp256k1 only).
+}
+
+// TxExpiredError is returned when injecting a transaction that is expired.
+type TxExpiredError struct {
+	Expiry int64
+	Now    int64
+}
+
+func (e *TxExpiredError) Error() string {
+	return fmt.Sprintf("tx expired at '%d', now is '%d'", e.Expiry, e.Now)
+}
+
+// Is matches 'ErrExpired'.
+func (e *TxExpiredError) Is(target error) bool {
+	return target == ErrExpired
 }
 
 // NewGenTx creates a "gen" transaction. This is where a kitty is created on the blockchain.
 func NewGenTx(prev *Transaction, kittyID KittyID, sk cipher.SecKey) *Transaction {
+	return NewGenTxWithMeta(prev, kittyID, MintMeta{}, sk)
+}
+
+// NewGenTxWithMeta creates a "gen" transaction that records the metadata of
+// the created kitty, so that the chain is the source of truth for what is
+// minted. Empty metadata creates a gen transaction as 'NewGenTx' does.
+func NewGenTxWithMeta(prev *Transaction, kittyID KittyID, meta MintMeta, sk cipher.SecKey) *Transaction {
 	var (
 		address = cipher.AddressFromSecKey(sk)
 		ts      = time.Now().UnixNano()
@@ -66,18 +145,48 @@ func NewGenTx(prev *Transaction, kittyID KittyID, sk cipher.SecKey) *Transaction
 			To:      address,
 		}
 	}
+	if !meta.IsZero() {
+		tx.Mint = meta
+		tx.extend()
+	}
+	tx.Sig = tx.Sign(sk)
+	return tx
+}
+
+// NewBreedTx creates a "gen" transaction where a kitty is bred from two parent
+// kitties, which need to exist in the chain. The lineage is recorded in state.
+func NewBreedTx(prev *Transaction, kittyID KittyID, parents KittyIDs, meta MintMeta, sk cipher.SecKey) *Transaction {
+	tx := NewGenTxWithMeta(prev, kittyID, meta, sk)
+	tx.Parents = append(KittyIDs{}, parents...)
+	tx.extend()
 	tx.Sig = tx.Sign(sk)
 	return tx
 }
 
 // NewTransferTx creates a normal transaction where a kitty is transferred from
-// one address to another.
-func NewTransferTx(prev *Transaction, kittyID KittyID, to cipher.Address, sk cipher.SecKey) *Transaction {
+// one address to another. The nonce needs to be one higher than the nonce of
+// the sending address in state (see 'BlockChain.NextNonce').
+func NewTransferTx(prev *Transaction, kittyID KittyID, to cipher.Address, nonce uint64, sk cipher.SecKey) *Transaction {
+	tx := NewUnsignedTransfer(prev, KittyIDs{kittyID}, cipher.AddressFromSecKey(sk), to, nonce)
+	tx.Sig = tx.Sign(sk)
+	return tx
+}
+
+// NewMultiTransferTx creates a transaction where multiple kitties are
+// transferred from one address to another. The nonce is as for
+// 'NewTransferTx'.
+func NewMultiTransferTx(prev *Transaction, kittyIDs KittyIDs, to cipher.Address, nonce uint64, sk cipher.SecKey) *Transaction {
+	if len(kittyIDs) == 0 {
+		log.Panic("no kitties to transfer")
+	}
 	tx := &Transaction{
+		Version: TxVersionExtended,
 		Prev:    prev.Hash(),
 		Seq:     prev.Seq + 1,
 		TS:      time.Now().UnixNano(),
-		KittyID: kittyID,
+		KittyID: kittyIDs[0],
+		Extra:   append(KittyIDs{}, kittyIDs[1:]...),
+		Nonce:   nonce,
 		From:    cipher.AddressFromSecKey(sk),
 		To:      to,
 	}
@@ -85,17 +194,237 @@ func NewTransferTx(prev *Transaction, kittyID KittyID, to cipher.Address, sk cip
 	return tx
 }
 
+// NewBurnTx creates a transaction where kitties are permanently retired, by
+// transferring them to 'BurnAddress'. If the chain requires burns to be
+// countersigned, the transaction also needs to be signed with 'Countersign'
+// by the master key. The nonce is as for 'NewTransferTx'.
+func NewBurnTx(prev *Transaction, kittyIDs KittyIDs, nonce uint64, sk cipher.SecKey) *Transaction {
+	tx := NewUnsignedTransfer(prev, kittyIDs, cipher.AddressFromSecKey(sk), BurnAddress, nonce)
+	tx.Sig = tx.Sign(sk)
+	return tx
+}
+
+// NewUnsignedTransfer creates an unsigned transaction where kitties are
+// transferred from one address to another, so that it can be signed offline.
+// The signable payload is obtained with 'SignaturePayload' (or its hash with
+// 'SignatureHash'), and the signature attached with 'AttachSignature'.
+func NewUnsignedTransfer(prev *Transaction, kittyIDs KittyIDs, from, to cipher.Address, nonce uint64) *Transaction {
+	if len(kittyIDs) == 0 {
+		log.Panic("no kitties to transfer")
+	}
+	tx := &Transaction{
+		Version: TxVersionLegacy,
+		Prev:    prev.Hash(),
+		Seq:     prev.Seq + 1,
+		TS:      time.Now().UnixNano(),
+		KittyID: kittyIDs[0],
+		Extra:   append(KittyIDs(nil), kittyIDs[1:]...),
+		Nonce:   nonce,
+		From:    from,
+		To:      to,
+	}
+	if tx.Features() != 0 || to == BurnAddress {
+		// Burns use the extended version, so that they can be
+		// countersigned.
+		tx.extend()
+	}
+	return tx
+}
+
+// NewMultisigTransferTx creates an unsigned transaction where kitties are
+// transferred from the address of a multisig account to another address.
+// The transaction needs to be signed with 'MultiSign' by enough of the
+// account's keys. Fields such as 'Memo' and 'Nonce' should be set directly
+// before signing.
+func NewMultisigTransferTx(prev *Transaction, kittyIDs KittyIDs, from MultisigAccount, to cipher.Address) *Transaction {
+	tx := NewUnsignedTransfer(prev, kittyIDs, from.Address(), to, 0)
+	tx.Signers = from
+	tx.extend()
+	return tx
+}
+
+// MultiSign adds a signature by one of the keys of the multisig account.
+func (tx *Transaction) MultiSign(sk cipher.SecKey) {
+	tx.Sigs = append(tx.Sigs, cipher.SignHash(tx.HashInner(), sk))
+}
+
+// SetMemo sets the memo of the transaction, changes it to the extended
+// version, and signs the transaction again so that the signature covers the
+// memo.
+func (tx *Transaction) SetMemo(memo string, sk cipher.SecKey) {
+	tx.Memo = memo
+	tx.extend()
+	tx.Sig = tx.Sign(sk)
+}
+
+// SetNonce sets the nonce of the transaction, changes it to the extended
+// version, and signs the transaction again. The nonce of a transfer needs to
+// be one higher than the nonce of the sending address in state.
+func (tx *Transaction) SetNonce(nonce uint64, sk cipher.SecKey) {
+	tx.Nonce = nonce
+	tx.extend()
+	tx.Sig = tx.Sign(sk)
+}
+
+// SetExpiry sets the timestamp after which the transaction is no longer
+// valid, changes it to the extended version, and signs the transaction again.
+func (tx *Transaction) SetExpiry(expiry int64, sk cipher.SecKey) {
+	tx.Expiry = expiry
+	tx.extend()
+	tx.Sig = tx.Sign(sk)
+}
+
+// PayFee sets the transfer fee of an unsigned transaction, and changes it to
+// the extended version.
+func (tx *Transaction) PayFee(fee uint64) {
+	tx.Fee = fee
+	tx.extend()
+}
+
+// SetFee sets the transfer fee of the transaction (as with 'PayFee'), and
+// signs the transaction again.
+func (tx *Transaction) SetFee(fee uint64, sk cipher.SecKey) {
+	tx.PayFee(fee)
+	tx.Sig = tx.Sign(sk)
+}
+
+// JoinGroup sets the atomic group of an unsigned transaction (see
+// 'GroupHash'), and changes it to the extended version.
+func (tx *Transaction) JoinGroup(group cipher.SHA256) {
+	tx.Group = group
+	tx.extend()
+}
+
+// SetGroup sets the atomic group of the transaction (as with 'JoinGroup'),
+// and signs the transaction again.
+func (tx *Transaction) SetGroup(group cipher.SHA256, sk cipher.SecKey) {
+	tx.JoinGroup(group)
+	tx.Sig = tx.Sign(sk)
+}
+
+// IsGrouped returns true if the transaction is part of an atomic group, and
+// hence can only be injected together with the rest of the group.
+func (tx Transaction) IsGrouped() bool {
+	return tx.Group != (cipher.SHA256{})
+}
+
+// IsBurn returns true if the transaction burns its kitties.
+func (tx Transaction) IsBurn() bool {
+	return tx.To == BurnAddress
+}
+
+// Countersign countersigns a burn with the master secret key. The
+// countersignature is over the same hash as the signature of the sender.
+func (tx *Transaction) Countersign(sk cipher.SecKey) {
+	tx.Cosig = cipher.SignHash(tx.SignatureHash(), sk)
+}
+
+// extend changes the transaction to the extended version, so that it can
+// carry optional fields.
+func (tx *Transaction) extend() {
+	tx.Version = TxVersionExtended
+}
+
+// CheckExpiry returns a '*TxExpiredError' if the transaction is expired at
+// the specified timestamp.
+// The expiry of the delegation of the transaction (if any) is also checked.
+func (tx Transaction) CheckExpiry(now int64) error {
+	if tx.Expiry != 0 && now > tx.Expiry {
+		return &TxExpiredError{Expiry: tx.Expiry, Now: now}
+	}
+	if d := tx.Delegate; d.Expiry != 0 && now > d.Expiry {
+		return &TxExpiredError{Expiry: d.Expiry, Now: now}
+	}
+	return nil
+}
+
+// Kitties obtains the IDs of all kitties transferred by the transaction.
+func (tx Transaction) Kitties() KittyIDs {
+	out := make(KittyIDs, 0, 1+len(tx.Extra))
+	out = append(out, tx.KittyID)
+	return append(out, tx.Extra...)
+}
+
+// Serialize encodes the transaction in the format of its version.
+// Use 'DecodeTx' to deserialize. It returns nil if the transaction can not be
+// encoded in the format of its version, as such transactions never verify.
 func (tx Transaction) Serialize() []byte {
-	return encoder.Serialize(tx)
+	raw, e := encodeTx(tx)
+	if e != nil {
+		return nil
+	}
+	return raw
 }
 
 func (tx Transaction) Hash() TxHash {
 	return TxHash(cipher.SumSHA256(tx.Serialize()))
 }
 
+// Ref obtains the reference of the transaction.
+func (tx Transaction) Ref() TxRef {
+	return TxRef{
+		Hash: tx.Hash(),
+		Seq:  tx.Seq,
+		TS:   tx.TS,
+	}
+}
+
 func (tx Transaction) HashInner() cipher.SHA256 {
+	return tx.SignatureHash()
+}
+
+// SignaturePayload obtains the canonical serialization of the transaction
+// without signatures. This is what signers sign the hash of.
+func (tx Transaction) SignaturePayload() []byte {
 	tx.Sig = cipher.Sig{}
-	return cipher.SHA256(tx.Hash())
+	tx.Sigs = nil
+	tx.Cosig = cipher.Sig{}
+	tx.SchemeKey = nil
+	tx.SchemeSig = nil
+	return tx.Serialize()
+}
+
+// SignatureHash obtains the hash that signers of the transaction sign.
+func (tx Transaction) SignatureHash() cipher.SHA256 {
+	return cipher.SumSHA256(tx.SignaturePayload())
+}
+
+// AttachSignature attaches a signature that is produced offline.
+// For multisig addresses, the signature is added to those of the other
+// signers. It returns an error if the signature is not by the signer (one
+// of the signers of the multisig account, or the operator of the delegation)
+// of the transaction.
+func (tx *Transaction) AttachSignature(sig cipher.Sig) error {
+	if tx.SigScheme() != Secp256k1Scheme {
+		return fmt.Errorf("tx with scheme '%s' needs a signature of the scheme (see 'AttachSchemeSignature')",
+			tx.SigScheme().Name())
+	}
+	hash := tx.SignatureHash()
+	if tx.IsDelegated() {
+		if e := cipher.VerifySignature(tx.Delegate.Operator, sig, hash); e != nil {
+			return e
+		}
+		tx.Sig = sig
+		return nil
+	}
+	if tx.Signers.IsZero() {
+		if e := cipher.ChkSig(tx.From, hash, sig); e != nil {
+			return e
+		}
+		tx.Sig = sig
+		return nil
+	}
+	pk, e := cipher.PubKeyFromSig(sig, hash)
+	if e != nil {
+		return e
+	}
+	for _, signer := range tx.Signers.PubKeys {
+		if signer == pk {
+			tx.Sigs = append(tx.Sigs, sig)
+			return nil
+		}
+	}
+	return fmt.Errorf("signature of public key '%s' is not from multisig account", pk.Hex())
 }
 
 func (tx Transaction) Sign(sk cipher.SecKey) cipher.Sig {
@@ -109,15 +438,36 @@ func (tx Transaction) Sign(sk cipher.SecKey) cipher.Sig {
 }
 
 // Verify checks the hash, seq and signature of the transaction.
+//		- Tx version, and the kitties transferred are valid for the version.
+//		- Tx memo is valid UTF-8 and within 'TxMaxMemoSize'.
+//		- Tx timestamp is not after the tx expiry.
 //		- Previous tx hash.
 //		- Tx sequence.
-//		- Tx timestamp (needs to be ahead of the previous tx, and behind ts now with threshold).
-//		- Tx signature.
+//		- Tx timestamp (needs to be ahead of the previous tx, and within the
+//		  'DefaultTxLimits' clock skew of ts now).
+//		- Tx signature (or threshold of signatures for multisig addresses).
 // Verify does not check:
 //		- Whether from address actually owns the kitty of ID.
 //		- Double spending of kitties.
 // TODO (evanlinjin): Write tests.
 func (tx Transaction) Verify(prev *Transaction) error {
+	if e := tx.verifyContent(); e != nil {
+		return e
+	}
+	if e := tx.verifyLink(prev); e != nil {
+		return e
+	}
+	if e := DefaultTxLimits.Check(&tx, time.Now().UnixNano()); e != nil {
+		return e
+	}
+
+	// Check signature.
+	return tx.verifySig()
+}
+
+// verifyLink checks the hash, seq and timestamp of the transaction against
+// the previous transaction (nil for genesis).
+func (tx Transaction) verifyLink(prev *Transaction) error {
 	isGenesis := prev == nil
 
 	// Check hash.
@@ -144,13 +494,123 @@ func (tx Transaction) Verify(prev *Transaction) error {
 
 	// Check timestamp.
 	if prev != nil {
-		if tx.TS <= prev.TS || tx.TS > time.Now().UnixNano()+int64(time.Minute) {
+		if tx.TS <= prev.TS {
 			return errors.New("invalid ts")
 		}
 	}
+	return nil
+}
 
-	// Check signature.
-	return cipher.ChkSig(tx.From, tx.HashInner(), tx.Sig)
+// verifyContent checks the parts of the transaction that do not depend
+// on the chain (version, kitties, memo and expiry).
+func (tx Transaction) verifyContent() error {
+	// Check version and kitties.
+	if _, e := encodeTx(tx); e != nil {
+		return e
+	}
+	if e := tx.verifyKitties(); e != nil {
+		return e
+	}
+	if tx.IsDelegated() {
+		if e := tx.verifyDelegation(); e != nil {
+			return e
+		}
+	}
+	if !tx.Mint.IsZero() {
+		if e := tx.verifyMint(); e != nil {
+			return e
+		}
+	}
+	if len(tx.Parents) != 0 {
+		if e := tx.verifyParents(); e != nil {
+			return e
+		}
+	}
+	if e := tx.verifyScheme(); e != nil {
+		return e
+	}
+	if tx.Cosig != (cipher.Sig{}) && !tx.IsBurn() {
+		return errors.New("only burn tx can be countersigned")
+	}
+
+	// Check memo.
+	if len(tx.Memo) > TxMaxMemoSize {
+		return fmt.Errorf("memo is larger than %d bytes", TxMaxMemoSize)
+	}
+	if !utf8.ValidString(tx.Memo) {
+		return errors.New("memo is not valid utf-8")
+	}
+
+	// Check expiry against the tx's own timestamp, so that replaying the
+	// chain is not affected by the current time.
+	return tx.CheckExpiry(tx.TS)
+}
+
+func (tx Transaction) verifySig() error {
+	if tx.IsDelegated() {
+		return tx.verifyDelegatedSig()
+	}
+	if tx.Signers.IsZero() {
+		if len(tx.Sigs) != 0 {
+			return errors.New("single signer tx has multisig signatures")
+		}
+		pk, sig := tx.schemeSig()
+		return tx.SigScheme().Verify(tx.From, tx.HashInner(), pk, sig)
+	}
+	if tx.Signers.Address() != tx.From {
+		return errors.New("multisig account does not match from address")
+	}
+	return tx.Signers.VerifySigs(tx.HashInner(), tx.Sigs)
+}
+
+func (tx Transaction) verifyKitties() error {
+	if len(tx.Extra)+1 > TxMaxKitties {
+		return fmt.Errorf("transaction has more than %d kitties", TxMaxKitties)
+	}
+	seen := make(map[KittyID]struct{}, len(tx.Extra)+1)
+	for _, kittyID := range tx.Kitties() {
+		if _, ok := seen[kittyID]; ok {
+			return fmt.Errorf("kitty of id '%d' is transferred more than once", kittyID)
+		}
+		seen[kittyID] = struct{}{}
+	}
+	return nil
+}
+
+// verifyMint checks the mint metadata of a transaction. Whether the
+// transaction is actually a gen transaction depends on the chain, and is
+// checked when it is applied.
+func (tx Transaction) verifyMint() error {
+	if len(tx.Extra) != 0 || tx.From != tx.To {
+		return errors.New("only gen tx can carry mint metadata")
+	}
+	if len(tx.Mint.URI) > TxMaxMintURISize {
+		return fmt.Errorf("mint uri is larger than %d bytes", TxMaxMintURISize)
+	}
+	if !utf8.ValidString(tx.Mint.URI) {
+		return errors.New("mint uri is not valid utf-8")
+	}
+	return nil
+}
+
+// verifyParents checks the parents of a breed transaction. Whether the parents
+// exist is checked when it is applied.
+func (tx Transaction) verifyParents() error {
+	if len(tx.Extra) != 0 || tx.From != tx.To {
+		return errors.New("only gen tx can breed kitties")
+	}
+	if len(tx.Parents) != TxBreedParents {
+		return fmt.Errorf("bred kitty needs %d parents", TxBreedParents)
+	}
+	if tx.Parents[0] == tx.Parents[1] {
+		return errors.New("parents of bred kitty need to be different")
+	}
+	for _, parent := range tx.Parents {
+		if parent == tx.KittyID {
+			return fmt.Errorf("kitty of id '%d' can not be its own parent", parent)
+		}
+	}
+	return nil
 }
 
 // IsKittyGen returns true if:
@@ -172,6 +632,6 @@ func (tx Transaction) IsKittyGen(pk cipher.PubKey) bool {
 
 // String returns human readable string of transaction.
 func (tx Transaction) String() string {
-	return fmt.Sprintf("prev:%s|seq:%d|ts:%d|kitty_id:%d|from:%s|to:%s|sig:%s",
-		tx.Prev.Hex(), tx.Seq, tx.TS, tx.KittyID, tx.From.String(), tx.To.String(), tx.Sig.Hex())
+	return fmt.Sprintf("version:%d|prev:%s|seq:%d|ts:%d|kitty_ids:%v|nonce:%d|fee:%d|group:%s|from:%s|to:%s|memo:%q|expiry:%d|sig:%s",
+		tx.Version, tx.Prev.Hex(), tx.Seq, tx.TS, tx.Kitties(), tx.Nonce, tx.Fee, tx.Group.Hex(), tx.From.String(), tx.To.String(), tx.Memo, tx.Expiry, tx.Sig.Hex())
 }
@@ -0,0 +1,328 @@
+package iko
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"reflect"
+	"strings"
+)
+
+// A serialized transaction of the legacy version is exactly the fields of
+// 'txLegacy', without a version byte, as it was before transactions were
+// versioned. Every other serialized transaction is the version byte of the
+// transaction, followed by the body of the transaction in the format of the
+// version. The body of the extended version is longer than a legacy
+// transaction, so the legacy version is told apart by its size.
+//
+// The body of the extended version is the fields that every transaction has,
+// the 'TxFeatures' of the transaction, and then the optional field of each
+// feature in the order of the feature bits. New fields are introduced by
+// adding a feature to 'txFields', so that transactions with existing features
+// decode as before.
+
+// TxFeatures determines the optional fields that a transaction of the
+// extended version carries.
+type TxFeatures uint32
+
+const (
+	TxFeatureKitties  TxFeatures = 1 << iota // 'Extra'.
+	TxFeatureNonce                           // 'Nonce'.
+	TxFeatureFee                             // 'Fee'.
+	TxFeatureGroup                           // 'Group'.
+	TxFeatureMemo                            // 'Memo'.
+	TxFeatureExpiry                          // 'Expiry'.
+	TxFeatureMultisig                        // 'Signers' and 'Sigs'.
+	TxFeatureDelegate                        // 'Delegate'.
+	TxFeatureMint                            // 'Mint'.
+	TxFeatureParents                         // 'Parents'.
+	TxFeatureCosig                           // 'Cosig'.
+	TxFeatureScheme                          // 'Scheme', 'SchemeKey' and 'SchemeSig'.
+)
+
+// txField is the optional field for a feature.
+type txField struct {
+	feature TxFeatures
+	name    string
+	isSet   func(tx *Transaction) bool
+	values  func(tx *Transaction) []interface{} // Pointers to the values of the field, in order.
+}
+
+var txFields = []txField{
+	{
+		feature: TxFeatureKitties,
+		name:    "kitties",
+		isSet:   func(tx *Transaction) bool { return len(tx.Extra) != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Extra} },
+	},
+	{
+		feature: TxFeatureNonce,
+		name:    "nonce",
+		isSet:   func(tx *Transaction) bool { return tx.Nonce != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Nonce} },
+	},
+	{
+		feature: TxFeatureFee,
+		name:    "fee",
+		isSet:   func(tx *Transaction) bool { return tx.Fee != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Fee} },
+	},
+	{
+		feature: TxFeatureGroup,
+		name:    "group",
+		isSet:   func(tx *Transaction) bool { return tx.IsGrouped() },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Group} },
+	},
+	{
+		feature: TxFeatureMemo,
+		name:    "memo",
+		isSet:   func(tx *Transaction) bool { return tx.Memo != "" },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Memo} },
+	},
+	{
+		feature: TxFeatureExpiry,
+		name:    "expiry",
+		isSet:   func(tx *Transaction) bool { return tx.Expiry != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Expiry} },
+	},
+	{
+		feature: TxFeatureMultisig,
+		name:    "multisig",
+		isSet:   func(tx *Transaction) bool { return !tx.Signers.IsZero() || len(tx.Sigs) != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Signers, &tx.Sigs} },
+	},
+	{
+		feature: TxFeatureDelegate,
+		name:    "delegate",
+		isSet:   func(tx *Transaction) bool { return tx.IsDelegated() },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Delegate} },
+	},
+	{
+		feature: TxFeatureMint,
+		name:    "mint",
+		isSet:   func(tx *Transaction) bool { return !tx.Mint.IsZero() },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Mint} },
+	},
+	{
+		feature: TxFeatureParents,
+		name:    "parents",
+		isSet:   func(tx *Transaction) bool { return len(tx.Parents) != 0 },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Parents} },
+	},
+	{
+		feature: TxFeatureCosig,
+		name:    "cosig",
+		isSet:   func(tx *Transaction) bool { return tx.Cosig != (cipher.Sig{}) },
+		values:  func(tx *Transaction) []interface{} { return []interface{}{&tx.Cosig} },
+	},
+	{
+		feature: TxFeatureScheme,
+		name:    "scheme",
+		isSet: func(tx *Transaction) bool {
+			return tx.Scheme != Secp256k1Scheme.ID() || len(tx.SchemeKey) != 0 || len(tx.SchemeSig) != 0
+		},
+		values: func(tx *Transaction) []interface{} {
+			return []interface{}{&tx.Scheme, &tx.SchemeKey, &tx.SchemeSig}
+		},
+	},
+}
+
+// txKnownFeatures are the features of 'txFields'.
+var txKnownFeatures = func() TxFeatures {
+	var out TxFeatures
+	for _, field := range txFields {
+		out |= field.feature
+	}
+	return out
+}()
+
+// String obtains the names of the features, separated by commas.
+func (f TxFeatures) String() string {
+	var names []string
+	for _, field := range txFields {
+		if f&field.feature != 0 {
+			names = append(names, field.name)
+		}
+	}
+	if unknown := f &^ txKnownFeatures; unknown != 0 {
+		names = append(names, fmt.Sprintf("unknown(%#x)", uint32(unknown)))
+	}
+	return strings.Join(names, ",")
+}
+
+// Features obtains the features of the optional fields that the transaction
+// carries.
+func (tx Transaction) Features() TxFeatures {
+	var out TxFeatures
+	for _, field := range txFields {
+		if field.isSet(&tx) {
+			out |= field.feature
+		}
+	}
+	return out
+}
+
+// txLegacySize is the size of a serialized transaction of the legacy version.
+var txLegacySize = len(encoder.Serialize(txLegacy{}))
+
+// DecodeTx deserializes a transaction by its size (for the legacy version)
+// or else by the format of its version byte.
+func DecodeTx(raw []byte) (*Transaction, error) {
+	switch {
+	case len(raw) == 0:
+		return nil, errors.New("empty transaction")
+	case len(raw) == txLegacySize:
+		tx := &Transaction{Version: TxVersionLegacy}
+		if e := decodeTxLegacy(raw, tx); e != nil {
+			return nil, fmt.Errorf("failed to decode tx of version '%d': %v", TxVersionLegacy, e)
+		}
+		return tx, nil
+	case raw[0] == TxVersionExtended:
+		tx := &Transaction{Version: TxVersionExtended}
+		if e := decodeTxExtended(raw[1:], tx); e != nil {
+			return nil, fmt.Errorf("failed to decode tx of version '%d': %v", TxVersionExtended, e)
+		}
+		return tx, nil
+	default:
+		return nil, fmt.Errorf("invalid tx version '%d'", raw[0])
+	}
+}
+
+// encodeTx serializes a transaction in the format of its version. It fails
+// for unknown versions, and for legacy transactions with optional fields.
+func encodeTx(tx Transaction) ([]byte, error) {
+	switch tx.Version {
+	case TxVersionLegacy:
+		if features := tx.Features(); features != 0 {
+			return nil, fmt.Errorf("tx of version '%d' can not carry '%s'", tx.Version, features)
+		}
+		return encodeTxLegacy(tx), nil
+	case TxVersionExtended:
+		return append([]byte{tx.Version}, encodeTxExtended(tx)...), nil
+	default:
+		return nil, fmt.Errorf("invalid tx version '%d'", tx.Version)
+	}
+}
+
+// txLegacy is a transaction of the legacy version.
+type txLegacy struct {
+	Prev    TxHash
+	Seq     uint64
+	TS      int64
+	KittyID KittyID
+	From    cipher.Address
+	To      cipher.Address
+	Sig     cipher.Sig
+}
+
+func encodeTxLegacy(tx Transaction) []byte {
+	return encoder.Serialize(txLegacy{
+		Prev:    tx.Prev,
+		Seq:     tx.Seq,
+		TS:      tx.TS,
+		KittyID: tx.KittyID,
+		From:    tx.From,
+		To:      tx.To,
+		Sig:     tx.Sig,
+	})
+}
+
+func decodeTxLegacy(raw []byte, tx *Transaction) error {
+	var v txLegacy
+	if e := encoder.DeserializeRaw(raw, &v); e != nil {
+		return e
+	}
+	tx.Prev = v.Prev
+	tx.Seq = v.Seq
+	tx.TS = v.TS
+	tx.KittyID = v.KittyID
+	tx.From = v.From
+	tx.To = v.To
+	tx.Sig = v.Sig
+	return nil
+}
+
+// txExtended is the part of the body of a transaction of the extended
+// version that precedes the optional fields.
+type txExtended struct {
+	Prev     TxHash
+	Seq      uint64
+	TS       int64
+	KittyID  KittyID
+	From     cipher.Address
+	To       cipher.Address
+	Sig      cipher.Sig
+	Features uint32
+}
+
+func encodeTxExtended(tx Transaction) []byte {
+	features := tx.Features()
+	out := encoder.Serialize(txExtended{
+		Prev:     tx.Prev,
+		Seq:      tx.Seq,
+		TS:       tx.TS,
+		KittyID:  tx.KittyID,
+		From:     tx.From,
+		To:       tx.To,
+		Sig:      tx.Sig,
+		Features: uint32(features),
+	})
+	for _, field := range txFields {
+		if features&field.feature == 0 {
+			continue
+		}
+		for _, v := range field.values(&tx) {
+			out = append(out, encoder.Serialize(v)...)
+		}
+	}
+	return out
+}
+
+func decodeTxExtended(body []byte, tx *Transaction) error {
+	var v txExtended
+	if e := decodeTxValue(&body, &v); e != nil {
+		return e
+	}
+	features := TxFeatures(v.Features)
+	if unknown := features &^ txKnownFeatures; unknown != 0 {
+		return fmt.Errorf("unknown features '%#x'", uint32(unknown))
+	}
+	tx.Prev = v.Prev
+	tx.Seq = v.Seq
+	tx.TS = v.TS
+	tx.KittyID = v.KittyID
+	tx.From = v.From
+	tx.To = v.To
+	tx.Sig = v.Sig
+	for _, field := range txFields {
+		if features&field.feature == 0 {
+			continue
+		}
+		for _, value := range field.values(tx) {
+			if e := decodeTxValue(&body, value); e != nil {
+				return fmt.Errorf("invalid %s: %v", field.name, e)
+			}
+		}
+	}
+	if len(body) != 0 {
+		return fmt.Errorf("tx has %d trailing bytes", len(body))
+	}
+	// Features of empty fields would not be encoded again, so the hash of the
+	// decoded transaction would differ.
+	if got := tx.Features(); got != features {
+		return fmt.Errorf("features '%s' do not match fields '%s'", features, got)
+	}
+	return nil
+}
+
+// decodeTxValue decodes a value from the start of the raw bytes, and advances
+// the raw bytes past it.
+func decodeTxValue(raw *[]byte, v interface{}) error {
+	n, e := encoder.DeserializeRawToValue(*raw, reflect.ValueOf(v))
+	if e != nil {
+		return e
+	}
+	*raw = (*raw)[n:]
+	return nil
+}
@@ -0,0 +1,280 @@
+package iko
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// The canonical JSON encoding of a transaction has the fields of 'txJSON' in
+// order, without whitespace or HTML escaping. Hashes, keys and signatures are
+// lowercase hex, addresses are base58, and 64 bit integers are decimal
+// strings (so that they are exact in clients that parse numbers as doubles).
+// Fields that are not used by the transaction are omitted. Encoding the same
+// transaction always produces the same bytes.
+//
+// Note that transaction hashes and signatures are always over the binary
+// encoding ('Serialize').
+
+type txJSON struct {
+	Version   uint8           `json:"version"`
+	Prev      string          `json:"prev"`
+	Seq       uint64          `json:"seq,string"`
+	TS        int64           `json:"ts,string"`
+	KittyID   KittyID         `json:"kitty_id,string"`
+	Extra     []string        `json:"extra,omitempty"`
+	Nonce     uint64          `json:"nonce,string"`
+	Fee       uint64          `json:"fee,omitempty,string"`
+	Group     string          `json:"group,omitempty"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Memo      string          `json:"memo,omitempty"`
+	Expiry    int64           `json:"expiry,omitempty,string"`
+	Signers   *multisigJSON   `json:"signers,omitempty"`
+	Delegate  *delegationJSON `json:"delegation,omitempty"`
+	Mint      *mintJSON       `json:"mint,omitempty"`
+	Parents   []string        `json:"parents,omitempty"`
+	Sig       string          `json:"sig,omitempty"`
+	Sigs      []string        `json:"sigs,omitempty"`
+	Cosig     string          `json:"cosig,omitempty"`
+	Scheme    string          `json:"scheme,omitempty"`
+	SchemeKey string          `json:"scheme_key,omitempty"`
+	SchemeSig string          `json:"scheme_sig,omitempty"`
+}
+
+type multisigJSON struct {
+	Threshold uint8    `json:"threshold"`
+	PubKeys   []string `json:"pub_keys"`
+}
+
+type delegationJSON struct {
+	KittyID  KittyID `json:"kitty_id,string"`
+	Nonce    uint64  `json:"nonce,string"`
+	Operator string  `json:"operator"`
+	Expiry   int64   `json:"expiry,omitempty,string"`
+	Sig      string  `json:"sig"`
+}
+
+type mintJSON struct {
+	AttrHash string `json:"attr_hash,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// MarshalJSON encodes the transaction in the canonical JSON encoding.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	v := txJSON{
+		Version: tx.Version,
+		Prev:    tx.Prev.Hex(),
+		Seq:     tx.Seq,
+		TS:      tx.TS,
+		KittyID: tx.KittyID,
+		Extra:   kittyIDStrings(tx.Extra),
+		Nonce:   tx.Nonce,
+		Fee:     tx.Fee,
+		From:    tx.From.String(),
+		To:      tx.To.String(),
+		Memo:    tx.Memo,
+		Expiry:  tx.Expiry,
+		Parents: kittyIDStrings(tx.Parents),
+	}
+	if tx.IsGrouped() {
+		v.Group = tx.Group.Hex()
+	}
+	if !tx.Signers.IsZero() {
+		v.Signers = &multisigJSON{
+			Threshold: tx.Signers.Threshold,
+			PubKeys:   make([]string, len(tx.Signers.PubKeys)),
+		}
+		for i, pk := range tx.Signers.PubKeys {
+			v.Signers.PubKeys[i] = pk.Hex()
+		}
+	}
+	if tx.IsDelegated() {
+		v.Delegate = &delegationJSON{
+			KittyID:  tx.Delegate.KittyID,
+			Nonce:    tx.Delegate.Nonce,
+			Operator: tx.Delegate.Operator.Hex(),
+			Expiry:   tx.Delegate.Expiry,
+			Sig:      tx.Delegate.Sig.Hex(),
+		}
+	}
+	if !tx.Mint.IsZero() {
+		v.Mint = &mintJSON{URI: tx.Mint.URI}
+		if tx.Mint.AttrHash != (cipher.SHA256{}) {
+			v.Mint.AttrHash = tx.Mint.AttrHash.Hex()
+		}
+	}
+	if tx.Sig != (cipher.Sig{}) {
+		v.Sig = tx.Sig.Hex()
+	}
+	for _, sig := range tx.Sigs {
+		v.Sigs = append(v.Sigs, sig.Hex())
+	}
+	if tx.Cosig != (cipher.Sig{}) {
+		v.Cosig = tx.Cosig.Hex()
+	}
+	if tx.Scheme != Secp256k1Scheme.ID() {
+		v.Scheme = tx.SigScheme().Name()
+	}
+	v.SchemeKey = hex.EncodeToString(tx.SchemeKey)
+	v.SchemeSig = hex.EncodeToString(tx.SchemeSig)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if e := enc.Encode(v); e != nil {
+		return nil, e
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON decodes a transaction from the canonical JSON encoding.
+// Unknown fields are rejected, so that no part of a payload is silently lost.
+func (tx *Transaction) UnmarshalJSON(raw []byte) error {
+	var v txJSON
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if e := dec.Decode(&v); e != nil {
+		return e
+	}
+	out := Transaction{
+		Version: v.Version,
+		Seq:     v.Seq,
+		TS:      v.TS,
+		KittyID: v.KittyID,
+		Nonce:   v.Nonce,
+		Fee:     v.Fee,
+		Memo:    v.Memo,
+		Expiry:  v.Expiry,
+	}
+	var e error
+	if e = hexFixed(out.Prev[:], v.Prev, "prev"); e != nil {
+		return e
+	}
+	if out.Extra, e = kittyIDsOfStrings(v.Extra, "extra"); e != nil {
+		return e
+	}
+	if v.Group != "" {
+		if e = hexFixed(out.Group[:], v.Group, "group"); e != nil {
+			return e
+		}
+	}
+	if out.From, e = cipher.DecodeBase58Address(v.From); e != nil {
+		return fmt.Errorf("invalid from: %v", e)
+	}
+	if out.To, e = cipher.DecodeBase58Address(v.To); e != nil {
+		return fmt.Errorf("invalid to: %v", e)
+	}
+	if v.Signers != nil {
+		out.Signers.Threshold = v.Signers.Threshold
+		out.Signers.PubKeys = make([]cipher.PubKey, len(v.Signers.PubKeys))
+		for i, pkHex := range v.Signers.PubKeys {
+			if e = hexFixed(out.Signers.PubKeys[i][:], pkHex, "signers.pub_keys"); e != nil {
+				return e
+			}
+		}
+	}
+	if d := v.Delegate; d != nil {
+		out.Delegate.KittyID = d.KittyID
+		out.Delegate.Nonce = d.Nonce
+		out.Delegate.Expiry = d.Expiry
+		if e = hexFixed(out.Delegate.Operator[:], d.Operator, "delegation.operator"); e != nil {
+			return e
+		}
+		if e = hexFixed(out.Delegate.Sig[:], d.Sig, "delegation.sig"); e != nil {
+			return e
+		}
+	}
+	if m := v.Mint; m != nil {
+		out.Mint.URI = m.URI
+		if m.AttrHash != "" {
+			if e = hexFixed(out.Mint.AttrHash[:], m.AttrHash, "mint.attr_hash"); e != nil {
+				return e
+			}
+		}
+	}
+	if out.Parents, e = kittyIDsOfStrings(v.Parents, "parents"); e != nil {
+		return e
+	}
+	if v.Sig != "" {
+		if e = hexFixed(out.Sig[:], v.Sig, "sig"); e != nil {
+			return e
+		}
+	}
+	if len(v.Sigs) > 0 {
+		out.Sigs = make([]cipher.Sig, len(v.Sigs))
+		for i, sigHex := range v.Sigs {
+			if e = hexFixed(out.Sigs[i][:], sigHex, "sigs"); e != nil {
+				return e
+			}
+		}
+	}
+	if v.Cosig != "" {
+		if e = hexFixed(out.Cosig[:], v.Cosig, "cosig"); e != nil {
+			return e
+		}
+	}
+	if v.Scheme != "" {
+		scheme, e := SigSchemeFromName(v.Scheme)
+		if e != nil {
+			return e
+		}
+		out.Scheme = scheme.ID()
+	}
+	if v.SchemeKey != "" {
+		if out.SchemeKey, e = hex.DecodeString(v.SchemeKey); e != nil {
+			return fmt.Errorf("invalid scheme_key: %v", e)
+		}
+	}
+	if v.SchemeSig != "" {
+		if out.SchemeSig, e = hex.DecodeString(v.SchemeSig); e != nil {
+			return fmt.Errorf("invalid scheme_sig: %v", e)
+		}
+	}
+	if _, e = encodeTx(out); e != nil {
+		return e
+	}
+	*tx = out
+	return nil
+}
+
+// hexFixed decodes hex of exactly the length of 'out'.
+func hexFixed(out []byte, s, field string) error {
+	b, e := hex.DecodeString(s)
+	if e != nil {
+		return fmt.Errorf("invalid %s: %v", field, e)
+	}
+	if len(b) != len(out) {
+		return fmt.Errorf("invalid %s: length '%d', expected '%d'", field, len(b), len(out))
+	}
+	copy(out, b)
+	return nil
+}
+
+func kittyIDStrings(ids KittyIDs) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make([]string, len(ids))
+	for i, kittyID := range ids {
+		out[i] = fmt.Sprint(uint64(kittyID))
+	}
+	return out
+}
+
+func kittyIDsOfStrings(strs []string, field string) (KittyIDs, error) {
+	if len(strs) == 0 {
+		return nil, nil
+	}
+	out := make(KittyIDs, len(strs))
+	for i, s := range strs {
+		kittyID, e := KittyIDFromString(s)
+		if e != nil {
+			return nil, fmt.Errorf("invalid %s: %v", field, e)
+		}
+		out[i] = kittyID
+	}
+	return out, nil
+}
@@ -0,0 +1,446 @@
+package iko
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// The protobuf encoding of transactions is defined in 'transaction.proto'.
+// It is implemented by hand, as the messages are small and fixed.
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// MarshalProto encodes the transaction as the protobuf message 'iko.Transaction'.
+func (tx Transaction) MarshalProto() []byte {
+	var w protoWriter
+	w.uint(1, uint64(tx.Version))
+	w.bytes(2, tx.Prev[:])
+	w.uint(3, tx.Seq)
+	w.uint(4, uint64(tx.TS))
+	w.uint(5, uint64(tx.KittyID))
+	if len(tx.Extra) > 0 {
+		var packed protoWriter
+		for _, kittyID := range tx.Extra {
+			packed.varint(uint64(kittyID))
+		}
+		w.bytes(6, packed.buf)
+	}
+	w.uint(7, tx.Nonce)
+	w.string(8, protoAddress(tx.From))
+	w.string(9, protoAddress(tx.To))
+	w.string(10, tx.Memo)
+	w.uint(11, uint64(tx.Expiry))
+	if !tx.Signers.IsZero() {
+		var signers protoWriter
+		signers.uint(1, uint64(tx.Signers.Threshold))
+		for _, pk := range tx.Signers.PubKeys {
+			signers.tag(2, protoBytes)
+			signers.varint(uint64(len(pk)))
+			signers.buf = append(signers.buf, pk[:]...)
+		}
+		w.tag(12, protoBytes)
+		w.varint(uint64(len(signers.buf)))
+		w.buf = append(w.buf, signers.buf...)
+	}
+	if tx.Sig != (cipher.Sig{}) {
+		w.bytes(13, tx.Sig[:])
+	}
+	for _, sig := range tx.Sigs {
+		w.tag(14, protoBytes)
+		w.varint(uint64(len(sig)))
+		w.buf = append(w.buf, sig[:]...)
+	}
+	w.uint(15, tx.Fee)
+	if tx.IsGrouped() {
+		w.bytes(16, tx.Group[:])
+	}
+	if tx.IsDelegated() {
+		var d protoWriter
+		d.uint(1, uint64(tx.Delegate.KittyID))
+		d.uint(2, tx.Delegate.Nonce)
+		d.bytes(3, tx.Delegate.Operator[:])
+		d.uint(4, uint64(tx.Delegate.Expiry))
+		d.bytes(5, tx.Delegate.Sig[:])
+		w.tag(17, protoBytes)
+		w.varint(uint64(len(d.buf)))
+		w.buf = append(w.buf, d.buf...)
+	}
+	if !tx.Mint.IsZero() {
+		var m protoWriter
+		if tx.Mint.AttrHash != (cipher.SHA256{}) {
+			m.bytes(1, tx.Mint.AttrHash[:])
+		}
+		m.string(2, tx.Mint.URI)
+		w.tag(18, protoBytes)
+		w.varint(uint64(len(m.buf)))
+		w.buf = append(w.buf, m.buf...)
+	}
+	if tx.Cosig != (cipher.Sig{}) {
+		w.bytes(19, tx.Cosig[:])
+	}
+	if len(tx.Parents) > 0 {
+		var packed protoWriter
+		for _, kittyID := range tx.Parents {
+			packed.varint(uint64(kittyID))
+		}
+		w.bytes(20, packed.buf)
+	}
+	w.bytes(21, tx.SchemeKey)
+	w.bytes(22, tx.SchemeSig)
+	w.uint(23, uint64(tx.Scheme))
+	return w.buf
+}
+
+// UnmarshalTxProto decodes a transaction from the protobuf message 'iko.Transaction'.
+func UnmarshalTxProto(raw []byte) (*Transaction, error) {
+	tx := new(Transaction)
+	e := readProto(raw, func(field uint64, r *protoReader) error {
+		switch field {
+		case 1:
+			v, e := r.uint()
+			if v > 0xFF {
+				return fmt.Errorf("invalid tx version '%d'", v)
+			}
+			tx.Version = uint8(v)
+			return e
+		case 2:
+			return r.fixedBytes(tx.Prev[:])
+		case 3:
+			v, e := r.uint()
+			tx.Seq = v
+			return e
+		case 4:
+			v, e := r.uint()
+			tx.TS = int64(v)
+			return e
+		case 5:
+			v, e := r.uint()
+			tx.KittyID = KittyID(v)
+			return e
+		case 6:
+			return r.uints(func(v uint64) {
+				tx.Extra = append(tx.Extra, KittyID(v))
+			})
+		case 7:
+			v, e := r.uint()
+			tx.Nonce = v
+			return e
+		case 8:
+			return r.address(&tx.From)
+		case 9:
+			return r.address(&tx.To)
+		case 10:
+			v, e := r.bytes()
+			tx.Memo = string(v)
+			return e
+		case 11:
+			v, e := r.uint()
+			tx.Expiry = int64(v)
+			return e
+		case 12:
+			v, e := r.bytes()
+			if e != nil {
+				return e
+			}
+			return readProto(v, func(field uint64, r *protoReader) error {
+				switch field {
+				case 1:
+					v, e := r.uint()
+					if v > 0xFF {
+						return fmt.Errorf("invalid multisig threshold '%d'", v)
+					}
+					tx.Signers.Threshold = uint8(v)
+					return e
+				case 2:
+					var pk cipher.PubKey
+					if e := r.fixedBytes(pk[:]); e != nil {
+						return e
+					}
+					tx.Signers.PubKeys = append(tx.Signers.PubKeys, pk)
+					return nil
+				default:
+					return r.skip()
+				}
+			})
+		case 13:
+			return r.fixedBytes(tx.Sig[:])
+		case 14:
+			var sig cipher.Sig
+			if e := r.fixedBytes(sig[:]); e != nil {
+				return e
+			}
+			tx.Sigs = append(tx.Sigs, sig)
+			return nil
+		case 15:
+			v, e := r.uint()
+			tx.Fee = v
+			return e
+		case 16:
+			return r.fixedBytes(tx.Group[:])
+		case 17:
+			v, e := r.bytes()
+			if e != nil {
+				return e
+			}
+			d := &tx.Delegate
+			return readProto(v, func(field uint64, r *protoReader) error {
+				switch field {
+				case 1:
+					v, e := r.uint()
+					d.KittyID = KittyID(v)
+					return e
+				case 2:
+					v, e := r.uint()
+					d.Nonce = v
+					return e
+				case 3:
+					return r.fixedBytes(d.Operator[:])
+				case 4:
+					v, e := r.uint()
+					d.Expiry = int64(v)
+					return e
+				case 5:
+					return r.fixedBytes(d.Sig[:])
+				default:
+					return r.skip()
+				}
+			})
+		case 18:
+			v, e := r.bytes()
+			if e != nil {
+				return e
+			}
+			m := &tx.Mint
+			return readProto(v, func(field uint64, r *protoReader) error {
+				switch field {
+				case 1:
+					return r.fixedBytes(m.AttrHash[:])
+				case 2:
+					v, e := r.bytes()
+					m.URI = string(v)
+					return e
+				default:
+					return r.skip()
+				}
+			})
+		case 19:
+			return r.fixedBytes(tx.Cosig[:])
+		case 20:
+			return r.uints(func(v uint64) {
+				tx.Parents = append(tx.Parents, KittyID(v))
+			})
+		case 21:
+			v, e := r.bytes()
+			tx.SchemeKey = append([]byte(nil), v...)
+			return e
+		case 22:
+			v, e := r.bytes()
+			tx.SchemeSig = append([]byte(nil), v...)
+			return e
+		case 23:
+			v, e := r.uint()
+			if v > 0xFF {
+				return fmt.Errorf("invalid signature scheme '%d'", v)
+			}
+			tx.Scheme = uint8(v)
+			return e
+		default:
+			return r.skip()
+		}
+	})
+	if e == nil {
+		_, e = encodeTx(*tx)
+	}
+	if e != nil {
+		return nil, fmt.Errorf("failed to decode protobuf tx: %v", e)
+	}
+	return tx, nil
+}
+
+func protoAddress(address cipher.Address) string {
+	if address == (cipher.Address{}) {
+		return ""
+	}
+	return address.String()
+}
+
+/*
+	<<< WIRE FORMAT >>>
+*/
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *protoWriter) tag(field uint64, wireType uint64) {
+	w.varint(field<<3 | wireType)
+}
+
+// uint writes a varint field, omitting the default value.
+func (w *protoWriter) uint(field uint64, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, protoVarint)
+	w.varint(v)
+}
+
+// bytes writes a length-delimited field, omitting the default value.
+func (w *protoWriter) bytes(field uint64, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.tag(field, protoBytes)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *protoWriter) string(field uint64, v string) {
+	w.bytes(field, []byte(v))
+}
+
+type protoReader struct {
+	buf      []byte
+	wireType uint64
+}
+
+// readProto calls 'action' for each field of the message. 'action' needs to
+// read (or skip) the value of the field.
+func readProto(raw []byte, action func(field uint64, r *protoReader) error) error {
+	r := &protoReader{buf: raw}
+	for len(r.buf) > 0 {
+		key, e := r.varint()
+		if e != nil {
+			return e
+		}
+		if key>>3 == 0 {
+			return errors.New("invalid field number '0'")
+		}
+		r.wireType = key & 7
+		if e := action(key>>3, r); e != nil {
+			return fmt.Errorf("field '%d': %v", key>>3, e)
+		}
+	}
+	return nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errors.New("invalid varint")
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) uint() (uint64, error) {
+	if r.wireType != protoVarint {
+		return 0, fmt.Errorf("invalid wire type '%d', expected varint", r.wireType)
+	}
+	return r.varint()
+}
+
+// uints reads a repeated varint field in either packed or unpacked encoding.
+func (r *protoReader) uints(action func(v uint64)) error {
+	switch r.wireType {
+	case protoVarint:
+		v, e := r.varint()
+		if e == nil {
+			action(v)
+		}
+		return e
+	case protoBytes:
+		packed, e := r.bytes()
+		if e != nil {
+			return e
+		}
+		pr := &protoReader{buf: packed}
+		for len(pr.buf) > 0 {
+			v, e := pr.varint()
+			if e != nil {
+				return e
+			}
+			action(v)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid wire type '%d', expected varint", r.wireType)
+	}
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	if r.wireType != protoBytes {
+		return nil, fmt.Errorf("invalid wire type '%d', expected bytes", r.wireType)
+	}
+	n, e := r.varint()
+	if e != nil {
+		return nil, e
+	}
+	if n > uint64(len(r.buf)) {
+		return nil, errors.New("length exceeds message")
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) fixedBytes(out []byte) error {
+	v, e := r.bytes()
+	if e != nil {
+		return e
+	}
+	if len(v) != len(out) {
+		return fmt.Errorf("invalid length '%d', expected '%d'", len(v), len(out))
+	}
+	copy(out, v)
+	return nil
+}
+
+func (r *protoReader) address(out *cipher.Address) error {
+	v, e := r.bytes()
+	if e != nil {
+		return e
+	}
+	if len(v) == 0 {
+		*out = cipher.Address{}
+		return nil
+	}
+	*out, e = cipher.DecodeBase58Address(string(v))
+	return e
+}
+
+// skip skips the value of a field that is not known.
+func (r *protoReader) skip() error {
+	var n uint64
+	switch r.wireType {
+	case protoVarint:
+		_, e := r.varint()
+		return e
+	case protoFixed64:
+		n = 8
+	case protoFixed32:
+		n = 4
+	case protoBytes:
+		_, e := r.bytes()
+		return e
+	default:
+		return fmt.Errorf("unsupported wire type '%d'", r.wireType)
+	}
+	if n > uint64(len(r.buf)) {
+		return errors.New("length exceeds message")
+	}
+	r.buf = r.buf[n:]
+	return nil
+}
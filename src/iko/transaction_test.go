@@ -1,10 +1,15 @@
 package iko
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
+	"time"
 )
 
 func runTransactionVerifyTest(t *testing.T, stateDB StateDB) {
@@ -25,7 +30,7 @@ func runTransactionVerifyTest(t *testing.T, stateDB StateDB) {
 		txHash := TxHash(cipher.SumSHA256([]byte{3, 4, 5, 6}))
 		kID := KittyID(3)
 
-		err := stateDB.AddKitty(txHash, kID, anAddress)
+		err := stateDB.AddKitty(TxRef{Hash: txHash}, kID, anAddress)
 
 		// If there's an error creating kitty, then deviate testing transaction -- no kitty means no transaction
 		if err == nil {
@@ -52,7 +57,7 @@ func runTransactionVerifyTest(t *testing.T, stateDB StateDB) {
 	})
 	toAddress := cipher.AddressFromSecKey(sk2)
 	prev := NewGenTx(nil, kID, sk)
-	nextTrans := NewTransferTx(prev, kID, toAddress, sk)
+	nextTrans := NewTransferTx(prev, kID, toAddress, 1, sk)
 
 	t.Run("TransactionCreated_InvalidDataMembers", func(t *testing.T) {
 		// Change transaction previous hash to test if verify return error
@@ -114,7 +119,7 @@ func runTransactionIsKittyGen(t *testing.T, stateDB StateDB) {
 	txHash := TxHash(cipher.SumSHA256([]byte{3, 7, 5, 6}))
 	kID := KittyID(4)
 
-	stateDB.AddKitty(txHash, kID, cAddress)
+	stateDB.AddKitty(TxRef{Hash: txHash}, kID, cAddress)
 
 	prev := NewGenTx(nil, kID, sk)
 
@@ -135,6 +140,116 @@ func runTransactionIsKittyGen(t *testing.T, stateDB StateDB) {
 	})
 }
 
+func TestTransaction_VerifyMulti(t *testing.T) {
+	sk := testSecKey
+	toAddress := cipher.AddressFromSecKey(testSecKey2)
+	prev := NewGenTx(nil, KittyID(1), sk)
+
+	t.Run("Kitties", func(t *testing.T) {
+		tx := NewMultiTransferTx(prev, KittyIDs{1, 2, 3}, toAddress, 0, sk)
+		require.Equal(t, TxVersionExtended, tx.Version, "Multi transfers should use the extended version")
+		require.Equal(t, TxFeatureKitties, tx.Features(), "Multi transfers should carry extra kitties")
+		require.Equal(t, KittyIDs{1, 2, 3}, tx.Kitties(), "Every kitty should be transferred")
+		require.Equal(t, KittyIDs{3}, NewTransferTx(prev, KittyID(3), toAddress, 1, sk).Kitties(),
+			"Single transfers should transfer one kitty")
+	})
+
+	t.Run("Verify_Success", func(t *testing.T) {
+		tx := NewMultiTransferTx(prev, KittyIDs{1, 2, 3}, toAddress, 1, sk)
+		require.Nil(t, tx.Verify(prev), "Valid multi transfers should verify")
+	})
+
+	t.Run("Verify_Duplicate", func(t *testing.T) {
+		tx := NewMultiTransferTx(prev, KittyIDs{1, 2, 1}, toAddress, 1, sk)
+		require.NotNil(t, tx.Verify(prev), "Transferring a kitty twice should fail")
+	})
+
+	t.Run("Verify_TooMany", func(t *testing.T) {
+		kitties := make(KittyIDs, TxMaxKitties+1)
+		for i := range kitties {
+			kitties[i] = KittyID(i)
+		}
+		tx := NewMultiTransferTx(prev, kitties, toAddress, 1, sk)
+		require.NotNil(t, tx.Verify(prev), "Transferring too many kitties should fail")
+	})
+
+	t.Run("Verify_InvalidVersion", func(t *testing.T) {
+		tx := NewTransferTx(prev, KittyID(1), toAddress, 0, sk)
+		tx.Extra = KittyIDs{2}
+		tx.Sig = tx.Sign(sk)
+		require.NotNil(t, tx.Verify(prev), "Legacy transfers should not have extra kitties")
+
+		tx.Version = 200
+		tx.Sig = tx.Sign(sk)
+		require.NotNil(t, tx.Verify(prev), "Unknown versions should fail")
+	})
+}
+
+func TestTransaction_Memo(t *testing.T) {
+	sk := testSecKey
+	prev := NewGenTx(nil, KittyID(1), sk)
+	tx := NewTransferTx(prev, KittyID(1), cipher.AddressFromSecKey(sk), 1, sk)
+
+	tx.SetMemo("happy birthday!", sk)
+	require.Nil(t, tx.Verify(prev), "Signed memos should verify")
+
+	tx.Memo = "happy birthday?"
+	require.NotNil(t, tx.Verify(prev), "The signature should cover the memo")
+
+	tx.SetMemo(strings.Repeat("a", TxMaxMemoSize+1), sk)
+	require.NotNil(t, tx.Verify(prev), "Memos larger than the limit should fail")
+
+	tx.SetMemo(string([]byte{0xff, 0xfe}), sk)
+	require.NotNil(t, tx.Verify(prev), "Memos that are not valid utf-8 should fail")
+}
+
+func TestTxLimits(t *testing.T) {
+	sk := testSecKey
+	prev := NewGenTx(nil, KittyID(1), sk)
+	tx := NewMultiTransferTx(prev, KittyIDs{1, 2, 3}, cipher.AddressFromSecKey(sk), 1, sk)
+	now := time.Now().UnixNano()
+
+	limits := TxLimits{MaxMemoSize: 4, MaxKitties: 2, MaxClockSkew: time.Second}
+	require.Nil(t, limits.Prepare(), "Limits within the protocol should prepare")
+	require.Nil(t, DefaultTxLimits.Check(tx, now), "Tx within the default limits should pass")
+
+	var limitErr *TxLimitError
+	require.True(t, errors.As(limits.Check(tx, now), &limitErr), "Tx with too many kitties should fail")
+	require.Equal(t, TxLimitKitties, limitErr.Limit, "Kitty limit should be reported")
+
+	tx.Extra = KittyIDs{2}
+	tx.Memo = "hello"
+	require.True(t, errors.As(limits.Check(tx, now), &limitErr), "Tx with a large memo should fail")
+	require.Equal(t, TxLimitMemoSize, limitErr.Limit, "Memo limit should be reported")
+
+	tx.Memo = ""
+	require.True(t, errors.As(limits.Check(tx, tx.TS-int64(time.Minute)), &limitErr),
+		"Tx with a timestamp far ahead should fail")
+	require.Equal(t, TxLimitClockSkew, limitErr.Limit, "Clock skew limit should be reported")
+
+	limits = TxLimits{MaxMemoSize: TxMaxMemoSize + 1}
+	require.NotNil(t, limits.Prepare(), "Limits above the protocol should fail")
+	limits = TxLimits{}
+	require.Nil(t, limits.Prepare(), "Zero limits should prepare")
+	require.Equal(t, DefaultTxLimits, limits, "Zero limits should be the defaults")
+}
+
+func TestTransaction_Expiry(t *testing.T) {
+	sk := testSecKey
+	prev := NewGenTx(nil, KittyID(1), sk)
+	tx := NewTransferTx(prev, KittyID(1), cipher.AddressFromSecKey(sk), 1, sk)
+
+	require.Nil(t, tx.CheckExpiry(tx.TS+int64(time.Hour)), "Txs without expiry should never expire")
+
+	tx.SetExpiry(tx.TS+10, sk)
+	require.Nil(t, tx.Verify(prev), "Txs created before their expiry should verify")
+	require.Nil(t, tx.CheckExpiry(tx.TS+10), "Txs should be valid up to their expiry")
+	require.IsType(t, &TxExpiredError{}, tx.CheckExpiry(tx.TS+11), "Txs should expire after their expiry")
+
+	tx.SetExpiry(tx.TS-1, sk)
+	require.IsType(t, &TxExpiredError{}, tx.Verify(prev), "Txs created after their expiry should fail")
+}
+
 func TestTransaction_Verify(t *testing.T) {
 	stateDB := NewMemoryState()
 	runTransactionVerifyTest(t, stateDB)
@@ -144,3 +259,371 @@ func TestTransaction_IsKittyGen(t *testing.T) {
 	stateDB := NewMemoryState()
 	runTransactionIsKittyGen(t, stateDB)
 }
+
+func TestTransaction_Multisig(t *testing.T) {
+	sks := []cipher.SecKey{
+		testSecKey,
+		testSecKey2,
+		cipher.SecKey([32]byte{
+			3, 4, 5, 6,
+			3, 4, 5, 6,
+			3, 4, 5, 6,
+			3, 4, 5, 6,
+			5, 4, 3, 6,
+			3, 4, 5, 6,
+			4, 4, 5, 6,
+			3, 4, 5, 6,
+		}),
+	}
+	pks := make([]cipher.PubKey, len(sks))
+	for i, sk := range sks {
+		pks[i] = cipher.PubKeyFromSecKey(sk)
+	}
+
+	t.Run("NewMultisigAccount", func(t *testing.T) {
+		_, err := NewMultisigAccount(0, pks)
+		require.NotNil(t, err, "A threshold of zero should fail")
+		_, err = NewMultisigAccount(4, pks)
+		require.NotNil(t, err, "A threshold above the number of keys should fail")
+		_, err = NewMultisigAccount(1, []cipher.PubKey{pks[0], pks[0]})
+		require.NotNil(t, err, "Duplicate keys should fail")
+		_, err = NewMultisigAccount(2, pks)
+		require.Nil(t, err, "A 2-of-3 account should succeed")
+	})
+
+	account, err := NewMultisigAccount(2, pks)
+	require.Nil(t, err, "A 2-of-3 account should succeed")
+	require.NotEqual(t, cipher.AddressFromSecKey(sks[0]), account.Address(),
+		"Multisig addresses should differ from those of single keys")
+
+	prev := NewGenTx(nil, KittyID(1), sks[0])
+	to := cipher.AddressFromSecKey(sks[1])
+
+	t.Run("Verify_BelowThreshold", func(t *testing.T) {
+		tx := NewMultisigTransferTx(prev, KittyIDs{1}, account, to)
+		tx.MultiSign(sks[0])
+		require.NotNil(t, tx.Verify(prev), "One signature for a 2-of-3 account should fail")
+
+		tx.MultiSign(sks[0])
+		require.NotNil(t, tx.Verify(prev), "Signing twice with the same key should fail")
+	})
+
+	t.Run("Verify_Outsider", func(t *testing.T) {
+		other, err := NewMultisigAccount(1, pks[1:])
+		require.Nil(t, err, "A 1-of-2 account should succeed")
+
+		tx := NewMultisigTransferTx(prev, KittyIDs{1}, other, to)
+		tx.MultiSign(sks[0])
+		require.NotNil(t, tx.Verify(prev), "Signatures of keys outside the account should fail")
+	})
+
+	t.Run("Verify_Success", func(t *testing.T) {
+		tx := NewMultisigTransferTx(prev, KittyIDs{1, 2}, account, to)
+		tx.Memo = "shared custody"
+		tx.MultiSign(sks[2])
+		tx.MultiSign(sks[0])
+		require.Nil(t, tx.Verify(prev), "Two signatures for a 2-of-3 account should verify")
+
+		tx.From = cipher.AddressFromSecKey(sks[0])
+		require.NotNil(t, tx.Verify(prev), "The from address should be the multisig account")
+	})
+}
+
+func TestTransaction_OfflineSigning(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	from := cipher.AddressFromSecKey(sk)
+	to := cipher.AddressFromSecKey(sk2)
+	prev := NewGenTx(nil, KittyID(1), sk)
+
+	tx := NewUnsignedTransfer(prev, KittyIDs{1}, from, to, 1)
+	require.Equal(t, TxVersionExtended, tx.Version, "Transfers with a nonce should use the extended version")
+	require.NotNil(t, tx.Verify(prev), "Unsigned transactions should not verify")
+
+	// Sign offline, from the payload only.
+	offline, err := DecodeTx(tx.SignaturePayload())
+	require.Nil(t, err, "Payload should be a transaction")
+	require.Equal(t, tx.SignatureHash(), cipher.SumSHA256(tx.SignaturePayload()), "Signature hash should be of the payload")
+	sig := cipher.SignHash(offline.SignatureHash(), sk)
+
+	require.NotNil(t, tx.AttachSignature(cipher.SignHash(tx.SignatureHash(), sk2)),
+		"Signatures by other keys should be rejected")
+	require.Nil(t, tx.AttachSignature(sig), "The signature of the sender should be attached")
+	require.Nil(t, tx.Verify(prev), "The completed transaction should verify")
+	require.Equal(t, tx.SignatureHash(), offline.SignatureHash(), "Attaching signatures should not change the signature hash")
+}
+
+func TestTransaction_Explain(t *testing.T) {
+	sk := testSecKey
+	to := cipher.AddressFromSecKey(testSecKey2)
+	genTx := NewGenTx(nil, KittyID(1), sk)
+	require.Equal(t, TxTypeGen, genTx.Explain().Type, "Gen txs should be explained as gen")
+
+	tx := NewMultiTransferTx(genTx, KittyIDs{1, 2}, to, 1, sk)
+	x := tx.Explain()
+	require.Equal(t, TxTypeTransfer, x.Type, "Transfers should be explained as transfers")
+	require.Equal(t, KittyIDs{1, 2}, x.KittyIDs, "Every kitty should be explained")
+	require.Equal(t, tx.Hash().Hex(), x.Hash, "Hash should be explained")
+	require.Equal(t, genTx.Hash().Hex(), x.Prev, "Prev hash should be explained")
+	require.Equal(t, to.String(), x.To, "To address should be explained")
+	require.True(t, x.SigValid, "Signature should be valid")
+	require.Contains(t, x.String(), "sig:            valid", "Rendering should include the signature")
+
+	tx.Memo = "tampered"
+	x = tx.Explain()
+	require.False(t, x.SigValid, "Signature on a tampered tx should be invalid")
+	require.NotEmpty(t, x.SigError, "Signature error should be explained")
+
+	require.Equal(t, TxTypeBurn, NewBurnTx(genTx, KittyIDs{1}, 1, sk).Explain().Type,
+		"Burns should be explained as burns")
+}
+
+func TestDecodeTx(t *testing.T) {
+	sk := testSecKey
+	toAddress := cipher.AddressFromSecKey(testSecKey2)
+	prev := NewGenTx(nil, KittyID(1), sk)
+
+	t.Run("Versions", func(t *testing.T) {
+		for _, tx := range []*Transaction{
+			NewTransferTx(prev, KittyID(1), toAddress, 0, sk),
+			NewTransferTx(prev, KittyID(1), toAddress, 1, sk),
+			NewMultiTransferTx(prev, KittyIDs{1, 2, 3}, toAddress, 1, sk),
+		} {
+			raw := tx.Serialize()
+			if tx.Version == TxVersionLegacy {
+				require.Len(t, raw, txLegacySize, "Legacy txs should have the legacy size")
+			} else {
+				require.Equal(t, tx.Version, raw[0], "First byte should be the version")
+			}
+
+			decoded, err := DecodeTx(raw)
+			require.Nil(t, err, "Decoding should succeed")
+			require.Equal(t, tx.Hash(), decoded.Hash(), "Decoded tx should have the same hash")
+			require.Equal(t, tx.Kitties(), decoded.Kitties(), "Decoded tx should have the same kitties")
+			require.Nil(t, decoded.Verify(prev), "Decoded tx should verify")
+		}
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		raw := NewMultiTransferTx(prev, KittyIDs{1, 2}, toAddress, 1, sk).Serialize()
+		raw[0] = 200
+		_, err := DecodeTx(raw)
+		require.NotNil(t, err, "Decoding unknown versions should fail")
+
+		tx := NewTransferTx(prev, KittyID(1), toAddress, 0, sk)
+		tx.Version = 200
+		require.Nil(t, tx.Serialize(), "Encoding unknown versions should fail")
+		require.NotNil(t, tx.Verify(prev), "Unknown versions should fail")
+
+		tx.Version = TxVersionLegacy
+		tx.Memo = "memo"
+		require.Nil(t, tx.Serialize(), "Encoding legacy txs with optional fields should fail")
+		require.NotNil(t, tx.Verify(prev), "Legacy txs with optional fields should fail")
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		// Serialized in the transaction format from before versions: the gen
+		// tx of kitty 1, and its transfer to 'toAddress'.
+		const (
+			genHex = "000000000000000000000000000000000000000000000000000000000000000000000000000000000000167b0d12d11401" +
+				"0000000000000000d9d858fb0dc87358aded6acc0ad23383b0be61e200d9d858fb0dc87358aded6acc0ad23383b0be61e2" +
+				"f2581cd69faf29394eca358027821d8df5cff8ef0f4ef495876c068efdf3a53239ef22e5ef259d80d9727d5e4cbcfe1477" +
+				"451afeb2455ee0380f8f96bed0539f01"
+			genHash     = "45604d90a3c5de77750233a258c929b964dabb8d355fc32da86394ccaf03492e"
+			transferHex = "45604d90a3c5de77750233a258c929b964dabb8d355fc32da86394ccaf03492e010000000000000000cab0b60d12d11401" +
+				"0000000000000000d9d858fb0dc87358aded6acc0ad23383b0be61e2005481e561991bdd1fee034abab061c8cbb0459b11" +
+				"3afae2579d2bf54aed63d6160235f2ab2d2fbaab9ab6ac2ea0cba987c06a3fc86ac79f8e47c0be2a994c8739bc4a83cb56" +
+				"ddd67aa79d4032eea32a98a64a4c9000"
+			transferHash = "44f455fb4b3c8809098c623e45d2de26eb50bcdbc2e5e6e46c202f7888ea1c33"
+		)
+		genRaw, err := hex.DecodeString(genHex)
+		require.Nil(t, err)
+		transferRaw, err := hex.DecodeString(transferHex)
+		require.Nil(t, err)
+
+		gen, err := DecodeTx(genRaw)
+		require.Nil(t, err, "Decoding legacy txs should succeed")
+		require.Equal(t, TxVersionLegacy, gen.Version)
+		require.Equal(t, genHash, gen.Hash().Hex(), "Legacy txs should keep their hash")
+		require.Equal(t, genRaw, gen.Serialize(), "Legacy txs should encode as before")
+		require.Nil(t, gen.Verify(nil), "Legacy txs should verify")
+
+		transfer, err := DecodeTx(transferRaw)
+		require.Nil(t, err, "Decoding legacy txs should succeed")
+		require.Equal(t, transferHash, transfer.Hash().Hex(), "Legacy txs should keep their hash")
+		require.Equal(t, KittyIDs{1}, transfer.Kitties())
+		require.Equal(t, toAddress, transfer.To)
+		require.Nil(t, transfer.verifyContent(), "Legacy transfers should be valid")
+		require.Nil(t, transfer.verifyLink(gen), "Legacy transfers should link to the legacy gen tx")
+		require.Nil(t, transfer.verifySig(), "Legacy transfers should be signed by the sender")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := DecodeTx(nil)
+		require.NotNil(t, err, "Decoding nothing should fail")
+
+		raw := NewMultiTransferTx(prev, KittyIDs{1, 2}, toAddress, 1, sk).Serialize()
+		_, err = DecodeTx(raw[:len(raw)-1])
+		require.NotNil(t, err, "Decoding a truncated tx should fail")
+
+		raw[0] = TxVersionLegacy
+		_, err = DecodeTx(raw)
+		require.NotNil(t, err, "Decoding with the format of another version should fail")
+	})
+
+	t.Run("Features", func(t *testing.T) {
+		tx := NewMultiTransferTx(prev, KittyIDs{1, 2}, toAddress, 0, sk)
+		tx.SetMemo("order 7", sk)
+		tx.SetFee(5, sk)
+		require.Equal(t, TxFeatureKitties|TxFeatureFee|TxFeatureMemo, tx.Features())
+		require.Equal(t, "kitties,fee,memo", tx.Features().String())
+
+		decoded, err := DecodeTx(tx.Serialize())
+		require.Nil(t, err, "Decoding should succeed")
+		require.Equal(t, *tx, *decoded, "Every optional field should be decoded")
+
+		// The features are the four bytes that precede the optional fields.
+		featuresAt := len(NewMultiTransferTx(prev, KittyIDs{1}, toAddress, 0, sk).Serialize()) - 4
+
+		raw := tx.Serialize()
+		raw[featuresAt+3] = 0x80
+		_, err = DecodeTx(raw)
+		require.NotNil(t, err, "Decoding unknown features should fail")
+
+		_, err = DecodeTx(append(tx.Serialize(), 0))
+		require.NotNil(t, err, "Decoding trailing bytes should fail")
+
+		empty := NewMultiTransferTx(prev, KittyIDs{1}, toAddress, 0, sk)
+		raw = append(empty.Serialize(), make([]byte, 8)...)
+		raw[featuresAt] = byte(TxFeatureNonce)
+		_, err = DecodeTx(raw)
+		require.NotNil(t, err, "Decoding features with empty fields should fail")
+	})
+}
+
+func TestTransaction_Proto(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	toAddress := cipher.AddressFromSecKey(sk2)
+	prev := NewGenTx(nil, KittyID(1), sk)
+
+	account, err := NewMultisigAccount(2, []cipher.PubKey{
+		cipher.PubKeyFromSecKey(sk),
+		cipher.PubKeyFromSecKey(sk2),
+	})
+	require.Nil(t, err, "A 2-of-2 account should succeed")
+	multisigTx := NewMultisigTransferTx(prev, KittyIDs{1, 2}, account, toAddress)
+	multisigTx.MultiSign(sk)
+	multisigTx.MultiSign(sk2)
+
+	memoTx := NewTransferTx(prev, KittyID(1), toAddress, 3, sk)
+	memoTx.SetMemo("order-1", sk)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, tx := range []*Transaction{
+			prev,
+			memoTx,
+			NewMultiTransferTx(prev, KittyIDs{1, 300, 70000}, toAddress, 1, sk),
+			multisigTx,
+		} {
+			decoded, err := UnmarshalTxProto(tx.MarshalProto())
+			require.Nil(t, err, "Decoding should succeed")
+			require.Equal(t, tx.Hash(), decoded.Hash(), "Decoded tx should have the same hash")
+		}
+		decoded, err := UnmarshalTxProto(multisigTx.MarshalProto())
+		require.Nil(t, err, "Decoding should succeed")
+		require.Nil(t, decoded.Verify(prev), "Decoded multisig tx should verify")
+	})
+
+	t.Run("UnknownFields", func(t *testing.T) {
+		var w protoWriter
+		w.uint(100, 7)
+		w.bytes(101, []byte("ignored"))
+		raw := append(memoTx.MarshalProto(), w.buf...)
+
+		decoded, err := UnmarshalTxProto(raw)
+		require.Nil(t, err, "Unknown fields should be skipped")
+		require.Equal(t, memoTx.Hash(), decoded.Hash(), "Decoded tx should have the same hash")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		raw := memoTx.MarshalProto()
+		_, err := UnmarshalTxProto(raw[:len(raw)-1])
+		require.NotNil(t, err, "Decoding a truncated tx should fail")
+
+		var w protoWriter
+		w.bytes(2, []byte{1, 2, 3})
+		_, err = UnmarshalTxProto(w.buf)
+		require.NotNil(t, err, "Decoding a prev hash with invalid length should fail")
+
+		w = protoWriter{}
+		w.string(8, "invalid")
+		_, err = UnmarshalTxProto(w.buf)
+		require.NotNil(t, err, "Decoding an invalid address should fail")
+	})
+}
+
+func TestTransaction_JSON(t *testing.T) {
+	sk := testSecKey
+	sk2 := testSecKey2
+	toAddress := cipher.AddressFromSecKey(sk2)
+	prev := NewGenTx(nil, KittyID(1), sk)
+
+	account, err := NewMultisigAccount(2, []cipher.PubKey{
+		cipher.PubKeyFromSecKey(sk),
+		cipher.PubKeyFromSecKey(sk2),
+	})
+	require.Nil(t, err, "A 2-of-2 account should succeed")
+	multisigTx := NewMultisigTransferTx(prev, KittyIDs{1, 2}, account, toAddress)
+	multisigTx.MultiSign(sk)
+	multisigTx.MultiSign(sk2)
+
+	memoTx := NewTransferTx(prev, KittyID(1), toAddress, 3, sk)
+	memoTx.SetMemo("<order & 1>", sk)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, tx := range []*Transaction{
+			prev,
+			memoTx,
+			multisigTx,
+			NewBreedTx(prev, KittyID(3), KittyIDs{1, 2}, MintMeta{URI: "ipfs://kitty"}, sk),
+			NewBurnTx(prev, KittyIDs{1, 2}, 1, sk),
+		} {
+			raw, err := json.Marshal(tx)
+			require.Nil(t, err, "Encoding should succeed")
+
+			var decoded Transaction
+			require.Nil(t, json.Unmarshal(raw, &decoded), "Decoding should succeed")
+			require.Equal(t, tx.Hash(), decoded.Hash(), "Decoded tx should have the same hash")
+
+			again, err := json.Marshal(decoded)
+			require.Nil(t, err, "Encoding should succeed")
+			require.Equal(t, string(raw), string(again), "Encoding should be byte-identical")
+		}
+	})
+
+	t.Run("Canonical", func(t *testing.T) {
+		raw, err := memoTx.MarshalJSON()
+		require.Nil(t, err, "Encoding should succeed")
+		require.True(t, strings.HasPrefix(string(raw), `{"version":1,"prev":"`), "Fields should be in order")
+		require.Contains(t, string(raw), `"memo":"<order & 1>"`, "Memo should not be HTML escaped")
+		require.Contains(t, string(raw), fmt.Sprintf(`"ts":"%d"`, memoTx.TS), "64 bit integers should be strings")
+		require.Contains(t, string(raw), `"sig":"`+memoTx.Sig.Hex()+`"`, "Signatures should be lowercase hex")
+		require.NotContains(t, string(raw), "\n", "Encoding should not have whitespace")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		raw, err := memoTx.MarshalJSON()
+		require.Nil(t, err, "Encoding should succeed")
+
+		var decoded Transaction
+		unknown := strings.Replace(string(raw), `{"version":1,`, `{"version":1,"extra_field":1,`, 1)
+		require.NotNil(t, json.Unmarshal([]byte(unknown), &decoded), "Unknown fields should fail")
+
+		legacy := strings.Replace(string(raw), `{"version":1,`, `{"version":0,`, 1)
+		require.NotNil(t, json.Unmarshal([]byte(legacy), &decoded), "Legacy txs with optional fields should fail")
+
+		short := strings.Replace(string(raw), memoTx.Sig.Hex(), memoTx.Sig.Hex()[2:], 1)
+		require.NotNil(t, json.Unmarshal([]byte(short), &decoded), "Signatures with invalid length should fail")
+	})
+}
@@ -0,0 +1,82 @@
+package iko
+
+import (
+	"reflect"
+	"sort"
+)
+
+// verifyPageSize is the number of addresses read from state at a time
+// when searching for kitties that should not exist.
+const verifyPageSize = 100
+
+// StateMismatch represents a kitty whose live state diverges
+// from the state obtained by replaying the chain.
+type StateMismatch struct {
+	KittyID  KittyID
+	Expected *KittyState // Nil if the kitty should not exist.
+	Actual   *KittyState // Nil if the kitty does not exist in the live state.
+}
+
+// VerifyState replays the whole chain into a scratch state, and compares it
+// against the live state. Every divergent kitty is reported in ascending
+// order of kitty ID. An error is returned if the chain itself fails to replay.
+func (bc *BlockChain) VerifyState() ([]StateMismatch, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	scratch := NewMemoryState()
+	if e := bc.replayTxs(scratch, nil, nil); e != nil {
+		return nil, e
+	}
+
+	var out []StateMismatch
+
+	// Check kitties that should exist.
+	ids := make(KittyIDs, 0, len(scratch.kitties))
+	for kittyID := range scratch.kitties {
+		ids = append(ids, kittyID)
+	}
+	ids.Sort()
+	for _, kittyID := range ids {
+		expected := scratch.kitties[kittyID]
+		actual, _ := bc.state.GetKittyState(kittyID)
+		if actual == nil || !reflect.DeepEqual(*expected, *actual) {
+			out = append(out, StateMismatch{
+				KittyID:  kittyID,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+
+	// Check kitties that should not exist.
+	var extra KittyIDs
+	for page := uint64(0); ; page++ {
+		addresses, e := bc.state.Addresses(page, verifyPageSize)
+		if e != nil {
+			return nil, e
+		}
+		if len(addresses) == 0 {
+			break
+		}
+		for _, address := range addresses {
+			for _, kittyID := range bc.state.GetAddressState(address).Kitties {
+				if _, ok := scratch.kitties[kittyID]; !ok {
+					extra = append(extra, kittyID)
+				}
+			}
+		}
+	}
+	for _, kittyID := range extra {
+		actual, _ := bc.state.GetKittyState(kittyID)
+		out = append(out, StateMismatch{
+			KittyID: kittyID,
+			Actual:  actual,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].KittyID < out[j].KittyID
+	})
+	return out, nil
+}
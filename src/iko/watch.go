@@ -0,0 +1,126 @@
+package iko
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+	"sync"
+)
+
+// WatchEvent notifies that a watched address gained or lost a kitty.
+type WatchEvent struct {
+	Address cipher.Address
+	KittyID KittyID
+	Gained  bool // True if the address gained the kitty, false if lost.
+	Tx      TxRef
+}
+
+// AddressWatch emits events whenever a watched address gains or loses a kitty.
+// It is fed from the tx hub of the blockchain.
+type AddressWatch struct {
+	mux       sync.RWMutex
+	addresses map[cipher.Address]struct{}
+	sub       *TxSubscription
+	c         chan WatchEvent
+	done      chan struct{} // Closed by 'Close', to stop a blocked send.
+	closeOnce sync.Once
+}
+
+// WatchAddresses creates a watch on the specified addresses.
+// Events are buffered up to 'bufferSize' before the watch blocks.
+func (bc *BlockChain) WatchAddresses(addresses []cipher.Address, bufferSize int) *AddressWatch {
+	w := &AddressWatch{
+		addresses: make(map[cipher.Address]struct{}, len(addresses)),
+		sub:       bc.hub.Subscribe(bufferSize),
+		c:         make(chan WatchEvent, bufferSize),
+		done:      make(chan struct{}),
+	}
+	w.Add(addresses...)
+	go w.run(bc.c.CreatorPK)
+	return w
+}
+
+func (w *AddressWatch) run(creator cipher.PubKey) {
+	defer close(w.c)
+
+	for tx := range w.sub.C() {
+		ref := tx.Ref()
+		for _, kittyID := range tx.Kitties() {
+			if !tx.IsKittyGen(creator) && w.Watched(tx.From) {
+				if !w.send(WatchEvent{
+					Address: tx.From,
+					KittyID: kittyID,
+					Gained:  false,
+					Tx:      ref,
+				}) {
+					return
+				}
+			}
+			if w.Watched(tx.To) {
+				if !w.send(WatchEvent{
+					Address: tx.To,
+					KittyID: kittyID,
+					Gained:  true,
+					Tx:      ref,
+				}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// send sends an event, and returns false if the watch is closed first.
+func (w *AddressWatch) send(event WatchEvent) bool {
+	select {
+	case w.c <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// C obtains the channel where events are received.
+// The channel is closed when the watch is closed.
+func (w *AddressWatch) C() <-chan WatchEvent {
+	return w.c
+}
+
+// Add adds addresses to the watch list.
+func (w *AddressWatch) Add(addresses ...cipher.Address) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for _, address := range addresses {
+		w.addresses[address] = struct{}{}
+	}
+}
+
+// Remove removes addresses from the watch list.
+func (w *AddressWatch) Remove(addresses ...cipher.Address) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for _, address := range addresses {
+		delete(w.addresses, address)
+	}
+}
+
+// Watched returns true if the address is in the watch list.
+func (w *AddressWatch) Watched(address cipher.Address) bool {
+	w.mux.RLock()
+	defer w.mux.RUnlock()
+
+	_, ok := w.addresses[address]
+	return ok
+}
+
+// Close stops the watch, even if events are no longer received.
+func (w *AddressWatch) Close() {
+	w.closeOnce.Do(func() { close(w.done) })
+	w.sub.Close()
+}
+
+// OwnershipChanges obtains the changes of ownership by a transaction, as in
+// the history of kitties. Changes of created kitties have an empty 'From'.
+func (bc *BlockChain) OwnershipChanges(tx *Transaction) []OwnershipChange {
+	return bc.txChanges(tx)
+}
@@ -0,0 +1,380 @@
+package rpc
+
+import (
+	"github.com/kittycash/wallet/src/iko"
+)
+
+// Messages of 'chain.proto'. Each is encoded with 'Marshal', and decoded with
+// 'Unmarshal', where unknown fields are skipped.
+
+type TxHashRequest struct {
+	Hash iko.TxHash
+}
+
+func (m TxHashRequest) Marshal() []byte {
+	var w protoWriter
+	w.bytes(1, m.Hash[:])
+	return w.buf
+}
+
+func (m *TxHashRequest) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) error {
+		switch field {
+		case 1:
+			return r.fixedBytes(m.Hash[:])
+		default:
+			return r.skip()
+		}
+	})
+}
+
+type TxSeqRequest struct {
+	Seq uint64
+}
+
+func (m TxSeqRequest) Marshal() []byte {
+	var w protoWriter
+	w.uint(1, m.Seq)
+	return w.buf
+}
+
+func (m *TxSeqRequest) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			m.Seq, e = r.uint()
+			return e
+		default:
+			return r.skip()
+		}
+	})
+}
+
+type TxLookup struct {
+	Seq           uint64
+	Hash          iko.TxHash
+	Pending       bool
+	Confirmations uint64
+	Tx            *iko.Transaction
+}
+
+func (m TxLookup) Marshal() []byte {
+	var w protoWriter
+	w.uint(1, m.Seq)
+	w.bytes(2, m.Hash[:])
+	w.bool(3, m.Pending)
+	w.uint(4, m.Confirmations)
+	if m.Tx != nil {
+		w.message(5, m.Tx.MarshalProto())
+	}
+	return w.buf
+}
+
+func (m *TxLookup) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			m.Seq, e = r.uint()
+			return e
+		case 2:
+			return r.fixedBytes(m.Hash[:])
+		case 3:
+			m.Pending, e = r.bool()
+			return e
+		case 4:
+			m.Confirmations, e = r.uint()
+			return e
+		case 5:
+			v, e := r.bytes()
+			if e != nil {
+				return e
+			}
+			m.Tx, e = iko.UnmarshalTxProto(v)
+			return e
+		default:
+			return r.skip()
+		}
+	})
+}
+
+type TxRangeRequest struct {
+	StartSeq uint64
+	PageSize uint64
+	Desc     bool
+	FromHead bool
+}
+
+func (m TxRangeRequest) Marshal() []byte {
+	var w protoWriter
+	w.uint(1, m.StartSeq)
+	w.uint(2, m.PageSize)
+	w.bool(3, m.Desc)
+	w.bool(4, m.FromHead)
+	return w.buf
+}
+
+func (m *TxRangeRequest) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			m.StartSeq, e = r.uint()
+		case 2:
+			m.PageSize, e = r.uint()
+		case 3:
+			m.Desc, e = r.bool()
+		case 4:
+			m.FromHead, e = r.bool()
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type TxRange struct {
+	Txs     []iko.Transaction
+	HeadSeq uint64
+	NextSeq uint64
+	HasMore bool
+}
+
+func (m TxRange) Marshal() []byte {
+	var w protoWriter
+	for _, tx := range m.Txs {
+		w.message(1, tx.MarshalProto())
+	}
+	w.uint(2, m.HeadSeq)
+	w.uint(3, m.NextSeq)
+	w.bool(4, m.HasMore)
+	return w.buf
+}
+
+func (m *TxRange) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			v, e := r.bytes()
+			if e != nil {
+				return e
+			}
+			tx, e := iko.UnmarshalTxProto(v)
+			if e != nil {
+				return e
+			}
+			m.Txs = append(m.Txs, *tx)
+		case 2:
+			m.HeadSeq, e = r.uint()
+		case 3:
+			m.NextSeq, e = r.uint()
+		case 4:
+			m.HasMore, e = r.bool()
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type InjectTxReply struct {
+	Hash      iko.TxHash
+	Seq       uint64
+	Duplicate bool
+}
+
+func (m InjectTxReply) Marshal() []byte {
+	var w protoWriter
+	w.bytes(1, m.Hash[:])
+	w.uint(2, m.Seq)
+	w.bool(3, m.Duplicate)
+	return w.buf
+}
+
+func (m *InjectTxReply) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			e = r.fixedBytes(m.Hash[:])
+		case 2:
+			m.Seq, e = r.uint()
+		case 3:
+			m.Duplicate, e = r.bool()
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type KittyRequest struct {
+	KittyID iko.KittyID
+}
+
+func (m KittyRequest) Marshal() []byte {
+	var w protoWriter
+	w.uint(1, uint64(m.KittyID))
+	return w.buf
+}
+
+func (m *KittyRequest) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) error {
+		switch field {
+		case 1:
+			v, e := r.uint()
+			m.KittyID = iko.KittyID(v)
+			return e
+		default:
+			return r.skip()
+		}
+	})
+}
+
+type Kitty struct {
+	KittyID    iko.KittyID
+	Owner      string
+	LastTxHash iko.TxHash
+	LastTxSeq  uint64
+	LastTxTime int64
+	Burned     bool
+}
+
+func (m Kitty) Marshal() []byte {
+	var w protoWriter
+	w.uint(1, uint64(m.KittyID))
+	w.string(2, m.Owner)
+	w.bytes(3, m.LastTxHash[:])
+	w.uint(4, m.LastTxSeq)
+	w.uint(5, uint64(m.LastTxTime))
+	w.bool(6, m.Burned)
+	return w.buf
+}
+
+func (m *Kitty) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		var v uint64
+		switch field {
+		case 1:
+			v, e = r.uint()
+			m.KittyID = iko.KittyID(v)
+		case 2:
+			m.Owner, e = r.string()
+		case 3:
+			e = r.fixedBytes(m.LastTxHash[:])
+		case 4:
+			m.LastTxSeq, e = r.uint()
+		case 5:
+			v, e = r.uint()
+			m.LastTxTime = int64(v)
+		case 6:
+			m.Burned, e = r.bool()
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type AddressKittiesRequest struct {
+	Address string
+	Page    uint64
+	PerPage uint64
+}
+
+func (m AddressKittiesRequest) Marshal() []byte {
+	var w protoWriter
+	w.string(1, m.Address)
+	w.uint(2, m.Page)
+	w.uint(3, m.PerPage)
+	return w.buf
+}
+
+func (m *AddressKittiesRequest) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			m.Address, e = r.string()
+		case 2:
+			m.Page, e = r.uint()
+		case 3:
+			m.PerPage, e = r.uint()
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type AddressKitties struct {
+	Address        string
+	Count          uint64
+	TotalPageCount uint64
+	KittyIDs       iko.KittyIDs
+}
+
+func (m AddressKitties) Marshal() []byte {
+	var w protoWriter
+	w.string(1, m.Address)
+	w.uint(2, m.Count)
+	w.uint(3, m.TotalPageCount)
+	w.uints(4, kittyIDUints(m.KittyIDs))
+	return w.buf
+}
+
+func (m *AddressKitties) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			m.Address, e = r.string()
+		case 2:
+			m.Count, e = r.uint()
+		case 3:
+			m.TotalPageCount, e = r.uint()
+		case 4:
+			e = r.uints(func(v uint64) {
+				m.KittyIDs = append(m.KittyIDs, iko.KittyID(v))
+			})
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+type TxStreamFilter struct {
+	Addresses []string
+	Kitties   iko.KittyIDs
+}
+
+func (m TxStreamFilter) Marshal() []byte {
+	var w protoWriter
+	for _, addr := range m.Addresses {
+		w.message(1, []byte(addr))
+	}
+	w.uints(2, kittyIDUints(m.Kitties))
+	return w.buf
+}
+
+func (m *TxStreamFilter) Unmarshal(raw []byte) error {
+	return readProto(raw, func(field uint64, r *protoReader) (e error) {
+		switch field {
+		case 1:
+			var addr string
+			addr, e = r.string()
+			m.Addresses = append(m.Addresses, addr)
+		case 2:
+			e = r.uints(func(v uint64) {
+				m.Kitties = append(m.Kitties, iko.KittyID(v))
+			})
+		default:
+			e = r.skip()
+		}
+		return e
+	})
+}
+
+func kittyIDUints(kittyIDs iko.KittyIDs) []uint64 {
+	out := make([]uint64, len(kittyIDs))
+	for i, kittyID := range kittyIDs {
+		out[i] = uint64(kittyID)
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The protobuf messages of 'chain.proto' are implemented by hand, as are the
+// transactions of 'iko' (see 'iko.Transaction.MarshalProto').
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *protoWriter) tag(field uint64, wireType uint64) {
+	w.varint(field<<3 | wireType)
+}
+
+// uint writes a varint field, omitting the default value.
+func (w *protoWriter) uint(field uint64, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, protoVarint)
+	w.varint(v)
+}
+
+func (w *protoWriter) bool(field uint64, v bool) {
+	if v {
+		w.uint(field, 1)
+	}
+}
+
+// bytes writes a length-delimited field, omitting the default value.
+func (w *protoWriter) bytes(field uint64, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.message(field, v)
+}
+
+// message writes an embedded message, which is written even if empty.
+func (w *protoWriter) message(field uint64, v []byte) {
+	w.tag(field, protoBytes)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *protoWriter) string(field uint64, v string) {
+	w.bytes(field, []byte(v))
+}
+
+// uints writes a packed repeated varint field.
+func (w *protoWriter) uints(field uint64, vs []uint64) {
+	if len(vs) == 0 {
+		return
+	}
+	var packed protoWriter
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	w.bytes(field, packed.buf)
+}
+
+type protoReader struct {
+	buf      []byte
+	wireType uint64
+}
+
+// readProto calls 'action' for each field of the message. 'action' needs to
+// read (or skip) the value of the field.
+func readProto(raw []byte, action func(field uint64, r *protoReader) error) error {
+	r := &protoReader{buf: raw}
+	for len(r.buf) > 0 {
+		key, e := r.varint()
+		if e != nil {
+			return e
+		}
+		if key>>3 == 0 {
+			return errors.New("invalid field number '0'")
+		}
+		r.wireType = key & 7
+		if e := action(key>>3, r); e != nil {
+			return fmt.Errorf("field '%d': %v", key>>3, e)
+		}
+	}
+	return nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errors.New("invalid varint")
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) uint() (uint64, error) {
+	if r.wireType != protoVarint {
+		return 0, fmt.Errorf("invalid wire type '%d', expected varint", r.wireType)
+	}
+	return r.varint()
+}
+
+func (r *protoReader) bool() (bool, error) {
+	v, e := r.uint()
+	return v != 0, e
+}
+
+// uints reads a repeated varint field in either packed or unpacked encoding.
+func (r *protoReader) uints(action func(v uint64)) error {
+	switch r.wireType {
+	case protoVarint:
+		v, e := r.varint()
+		if e == nil {
+			action(v)
+		}
+		return e
+	case protoBytes:
+		packed, e := r.bytes()
+		if e != nil {
+			return e
+		}
+		pr := &protoReader{buf: packed}
+		for len(pr.buf) > 0 {
+			v, e := pr.varint()
+			if e != nil {
+				return e
+			}
+			action(v)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid wire type '%d', expected varint", r.wireType)
+	}
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	if r.wireType != protoBytes {
+		return nil, fmt.Errorf("invalid wire type '%d', expected bytes", r.wireType)
+	}
+	n, e := r.varint()
+	if e != nil {
+		return nil, e
+	}
+	if n > uint64(len(r.buf)) {
+		return nil, errors.New("length exceeds message")
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) string() (string, error) {
+	v, e := r.bytes()
+	return string(v), e
+}
+
+func (r *protoReader) fixedBytes(out []byte) error {
+	v, e := r.bytes()
+	if e != nil {
+		return e
+	}
+	if len(v) != len(out) {
+		return fmt.Errorf("invalid length '%d', expected '%d'", len(v), len(out))
+	}
+	copy(out, v)
+	return nil
+}
+
+// skip skips the value of a field that is not known.
+func (r *protoReader) skip() error {
+	var n uint64
+	switch r.wireType {
+	case protoVarint:
+		_, e := r.varint()
+		return e
+	case protoFixed64:
+		n = 8
+	case protoFixed32:
+		n = 4
+	case protoBytes:
+		_, e := r.bytes()
+		return e
+	default:
+		return fmt.Errorf("unsupported wire type '%d'", r.wireType)
+	}
+	if n > uint64(len(r.buf)) {
+		return errors.New("length exceeds message")
+	}
+	r.buf = r.buf[n:]
+	return nil
+}
@@ -0,0 +1,479 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	khttp "github.com/kittycash/wallet/src/http"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"gopkg.in/sirupsen/logrus.v1"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ServiceName is the full name of the service in 'chain.proto'.
+	ServiceName = "iko.rpc.Chain"
+
+	// MaxMessageSize is the maximum size of a request message, as is the
+	// default of gRPC.
+	MaxMessageSize = 4 << 20
+
+	// StreamBufferSize is the number of transactions that a stream buffers,
+	// before it misses transactions.
+	StreamBufferSize = 64
+
+	// DefaultKittiesPageSize is the page size of 'GetAddressKitties' for
+	// requests that do not specify one.
+	DefaultKittiesPageSize = 50
+)
+
+var log = logrus.New()
+
+// Code is a status code of gRPC.
+type Code uint32
+
+const (
+	CodeOK                 Code = 0
+	CodeInvalidArgument    Code = 3
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnauthenticated    Code = 16
+)
+
+// StatusError is an error from a call, with its status code.
+type StatusError struct {
+	Code    Code
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("rpc error with code '%d': %s", e.Code, e.Message)
+}
+
+func statusErrorf(code Code, format string, a ...interface{}) *StatusError {
+	return &StatusError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+type ServerConfig struct {
+	Address string
+
+	// APIKeys, if not nil, are required for 'InjectTx', as the 'x-api-key' or
+	// the 'authorization' (bearer) metadata of the call. Calls with an
+	// invalid key are rejected for every method.
+	APIKeys *khttp.APIKeyStore
+
+	// RateLimits, if not nil, limit the calls by each client by the route
+	// groups of the gateway: 'InjectTx' is in the 'write' group, and every
+	// other method in the 'read' group.
+	RateLimits *khttp.RateLimits
+}
+
+// Server serves the service of 'chain.proto' over gRPC, over HTTP/2 without
+// TLS (h2c).
+type Server struct {
+	c     *ServerConfig
+	bc    *iko.BlockChain
+	l     net.Listener
+	srv   *http.Server
+	unary map[string]func(req []byte) ([]byte, error)
+}
+
+func NewServer(config *ServerConfig, bc *iko.BlockChain) (*Server, error) {
+	l, e := net.Listen("tcp", config.Address)
+	if e != nil {
+		return nil, e
+	}
+	s := &Server{
+		c:  config,
+		bc: bc,
+		l:  l,
+	}
+	s.unary = map[string]func([]byte) ([]byte, error){
+		"GetTxOfHash":       s.getTxOfHash,
+		"GetTxOfSeq":        s.getTxOfSeq,
+		"GetTxRange":        s.getTxRange,
+		"InjectTx":          s.injectTx,
+		"GetKitty":          s.getKitty,
+		"GetAddressKitties": s.getAddressKitties,
+	}
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	s.srv = &http.Server{
+		Handler:   s,
+		Protocols: &protocols,
+	}
+	go func() {
+		if e := s.srv.Serve(l); e != nil && e != http.ErrServerClosed {
+			log.WithError(e).Error("rpc server stopped")
+		}
+	}()
+	return s, nil
+}
+
+// Addr obtains the address that the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+// Close quits the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// ServeHTTP serves a gRPC call, at the path "/iko.rpc.Chain/<method>".
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.ProtoMajor != 2:
+		http.Error(w, "rpc calls need http/2", http.StatusHTTPVersionNotSupported)
+		return
+	case r.Method != "POST":
+		http.Error(w, fmt.Sprintf("invalid method type of '%s', expected 'POST'", r.Method),
+			http.StatusMethodNotAllowed)
+		return
+	case !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc"):
+		http.Error(w, fmt.Sprintf("invalid content type '%s', expected 'application/grpc'",
+			r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	e := s.call(w, r)
+	status := &StatusError{Code: CodeOK}
+	if e != nil && !errors.As(e, &status) {
+		status = &StatusError{Code: CodeInternal, Message: e.Error()}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		w.Header().Set("Grpc-Message", encodeGrpcMessage(status.Message))
+	}
+}
+
+/*
+	<<< METHODS >>>
+*/
+
+func (s *Server) getTxOfHash(raw []byte) ([]byte, error) {
+	var req TxHashRequest
+	if e := req.Unmarshal(raw); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	if tx, e := s.bc.GetTxOfHash(req.Hash); e == nil {
+		return s.lookup(tx)
+	}
+	for _, p := range s.bc.PendingTxs() {
+		if p.Tx.Hash() == req.Hash {
+			return TxLookup{
+				Seq:     p.Tx.Seq,
+				Hash:    req.Hash,
+				Pending: true,
+				Tx:      &p.Tx,
+			}.Marshal(), nil
+		}
+	}
+	return nil, statusErrorf(CodeNotFound, "tx of hash '%s' not found", req.Hash.Hex())
+}
+
+func (s *Server) getTxOfSeq(raw []byte) ([]byte, error) {
+	var req TxSeqRequest
+	if e := req.Unmarshal(raw); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	tx, e := s.bc.GetTxOfSeq(req.Seq)
+	if e != nil {
+		return nil, statusErrorf(CodeNotFound, "%v", e)
+	}
+	return s.lookup(tx)
+}
+
+func (s *Server) getTxRange(raw []byte) ([]byte, error) {
+	var req TxRangeRequest
+	if e := req.Unmarshal(raw); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	startSeq := req.StartSeq
+	if req.FromHead {
+		startSeq = ^uint64(0)
+	}
+	if req.FromHead && !req.Desc {
+		head, e := s.bc.GetHeadTx()
+		if e == nil {
+			startSeq = head.Seq
+		}
+	}
+	txs, e := s.bc.GetTxRange(startSeq, req.PageSize, req.Desc)
+	if e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	return TxRange{
+		Txs:     txs.Transactions,
+		HeadSeq: txs.HeadSeq,
+		NextSeq: txs.NextSeq,
+		HasMore: txs.HasMore,
+	}.Marshal(), nil
+}
+
+func (s *Server) injectTx(raw []byte) ([]byte, error) {
+	tx, e := iko.UnmarshalTxProto(raw)
+	if e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	if e := s.bc.InjectTx(tx); e != nil {
+		if accepted, ok := e.(*iko.TxAcceptedError); ok {
+			return InjectTxReply{
+				Hash:      accepted.Hash,
+				Seq:       accepted.Seq,
+				Duplicate: true,
+			}.Marshal(), nil
+		}
+		return nil, &StatusError{Code: txErrorCode(e), Message: e.Error()}
+	}
+	return InjectTxReply{
+		Hash: tx.Hash(),
+		Seq:  tx.Seq,
+	}.Marshal(), nil
+}
+
+func (s *Server) getKitty(raw []byte) ([]byte, error) {
+	var req KittyRequest
+	if e := req.Unmarshal(raw); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	kState, ok := s.bc.GetKittyState(req.KittyID)
+	if !ok {
+		return nil, statusErrorf(CodeNotFound, "kitty of id '%d' not found", req.KittyID)
+	}
+	return Kitty{
+		KittyID:    req.KittyID,
+		Owner:      kState.Address.String(),
+		LastTxHash: kState.LastTx.Hash,
+		LastTxSeq:  kState.LastTx.Seq,
+		LastTxTime: kState.LastTx.TS,
+		Burned:     kState.Address == iko.BurnAddress,
+	}.Marshal(), nil
+}
+
+func (s *Server) getAddressKitties(raw []byte) ([]byte, error) {
+	var req AddressKittiesRequest
+	if e := req.Unmarshal(raw); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	address, e := cipher.DecodeBase58Address(req.Address)
+	if e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "invalid address '%s': %v", req.Address, e)
+	}
+	if req.PerPage == 0 {
+		req.PerPage = DefaultKittiesPageSize
+	}
+	paginated, e := s.bc.GetKittiesOfAddress(address, req.Page, req.PerPage)
+	if e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	return AddressKitties{
+		Address:        address.String(),
+		Count:          s.bc.CountOfAddress(address),
+		TotalPageCount: paginated.TotalPageCount,
+		KittyIDs:       paginated.Kitties,
+	}.Marshal(), nil
+}
+
+// streamTxs streams the transactions matching the filter that are accepted into
+// the chain, until the client cancels the call.
+func (s *Server) streamTxs(w http.ResponseWriter, r *http.Request, raw []byte) error {
+	var req TxStreamFilter
+	if e := req.Unmarshal(raw); e != nil {
+		return statusErrorf(CodeInvalidArgument, "%v", e)
+	}
+	filter := iko.TxFilter{Kitties: req.Kitties}
+	for _, s := range req.Addresses {
+		addr, e := cipher.DecodeBase58Address(s)
+		if e != nil {
+			return statusErrorf(CodeInvalidArgument, "invalid address '%s': %v", s, e)
+		}
+		filter.Addresses = append(filter.Addresses, addr)
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return statusErrorf(CodeInternal, "streaming is not supported")
+	}
+	sub := s.bc.Subscribe(StreamBufferSize)
+	defer sub.Close()
+
+	// Headers are sent once subscribed, so that clients can rely on the
+	// stream from then on.
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case tx, ok := <-sub.C():
+			if !ok {
+				return nil
+			}
+			if !filter.Match(tx) {
+				continue
+			}
+			if e := writeMessage(w, tx.MarshalProto()); e != nil {
+				return e
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// call reads the request message, and replies with the method of the path.
+func (s *Server) call(w http.ResponseWriter, r *http.Request) error {
+	service, method := splitMethod(r.URL.Path)
+	if service != ServiceName {
+		return statusErrorf(CodeUnimplemented, "unknown service '%s'", service)
+	}
+	unary, ok := s.unary[method]
+	if !ok && method != "StreamTxs" {
+		return statusErrorf(CodeUnimplemented, "unknown method '%s' for service '%s'", method, service)
+	}
+	if e := s.authorize(r, method); e != nil {
+		return e
+	}
+	req, e := readMessage(r.Body)
+	if e != nil {
+		return e
+	}
+	if !ok {
+		return s.streamTxs(w, r, req)
+	}
+	res, e := unary(req)
+	if e != nil {
+		return e
+	}
+	return writeMessage(w, res)
+}
+
+// authorize checks a call to the method against the rate limits, and then
+// against the API keys, so that calls with invalid keys are limited too.
+func (s *Server) authorize(r *http.Request, method string) error {
+	mutating := method == "InjectTx"
+	if s.c.RateLimits != nil {
+		group := khttp.RateGroupRead
+		if mutating {
+			group = khttp.RateGroupWrite
+		}
+		if wait, ok := s.c.RateLimits.Allow(group, r); !ok {
+			return statusErrorf(CodeResourceExhausted,
+				"rate limit for route group '%s' exceeded, retry in %s", group, wait)
+		}
+	}
+	if s.c.APIKeys == nil {
+		return nil
+	}
+	if key := khttp.APIKeyOfRequest(r); key != "" {
+		if _, ok := s.c.APIKeys.Verify(key); !ok {
+			return statusErrorf(CodeUnauthenticated, "%v", khttp.ErrAPIKeyInvalid)
+		}
+	} else if mutating {
+		return statusErrorf(CodeUnauthenticated, "%v", khttp.ErrAPIKeyRequired)
+	}
+	return nil
+}
+
+// lookup obtains the lookup of a transaction in the chain, with its
+// confirmations counted to the head.
+func (s *Server) lookup(tx iko.Transaction) ([]byte, error) {
+	head, e := s.bc.GetHeadTx()
+	if e != nil {
+		return nil, statusErrorf(CodeInternal, "%v", e)
+	}
+	out := TxLookup{
+		Seq:  tx.Seq,
+		Hash: tx.Hash(),
+		Tx:   &tx,
+	}
+	if head.Seq >= tx.Seq {
+		out.Confirmations = head.Seq - tx.Seq + 1
+	}
+	return out.Marshal(), nil
+}
+
+// txErrorCode obtains the status code for an error from injecting a
+// transaction, following the status codes of the gateway.
+func txErrorCode(e error) Code {
+	switch {
+	case errors.Is(e, iko.ErrBadSignature):
+		return CodeUnauthenticated
+	case errors.Is(e, iko.ErrNotOwner):
+		return CodePermissionDenied
+	case errors.Is(e, iko.ErrKittyUnknown):
+		return CodeNotFound
+	case errors.Is(e, iko.ErrDuplicateTx):
+		return CodeAlreadyExists
+	case errors.Is(e, iko.ErrExpired):
+		return CodeFailedPrecondition
+	case errors.Is(e, iko.ErrRateLimited):
+		return CodeResourceExhausted
+	default:
+		return CodeInvalidArgument
+	}
+}
+
+func splitMethod(path string) (service, method string) {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// readMessage reads a length-prefixed message of gRPC.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, e := io.ReadFull(r, prefix[:]); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "failed to read message: %v", e)
+	}
+	if prefix[0] != 0 {
+		return nil, statusErrorf(CodeUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > MaxMessageSize {
+		return nil, statusErrorf(CodeResourceExhausted, "message with %d bytes exceeds %d bytes", n, MaxMessageSize)
+	}
+	msg := make([]byte, n)
+	if _, e := io.ReadFull(r, msg); e != nil {
+		return nil, statusErrorf(CodeInvalidArgument, "failed to read message: %v", e)
+	}
+	return msg, nil
+}
+
+// writeMessage writes a length-prefixed message of gRPC.
+func writeMessage(w io.Writer, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, e := w.Write(append(frame, msg...))
+	return e
+}
+
+// encodeGrpcMessage percent-encodes a status message, as in gRPC over HTTP/2.
+func encodeGrpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
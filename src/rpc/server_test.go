@@ -0,0 +1,245 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	khttp "github.com/kittycash/wallet/src/http"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testCall is the reply to a call of the service.
+type testCall struct {
+	status   string
+	message  string
+	messages [][]byte
+}
+
+type testServer struct {
+	t      *testing.T
+	bc     *iko.BlockChain
+	sk     cipher.SecKey
+	srv    *Server
+	client *http.Client
+	header http.Header // Metadata for the calls.
+}
+
+// testSecKey is the key of the creator of the blockchains in the tests.
+var testSecKey = cipher.SecKey([32]byte{
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+	3, 4, 5, 6,
+})
+
+// newTestBlockChain creates a blockchain in memory, whose creator is
+// 'testSecKey'.
+func newTestBlockChain(t *testing.T, config iko.BlockChainConfig) *iko.BlockChain {
+	config.CreatorPK = cipher.PubKeyFromSecKey(testSecKey)
+	bc, e := iko.NewBlockChain(&config, iko.NewMemoryChain(10), iko.NewMemoryState())
+	require.Nil(t, e, "failed to create blockchain")
+	return bc
+}
+
+func newTestServer(t *testing.T, c ServerConfig) *testServer {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+	c.Address = "127.0.0.1:0"
+	srv, e := NewServer(&c, bc)
+	require.Nil(t, e, "failed to create server")
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &testServer{
+		t:      t,
+		bc:     bc,
+		sk:     sk,
+		srv:    srv,
+		client: &http.Client{Transport: &http.Transport{Protocols: &protocols}},
+		header: make(http.Header),
+	}
+}
+
+func (ts *testServer) Close() {
+	ts.srv.Close()
+	ts.bc.Close()
+}
+
+// start starts a call to the method, with the request message.
+func (ts *testServer) start(ctx context.Context, method string, req []byte) *http.Response {
+	var body bytes.Buffer
+	require.Nil(ts.t, writeMessage(&body, req))
+	r, e := http.NewRequestWithContext(ctx, "POST",
+		"http://"+ts.srv.Addr().String()+"/"+ServiceName+"/"+method, &body)
+	require.Nil(ts.t, e)
+	for k, v := range ts.header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	res, e := ts.client.Do(r)
+	require.Nil(ts.t, e, "failed to call")
+	require.Equal(ts.t, 2, res.ProtoMajor, "calls should use http/2")
+	return res
+}
+
+// call calls the method, and reads the reply until the end of the call.
+func (ts *testServer) call(method string, req []byte) testCall {
+	res := ts.start(context.Background(), method, req)
+	defer res.Body.Close()
+
+	var out testCall
+	for {
+		msg, e := readMessage(res.Body)
+		if e != nil {
+			break
+		}
+		out.messages = append(out.messages, msg)
+	}
+	io.Copy(io.Discard, res.Body)
+	out.status = res.Trailer.Get("Grpc-Status")
+	out.message = res.Trailer.Get("Grpc-Message")
+	return out
+}
+
+func TestServer_Unary(t *testing.T) {
+	ts := newTestServer(t, ServerConfig{})
+	defer ts.Close()
+	bc, sk, call := ts.bc, ts.sk, ts.call
+
+	gen := iko.NewGenTx(nil, 1, sk)
+	res := call("InjectTx", gen.MarshalProto())
+	require.Equal(t, "0", res.status, res.message)
+	require.Len(t, res.messages, 1)
+	var injected InjectTxReply
+	require.Nil(t, injected.Unmarshal(res.messages[0]))
+	require.Equal(t, gen.Hash(), injected.Hash)
+	require.False(t, injected.Duplicate)
+
+	res = call("InjectTx", gen.MarshalProto())
+	require.Equal(t, "0", res.status, "retried injections should succeed")
+	require.Nil(t, injected.Unmarshal(res.messages[0]))
+	require.True(t, injected.Duplicate, "retried injections should be duplicates")
+
+	to := cipher.AddressFromSecKey(cipher.SecKey([32]byte{1}))
+	transfer := iko.NewTransferTx(gen, 1, to, bc.NextNonce(cipher.AddressFromSecKey(sk)), sk)
+	require.Nil(t, bc.InjectTx(transfer), "failed to inject transfer")
+
+	res = call("GetTxOfHash", TxHashRequest{Hash: gen.Hash()}.Marshal())
+	require.Equal(t, "0", res.status, res.message)
+	var lookup TxLookup
+	require.Nil(t, lookup.Unmarshal(res.messages[0]))
+	require.Equal(t, uint64(0), lookup.Seq)
+	require.Equal(t, uint64(2), lookup.Confirmations, "confirmations should be counted to the head")
+	require.Equal(t, gen.Hash(), lookup.Tx.Hash(), "lookups should find the transaction")
+
+	res = call("GetTxOfSeq", TxSeqRequest{Seq: 1}.Marshal())
+	require.Equal(t, "0", res.status, res.message)
+	lookup = TxLookup{}
+	require.Nil(t, lookup.Unmarshal(res.messages[0]))
+	require.Equal(t, transfer.Hash(), lookup.Hash)
+	require.Equal(t, uint64(1), lookup.Confirmations)
+
+	res = call("GetTxOfSeq", TxSeqRequest{Seq: 9}.Marshal())
+	require.Equal(t, "5", res.status, "unknown transactions should not be found")
+
+	res = call("GetTxRange", TxRangeRequest{PageSize: 10, Desc: true, FromHead: true}.Marshal())
+	require.Equal(t, "0", res.status, res.message)
+	var txRange TxRange
+	require.Nil(t, txRange.Unmarshal(res.messages[0]))
+	require.Len(t, txRange.Txs, 2)
+	require.Equal(t, uint64(1), txRange.Txs[0].Seq, "descending ranges should start at the head")
+	require.False(t, txRange.HasMore)
+
+	res = call("GetKitty", KittyRequest{KittyID: 1}.Marshal())
+	require.Equal(t, "0", res.status, res.message)
+	var kitty Kitty
+	require.Nil(t, kitty.Unmarshal(res.messages[0]))
+	require.Equal(t, to.String(), kitty.Owner, "kitties should have their owner")
+	require.Equal(t, transfer.Hash(), kitty.LastTxHash)
+
+	res = call("GetAddressKitties", AddressKittiesRequest{Address: to.String()}.Marshal())
+	require.Equal(t, "0", res.status, res.message)
+	var kitties AddressKitties
+	require.Nil(t, kitties.Unmarshal(res.messages[0]))
+	require.Equal(t, uint64(1), kitties.Count)
+	require.Equal(t, iko.KittyIDs{1}, kitties.KittyIDs)
+
+	res = call("GetAddressKitties", AddressKittiesRequest{Address: "invalid"}.Marshal())
+	require.Equal(t, "3", res.status, "invalid addresses should be rejected")
+
+	res = call("Unknown", nil)
+	require.Equal(t, "12", res.status, "unknown methods should be unimplemented")
+}
+
+func TestServer_StreamTxs(t *testing.T) {
+	ts := newTestServer(t, ServerConfig{})
+	defer ts.Close()
+
+	res := ts.call("StreamTxs", TxStreamFilter{Addresses: []string{"invalid"}}.Marshal())
+	require.Equal(t, "3", res.status, "invalid filters should be rejected")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := ts.start(ctx, "StreamTxs", TxStreamFilter{Kitties: iko.KittyIDs{2}}.Marshal())
+	defer stream.Body.Close()
+
+	gen1 := iko.NewGenTx(nil, 1, ts.sk)
+	require.Nil(t, ts.bc.InjectTx(gen1), "failed to inject gen tx")
+	gen2 := iko.NewGenTx(gen1, 2, ts.sk)
+	require.Nil(t, ts.bc.InjectTx(gen2), "failed to inject gen tx")
+
+	received := make(chan []byte, 1)
+	go func() {
+		msg, _ := readMessage(stream.Body)
+		received <- msg
+	}()
+	select {
+	case msg := <-received:
+		tx, e := iko.UnmarshalTxProto(msg)
+		require.Nil(t, e, "streamed messages should be transactions")
+		require.Equal(t, gen2.Hash(), tx.Hash(), "only transactions matching the filter should be streamed")
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for streamed transaction")
+	}
+}
+
+func TestServer_Authorize(t *testing.T) {
+	keys, e := khttp.NewAPIKeyStore("")
+	require.Nil(t, e)
+	key, _, e := keys.Create("writer", false)
+	require.Nil(t, e)
+	limits, e := khttp.NewRateLimits(khttp.RateLimitConfig{
+		Limits: map[string]khttp.RateLimit{khttp.RateGroupRead: {Requests: 3, Per: time.Minute}},
+	}, keys)
+	require.Nil(t, e)
+
+	ts := newTestServer(t, ServerConfig{APIKeys: keys, RateLimits: limits})
+	defer ts.Close()
+
+	gen := iko.NewGenTx(nil, 1, ts.sk)
+	res := ts.call("InjectTx", gen.MarshalProto())
+	require.Equal(t, "16", res.status, "injections should require a key")
+	ts.header.Set("Authorization", "Bearer "+key)
+	res = ts.call("InjectTx", gen.MarshalProto())
+	require.Equal(t, "0", res.status, res.message)
+
+	ts.header.Set("Authorization", "Bearer "+key+"0")
+	res = ts.call("GetTxOfSeq", TxSeqRequest{Seq: 0}.Marshal())
+	require.Equal(t, "16", res.status, "invalid keys should be rejected for every method")
+	ts.header.Del("Authorization")
+	for i := 0; i < 2; i++ {
+		res = ts.call("GetTxOfSeq", TxSeqRequest{Seq: 0}.Marshal())
+		require.Equal(t, "0", res.status, res.message)
+	}
+	res = ts.call("GetTxOfSeq", TxSeqRequest{Seq: 0}.Marshal())
+	require.Equal(t, "8", res.status, "calls should be rate limited, including those with invalid keys")
+}
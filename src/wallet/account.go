@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"strconv"
+)
+
+const (
+	// MaxAccounts is the maximum number of named accounts in a wallet
+	// (besides the default account).
+	MaxAccounts = 64
+
+	// DefaultAccountName is the name of the default account of a wallet,
+	// which holds the wallet's entries (derived from the seed itself).
+	DefaultAccountName = "default"
+)
+
+var ErrAccountNotFound = errors.New("account not found")
+
+// Account is a named account of a wallet, with its own list of entries. The
+// entries of an account are derived (as with 'DeriveSecKey') from the seed for
+// its index (see 'AccountSeed'), so accounts are covered by a backup of the
+// wallet seed, and separate holdings of the seed (such as "personal" and
+// "trading") without sharing addresses.
+type Account struct {
+	Name    string
+	Index   uint64 // Starts from 1, as 0 is the default account.
+	Entries []Entry
+}
+
+// FloatingAccount represents a readable account of a wallet.
+type FloatingAccount struct {
+	Name    string           `json:"name"`
+	Index   uint64           `json:"index"`
+	Path    string           `json:"path"` // Derivation path (see 'AccountPath').
+	Entries []*FloatingEntry `json:"entries"`
+}
+
+// AccountPath obtains the derivation path of an account with an index, as
+// "m/<index>". The path of the default account is "m/0".
+func AccountPath(index uint64) string {
+	return "m/" + strconv.FormatUint(index, 10)
+}
+
+// AccountSeed obtains the seed from which the entries of an account with an index
+// are derived, as the hex of the SHA256 of "<seed>/<index>". The default
+// account (index 0) uses the wallet seed itself.
+func AccountSeed(seed string, index uint64) string {
+	if index == 0 {
+		return seed
+	}
+	return cipher.SumSHA256([]byte(seed + "/" + strconv.FormatUint(index, 10))).Hex()
+}
+
+// VerifyAccountName checks that an account name is not empty, reserved or
+// too long.
+func VerifyAccountName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("account name can not be empty")
+	case name == DefaultAccountName:
+		return fmt.Errorf("account name '%s' is reserved", name)
+	case len(name) > MaxNameSize:
+		return fmt.Errorf("account name exceeds %d bytes", MaxNameSize)
+	}
+	return nil
+}
+
+// Verify checks the name of the account, and its entries.
+func (a *Account) Verify() error {
+	if e := VerifyAccountName(a.Name); e != nil {
+		return e
+	}
+	if a.Index == 0 {
+		return fmt.Errorf("account '%s' has the index of the default account", a.Name)
+	}
+	for i := range a.Entries {
+		if e := a.Entries[i].Verify(); e != nil {
+			return fmt.Errorf("account '%s' has invalid entry: %v", a.Name, e)
+		}
+	}
+	return nil
+}
+
+func (a *Account) ToFloating() *FloatingAccount {
+	fa := &FloatingAccount{
+		Name:    a.Name,
+		Index:   a.Index,
+		Path:    AccountPath(a.Index),
+		Entries: make([]*FloatingEntry, len(a.Entries)),
+	}
+	for i, entry := range a.Entries {
+		fa.Entries[i] = entry.ToFloating()
+	}
+	return fa
+}
+
+// AddAccount adds a named account with the next index to the wallet (without
+// entries). Watch-only wallets have no seed, and so no accounts.
+func (w *Wallet) AddAccount(name string) (*Account, error) {
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	if e := VerifyAccountName(name); e != nil {
+		return nil, e
+	}
+	if len(w.Accounts) >= MaxAccounts {
+		return nil, fmt.Errorf("wallet exceeds %d accounts", MaxAccounts)
+	}
+	var index uint64
+	for _, a := range w.Accounts {
+		if a.Name == name {
+			return nil, fmt.Errorf("account '%s' already exists", name)
+		}
+		if a.Index > index {
+			index = a.Index
+		}
+	}
+	w.Accounts = append(w.Accounts, Account{
+		Name:    name,
+		Index:   index + 1,
+		Entries: []Entry{},
+	})
+	w.Meta.Saved = false
+	return &w.Accounts[len(w.Accounts)-1], nil
+}
+
+// Account obtains the named account of the wallet.
+func (w *Wallet) Account(name string) (*Account, error) {
+	for i := range w.Accounts {
+		if w.Accounts[i].Name == name {
+			return &w.Accounts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrAccountNotFound, name)
+}
+
+// NewAccountAddress derives the entry at the next index of the named account,
+// and returns its address. The default account uses 'NewAddress'.
+func (w *Wallet) NewAccountAddress(name string) (cipher.Address, error) {
+	if name == "" || name == DefaultAccountName {
+		return w.NewAddress()
+	}
+	if w.IsWatchOnly() {
+		return cipher.Address{}, ErrWatchOnly
+	}
+	a, e := w.Account(name)
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	sk, e := DeriveSecKey(AccountSeed(w.Meta.Seed, a.Index), len(a.Entries))
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	entry, e := NewEntry(sk)
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	a.Entries = append(a.Entries, *entry)
+	w.Meta.Saved = false
+	return entry.Address, nil
+}
+
+// AddWalletAccount adds a named account to the wallet of specified label (see
+// 'Wallet.AddAccount'), and saves it.
+func (m *Manager) AddWalletAccount(label, name string) (*FloatingWallet, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	n := len(w.Accounts)
+	if _, e := w.AddAccount(name); e != nil {
+		return nil, e
+	}
+	if e := w.Save(); e != nil {
+		w.Accounts = w.Accounts[:n]
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
+
+// NewWalletAddresses derives n new addresses for the default account of the
+// wallet of specified label, with one file write (see 'Wallet.NewAddresses').
+func (m *Manager) NewWalletAddresses(label string, n int) ([]cipher.Address, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	return w.NewAddresses(n)
+}
+
+// NewWalletAccountAddress derives a new address for the named account of the
+// wallet of specified label (see 'Wallet.NewAccountAddress'), and saves it.
+func (m *Manager) NewWalletAccountAddress(label, name string) (cipher.Address, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	addr, e := w.NewAccountAddress(name)
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	if e := w.Save(); e != nil {
+		return cipher.Address{}, e
+	}
+	return addr, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// verifyAccounts checks the accounts of a wallet file, for unique names and
+// indexes.
+func verifyAccounts(accounts []Account) error {
+	if len(accounts) > MaxAccounts {
+		return fmt.Errorf("wallet exceeds %d accounts", MaxAccounts)
+	}
+	names := make(map[string]bool, len(accounts))
+	indexes := make(map[uint64]bool, len(accounts))
+	for i := range accounts {
+		a := &accounts[i]
+		if e := a.Verify(); e != nil {
+			return e
+		}
+		if names[a.Name] || indexes[a.Index] {
+			return fmt.Errorf("account '%s' is duplicate", a.Name)
+		}
+		names[a.Name], indexes[a.Index] = true, true
+	}
+	return nil
+}
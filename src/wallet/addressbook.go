@@ -0,0 +1,190 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrContactNotFound = errors.New("contact of name is not found")
+
+const (
+	// AddressBookFile is the name of the address book file, which is saved
+	// in the root directory alongside the wallet files.
+	AddressBookFile = "addressbook.json"
+
+	// MaxContactNoteSize is the maximum size of the note of a contact.
+	MaxContactNoteSize = 1024
+)
+
+// Contact is an entry in the address book.
+type Contact struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Verify checks the name, address and note of the contact.
+func (c *Contact) Verify() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("contact needs a name")
+	}
+	if len(c.Name) > MaxNameSize {
+		return fmt.Errorf("contact name exceeds %d bytes", MaxNameSize)
+	}
+	if _, e := cipher.DecodeBase58Address(c.Address); e != nil {
+		return fmt.Errorf("invalid address '%s' for contact '%s': %v", c.Address, c.Name, e)
+	}
+	if len(c.Note) > MaxContactNoteSize {
+		return fmt.Errorf("contact note exceeds %d bytes", MaxContactNoteSize)
+	}
+	return nil
+}
+
+// AddressBook maps names of contacts to their addresses, so that kitties can
+// be sent to a name rather than a raw address. Names are unique regardless of
+// case, and every change is saved to the file of the address book.
+type AddressBook struct {
+	mux      sync.Mutex
+	path     string
+	contacts []Contact // Sorted by name.
+}
+
+// NewAddressBook loads the address book at the path (an address book at a
+// path that does not exist is empty).
+func NewAddressBook(path string) (*AddressBook, error) {
+	ab := &AddressBook{path: path}
+	if raw, e := store.ReadFile(path); e == nil {
+		if e := json.Unmarshal(raw, &ab.contacts); e != nil {
+			return nil, fmt.Errorf("failed to load address book: %v", e)
+		}
+	} else if !os.IsNotExist(e) {
+		return nil, fmt.Errorf("failed to load address book: %v", e)
+	}
+	for _, c := range ab.contacts {
+		if e := c.Verify(); e != nil {
+			return nil, e
+		}
+	}
+	ab.sort()
+	return ab, nil
+}
+
+// AddressBookPath obtains the path of the address book in the root directory.
+func AddressBookPath() string {
+	return filepath.Join(rootDir, AddressBookFile)
+}
+
+// List obtains the contacts, sorted by name.
+func (ab *AddressBook) List() []Contact {
+	ab.mux.Lock()
+	defer ab.mux.Unlock()
+
+	return append([]Contact{}, ab.contacts...)
+}
+
+// Get obtains the contact with a name.
+func (ab *AddressBook) Get(name string) (Contact, error) {
+	ab.mux.Lock()
+	defer ab.mux.Unlock()
+
+	i := ab.find(name)
+	if i < 0 {
+		return Contact{}, ErrContactNotFound
+	}
+	return ab.contacts[i], nil
+}
+
+// Put adds a contact, or replaces the contact with the same name.
+func (ab *AddressBook) Put(c Contact) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Address = strings.TrimSpace(c.Address)
+	if e := c.Verify(); e != nil {
+		return e
+	}
+
+	ab.mux.Lock()
+	defer ab.mux.Unlock()
+
+	old := append([]Contact{}, ab.contacts...)
+	if i := ab.find(c.Name); i >= 0 {
+		ab.contacts[i] = c
+	} else {
+		ab.contacts = append(ab.contacts, c)
+		ab.sort()
+	}
+	if e := ab.save(); e != nil {
+		ab.contacts = old
+		return e
+	}
+	return nil
+}
+
+// Remove removes the contact with a name.
+func (ab *AddressBook) Remove(name string) error {
+	ab.mux.Lock()
+	defer ab.mux.Unlock()
+
+	i := ab.find(name)
+	if i < 0 {
+		return ErrContactNotFound
+	}
+	old := append([]Contact{}, ab.contacts...)
+	ab.contacts = append(ab.contacts[:i], ab.contacts[i+1:]...)
+	if e := ab.save(); e != nil {
+		ab.contacts = old
+		return e
+	}
+	return nil
+}
+
+// Resolve obtains the address of a contact name, or the input itself if it
+// is an address.
+func (ab *AddressBook) Resolve(nameOrAddress string) (cipher.Address, error) {
+	if addr, e := cipher.DecodeBase58Address(nameOrAddress); e == nil {
+		return addr, nil
+	}
+	c, e := ab.Get(nameOrAddress)
+	if e != nil {
+		return cipher.Address{}, fmt.Errorf("%w: '%s'", e, nameOrAddress)
+	}
+	return cipher.DecodeBase58Address(c.Address)
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func (ab *AddressBook) find(name string) int {
+	name = strings.TrimSpace(name)
+	for i, c := range ab.contacts {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ab *AddressBook) sort() {
+	sort.SliceStable(ab.contacts, func(i, j int) bool {
+		return strings.ToLower(ab.contacts[i].Name) < strings.ToLower(ab.contacts[j].Name)
+	})
+}
+
+func (ab *AddressBook) save() error {
+	contacts := ab.contacts
+	if contacts == nil {
+		contacts = []Contact{}
+	}
+	raw, e := json.MarshalIndent(contacts, "", "    ")
+	if e != nil {
+		return e
+	}
+	return store.WriteFile(ab.path, raw)
+}
@@ -0,0 +1,267 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AuditFile is the name of the audit log, which is kept in the root directory
+// alongside the wallet files. AuditKeyFile keeps the random key of the log
+// for managers without an audit secret (see 'ManagerConfig.AuditSecret').
+const (
+	AuditFile    = "audit.log"
+	AuditKeyFile = "audit.key"
+
+	auditKeyContext = "kittycash/wallet audit log"
+)
+
+// AuditAction determines the action of an audit entry.
+type AuditAction string
+
+const (
+	AuditUnlock         AuditAction = "unlock"
+	AuditPasswordFailed AuditAction = "password_failed" // On unlocks and password changes.
+	AuditSign           AuditAction = "sign"
+	AuditExport         AuditAction = "export"   // On exports, backups and seed shares.
+	AuditTampered       AuditAction = "tampered" // First entry in a new log, where detail is the moved log.
+)
+
+var ErrAuditTampered = errors.New("audit log is tampered with")
+
+// AuditEntry is an entry in the audit log. Each entry is authenticated by an
+// HMAC-SHA256 under the key of the log, which covers the MAC of the previous
+// entry, so entries that are changed, removed or reordered break the chain
+// (see 'Manager.VerifyAuditLog'). Without the key the chain can not be
+// rewritten either.
+type AuditEntry struct {
+	Seq    uint64      `json:"seq"`
+	TS     int64       `json:"timestamp"`
+	Label  string      `json:"label"`
+	Action AuditAction `json:"action"`
+	Detail string      `json:"detail,omitempty"` // Such as the address for a signature.
+	Prev   string      `json:"prev"`             // MAC of the previous entry.
+	MAC    string      `json:"mac"`              // HMAC-SHA256 of the entry, with 'Prev' but without 'MAC'.
+}
+
+// AuditQuery selects entries in the audit log, where empty fields select
+// every entry.
+type AuditQuery struct {
+	Label  string
+	Action AuditAction
+	Since  int64 // In unix nanoseconds, inclusive.
+	Until  int64 // In unix nanoseconds, exclusive.
+	Limit  int   // Number of the latest entries.
+}
+
+// AuditLogPath obtains the path of the audit log in the root directory.
+func AuditLogPath() string {
+	return filepath.Join(rootDir, AuditFile)
+}
+
+// AuditLog obtains the entries in the audit log that match the query, in the
+// order they are recorded. The chain of the log is checked as it is read, so
+// a log that is tampered with fails with 'ErrAuditTampered'.
+func (m *Manager) AuditLog(q AuditQuery) ([]AuditEntry, error) {
+	m.auditMux.Lock()
+	defer m.auditMux.Unlock()
+
+	entries, e := m.readAuditLog()
+	if e != nil {
+		return nil, e
+	}
+	out := make([]AuditEntry, 0)
+	for _, entry := range entries {
+		switch {
+		case q.Label != "" && entry.Label != q.Label:
+		case q.Action != "" && entry.Action != q.Action:
+		case q.Since != 0 && entry.TS < q.Since:
+		case q.Until != 0 && entry.TS >= q.Until:
+		default:
+			out = append(out, entry)
+		}
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
+
+// VerifyAuditLog checks the chain of the audit log, and obtains the MAC of
+// its latest entry. Entries can still be cut from the end of the log, so the
+// MAC can be noted elsewhere to check later logs against it.
+func (m *Manager) VerifyAuditLog() (string, error) {
+	m.auditMux.Lock()
+	defer m.auditMux.Unlock()
+
+	entries, e := m.readAuditLog()
+	if e != nil || len(entries) == 0 {
+		return "", e
+	}
+	return entries[len(entries)-1].MAC, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// audit records an entry in the audit log. Failures are logged, rather than
+// failing the action. A log that is tampered with is moved aside (see
+// 'moveAuditLog'), and recording goes on in a new log.
+func (m *Manager) audit(label string, action AuditAction, detail string) {
+	m.auditMux.Lock()
+	defer m.auditMux.Unlock()
+
+	if !m.auditRead {
+		entries, e := m.readAuditLog()
+		switch {
+		case errors.Is(e, ErrAuditTampered):
+			log.WithError(e).Error("audit log is tampered with, starting a new log")
+			moved, e := moveAuditLog()
+			if e != nil {
+				log.WithError(e).Warningf("failed to record `%s` for wallet `%s` in audit log", action, label)
+				return
+			}
+			m.auditSeq, m.auditPrev, m.auditRead = 0, "", true
+			if !m.appendAudit("", AuditTampered, moved) {
+				m.auditRead = false
+				return
+			}
+		case e != nil:
+			log.WithError(e).Warningf("failed to record `%s` for wallet `%s` in audit log", action, label)
+			return
+		default:
+			m.auditSeq, m.auditPrev = 0, ""
+			if n := len(entries); n != 0 {
+				m.auditSeq, m.auditPrev = entries[n-1].Seq+1, entries[n-1].MAC
+			}
+			m.auditRead = true
+		}
+	}
+	m.appendAudit(label, action, detail)
+}
+
+// appendAudit appends an entry to the chain of the audit log, and determines
+// whether it is recorded.
+func (m *Manager) appendAudit(label string, action AuditAction, detail string) bool {
+	entry := AuditEntry{
+		Seq:    m.auditSeq,
+		TS:     time.Now().UnixNano(),
+		Label:  label,
+		Action: action,
+		Detail: detail,
+		Prev:   m.auditPrev,
+	}
+	entry.MAC = entry.mac(m.auditKey)
+	raw, _ := json.Marshal(entry)
+	if e := store.AppendFile(AuditLogPath(), append(raw, '\n')); e != nil {
+		log.WithError(e).Warningf("failed to record `%s` for wallet `%s` in audit log", action, label)
+		return false
+	}
+	m.auditSeq, m.auditPrev = entry.Seq+1, entry.MAC
+	return true
+}
+
+// auditPassword records a failed password attempt, if the error is one.
+func (m *Manager) auditPassword(label string, e error, detail string) {
+	if errors.Is(e, ErrInvalidPassword) {
+		m.audit(label, AuditPasswordFailed, detail)
+	}
+}
+
+func (entry AuditEntry) mac(key []byte) string {
+	entry.MAC = ""
+	raw, _ := json.Marshal(entry)
+	h := hmac.New(sha256.New, key)
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// auditKey derives the key of the audit log from the audit secret. Without a
+// secret the key is random, and is kept in the root directory, where it only
+// stops those who can not read the directory from rewriting the log.
+func auditKey(secret []byte) ([]byte, error) {
+	if len(secret) == 0 {
+		keyPath := filepath.Join(rootDir, AuditKeyFile)
+		raw, e := store.ReadFile(keyPath)
+		switch {
+		case e == nil:
+			if secret, e = hex.DecodeString(strings.TrimSpace(string(raw))); e != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("invalid audit key file '%s'", keyPath)
+			}
+		case os.IsNotExist(e):
+			secret = make([]byte, 32)
+			if _, e := rand.Read(secret); e != nil {
+				return nil, e
+			}
+			if e := store.WriteFile(keyPath, []byte(hex.EncodeToString(secret)+"\n")); e != nil {
+				return nil, e
+			}
+		default:
+			return nil, e
+		}
+	}
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(auditKeyContext))
+	return h.Sum(nil), nil
+}
+
+// moveAuditLog moves a tampered audit log aside, and obtains the name it is
+// moved to.
+func moveAuditLog() (string, error) {
+	name := fmt.Sprintf("audit-%d.tampered.log", time.Now().UnixNano())
+	if e := store.Rename(AuditLogPath(), filepath.Join(rootDir, name)); e != nil {
+		return "", e
+	}
+	return name, nil
+}
+
+// readAuditLog reads and checks the entries in the audit log. A log that is
+// tampered with is read again (and moved aside) before the next entry is
+// recorded.
+func (m *Manager) readAuditLog() ([]AuditEntry, error) {
+	entries, e := m.readAuditEntries()
+	if errors.Is(e, ErrAuditTampered) {
+		m.auditRead = false
+	}
+	return entries, e
+}
+
+func (m *Manager) readAuditEntries() ([]AuditEntry, error) {
+	raw, e := store.ReadFile(AuditLogPath())
+	if os.IsNotExist(e) {
+		return nil, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	var (
+		entries []AuditEntry
+		prev    string
+	)
+	for i, line := range bytes.Split(bytes.TrimSuffix(raw, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if e := json.Unmarshal(line, &entry); e != nil {
+			return nil, fmt.Errorf("%w: malformed line %d", ErrAuditTampered, i+1)
+		}
+		if entry.Seq != uint64(len(entries)) || entry.Prev != prev ||
+			!hmac.Equal([]byte(entry.MAC), []byte(entry.mac(m.auditKey))) {
+			return nil, fmt.Errorf("%w: at entry %d", ErrAuditTampered, len(entries))
+		}
+		entries = append(entries, entry)
+		prev = entry.MAC
+	}
+	return entries, nil
+}
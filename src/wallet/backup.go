@@ -0,0 +1,284 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+var ErrInvalidBackup = errors.New("invalid backup archive, with a checksum that does not match")
+
+const (
+	// BackupVersion is the version of the backup archive format.
+	BackupVersion uint64 = 1
+
+	// BackupMagic starts every backup archive.
+	BackupMagic = "KCWBACKU"
+)
+
+// BackupFile is a wallet file in a backup archive, as it is on disk (so
+// encrypted wallets stay encrypted with their own passwords).
+type BackupFile struct {
+	Label string
+	Data  []byte
+}
+
+// Backup is the (decrypted) content of a backup archive.
+type Backup struct {
+	Version uint64
+	TS      int64 // Time that the archive is created.
+	Files   []BackupFile
+}
+
+// Backup writes a single archive with every wallet file to the writer, which is
+// encrypted with the password. The archive ends with the SHA256 of the rest of
+// it, so a copy can be checked with 'VerifyBackup' without the password.
+func (m *Manager) Backup(w io.Writer, password string) error {
+	defer m.lock()()
+
+	backup := Backup{
+		Version: BackupVersion,
+		TS:      time.Now().UnixNano(),
+		Files:   make([]BackupFile, len(m.labels)),
+	}
+	for i, label := range m.labels {
+		data, _, e := readWalletFile(LabelPath(label))
+		if e != nil {
+			return e
+		}
+		backup.Files[i] = BackupFile{Label: label, Data: data}
+	}
+
+	header := backupHeader()
+	data, e := encryptData(header, encoder.Serialize(backup), password)
+	if e != nil {
+		return e
+	}
+	raw := append(header, data...)
+	sum := cipher.SumSHA256(raw)
+	if _, e = w.Write(append(raw, sum[:]...)); e != nil {
+		return e
+	}
+	for _, label := range m.labels {
+		m.audit(label, AuditExport, "backup")
+	}
+	return nil
+}
+
+// VerifyBackup checks the checksum of a backup archive, without decrypting it.
+func VerifyBackup(r io.Reader) error {
+	_, e := readBackup(r)
+	return e
+}
+
+// ReadBackup checks and decrypts a backup archive.
+func ReadBackup(r io.Reader, password string) (*Backup, error) {
+	data, e := readBackup(r)
+	if e != nil {
+		return nil, e
+	}
+	if data, e = decryptData(backupHeader(), data, password); e != nil {
+		return nil, e
+	}
+	backup := new(Backup)
+	if e := decodeBackup(data, backup); e != nil {
+		return nil, e
+	}
+	if backup.Version != BackupVersion {
+		return nil, fmt.Errorf("backup archive with version %d is not supported", backup.Version)
+	}
+	for _, f := range backup.Files {
+		if e := VerifyLabel(f.Label); e != nil {
+			return nil, e
+		}
+	}
+	return backup, nil
+}
+
+// RestorePolicy determines what happens to wallets in a backup archive with
+// labels that already exist.
+type RestorePolicy uint8
+
+const (
+	// RestoreAbort fails the restore (restoring nothing) if any label in the
+	// archive already exists.
+	RestoreAbort RestorePolicy = iota
+
+	// RestoreMerge restores wallets with new labels, and keeps the existing
+	// wallets with labels that collide.
+	RestoreMerge
+
+	// RestoreReplace restores every wallet, replacing existing wallets with
+	// labels that collide.
+	RestoreReplace
+)
+
+// RestoreResult lists the labels in a restore, by what happened to them.
+type RestoreResult struct {
+	Restored   []string           `json:"restored"`
+	Replaced   []string           `json:"replaced"`
+	Skipped    []string           `json:"skipped"`
+	Duplicates []DuplicateAddress `json:"duplicates"` // Of restored (or skipped) wallets.
+}
+
+// Restore restores the wallet files from a backup archive (see 'Backup'). Every
+// file is checked before any is written. Restored wallets that are encrypted
+// are locked. Wallets with addresses of other wallets fail the restore (see
+// 'RestoreWithPolicy').
+func (m *Manager) Restore(r io.Reader, password string, policy RestorePolicy) (*RestoreResult, error) {
+	return m.RestoreWithPolicy(r, password, policy, DuplicateReject)
+}
+
+// RestoreWithPolicy restores the wallet files from a backup archive (as with
+// 'Restore'), where the duplicate policy determines what happens to wallets with
+// addresses that already belong to other wallets. Only addresses of unencrypted
+// wallets in the archive, and of unlocked (and watch-only) wallets in the
+// manager, are known.
+func (m *Manager) RestoreWithPolicy(r io.Reader, password string, policy RestorePolicy, duplicates DuplicatePolicy) (*RestoreResult, error) {
+	if policy > RestoreReplace {
+		return nil, fmt.Errorf("invalid restore policy '%d'", policy)
+	}
+	if duplicates > DuplicateAllow {
+		return nil, fmt.Errorf("invalid duplicate policy '%d'", duplicates)
+	}
+	backup, e := ReadBackup(r, password)
+	if e != nil {
+		return nil, e
+	}
+
+	defer m.lock()()
+
+	var (
+		res        = new(RestoreResult)
+		wallets    = make([]*Wallet, len(backup.Files))
+		merged     = make([]bool, len(backup.Files))
+		collisions []string
+		seen       = make(map[string]bool)
+	)
+	for i, f := range backup.Files {
+		if seen[f.Label] {
+			return nil, fmt.Errorf("backup archive has duplicate label '%s'", f.Label)
+		}
+		seen[f.Label] = true
+		if wallets[i], e = checkBackupFile(f); e != nil {
+			return nil, e
+		}
+		if m.config.RequireEncrypted && wallets[i] != nil {
+			return nil, fmt.Errorf("%w: '%s'", ErrUnencryptedWallet, f.Label)
+		}
+		if _, ok := m.wallets[f.Label]; ok {
+			collisions = append(collisions, f.Label)
+		}
+		if wallets[i] == nil {
+			continue
+		}
+		var addrs []cipher.Address
+		for _, entry := range wallets[i].publicEntries() {
+			addrs = append(addrs, entry.Address)
+		}
+		dups := m.duplicatesOf(f.Label, addrs)
+		if len(dups) == 0 {
+			continue
+		}
+		if duplicates == DuplicateReject {
+			return nil, &DuplicateError{Label: f.Label, Duplicates: dups}
+		}
+		merged[i] = duplicates == DuplicateMerge
+		res.Duplicates = append(res.Duplicates, dups...)
+	}
+	if policy == RestoreAbort && len(collisions) != 0 {
+		return nil, fmt.Errorf("%v: %v", ErrLabelAlreadyExists, collisions)
+	}
+
+	for i, f := range backup.Files {
+		_, exists := m.wallets[f.Label]
+		if (exists && policy == RestoreMerge) || merged[i] {
+			res.Skipped = append(res.Skipped, f.Label)
+			continue
+		}
+		if e := writeWalletFile(LabelPath(f.Label), "", f.Data); e != nil {
+			return res, e
+		}
+		if exists {
+			m.lockWallet(f.Label)
+			m.wallets[f.Label] = wallets[i]
+			res.Replaced = append(res.Replaced, f.Label)
+		} else {
+			m.append(f.Label, wallets[i])
+			res.Restored = append(res.Restored, f.Label)
+		}
+	}
+	return res, m.sort()
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// checkBackupFile checks a wallet file in a backup archive, and loads it if
+// it is not encrypted (encrypted wallets are nil, as they are locked).
+func checkBackupFile(f BackupFile) (*Wallet, error) {
+	prefix, _, e := ExtractPrefix(f.Data)
+	if e != nil {
+		return nil, fmt.Errorf("wallet file '%s' in backup is invalid: %v", f.Label, e)
+	}
+	if prefix.Version() > Version {
+		return nil, fmt.Errorf("wallet file '%s' in backup is of version %v, while only up to version %v is supported",
+			f.Label, prefix.Version(), Version)
+	}
+	if prefix.Encrypted() {
+		if e := VerifyFile(f.Label, f.Data); e != nil {
+			return nil, fmt.Errorf("wallet file '%s' in backup is invalid: %v", f.Label, e)
+		}
+		return nil, nil
+	}
+	w, e := LoadFloatingWallet(bytes.NewReader(f.Data), f.Label, "")
+	if e != nil {
+		return nil, fmt.Errorf("wallet file '%s' in backup is invalid: %v", f.Label, e)
+	}
+	return w, nil
+}
+
+func backupHeader() []byte {
+	return append([]byte(BackupMagic), encoder.Serialize(BackupVersion)...)
+}
+
+// readBackup checks the header and checksum of a backup archive, and obtains
+// the encrypted data.
+func readBackup(r io.Reader) ([]byte, error) {
+	raw, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+	header := backupHeader()
+	if len(raw) < len(header)+len(cipher.SHA256{}) {
+		return nil, ErrInvalidBackup
+	}
+	if !bytes.Equal(raw[:len(BackupMagic)], []byte(BackupMagic)) {
+		return nil, errors.New("not a backup archive")
+	}
+	if !bytes.Equal(raw[:len(header)], header) {
+		return nil, errors.New("backup archive with unsupported version")
+	}
+	n := len(raw) - len(cipher.SHA256{})
+	if sum := cipher.SumSHA256(raw[:n]); !bytes.Equal(sum[:], raw[n:]) {
+		return nil, ErrInvalidBackup
+	}
+	return raw[len(header):n], nil
+}
+
+// decodeBackup deserializes a backup, where the encoder panics on some
+// malformed data.
+func decodeBackup(data []byte, backup *Backup) (e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("malformed backup archive: %v", r)
+		}
+	}()
+	return encoder.DeserializeRaw(data, backup)
+}
@@ -0,0 +1,122 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrPasswordRequired = errors.New("password is required for encrypted wallet")
+	ErrInvalidPassword  = errors.New("invalid password, or wallet file is corrupted")
+)
+
+const (
+	// SaltSize is the size of the scrypt salt in an encrypted wallet file.
+	SaltSize = 32
+
+	// KeySize is the size of the AES-256 key.
+	KeySize = 32
+
+	// headerSize is the size of the header of the encrypted data in a wallet
+	// file: the salt, the scrypt parameters (N, r and p) and the GCM nonce.
+	headerSize = SaltSize + 3*4 + 12
+)
+
+// ScryptParams are the parameters of the scrypt key derivation for encrypted
+// wallet files. They are stored in each file, so that they can be raised
+// without breaking existing files.
+type ScryptParams struct {
+	N int
+	R int
+	P int
+}
+
+// DefaultScryptParams are the parameters that wallet files are encrypted with.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// Verify checks that the parameters are within bounds, so that a wallet file
+// can not make opening it arbitrarily expensive.
+func (sp ScryptParams) Verify() error {
+	if sp.N <= 1 || sp.N > 1<<20 || sp.N&(sp.N-1) != 0 {
+		return fmt.Errorf("invalid scrypt N '%d'", sp.N)
+	}
+	if sp.R <= 0 || sp.R > 32 {
+		return fmt.Errorf("invalid scrypt r '%d'", sp.R)
+	}
+	if sp.P <= 0 || sp.P > 16 {
+		return fmt.Errorf("invalid scrypt p '%d'", sp.P)
+	}
+	return nil
+}
+
+// encryptData encrypts the data of a wallet file with AES-256-GCM, with a key
+// derived from the password with scrypt. The prefix of the file is
+// authenticated along with the header.
+func encryptData(prefix []byte, data []byte, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	sp := DefaultScryptParams
+	header := make([]byte, headerSize)
+	if _, e := rand.Read(header[:SaltSize]); e != nil {
+		return nil, e
+	}
+	binary.LittleEndian.PutUint32(header[SaltSize:], uint32(sp.N))
+	binary.LittleEndian.PutUint32(header[SaltSize+4:], uint32(sp.R))
+	binary.LittleEndian.PutUint32(header[SaltSize+8:], uint32(sp.P))
+	if _, e := rand.Read(header[SaltSize+12:]); e != nil {
+		return nil, e
+	}
+	gcm, e := newGCM(password, header[:SaltSize], sp)
+	if e != nil {
+		return nil, e
+	}
+	aad := append(append([]byte{}, prefix...), header...)
+	return gcm.Seal(header, header[SaltSize+12:], data, aad), nil
+}
+
+// decryptData decrypts the data of a wallet file from 'encryptData'.
+func decryptData(prefix []byte, raw []byte, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	if len(raw) < headerSize {
+		return nil, ErrFileSize
+	}
+	header := raw[:headerSize]
+	sp := ScryptParams{
+		N: int(binary.LittleEndian.Uint32(header[SaltSize:])),
+		R: int(binary.LittleEndian.Uint32(header[SaltSize+4:])),
+		P: int(binary.LittleEndian.Uint32(header[SaltSize+8:])),
+	}
+	if e := sp.Verify(); e != nil {
+		return nil, e
+	}
+	gcm, e := newGCM(password, header[:SaltSize], sp)
+	if e != nil {
+		return nil, e
+	}
+	aad := append(append([]byte{}, prefix...), header...)
+	data, e := gcm.Open(nil, header[SaltSize+12:], raw[headerSize:], aad)
+	if e != nil {
+		return nil, ErrInvalidPassword
+	}
+	return data, nil
+}
+
+func newGCM(password string, salt []byte, sp ScryptParams) (cipher.AEAD, error) {
+	key, e := scrypt.Key([]byte(password), salt, sp.N, sp.R, sp.P, KeySize)
+	if e != nil {
+		return nil, e
+	}
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+	return cipher.NewGCM(block)
+}
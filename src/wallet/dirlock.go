@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LockFileName is the name of the file in the root directory that managers
+// hold an advisory lock on (flock, or LockFileEx on Windows), so that
+// processes on the same root directory do not corrupt each other's files.
+const LockFileName = ".lock"
+
+var ErrDirLocked = errors.New("wallet directory is in use by another process")
+
+// dirLock is the lock on a root directory, shared by the managers of this
+// process.
+type dirLock struct {
+	f    *os.File
+	refs int
+}
+
+var (
+	dirLocksMux sync.Mutex
+	dirLocks    = make(map[string]*dirLock)
+)
+
+// acquireDirLock locks the directory, or fails with 'ErrDirLocked' when another
+// process holds its lock. The returned function releases it.
+func acquireDirLock(dir string) (func(), error) {
+	dirLocksMux.Lock()
+	defer dirLocksMux.Unlock()
+
+	fPath := filepath.Join(dir, LockFileName)
+	l, ok := dirLocks[fPath]
+	if !ok {
+		f, e := os.OpenFile(fPath, os.O_RDWR|os.O_CREATE, os.FileMode(0600))
+		if e != nil {
+			return nil, e
+		}
+		if e := lockFile(f); e != nil {
+			f.Close()
+			if e == errLockHeld {
+				return nil, fmt.Errorf("%w: '%s'", ErrDirLocked, dir)
+			}
+			return nil, e
+		}
+		l = &dirLock{f: f}
+		dirLocks[fPath] = l
+	}
+	l.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			dirLocksMux.Lock()
+			defer dirLocksMux.Unlock()
+			if l.refs--; l.refs == 0 {
+				unlockFile(l.f)
+				l.f.Close()
+				delete(dirLocks, fPath)
+			}
+		})
+	}, nil
+}
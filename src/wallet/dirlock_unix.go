@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package wallet
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+var errLockHeld = errors.New("lock is held")
+
+func lockFile(f *os.File) error {
+	e := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if e == unix.EWOULDBLOCK {
+		return errLockHeld
+	}
+	return e
+}
+
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,51 @@
+//go:build windows
+// +build windows
+
+package wallet
+
+import (
+	"errors"
+	"golang.org/x/sys/windows"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var errLockHeld = errors.New("lock is held")
+
+// The vendored 'windows' package is without LockFileEx, which is in kernel32.
+var (
+	kernel32         = windows.NewLazySystemDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33) // ERROR_LOCK_VIOLATION
+)
+
+func lockFile(f *os.File) error {
+	var ol windows.Overlapped
+	r, _, e := procLockFileEx.Call(f.Fd(),
+		lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)))
+	if r != 0 {
+		return nil
+	}
+	if e == errorLockViolation {
+		return errLockHeld
+	}
+	return e
+}
+
+func unlockFile(f *os.File) error {
+	var ol windows.Overlapped
+	r, _, e := procUnlockFileEx.Call(f.Fd(), 0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)))
+	if r != 0 {
+		return nil
+	}
+	return e
+}
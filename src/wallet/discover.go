@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+const (
+	// DefaultGapLimit is the number of consecutive unused addresses after
+	// which address discovery stops (see 'Manager.DiscoverAddresses'), as in
+	// HD wallets.
+	DefaultGapLimit = 20
+
+	// MaxGapLimit is the maximum gap limit for address discovery.
+	MaxGapLimit = 1000
+)
+
+// DiscoverAddresses scans the addresses derived from the seed of the wallet of
+// specified label (and of its accounts) against the state of the chain, and
+// ensures the entries up to the last address that is used. Scanning stops
+// after 'gapLimit' consecutive unused addresses ('DefaultGapLimit' if 0),
+// so addresses that are used after a larger gap are not discovered. An
+// address is used if it holds kitties, or is in any transaction. This is for
+// restoring wallets from their seeds, as the file that kept the number of
+// entries is gone. Entries are never removed.
+func (m *Manager) DiscoverAddresses(bc *iko.BlockChain, label string, gapLimit int) (*FloatingWallet, error) {
+	switch {
+	case gapLimit == 0:
+		gapLimit = DefaultGapLimit
+	case gapLimit < 0 || gapLimit > MaxGapLimit:
+		return nil, fmt.Errorf("gap limit needs to be between 1 and %d", MaxGapLimit)
+	}
+
+	// The chain is scanned without the lock, with a copy of the seed.
+	seed, accounts, e := m.discoverTargets(label)
+	if e != nil {
+		return nil, e
+	}
+	count := discoverCount(bc, seed, gapLimit)
+	counts := make(map[string]int, len(accounts))
+	for _, a := range accounts {
+		counts[a.Name] = discoverCount(bc, AccountSeed(seed, a.Index), gapLimit)
+	}
+
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	if w.Meta.Seed != seed {
+		return nil, fmt.Errorf("seed of wallet '%s' changed while discovering", label)
+	}
+	if e := w.EnsureEntries(count); e != nil {
+		return nil, e
+	}
+	for name, n := range counts {
+		a, e := w.Account(name)
+		if e != nil {
+			continue
+		}
+		for len(a.Entries) < n {
+			if _, e := w.NewAccountAddress(name); e != nil {
+				return nil, e
+			}
+		}
+	}
+	if !w.Meta.Saved {
+		if e := w.Save(); e != nil {
+			return nil, e
+		}
+	}
+	return w.ToFloating(), nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// discoverTargets obtains the seed and accounts of an unlocked wallet that is
+// not watch-only.
+func (m *Manager) discoverTargets(label string) (string, []Account, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return "", nil, e
+	}
+	if w.IsWatchOnly() {
+		return "", nil, ErrWatchOnly
+	}
+	accounts := make([]Account, len(w.Accounts))
+	for i, a := range w.Accounts {
+		accounts[i] = Account{Name: a.Name, Index: a.Index}
+	}
+	return w.Meta.Seed, accounts, nil
+}
+
+// discoverCount obtains the number of entries derived from the seed (as with
+// 'DeriveSecKey') up to, and including, the last used address before a gap of
+// 'gapLimit' unused addresses.
+func discoverCount(bc *iko.BlockChain, seed string, gapLimit int) int {
+	next := []byte(seed)
+	count := 0
+	for i, gap := 0, 0; gap < gapLimit; i++ {
+		var sk cipher.SecKey
+		next, _, sk = cipher.DeterministicKeyPairIterator(next)
+		state := bc.GetAddressState(cipher.AddressFromSecKey(sk))
+		if len(state.Kitties) != 0 || len(state.Transactions) != 0 {
+			count, gap = i+1, 0
+		} else {
+			gap++
+		}
+	}
+	return count
+}
@@ -0,0 +1,164 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sort"
+	"strings"
+)
+
+var ErrDuplicateAddress = errors.New("address already belongs to another wallet")
+
+// DuplicatePolicy determines what happens to addresses in imports (and
+// restores) that already belong to other wallets.
+type DuplicatePolicy uint8
+
+const (
+	// DuplicateReject fails with a '*DuplicateError' (which is
+	// 'ErrDuplicateAddress'), importing nothing.
+	DuplicateReject DuplicatePolicy = iota
+
+	// DuplicateMerge keeps the addresses of the other wallets, and skips the
+	// import (or the restore of the wallet).
+	DuplicateMerge
+
+	// DuplicateAllow imports the addresses anyway, so they belong to more than
+	// one wallet.
+	DuplicateAllow
+)
+
+// DuplicateAddress is an address that belongs to more than one wallet.
+type DuplicateAddress struct {
+	Address string   `json:"address"`
+	Labels  []string `json:"labels"` // Of the other wallets with the address.
+}
+
+// DuplicateError is for addresses that already belong to other wallets.
+type DuplicateError struct {
+	Label      string             // Of the wallet being imported into (or restored).
+	Duplicates []DuplicateAddress // Sorted by address.
+}
+
+func (e *DuplicateError) Error() string {
+	parts := make([]string, len(e.Duplicates))
+	for i, d := range e.Duplicates {
+		parts[i] = fmt.Sprintf("'%s' in %s", d.Address, strings.Join(d.Labels, ", "))
+	}
+	return fmt.Sprintf("%v: wallet '%s' has addresses %s", ErrDuplicateAddress,
+		e.Label, strings.Join(parts, "; "))
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicateAddress
+}
+
+// ImportResult is the result of importing a key into a wallet.
+type ImportResult struct {
+	Wallet     *FloatingWallet    `json:"wallet"`
+	Imported   bool               `json:"imported"`   // False if merged as a duplicate.
+	Duplicates []DuplicateAddress `json:"duplicates"` // Of the imported key.
+}
+
+// ImportWalletKeyWithPolicy imports a hex encoded secret key into the wallet
+// of specified label (see 'Wallet.ImportKey'), where the policy determines
+// what happens if the address of the key belongs to another wallet. Only addresses
+// of unlocked (and watch-only) wallets are known.
+func (m *Manager) ImportWalletKeyWithPolicy(label, hexSecKey, keyLabel string, policy DuplicatePolicy) (*ImportResult, error) {
+	if policy > DuplicateAllow {
+		return nil, fmt.Errorf("invalid duplicate policy '%d'", policy)
+	}
+
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	sk, e := cipher.SecKeyFromHex(strings.TrimSpace(hexSecKey))
+	if e != nil {
+		return nil, fmt.Errorf("invalid secret key: %v", e)
+	}
+	if e := sk.Verify(); e != nil {
+		return nil, fmt.Errorf("invalid secret key: %v", e)
+	}
+	res := &ImportResult{
+		Duplicates: m.duplicatesOf(label, []cipher.Address{cipher.AddressFromSecKey(sk)}),
+	}
+	switch {
+	case len(res.Duplicates) == 0 || policy == DuplicateAllow:
+		if _, e := w.ImportKey(hexSecKey, keyLabel); e != nil {
+			return nil, e
+		}
+		res.Imported = true
+	case policy == DuplicateReject:
+		return nil, &DuplicateError{Label: label, Duplicates: res.Duplicates}
+	}
+	res.Wallet = w.ToFloating()
+	return res, nil
+}
+
+// FindDuplicates obtains the addresses that belong to more than one of the
+// unlocked (and watch-only) wallets.
+func (m *Manager) FindDuplicates() []DuplicateAddress {
+	defer m.lock()()
+
+	index := m.addressIndex("")
+	out := make([]DuplicateAddress, 0)
+	for addr, labels := range index {
+		if len(labels) > 1 {
+			out = append(out, DuplicateAddress{Address: addr.String(), Labels: labels})
+		}
+	}
+	sortDuplicates(out)
+	return out
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// duplicatesOf obtains the addresses that belong to loaded wallets other than the
+// wallet with the label.
+func (m *Manager) duplicatesOf(label string, addrs []cipher.Address) []DuplicateAddress {
+	index := m.addressIndex(label)
+	out := make([]DuplicateAddress, 0)
+	seen := make(map[cipher.Address]bool, len(addrs))
+	for _, addr := range addrs {
+		if labels, ok := index[addr]; ok && !seen[addr] {
+			out = append(out, DuplicateAddress{Address: addr.String(), Labels: labels})
+		}
+		seen[addr] = true
+	}
+	sortDuplicates(out)
+	return out
+}
+
+// addressIndex obtains the labels of the loaded wallets (other than the
+// excluded label) for every address, in order of the labels.
+func (m *Manager) addressIndex(exclude string) map[cipher.Address][]string {
+	index := make(map[cipher.Address][]string)
+	for _, label := range m.labels {
+		w := m.wallets[label]
+		if w == nil || label == exclude {
+			continue
+		}
+		seen := make(map[cipher.Address]bool)
+		for _, entry := range w.publicEntries() {
+			if !seen[entry.Address] {
+				index[entry.Address] = append(index[entry.Address], label)
+				seen[entry.Address] = true
+			}
+		}
+	}
+	return index
+}
+
+func sortDuplicates(ds []DuplicateAddress) {
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].Address < ds[j].Address
+	})
+}
@@ -7,9 +7,10 @@ import (
 
 // FloatingEntry represents a readable wallet entry.
 type FloatingEntry struct {
+	Label   string `json:"label,omitempty"` // Only for imported entries.
 	Address string `json:"address"`
-	PubKey  string `json:"public_key"`
-	SecKey  string `json:"secret_key"`
+	PubKey  string `json:"public_key,omitempty"`
+	SecKey  string `json:"secret_key,omitempty"`
 }
 
 // Entry represents a wallet entry.
@@ -30,17 +31,40 @@ func NewEntry(sk cipher.SecKey) (*Entry, error) {
 	}, nil
 }
 
+// NewWatchEntry creates an entry with an address alone, which is watched
+// (without the secret key) by a watch-only wallet.
+func NewWatchEntry(addr cipher.Address) *Entry {
+	return &Entry{Address: addr}
+}
+
+// IsWatchOnly determines whether the entry is without a secret key.
+func (we *Entry) IsWatchOnly() bool {
+	return we.SecKey == (cipher.SecKey{})
+}
+
 func (we *Entry) ToFloating() *FloatingEntry {
-	return &FloatingEntry{
+	fe := &FloatingEntry{
 		Address: we.Address.String(),
-		PubKey:  we.PubKey.Hex(),
-		SecKey:  we.SecKey.Hex(),
 	}
+	if we.PubKey != (cipher.PubKey{}) {
+		fe.PubKey = we.PubKey.Hex()
+	}
+	if !we.IsWatchOnly() {
+		fe.SecKey = we.SecKey.Hex()
+	}
+	return fe
 }
 
 // Verify checks that the public key is derivable from the secret key,
-// and that the public key is associated with the address
+// and that the public key is associated with the address. Watch-only entries
+// need not have a public key.
 func (we *Entry) Verify() error {
+	if we.IsWatchOnly() {
+		if we.PubKey == (cipher.PubKey{}) {
+			return nil
+		}
+		return we.VerifyPublic()
+	}
 	if cipher.PubKeyFromSecKey(we.SecKey) != we.PubKey {
 		return errors.New("invalid public key for secret key")
 	}
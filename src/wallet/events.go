@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// EventKind determines the kind of a wallet event.
+type EventKind string
+
+const (
+	// EventKittyReceived is for an address of a wallet that gains a kitty
+	// (by a transfer, or its creation).
+	EventKittyReceived EventKind = "kitty_received"
+
+	// EventKittySent is for an address of a wallet that loses a kitty.
+	EventKittySent EventKind = "kitty_sent"
+
+	// DefaultEventBufferSize is the buffer size of event subscriptions for the
+	// chain watch (see 'Manager.WatchChain').
+	DefaultEventBufferSize = 64
+)
+
+// Event notifies that an address of a wallet gained or lost a kitty, by a
+// transaction that is accepted into the chain.
+type Event struct {
+	Kind         EventKind   `json:"kind"`
+	Label        string      `json:"label"`   // Of the wallet.
+	Address      string      `json:"address"` // Of the wallet.
+	KittyID      iko.KittyID `json:"kitty_id"`
+	Counterparty string      `json:"counterparty,omitempty"` // Empty for created kitties.
+	TxHash       string      `json:"tx_hash"`
+	TxSeq        uint64      `json:"tx_seq"`
+	TS           int64       `json:"timestamp"` // Of the transaction.
+}
+
+func (ev Event) String() string {
+	switch ev.Kind {
+	case EventKittyReceived:
+		return fmt.Sprintf("address %s received kitty %d", ev.Address, ev.KittyID)
+	case EventKittySent:
+		return fmt.Sprintf("address %s sent kitty %d", ev.Address, ev.KittyID)
+	default:
+		return fmt.Sprintf("address %s: %s (kitty %d)", ev.Address, ev.Kind, ev.KittyID)
+	}
+}
+
+// EventSubscription receives the events from a manager (see
+// 'Manager.SubscribeEvents').
+type EventSubscription struct {
+	m *Manager
+	c chan Event
+}
+
+// C obtains the channel where events are received. The channel is closed when
+// the subscription (or the manager) is closed.
+func (s *EventSubscription) C() <-chan Event {
+	return s.c
+}
+
+// Close unsubscribes from the manager.
+func (s *EventSubscription) Close() {
+	s.m.eventMux.Lock()
+	defer s.m.eventMux.Unlock()
+
+	if _, ok := s.m.eventSubs[s]; ok {
+		delete(s.m.eventSubs, s)
+		close(s.c)
+	}
+}
+
+// SubscribeEvents creates a subscription to the events of the wallets, with the
+// specified buffer size. As with the tx hub, broadcasting never blocks, so a
+// subscriber that falls behind by more than the buffer size misses events.
+func (m *Manager) SubscribeEvents(bufferSize int) *EventSubscription {
+	m.eventMux.Lock()
+	defer m.eventMux.Unlock()
+
+	sub := &EventSubscription{
+		m: m,
+		c: make(chan Event, bufferSize),
+	}
+	m.eventSubs[sub] = struct{}{}
+	return sub
+}
+
+// WatchChain subscribes to the tx hub of the chain, and emits events for the
+// changes of ownership of kitties at the addresses of the wallets. Only
+// addresses of unlocked (and watch-only) wallets are known, and external
+// signers are not watched, as obtaining their addresses may need a device.
+// Watching again replaces the previous watch.
+func (m *Manager) WatchChain(bc *iko.BlockChain) {
+	m.eventMux.Lock()
+	defer m.eventMux.Unlock()
+
+	if m.chainSub != nil {
+		m.chainSub.Close()
+	}
+	sub := bc.Subscribe(DefaultEventBufferSize)
+	m.chainSub = sub
+	go m.watchChain(bc, sub)
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func (m *Manager) watchChain(bc *iko.BlockChain, sub *iko.TxSubscription) {
+	for tx := range sub.C() {
+		for _, ev := range m.txEvents(bc.OwnershipChanges(tx)) {
+			m.broadcast(ev)
+		}
+	}
+}
+
+// txEvents obtains the events for changes of ownership, for the wallets that
+// the addresses belong to.
+func (m *Manager) txEvents(changes []iko.OwnershipChange) []Event {
+	defer m.lock()()
+
+	var out []Event
+	for _, c := range changes {
+		if c.From != (cipher.Address{}) {
+			if label, ok := m.labelOfAddress(c.From); ok {
+				out = append(out, changeEvent(EventKittySent, label, c, c.From, c.To))
+			}
+		}
+		if label, ok := m.labelOfAddress(c.To); ok {
+			out = append(out, changeEvent(EventKittyReceived, label, c, c.To, c.From))
+		}
+	}
+	return out
+}
+
+// changeEvent obtains the event for a change of ownership, at the address of
+// the wallet with the label.
+func changeEvent(kind EventKind, label string, c iko.OwnershipChange, addr, counterparty cipher.Address) Event {
+	ev := Event{
+		Kind:    kind,
+		Label:   label,
+		Address: addr.String(),
+		KittyID: c.KittyID,
+		TxHash:  c.Tx.Hash.Hex(),
+		TxSeq:   c.Tx.Seq,
+		TS:      c.Tx.TS,
+	}
+	if counterparty != (cipher.Address{}) {
+		ev.Counterparty = counterparty.String()
+	}
+	return ev
+}
+
+// labelOfAddress obtains the label of the loaded wallet that has the address.
+func (m *Manager) labelOfAddress(addr cipher.Address) (string, bool) {
+	for _, label := range m.labels {
+		if w := m.wallets[label]; w != nil && w.HasAddress(addr) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+func (m *Manager) broadcast(ev Event) {
+	m.eventMux.RLock()
+	defer m.eventMux.RUnlock()
+
+	for sub := range m.eventSubs {
+		select {
+		case sub.c <- ev:
+		default:
+		}
+	}
+}
+
+// closeEvents stops the chain watch, and closes the event subscriptions.
+func (m *Manager) closeEvents() {
+	m.eventMux.Lock()
+	defer m.eventMux.Unlock()
+
+	if m.chainSub != nil {
+		m.chainSub.Close()
+		m.chainSub = nil
+	}
+	for sub := range m.eventSubs {
+		delete(m.eventSubs, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,222 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"io"
+	"strconv"
+)
+
+// ExportFormat determines the format of a wallet export.
+type ExportFormat string
+
+const (
+	// ExportJSON exports the wallets as a JSON array of 'ExportEntry' objects.
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV exports the wallets as CSV with a header row of
+	// "label,name,index,address,public_key,imported,key_label,account"
+	// (followed by ",secret_key" when secret keys are exported).
+	ExportCSV ExportFormat = "csv"
+
+	// ExportSecretKeysConfirmation needs to be given as the confirmation for
+	// exports with secret keys.
+	ExportSecretKeysConfirmation = "export secret keys"
+)
+
+var (
+	ErrExportNotConfirmed = fmt.Errorf(
+		"exporting secret keys needs the confirmation '%s'", ExportSecretKeysConfirmation)
+	ErrExportPublicOnly = errors.New("public-only exports can not have secret keys")
+)
+
+// ExportOptions are the options for a wallet export.
+type ExportOptions struct {
+	Format     ExportFormat
+	SecretKeys bool   // Whether to export secret keys (needs 'Confirm').
+	Confirm    string // Needs to be 'ExportSecretKeysConfirmation' to export secret keys.
+
+	// PublicOnly exports addresses and public keys alone (such as for
+	// accounting, or watch-only wallets elsewhere). The entries of such
+	// exports are built from the addresses and public keys of the wallets,
+	// rather than from their entries, so no secret key is ever read into the
+	// export.
+	PublicOnly bool
+}
+
+// ExportEntry is a row of an export, for an address of a wallet. The index is
+// into the entries derived from the seed, the imported entries, or the
+// entries of the account.
+type ExportEntry struct {
+	Label    string `json:"label"`
+	Name     string `json:"name"`
+	Index    int    `json:"index"`
+	Address  string `json:"address"`
+	PubKey   string `json:"public_key"`
+	Imported bool   `json:"imported"`
+	KeyLabel string `json:"key_label,omitempty"` // Label of an imported key.
+	Account  string `json:"account,omitempty"`   // Name of the account, if not the default account.
+	SecKey   string `json:"secret_key,omitempty"`
+}
+
+// Export writes the addresses of the wallets with the labels to 'w', for
+// migrating to other tools. Wallets need to be unlocked. If no labels are
+// given, every unlocked wallet is exported.
+func (m *Manager) Export(w io.Writer, labels []string, opts ExportOptions) error {
+	if opts.PublicOnly && opts.SecretKeys {
+		return ErrExportPublicOnly
+	}
+	if opts.SecretKeys && opts.Confirm != ExportSecretKeysConfirmation {
+		return ErrExportNotConfirmed
+	}
+
+	defer m.lock()()
+
+	var wallets []*Wallet
+	if len(labels) == 0 {
+		for _, label := range m.labels {
+			if w := m.wallets[label]; w != nil {
+				wallets = append(wallets, w)
+			}
+		}
+	} else {
+		for _, label := range labels {
+			w, e := m.getWallet(label)
+			if e != nil {
+				return fmt.Errorf("%w: '%s'", e, label)
+			}
+			wallets = append(wallets, w)
+		}
+	}
+
+	var entries []ExportEntry
+	for _, w := range wallets {
+		if opts.SecretKeys {
+			if e := w.checkHold(); e != nil {
+				return fmt.Errorf("%w: '%s'", e, w.Meta.Label)
+			}
+		}
+		if opts.PublicOnly {
+			entries = append(entries, w.publicExport()...)
+			continue
+		}
+		fw := w.ToFloating()
+		add := func(i int, fe *FloatingEntry, imported bool, account string) {
+			ee := ExportEntry{
+				Label:    w.Meta.Label,
+				Name:     w.Meta.Name,
+				Index:    i,
+				Address:  fe.Address,
+				PubKey:   fe.PubKey,
+				Imported: imported,
+				KeyLabel: fe.Label,
+				Account:  account,
+			}
+			if opts.SecretKeys {
+				ee.SecKey = fe.SecKey
+			}
+			entries = append(entries, ee)
+		}
+		for i, fe := range fw.Entries {
+			add(i, fe, false, "")
+		}
+		for i, fe := range fw.Imported {
+			add(i, fe, true, "")
+		}
+		for _, fa := range fw.Accounts {
+			for i, fe := range fa.Entries {
+				add(i, fe, false, fa.Name)
+			}
+		}
+	}
+	if e := WriteExport(w, opts.Format, entries, opts.SecretKeys); e != nil {
+		return e
+	}
+	detail := "addresses"
+	switch {
+	case opts.SecretKeys:
+		detail = "secret keys"
+	case opts.PublicOnly:
+		detail = "public keys"
+	}
+	for _, w := range wallets {
+		m.audit(w.Meta.Label, AuditExport, fmt.Sprintf("%s as %s", detail, opts.Format))
+	}
+	return nil
+}
+
+// publicExport obtains the export entries of the wallet from their addresses and
+// public keys alone.
+func (w *Wallet) publicExport() []ExportEntry {
+	var out []ExportEntry
+	add := func(i int, entry Entry, imported bool, keyLabel, account string) {
+		ee := ExportEntry{
+			Label:    w.Meta.Label,
+			Name:     w.Meta.Name,
+			Index:    i,
+			Address:  entry.Address.String(),
+			Imported: imported,
+			KeyLabel: keyLabel,
+			Account:  account,
+		}
+		if entry.PubKey != (cipher.PubKey{}) {
+			ee.PubKey = entry.PubKey.Hex()
+		}
+		out = append(out, ee)
+	}
+	for i, entry := range w.Entries {
+		add(i, entry, false, "", "")
+	}
+	for i, imported := range w.Imported {
+		add(i, imported.Entry, true, imported.Label, "")
+	}
+	for _, a := range w.Accounts {
+		for i, entry := range a.Entries {
+			add(i, entry, false, "", a.Name)
+		}
+	}
+	return out
+}
+
+// WriteExport writes the export entries to 'w' in the specified format.
+func WriteExport(w io.Writer, format ExportFormat, entries []ExportEntry, secretKeys bool) error {
+	switch format {
+	case ExportJSON:
+		if entries == nil {
+			entries = []ExportEntry{}
+		}
+		return json.NewEncoder(w).Encode(entries)
+
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		header := []string{"label", "name", "index", "address", "public_key", "imported", "key_label", "account"}
+		if secretKeys {
+			header = append(header, "secret_key")
+		}
+		cw.Write(header)
+		for _, entry := range entries {
+			row := []string{
+				entry.Label,
+				entry.Name,
+				strconv.Itoa(entry.Index),
+				entry.Address,
+				entry.PubKey,
+				strconv.FormatBool(entry.Imported),
+				entry.KeyLabel,
+				entry.Account,
+			}
+			if secretKeys {
+				row = append(row, entry.SecKey)
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return fmt.Errorf("invalid export format '%s'", format)
+	}
+}
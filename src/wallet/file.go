@@ -1,31 +1,101 @@
 package wallet
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"gopkg.in/sirupsen/logrus.v1"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
+const (
+	// MaxLabelSize is the maximum size of a wallet label.
+	MaxLabelSize = 64
+
+	// RootDirEnv is the environment variable for the root directory, as read
+	// by 'cmd/iko'.
+	RootDirEnv = "KITTYCASH_WALLET_DIR"
+)
+
 // This holds the root directory.
 var (
 	rootDir string
 	log     = logrus.New()
 )
 
-// SetRootDir sets the root directory.
+// SetRootDir sets the root directory, and creates it (only accessible by the
+// user) if it does not exist. Existing directories that are accessible by
+// others are used as they are, with a warning.
 func SetRootDir(r string) error {
 	var e error
 	if rootDir, e = filepath.Abs(r); e != nil {
 		return e
 	}
-	if e = os.MkdirAll(rootDir, os.FileMode(0700)); e != nil {
+	return ensureRootDir()
+}
+
+// DefaultRootDir obtains the root directory in the data directory of the
+// user, for the OS: "%LOCALAPPDATA%\kittycash\wallet" on Windows,
+// "~/Library/Application Support/kittycash/wallet" on macOS, and
+// "$XDG_DATA_HOME/kittycash/wallet" (or "~/.local/share/kittycash/wallet")
+// elsewhere.
+func DefaultRootDir() (string, error) {
+	var base string
+	switch runtime.GOOS {
+	case "windows":
+		if base = os.Getenv("LOCALAPPDATA"); base == "" {
+			return "", errors.New("%LOCALAPPDATA% is not defined")
+		}
+	case "darwin":
+		home, e := os.UserHomeDir()
+		if e != nil {
+			return "", e
+		}
+		base = filepath.Join(home, "Library", "Application Support")
+	default:
+		if base = os.Getenv("XDG_DATA_HOME"); base == "" {
+			home, e := os.UserHomeDir()
+			if e != nil {
+				return "", e
+			}
+			base = filepath.Join(home, ".local", "share")
+		}
+	}
+	return filepath.Join(base, "kittycash", "wallet"), nil
+}
+
+func ensureRootDir() error {
+	if e := store.MkdirAll(rootDir); e != nil {
+		return e
+	}
+	info, e := store.Stat(rootDir)
+	if e != nil {
 		return e
 	}
+	if !info.IsDir() {
+		return fmt.Errorf("wallet root '%s' is not a directory", rootDir)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		log.Warningf("wallet directory `%s` is accessible by other users (mode %v)",
+			rootDir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// VerifyLabel checks that a label can name a wallet file, directly under the
+// root directory.
+func VerifyLabel(label string) error {
+	if label == "" || len(label) > MaxLabelSize {
+		return errors.New("invalid label")
+	}
+	if label[0] == '.' || strings.ContainsAny(label, `/\`) {
+		return fmt.Errorf("invalid label '%s'", label)
+	}
 	return nil
 }
 
@@ -39,7 +109,7 @@ func LabelPath(label string) string {
 }
 
 func ListLabels() ([]string, error) {
-	list, e := ioutil.ReadDir(rootDir)
+	list, e := store.ReadDir(rootDir)
 	if e != nil {
 		return nil, e
 	}
@@ -60,7 +130,7 @@ func ListLabels() ([]string, error) {
 type LabelAction func(f io.Reader, label, fPath string, prefix Prefix)
 
 func RangeLabels(action LabelAction) error {
-	list, e := ioutil.ReadDir(rootDir)
+	list, e := store.ReadDir(rootDir)
 	if e != nil {
 		return e
 	}
@@ -75,15 +145,19 @@ func RangeLabels(action LabelAction) error {
 		label := strings.TrimSuffix(name, string(FileExt))
 		fPath := LabelPath(label)
 
-		f, e := os.Open(fPath)
+		raw, keystore, e := readWalletFile(fPath)
+		if e != nil && keystore != "" {
+			log.WithError(e).Warningf("skipped wallet file `%s` in keystore '%s'", label, keystore)
+			continue
+		}
 		if e != nil {
 			return e
 		}
 
+		f := bytes.NewReader(raw)
 		var prefix Prefix
 		f.Read(prefix[:])
 		action(f, label, fPath, prefix)
-		f.Close()
 	}
 	return nil
 }
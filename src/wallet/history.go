@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sort"
+)
+
+// DefaultHistoryPageSize is the number of entries in a page of 'History', for
+// a page size of 0.
+const DefaultHistoryPageSize = 50
+
+// HistoryPage is a page of the history of a wallet (see 'Manager.History').
+type HistoryPage struct {
+	Label          string  `json:"label"`
+	Entries        []Event `json:"entries"`
+	Total          int     `json:"total"`
+	TotalPageCount uint64  `json:"total_page_count"`
+}
+
+// History obtains a page of the sends and receives of kitties by the addresses
+// of the wallet (or external signer) of specified label, from the index of
+// addresses of the chain, newest first. Entries are as in the events of the
+// chain watch (see 'WatchChain'), so a transfer between two addresses of the
+// wallet is both a send and a receive. Pages have the page size
+// ('DefaultHistoryPageSize' if 0), from 0. Encrypted wallets need to be
+// unlocked, as their addresses are unknown while locked.
+func (m *Manager) History(bc *iko.BlockChain, label string, page, pageSize uint64) (*HistoryPage, error) {
+	if pageSize == 0 {
+		pageSize = DefaultHistoryPageSize
+	}
+	s, e := m.Signer(label)
+	if e != nil {
+		return nil, e
+	}
+	entries, e := s.Entries()
+	if e != nil {
+		return nil, e
+	}
+	own := make(map[cipher.Address]bool, len(entries))
+	for _, entry := range entries {
+		own[entry.Address] = true
+	}
+
+	var (
+		out  = make([]Event, 0)
+		seen = make(map[iko.TxHash]bool)
+	)
+	for addr := range own {
+		for _, txHash := range bc.GetAddressState(addr).Transactions {
+			if seen[txHash] {
+				continue
+			}
+			seen[txHash] = true
+			tx, e := bc.GetTxOfHash(txHash)
+			if e != nil {
+				return nil, e
+			}
+			for _, c := range bc.OwnershipChanges(&tx) {
+				if own[c.From] {
+					out = append(out, changeEvent(EventKittySent, label, c, c.From, c.To))
+				}
+				if own[c.To] {
+					out = append(out, changeEvent(EventKittyReceived, label, c, c.To, c.From))
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case a.TS != b.TS:
+			return a.TS > b.TS
+		case a.TxSeq != b.TxSeq:
+			return a.TxSeq > b.TxSeq
+		case a.KittyID != b.KittyID:
+			return a.KittyID < b.KittyID
+		default:
+			return a.Kind == EventKittySent && b.Kind != EventKittySent
+		}
+	})
+
+	n := uint64(len(out))
+	res := &HistoryPage{
+		Label:          label,
+		Entries:        []Event{},
+		Total:          len(out),
+		TotalPageCount: (n + pageSize - 1) / pageSize,
+	}
+	if start := page * pageSize; page < res.TotalPageCount {
+		end := start + pageSize
+		if end > n {
+			end = n
+		}
+		res.Entries = out[start:end]
+	}
+	return res, nil
+}
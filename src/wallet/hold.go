@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrWalletHeld      = errors.New("wallet is on hold")
+	ErrHoldUnencrypted = errors.New("only encrypted wallets can be put on hold")
+)
+
+// Held determines whether the wallet is on a hold that is not yet due. A held
+// wallet can be unlocked to list its addresses, but the node signs nothing
+// with it, and its seed and secret keys are neither obtainable, exported nor
+// split (see 'SplitSeed') until the hold is due, as for vesting or gifts.
+//
+// A hold is a policy of the node, not a time-lock on the keys: the date is
+// stored in the encrypted data of the wallet file, so it can not be removed
+// or brought forward without the password, but it is checked against the
+// clock of the node. Holders of the password can still decrypt the file with
+// other tools, or run the node with a clock that is set ahead.
+func (w *Wallet) Held() bool {
+	return w.Meta.HoldUntil != 0 && time.Now().UnixNano() < w.Meta.HoldUntil
+}
+
+// SetWalletHold puts the (unlocked, encrypted) wallet of specified label on
+// hold until the time (see 'Wallet.Held'), and saves it. Holds can only be
+// extended, so an existing hold can not be removed or brought forward, even
+// with the password.
+func (m *Manager) SetWalletHold(label string, until time.Time) (*FloatingWallet, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	switch {
+	case w.IsWatchOnly():
+		return nil, ErrWatchOnly
+	case !w.Meta.Encrypted:
+		return nil, ErrHoldUnencrypted
+	case !until.After(time.Now()):
+		return nil, errors.New("hold needs to end in the future")
+	case until.UnixNano() < w.Meta.HoldUntil:
+		return nil, fmt.Errorf("hold can only be extended beyond '%s'",
+			time.Unix(0, w.Meta.HoldUntil).UTC().Format(time.RFC3339))
+	}
+	old := w.Meta.HoldUntil
+	w.Meta.HoldUntil = until.UnixNano()
+	if e := w.Save(); e != nil {
+		w.Meta.HoldUntil = old
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// checkHold fails with 'ErrWalletHeld' (and the date it is due) if the
+// wallet is on hold.
+func (w *Wallet) checkHold() error {
+	if !w.Held() {
+		return nil
+	}
+	return fmt.Errorf("%w until '%s'", ErrWalletHeld,
+		time.Unix(0, w.Meta.HoldUntil).UTC().Format(time.RFC3339))
+}
+
+// redactSecrets removes the seed and secret keys of the floating wallet.
+func (fw *FloatingWallet) redactSecrets() {
+	fw.Meta.Seed = ""
+	for _, fe := range fw.Entries {
+		fe.SecKey = ""
+	}
+	for _, fe := range fw.Imported {
+		fe.SecKey = ""
+	}
+	for _, fa := range fw.Accounts {
+		for _, fe := range fa.Entries {
+			fe.SecKey = ""
+		}
+	}
+}
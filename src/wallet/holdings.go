@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"github.com/kittycash/wallet/src/iko"
+	"sort"
+	"time"
+)
+
+// AddressHoldings are the kitties at an address of a wallet.
+type AddressHoldings struct {
+	Address string       `json:"address"`
+	Kitties iko.KittyIDs `json:"kitties"`
+}
+
+// Holdings are the kitties at the addresses of a wallet, as of a scan of the
+// chain (see 'Manager.ScanHoldings').
+type Holdings struct {
+	Label     string            `json:"label"`
+	Seq       uint64            `json:"seq"`       // Of the head transaction at the scan.
+	TS        int64             `json:"timestamp"` // Of the scan.
+	Total     int               `json:"total"`     // Number of kitties.
+	Addresses []AddressHoldings `json:"addresses"` // Only for addresses that hold kitties.
+}
+
+// ScanHoldings obtains the kitties at the addresses of every unlocked (and
+// watch-only) wallet and external signer from the state of the chain, and
+// caches them per wallet (see 'Holdings'). Addresses of locked wallets are
+// unknown, so they are only scanned once unlocked. Signers that fail to
+// obtain their addresses are skipped.
+func (m *Manager) ScanHoldings(bc *iko.BlockChain) ([]Holdings, error) {
+	var seq uint64
+	if head, e := bc.GetHeadTx(); e == nil {
+		seq = head.Seq
+	}
+
+	// Entries of external signers are obtained without the lock, as they may
+	// be on a device.
+	entries, signers := m.scanTargets()
+	for _, s := range signers {
+		es, e := s.Entries()
+		if e != nil {
+			log.WithError(e).Warningf("failed to obtain addresses of signer `%s`", s.Label())
+			continue
+		}
+		entries[s.Label()] = es
+	}
+
+	now := time.Now().UnixNano()
+	out := make([]Holdings, 0, len(entries))
+	for label, es := range entries {
+		h := Holdings{
+			Label:     label,
+			Seq:       seq,
+			TS:        now,
+			Addresses: []AddressHoldings{},
+		}
+		for _, entry := range es {
+			kitties := bc.GetAddressState(entry.Address).Kitties
+			if len(kitties) == 0 {
+				continue
+			}
+			h.Total += len(kitties)
+			h.Addresses = append(h.Addresses, AddressHoldings{
+				Address: entry.Address.String(),
+				Kitties: append(iko.KittyIDs{}, kitties...),
+			})
+		}
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Label < out[j].Label
+	})
+
+	defer m.lock()()
+	for i := range out {
+		label := out[i].Label
+		_, isWallet := m.wallets[label]
+		_, isSigner := m.signers[label]
+		if isWallet || isSigner {
+			h := out[i]
+			m.holdings[label] = &h
+		}
+	}
+	return out, nil
+}
+
+// Holdings obtains the cached holdings of a wallet, as of the last scan that
+// it was unlocked for. A wallet that is not yet scanned has nil holdings.
+func (m *Manager) Holdings(label string) (*Holdings, error) {
+	defer m.lock()()
+
+	if _, ok := m.signers[label]; !ok {
+		if _, e := m.getWallet(label); e != nil {
+			return nil, e
+		}
+	}
+	h, ok := m.holdings[label]
+	if !ok {
+		return nil, nil
+	}
+	out := *h
+	return &out, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// scanTargets obtains the entries of the wallets that are unlocked, and the
+// external signers.
+func (m *Manager) scanTargets() (map[string][]Entry, []Signer) {
+	defer m.lock()()
+
+	entries := make(map[string][]Entry)
+	for label, w := range m.wallets {
+		if w != nil {
+			entries[label] = w.publicEntries()
+		}
+	}
+	signers := make([]Signer, 0, len(m.signers))
+	for _, s := range m.signers {
+		signers = append(signers, s)
+	}
+	return entries, signers
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"strings"
+)
+
+// ImportedEntry is an entry for a secret key that is imported into a wallet
+// (such as a key from a Skycoin wallet), with a label from the user. As imported
+// keys are not derived from the seed, they are not covered by a backup of
+// the seed alone.
+type ImportedEntry struct {
+	Label string
+	Entry Entry
+}
+
+// ImportKey imports a hex encoded secret key into the wallet, and saves the
+// wallet. Keys for addresses that are already in the wallet are rejected.
+func (w *Wallet) ImportKey(hexSecKey, label string) (cipher.Address, error) {
+	if w.IsWatchOnly() {
+		return cipher.Address{}, ErrWatchOnly
+	}
+	if len(label) > MaxNameSize {
+		return cipher.Address{}, fmt.Errorf("key label exceeds %d bytes", MaxNameSize)
+	}
+	sk, e := cipher.SecKeyFromHex(strings.TrimSpace(hexSecKey))
+	if e != nil {
+		return cipher.Address{}, fmt.Errorf("invalid secret key: %v", e)
+	}
+	entry, e := NewEntry(sk)
+	if e != nil {
+		return cipher.Address{}, fmt.Errorf("invalid secret key: %v", e)
+	}
+	if w.HasAddress(entry.Address) {
+		return cipher.Address{}, fmt.Errorf("address '%s' is already in wallet", entry.Address)
+	}
+
+	w.Imported = append(w.Imported, ImportedEntry{Label: label, Entry: *entry})
+	if e := w.Save(); e != nil {
+		w.Imported = w.Imported[:len(w.Imported)-1]
+		return cipher.Address{}, e
+	}
+	return entry.Address, nil
+}
+
+// HasAddress determines whether an address belongs to an entry (imported entry, or
+// entry of an account) in the wallet.
+func (w *Wallet) HasAddress(addr cipher.Address) bool {
+	for _, entry := range w.Entries {
+		if entry.Address == addr {
+			return true
+		}
+	}
+	for _, imported := range w.Imported {
+		if imported.Entry.Address == addr {
+			return true
+		}
+	}
+	for _, a := range w.Accounts {
+		for _, entry := range a.Entries {
+			if entry.Address == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImportWalletKey imports a hex encoded secret key into the wallet of
+// specified label (see 'Wallet.ImportKey'). Keys for addresses of other wallets
+// are rejected (see 'ImportWalletKeyWithPolicy').
+func (m *Manager) ImportWalletKey(label, hexSecKey, keyLabel string) (*FloatingWallet, error) {
+	res, e := m.ImportWalletKeyWithPolicy(label, hexSecKey, keyLabel, DuplicateReject)
+	if e != nil {
+		return nil, e
+	}
+	return res.Wallet, nil
+}
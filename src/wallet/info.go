@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// MaxNameSize is the maximum size of the display name of a wallet.
+	MaxNameSize = 128
+
+	// MaxMetaFields is the maximum number of metadata fields of a wallet.
+	MaxMetaFields = 64
+
+	// MaxMetaKeySize and MaxMetaValueSize are the maximum sizes of the key
+	// and value of a metadata field.
+	MaxMetaKeySize   = 64
+	MaxMetaValueSize = 1024
+)
+
+// MetaField is a free-form key/value pair in a wallet's metadata.
+type MetaField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Info is the human-friendly information about a wallet, for display. Unlike the
+// label (which is the file name), the name can be anything and be changed.
+// Metadata fields are sorted by key.
+type Info struct {
+	Name     string      `json:"name"`
+	Metadata []MetaField `json:"metadata"`
+}
+
+// Verify checks the sizes of the name and metadata.
+func (in *Info) Verify() error {
+	if len(in.Name) > MaxNameSize {
+		return fmt.Errorf("wallet name exceeds %d bytes", MaxNameSize)
+	}
+	if len(in.Metadata) > MaxMetaFields {
+		return fmt.Errorf("wallet metadata exceeds %d fields", MaxMetaFields)
+	}
+	for i, f := range in.Metadata {
+		if f.Key == "" {
+			return errors.New("wallet metadata key can not be empty")
+		}
+		if len(f.Key) > MaxMetaKeySize {
+			return fmt.Errorf("wallet metadata key '%s' exceeds %d bytes", f.Key, MaxMetaKeySize)
+		}
+		if len(f.Value) > MaxMetaValueSize {
+			return fmt.Errorf("wallet metadata value for key '%s' exceeds %d bytes", f.Key, MaxMetaValueSize)
+		}
+		if i > 0 && in.Metadata[i-1].Key >= f.Key {
+			return fmt.Errorf("wallet metadata key '%s' is duplicate or unsorted", f.Key)
+		}
+	}
+	return nil
+}
+
+// Get obtains the metadata value for a key.
+func (in *Info) Get(key string) (string, bool) {
+	i := in.search(key)
+	if i < len(in.Metadata) && in.Metadata[i].Key == key {
+		return in.Metadata[i].Value, true
+	}
+	return "", false
+}
+
+// Set sets the metadata value for a key, where an empty value removes the key.
+func (in *Info) Set(key, value string) {
+	i := in.search(key)
+	found := i < len(in.Metadata) && in.Metadata[i].Key == key
+	switch {
+	case found && value == "":
+		in.Metadata = append(in.Metadata[:i], in.Metadata[i+1:]...)
+	case found:
+		in.Metadata[i].Value = value
+	case value != "":
+		in.Metadata = append(in.Metadata, MetaField{})
+		copy(in.Metadata[i+1:], in.Metadata[i:])
+		in.Metadata[i] = MetaField{Key: key, Value: value}
+	}
+}
+
+func (in *Info) search(key string) int {
+	return sort.Search(len(in.Metadata), func(i int) bool {
+		return in.Metadata[i].Key >= key
+	})
+}
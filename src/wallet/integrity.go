@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ChecksumSize is the size of the checksum that wallet files end with: the
+	// SHA256 of the rest of the file.
+	ChecksumSize = len(cipher.SHA256{})
+
+	// QuarantineDirName is the name of the directory (in the root directory)
+	// that 'Manager.VerifyAll' moves bad wallet files to.
+	QuarantineDirName = "quarantine"
+
+	// gcmTagSize is the size of the authentication tag in encrypted data.
+	gcmTagSize = 16
+)
+
+var ErrChecksum = errors.New("wallet file is corrupted (checksum mismatch)")
+
+// QuarantinedFile is a wallet file that failed verification, and is moved out
+// of the root directory.
+type QuarantinedFile struct {
+	Label  string `json:"label"`
+	Path   string `json:"path"` // Of the quarantined file.
+	Reason string `json:"reason"`
+}
+
+// VerifyFile checks that a wallet file is neither truncated nor corrupted,
+// without its password. Files with versions before 'NoChecksumVersion' are only
+// checked by loading them, which encrypted files can not be without their
+// passwords. The checksum detects accidental corruption, while tampering with
+// encrypted files is also detected by their encryption when unlocked. Files of
+// newer versions than 'Version' are not checked.
+func VerifyFile(label string, raw []byte) error {
+	prefix, data, e := ExtractPrefix(raw)
+	if e != nil {
+		return e
+	}
+	version := prefix.Version()
+	if version > Version {
+		return nil
+	}
+	if !prefix.Encrypted() {
+		_, e := LoadFloatingWallet(bytes.NewReader(raw), label, "")
+		return e
+	}
+	if version > NoChecksumVersion {
+		if raw, e = splitChecksum(raw); e != nil {
+			return e
+		}
+		data = raw[PrefixSize:]
+	}
+	if version != LegacyVersion && len(data) < headerSize+gcmTagSize {
+		return ErrFileSize
+	}
+	return nil
+}
+
+// VerifyAll verifies every wallet file in the root directory (see
+// 'VerifyFile'), and moves those that fail into the quarantine directory
+// rather than loading them. Quarantined wallets are removed from the manager.
+// The copies of upgraded files (see 'BackupPath') are kept, as they may be
+// the means to recover the wallet.
+func (m *Manager) VerifyAll() ([]QuarantinedFile, error) {
+	defer m.lock()()
+
+	labels, e := ListLabels()
+	if e != nil {
+		return nil, e
+	}
+	var out []QuarantinedFile
+	for _, label := range labels {
+		raw, keystore, e := readWalletFile(LabelPath(label))
+		if e != nil && keystore != "" {
+			// The keystore may be unavailable for now, which is not the fault of the file.
+			log.WithError(e).Warningf("skipped verifying wallet file `%s` in keystore '%s'", label, keystore)
+			continue
+		}
+		if e != nil {
+			return out, e
+		}
+		if e := VerifyFile(label, raw); e != nil {
+			qPath, qe := quarantine(label)
+			if qe != nil {
+				return out, fmt.Errorf("failed to quarantine wallet file '%s': %v", label, qe)
+			}
+			log.WithError(e).Warningf("quarantined wallet file `%s` as `%s`", label, qPath)
+			m.remove(label)
+			out = append(out, QuarantinedFile{
+				Label:  label,
+				Path:   qPath,
+				Reason: e.Error(),
+			})
+		}
+	}
+	return out, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func appendChecksum(raw []byte) []byte {
+	sum := cipher.SumSHA256(raw)
+	return append(raw, sum[:]...)
+}
+
+// splitChecksum checks the checksum of a file, and obtains the file without
+// it.
+func splitChecksum(raw []byte) ([]byte, error) {
+	if len(raw) < PrefixSize+ChecksumSize {
+		return nil, ErrFileSize
+	}
+	n := len(raw) - ChecksumSize
+	if sum := cipher.SumSHA256(raw[:n]); !bytes.Equal(sum[:], raw[n:]) {
+		return nil, ErrChecksum
+	}
+	return raw[:n], nil
+}
+
+// quarantine moves the wallet file with a label into the quarantine directory,
+// with the time as a suffix so that files with the same label do not collide.
+func quarantine(label string) (string, error) {
+	dir := filepath.Join(rootDir, QuarantineDirName)
+	if e := store.MkdirAll(dir); e != nil {
+		return "", e
+	}
+	qPath := filepath.Join(dir, fmt.Sprintf("%s%s.%d", label, FileExt, time.Now().UnixNano()))
+	return qPath, store.Rename(LabelPath(label), qPath)
+}
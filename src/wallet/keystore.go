@@ -0,0 +1,251 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	// KeystoreFile is the keystore of the wallet file itself, which is the
+	// default keystore for wallets.
+	KeystoreFile = "file"
+
+	// KeystoreKeychain is the keystore of the OS: the Keychain of the user on
+	// macOS, and files protected by DPAPI for the user on Windows. It is only
+	// registered on those.
+	KeystoreKeychain = "keychain"
+
+	// KeystoreVault is the name that 'cmd/iko' registers a 'VaultKeystore'
+	// with, when a Vault address is given.
+	KeystoreVault = "vault"
+
+	// KeystoreDirName is the name of the directory (in the root directory)
+	// that keystores of the OS keep the sealed data of wallet files in.
+	KeystoreDirName = "keystore"
+)
+
+var ErrKeystoreNotFound = errors.New("keystore of name is not registered")
+
+// Keystore keeps the data of wallet files elsewhere than the root directory,
+// under keys that are chosen by the manager. The wallet file of a wallet in a
+// keystore (see 'Manager.SetWalletKeystore') only references the key, so it
+// is still listed, moved into the trash and recovered as usual, while the
+// data stays in the keystore until the wallet is purged.
+type Keystore interface {
+	Load(key string) ([]byte, error)
+	Save(key string, data []byte) error
+	Delete(key string) error
+}
+
+var (
+	keystoreMux sync.RWMutex
+	keystores   = make(map[string]Keystore)
+)
+
+// RegisterKeystore makes a keystore available under the specified name, so
+// that wallets can be moved into it.
+// It panics if the keystore is nil, or a keystore with the same name (or
+// 'KeystoreFile') is already registered.
+func RegisterKeystore(name string, ks Keystore) {
+	keystoreMux.Lock()
+	defer keystoreMux.Unlock()
+
+	if ks == nil {
+		panic(fmt.Errorf("nil keystore '%s'", name))
+	}
+	if _, ok := keystores[name]; ok || name == KeystoreFile {
+		panic(fmt.Errorf("keystore '%s' is already registered", name))
+	}
+	keystores[name] = ks
+}
+
+// Keystores obtains the names of the registered keystores (and
+// 'KeystoreFile') in alphabetical order.
+func Keystores() []string {
+	keystoreMux.RLock()
+	defer keystoreMux.RUnlock()
+
+	out := []string{KeystoreFile}
+	for name := range keystores {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// VerifyKeystore checks that a keystore is registered (or is empty, for
+// 'KeystoreFile').
+func VerifyKeystore(name string) error {
+	if name == "" || name == KeystoreFile {
+		return nil
+	}
+	_, e := getKeystore(name)
+	return e
+}
+
+// SetWalletKeystore moves the data of the (unlocked) wallet of specified label
+// into the keystore with the name, and removes it from the previous keystore.
+// Moving out of 'KeystoreFile' replaces the wallet file with the reference,
+// which does not reach copies that the file system or storage keeps
+// elsewhere (as with 'PurgeTrash').
+func (m *Manager) SetWalletKeystore(label, name string) (*FloatingWallet, error) {
+	if e := VerifyKeystore(name); e != nil {
+		return nil, e
+	}
+	if name == "" {
+		name = KeystoreFile
+	}
+
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	old := w.Meta.Keystore
+	w.Meta.Keystore = name
+	if e := w.Save(); e != nil {
+		w.Meta.Keystore = old
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// keystoreMagic starts the wallet files that reference keystores.
+var keystoreMagic = []byte("kittycash-keystore\n")
+
+// keystoreRef is the reference from a wallet file to the data in a keystore.
+type keystoreRef struct {
+	Keystore string `json:"keystore"`
+	Key      string `json:"key"`
+}
+
+// keystorePath obtains the path of sealed data in the keystore directory.
+func keystorePath(key string) string {
+	return filepath.Join(rootDir, KeystoreDirName, key)
+}
+
+func getKeystore(name string) (Keystore, error) {
+	keystoreMux.RLock()
+	defer keystoreMux.RUnlock()
+
+	ks, ok := keystores[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrKeystoreNotFound, name)
+	}
+	return ks, nil
+}
+
+// parseKeystoreRef parses the reference in a wallet file, if it is one.
+func parseKeystoreRef(raw []byte) (*keystoreRef, bool) {
+	if !bytes.HasPrefix(raw, keystoreMagic) {
+		return nil, false
+	}
+	var ref keystoreRef
+	if e := json.Unmarshal(raw[len(keystoreMagic):], &ref); e != nil {
+		return nil, false
+	}
+	return &ref, true
+}
+
+// readWalletFile obtains the data of a wallet file (from its keystore, if it
+// references one), and the name of the keystore.
+func readWalletFile(fPath string) ([]byte, string, error) {
+	raw, e := store.ReadFile(fPath)
+	if e != nil {
+		return nil, "", e
+	}
+	ref, ok := parseKeystoreRef(raw)
+	if !ok {
+		return raw, KeystoreFile, nil
+	}
+	ks, e := getKeystore(ref.Keystore)
+	if e != nil {
+		return nil, ref.Keystore, e
+	}
+	data, e := ks.Load(ref.Key)
+	if e != nil {
+		return nil, ref.Keystore, fmt.Errorf("failed to load from keystore '%s': %v", ref.Keystore, e)
+	}
+	return data, ref.Keystore, nil
+}
+
+// writeWalletFile replaces the data of a wallet file, in the keystore with the
+// name (the keystore that the file already references, or 'KeystoreFile',
+// if empty). Data in a previous keystore is deleted.
+func writeWalletFile(fPath, name string, data []byte) error {
+	var old *keystoreRef
+	if raw, e := store.ReadFile(fPath); e == nil {
+		old, _ = parseKeystoreRef(raw)
+	}
+	switch {
+	case name != "":
+	case old != nil:
+		name = old.Keystore
+	default:
+		name = KeystoreFile
+	}
+	if name == KeystoreFile {
+		if e := SaveBinary(fPath, data); e != nil {
+			return e
+		}
+	} else {
+		ks, e := getKeystore(name)
+		if e != nil {
+			return e
+		}
+		ref := old
+		if ref == nil || ref.Keystore != name {
+			key := make([]byte, 16)
+			if _, e := rand.Read(key); e != nil {
+				return e
+			}
+			ref = &keystoreRef{Keystore: name, Key: hex.EncodeToString(key)}
+		}
+		if e := ks.Save(ref.Key, data); e != nil {
+			return fmt.Errorf("failed to save to keystore '%s': %v", name, e)
+		}
+		raw, _ := json.Marshal(ref)
+		if e := SaveBinary(fPath, append(append([]byte(nil), keystoreMagic...), raw...)); e != nil {
+			return e
+		}
+	}
+	if old != nil && old.Keystore != name {
+		if e := deleteKeystoreData(old); e != nil {
+			log.WithError(e).Warningf("failed to delete wallet data in keystore '%s'", old.Keystore)
+		}
+	}
+	return nil
+}
+
+// deleteKeystoreOf deletes the data in the keystore that a wallet file
+// references, if any.
+func deleteKeystoreOf(fPath string) error {
+	raw, e := store.ReadFile(fPath)
+	if e != nil {
+		return e
+	}
+	if ref, ok := parseKeystoreRef(raw); ok {
+		return deleteKeystoreData(ref)
+	}
+	return nil
+}
+
+func deleteKeystoreData(ref *keystoreRef) error {
+	ks, e := getKeystore(ref.Keystore)
+	if e != nil {
+		return e
+	}
+	return ks.Delete(ref.Key)
+}
@@ -0,0 +1,135 @@
+//go:build darwin
+// +build darwin
+
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// KeychainService is the service of the items in the Keychain that keep the
+// keys for the data of wallet files.
+const KeychainService = "kittycash-wallet"
+
+// errSecItemNotFound is the exit status of the 'security' tool for items that
+// are not found.
+const errSecItemNotFound = 44
+
+func init() {
+	RegisterKeystore(KeystoreKeychain, KeychainKeystore{})
+}
+
+// KeychainKeystore keeps the data of wallet files sealed (with AES-256-GCM) in
+// the keystore directory (see 'KeystoreDirName'), with keys that are kept in the
+// Keychain of the user, through the 'security' tool. Keys are passed to the tool
+// on its standard input, so they are not visible in the arguments of its
+// process.
+type KeychainKeystore struct{}
+
+func (KeychainKeystore) Load(key string) ([]byte, error) {
+	dataKey, e := keychainFind(key)
+	if e != nil {
+		return nil, e
+	}
+	raw, e := store.ReadFile(keystorePath(key))
+	if e != nil {
+		return nil, e
+	}
+	aead, e := newKeychainAEAD(dataKey)
+	if e != nil {
+		return nil, e
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, ErrFileSize
+	}
+	return aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(key))
+}
+
+func (KeychainKeystore) Save(key string, data []byte) error {
+	dataKey, e := keychainFind(key)
+	if e != nil {
+		dataKey = make([]byte, 32)
+		if _, e := rand.Read(dataKey); e != nil {
+			return e
+		}
+		if e := keychainAdd(key, dataKey); e != nil {
+			return e
+		}
+	}
+	aead, e := newKeychainAEAD(dataKey)
+	if e != nil {
+		return e
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return e
+	}
+	if e := store.MkdirAll(filepath.Dir(keystorePath(key))); e != nil {
+		return e
+	}
+	return store.WriteFile(keystorePath(key), aead.Seal(nonce, nonce, data, []byte(key)))
+}
+
+func (KeychainKeystore) Delete(key string) error {
+	if e := store.Remove(keystorePath(key)); e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	out, e := exec.Command("security", "delete-generic-password",
+		"-s", KeychainService, "-a", key).CombinedOutput()
+	if e != nil && !isItemNotFound(e) {
+		return fmt.Errorf("failed to delete keychain item: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func keychainFind(key string) ([]byte, error) {
+	out, e := exec.Command("security", "find-generic-password",
+		"-s", KeychainService, "-a", key, "-w").Output()
+	if e != nil {
+		if isItemNotFound(e) {
+			return nil, fmt.Errorf("keychain item '%s' is not found", key)
+		}
+		return nil, e
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func keychainAdd(key string, dataKey []byte) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		KeychainService, key, hex.EncodeToString(dataKey)))
+	out, e := cmd.CombinedOutput()
+	if msg := strings.TrimSpace(string(out)); e == nil && msg != "" {
+		e = errors.New(msg)
+	}
+	if e != nil {
+		return fmt.Errorf("failed to add keychain item: %v", e)
+	}
+	return nil
+}
+
+func isItemNotFound(e error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(e, &exitErr) && exitErr.ExitCode() == errSecItemNotFound
+}
+
+func newKeychainAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, e := aes.NewCipher(dataKey)
+	if e != nil {
+		return nil, e
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultVaultMount is the mount of the KV secrets engine in Vault.
+	DefaultVaultMount = "secret"
+
+	// DefaultVaultPrefix is the path (in the mount) of the secrets of wallets.
+	DefaultVaultPrefix = "kittycash/wallet"
+)
+
+var ErrVaultSecretNotFound = errors.New("vault secret is not found")
+
+// VaultKeystore keeps the data of wallet files as secrets in the KV (version
+// 2) secrets engine of a HashiCorp Vault, through its HTTP API. The token needs
+// to be able to read, create, update and delete "<mount>/data/<prefix>/*",
+// and to delete "<mount>/metadata/<prefix>/*".
+type VaultKeystore struct {
+	Address string       // Such as "https://127.0.0.1:8200".
+	Token   string       // Sent as 'X-Vault-Token'.
+	Mount   string       // 'DefaultVaultMount' if empty.
+	Prefix  string       // 'DefaultVaultPrefix' if empty.
+	Client  *http.Client // 'http.DefaultClient' if nil.
+}
+
+// NewVaultKeystore creates a Vault keystore with the address, token and mount
+// of the KV secrets engine ('DefaultVaultMount' if empty).
+func NewVaultKeystore(address, token, mount string) *VaultKeystore {
+	return &VaultKeystore{
+		Address: address,
+		Token:   token,
+		Mount:   mount,
+	}
+}
+
+func (v *VaultKeystore) Load(key string) ([]byte, error) {
+	var reply struct {
+		Data struct {
+			Data struct {
+				Wallet string `json:"wallet"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if e := v.do(http.MethodGet, "data", key, nil, &reply); e != nil {
+		return nil, e
+	}
+	return base64.StdEncoding.DecodeString(reply.Data.Data.Wallet)
+}
+
+func (v *VaultKeystore) Save(key string, data []byte) error {
+	body := map[string]interface{}{
+		"data": map[string]string{
+			"wallet": base64.StdEncoding.EncodeToString(data),
+		},
+	}
+	return v.do(http.MethodPost, "data", key, body, nil)
+}
+
+// Delete deletes every version of the secret with the key.
+func (v *VaultKeystore) Delete(key string) error {
+	e := v.do(http.MethodDelete, "metadata", key, nil, nil)
+	if errors.Is(e, ErrVaultSecretNotFound) {
+		return nil
+	}
+	return e
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func (v *VaultKeystore) do(method, kind, key string, body, reply interface{}) error {
+	mount, prefix := v.Mount, v.Prefix
+	if mount == "" {
+		mount = DefaultVaultMount
+	}
+	if prefix == "" {
+		prefix = DefaultVaultPrefix
+	}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s/%s", strings.TrimSuffix(v.Address, "/"),
+		strings.Trim(mount, "/"), kind, strings.Trim(prefix, "/"), key)
+
+	var r io.Reader
+	if body != nil {
+		raw, e := json.Marshal(body)
+		if e != nil {
+			return e
+		}
+		r = bytes.NewReader(raw)
+	}
+	req, e := http.NewRequest(method, url, r)
+	if e != nil {
+		return e
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, e := client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: '%s'", ErrVaultSecretNotFound, key)
+	case resp.StatusCode >= 300:
+		var errReply struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errReply)
+		return fmt.Errorf("vault replied %d: %s", resp.StatusCode, strings.Join(errReply.Errors, "; "))
+	case reply != nil:
+		return json.NewDecoder(resp.Body).Decode(reply)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+//go:build windows
+// +build windows
+
+package wallet
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// The vendored 'windows' package is without DPAPI, which is in crypt32.
+var (
+	crypt32                = windows.NewLazySystemDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+const cryptprotectUIForbidden = 0x1
+
+// dataBlob is the DATA_BLOB of DPAPI.
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func init() {
+	RegisterKeystore(KeystoreKeychain, DPAPIKeystore{})
+}
+
+// DPAPIKeystore keeps the data of wallet files protected by DPAPI (for the
+// user) in the keystore directory (see 'KeystoreDirName'), so it can only be
+// unprotected by the Windows account of the user.
+type DPAPIKeystore struct{}
+
+func (DPAPIKeystore) Load(key string) ([]byte, error) {
+	raw, e := store.ReadFile(keystorePath(key))
+	if e != nil {
+		return nil, e
+	}
+	return dpapiCall(procCryptUnprotectData, raw)
+}
+
+func (DPAPIKeystore) Save(key string, data []byte) error {
+	raw, e := dpapiCall(procCryptProtectData, data)
+	if e != nil {
+		return e
+	}
+	if e := store.MkdirAll(filepath.Dir(keystorePath(key))); e != nil {
+		return e
+	}
+	return store.WriteFile(keystorePath(key), raw)
+}
+
+func (DPAPIKeystore) Delete(key string) error {
+	if e := store.Remove(keystorePath(key)); e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	return nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// dpapiCall protects (or unprotects) data with CryptProtectData (or
+// CryptUnprotectData), which take the same arguments.
+func dpapiCall(proc *windows.LazyProc, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrFileSize
+	}
+	in := dataBlob{size: uint32(len(data)), data: &data[0]}
+	var out dataBlob
+	r, _, e := proc.Call(
+		uintptr(unsafe.Pointer(&in)),
+		0, 0, 0, 0,
+		uintptr(cryptprotectUIForbidden),
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if r == 0 {
+		return nil, e
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.data)))
+	return append([]byte(nil), unsafe.Slice(out.data, out.size)...), nil
+}
@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"io"
+	"sync"
+)
+
+// A Ledger device signs with the deterministic keys from the seed that the
+// device holds, through the KittyCash app on the device. The app is driven
+// by APDU commands, which are carried over the HID transport of Ledger
+// devices in 64 byte packets:
+//
+//	channel (2 bytes), tag 0x05 (1 byte), packet index (2 bytes),
+//	then (in the first packet only) the length of the APDU (2 bytes),
+//	then the APDU, padded with zeros.
+//
+// The commands of the app are:
+//
+//	GET_PUBKEY (0x02): data is the key index (uint32, big-endian),
+//	                   reply is the 33 byte compressed public key.
+//	SIGN_HASH  (0x04): data is the key index and the 32 byte hash,
+//	                   reply is the 65 byte recoverable signature, once the
+//	                   user confirms on the device.
+const (
+	LedgerVendorID = 0x2c97
+
+	ledgerCLA          = 0xe0
+	ledgerInsGetPubKey = 0x02
+	ledgerInsSignHash  = 0x04
+
+	ledgerChannel    = 0x0101
+	ledgerTagAPDU    = 0x05
+	ledgerPacketSize = 64
+
+	ledgerStatusOK       = 0x9000
+	ledgerStatusRejected = 0x6985
+)
+
+var (
+	ErrLedgerRejected = errors.New("request was rejected on the ledger device")
+	ErrLedgerNotFound = errors.New("no ledger device is connected")
+)
+
+// LedgerSigner is the signer for a Ledger device. The signer's addresses are
+// those of the first keys of the device, whose public keys are obtained once,
+// when the signer is created.
+type LedgerSigner struct {
+	label   string
+	mux     sync.Mutex // The device is used by one exchange at a time.
+	dev     io.ReadWriter
+	entries []Entry // Index is the key index on the device.
+}
+
+// NewLedgerSigner creates the signer for a device with the specified number
+// of addresses. The device is the HID transport of the device (see
+// 'OpenLedger').
+func NewLedgerSigner(label string, dev io.ReadWriter, addresses int) (*LedgerSigner, error) {
+	if addresses < 1 {
+		return nil, errors.New("ledger signer needs at least one address")
+	}
+	s := &LedgerSigner{
+		label:   label,
+		dev:     dev,
+		entries: make([]Entry, addresses),
+	}
+	for i := range s.entries {
+		reply, e := s.exchange(ledgerInsGetPubKey, ledgerIndex(uint32(i)))
+		if e != nil {
+			return nil, fmt.Errorf("failed to get public key %d from ledger: %v", i, e)
+		}
+		var pk cipher.PubKey
+		if len(reply) != len(pk) {
+			return nil, fmt.Errorf("ledger replied a public key with %d bytes", len(reply))
+		}
+		copy(pk[:], reply)
+		if e := pk.Verify(); e != nil {
+			return nil, fmt.Errorf("ledger replied an invalid public key: %v", e)
+		}
+		s.entries[i] = Entry{Address: cipher.AddressFromPubKey(pk), PubKey: pk}
+	}
+	return s, nil
+}
+
+func (s *LedgerSigner) Label() string {
+	return s.label
+}
+
+func (s *LedgerSigner) Entries() ([]Entry, error) {
+	return append([]Entry(nil), s.entries...), nil
+}
+
+// SignHash signs a hash on the device, which waits for the user to confirm.
+// It fails with 'ErrLedgerRejected' if the user declines.
+func (s *LedgerSigner) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	for i, entry := range s.entries {
+		if entry.Address != addr {
+			continue
+		}
+		reply, e := s.exchange(ledgerInsSignHash, append(ledgerIndex(uint32(i)), hash[:]...))
+		if e != nil {
+			return cipher.Sig{}, e
+		}
+		var sig cipher.Sig
+		if len(reply) != len(sig) {
+			return cipher.Sig{}, fmt.Errorf("ledger replied a signature with %d bytes", len(reply))
+		}
+		copy(sig[:], reply)
+		if e := cipher.VerifySignature(entry.PubKey, sig, hash); e != nil {
+			return cipher.Sig{}, fmt.Errorf("ledger replied an invalid signature: %v", e)
+		}
+		return sig, nil
+	}
+	return cipher.Sig{}, fmt.Errorf("address '%s' does not belong to ledger '%s'", addr, s.label)
+}
+
+// Close closes the device, if it can be closed.
+func (s *LedgerSigner) Close() error {
+	if c, ok := s.dev.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// exchange sends a command to the app on the device, and obtains the data of
+// the reply.
+func (s *LedgerSigner) exchange(ins byte, data []byte) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	apdu := append([]byte{ledgerCLA, ins, 0, 0, byte(len(data))}, data...)
+	if e := writeLedgerFrames(s.dev, apdu); e != nil {
+		return nil, e
+	}
+	reply, e := readLedgerFrames(s.dev)
+	if e != nil {
+		return nil, e
+	}
+	if len(reply) < 2 {
+		return nil, errors.New("ledger reply has no status")
+	}
+	switch status := binary.BigEndian.Uint16(reply[len(reply)-2:]); status {
+	case ledgerStatusOK:
+		return reply[:len(reply)-2], nil
+	case ledgerStatusRejected:
+		return nil, ErrLedgerRejected
+	default:
+		return nil, fmt.Errorf("ledger replied status '%#04x'", status)
+	}
+}
+
+func ledgerIndex(i uint32) []byte {
+	out := make([]byte, 4)
+	binary.BigEndian.PutUint32(out, i)
+	return out
+}
+
+// writeLedgerFrames writes an APDU as the packets of the HID transport.
+func writeLedgerFrames(w io.Writer, apdu []byte) error {
+	data := make([]byte, 2, 2+len(apdu))
+	binary.BigEndian.PutUint16(data, uint16(len(apdu)))
+	data = append(data, apdu...)
+
+	for i := 0; len(data) > 0; i++ {
+		packet := make([]byte, ledgerPacketSize)
+		binary.BigEndian.PutUint16(packet[0:], ledgerChannel)
+		packet[2] = ledgerTagAPDU
+		binary.BigEndian.PutUint16(packet[3:], uint16(i))
+		n := copy(packet[5:], data)
+		data = data[n:]
+		if _, e := w.Write(packet); e != nil {
+			return fmt.Errorf("failed to write to ledger: %v", e)
+		}
+	}
+	return nil
+}
+
+// readLedgerFrames reads a reply from the packets of the HID transport.
+func readLedgerFrames(r io.Reader) ([]byte, error) {
+	var (
+		out  []byte
+		size = -1
+	)
+	for i := 0; size < 0 || len(out) < size; i++ {
+		packet := make([]byte, ledgerPacketSize)
+		if _, e := io.ReadFull(r, packet); e != nil {
+			return nil, fmt.Errorf("failed to read from ledger: %v", e)
+		}
+		header := []byte{0, 0, ledgerTagAPDU, byte(i >> 8), byte(i)}
+		binary.BigEndian.PutUint16(header, ledgerChannel)
+		if !bytes.Equal(packet[:5], header) {
+			return nil, fmt.Errorf("unexpected packet %d from ledger", i)
+		}
+		body := packet[5:]
+		if i == 0 {
+			size = int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+		}
+		out = append(out, body...)
+	}
+	return out[:size], nil
+}
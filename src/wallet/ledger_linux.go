@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package wallet
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hidrawClassDir is where the hidraw devices of the kernel are listed.
+const hidrawClassDir = "/sys/class/hidraw"
+
+// OpenLedger opens the HID transport of the first Ledger device that is
+// connected, through the hidraw devices of the kernel (which needs read and
+// write access to '/dev/hidraw*', as granted by the udev rules of Ledger).
+func OpenLedger() (io.ReadWriteCloser, error) {
+	names, e := ioutil.ReadDir(hidrawClassDir)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return nil, ErrLedgerNotFound
+		}
+		return nil, e
+	}
+	for _, name := range names {
+		dir := filepath.Join(hidrawClassDir, name.Name())
+		if !isLedgerHidraw(dir) {
+			continue
+		}
+		f, e := os.OpenFile(filepath.Join("/dev", name.Name()), os.O_RDWR, 0)
+		if e != nil {
+			return nil, fmt.Errorf("failed to open ledger: %v", e)
+		}
+		return hidrawDevice{f}, nil
+	}
+	return nil, ErrLedgerNotFound
+}
+
+// isLedgerHidraw determines whether a hidraw device has the Ledger vendor ID
+// and is the first USB interface, which carries APDUs (the others carry U2F
+// and such).
+func isLedgerHidraw(dir string) bool {
+	raw, e := ioutil.ReadFile(filepath.Join(dir, "device", "uevent"))
+	if e != nil {
+		return false
+	}
+	vendor := false
+	for _, line := range strings.Split(string(raw), "\n") {
+		// As 'HID_ID=0003:00002C97:00004011': the bus, vendor and product.
+		if parts := strings.Split(strings.TrimPrefix(line, "HID_ID="), ":"); len(parts) == 3 && line != parts[0] {
+			vendor = strings.EqualFold(parts[1], fmt.Sprintf("%08x", LedgerVendorID))
+		}
+	}
+	if !vendor {
+		return false
+	}
+	dev, e := filepath.EvalSymlinks(filepath.Join(dir, "device"))
+	if e != nil {
+		return false
+	}
+	// The parent of the HID device is the USB interface, as '1-1:1.0'.
+	return strings.HasSuffix(filepath.Base(filepath.Dir(dev)), ".0")
+}
+
+// hidrawDevice is a hidraw device whose reports have no report IDs, so writes
+// start with the report ID 0.
+type hidrawDevice struct {
+	*os.File
+}
+
+func (d hidrawDevice) Write(p []byte) (int, error) {
+	n, e := d.File.Write(append([]byte{0}, p...))
+	if n > 0 {
+		n--
+	}
+	return n, e
+}
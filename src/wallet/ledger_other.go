@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package wallet
+
+import (
+	"errors"
+	"io"
+)
+
+// OpenLedger opens the HID transport of the first Ledger device that is
+// connected. Only the hidraw devices of Linux are supported.
+func OpenLedger() (io.ReadWriteCloser, error) {
+	return nil, errors.New("ledger devices are only supported on linux")
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WalletSort is the order of wallets in 'ListWalletsPage'.
+type WalletSort string
+
+const (
+	SortByLabel    WalletSort = "label"
+	SortByCreated  WalletSort = "created"  // By the timestamp of the wallet.
+	SortByHoldings WalletSort = "holdings" // By the kitties from the last scan (see 'Holdings').
+)
+
+// ListOptions are the options for 'ListWalletsPage'.
+type ListOptions struct {
+	Filter  string     // Substring of the labels (in any case), or empty for every wallet.
+	Sort    WalletSort // 'SortByLabel' if empty.
+	Desc    bool       // Sorts in descending order.
+	Page    uint64     // In pages of 'PerPage' wallets, from 0.
+	PerPage uint64     // Every wallet in one page if 0.
+}
+
+// WalletPage is a page of the wallets from 'ListWalletsPage'.
+type WalletPage struct {
+	Wallets        []Stat `json:"wallets"`
+	Total          int    `json:"total"` // Number of wallets matching the filter.
+	TotalPageCount uint64 `json:"total_page_count"`
+}
+
+// ListWalletsPage lists a page of the wallets available (see 'ListWallets'),
+// with labels matching the filter, in the order of the options. Locked wallets have
+// no timestamp, and wallets that are not yet scanned have no holdings, so
+// they are listed after the others (by their labels) when sorted by them.
+func (m *Manager) ListWalletsPage(opts ListOptions) (*WalletPage, error) {
+	switch opts.Sort {
+	case "":
+		opts.Sort = SortByLabel
+	case SortByLabel, SortByCreated, SortByHoldings:
+	default:
+		return nil, fmt.Errorf("invalid sort '%s'", opts.Sort)
+	}
+
+	defer m.lock()()
+
+	var (
+		stats  = m.listWallets()
+		filter = strings.ToLower(opts.Filter)
+		out    = make([]Stat, 0, len(stats))
+	)
+	for _, s := range stats {
+		if strings.Contains(strings.ToLower(s.Label), filter) {
+			out = append(out, s)
+		}
+	}
+	if opts.Sort != SortByLabel {
+		keys := make(map[string]int64, len(out))
+		for _, s := range out {
+			if v, ok := m.sortKey(s.Label, opts.Sort); ok {
+				keys[s.Label] = v
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			a, aOK := keys[out[i].Label]
+			b, bOK := keys[out[j].Label]
+			switch {
+			case aOK != bOK:
+				return aOK
+			case !aOK || a == b:
+				return false
+			case opts.Desc:
+				return a > b
+			default:
+				return a < b
+			}
+		})
+	} else if opts.Desc {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+
+	page := &WalletPage{
+		Wallets:        out,
+		Total:          len(out),
+		TotalPageCount: 1,
+	}
+	if opts.PerPage != 0 {
+		n := uint64(len(out))
+		page.TotalPageCount = (n + opts.PerPage - 1) / opts.PerPage
+		start := opts.Page * opts.PerPage
+		switch {
+		case opts.Page >= page.TotalPageCount:
+			page.Wallets = []Stat{}
+		case start+opts.PerPage > n:
+			page.Wallets = out[start:]
+		default:
+			page.Wallets = out[start : start+opts.PerPage]
+		}
+	}
+	return page, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// sortKey obtains the key of the wallet for the sort, if it is known.
+func (m *Manager) sortKey(label string, by WalletSort) (int64, bool) {
+	switch by {
+	case SortByCreated:
+		if w := m.wallets[label]; w != nil {
+			return w.Meta.TS, true
+		}
+	case SortByHoldings:
+		if h, ok := m.holdings[label]; ok {
+			return int64(h.Total), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"github.com/skycoin/skycoin/src/cipher"
+	"time"
+)
+
+// DefaultUnlockTTL is how long an encrypted wallet stays unlocked without
+// activity, when unlocked by 'DisplayWallet' (or created).
+const DefaultUnlockTTL = 5 * time.Minute
+
+// unlockState is for an unlocked encrypted wallet, which is locked once it is
+// inactive for 'ttl'.
+type unlockState struct {
+	ttl     time.Duration
+	expires time.Time
+	timer   *time.Timer
+}
+
+// Unlock decrypts the encrypted wallet of specified label, which keeps the
+// decrypted key material in memory until it is locked. The wallet is locked
+// after it is inactive for the ttl (where a ttl of 0 keeps it unlocked until
+// 'Lock'). Unlocking an unlocked wallet checks the password, and resets the
+// ttl.
+func (m *Manager) Unlock(label, password string, ttl time.Duration) error {
+	if ttl < 0 {
+		return errors.New("unlock ttl can not be negative")
+	}
+
+	defer m.lock()()
+
+	return m.unlock(label, password, ttl)
+}
+
+// Lock locks the encrypted wallet of specified label, removing its decrypted
+// key material from memory. Locking a locked wallet does nothing.
+func (m *Manager) Lock(label string) error {
+	defer m.lock()()
+
+	w, ok := m.wallets[label]
+	if !ok {
+		return ErrWalletNotFound
+	}
+	if w != nil && !w.Meta.Encrypted {
+		return errors.New("wallet is not encrypted")
+	}
+	m.lockWallet(label)
+	return nil
+}
+
+// SignHash signs a hash with the secret key for an address of the wallet of
+// specified label (or of an external signer). It fails with 'ErrWalletLocked'
+// if the wallet is locked.
+func (m *Manager) SignHash(label string, addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	m.mux.Lock()
+	if s, ok := m.signers[label]; ok {
+		// External signers may wait on the user (such as to confirm on a
+		// device), so they sign without the manager locked.
+		m.mux.Unlock()
+		sig, e := s.SignHash(addr, hash)
+		if e == nil {
+			m.audit(label, AuditSign, addr.String())
+		}
+		return sig, e
+	}
+	defer m.mux.Unlock()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return cipher.Sig{}, e
+	}
+	sk, e := w.secKeyOf(addr)
+	if e != nil {
+		return cipher.Sig{}, e
+	}
+	m.audit(label, AuditSign, addr.String())
+	return cipher.SignHash(hash, sk), nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// secKeyOf obtains the secret key for an address of the wallet.
+func (w *Wallet) secKeyOf(addr cipher.Address) (cipher.SecKey, error) {
+	if e := w.checkHold(); e != nil {
+		return cipher.SecKey{}, e
+	}
+	var entry *Entry
+	for i := range w.Entries {
+		if w.Entries[i].Address == addr {
+			entry = &w.Entries[i]
+		}
+	}
+	for i := range w.Imported {
+		if w.Imported[i].Entry.Address == addr {
+			entry = &w.Imported[i].Entry
+		}
+	}
+	for i := range w.Accounts {
+		for j := range w.Accounts[i].Entries {
+			if w.Accounts[i].Entries[j].Address == addr {
+				entry = &w.Accounts[i].Entries[j]
+			}
+		}
+	}
+	switch {
+	case entry == nil:
+		return cipher.SecKey{}, errors.New("address does not belong to wallet")
+	case entry.IsWatchOnly():
+		return cipher.SecKey{}, ErrWatchOnly
+	default:
+		return entry.SecKey, nil
+	}
+}
+
+// wipe removes the key material of the wallet from memory, as far as it can
+// be (the seed is a string, which can only be dropped).
+func (w *Wallet) wipe() {
+	for i := range w.Entries {
+		w.Entries[i].SecKey = cipher.SecKey{}
+	}
+	for i := range w.Imported {
+		w.Imported[i].Entry.SecKey = cipher.SecKey{}
+	}
+	for i := range w.Accounts {
+		for j := range w.Accounts[i].Entries {
+			w.Accounts[i].Entries[j].SecKey = cipher.SecKey{}
+		}
+	}
+	w.Meta.Seed = ""
+	w.Meta.Password = ""
+	w.next = nil
+}
+
+func (m *Manager) unlock(label, password string, ttl time.Duration) error {
+	w, ok := m.wallets[label]
+	if !ok {
+		return ErrWalletNotFound
+	}
+	if w != nil && !w.Meta.Encrypted {
+		return nil
+	}
+
+	raw, keystore, e := readWalletFile(LabelPath(label))
+	if e != nil {
+		return e
+	}
+	loaded, e := LoadFloatingWallet(bytes.NewReader(raw), label, password)
+	if e != nil {
+		m.auditPassword(label, e, "unlock")
+		return e
+	}
+	loaded.Meta.Keystore = keystore
+	upgradeWallet(loaded)
+
+	m.lockWallet(label)
+	m.wallets[label] = loaded
+	if ttl > 0 {
+		m.watchUnlock(label, ttl)
+	}
+	m.audit(label, AuditUnlock, "")
+	return nil
+}
+
+// watchUnlock locks the wallet of the label once it is inactive for the ttl.
+func (m *Manager) watchUnlock(label string, ttl time.Duration) {
+	st := &unlockState{ttl: ttl, expires: time.Now().Add(ttl)}
+	var check func()
+	check = func() {
+		defer m.lock()()
+
+		if m.unlocks[label] != st {
+			return
+		}
+		if left := time.Until(st.expires); left > 0 {
+			st.timer = time.AfterFunc(left, check)
+			return
+		}
+		m.lockWallet(label)
+	}
+	st.timer = time.AfterFunc(ttl, check)
+	m.unlocks[label] = st
+}
+
+// touch extends the unlock of the wallet of the label, on activity.
+func (m *Manager) touch(label string) {
+	if st, ok := m.unlocks[label]; ok {
+		st.expires = time.Now().Add(st.ttl)
+	}
+}
+
+// lockWallet wipes and locks the wallet of the label, if it is encrypted.
+func (m *Manager) lockWallet(label string) {
+	m.forgetUnlock(label)
+	if w := m.wallets[label]; w != nil && w.Meta.Encrypted {
+		w.wipe()
+		m.wallets[label] = nil
+	}
+}
+
+// forgetUnlock stops the auto-lock of the wallet of the label.
+func (m *Manager) forgetUnlock(label string) {
+	if st, ok := m.unlocks[label]; ok {
+		st.timer.Stop()
+		delete(m.unlocks, label)
+	}
+}
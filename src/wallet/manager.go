@@ -1,57 +1,178 @@
 package wallet
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
 	"io"
-	"os"
 	"sort"
 	"sync"
+	"time"
 )
 
 var (
 	ErrWalletNotFound     = errors.New("wallet of label is not found")
 	ErrWalletLocked       = errors.New("wallet is locked")
 	ErrLabelAlreadyExists = errors.New("label already exists")
+	ErrWatchOnly          = errors.New("wallet is watch-only")
+	ErrUnencryptedWallet  = errors.New("unencrypted wallets are refused by policy")
 )
 
 // Manager manages the wallet files.
 type Manager struct {
-	mux     sync.Mutex
-	labels  []string
-	wallets map[string]*Wallet
+	mux      sync.Mutex
+	labels   []string
+	wallets  map[string]*Wallet
+	unlocks  map[string]*unlockState // Auto-locks for unlocked encrypted wallets.
+	signers  map[string]Signer       // External (such as hardware) signers.
+	holdings map[string]*Holdings    // From the last scan (see 'ScanHoldings').
+	book     *AddressBook
+	release  func() // Releases the lock on the root directory.
+
+	config       ManagerConfig
+	deleteTokens map[string]deleteToken // Confirmations for 'DeleteWallet'.
+
+	eventMux  sync.RWMutex
+	eventSubs map[*EventSubscription]struct{} // See 'SubscribeEvents'.
+	chainSub  *iko.TxSubscription             // For the chain watch (see 'WatchChain').
+
+	dirStop  chan struct{}        // For the directory watch (see 'WatchDir').
+	dirSkips map[string]time.Time // Modification times of files that failed to reload.
+
+	auditMux  sync.Mutex // For the audit log (see 'AuditLog').
+	auditRead bool       // Whether the head of the log is read.
+	auditSeq  uint64     // Seq of the next entry.
+	auditPrev string     // MAC of the latest entry.
+	auditKey  []byte     // For the MACs of the entries (see 'auditKey').
 }
 
-// NewManager creates a new wallet manager.
+// ManagerConfig is the configuration of a wallet manager.
+type ManagerConfig struct {
+	// RequireEncrypted refuses to load or create unencrypted wallet files
+	// (with 'ErrUnencryptedWallet'), for operators with compliance requirements.
+	// Unencrypted files in the root directory are skipped with a warning, and
+	// are left untouched.
+	RequireEncrypted bool
+
+	// MinPasswordScore refuses passwords for new and encrypted wallets (and for
+	// password changes) with a lower score (with 'ErrWeakPassword', see
+	// 'EstimatePassword'), where 0 accepts every password.
+	MinPasswordScore int
+
+	// AuditSecret is the secret that the key for the MACs of the audit log is
+	// derived from (see 'AuditLog'). It is to be kept away from the root
+	// directory, as whoever holds it can rewrite the log. Without it, a random
+	// key is kept in 'AuditKeyFile' in the root directory.
+	AuditSecret []byte
+}
+
+// NewManager creates a new wallet manager with the default configuration (see
+// 'NewManagerWithConfig').
 func NewManager() (*Manager, error) {
-	m := new(Manager)
+	return NewManagerWithConfig(ManagerConfig{})
+}
+
+// NewManagerWithConfig creates a new wallet manager. The manager holds a lock on the
+// root directory until closed, and fails with 'ErrDirLocked' if a manager of
+// another process holds it. Bad wallet files are quarantined (see
+// 'VerifyAll') before the wallets are loaded, and deleted wallets in the trash
+// that are older than 'TrashPeriod' are purged (see 'PurgeTrash'). The root
+// directory is created if it does not exist ('DefaultRootDir' if it is not
+// set).
+func NewManagerWithConfig(config ManagerConfig) (*Manager, error) {
+	if config.MinPasswordScore < 0 || config.MinPasswordScore > MaxPasswordScore {
+		return nil, fmt.Errorf("minimum password score needs to be between 0 and %d", MaxPasswordScore)
+	}
+	if rootDir == "" {
+		dir, e := DefaultRootDir()
+		if e != nil {
+			return nil, e
+		}
+		if e := SetRootDir(dir); e != nil {
+			return nil, e
+		}
+	} else if e := ensureRootDir(); e != nil {
+		return nil, e
+	}
+	release, e := store.Lock(rootDir)
+	if e != nil {
+		return nil, e
+	}
+	book, e := NewAddressBook(AddressBookPath())
+	if e != nil {
+		release()
+		return nil, e
+	}
+	key, e := auditKey(config.AuditSecret)
+	if e != nil {
+		release()
+		return nil, fmt.Errorf("failed to obtain key for audit log: %v", e)
+	}
+	m := &Manager{
+		unlocks:  make(map[string]*unlockState),
+		signers:  make(map[string]Signer),
+		holdings: make(map[string]*Holdings),
+		book:     book,
+		release:  release,
+		auditKey: key,
+
+		config:       config,
+		deleteTokens: make(map[string]deleteToken),
+		eventSubs:    make(map[*EventSubscription]struct{}),
+		dirSkips:     make(map[string]time.Time),
+	}
+	if _, e := m.VerifyAll(); e != nil {
+		release()
+		return nil, e
+	}
+	if _, e := m.PurgeTrash(time.Now().Add(-TrashPeriod), true); e != nil {
+		log.WithError(e).Warning("failed to purge trash of wallets")
+	}
 	if e := m.Refresh(); e != nil {
+		release()
 		return nil, e
 	}
 	return m, nil
 }
 
+// Close stops the chain watch (closing event subscriptions) and the directory
+// watch, locks every wallet, and releases the lock of the root directory.
+func (m *Manager) Close() {
+	m.closeEvents()
+
+	defer m.lock()()
+
+	m.stopDirWatch()
+
+	for label := range m.unlocks {
+		m.lockWallet(label)
+	}
+	m.release()
+}
+
+// AddressBook obtains the address book, which is saved alongside the wallet
+// files.
+func (m *Manager) AddressBook() *AddressBook {
+	return m.book
+}
+
 // Refresh reloads the list of wallets.
 // All wallets will be locked.
 func (m *Manager) Refresh() error {
 	defer m.lock()()
 
+	for label := range m.unlocks {
+		m.lockWallet(label)
+	}
 	m.labels = make([]string, 0)
 	m.wallets = make(map[string]*Wallet)
+	m.holdings = make(map[string]*Holdings)
 	e := RangeLabels(func(f io.Reader, label, fPath string, prefix Prefix) {
-		if prefix.Version() != Version {
-			log.Warningf(
-				"wallet file `%s` is of version %v, while only version %v is supported",
-				label, prefix.Version(), Version)
-			return
-		}
-		var wallet *Wallet
-		if prefix.Encrypted() {
-			var e error
-			if wallet, e = LoadFloatingWallet(f, label, ""); e != nil {
-				return
-			}
+		if wallet, ok := m.loadFile(label, fPath, prefix); ok {
+			m.append(label, wallet)
 		}
-		m.append(label, wallet)
 	})
 	if e != nil {
 		return e
@@ -62,26 +183,37 @@ func (m *Manager) Refresh() error {
 // Stat represents a wallet when listed by 'ListWallets'.
 type Stat struct {
 	Label     string `json:"label"`
+	Name      string `json:"name,omitempty"` // Display name, unless locked.
 	Encrypted bool   `json:"encrypted"`
 	Locked    *bool  `json:"locked,omitempty"`
+	WatchOnly bool   `json:"watch_only,omitempty"` // Unless locked.
+	Hardware  bool   `json:"hardware,omitempty"`   // For an external signer (see 'AddSigner').
 }
 
 // Lists the wallets available.
 func (m *Manager) ListWallets() []Stat {
 	defer m.lock()()
 
+	return m.listWallets()
+}
+
+func (m *Manager) listWallets() []Stat {
 	var out = make([]Stat, len(m.labels))
 	for i, label := range m.labels {
 		fw := m.wallets[label]
 		var (
+			name      string
 			encrypted bool
 			locked    *bool
+			watchOnly bool
 		)
 		if fw == nil {
 			encrypted = true
 			locked = new(bool)
 			*locked = true
 		} else {
+			name = fw.Meta.Name
+			watchOnly = fw.IsWatchOnly()
 			encrypted = fw.Meta.Encrypted
 			if encrypted {
 				locked = new(bool)
@@ -90,44 +222,79 @@ func (m *Manager) ListWallets() []Stat {
 		}
 		out[i] = Stat{
 			Label:     label,
+			Name:      name,
 			Encrypted: encrypted,
 			Locked:    locked,
+			WatchOnly: watchOnly,
 		}
 	}
-	return out
+	return m.signerStats(out)
 }
 
-// NewWallet creates a new wallet (and it's associated file)
+// NewWallet creates a new wallet (and its associated file)
 // with specified options, and the number of addresses to generate under it.
 func (m *Manager) NewWallet(opts *Options, addresses int) error {
+	opts.Addresses = addresses
+	_, e := m.CreateWallet(opts)
+	return e
+}
+
+// CreateWallet creates a new wallet with the options, and saves it as its own
+// file under the root directory (with the label as the file name).
+func (m *Manager) CreateWallet(opts *Options) (*FloatingWallet, error) {
 	defer m.lock()()
 
 	if _, ok := m.wallets[opts.Label]; ok {
-		return ErrLabelAlreadyExists
+		return nil, ErrLabelAlreadyExists
+	}
+	if _, ok := m.signers[opts.Label]; ok {
+		return nil, ErrLabelAlreadyExists
+	}
+	if m.config.RequireEncrypted && !opts.Encrypted {
+		return nil, ErrUnencryptedWallet
+	}
+	if opts.Encrypted {
+		if e := m.checkPasswordPolicy(opts.Password, opts.Label, opts.Name); e != nil {
+			return nil, e
+		}
 	}
 
 	fw, e := NewFloatingWallet(opts)
 	if e != nil {
-		return e
+		return nil, e
 	}
-	if e := fw.EnsureEntries(addresses); e != nil {
-		return e
+	if e := fw.EnsureEntries(opts.Addresses); e != nil {
+		return nil, e
 	}
 	if e := fw.Save(); e != nil {
-		return e
+		return nil, e
 	}
 	m.append(opts.Label, fw)
-	return m.sort()
+	if fw.Meta.Encrypted {
+		m.watchUnlock(opts.Label, DefaultUnlockTTL)
+	}
+	if e := m.sort(); e != nil {
+		return nil, e
+	}
+	return fw.ToFloating(), nil
 }
 
-// DeleteWallet deletes a wallet of a given label.
-func (m *Manager) DeleteWallet(label string) error {
-	defer m.lock()()
+// GetWallet obtains the wallet of specified label. Encrypted wallets need to
+// be unlocked first (see 'DisplayWallet'), or 'ErrWalletLocked' is returned.
+// Wallets of external signers have their addresses alone.
+func (m *Manager) GetWallet(label string) (*FloatingWallet, error) {
+	m.mux.Lock()
+	if s, ok := m.signers[label]; ok {
+		m.mux.Unlock()
+		return signerWallet(s)
+	}
+	defer m.mux.Unlock()
 
-	if m.remove(label) {
-		return os.Remove(LabelPath(label))
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
 	}
-	return ErrWalletNotFound
+	return w.ToFloating(), nil
 }
 
 // DisplayWallet displays the wallet of specified label.
@@ -143,22 +310,47 @@ func (m *Manager) DisplayWallet(label, password string) (*FloatingWallet, error)
 		return nil, ErrWalletNotFound
 
 	case ErrWalletLocked:
-		f, e := os.Open(LabelPath(label))
-		if e != nil {
+		if e := m.unlock(label, password, DefaultUnlockTTL); e != nil {
 			return nil, e
 		}
-		defer f.Close()
-		if w, e = LoadFloatingWallet(f, label, password); e != nil {
-			return nil, e
-		}
-		m.wallets[label] = w
-		return w.ToFloating(), nil
+		return m.wallets[label].ToFloating(), nil
 
 	default:
 		return nil, errors.New("unknown error")
 	}
 }
 
+// ChangeWalletPassword changes the password of the wallet of specified label
+// (see 'Wallet.ChangePassword'). A locked wallet is unlocked with the old
+// password first.
+func (m *Manager) ChangeWalletPassword(label, old, new string) error {
+	defer m.lock()()
+
+	if e := m.checkPasswordPolicy(new, label); e != nil {
+		return e
+	}
+
+	w, e := m.getWallet(label)
+	if e == ErrWalletLocked {
+		if e = m.unlock(label, old, DefaultUnlockTTL); e != nil {
+			return e
+		}
+		w, e = m.getWallet(label)
+	}
+	if e != nil {
+		return e
+	}
+	wasEncrypted := w.Meta.Encrypted
+	if e := w.ChangePassword(old, new); e != nil {
+		m.auditPassword(label, e, "change password")
+		return e
+	}
+	if !wasEncrypted {
+		m.watchUnlock(label, DefaultUnlockTTL)
+	}
+	return nil
+}
+
 // EnsureWalletEntries ensures that the wallet of specified label
 // has the specified number of address entries.
 func (m *Manager) EnsureWalletEntries(label string, addresses int) (*FloatingWallet, error) {
@@ -177,10 +369,109 @@ func (m *Manager) EnsureWalletEntries(label string, addresses int) (*FloatingWal
 	return w.ToFloating(), nil
 }
 
+// WatchWalletAddress adds an address to the watch-only wallet of specified
+// label.
+func (m *Manager) WatchWalletAddress(label string, addr cipher.Address) (*FloatingWallet, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	if !w.IsWatchOnly() {
+		return nil, errors.New("wallet is not watch-only")
+	}
+	n := w.Count()
+	if e := w.WatchAddress(addr); e != nil {
+		return nil, e
+	}
+	if e := w.Save(); e != nil {
+		w.Entries = w.Entries[:n]
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
+
+// SetWalletName sets the display name of the wallet of specified label.
+func (m *Manager) SetWalletName(label, name string) (*FloatingWallet, error) {
+	return m.updateInfo(label, func(info *Info) {
+		info.Name = name
+	})
+}
+
+// SetWalletMeta sets a metadata field of the wallet of specified label. An
+// empty value removes the field.
+func (m *Manager) SetWalletMeta(label, key, value string) (*FloatingWallet, error) {
+	if key == "" {
+		return nil, errors.New("wallet metadata key can not be empty")
+	}
+	return m.updateInfo(label, func(info *Info) {
+		info.Set(key, value)
+	})
+}
+
 /*
 	<<< HELPER FUNCTIONS >>>
 */
 
+// updateInfo updates the info of an unlocked wallet, and saves it. The info
+// is left unchanged if it becomes invalid, or fails to save.
+func (m *Manager) updateInfo(label string, update func(info *Info)) (*FloatingWallet, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	info := Info{
+		Name:     w.Meta.Name,
+		Metadata: append([]MetaField{}, w.Meta.Metadata...),
+	}
+	update(&info)
+	if e := info.Verify(); e != nil {
+		return nil, e
+	}
+	old := w.Meta.Info
+	w.Meta.Info = info
+	if e := w.Save(); e != nil {
+		w.Meta.Info = old
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
+
+// loadFile loads the wallet file with the label (as from 'RangeLabels'), where
+// encrypted wallets are nil as they are locked. Files that are not loaded are
+// logged.
+func (m *Manager) loadFile(label, fPath string, prefix Prefix) (*Wallet, bool) {
+	if prefix.Version() > Version {
+		log.Warningf(
+			"wallet file `%s` is of version %v, while only up to version %v is supported",
+			label, prefix.Version(), Version)
+		return nil, false
+	}
+	if prefix.Encrypted() {
+		return nil, true
+	}
+	if m.config.RequireEncrypted {
+		log.Warningf("skipped unencrypted wallet file `%s`, as encrypted wallets are required", label)
+		return nil, false
+	}
+	raw, keystore, e := readWalletFile(fPath)
+	if e != nil {
+		log.WithError(e).Warningf("failed to open wallet file `%s`", label)
+		return nil, false
+	}
+	wallet, e := LoadFloatingWallet(bytes.NewReader(raw), label, "")
+	if e != nil {
+		log.WithError(e).Warningf("failed to load wallet file `%s`", label)
+		return nil, false
+	}
+	wallet.Meta.Keystore = keystore
+	upgradeWallet(wallet)
+	return wallet, true
+}
+
 func (m *Manager) lock() func() {
 	m.mux.Lock()
 	return m.mux.Unlock
@@ -194,8 +485,11 @@ func (m *Manager) append(label string, fw *Wallet) {
 func (m *Manager) remove(label string) bool {
 	for i, l := range m.labels {
 		if l == label {
+			m.lockWallet(label)
 			m.labels = append(m.labels[:i], m.labels[i+1:]...)
 			delete(m.wallets, label)
+			delete(m.holdings, label)
+			delete(m.deleteTokens, label)
 			return true
 		}
 	}
@@ -215,5 +509,6 @@ func (m *Manager) getWallet(label string) (*Wallet, error) {
 	if w == nil {
 		return nil, ErrWalletLocked
 	}
+	m.touch(label)
 	return w, nil
 }
@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+// BackupExt is the extension of the copy of a wallet file that is kept when
+// it is upgraded to the current version, as "<label>.kcw.bak".
+const BackupExt = ".bak"
+
+// migration converts the (decrypted) data of a wallet file at a version into
+// the layout of the next version.
+type migration func(data []byte) ([]byte, error)
+
+// migrations are keyed by the versions that they migrate from. Changes to the layout
+// of 'File' (or its encryption) need 'Version' bumped, and a migration from
+// the previous version, so that files at every older version still load.
+var migrations = map[uint64]migration{
+	// Only the encryption differs, which is handled by 'LoadFloatingWallet'.
+	LegacyVersion: func(data []byte) ([]byte, error) {
+		return data, nil
+	},
+	NoInfoVersion: func(data []byte) ([]byte, error) {
+		var old noInfoFile
+		if e := encoder.DeserializeRaw(data, &old); e != nil {
+			return nil, e
+		}
+		return encoder.Serialize(noImportFile{
+			Meta:    old.Meta,
+			Entries: old.Entries,
+		}), nil
+	},
+	NoImportVersion: func(data []byte) ([]byte, error) {
+		var old noImportFile
+		if e := encoder.DeserializeRaw(data, &old); e != nil {
+			return nil, e
+		}
+		return encoder.Serialize(noNotesFile{
+			Meta:    old.Meta,
+			Entries: old.Entries,
+			Info:    old.Info,
+		}), nil
+	},
+	// Only the checksum differs, which is handled by 'LoadFloatingWallet'.
+	NoChecksumVersion: func(data []byte) ([]byte, error) {
+		return data, nil
+	},
+	NoNotesVersion: func(data []byte) ([]byte, error) {
+		var old noNotesFile
+		if e := encoder.DeserializeRaw(data, &old); e != nil {
+			return nil, e
+		}
+		return encoder.Serialize(noAccountsFile{
+			Meta:     old.Meta,
+			Entries:  old.Entries,
+			Info:     old.Info,
+			Imported: old.Imported,
+		}), nil
+	},
+	NoAccountsVersion: func(data []byte) ([]byte, error) {
+		var old noAccountsFile
+		if e := encoder.DeserializeRaw(data, &old); e != nil {
+			return nil, e
+		}
+		return encoder.Serialize(noHoldFile{
+			Meta:     old.Meta,
+			Entries:  old.Entries,
+			Info:     old.Info,
+			Imported: old.Imported,
+			Notes:    old.Notes,
+		}), nil
+	},
+	NoHoldVersion: func(data []byte) ([]byte, error) {
+		var old noHoldFile
+		if e := encoder.DeserializeRaw(data, &old); e != nil {
+			return nil, e
+		}
+		return File{
+			Meta:     old.Meta,
+			Entries:  old.Entries,
+			Info:     old.Info,
+			Imported: old.Imported,
+			Notes:    old.Notes,
+			Accounts: old.Accounts,
+		}.Serialize(), nil
+	},
+}
+
+// migrateData converts the data of a wallet file at a version into the layout
+// of the current version, one version at a time.
+func migrateData(version uint64, data []byte) ([]byte, error) {
+	if version > Version {
+		return nil, fmt.Errorf("wallet file with version %d is not supported", version)
+	}
+	for v := version; v < Version; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("wallet file with version %d can not be migrated", v)
+		}
+		var e error
+		if data, e = migrate(data); e != nil {
+			return nil, fmt.Errorf("failed to migrate wallet file from version %d: %v", v, e)
+		}
+	}
+	return data, nil
+}
+
+// BackupPath obtains the path of the copy of a wallet file at an older
+// version, as kept by upgrades.
+func BackupPath(label string) string {
+	return LabelPath(label) + BackupExt
+}
+
+// upgradeWallet re-saves a wallet that is loaded from a file at an older
+// version, so that its file is at the current version (and encryption). The
+// old file is kept as a copy (see 'BackupPath') until its password is
+// changed, in case the upgrade needs to be undone by downgrading.
+func upgradeWallet(w *Wallet) {
+	if w.Meta.Version == Version {
+		return
+	}
+	from := w.Meta.Version
+	old, _, e := readWalletFile(LabelPath(w.Meta.Label))
+	if e == nil {
+		e = SaveBinary(BackupPath(w.Meta.Label), old)
+	}
+	if e != nil {
+		log.WithError(e).Warningf("failed to copy wallet file `%s` before upgrade", w.Meta.Label)
+		return
+	}
+	if e := w.Save(); e != nil {
+		log.WithError(e).Warningf("failed to upgrade wallet file `%s`", w.Meta.Label)
+		return
+	}
+	log.Infof("upgraded wallet file `%s` from version %v to %v (old file kept as `%s`)",
+		w.Meta.Label, from, Version, BackupPath(w.Meta.Label))
+}
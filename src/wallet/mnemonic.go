@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher/go-bip39"
+	"strings"
+)
+
+var ErrInvalidSeedPhrase = errors.New("invalid seed phrase")
+
+// SeedPhraseWords are the supported numbers of words in a seed phrase.
+var SeedPhraseWords = []int{12, 24}
+
+// NewSeedPhrase generates a BIP39 seed phrase of 12 or 24 words. A wallet with
+// the phrase as its seed can be recreated on another machine with the phrase
+// alone, as every address is derived from the seed.
+func NewSeedPhrase(words int) (string, error) {
+	var bitSize int
+	switch words {
+	case 12:
+		bitSize = 128
+	case 24:
+		bitSize = 256
+	default:
+		return "", fmt.Errorf("seed phrase needs to have %v words", SeedPhraseWords)
+	}
+	entropy, e := bip39.NewEntropy(bitSize)
+	if e != nil {
+		return "", e
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// NormalizeSeedPhrase lowercases a seed phrase and separates its words by a
+// single space, so that a phrase that is typed differently on restore still
+// derives the same addresses.
+func NormalizeSeedPhrase(phrase string) string {
+	return strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+}
+
+// VerifySeedPhrase checks that a (normalized) seed phrase is in the BIP39
+// word list, and has a valid checksum.
+func VerifySeedPhrase(phrase string) error {
+	if !bip39.IsMnemonicValid(phrase) {
+		return ErrInvalidSeedPhrase
+	}
+
+	// Each word is 11 bits, from the entropy followed by the checksum (the
+	// leading bits of the SHA256 of the entropy, one per 32 bits of entropy).
+	words := strings.Fields(phrase)
+	bits := make([]byte, 0, len(words)*11)
+	for _, word := range words {
+		index := bip39.ReverseWordMap[word]
+		for i := 10; i >= 0; i-- {
+			bits = append(bits, byte(index>>uint(i))&1)
+		}
+	}
+	checksumSize := len(bits) / 33
+	entropy := make([]byte, (len(bits)-checksumSize)/8)
+	for i := range entropy {
+		for _, bit := range bits[i*8 : i*8+8] {
+			entropy[i] = entropy[i]<<1 | bit
+		}
+	}
+	hash := sha256.Sum256(entropy)
+	for i, bit := range bits[len(entropy)*8:] {
+		if hash[i/8]>>uint(7-i%8)&1 != bit {
+			return ErrInvalidSeedPhrase
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"sort"
+)
+
+const (
+	// MaxKittyNotes is the maximum number of kitty notes in a wallet.
+	MaxKittyNotes = 1024
+
+	// MaxKittyNoteSize is the maximum size of a kitty note.
+	MaxKittyNoteSize = 1024
+)
+
+// KittyNote is a private note on a kitty (such as a nickname, or what it was
+// bought for), kept in the wallet file.
+type KittyNote struct {
+	KittyID iko.KittyID `json:"kitty_id"`
+	Note    string      `json:"note"`
+}
+
+// KittyNotes are the kitty notes in a wallet, sorted by kitty ID. They are
+// stored in the wallet file, so they are encrypted along with the wallet and
+// are never sent to the chain.
+type KittyNotes []KittyNote
+
+// Verify checks the number and sizes of the notes.
+func (ns KittyNotes) Verify() error {
+	if len(ns) > MaxKittyNotes {
+		return fmt.Errorf("kitty notes exceed %d notes", MaxKittyNotes)
+	}
+	for i, n := range ns {
+		if n.Note == "" {
+			return fmt.Errorf("kitty note for kitty '%d' is empty", n.KittyID)
+		}
+		if len(n.Note) > MaxKittyNoteSize {
+			return fmt.Errorf("kitty note for kitty '%d' exceeds %d bytes", n.KittyID, MaxKittyNoteSize)
+		}
+		if i > 0 && ns[i-1].KittyID >= n.KittyID {
+			return fmt.Errorf("kitty note for kitty '%d' is duplicate or unsorted", n.KittyID)
+		}
+	}
+	return nil
+}
+
+// Get obtains the note on a kitty.
+func (ns KittyNotes) Get(kittyID iko.KittyID) (string, bool) {
+	i := ns.search(kittyID)
+	if i < len(ns) && ns[i].KittyID == kittyID {
+		return ns[i].Note, true
+	}
+	return "", false
+}
+
+// Set sets the note on a kitty, where an empty note removes it.
+func (ns *KittyNotes) Set(kittyID iko.KittyID, note string) {
+	i := ns.search(kittyID)
+	found := i < len(*ns) && (*ns)[i].KittyID == kittyID
+	switch {
+	case found && note == "":
+		*ns = append((*ns)[:i], (*ns)[i+1:]...)
+	case found:
+		(*ns)[i].Note = note
+	case note != "":
+		*ns = append(*ns, KittyNote{})
+		copy((*ns)[i+1:], (*ns)[i:])
+		(*ns)[i] = KittyNote{KittyID: kittyID, Note: note}
+	}
+}
+
+func (ns KittyNotes) search(kittyID iko.KittyID) int {
+	return sort.Search(len(ns), func(i int) bool {
+		return ns[i].KittyID >= kittyID
+	})
+}
+
+// SetKittyNote sets the note on a kitty in an unlocked (or watch-only) wallet,
+// and saves it. An empty note removes the note. The kitty does not need to be
+// in the wallet (such as to note kitties to buy).
+func (m *Manager) SetKittyNote(label string, kittyID iko.KittyID, note string) (*FloatingWallet, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	notes := append(KittyNotes{}, w.Notes...)
+	notes.Set(kittyID, note)
+	if e := notes.Verify(); e != nil {
+		return nil, e
+	}
+	old := w.Notes
+	w.Notes = notes
+	if e := w.Save(); e != nil {
+		w.Notes = old
+		return nil, e
+	}
+	return w.ToFloating(), nil
+}
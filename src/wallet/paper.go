@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"github.com/skycoin/skycoin/src/cipher"
+	"html/template"
+	"io"
+)
+
+// PaperQRScale is the number of pixels per module of the QR codes in paper
+// wallets.
+const PaperQRScale = 6
+
+// PaperWallet is a keypair generated for offline storage, with QR codes (as
+// PNG images) with the address and secret key for printing.
+type PaperWallet struct {
+	Address   string `json:"address"`
+	PubKey    string `json:"public_key"`
+	SecKey    string `json:"secret_key"`
+	AddressQR []byte `json:"address_qr"`    // PNG image.
+	SecKeyQR  []byte `json:"secret_key_qr"` // PNG image.
+
+	addressQR *QRCode
+	secKeyQR  *QRCode
+}
+
+// NewPaperWallet generates a new keypair as a paper wallet. The keypair is
+// not stored anywhere, so it is lost unless printed (or otherwise kept).
+func NewPaperWallet() (*PaperWallet, error) {
+	pk, sk := cipher.GenerateKeyPair()
+	pw := &PaperWallet{
+		Address: cipher.AddressFromPubKey(pk).String(),
+		PubKey:  pk.Hex(),
+		SecKey:  sk.Hex(),
+	}
+	var e error
+	if pw.addressQR, e = NewQRCode([]byte(pw.Address)); e != nil {
+		return nil, e
+	}
+	if pw.secKeyQR, e = NewQRCode([]byte(pw.SecKey)); e != nil {
+		return nil, e
+	}
+	if pw.AddressQR, e = pw.addressQR.PNG(PaperQRScale); e != nil {
+		return nil, e
+	}
+	if pw.SecKeyQR, e = pw.secKeyQR.PNG(PaperQRScale); e != nil {
+		return nil, e
+	}
+	return pw, nil
+}
+
+// AddressQRCode obtains the QR code of the address.
+func (pw *PaperWallet) AddressQRCode() *QRCode {
+	return pw.addressQR
+}
+
+// SecKeyQRCode obtains the QR code of the secret key.
+func (pw *PaperWallet) SecKeyQRCode() *QRCode {
+	return pw.secKeyQR
+}
+
+var paperTemplate = template.Must(template.New("paper").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Kitty Wallet - {{.Address}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.half { display: inline-block; vertical-align: top; width: 45%; margin-right: 4%; }
+code { font-size: 0.8em; word-break: break-all; }
+</style>
+</head>
+<body>
+<div class="half">
+<h2>Address</h2>
+<img src="data:image/png;base64,{{.AddressQR}}" alt="address">
+<p><code>{{.Address}}</code></p>
+</div>
+<div class="half">
+<h2>Secret Key</h2>
+<img src="data:image/png;base64,{{.SecKeyQR}}" alt="secret key">
+<p><code>{{.SecKey}}</code></p>
+<p>Keep this secret. Anyone with this key owns the kitties of the address.</p>
+</div>
+</body>
+</html>
+`))
+
+// HTML writes a printable page of the paper wallet, with the QR codes embedded.
+func (pw *PaperWallet) HTML(w io.Writer) error {
+	return paperTemplate.Execute(w, struct {
+		Address   string
+		SecKey    string
+		AddressQR template.URL
+		SecKeyQR  template.URL
+	}{
+		Address:   pw.Address,
+		SecKey:    pw.SecKey,
+		AddressQR: template.URL(base64.StdEncoding.EncodeToString(pw.AddressQR)),
+		SecKeyQR:  template.URL(base64.StdEncoding.EncodeToString(pw.SecKeyQR)),
+	})
+}
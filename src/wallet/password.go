@@ -0,0 +1,586 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxPasswordScore is the score of the most unguessable passwords (see
+	// 'EstimatePassword').
+	MaxPasswordScore = 4
+
+	// maxEstimatedSize is the size of the prefix of a password that is
+	// estimated, which keeps estimates fast for long passwords: longer
+	// passwords are only stronger.
+	maxEstimatedSize = 100
+)
+
+var ErrWeakPassword = errors.New("password is too weak")
+
+// PasswordStrength is the estimate of the strength of a password, with the
+// score and feedback to show to the user.
+type PasswordStrength struct {
+	Score        int      `json:"score"`         // From 0 (too guessable) to 'MaxPasswordScore' (very unguessable).
+	GuessesLog10 float64  `json:"guesses_log10"` // Of the estimated guesses to find the password.
+	Warning      string   `json:"warning,omitempty"`
+	Suggestions  []string `json:"suggestions"`
+	MinScore     int      `json:"min_score"`  // From the policy of the manager (see 'ManagerConfig').
+	Acceptable   bool     `json:"acceptable"` // Whether the score meets the policy.
+}
+
+// EstimatePassword estimates the strength of a password, with the patterns of
+// zxcvbn: the password is split into the sequence of common passwords and
+// words (also with capitals, l33t substitutions and reversed), user inputs
+// (such as the wallet label and name), keyboard rows, sequences, repeats and
+// recent years (and characters matching none) that is the cheapest to guess. The
+// estimate is for the guesses of an attacker that knows the patterns, not for
+// the characters alone, so "P@ssw0rd!" scores low.
+func EstimatePassword(password string, userInputs ...string) *PasswordStrength {
+	return estimatePassword(password, time.Now().Year(), userInputs...)
+}
+
+// estimatePassword estimates the strength of a password, with years guessed
+// from the specified current year.
+func estimatePassword(password string, thisYear int, userInputs ...string) *PasswordStrength {
+	runes := []rune(password)
+	if len(runes) > maxEstimatedSize {
+		runes = runes[:maxEstimatedSize]
+	}
+	dicts := passwordDicts(userInputs)
+	seq := cheapestMatches(runes, findMatches(runes, dicts, thisYear))
+
+	var guesses float64
+	for _, mt := range seq {
+		guesses += mt.guesses
+	}
+	guesses += math.Log10(factorial(len(seq)))
+	s := &PasswordStrength{
+		Score:        guessesScore(guesses),
+		GuessesLog10: math.Round(guesses*100) / 100,
+		Suggestions:  make([]string, 0),
+		Acceptable:   true,
+	}
+	s.feedback(runes, seq)
+	return s
+}
+
+// CheckPassword estimates the strength of a password (see 'EstimatePassword')
+// against the minimum score of the manager (see 'ManagerConfig').
+func (m *Manager) CheckPassword(password string, userInputs ...string) *PasswordStrength {
+	s := EstimatePassword(password, userInputs...)
+	s.MinScore = m.config.MinPasswordScore
+	s.Acceptable = s.Score >= s.MinScore
+	return s
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// checkPasswordPolicy fails with 'ErrWeakPassword' if the password has a
+// lower score than the minimum score of the manager.
+func (m *Manager) checkPasswordPolicy(password string, userInputs ...string) error {
+	if m.config.MinPasswordScore <= 0 {
+		return nil
+	}
+	s := m.CheckPassword(password, userInputs...)
+	if s.Acceptable {
+		return nil
+	}
+	hint := s.Warning
+	if hint == "" && len(s.Suggestions) != 0 {
+		hint = s.Suggestions[0]
+	}
+	return fmt.Errorf("%w (score %d of minimum %d): %s", ErrWeakPassword, s.Score, s.MinScore, hint)
+}
+
+func guessesScore(log10 float64) int {
+	switch {
+	case log10 < 3:
+		return 0
+	case log10 < 6:
+		return 1
+	case log10 < 8:
+		return 2
+	case log10 < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+type matchKind int
+
+const (
+	matchBruteforce matchKind = iota
+	matchCommon               // From 'commonPasswords'.
+	matchWord                 // From 'commonWords'.
+	matchUserInput
+	matchKeyboard
+	matchSequence
+	matchRepeat
+	matchYear
+)
+
+// passwordMatch is a substring of a password matching a pattern, with the log10 of
+// its guesses.
+type passwordMatch struct {
+	kind     matchKind
+	i, j     int // Into the runes, inclusive.
+	guesses  float64
+	rank     int
+	l33t     bool
+	reversed bool
+	upper    bool
+}
+
+func findMatches(runes []rune, dicts map[matchKind]map[string]int, thisYear int) []passwordMatch {
+	var out []passwordMatch
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	// Words in the dictionaries, as they are, unleeted and reversed.
+	for i := range runes {
+		for j := i; j < len(runes); j++ {
+			word := string(lower[i : j+1])
+			for kind, dict := range dicts {
+				forms := []struct {
+					word           string
+					l33t, reversed bool
+				}{
+					{word, false, false},
+					{unleet(word), true, false},
+					{reverse(word), false, true},
+				}
+				for _, f := range forms {
+					rank, ok := dict[f.word]
+					if !ok || (f.l33t && f.word == word) || (f.reversed && f.word == word) {
+						continue
+					}
+					guesses := float64(rank) * upperVariations(runes[i:j+1])
+					if f.l33t {
+						guesses *= l33tVariations(runes[i : j+1])
+					}
+					if f.reversed {
+						guesses *= 2
+					}
+					out = append(out, passwordMatch{
+						kind: kind, i: i, j: j, guesses: math.Log10(math.Max(guesses, 1)),
+						rank: rank, l33t: f.l33t, reversed: f.reversed,
+						upper: upperVariations(runes[i:j+1]) > 1,
+					})
+				}
+			}
+		}
+	}
+
+	// Keyboard rows, sequences and repeats, with 3 or more runes.
+	for i := 0; i+2 < len(lower); i++ {
+		if j := keyboardRun(lower, i); j >= i+2 {
+			out = append(out, passwordMatch{kind: matchKeyboard, i: i, j: j,
+				guesses: math.Log10(47 * math.Pow(2, float64(j-i)))})
+		}
+		if j, desc := sequenceRun(lower, i); j >= i+2 {
+			base := 26.0
+			switch {
+			case lower[i] == 'a' || lower[i] == '1' || lower[i] == 'z' || lower[i] == '9':
+				base = 4
+			case unicode.IsDigit(lower[i]):
+				base = 10
+			}
+			if desc {
+				base *= 2
+			}
+			out = append(out, passwordMatch{kind: matchSequence, i: i, j: j,
+				guesses: math.Log10(base * float64(j-i+1))})
+		}
+		for size := 1; size <= 4 && i+2*size <= len(runes); size++ {
+			n := 1
+			for i+(n+1)*size <= len(runes) && string(runes[i+n*size:i+(n+1)*size]) == string(runes[i:i+size]) {
+				n++
+			}
+			if n < 2 || (size == 1 && n < 3) {
+				continue
+			}
+			base := math.Pow(cardinality(runes[i:i+size]), float64(size))
+			out = append(out, passwordMatch{kind: matchRepeat, i: i, j: i + n*size - 1,
+				guesses: math.Log10(base * float64(n))})
+		}
+	}
+
+	// Years from 1900 to 2039, by the distance from the current year.
+	for i := 0; i+4 <= len(runes); i++ {
+		var year int
+		if _, e := fmt.Sscanf(string(runes[i:i+4]), "%4d", &year); e != nil || year < 1900 || year > 2039 {
+			continue
+		}
+		if !allDigits(runes[i : i+4]) {
+			continue
+		}
+		space := math.Max(math.Abs(float64(year-thisYear)), 20)
+		out = append(out, passwordMatch{kind: matchYear, i: i, j: i + 3, guesses: math.Log10(space)})
+	}
+	return out
+}
+
+// cheapestMatches obtains the sequence of matches (and bruteforce for the
+// rest) that covers the password for the fewest guesses, with the number of
+// matches penalised by its factorial (as in zxcvbn).
+func cheapestMatches(runes []rune, matches []passwordMatch) []passwordMatch {
+	n := len(runes)
+	if n == 0 {
+		return nil
+	}
+	ending := make([][]passwordMatch, n)
+	for _, mt := range matches {
+		ending[mt.j] = append(ending[mt.j], mt)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			guesses := float64(j-i+1) * math.Log10(cardinality(runes[i:j+1]))
+			if j == i {
+				guesses = math.Max(guesses, 1)
+			}
+			ending[j] = append(ending[j], passwordMatch{kind: matchBruteforce, i: i, j: j, guesses: guesses})
+		}
+	}
+
+	// cost[k][c] is for the cheapest sequence of c matches over the first k runes.
+	inf := math.Inf(1)
+	cost := make([][]float64, n+1)
+	back := make([][]*passwordMatch, n+1)
+	for k := range cost {
+		cost[k] = make([]float64, n+1)
+		back[k] = make([]*passwordMatch, n+1)
+		for c := range cost[k] {
+			cost[k][c] = inf
+		}
+	}
+	cost[0][0] = 0
+	for k := 1; k <= n; k++ {
+		for idx := range ending[k-1] {
+			mt := &ending[k-1][idx]
+			for c := 1; c <= k; c++ {
+				prev := cost[mt.i][c-1]
+				if prev == inf {
+					continue
+				}
+				if v := prev + mt.guesses; v < cost[k][c] {
+					cost[k][c] = v
+					back[k][c] = mt
+				}
+			}
+		}
+	}
+	best, count := inf, 0
+	for c := 1; c <= n; c++ {
+		if v := cost[n][c] + math.Log10(factorial(c)); v < best {
+			best, count = v, c
+		}
+	}
+	seq := make([]passwordMatch, count)
+	for k, c := n, count; c > 0; c-- {
+		seq[c-1] = *back[k][c]
+		k = back[k][c].i
+	}
+	return seq
+}
+
+// feedback sets the warning and suggestions of the strength, from the longest
+// match in the password.
+func (s *PasswordStrength) feedback(runes []rune, seq []passwordMatch) {
+	if len(runes) == 0 {
+		s.Suggestions = append(s.Suggestions,
+			"Use a few words, avoid common phrases.",
+			"No need for symbols, digits, or uppercase letters.")
+		return
+	}
+	if s.Score > 2 {
+		return
+	}
+	var longest *passwordMatch
+	for i := range seq {
+		if longest == nil || seq[i].j-seq[i].i > longest.j-longest.i {
+			longest = &seq[i]
+		}
+	}
+	s.Suggestions = append(s.Suggestions, "Add another word or two. Uncommon words are better.")
+	if longest == nil {
+		return
+	}
+	switch longest.kind {
+	case matchCommon:
+		if longest.rank <= 10 && !longest.l33t && !longest.reversed {
+			s.Warning = "This is a top-10 common password."
+		} else {
+			s.Warning = "This is similar to a commonly used password."
+		}
+	case matchWord:
+		if len(seq) == 1 {
+			s.Warning = "A word by itself is easy to guess."
+		}
+	case matchUserInput:
+		s.Warning = "Passwords with the wallet label or name are easy to guess."
+	case matchKeyboard:
+		s.Warning = "Straight rows of keys are easy to guess."
+		s.Suggestions = append(s.Suggestions, "Use a longer keyboard pattern with more turns.")
+	case matchSequence:
+		s.Warning = "Sequences like abc or 6543 are easy to guess."
+		s.Suggestions = append(s.Suggestions, "Avoid sequences.")
+	case matchRepeat:
+		s.Warning = `Repeats like "aaa" or "abcabc" are easy to guess.`
+		s.Suggestions = append(s.Suggestions, "Avoid repeated words and characters.")
+	case matchYear:
+		s.Warning = "Recent years are easy to guess."
+		s.Suggestions = append(s.Suggestions, "Avoid recent years, and years that are associated with you.")
+	}
+	if longest.upper {
+		s.Suggestions = append(s.Suggestions, "Capitalization doesn't help very much.")
+	}
+	if longest.reversed {
+		s.Suggestions = append(s.Suggestions, "Reversed words aren't much harder to guess.")
+	}
+	if longest.l33t {
+		s.Suggestions = append(s.Suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+	}
+}
+
+// passwordDicts obtains the dictionaries of ranks of words, with the user
+// inputs (such as words of the wallet label and name).
+func passwordDicts(userInputs []string) map[matchKind]map[string]int {
+	inputs := make(map[string]int)
+	for _, in := range userInputs {
+		words := strings.FieldsFunc(strings.ToLower(in), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range append(words, strings.ToLower(in)) {
+			if _, ok := inputs[w]; !ok && w != "" {
+				inputs[w] = len(inputs) + 1
+			}
+		}
+	}
+	return map[matchKind]map[string]int{
+		matchCommon:    commonPasswordRanks,
+		matchWord:      commonWordRanks,
+		matchUserInput: inputs,
+	}
+}
+
+var (
+	commonPasswordRanks = ranks(commonPasswords)
+	commonWordRanks     = ranks(commonWords)
+)
+
+func ranks(words []string) map[string]int {
+	out := make(map[string]int, len(words))
+	for i, w := range words {
+		if _, ok := out[w]; !ok {
+			out[w] = i + 1
+		}
+	}
+	return out
+}
+
+// upperVariations obtains the number of ways that a word is capitalised.
+func upperVariations(word []rune) float64 {
+	var upper, lower int
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0, upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1])):
+		return 2
+	}
+	var out float64
+	for i := 1; i <= upper && i <= lower; i++ {
+		out += binomial(upper+lower, i)
+	}
+	return out
+}
+
+var l33tTable = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func unleet(word string) string {
+	out := []rune(word)
+	for i, r := range out {
+		if sub, ok := l33tTable[r]; ok {
+			out[i] = sub
+		}
+	}
+	return string(out)
+}
+
+// l33tVariations obtains the number of ways of the substitutions in a word.
+func l33tVariations(word []rune) float64 {
+	var subs int
+	for _, r := range word {
+		if _, ok := l33tTable[r]; ok {
+			subs++
+		}
+	}
+	return math.Max(2, math.Pow(2, float64(subs))-1)
+}
+
+func reverse(word string) string {
+	out := []rune(word)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// keyboardRun obtains the index of the last rune of the run of adjacent keys on
+// a keyboard row (either way) from the rune at index i.
+func keyboardRun(runes []rune, i int) int {
+	for _, row := range keyboardRows {
+		pos := strings.IndexRune(row, runes[i])
+		if pos < 0 {
+			continue
+		}
+		for _, step := range []int{1, -1} {
+			j, p := i, pos
+			for j+1 < len(runes) && p+step >= 0 && p+step < len(row) && rune(row[p+step]) == runes[j+1] {
+				j, p = j+1, p+step
+			}
+			if j >= i+2 {
+				return j
+			}
+		}
+	}
+	return i
+}
+
+// sequenceRun obtains the index of the last rune of the run of consecutive
+// letters or digits (with a step of 1, either way) from the rune at index i.
+func sequenceRun(runes []rune, i int) (int, bool) {
+	if i+1 >= len(runes) {
+		return i, false
+	}
+	step := runes[i+1] - runes[i]
+	if step != 1 && step != -1 {
+		return i, false
+	}
+	kind := unicode.IsDigit(runes[i])
+	j := i
+	for j+1 < len(runes) && runes[j+1]-runes[j] == step && unicode.IsDigit(runes[j+1]) == kind &&
+		(unicode.IsLetter(runes[j+1]) || unicode.IsDigit(runes[j+1])) {
+		j++
+	}
+	return j, step == -1
+}
+
+// cardinality obtains the size of the alphabet of the runes, as for
+// bruteforce.
+func cardinality(runes []rune) float64 {
+	var lower, upper, digits, symbols, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digits = true
+		case r < 0x80:
+			symbols = true
+		default:
+			other = true
+		}
+	}
+	var out float64
+	for _, c := range []struct {
+		ok   bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digits, 10}, {symbols, 33}, {other, 100}} {
+		if c.ok {
+			out += c.size
+		}
+	}
+	return math.Max(out, 10)
+}
+
+func allDigits(runes []rune) bool {
+	for _, r := range runes {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func factorial(n int) float64 {
+	out := 1.0
+	for i := 2; i <= n; i++ {
+		out *= float64(i)
+	}
+	return out
+}
+
+func binomial(n, k int) float64 {
+	out := 1.0
+	for i := 1; i <= k; i++ {
+		out = out * float64(n-k+i) / float64(i)
+	}
+	return out
+}
+
+// commonPasswords are the most common passwords in leaks, in order.
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "pussy", "superman", "1qaz2wsx", "7777777", "fuckyou", "121212",
+	"000000", "qazwsx", "123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm",
+	"asdfgh", "hunter", "buster", "soccer", "harley", "batman", "andrew", "tigger",
+	"sunshine", "iloveyou", "fuckme", "2000", "charlie", "robert", "thomas", "hockey",
+	"ranger", "daniel", "starwars", "klaster", "112233", "george", "asshole", "computer",
+	"michelle", "jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313",
+	"freedom", "777777", "pass", "fuck", "maggie", "159753", "aaaaaa", "ginger",
+	"princess", "joshua", "cheese", "amanda", "summer", "love", "ashley", "6969",
+	"nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321", "dallas",
+	"austin", "thunder", "taylor", "matrix", "admin", "welcome", "passw0rd", "login",
+	"qwerty123", "solo", "starwars", "whatever", "donald", "bitcoin", "skycoin", "kitty",
+	"kittycash", "wallet", "secret", "changeme", "default", "test", "guest", "hello",
+}
+
+// commonWords are common English words (and names), in order.
+var commonWords = []string{
+	"the", "be", "and", "of", "a", "in", "to", "have", "it", "i", "that", "for", "you", "he",
+	"with", "on", "do", "say", "this", "they", "at", "but", "we", "his", "from", "not", "by",
+	"she", "or", "as", "what", "go", "their", "can", "who", "get", "if", "would", "her", "all",
+	"my", "make", "about", "know", "will", "up", "one", "time", "there", "year", "so",
+	"think", "when", "which", "them", "some", "me", "people", "take", "out", "into", "just",
+	"see", "him", "your", "come", "could", "now", "than", "like", "other", "how", "then",
+	"its", "our", "two", "more", "these", "want", "way", "look", "first", "also", "new",
+	"because", "day", "use", "no", "man", "find", "here", "thing", "give", "many", "well",
+	"only", "those", "tell", "very", "even", "back", "any", "good", "woman", "through",
+	"us", "life", "child", "work", "down", "may", "after", "should", "call", "world", "over",
+	"school", "still", "try", "last", "ask", "need", "too", "feel", "three", "state",
+	"never", "become", "between", "high", "really", "something", "most", "another", "much",
+	"family", "own", "leave", "put", "old", "while", "mean", "keep", "student", "why", "let",
+	"great", "same", "big", "group", "begin", "seem", "country", "help", "talk", "where",
+	"turn", "problem", "every", "start", "hand", "might", "show", "part", "against", "place",
+	"cat", "dog", "money", "house", "king", "queen", "blue", "red", "green", "black", "white",
+	"happy", "sun", "moon", "star", "fire", "water", "tree", "god", "angel", "baby", "cash",
+	"coin", "key", "lock", "safe", "gold", "silver", "fish", "bird", "horse", "lion", "tiger",
+	"john", "james", "david", "mary", "anna", "alex", "sam", "max", "mike", "chris", "paul",
+}
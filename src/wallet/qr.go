@@ -0,0 +1,495 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// qrBlocks are the error correction blocks of QR code versions 1 to 9 at
+// error correction level M: the error correction codewords per block, and the
+// data codewords of each block.
+var qrBlocks = [...]struct {
+	ec   int
+	data []int
+}{
+	1: {10, []int{16}},
+	2: {16, []int{28}},
+	3: {26, []int{44}},
+	4: {18, []int{32, 32}},
+	5: {24, []int{43, 43}},
+	6: {16, []int{27, 27, 27, 27}},
+	7: {18, []int{31, 31, 31, 31}},
+	8: {22, []int{38, 38, 39, 39}},
+	9: {22, []int{36, 36, 36, 37, 37}},
+}
+
+// qrAlignments are the centers of alignment patterns for each version.
+var qrAlignments = [...][]int{
+	2: {6, 18},
+	3: {6, 22},
+	4: {6, 26},
+	5: {6, 30},
+	6: {6, 34},
+	7: {6, 22, 38},
+	8: {6, 24, 42},
+	9: {6, 26, 46},
+}
+
+// qrVersionInfo are the version information bits for versions 7 and above.
+var qrVersionInfo = map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99}
+
+// QRCode is a QR code of data in byte mode, with error correction level M. It
+// is up to version 9, which holds up to 180 bytes (enough for addresses,
+// keys and seeds).
+type QRCode struct {
+	Size     int
+	modules  [][]bool
+	function [][]bool // Whether a module is in a function pattern.
+}
+
+// NewQRCode encodes data as a QR code with the smallest version that fits it.
+func NewQRCode(data []byte) (*QRCode, error) {
+	return newQRCode(data, -1)
+}
+
+// newQRCode encodes data with a mask (or the mask with the lowest penalty if the
+// mask is negative).
+func newQRCode(data []byte, mask int) (*QRCode, error) {
+	version := 0
+	for v := 1; v < len(qrBlocks); v++ {
+		if 4+8+8*len(data) <= 8*qrDataSize(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("qr code data with %d bytes is too long", len(data))
+	}
+
+	codewords := qrCodewords(version, data)
+	if mask >= 0 {
+		q := newQRBase(version)
+		q.drawCodewords(codewords)
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		return q, nil
+	}
+	var best *QRCode
+	bestPenalty := -1
+	for m := 0; m < 8; m++ {
+		q := newQRBase(version)
+		q.drawCodewords(codewords)
+		q.applyMask(m)
+		q.drawFormat(m)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = q, p
+		}
+	}
+	return best, nil
+}
+
+// Dark determines whether the module at a column and row is dark.
+func (q *QRCode) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+		return false
+	}
+	return q.modules[y][x]
+}
+
+// PNG renders the QR code as a PNG image, with a number of pixels per module
+// and the quiet zone of 4 modules around it.
+func (q *QRCode) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("invalid qr code scale '%d'", scale)
+	}
+	const quiet = 4
+	n := (q.Size + 2*quiet) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for py := 0; py < n; py++ {
+		for px := 0; px < n; px++ {
+			c := color.Gray{Y: 0xff}
+			if q.Dark(px/scale-quiet, py/scale-quiet) {
+				c.Y = 0
+			}
+			img.SetGray(px, py, c)
+		}
+	}
+	var buf bytes.Buffer
+	if e := png.Encode(&buf, img); e != nil {
+		return nil, e
+	}
+	return buf.Bytes(), nil
+}
+
+// String renders the QR code as text, with two characters per module.
+func (q *QRCode) String() string {
+	var buf bytes.Buffer
+	for y := -2; y < q.Size+2; y++ {
+		for x := -2; x < q.Size+2; x++ {
+			if q.Dark(x, y) {
+				buf.WriteString("██")
+			} else {
+				buf.WriteString("  ")
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+/*
+	<<< ENCODING >>>
+*/
+
+func qrDataSize(version int) int {
+	n := 0
+	for _, v := range qrBlocks[version].data {
+		n += v
+	}
+	return n
+}
+
+// qrCodewords obtains the interleaved data and error correction codewords for
+// the data.
+func qrCodewords(version int, data []byte) []byte {
+	var (
+		size = qrDataSize(version)
+		bits = make([]bool, 0, 8*size)
+	)
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (v>>uint(i))&1 == 1)
+		}
+	}
+	put(0x4, 4) // Byte mode.
+	put(len(data), 8)
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	for i := 0; i < 4 && len(bits) < 8*size; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	raw := make([]byte, 0, size)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		raw = append(raw, b)
+	}
+	for pad := byte(0xEC); len(raw) < size; pad ^= 0xEC ^ 0x11 {
+		raw = append(raw, pad)
+	}
+
+	var (
+		blocks    = qrBlocks[version]
+		divisor   = qrDivisor(blocks.ec)
+		dataParts = make([][]byte, len(blocks.data))
+		ecParts   = make([][]byte, len(blocks.data))
+	)
+	for i, n := range blocks.data {
+		dataParts[i], raw = raw[:n], raw[n:]
+		ecParts[i] = qrRemainder(dataParts[i], divisor)
+	}
+	var out []byte
+	for i := 0; i < blocks.data[len(blocks.data)-1]; i++ {
+		for _, part := range dataParts {
+			if i < len(part) {
+				out = append(out, part[i])
+			}
+		}
+	}
+	for i := 0; i < blocks.ec; i++ {
+		for _, part := range ecParts {
+			out = append(out, part[i])
+		}
+	}
+	return out
+}
+
+// qrMultiply multiplies in GF(2^8) with the QR code polynomial (0x11D).
+func qrMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrDivisor obtains the Reed-Solomon generator polynomial with a degree
+// (without its leading coefficient).
+func qrDivisor(degree int) []byte {
+	out := make([]byte, degree)
+	out[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range out {
+			out[j] = qrMultiply(out[j], root)
+			if j+1 < len(out) {
+				out[j] ^= out[j+1]
+			}
+		}
+		root = qrMultiply(root, 0x02)
+	}
+	return out
+}
+
+// qrRemainder obtains the Reed-Solomon error correction codewords for data.
+func qrRemainder(data, divisor []byte) []byte {
+	out := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ out[0]
+		copy(out, out[1:])
+		out[len(out)-1] = 0
+		for i, coef := range divisor {
+			out[i] ^= qrMultiply(coef, factor)
+		}
+	}
+	return out
+}
+
+/*
+	<<< DRAWING >>>
+*/
+
+// newQRBase creates a QR code with the function patterns of a version.
+func newQRBase(version int) *QRCode {
+	size := 4*version + 17
+	q := &QRCode{
+		Size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+
+	align := qrAlignments[version]
+	for i := range align {
+		for j := range align {
+			last := len(align) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // Taken by the finder patterns.
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(align[i]+dx, align[j]+dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormat(0) // Reserves the format modules, which are drawn with the mask.
+	if info, ok := qrVersionInfo[version]; ok {
+		for i := 0; i < 18; i++ {
+			bit := (info>>uint(i))&1 == 1
+			a, b := size-11+i%3, i/3
+			q.set(a, b, bit)
+			q.set(b, a, bit)
+		}
+	}
+	return q
+}
+
+// set sets a module in a function pattern.
+func (q *QRCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *QRCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.Size || yy >= q.Size {
+				continue
+			}
+			d := qrMax(qrAbs(dx), qrAbs(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormat draws the format information for level M and a mask.
+func (q *QRCode) drawFormat(mask int) {
+	data := mask // Level M has the bits '00'.
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.Size-15+i, bit(i))
+	}
+	q.set(8, q.Size-8, true)
+}
+
+// drawCodewords draws the codewords in the zigzag order, in two columns
+// upwards and downwards from the right.
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.modules[y][x] = (codewords[i>>3]>>uint(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the QR code by the rules that masks are chosen by: runs of
+// modules with the same color, 2x2 blocks, patterns that look like finders,
+// and the balance of dark and light modules.
+func (q *QRCode) penalty() int {
+	var (
+		n      = q.Size
+		score  = 0
+		finder = []bool{true, false, true, true, true, false, true}
+	)
+	at := func(x, y int, horizontal bool) bool {
+		if horizontal {
+			return q.Dark(x, y)
+		}
+		return q.Dark(y, x)
+	}
+	for _, horizontal := range []bool{true, false} {
+		for y := 0; y < n; y++ {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && at(x, y, horizontal) == at(x-1, y, horizontal) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= n; x++ {
+				match := true
+				for i, v := range finder {
+					if at(x+i, y, horizontal) != v {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				// Light modules (in the quiet zone, out of bounds) either side.
+				before, after := true, true
+				for i := 1; i <= 4; i++ {
+					before = before && !at(x-i, y, horizontal)
+					after = after && !at(x+6+i, y, horizontal)
+				}
+				if before || after {
+					score += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if q.modules[y][x+1] == c && q.modules[y+1][x] == c && q.modules[y+1][x+1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+	percent := dark * 100 / (n * n)
+	score += qrAbs(percent-50) / 5 * 10
+	return score
+}
+
+func qrAbs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func qrMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,193 @@
+package wallet
+
+import (
+	"io"
+	"time"
+)
+
+// DefaultReloadInterval is the interval at which the root directory is polled
+// by the directory watch where changes are not notified (see
+// 'Manager.WatchDir'). Notified changes are reloaded once the directory is
+// quiet for 'dirSettle', as files are written in steps.
+const (
+	DefaultReloadInterval = 2 * time.Second
+
+	dirSettle = 100 * time.Millisecond
+)
+
+// ReloadResult is the result of reloading the root directory.
+type ReloadResult struct {
+	Added   []string `json:"added"`   // Labels of wallet files that appeared.
+	Removed []string `json:"removed"` // Labels of wallet files that are gone.
+}
+
+// Reload synchronises the list of wallets with the wallet files in the root
+// directory, such as with files that are added (or removed) by other tools, or
+// restored by hand. Unlike 'Refresh', wallets that are already listed are left
+// as they are, so unlocked wallets stay unlocked. Files that fail to load are
+// logged once, and retried when they are modified.
+func (m *Manager) Reload() (*ReloadResult, error) {
+	defer m.lock()()
+
+	return m.reload()
+}
+
+// WatchDir watches the root directory, so wallet files that are added or
+// removed externally appear in 'ListWallets' without restarting. Changes are
+// notified through inotify on Linux. Elsewhere (and for stores other than
+// 'DirStore'), the directory is polled at the interval instead
+// ('DefaultReloadInterval' if 0). Watching again replaces the previous watch,
+// and the watch stops when the manager is closed.
+func (m *Manager) WatchDir(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	defer m.lock()()
+
+	m.stopDirWatch()
+	stop := make(chan struct{})
+	m.dirStop = stop
+
+	// The watch is started before returning, so no change is missed.
+	var (
+		events      <-chan struct{}
+		closeEvents = func() {}
+	)
+	if _, ok := store.(DirStore); ok {
+		ch, closeFn, e := notifyDir(rootDir)
+		if e != nil {
+			log.WithError(e).Infof("polling wallet directory every %s", interval)
+		} else {
+			events, closeEvents = ch, closeFn
+		}
+	}
+	go m.watchDir(interval, events, closeEvents, stop)
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// watchDir reloads the root directory as changes are notified by the events
+// (once, as the watch starts, for changes that preceded it), or at the
+// interval if there are no events.
+func (m *Manager) watchDir(interval time.Duration, events <-chan struct{}, closeEvents func(), stop chan struct{}) {
+	defer closeEvents()
+
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+		settle <-chan time.Time
+	)
+	poll := func() {
+		ticker = time.NewTicker(interval)
+		tick = ticker.C
+	}
+	if events == nil {
+		poll()
+	} else {
+		settle = time.After(dirSettle)
+	}
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			m.reloadWatched(stop)
+		case _, ok := <-events:
+			if !ok {
+				log.Warningf("stopped watching wallet directory, polling every %s", interval)
+				events = nil
+				poll()
+				continue
+			}
+			if settle == nil {
+				settle = time.After(dirSettle)
+			}
+		case <-settle:
+			settle = nil
+			m.reloadWatched(stop)
+		}
+	}
+}
+
+// reloadWatched reloads the root directory, unless the watch is stopped
+// while waiting for the lock.
+func (m *Manager) reloadWatched(stop chan struct{}) {
+	defer m.lock()()
+
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	res, e := m.reload()
+	if e != nil {
+		log.WithError(e).Warning("failed to reload wallet directory")
+		return
+	}
+	for _, label := range res.Added {
+		log.Infof("loaded wallet file `%s` that appeared in the wallet directory", label)
+	}
+	for _, label := range res.Removed {
+		log.Infof("removed wallet `%s`, whose file is gone", label)
+	}
+}
+
+func (m *Manager) reload() (*ReloadResult, error) {
+	res := &ReloadResult{
+		Added:   make([]string, 0),
+		Removed: make([]string, 0),
+	}
+	present := make(map[string]bool)
+	e := RangeLabels(func(f io.Reader, label, fPath string, prefix Prefix) {
+		present[label] = true
+		if _, ok := m.wallets[label]; ok {
+			return
+		}
+		var modTime time.Time
+		if info, e := store.Stat(fPath); e == nil {
+			modTime = info.ModTime()
+		}
+		if t, ok := m.dirSkips[label]; ok && t.Equal(modTime) {
+			return
+		}
+		wallet, ok := m.loadFile(label, fPath, prefix)
+		if !ok {
+			m.dirSkips[label] = modTime
+			return
+		}
+		delete(m.dirSkips, label)
+		m.append(label, wallet)
+		res.Added = append(res.Added, label)
+	})
+	if e != nil {
+		return nil, e
+	}
+	for _, label := range append([]string(nil), m.labels...) {
+		if !present[label] && m.remove(label) {
+			res.Removed = append(res.Removed, label)
+		}
+	}
+	for label := range m.dirSkips {
+		if !present[label] {
+			delete(m.dirSkips, label)
+		}
+	}
+	return res, m.sort()
+}
+
+// stopDirWatch stops the directory watch, if any.
+func (m *Manager) stopDirWatch() {
+	if m.dirStop != nil {
+		close(m.dirStop)
+		m.dirStop = nil
+	}
+}
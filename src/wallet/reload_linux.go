@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+// dirEventMask are the inotify events that the directory watch needs: files that are
+// created, written, moved in or out, or deleted.
+const dirEventMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO |
+	unix.IN_MOVED_FROM | unix.IN_DELETE
+
+// notifyDir watches a directory through inotify, and signals the channel as
+// wallet files in the directory change (or as events are lost, when the
+// queue of the kernel overflows). Signals are coalesced while the channel is
+// not read, and the channel is closed when the watch fails or is closed.
+func notifyDir(dir string) (<-chan struct{}, func(), error) {
+	fd, e := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if e != nil {
+		return nil, nil, os.NewSyscallError("inotify_init1", e)
+	}
+	if _, e := unix.InotifyAddWatch(fd, dir, dirEventMask); e != nil {
+		unix.Close(fd)
+		return nil, nil, os.NewSyscallError("inotify_add_watch", e)
+	}
+	// The descriptor is non-blocking, so reads of the file are interrupted
+	// as it is closed.
+	f := os.NewFile(uintptr(fd), "inotify")
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			n, e := f.Read(buf)
+			if e != nil {
+				if !errors.Is(e, os.ErrClosed) {
+					log.WithError(e).Warning("failed to read events for wallet directory")
+				}
+				return
+			}
+			if dirEventsChanged(buf[:n]) {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events, func() { f.Close() }, nil
+}
+
+// dirEventsChanged determines whether inotify events concern wallet files.
+func dirEventsChanged(raw []byte) bool {
+	for len(raw) >= unix.SizeofInotifyEvent {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&raw[0]))
+		size := unix.SizeofInotifyEvent + int(event.Len)
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 || size > len(raw) {
+			return true
+		}
+		name := string(bytes.TrimRight(raw[unix.SizeofInotifyEvent:size], "\x00"))
+		if strings.HasSuffix(name, string(FileExt)) {
+			return true
+		}
+		raw = raw[size:]
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package wallet
+
+import "errors"
+
+// notifyDir watches a directory for changes to wallet files. Only the
+// inotify on Linux is supported, so other systems poll the directory.
+func notifyDir(dir string) (<-chan struct{}, func(), error) {
+	return nil, nil, errors.New("directory notifications are only supported on linux")
+}
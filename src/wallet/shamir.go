@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"strings"
+)
+
+const (
+	// MaxSeedShares is the maximum number of shares for a seed, as the shares
+	// are at distinct non-zero points of GF(256).
+	MaxSeedShares = 255
+
+	// SeedShareVersion is the version of the encoding for seed shares.
+	SeedShareVersion = 1
+
+	// seedShareCheckSize is the size of the checksum of the seed, in every
+	// share, so that reassembling from wrong shares fails rather than obtaining
+	// a wrong seed.
+	seedShareCheckSize = 4
+
+	// seedShareHeaderSize is for the version, threshold, point and checksum.
+	seedShareHeaderSize = 3 + seedShareCheckSize
+)
+
+var (
+	ErrInvalidSeedShare   = errors.New("invalid seed share")
+	ErrTooFewSeedShares   = errors.New("too few seed shares to reassemble the seed")
+	ErrSeedSharesMismatch = errors.New("seed shares are from different seeds")
+)
+
+// SplitSeed splits a seed into n shares with Shamir's secret sharing (over every
+// byte in GF(256)), of which any k reassemble the seed (see 'CombineSeed'),
+// while fewer reveal nothing about it. Shares are hex encoded, with the threshold
+// and a checksum of the seed.
+func SplitSeed(seed string, n, k int) ([]string, error) {
+	switch {
+	case seed == "":
+		return nil, errors.New("invalid seed")
+	case n < 2 || n > MaxSeedShares:
+		return nil, fmt.Errorf("number of shares needs to be between 2 and %d", MaxSeedShares)
+	case k < 2 || k > n:
+		return nil, errors.New("threshold needs to be between 2 and the number of shares")
+	}
+	secret := []byte(seed)
+	sum := cipher.SumSHA256(secret)
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, seedShareHeaderSize, seedShareHeaderSize+len(secret))
+		shares[i][0], shares[i][1], shares[i][2] = SeedShareVersion, byte(k), byte(i+1)
+		copy(shares[i][3:], sum[:seedShareCheckSize])
+	}
+	coeffs := make([]byte, k)
+	defer zero(coeffs)
+	for _, b := range secret {
+		coeffs[0] = b
+		if _, e := rand.Read(coeffs[1:]); e != nil {
+			return nil, e
+		}
+		for i := range shares {
+			shares[i] = append(shares[i], gfEval(coeffs, byte(i+1)))
+		}
+	}
+	out := make([]string, n)
+	for i, share := range shares {
+		out[i] = hex.EncodeToString(share)
+	}
+	return out, nil
+}
+
+// CombineSeed reassembles a seed from shares of 'SplitSeed', with at least as
+// many shares as their threshold. Duplicate shares are counted once.
+func CombineSeed(shares []string) (string, error) {
+	var (
+		parsed [][]byte
+		seen   = make(map[byte]bool)
+	)
+	for _, s := range shares {
+		raw, e := hex.DecodeString(strings.TrimSpace(s))
+		if e != nil || len(raw) <= seedShareHeaderSize || raw[0] != SeedShareVersion ||
+			raw[1] < 2 || raw[2] == 0 {
+			return "", fmt.Errorf("%w: '%s'", ErrInvalidSeedShare, s)
+		}
+		if len(parsed) != 0 {
+			first := parsed[0]
+			if raw[1] != first[1] || len(raw) != len(first) ||
+				!bytes.Equal(raw[3:seedShareHeaderSize], first[3:seedShareHeaderSize]) {
+				return "", ErrSeedSharesMismatch
+			}
+		}
+		if !seen[raw[2]] {
+			seen[raw[2]] = true
+			parsed = append(parsed, raw)
+		}
+	}
+	if len(parsed) == 0 || len(parsed) < int(parsed[0][1]) {
+		return "", ErrTooFewSeedShares
+	}
+	parsed = parsed[:parsed[0][1]]
+
+	// Lagrange interpolation at zero, for every byte.
+	secret := make([]byte, len(parsed[0])-seedShareHeaderSize)
+	for i, share := range parsed {
+		basis := byte(1)
+		for j, other := range parsed {
+			if i != j {
+				basis = gfMul(basis, gfDiv(other[2], other[2]^share[2]))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(share[seedShareHeaderSize+b], basis)
+		}
+	}
+	if sum := cipher.SumSHA256(secret); !bytes.Equal(sum[:seedShareCheckSize], parsed[0][3:seedShareHeaderSize]) {
+		return "", fmt.Errorf("%w: checksum of the seed does not match", ErrInvalidSeedShare)
+	}
+	return string(secret), nil
+}
+
+// SplitWalletSeed splits the seed of the (unlocked) wallet of specified label
+// into shares (see 'SplitSeed'). The seed covers the entries and accounts of
+// the wallet, but not imported keys, which are only in the wallet file.
+func (m *Manager) SplitWalletSeed(label string, n, k int) ([]string, error) {
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	if e := w.checkHold(); e != nil {
+		return nil, e
+	}
+	shares, e := SplitSeed(w.Meta.Seed, n, k)
+	if e != nil {
+		return nil, e
+	}
+	m.audit(label, AuditExport, fmt.Sprintf("seed shares of %d of %d", k, n))
+	return shares, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// Tables of GF(256) with the polynomial of AES (x^8 + x^4 + x^3 + x + 1), for
+// the generator 3.
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i], gfExp[i+255] = x, x
+		gfLog[x] = byte(i)
+		// Multiplies by 3, as x*2 (reduced by the polynomial) xor x.
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// gfEval evaluates the polynomial with the coefficients (from the lowest degree
+// first) at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var out byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		out = gfMul(out, x) ^ coeffs[i]
+	}
+	return out
+}
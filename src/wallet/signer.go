@@ -0,0 +1,154 @@
+package wallet
+
+import (
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"sort"
+)
+
+// Signer signs for addresses with keys that it holds. Wallet files are signers
+// for their entries (see 'Manager.Signer'), and hardware wallets implement it
+// to sign on the device, so that their keys never leave it.
+type Signer interface {
+	// Label identifies the signer, as the label of a wallet.
+	Label() string
+
+	// Entries obtains the addresses (and public keys) that the signer signs
+	// for. Secret keys are never included.
+	Entries() ([]Entry, error)
+
+	// SignHash signs a hash with the key for an address.
+	SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error)
+}
+
+// SignTx signs a transaction with the signer, with the key for the address.
+func SignTx(s Signer, addr cipher.Address, tx *iko.Transaction) error {
+	sig, e := s.SignHash(addr, tx.SignatureHash())
+	if e != nil {
+		return e
+	}
+	return tx.AttachSignature(sig)
+}
+
+// AddSigner registers an external (such as hardware) signer, whose
+// addresses are listed as a wallet with the signer's label.
+func (m *Manager) AddSigner(s Signer) error {
+	defer m.lock()()
+
+	label := s.Label()
+	if e := VerifyLabel(label); e != nil {
+		return e
+	}
+	if _, ok := m.wallets[label]; ok {
+		return ErrLabelAlreadyExists
+	}
+	if _, ok := m.signers[label]; ok {
+		return ErrLabelAlreadyExists
+	}
+	m.signers[label] = s
+	return nil
+}
+
+// RemoveSigner removes an external signer.
+func (m *Manager) RemoveSigner(label string) error {
+	defer m.lock()()
+
+	if _, ok := m.signers[label]; !ok {
+		return ErrWalletNotFound
+	}
+	delete(m.signers, label)
+	delete(m.holdings, label)
+	return nil
+}
+
+// Signer obtains the signer of specified label, either from a wallet file or
+// an external signer. Signers for encrypted wallet files fail with
+// 'ErrWalletLocked' while the wallet is locked.
+func (m *Manager) Signer(label string) (Signer, error) {
+	defer m.lock()()
+
+	if s, ok := m.signers[label]; ok {
+		return s, nil
+	}
+	if _, e := m.getWallet(label); e != nil {
+		return nil, e
+	}
+	return &walletSigner{m: m, label: label}, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// walletSigner is the signer for a wallet file of the manager. The wallet is
+// obtained for each signature, so locking the wallet also stops the signer.
+type walletSigner struct {
+	m     *Manager
+	label string
+}
+
+func (s *walletSigner) Label() string {
+	return s.label
+}
+
+func (s *walletSigner) Entries() ([]Entry, error) {
+	defer s.m.lock()()
+
+	w, e := s.m.getWallet(s.label)
+	if e != nil {
+		return nil, e
+	}
+	return w.publicEntries(), nil
+}
+
+func (s *walletSigner) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	return s.m.SignHash(s.label, addr, hash)
+}
+
+// publicEntries obtains the entries (imported entries, and entries in
+// accounts) of the wallet, without secret keys.
+func (w *Wallet) publicEntries() []Entry {
+	out := make([]Entry, 0, len(w.Entries)+len(w.Imported))
+	for _, entry := range w.Entries {
+		out = append(out, Entry{Address: entry.Address, PubKey: entry.PubKey})
+	}
+	for _, imported := range w.Imported {
+		out = append(out, Entry{Address: imported.Entry.Address, PubKey: imported.Entry.PubKey})
+	}
+	for _, a := range w.Accounts {
+		for _, entry := range a.Entries {
+			out = append(out, Entry{Address: entry.Address, PubKey: entry.PubKey})
+		}
+	}
+	return out
+}
+
+// signerWallet obtains the floating wallet for an external signer.
+func signerWallet(s Signer) (*FloatingWallet, error) {
+	entries, e := s.Entries()
+	if e != nil {
+		return nil, e
+	}
+	fw := &FloatingWallet{
+		Meta:     FloatingMeta{Label: s.Label()},
+		Hardware: true,
+		Entries:  make([]*FloatingEntry, len(entries)),
+		Imported: []*FloatingEntry{},
+		Accounts: []*FloatingAccount{},
+	}
+	for i, entry := range entries {
+		entry.SecKey = cipher.SecKey{}
+		fw.Entries[i] = entry.ToFloating()
+	}
+	return fw, nil
+}
+
+func (m *Manager) signerStats(out []Stat) []Stat {
+	for label := range m.signers {
+		out = append(out, Stat{Label: label, Hardware: true})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Label < out[j].Label
+	})
+	return out
+}
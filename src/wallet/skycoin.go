@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"io"
+	"strings"
+)
+
+// MaxSkycoinWalletSize is the maximum size of a Skycoin wallet file.
+const MaxSkycoinWalletSize = 16 << 20
+
+var ErrSkycoinEncrypted = errors.New("skycoin wallet is encrypted, decrypt it with skycoin first")
+
+// SkycoinWallet is a wallet in the '.wlt' (JSON) format of Skycoin wallets.
+type SkycoinWallet struct {
+	Meta    map[string]string `json:"meta"`
+	Entries []SkycoinEntry    `json:"entries"`
+}
+
+// SkycoinEntry is an entry in a Skycoin wallet.
+type SkycoinEntry struct {
+	Address string `json:"address"`
+	PubKey  string `json:"public_key"`
+	SecKey  string `json:"secret_key"`
+}
+
+// SkycoinImport is the result of importing a Skycoin wallet.
+type SkycoinImport struct {
+	Wallet     *FloatingWallet    `json:"wallet"`
+	Derived    int                `json:"derived"`    // Entries from the seed of the Skycoin wallet.
+	Imported   int                `json:"imported"`   // Keys imported, as not from the seed.
+	Skipped    []string           `json:"skipped"`    // Addresses already in the wallet (or merged).
+	Duplicates []DuplicateAddress `json:"duplicates"` // Addresses of other wallets.
+}
+
+// ParseSkycoinWallet parses a Skycoin wallet file, and checks that the
+// secret key of every entry matches its address. Encrypted Skycoin wallets
+// (of version 0.2) are rejected with 'ErrSkycoinEncrypted'.
+func ParseSkycoinWallet(r io.Reader) (*SkycoinWallet, error) {
+	var sw SkycoinWallet
+	if e := json.NewDecoder(io.LimitReader(r, MaxSkycoinWalletSize)).Decode(&sw); e != nil {
+		return nil, fmt.Errorf("invalid skycoin wallet: %v", e)
+	}
+	if coin := sw.Meta["coin"]; coin != "" && coin != "skycoin" {
+		return nil, fmt.Errorf("invalid skycoin wallet for coin '%s'", coin)
+	}
+	if sw.Meta["encrypted"] == "true" {
+		return nil, ErrSkycoinEncrypted
+	}
+	for i, entry := range sw.Entries {
+		sk, e := cipher.SecKeyFromHex(strings.TrimSpace(entry.SecKey))
+		if e != nil {
+			return nil, fmt.Errorf("invalid secret key in skycoin entry %d: %v", i, e)
+		}
+		if e := sk.Verify(); e != nil {
+			return nil, fmt.Errorf("invalid secret key in skycoin entry %d: %v", i, e)
+		}
+		if addr := cipher.AddressFromSecKey(sk).String(); addr != entry.Address {
+			return nil, fmt.Errorf("secret key in skycoin entry %d does not match address '%s'", i, entry.Address)
+		}
+	}
+	return &sw, nil
+}
+
+// Seed obtains the seed of the Skycoin wallet, which is empty unless it is
+// deterministic.
+func (sw *SkycoinWallet) Seed() string {
+	if sw.Meta["type"] != "deterministic" {
+		return ""
+	}
+	return sw.Meta["seed"]
+}
+
+// Label obtains the label of the Skycoin wallet.
+func (sw *SkycoinWallet) Label() string {
+	return sw.Meta["label"]
+}
+
+// CreateSkycoinWallet creates a wallet from a Skycoin wallet, with the options
+// but without a seed, as the wallet uses the seed of the Skycoin wallet.
+// Skycoin derives keys the same way, so the entries from the seed have the
+// same addresses; other entries are imported as keys (with the label of the
+// Skycoin wallet). Addresses of other wallets are reported, and not
+// rejected.
+func (m *Manager) CreateSkycoinWallet(sw *SkycoinWallet, opts *Options) (*SkycoinImport, error) {
+	if opts.Seed != "" || opts.WatchOnly {
+		return nil, errors.New("wallets from skycoin wallets use the seed of the skycoin wallet")
+	}
+	seed := sw.Seed()
+	if seed == "" {
+		return nil, errors.New("skycoin wallet has no seed, import its keys into a wallet instead")
+	}
+	o := *opts
+	o.Seed, o.SeedPhrase = seed, false
+
+	defer m.lock()()
+
+	if _, ok := m.wallets[o.Label]; ok {
+		return nil, ErrLabelAlreadyExists
+	}
+	if _, ok := m.signers[o.Label]; ok {
+		return nil, ErrLabelAlreadyExists
+	}
+	if m.config.RequireEncrypted && !o.Encrypted {
+		return nil, ErrUnencryptedWallet
+	}
+	if o.Encrypted {
+		if e := m.checkPasswordPolicy(o.Password, o.Label, o.Name); e != nil {
+			return nil, e
+		}
+	}
+	w, e := NewFloatingWallet(&o)
+	if e != nil {
+		return nil, e
+	}
+	res := &SkycoinImport{
+		Derived: skycoinDerived(seed, sw.Entries),
+		Skipped: make([]string, 0),
+	}
+	if e := w.EnsureEntries(res.Derived); e != nil {
+		return nil, e
+	}
+	if e := m.importSkycoinEntries(w, sw, res, DuplicateAllow); e != nil {
+		return nil, e
+	}
+	m.append(o.Label, w)
+	if w.Meta.Encrypted {
+		m.watchUnlock(o.Label, DefaultUnlockTTL)
+	}
+	if e := m.sort(); e != nil {
+		return nil, e
+	}
+	res.Wallet = w.ToFloating()
+	return res, nil
+}
+
+// ImportSkycoinKeys imports the keys of a Skycoin wallet into the (unlocked)
+// wallet of specified label, with the label of the Skycoin wallet. Keys for
+// addresses that are already in the wallet are skipped, and the policy
+// determines what happens to keys for addresses of other wallets (as with
+// 'ImportWalletKeyWithPolicy'). The wallet is saved once, with every key.
+func (m *Manager) ImportSkycoinKeys(label string, sw *SkycoinWallet, policy DuplicatePolicy) (*SkycoinImport, error) {
+	if policy > DuplicateAllow {
+		return nil, fmt.Errorf("invalid duplicate policy '%d'", policy)
+	}
+
+	defer m.lock()()
+
+	w, e := m.getWallet(label)
+	if e != nil {
+		return nil, e
+	}
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	res := &SkycoinImport{
+		Skipped: make([]string, 0),
+	}
+	if e := m.importSkycoinEntries(w, sw, res, policy); e != nil {
+		return nil, e
+	}
+	res.Wallet = w.ToFloating()
+	return res, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// skycoinDerived obtains the number of leading entries that are derived from
+// the seed.
+func skycoinDerived(seed string, entries []SkycoinEntry) int {
+	next := []byte(seed)
+	for i, entry := range entries {
+		var sk cipher.SecKey
+		next, _, sk = cipher.DeterministicKeyPairIterator(next)
+		if cipher.AddressFromSecKey(sk).String() != entry.Address {
+			return i
+		}
+	}
+	return len(entries)
+}
+
+// importSkycoinEntries imports the keys of the entries (after the derived
+// entries) into the wallet, and saves it. The wallet is left as it was if
+// it fails.
+func (m *Manager) importSkycoinEntries(w *Wallet, sw *SkycoinWallet, res *SkycoinImport, policy DuplicatePolicy) error {
+	var (
+		keyLabel = sw.Label()
+		imported []ImportedEntry
+		addrs    []cipher.Address
+	)
+	if len(keyLabel) > MaxNameSize {
+		keyLabel = keyLabel[:MaxNameSize]
+	}
+	seen := make(map[cipher.Address]bool)
+	for _, se := range sw.Entries[res.Derived:] {
+		sk, _ := cipher.SecKeyFromHex(strings.TrimSpace(se.SecKey))
+		entry, e := NewEntry(sk)
+		if e != nil {
+			return e
+		}
+		if w.HasAddress(entry.Address) || seen[entry.Address] {
+			res.Skipped = append(res.Skipped, entry.Address.String())
+			continue
+		}
+		seen[entry.Address] = true
+		imported = append(imported, ImportedEntry{Label: keyLabel, Entry: *entry})
+		addrs = append(addrs, entry.Address)
+	}
+	for _, entry := range w.Entries[:res.Derived] {
+		addrs = append(addrs, entry.Address)
+	}
+	res.Duplicates = m.duplicatesOf(w.Meta.Label, addrs)
+
+	switch {
+	case len(res.Duplicates) == 0 || policy == DuplicateAllow:
+	case policy == DuplicateReject:
+		return &DuplicateError{Label: w.Meta.Label, Duplicates: res.Duplicates}
+	case policy == DuplicateMerge:
+		dups := make(map[string]bool, len(res.Duplicates))
+		for _, d := range res.Duplicates {
+			dups[d.Address] = true
+		}
+		kept := imported[:0]
+		for _, ie := range imported {
+			if dups[ie.Entry.Address.String()] {
+				res.Skipped = append(res.Skipped, ie.Entry.Address.String())
+			} else {
+				kept = append(kept, ie)
+			}
+		}
+		imported = kept
+	}
+
+	n := len(w.Imported)
+	w.Imported = append(w.Imported, imported...)
+	if e := w.Save(); e != nil {
+		w.Imported = w.Imported[:n]
+		return e
+	}
+	res.Imported = len(imported)
+	return nil
+}
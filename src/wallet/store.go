@@ -0,0 +1,396 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store stores the files in the root directory (wallet files, and the trash,
+// quarantine and address book for them), at absolute paths. Files are only
+// accessible by the user.
+type Store interface {
+	ReadFile(fPath string) ([]byte, error)
+	WriteFile(fPath string, data []byte) error  // Replaces atomically.
+	AppendFile(fPath string, data []byte) error // Creates the file if it does not exist.
+	Rename(oldPath, newPath string) error
+	Remove(fPath string) error // Of files, or of empty directories.
+	RemoveAll(fPath string) error
+	ReadDir(dir string) ([]os.FileInfo, error) // Sorted by name.
+	Stat(fPath string) (os.FileInfo, error)
+	MkdirAll(dir string) error
+
+	// Wipe overwrites the contents of a file with random data, before it is
+	// removed.
+	Wipe(fPath string) error
+
+	// Lock locks the root directory against the managers of other processes (see
+	// 'ErrDirLocked'). The returned function releases it.
+	Lock(dir string) (func(), error)
+}
+
+// This holds the store for the wallet files.
+var store Store = DirStore{}
+
+// SetStore sets the store for the wallet files, which is a 'DirStore' by
+// default. It is to be set before managers are created, such as to a
+// 'MemoryStore' so that tests (and test mode) never write key material to
+// disk.
+func SetStore(s Store) {
+	store = s
+}
+
+// DirStore stores the files in the file system.
+type DirStore struct{}
+
+func (DirStore) ReadFile(fPath string) ([]byte, error) {
+	return ioutil.ReadFile(fPath)
+}
+
+// WriteFile replaces the file atomically, with a temporary file that is synced
+// and renamed over it (so the file has either the old or the new data).
+func (DirStore) WriteFile(fPath string, data []byte) error {
+	tmp := fPath + ".tmp"
+	f, e := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0600))
+	if e != nil {
+		return e
+	}
+	if _, e = f.Write(data); e == nil {
+		e = f.Sync()
+	}
+	if e2 := f.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Rename(tmp, fPath)
+	}
+	if e != nil {
+		os.Remove(tmp)
+	}
+	return e
+}
+
+// AppendFile appends to the file, and syncs it.
+func (DirStore) AppendFile(fPath string, data []byte) error {
+	f, e := os.OpenFile(fPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.FileMode(0600))
+	if e != nil {
+		return e
+	}
+	if _, e = f.Write(data); e == nil {
+		e = f.Sync()
+	}
+	if e2 := f.Close(); e == nil {
+		e = e2
+	}
+	return e
+}
+
+func (DirStore) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (DirStore) Remove(fPath string) error {
+	return os.Remove(fPath)
+}
+
+func (DirStore) RemoveAll(fPath string) error {
+	return os.RemoveAll(fPath)
+}
+
+func (DirStore) ReadDir(dir string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dir)
+}
+
+func (DirStore) Stat(fPath string) (os.FileInfo, error) {
+	return os.Stat(fPath)
+}
+
+func (DirStore) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, os.FileMode(0700))
+}
+
+// Wipe overwrites the file in place, and syncs it. This does not reach copies
+// that the file system or storage keeps elsewhere.
+func (DirStore) Wipe(fPath string) error {
+	f, e := os.OpenFile(fPath, os.O_WRONLY, 0)
+	if e != nil {
+		return e
+	}
+	info, e := f.Stat()
+	if e == nil {
+		_, e = io.CopyN(f, rand.Reader, info.Size())
+	}
+	if e == nil {
+		e = f.Sync()
+	}
+	if e2 := f.Close(); e == nil {
+		e = e2
+	}
+	return e
+}
+
+// Lock holds an advisory lock on the lock file of the directory (see
+// 'LockFileName').
+func (DirStore) Lock(dir string) (func(), error) {
+	return acquireDirLock(dir)
+}
+
+// MemoryStore stores the files in memory, so they are gone once the process
+// exits. Removed files are zeroed. Managers with the same store share its
+// files, as if in the same directory.
+type MemoryStore struct {
+	mux   sync.RWMutex
+	files map[string]*memoryFile
+	dirs  map[string]time.Time // With the modification times.
+}
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemoryStore creates an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		files: make(map[string]*memoryFile),
+		dirs:  make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) ReadFile(fPath string) ([]byte, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	f, ok := s.files[filepath.Clean(fPath)]
+	if !ok {
+		return nil, notExist("open", fPath)
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (s *MemoryStore) WriteFile(fPath string, data []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	fPath = filepath.Clean(fPath)
+	if _, ok := s.dirs[filepath.Dir(fPath)]; !ok {
+		return notExist("open", fPath)
+	}
+	if _, ok := s.dirs[fPath]; ok {
+		return &os.PathError{Op: "open", Path: fPath, Err: os.ErrExist}
+	}
+	if old, ok := s.files[fPath]; ok {
+		zero(old.data)
+	}
+	s.files[fPath] = &memoryFile{
+		data:    append([]byte(nil), data...),
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) AppendFile(fPath string, data []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	fPath = filepath.Clean(fPath)
+	if _, ok := s.dirs[filepath.Dir(fPath)]; !ok {
+		return notExist("open", fPath)
+	}
+	if _, ok := s.dirs[fPath]; ok {
+		return &os.PathError{Op: "open", Path: fPath, Err: os.ErrExist}
+	}
+	f, ok := s.files[fPath]
+	if !ok {
+		f = &memoryFile{}
+		s.files[fPath] = f
+	}
+	f.data = append(f.data, data...)
+	f.modTime = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) Rename(oldPath, newPath string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	f, ok := s.files[oldPath]
+	if !ok {
+		return notExist("rename", oldPath)
+	}
+	if _, ok := s.dirs[filepath.Dir(newPath)]; !ok {
+		return notExist("rename", newPath)
+	}
+	if old, ok := s.files[newPath]; ok && old != f {
+		zero(old.data)
+	}
+	delete(s.files, oldPath)
+	s.files[newPath] = f
+	return nil
+}
+
+func (s *MemoryStore) Remove(fPath string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	fPath = filepath.Clean(fPath)
+	if f, ok := s.files[fPath]; ok {
+		zero(f.data)
+		delete(s.files, fPath)
+		return nil
+	}
+	if _, ok := s.dirs[fPath]; !ok {
+		return notExist("remove", fPath)
+	}
+	if len(s.children(fPath)) != 0 {
+		return &os.PathError{Op: "remove", Path: fPath, Err: os.ErrExist}
+	}
+	delete(s.dirs, fPath)
+	return nil
+}
+
+func (s *MemoryStore) RemoveAll(fPath string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	fPath = filepath.Clean(fPath)
+	for name, f := range s.files {
+		if name == fPath || isUnder(name, fPath) {
+			zero(f.data)
+			delete(s.files, name)
+		}
+	}
+	for name := range s.dirs {
+		if name == fPath || isUnder(name, fPath) {
+			delete(s.dirs, name)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReadDir(dir string) ([]os.FileInfo, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	dir = filepath.Clean(dir)
+	if _, ok := s.dirs[dir]; !ok {
+		return nil, notExist("open", dir)
+	}
+	out := s.children(dir)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name() < out[j].Name()
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) Stat(fPath string) (os.FileInfo, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	fPath = filepath.Clean(fPath)
+	if f, ok := s.files[fPath]; ok {
+		return memoryInfo{name: filepath.Base(fPath), size: len(f.data), modTime: f.modTime}, nil
+	}
+	if t, ok := s.dirs[fPath]; ok {
+		return memoryInfo{name: filepath.Base(fPath), dir: true, modTime: t}, nil
+	}
+	return nil, notExist("stat", fPath)
+}
+
+func (s *MemoryStore) MkdirAll(dir string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		if _, ok := s.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		if _, ok := s.dirs[dir]; !ok {
+			s.dirs[dir] = time.Now()
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil
+		}
+	}
+}
+
+// Wipe overwrites the data of the file in place.
+func (s *MemoryStore) Wipe(fPath string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	f, ok := s.files[filepath.Clean(fPath)]
+	if !ok {
+		return notExist("open", fPath)
+	}
+	_, e := rand.Read(f.data)
+	return e
+}
+
+// Lock does nothing, as the files are only in this process.
+func (s *MemoryStore) Lock(dir string) (func(), error) {
+	return func() {}, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// children obtains the files and directories directly under a directory.
+func (s *MemoryStore) children(dir string) []os.FileInfo {
+	var out []os.FileInfo
+	for name, f := range s.files {
+		if filepath.Dir(name) == dir {
+			out = append(out, memoryInfo{name: filepath.Base(name), size: len(f.data), modTime: f.modTime})
+		}
+	}
+	for name, t := range s.dirs {
+		if name != dir && filepath.Dir(name) == dir {
+			out = append(out, memoryInfo{name: filepath.Base(name), dir: true, modTime: t})
+		}
+	}
+	return out
+}
+
+// memoryInfo is the 'os.FileInfo' of files in a memory store.
+type memoryInfo struct {
+	name    string
+	size    int
+	dir     bool
+	modTime time.Time
+}
+
+func (i memoryInfo) Name() string       { return i.name }
+func (i memoryInfo) Size() int64        { return int64(i.size) }
+func (i memoryInfo) ModTime() time.Time { return i.modTime }
+func (i memoryInfo) IsDir() bool        { return i.dir }
+func (i memoryInfo) Sys() interface{}   { return nil }
+
+func (i memoryInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | os.FileMode(0700)
+	}
+	return os.FileMode(0600)
+}
+
+func notExist(op, fPath string) error {
+	return &os.PathError{Op: op, Path: fPath, Err: os.ErrNotExist}
+}
+
+func isUnder(fPath, dir string) bool {
+	rel, e := filepath.Rel(dir, fPath)
+	return e == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+func zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
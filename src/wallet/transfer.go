@@ -0,0 +1,86 @@
+package wallet
+
+import (
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// SignTransfer builds a transfer of a kitty to an address, from the address
+// of the wallet that owns it, and signs it with the key of that address. The
+// transfer is on top of the head of the chain, with the owner's next nonce and
+// the transfer fee of the chain, so it is ready to be injected. Kitties at
+// addresses of other wallets fail with 'iko.ErrNotOwner'.
+func (w *Wallet) SignTransfer(bc *iko.BlockChain, kittyID iko.KittyID, to cipher.Address) (*iko.Transaction, error) {
+	tx, e := newTransfer(bc, kittyID, to)
+	if e != nil {
+		return nil, e
+	}
+	if !w.HasAddress(tx.From) {
+		return nil, notOwnerError(kittyID, tx.From)
+	}
+	sk, e := w.secKeyOf(tx.From)
+	if e != nil {
+		return nil, e
+	}
+	if e := tx.AttachSignature(cipher.SignHash(tx.SignatureHash(), sk)); e != nil {
+		return nil, e
+	}
+	return tx, nil
+}
+
+// SignTransfer signs a transfer of a kitty to an address (as with
+// 'Wallet.SignTransfer') with the wallet (or external signer) of specified
+// label. Encrypted wallets need to be unlocked.
+func (m *Manager) SignTransfer(bc *iko.BlockChain, label string, kittyID iko.KittyID, to cipher.Address) (*iko.Transaction, error) {
+	s, e := m.Signer(label)
+	if e != nil {
+		return nil, e
+	}
+	tx, e := newTransfer(bc, kittyID, to)
+	if e != nil {
+		return nil, e
+	}
+	entries, e := s.Entries()
+	if e != nil {
+		return nil, e
+	}
+	owned := false
+	for _, entry := range entries {
+		owned = owned || entry.Address == tx.From
+	}
+	if !owned {
+		return nil, notOwnerError(kittyID, tx.From)
+	}
+	if e := SignTx(s, tx.From, tx); e != nil {
+		return nil, e
+	}
+	return tx, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+// newTransfer builds an unsigned transfer of a kitty, from its owner.
+func newTransfer(bc *iko.BlockChain, kittyID iko.KittyID, to cipher.Address) (*iko.Transaction, error) {
+	state, ok := bc.GetKittyState(kittyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%d'", iko.ErrKittyUnknown, kittyID)
+	}
+	head, e := bc.GetHeadTx()
+	if e != nil {
+		return nil, e
+	}
+	tx := iko.NewUnsignedTransfer(&head, iko.KittyIDs{kittyID}, state.Address, to,
+		bc.NextNonce(state.Address))
+	if fee := bc.TransferFee(); fee != 0 {
+		tx.PayFee(fee)
+	}
+	return tx, nil
+}
+
+func notOwnerError(kittyID iko.KittyID, owner cipher.Address) error {
+	return fmt.Errorf("%w: kitty '%d' is owned by address '%s', which is not in wallet",
+		iko.ErrNotOwner, kittyID, owner)
+}
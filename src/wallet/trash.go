@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TrashDirName is the name of the directory (in the root directory) that
+	// deleted wallet files are moved to.
+	TrashDirName = "trash"
+
+	// DeleteTokenTTL is the duration that a delete confirmation token (see
+	// 'Manager.DeleteToken') is valid for.
+	DeleteTokenTTL = 5 * time.Minute
+)
+
+// TrashPeriod is the grace period that deleted wallets can be recovered
+// within (see 'Manager.RecoverWallet'). Managers purge wallets in the trash
+// after it, when created.
+var TrashPeriod = 7 * 24 * time.Hour
+
+var ErrInvalidDeleteToken = errors.New("invalid or expired delete confirmation token")
+
+// TrashedWallet is a deleted wallet in the trash.
+type TrashedWallet struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Deleted int64  `json:"deleted"` // Time of deletion.
+	Purge   int64  `json:"purge"`   // Time after which it is purged.
+}
+
+// deleteToken is a confirmation token for deleting a wallet.
+type deleteToken struct {
+	value   string
+	expires time.Time
+}
+
+// DeleteToken issues a confirmation token for deleting the wallet with the label,
+// which is valid for 'DeleteTokenTTL' and for a single deletion. Issuing
+// another token for the same label replaces it.
+func (m *Manager) DeleteToken(label string) (string, error) {
+	defer m.lock()()
+
+	if _, ok := m.wallets[label]; !ok {
+		return "", ErrWalletNotFound
+	}
+	raw := make([]byte, 16)
+	if _, e := rand.Read(raw); e != nil {
+		return "", e
+	}
+	token := hex.EncodeToString(raw)
+	m.deleteTokens[label] = deleteToken{
+		value:   token,
+		expires: time.Now().Add(DeleteTokenTTL),
+	}
+	return token, nil
+}
+
+// DeleteWallet deletes a wallet of a given label, with a confirmation token from
+// 'DeleteToken'. The wallet file (and the copy from its upgrade) is moved into
+// the trash, where it can be recovered within 'TrashPeriod'.
+func (m *Manager) DeleteWallet(label, confirmToken string) error {
+	defer m.lock()()
+
+	if _, ok := m.wallets[label]; !ok {
+		return ErrWalletNotFound
+	}
+	token, ok := m.deleteTokens[label]
+	if !ok || token.value != confirmToken || time.Now().After(token.expires) {
+		return ErrInvalidDeleteToken
+	}
+	delete(m.deleteTokens, label)
+
+	now := time.Now()
+	dir := filepath.Join(rootDir, TrashDirName, fmt.Sprintf("%d-%s", now.UnixNano(), label))
+	if e := store.MkdirAll(dir); e != nil {
+		return e
+	}
+	if e := store.Rename(LabelPath(label), filepath.Join(dir, label+string(FileExt))); e != nil {
+		store.Remove(dir)
+		return e
+	}
+	if e := store.Rename(BackupPath(label), filepath.Join(dir, label+string(FileExt)+BackupExt)); e != nil && !os.IsNotExist(e) {
+		log.WithError(e).Warningf("failed to move copy of wallet file `%s` into trash", label)
+	}
+	m.remove(label)
+	return nil
+}
+
+// ListTrash lists the deleted wallets in the trash, with the latest first.
+func (m *Manager) ListTrash() ([]TrashedWallet, error) {
+	defer m.lock()()
+	return listTrash()
+}
+
+// RecoverWallet moves a deleted wallet in the trash back, as the wallet with
+// its label. Recovered encrypted wallets are locked.
+func (m *Manager) RecoverWallet(id string) (*TrashedWallet, error) {
+	defer m.lock()()
+
+	tw, e := trashedWallet(id)
+	if e != nil {
+		return nil, e
+	}
+	if _, ok := m.wallets[tw.Label]; ok {
+		return nil, ErrLabelAlreadyExists
+	}
+	dir := filepath.Join(rootDir, TrashDirName, id)
+	raw, keystore, e := readWalletFile(filepath.Join(dir, tw.Label+string(FileExt)))
+	if e != nil {
+		return nil, e
+	}
+	w, e := checkBackupFile(BackupFile{Label: tw.Label, Data: raw})
+	if e != nil {
+		return nil, e
+	}
+	if w != nil {
+		w.Meta.Keystore = keystore
+	}
+	if m.config.RequireEncrypted && w != nil {
+		return nil, ErrUnencryptedWallet
+	}
+	if e := store.Rename(filepath.Join(dir, tw.Label+string(FileExt)), LabelPath(tw.Label)); e != nil {
+		return nil, e
+	}
+	store.Rename(filepath.Join(dir, tw.Label+string(FileExt)+BackupExt), BackupPath(tw.Label))
+	store.RemoveAll(dir)
+	m.append(tw.Label, w)
+	return tw, m.sort()
+}
+
+// PurgeTrash permanently removes the deleted wallets in the trash that were
+// deleted before a time. If secure, the files are overwritten with random data
+// before they are removed. This does not reach copies that the file system or
+// storage keeps elsewhere (such as in journals, snapshots or the wear
+// leveling of SSDs), so secrets of deleted wallets should still be moved to
+// new wallets.
+func (m *Manager) PurgeTrash(before time.Time, secure bool) ([]TrashedWallet, error) {
+	defer m.lock()()
+
+	list, e := listTrash()
+	if e != nil {
+		return nil, e
+	}
+	var out []TrashedWallet
+	for _, tw := range list {
+		if tw.Deleted >= before.UnixNano() {
+			continue
+		}
+		dir := filepath.Join(rootDir, TrashDirName, tw.ID)
+		files, e := store.ReadDir(dir)
+		if e != nil {
+			return out, e
+		}
+		for _, f := range files {
+			fPath := filepath.Join(dir, f.Name())
+			if e := deleteKeystoreOf(fPath); e != nil {
+				return out, e
+			}
+			if secure {
+				if e := store.Wipe(fPath); e != nil {
+					return out, e
+				}
+			}
+			if e := store.Remove(fPath); e != nil {
+				return out, e
+			}
+		}
+		if e := store.Remove(dir); e != nil {
+			return out, e
+		}
+		out = append(out, tw)
+	}
+	return out, nil
+}
+
+/*
+	<<< HELPERS >>>
+*/
+
+func listTrash() ([]TrashedWallet, error) {
+	list, e := store.ReadDir(filepath.Join(rootDir, TrashDirName))
+	if os.IsNotExist(e) {
+		return []TrashedWallet{}, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	out := make([]TrashedWallet, 0, len(list))
+	for _, info := range list {
+		if !info.IsDir() {
+			continue
+		}
+		if tw, e := trashedWallet(info.Name()); e == nil {
+			out = append(out, *tw)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Deleted > out[j].Deleted
+	})
+	return out, nil
+}
+
+// trashedWallet parses the ID of a deleted wallet, as "<time>-<label>".
+func trashedWallet(id string) (*TrashedWallet, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid trash id '%s'", id)
+	}
+	deleted, e := strconv.ParseInt(parts[0], 10, 64)
+	if e != nil {
+		return nil, fmt.Errorf("invalid trash id '%s'", id)
+	}
+	if e := VerifyLabel(parts[1]); e != nil {
+		return nil, fmt.Errorf("invalid trash id '%s'", id)
+	}
+	if _, e := store.Stat(filepath.Join(rootDir, TrashDirName, id)); e != nil {
+		return nil, ErrWalletNotFound
+	}
+	return &TrashedWallet{
+		ID:      id,
+		Label:   parts[1],
+		Deleted: deleted,
+		Purge:   time.Unix(0, deleted).Add(TrashPeriod).UnixNano(),
+	}, nil
+}
@@ -1,13 +1,13 @@
 package wallet
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
-	"github.com/skycoin/skycoin/src/util/file"
 	"io"
 	"io/ioutil"
-	"os"
 	"time"
 )
 
@@ -20,14 +20,51 @@ type (
 )
 
 const (
-	// Version determines the wallet file's version.
-	Version uint64 = 0
+	// Version determines the wallet file's version. Encrypted files of this
+	// version use AES-256-GCM, with a key derived from the password with
+	// scrypt (see 'DefaultScryptParams'), files carry the wallet's 'Info',
+	// imported keys, kitty notes, accounts and hold (see 'Wallet.Held'),
+	// and end with a checksum (see 'VerifyFile').
+	Version uint64 = 7
+
+	// NoHoldVersion is for files with the same encryption and checksum as
+	// 'Version', without the hold.
+	NoHoldVersion uint64 = 6
+
+	// NoAccountsVersion is for files with the same encryption and checksum as
+	// 'Version', without accounts.
+	NoAccountsVersion uint64 = 5
+
+	// NoNotesVersion is for files with the same encryption and checksum as
+	// 'Version', without kitty notes.
+	NoNotesVersion uint64 = 4
+
+	// NoChecksumVersion is for files with the same layout as 'NoNotesVersion',
+	// without the checksum.
+	NoChecksumVersion uint64 = 3
+
+	// NoImportVersion is for files with the same encryption as 'Version',
+	// without imported keys.
+	NoImportVersion uint64 = 2
+
+	// NoInfoVersion is for files with the same encryption as 'Version', without
+	// the wallet's 'Info' (or imported keys).
+	NoInfoVersion uint64 = 1
+
+	// LegacyVersion is for files that are encrypted with ChaCha20, with the
+	// SHA256 of the password. These are still loaded, and are re-encrypted
+	// in the current version when opened by the manager.
+	LegacyVersion uint64 = 0
 
 	// KittyAsset represents the "kittycash" asset type.
 	KittyAsset AssetType = "kittycash"
 
 	// FileExt is the kittycash file extension.
 	FileExt Extension = ".kcw"
+
+	// MaxNewAddresses is the maximum number of addresses from one call of
+	// 'Wallet.NewAddresses'.
+	MaxNewAddresses = 10000
 )
 
 /*
@@ -40,7 +77,10 @@ type FloatingMeta struct {
 	Encrypted bool   `json:"encrypted"`
 	Password  string `json:"-"`
 	Saved     bool   `json:"-"`
+	Keystore  string `json:"keystore,omitempty"`   // For the data of the file (see 'Keystore').
+	HoldUntil int64  `json:"hold_until,omitempty"` // Unix nanoseconds before which the wallet can not sign (see 'Wallet.Held').
 	Meta
+	Info
 }
 
 type Meta struct {
@@ -50,16 +90,73 @@ type Meta struct {
 }
 
 type FloatingWallet struct {
-	Meta    FloatingMeta     `json:"meta"`
-	Entries []*FloatingEntry `json:"entries"`
+	Meta      FloatingMeta       `json:"meta"`
+	WatchOnly bool               `json:"watch_only"`
+	Hardware  bool               `json:"hardware,omitempty"` // For an external signer (see 'Manager.AddSigner').
+	Entries   []*FloatingEntry   `json:"entries"`
+	Imported  []*FloatingEntry   `json:"imported"`
+	Notes     KittyNotes         `json:"kitty_notes"`
+	Accounts  []*FloatingAccount `json:"accounts"`
 }
 
 type Wallet struct {
-	Meta    FloatingMeta
-	Entries []Entry
+	Meta     FloatingMeta
+	Entries  []Entry
+	Imported []ImportedEntry // Keys that are imported, rather than derived from the seed.
+	Notes    KittyNotes
+	Accounts []Account // Named accounts, besides the default account in 'Entries'.
+
+	// next is the seed of the entry after the last, once derived.
+	next []byte
 }
 
 type File struct {
+	Meta      Meta
+	Entries   []Entry
+	Info      Info
+	Imported  []ImportedEntry
+	Notes     KittyNotes
+	Accounts  []Account
+	HoldUntil int64
+}
+
+// noHoldFile is the layout of files with 'NoHoldVersion'.
+type noHoldFile struct {
+	Meta     Meta
+	Entries  []Entry
+	Info     Info
+	Imported []ImportedEntry
+	Notes    KittyNotes
+	Accounts []Account
+}
+
+// noAccountsFile is the layout of files with 'NoAccountsVersion'.
+type noAccountsFile struct {
+	Meta     Meta
+	Entries  []Entry
+	Info     Info
+	Imported []ImportedEntry
+	Notes    KittyNotes
+}
+
+// noNotesFile is the layout of files with 'NoChecksumVersion' and
+// 'NoNotesVersion'.
+type noNotesFile struct {
+	Meta     Meta
+	Entries  []Entry
+	Info     Info
+	Imported []ImportedEntry
+}
+
+// noImportFile is the layout of files with 'NoImportVersion'.
+type noImportFile struct {
+	Meta    Meta
+	Entries []Entry
+	Info    Info
+}
+
+// noInfoFile is the layout of files with versions before 'NoImportVersion'.
+type noInfoFile struct {
 	Meta    Meta
 	Entries []Entry
 }
@@ -73,19 +170,68 @@ func (w File) Serialize() []byte {
 */
 
 type Options struct {
-	Label     string `json:"string"`
-	Seed      string `json:"seed"`
-	Encrypted bool   `json:"encrypted"`
-	Password  string `json:"password,omitempty"`
+	Label      string `json:"label"`
+	Name       string `json:"name"` // Display name (see 'Info').
+	Seed       string `json:"seed"`
+	SeedPhrase bool   `json:"seed_phrase"` // Whether the seed is a BIP39 seed phrase (see 'NewSeedPhrase').
+	Encrypted  bool   `json:"encrypted"`
+	Password   string `json:"password,omitempty"`
+	Addresses  int    `json:"addresses"`  // Number of addresses to generate (see 'Manager.CreateWallet').
+	Keystore   string `json:"keystore"`   // For the data of the file, 'KeystoreFile' if empty.
+	HoldUntil  int64  `json:"hold_until"` // Unix nanoseconds before which the wallet can not sign (see 'Wallet.Held').
+
+	// WatchOnly creates a wallet with the watched addresses alone, without a
+	// seed (or secret keys).
+	WatchOnly      bool     `json:"watch_only"`
+	WatchAddresses []string `json:"watch_addresses"`
 }
 
 func (o *Options) Verify() error {
-	if o.Label == "" {
-		return errors.New("invalid label")
+	if e := VerifyLabel(o.Label); e != nil {
+		return e
+	}
+	if len(o.Name) > MaxNameSize {
+		return fmt.Errorf("wallet name exceeds %d bytes", MaxNameSize)
+	}
+	if e := VerifyKeystore(o.Keystore); e != nil {
+		return e
+	}
+	if o.Addresses < 0 {
+		return errors.New("can not have negative number of addresses")
+	}
+	if o.WatchOnly {
+		return o.verifyWatchOnly()
+	}
+	if len(o.WatchAddresses) != 0 {
+		return errors.New("only watch-only wallets can have watched addresses")
 	}
 	if o.Seed == "" {
 		return errors.New("invalid seed")
 	}
+	if o.SeedPhrase {
+		if e := VerifySeedPhrase(NormalizeSeedPhrase(o.Seed)); e != nil {
+			return e
+		}
+	}
+	if o.Encrypted && o.Password == "" {
+		return errors.New("invalid password")
+	}
+	if o.HoldUntil != 0 && !o.Encrypted {
+		return ErrHoldUnencrypted
+	}
+	return nil
+}
+
+func (o *Options) verifyWatchOnly() error {
+	if o.Seed != "" || o.SeedPhrase {
+		return errors.New("watch-only wallet can not have a seed")
+	}
+	if o.Addresses != 0 {
+		return errors.New("watch-only wallet can not generate addresses")
+	}
+	if o.HoldUntil != 0 {
+		return errors.New("watch-only wallet can not be put on hold")
+	}
 	if o.Encrypted && o.Password == "" {
 		return errors.New("invalid password")
 	}
@@ -96,21 +242,44 @@ func NewFloatingWallet(options *Options) (*Wallet, error) {
 	if e := options.Verify(); e != nil {
 		return nil, e
 	}
+	seed := options.Seed
+	if options.SeedPhrase {
+		seed = NormalizeSeedPhrase(seed)
+	}
+	keystore := options.Keystore
+	if keystore == "" {
+		keystore = KeystoreFile
+	}
 
-	return &Wallet{
+	w := &Wallet{
 		Meta: FloatingMeta{
 			Version:   Version,
 			Label:     options.Label,
 			Encrypted: options.Encrypted,
 			Password:  options.Password,
+			Keystore:  keystore,
+			HoldUntil: options.HoldUntil,
 			Meta: Meta{
 				AssetType: KittyAsset,
-				Seed:      options.Seed,
+				Seed:      seed,
 				TS:        time.Now().UnixNano(),
 			},
+			Info: Info{
+				Name: options.Name,
+			},
 		},
 		Entries: []Entry{},
-	}, nil
+	}
+	for _, v := range options.WatchAddresses {
+		addr, e := cipher.DecodeBase58Address(v)
+		if e != nil {
+			return nil, fmt.Errorf("invalid watch address '%s': %v", v, e)
+		}
+		if e := w.WatchAddress(addr); e != nil {
+			return nil, e
+		}
+	}
+	return w, nil
 }
 
 func LoadFloatingWallet(f io.Reader, label, password string) (*Wallet, error) {
@@ -122,10 +291,26 @@ func LoadFloatingWallet(f io.Reader, label, password string) (*Wallet, error) {
 	if e != nil {
 		return nil, e
 	}
+	if prefix.Version() > NoChecksumVersion {
+		if raw, e = splitChecksum(raw); e != nil {
+			return nil, e
+		}
+		data = raw[PrefixSize:]
+	}
 	encrypted := prefix.Encrypted()
 	if encrypted {
-		pHash := cipher.SumSHA256([]byte(password))
-		data, e = cipher.Chacha20Decrypt(data, pHash[:], prefix.Nonce())
+		switch prefix.Version() {
+		case Version, NoHoldVersion, NoAccountsVersion, NoNotesVersion, NoChecksumVersion, NoImportVersion, NoInfoVersion:
+			data, e = decryptData(prefix[:], data, password)
+		case LegacyVersion:
+			if password == "" {
+				return nil, ErrPasswordRequired
+			}
+			pHash := cipher.SumSHA256([]byte(password))
+			data, e = cipher.Chacha20Decrypt(data, pHash[:], prefix.Nonce())
+		default:
+			e = fmt.Errorf("wallet file with version %d is not supported", prefix.Version())
+		}
 		if e != nil {
 			return nil, e
 		}
@@ -133,8 +318,11 @@ func LoadFloatingWallet(f io.Reader, label, password string) (*Wallet, error) {
 		password = ""
 	}
 
-	var wallet File
-	if e := encoder.DeserializeRaw(data, &wallet); e != nil {
+	wallet, e := decodeFile(prefix.Version(), data)
+	if e != nil {
+		if encrypted {
+			return nil, ErrInvalidPassword
+		}
 		return nil, e
 	}
 	return &Wallet{
@@ -143,58 +331,171 @@ func LoadFloatingWallet(f io.Reader, label, password string) (*Wallet, error) {
 			Label:     label,
 			Encrypted: encrypted,
 			Password:  password,
+			Saved:     true,
+			HoldUntil: wallet.HoldUntil,
 			Meta:      wallet.Meta,
+			Info:      wallet.Info,
 		},
-		Entries: wallet.Entries,
+		Entries:  wallet.Entries,
+		Imported: wallet.Imported,
+		Notes:    wallet.Notes,
+		Accounts: wallet.Accounts,
 	}, nil
 }
 
+// Save saves the wallet to its file, in the current version.
 func (w *Wallet) Save() error {
-	version := w.Meta.Version
-
+	// The nonce of the prefix only marks the file as encrypted, as the
+	// encrypted data carries its own nonce.
 	nonce := EmptyNonce()
 	if w.Meta.Encrypted {
 		nonce = RandNonce()
 	}
 
-	prefix := NewPrefix(version, nonce)
+	prefix := NewPrefix(Version, nonce)
 
 	data := w.ToFile().Serialize()
 	if w.Meta.Encrypted {
 		var e error
-		pHash := cipher.SumSHA256([]byte(w.Meta.Password))
-		data, e = cipher.Chacha20Encrypt(data, pHash[:], nonce)
-		if e != nil {
+		if data, e = encryptData(prefix[:], data, w.Meta.Password); e != nil {
 			return e
 		}
 	}
 
-	e := SaveBinary(
+	e := writeWalletFile(
 		LabelPath(w.Meta.Label),
-		append(prefix[:], data...),
+		w.Meta.Keystore,
+		appendChecksum(append(prefix[:], data...)),
 	)
 	if e != nil {
 		return e
 	}
 
+	w.Meta.Version = Version
 	w.Meta.Saved = true
 	return nil
 }
 
+// ChangePassword re-encrypts the wallet with a new password (with new scrypt
+// parameters and salt), and replaces its file. The old password is checked
+// against the file, and unencrypted wallets are encrypted with an empty old
+// password. If the file fails to be replaced, the wallet keeps the old
+// password.
+func (w *Wallet) ChangePassword(old, new string) error {
+	if new == "" {
+		return ErrPasswordRequired
+	}
+	if w.Meta.Encrypted {
+		raw, _, e := readWalletFile(LabelPath(w.Meta.Label))
+		if e != nil {
+			return e
+		}
+		if _, e := LoadFloatingWallet(bytes.NewReader(raw), w.Meta.Label, old); e != nil {
+			return e
+		}
+	} else if old != "" {
+		return errors.New("wallet is not encrypted")
+	}
+
+	encrypted, password := w.Meta.Encrypted, w.Meta.Password
+	w.Meta.Encrypted, w.Meta.Password = true, new
+	if e := w.Save(); e != nil {
+		w.Meta.Encrypted, w.Meta.Password = encrypted, password
+		return e
+	}
+	// Copies from upgrades (and from older versions of 'SaveBinary') have the
+	// old password.
+	store.Remove(BackupPath(w.Meta.Label))
+	return nil
+}
+
 func (w *Wallet) EnsureEntries(n int) error {
 	switch {
 	case n < 0:
 		return errors.New("can not have negative number of entries")
 	case n <= w.Count():
 		return nil
+	case w.IsWatchOnly():
+		return ErrWatchOnly
 	}
-	sks := cipher.GenerateDeterministicKeyPairs([]byte(w.Meta.Seed), n)
+	next, sks := cipher.GenerateDeterministicKeyPairsSeed([]byte(w.Meta.Seed), n)
 	w.Entries = make([]Entry, n)
 	for i := 0; i < n; i++ {
 		entry, _ := NewEntry(sks[i])
 		w.Entries[i] = *entry
 	}
 
+	w.next = next
+	w.Meta.Saved = false
+	return nil
+}
+
+// NewAddress derives the entry at the next index from the seed, and returns
+// its address. As every entry is derived from the seed, a backup of the seed
+// covers addresses that are created after the backup.
+func (w *Wallet) NewAddress() (cipher.Address, error) {
+	if w.IsWatchOnly() {
+		return cipher.Address{}, ErrWatchOnly
+	}
+	if w.next == nil {
+		w.next, _ = cipher.GenerateDeterministicKeyPairsSeed([]byte(w.Meta.Seed), w.Count())
+	}
+	next, _, sk := cipher.DeterministicKeyPairIterator(w.next)
+	entry, e := NewEntry(sk)
+	if e != nil {
+		return cipher.Address{}, e
+	}
+	w.Entries = append(w.Entries, *entry)
+	w.next = next
+	w.Meta.Saved = false
+	return entry.Address, nil
+}
+
+// NewAddresses derives the entries at the next n indexes from the seed (see
+// 'NewAddress'), and saves the wallet once for all of them, such as for deposit
+// addresses that are generated in advance. The wallet is left as it was if it
+// fails.
+func (w *Wallet) NewAddresses(n int) ([]cipher.Address, error) {
+	switch {
+	case n < 1 || n > MaxNewAddresses:
+		return nil, fmt.Errorf("number of addresses needs to be between 1 and %d", MaxNewAddresses)
+	case w.IsWatchOnly():
+		return nil, ErrWatchOnly
+	}
+	count, next, saved := len(w.Entries), w.next, w.Meta.Saved
+	addrs := make([]cipher.Address, n)
+	for i := range addrs {
+		addr, e := w.NewAddress()
+		if e != nil {
+			w.Entries, w.next, w.Meta.Saved = w.Entries[:count], next, saved
+			return nil, e
+		}
+		addrs[i] = addr
+	}
+	if e := w.Save(); e != nil {
+		w.Entries, w.next, w.Meta.Saved = w.Entries[:count], next, saved
+		return nil, e
+	}
+	return addrs, nil
+}
+
+// IsWatchOnly determines whether the wallet is without a seed, and only
+// watches addresses whose secret keys are elsewhere (such as cold
+// storage).
+func (w *Wallet) IsWatchOnly() bool {
+	return w.Meta.Seed == ""
+}
+
+// WatchAddress adds an address to a watch-only wallet. Addresses that are
+// already watched are ignored.
+func (w *Wallet) WatchAddress(addr cipher.Address) error {
+	if !w.IsWatchOnly() {
+		return errors.New("only watch-only wallets can watch addresses")
+	}
+	if w.HasAddress(addr) {
+		return nil
+	}
+	w.Entries = append(w.Entries, *NewWatchEntry(addr))
 	w.Meta.Saved = false
 	return nil
 }
@@ -205,19 +506,38 @@ func (w *Wallet) Count() int {
 
 func (w *Wallet) ToFile() *File {
 	return &File{
-		Meta:    w.Meta.Meta,
-		Entries: w.Entries,
+		Meta:      w.Meta.Meta,
+		Entries:   w.Entries,
+		Info:      w.Meta.Info,
+		Imported:  w.Imported,
+		Notes:     w.Notes,
+		Accounts:  w.Accounts,
+		HoldUntil: w.Meta.HoldUntil,
 	}
 }
 
 func (w *Wallet) ToFloating() *FloatingWallet {
 	fw := &FloatingWallet{
-		Meta:    w.Meta,
-		Entries: make([]*FloatingEntry, len(w.Entries)),
+		Meta:      w.Meta,
+		WatchOnly: w.IsWatchOnly(),
+		Entries:   make([]*FloatingEntry, len(w.Entries)),
+		Imported:  make([]*FloatingEntry, len(w.Imported)),
+		Notes:     append(KittyNotes{}, w.Notes...),
+		Accounts:  make([]*FloatingAccount, len(w.Accounts)),
 	}
 	for i, entry := range w.Entries {
 		fw.Entries[i] = entry.ToFloating()
 	}
+	for i, imported := range w.Imported {
+		fw.Imported[i] = imported.Entry.ToFloating()
+		fw.Imported[i].Label = imported.Label
+	}
+	for i := range w.Accounts {
+		fw.Accounts[i] = w.Accounts[i].ToFloating()
+	}
+	if w.Held() {
+		fw.redactSecrets()
+	}
 	return fw
 }
 
@@ -225,6 +545,47 @@ func (w *Wallet) ToFloating() *FloatingWallet {
 	<<< HELPERS >>>
 */
 
+// DeriveSecKey derives the secret key of the entry at an index (starting
+// from 0) from a wallet seed. Keys are derived as a chain, where the seed of
+// each key is the SHA256 of the seed of the previous key.
+func DeriveSecKey(seed string, index int) (cipher.SecKey, error) {
+	if index < 0 {
+		return cipher.SecKey{}, fmt.Errorf("invalid entry index '%d'", index)
+	}
+	next, _ := cipher.GenerateDeterministicKeyPairsSeed([]byte(seed), index)
+	_, _, sk := cipher.DeterministicKeyPairIterator(next)
+	return sk, nil
+}
+
+// decodeFile deserializes the data of a wallet file at a version, migrated to
+// the current version (see 'migrations'). The encoder panics on some malformed
+// data (such as legacy files decrypted with a wrong password), which is
+// returned as an error instead.
+func decodeFile(version uint64, data []byte) (out File, e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("malformed wallet file: %v", r)
+		}
+	}()
+	if data, e = migrateData(version, data); e != nil {
+		return
+	}
+	if e = encoder.DeserializeRaw(data, &out); e != nil {
+		return
+	}
+	if e = out.Info.Verify(); e != nil {
+		return
+	}
+	if e = out.Notes.Verify(); e != nil {
+		return
+	}
+	e = verifyAccounts(out.Accounts)
+	return
+}
+
+// SaveBinary replaces the file atomically, in the store of the wallet files
+// (see 'SetStore'). Unlike 'file.SaveBinary', no '.bak' copy of the old data
+// is kept, as that would keep keys under an old password (or before encryption).
 func SaveBinary(fn string, data []byte) error {
-	return file.SaveBinary(fn, data, os.FileMode(0600))
+	return store.WriteFile(fn, data)
 }
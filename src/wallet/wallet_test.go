@@ -1,12 +1,36 @@
 package wallet
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kittycash/wallet/src/iko"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/stretchr/testify/require"
+	"image/png"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
+// deleteWallet deletes a wallet with a confirmation token.
+func deleteWallet(m *Manager, label string) error {
+	token, e := m.DeleteToken(label)
+	if e != nil {
+		return e
+	}
+	return m.DeleteWallet(label, token)
+}
+
 func initTempDir(t *testing.T) func() {
 	dir, e := ioutil.TempDir("", "kittycash_test")
 	require.Empty(t, e, "failed to create temp dir")
@@ -72,3 +96,2168 @@ func TestFloatingWallet_Save(t *testing.T) {
 	}
 
 }
+
+func TestLoadFloatingWallet_Encryption(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	saveWallet(t, &Options{
+		Label:     "wallet0",
+		Seed:      "secure seed",
+		Encrypted: true,
+		Password:  "password",
+	})
+
+	raw, e := ioutil.ReadFile(LabelPath("wallet0"))
+	require.Nil(t, e, "failed to read wallet file")
+	prefix, _, e := ExtractPrefix(raw)
+	require.Nil(t, e, "failed to extract prefix")
+	require.Equal(t, Version, prefix.Version(), "wallet file should have the current version")
+	require.False(t, bytes.Contains(raw, []byte("secure seed")), "seed should not be in plaintext")
+
+	load := func(pw string) error {
+		f, e := os.Open(LabelPath("wallet0"))
+		require.Nil(t, e, "failed to open wallet file")
+		defer f.Close()
+		_, e = LoadFloatingWallet(f, "wallet0", pw)
+		return e
+	}
+	require.Equal(t, ErrPasswordRequired, load(""), "opening without a password should fail")
+	require.Equal(t, ErrInvalidPassword, load("wrong"), "opening with a wrong password should fail")
+
+	// Tampering that keeps the checksum is detected by the encryption.
+	tampered := append([]byte{}, raw[:len(raw)-ChecksumSize]...)
+	tampered[len(tampered)-1] ^= 1
+	require.Nil(t, SaveBinary(LabelPath("wallet0"), appendChecksum(tampered)), "failed to save wallet file")
+	require.Equal(t, ErrInvalidPassword, load("password"), "opening a tampered file should fail")
+
+	raw[len(raw)-ChecksumSize-1] ^= 1
+	require.Nil(t, SaveBinary(LabelPath("wallet0"), raw), "failed to save wallet file")
+	require.Equal(t, ErrChecksum, load("password"), "opening a corrupted file should fail")
+}
+
+func TestManager_UpgradeLegacyWallet(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	// Save a wallet in the legacy format.
+	w, e := NewFloatingWallet(&Options{
+		Label:     "legacy",
+		Seed:      "secure seed",
+		Encrypted: true,
+		Password:  "password",
+	})
+	require.Nil(t, e, "failed to create floating wallet")
+	require.Nil(t, w.EnsureEntries(2), "failed to ensure entries")
+	nonce := RandNonce()
+	prefix := NewPrefix(LegacyVersion, nonce)
+	pHash := cipher.SumSHA256([]byte("password"))
+	data, e := cipher.Chacha20Encrypt(w.ToFile().Serialize(), pHash[:], nonce)
+	require.Nil(t, e, "failed to encrypt legacy wallet")
+	require.Nil(t, SaveBinary(LabelPath("legacy"), append(prefix[:], data...)),
+		"failed to save legacy wallet")
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.DisplayWallet("legacy", "wrong")
+	require.NotNil(t, e, "opening with a wrong password should fail")
+
+	fw, e := m.DisplayWallet("legacy", "password")
+	require.Nil(t, e, "opening a legacy wallet should succeed")
+	require.Len(t, fw.Entries, 2, "entries should be loaded")
+
+	upgraded := loadWallet(t, "legacy", "password")
+	require.Equal(t, Version, upgraded.Meta.Version, "legacy wallet file should be upgraded")
+	require.Equal(t, w.Entries, upgraded.Entries, "upgraded wallet should keep its entries")
+}
+
+func TestSeedPhrase(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	for _, words := range SeedPhraseWords {
+		phrase, e := NewSeedPhrase(words)
+		require.Nil(t, e, "failed to generate seed phrase")
+		require.Len(t, strings.Fields(phrase), words, "seed phrase has the wrong number of words")
+		require.Nil(t, VerifySeedPhrase(phrase), "generated seed phrase should be valid")
+	}
+	_, e := NewSeedPhrase(13)
+	require.NotNil(t, e, "seed phrases with unsupported lengths should fail")
+
+	require.Nil(t, VerifySeedPhrase(strings.Repeat("abandon ", 11)+"about"),
+		"seed phrases with a valid checksum should pass")
+	require.NotNil(t, VerifySeedPhrase(strings.TrimSpace(strings.Repeat("abandon ", 12))),
+		"seed phrases with an invalid checksum should fail")
+	require.NotNil(t, VerifySeedPhrase("not a seed phrase"), "invalid seed phrases should fail")
+
+	phrase, e := NewSeedPhrase(12)
+	require.Nil(t, e, "failed to generate seed phrase")
+
+	// Restoring with the phrase typed differently derives the same entries.
+	original, e := NewFloatingWallet(&Options{Label: "original", Seed: phrase, SeedPhrase: true})
+	require.Nil(t, e, "failed to create wallet from seed phrase")
+	require.Nil(t, original.EnsureEntries(5), "failed to ensure entries")
+
+	restored, e := NewFloatingWallet(&Options{
+		Label:      "restored",
+		Seed:       "  " + strings.ToUpper(strings.Replace(phrase, " ", "   ", -1)),
+		SeedPhrase: true,
+	})
+	require.Nil(t, e, "failed to restore wallet from seed phrase")
+	require.Nil(t, restored.EnsureEntries(5), "failed to ensure entries")
+	require.Equal(t, original.Entries, restored.Entries, "restored wallet should have the same entries")
+
+	_, e = NewFloatingWallet(&Options{Label: "invalid", Seed: "not a seed phrase", SeedPhrase: true})
+	require.NotNil(t, e, "creating a wallet from an invalid seed phrase should fail")
+}
+
+func TestWallet_NewAddress(t *testing.T) {
+	w, e := NewFloatingWallet(&Options{Label: "hd", Seed: "hd seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	for i := 0; i < 3; i++ {
+		addr, e := w.NewAddress()
+		require.Nil(t, e, "failed to derive address")
+		require.Equal(t, i+1, w.Count(), "address should be appended")
+		require.Equal(t, w.Entries[i].Address, addr, "address should be the new entry's")
+
+		sk, e := DeriveSecKey(w.Meta.Seed, i)
+		require.Nil(t, e, "failed to derive secret key")
+		require.Equal(t, sk, w.Entries[i].SecKey, "entry should have the key of its index")
+	}
+
+	// Entries from the same seed are derived in the same order, however created.
+	backup, e := NewFloatingWallet(&Options{Label: "backup", Seed: "hd seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, backup.EnsureEntries(2), "failed to ensure entries")
+	_, e = backup.NewAddress()
+	require.Nil(t, e, "failed to derive address")
+	require.Equal(t, w.Entries, backup.Entries, "wallets from the same seed should have the same entries")
+
+	_, e = DeriveSecKey(w.Meta.Seed, -1)
+	require.NotNil(t, e, "negative indexes should fail")
+}
+
+func TestManager_VerifyAll(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	for _, opts := range []*Options{
+		{Label: "good", Seed: "good seed"},
+		{Label: "truncated", Seed: "truncated seed"},
+		{Label: "flipped", Seed: "flipped seed", Encrypted: true, Password: "pw"},
+	} {
+		_, e := m.CreateWallet(opts)
+		require.Nil(t, e, "failed to create wallet")
+	}
+	m.Close()
+
+	raw, e := ioutil.ReadFile(LabelPath("truncated"))
+	require.Nil(t, e, "failed to read wallet file")
+	require.Nil(t, SaveBinary(LabelPath("truncated"), raw[:len(raw)/2]), "failed to truncate")
+	raw, e = ioutil.ReadFile(LabelPath("flipped"))
+	require.Nil(t, e, "failed to read wallet file")
+	raw[PrefixSize+1] ^= 1
+	require.Nil(t, SaveBinary(LabelPath("flipped"), raw), "failed to flip")
+	require.Equal(t, ErrChecksum, VerifyFile("flipped", raw), "encrypted files should be checked")
+
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	stats := m.ListWallets()
+	require.Len(t, stats, 1, "bad wallet files should not be loaded")
+	require.Equal(t, "good", stats[0].Label, "good wallet files should be loaded")
+	quarantined, e := ioutil.ReadDir(filepath.Join(rootDir, QuarantineDirName))
+	require.Nil(t, e, "quarantine should exist")
+	require.Len(t, quarantined, 2, "bad wallet files should be quarantined")
+
+	// Files that go bad while running are removed from the manager.
+	require.Nil(t, SaveBinary(LabelPath("good"), []byte("bad")), "failed to corrupt")
+	out, e := m.VerifyAll()
+	require.Nil(t, e, "failed to verify")
+	require.Len(t, out, 1, "bad wallet file should be quarantined")
+	require.Equal(t, "good", out[0].Label, "quarantined label should be reported")
+	_, e = os.Stat(out[0].Path)
+	require.Nil(t, e, "quarantined file should exist")
+	require.Empty(t, m.ListWallets(), "quarantined wallets should be removed")
+}
+
+func TestManager_DeleteWallet(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "one", Seed: "one seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+
+	require.Equal(t, ErrInvalidDeleteToken, m.DeleteWallet("one", ""),
+		"deleting should need a token")
+	token, e := m.DeleteToken("one")
+	require.Nil(t, e, "failed to obtain token")
+	require.Equal(t, ErrInvalidDeleteToken, m.DeleteWallet("one", token+"0"),
+		"deleting should need the issued token")
+	m.deleteTokens["one"] = deleteToken{value: token, expires: time.Now().Add(-time.Second)}
+	require.Equal(t, ErrInvalidDeleteToken, m.DeleteWallet("one", token),
+		"expired tokens should fail")
+	token, e = m.DeleteToken("one")
+	require.Nil(t, e, "failed to obtain token")
+	require.Nil(t, m.DeleteWallet("one", token), "failed to delete wallet")
+	require.Equal(t, ErrWalletNotFound, m.DeleteWallet("one", token),
+		"tokens should be for a single deletion")
+	require.Empty(t, m.ListWallets(), "deleted wallet should be removed")
+	_, e = os.Stat(LabelPath("one"))
+	require.True(t, os.IsNotExist(e), "file of deleted wallet should be moved")
+
+	trash, e := m.ListTrash()
+	require.Nil(t, e, "failed to list trash")
+	require.Len(t, trash, 1, "deleted wallet should be in trash")
+	require.Equal(t, "one", trash[0].Label, "trashed label should be listed")
+
+	// Wallets with the same label block recovery.
+	_, e = m.CreateWallet(&Options{Label: "one", Seed: "other seed"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.RecoverWallet(trash[0].ID)
+	require.Equal(t, ErrLabelAlreadyExists, e, "recovery should not replace wallets")
+	require.Nil(t, deleteWallet(m, "one"), "failed to delete wallet")
+
+	trash, e = m.ListTrash()
+	require.Nil(t, e, "failed to list trash")
+	require.Len(t, trash, 2, "deleted wallets should be in trash")
+	tw, e := m.RecoverWallet(trash[1].ID)
+	require.Nil(t, e, "failed to recover wallet")
+	require.Equal(t, "one", tw.Label, "recovered label should be reported")
+	stats := m.ListWallets()
+	require.Len(t, stats, 1, "recovered wallet should be loaded")
+	require.True(t, *stats[0].Locked, "recovered encrypted wallet should be locked")
+	require.Nil(t, m.Unlock("one", "pw", 0), "recovered wallet should unlock")
+
+	// Only wallets past the time are purged.
+	require.Nil(t, deleteWallet(m, "one"), "failed to delete wallet")
+	purged, e := m.PurgeTrash(time.Now().Add(-time.Hour), true)
+	require.Nil(t, e, "failed to purge trash")
+	require.Empty(t, purged, "recent wallets should be kept")
+	purged, e = m.PurgeTrash(time.Now(), true)
+	require.Nil(t, e, "failed to purge trash")
+	require.Len(t, purged, 2, "wallets should be purged")
+	trash, e = m.ListTrash()
+	require.Nil(t, e, "failed to list trash")
+	require.Empty(t, trash, "purged wallets should be removed")
+}
+
+func TestManager_CRUD(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+
+	fw, e := m.CreateWallet(&Options{Label: "plain", Seed: "plain seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	require.Len(t, fw.Entries, 2, "wallet should have its addresses")
+
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create encrypted wallet")
+
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "other seed"})
+	require.Equal(t, ErrLabelAlreadyExists, e, "labels should be unique")
+
+	for _, label := range []string{"", "../escape", ".hidden", strings.Repeat("a", MaxLabelSize+1)} {
+		_, e = m.CreateWallet(&Options{Label: label, Seed: "seed"})
+		require.NotNil(t, e, "label '%s' should be invalid", label)
+	}
+
+	// Each wallet is persisted as its own file, which a new manager lists.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	require.Equal(t, []Stat{
+		{Label: "plain"},
+		{Label: "secret", Encrypted: true, Locked: func() *bool { v := true; return &v }()},
+	}, m.ListWallets(), "wallets should be listed from their files")
+
+	got, e := m.GetWallet("plain")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, fw, got, "wallet should be the same as created")
+
+	_, e = m.GetWallet("secret")
+	require.Equal(t, ErrWalletLocked, e, "encrypted wallets should be locked")
+	_, e = m.DisplayWallet("secret", "pw")
+	require.Nil(t, e, "failed to unlock wallet")
+	_, e = m.GetWallet("secret")
+	require.Nil(t, e, "unlocked wallets should be obtainable")
+
+	require.Nil(t, deleteWallet(m, "plain"), "failed to delete wallet")
+	_, e = m.GetWallet("plain")
+	require.Equal(t, ErrWalletNotFound, e, "deleted wallets should not be found")
+	_, e = os.Stat(LabelPath("plain"))
+	require.True(t, os.IsNotExist(e), "file of deleted wallet should be removed")
+	require.Equal(t, ErrWalletNotFound, deleteWallet(m, "plain"), "deleting twice should fail")
+}
+
+func TestManager_WalletInfo(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+
+	_, e = m.CreateWallet(&Options{Label: "info", Name: "My Kitties", Seed: "info seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.SetWalletMeta("info", "color", "orange")
+	require.Nil(t, e, "failed to set metadata")
+	_, e = m.SetWalletMeta("info", "avatar", "cat.png")
+	require.Nil(t, e, "failed to set metadata")
+	_, e = m.SetWalletMeta("info", "removed", "soon")
+	require.Nil(t, e, "failed to set metadata")
+	_, e = m.SetWalletMeta("info", "removed", "")
+	require.Nil(t, e, "failed to remove metadata")
+	fw, e := m.SetWalletName("info", "Savings")
+	require.Nil(t, e, "failed to set name")
+
+	_, e = m.SetWalletMeta("info", "", "value")
+	require.NotNil(t, e, "empty keys should fail")
+	_, e = m.SetWalletName("info", strings.Repeat("a", MaxNameSize+1))
+	require.NotNil(t, e, "long names should fail")
+
+	// Info persists in the wallet's file.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	got, e := m.GetWallet("info")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, fw.Meta.TS, got.Meta.TS, "creation time should persist")
+	require.Equal(t, Info{
+		Name: "Savings",
+		Metadata: []MetaField{
+			{Key: "avatar", Value: "cat.png"},
+			{Key: "color", Value: "orange"},
+		},
+	}, got.Meta.Info, "info should persist, with sorted metadata")
+	require.Equal(t, "Savings", m.ListWallets()[0].Name, "name should be listed")
+}
+
+func TestUpgradeWallet(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	for v := uint64(0); v < Version; v++ {
+		require.NotNil(t, migrations[v], "version %d should have a migration", v)
+	}
+	_, e := migrateData(Version+1, nil)
+	require.NotNil(t, e, "newer versions should not migrate")
+
+	w, e := NewFloatingWallet(&Options{Label: "old", Seed: "old seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, w.EnsureEntries(2), "failed to ensure entries")
+	prefix := NewPrefix(NoInfoVersion, EmptyNonce())
+	raw := append(prefix[:], encoder.Serialize(noInfoFile{
+		Meta:    w.Meta.Meta,
+		Entries: w.Entries,
+	})...)
+	require.Nil(t, SaveBinary(LabelPath("old"), raw), "failed to save old wallet")
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	upgraded := loadWallet(t, "old", "")
+	require.Equal(t, Version, upgraded.Meta.Version, "wallet file should be upgraded")
+	require.Equal(t, w.Entries, upgraded.Entries, "upgraded wallet should keep its entries")
+	bak, e := ioutil.ReadFile(BackupPath("old"))
+	require.Nil(t, e, "old file should be kept")
+	require.Equal(t, raw, bak, "copy should have the old file")
+	require.Len(t, m.ListWallets(), 1, "copies should not be listed")
+
+	require.Nil(t, deleteWallet(m, "old"), "failed to delete wallet")
+	_, e = os.Stat(BackupPath("old"))
+	require.True(t, os.IsNotExist(e), "copies should be deleted with their wallets")
+}
+
+func TestLoadFloatingWallet_NoInfoVersion(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	w, e := NewFloatingWallet(&Options{Label: "old", Seed: "old seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, w.EnsureEntries(2), "failed to ensure entries")
+
+	// Write a file with the layout before 'Info'.
+	prefix := NewPrefix(NoInfoVersion, RandNonce())
+	data, e := encryptData(prefix[:], encoder.Serialize(noInfoFile{
+		Meta:    w.Meta.Meta,
+		Entries: w.Entries,
+	}), "pw")
+	require.Nil(t, e, "failed to encrypt")
+
+	loaded, e := LoadFloatingWallet(bytes.NewReader(append(prefix[:], data...)), "old", "pw")
+	require.Nil(t, e, "failed to load wallet with version %d", NoInfoVersion)
+	require.Equal(t, w.Entries, loaded.Entries, "entries should be loaded")
+	require.Equal(t, NoInfoVersion, loaded.Meta.Version, "version should be the file's")
+
+	// Files with the layout before imported keys keep their info.
+	w.Meta.Name = "old name"
+	prefix = NewPrefix(NoImportVersion, EmptyNonce())
+	raw := append(prefix[:], encoder.Serialize(noImportFile{
+		Meta:    w.Meta.Meta,
+		Entries: w.Entries,
+		Info:    w.Meta.Info,
+	})...)
+	loaded, e = LoadFloatingWallet(bytes.NewReader(raw), "old", "")
+	require.Nil(t, e, "failed to load wallet with version %d", NoImportVersion)
+	require.Equal(t, w.Entries, loaded.Entries, "entries should be loaded")
+	require.Equal(t, "old name", loaded.Meta.Name, "info should be loaded")
+}
+
+func TestManager_WatchOnly(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	cold, e := NewFloatingWallet(&Options{Label: "cold", Seed: "cold seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, cold.EnsureEntries(3), "failed to ensure entries")
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+
+	_, e = m.CreateWallet(&Options{Label: "bad", WatchOnly: true, Seed: "seed"})
+	require.NotNil(t, e, "watch-only wallets can not have a seed")
+	_, e = m.CreateWallet(&Options{Label: "bad", WatchOnly: true, WatchAddresses: []string{"invalid"}})
+	require.NotNil(t, e, "invalid addresses should fail")
+	_, e = m.CreateWallet(&Options{Label: "bad", Seed: "seed", WatchAddresses: []string{cold.Entries[0].Address.String()}})
+	require.NotNil(t, e, "only watch-only wallets can watch addresses")
+
+	fw, e := m.CreateWallet(&Options{
+		Label:     "watch",
+		WatchOnly: true,
+		WatchAddresses: []string{
+			cold.Entries[0].Address.String(),
+			cold.Entries[1].Address.String(),
+			cold.Entries[0].Address.String(),
+		},
+	})
+	require.Nil(t, e, "failed to create watch-only wallet")
+	require.True(t, fw.WatchOnly, "wallet should be watch-only")
+	require.Len(t, fw.Entries, 2, "duplicate addresses should be ignored")
+
+	fw, e = m.WatchWalletAddress("watch", cold.Entries[2].Address)
+	require.Nil(t, e, "failed to watch address")
+	for i, entry := range fw.Entries {
+		require.Equal(t, cold.Entries[i].Address.String(), entry.Address, "address should be watched")
+		require.Empty(t, entry.SecKey, "watch-only entries should have no secret key")
+	}
+
+	// The wallet persists without secrets, and can not derive addresses.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	require.True(t, m.ListWallets()[0].WatchOnly, "wallet should be listed as watch-only")
+	got, e := m.GetWallet("watch")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, fw, got, "watch-only wallet should persist")
+	_, e = m.EnsureWalletEntries("watch", 5)
+	require.Equal(t, ErrWatchOnly, e, "watch-only wallets can not generate addresses")
+
+	_, e = m.CreateWallet(&Options{Label: "hot", Seed: "hot seed"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.WatchWalletAddress("hot", cold.Entries[0].Address)
+	require.NotNil(t, e, "wallets with a seed can not watch addresses")
+}
+
+func TestManager_Backup(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "plain seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+
+	var buf bytes.Buffer
+	require.Equal(t, ErrPasswordRequired, m.Backup(&buf, ""), "backups need a password")
+	require.Nil(t, m.Backup(&buf, "backup pw"), "failed to backup")
+	archive := buf.Bytes()
+
+	require.Nil(t, VerifyBackup(bytes.NewReader(archive)), "archive should be valid")
+	backup, e := ReadBackup(bytes.NewReader(archive), "backup pw")
+	require.Nil(t, e, "failed to read backup")
+	require.Len(t, backup.Files, 2, "every wallet should be in the backup")
+	for _, f := range backup.Files {
+		data, e := ioutil.ReadFile(LabelPath(f.Label))
+		require.Nil(t, e, "failed to read wallet file")
+		require.Equal(t, data, f.Data, "backup should have the wallet file")
+	}
+
+	_, e = ReadBackup(bytes.NewReader(archive), "wrong")
+	require.Equal(t, ErrInvalidPassword, e, "wrong passwords should fail")
+
+	corrupted := append([]byte{}, archive...)
+	corrupted[len(BackupMagic)+10] ^= 1
+	require.Equal(t, ErrInvalidBackup, VerifyBackup(bytes.NewReader(corrupted)), "corrupted archives should fail")
+	require.NotNil(t, VerifyBackup(bytes.NewReader(archive[:10])), "truncated archives should fail")
+}
+
+func TestManager_Restore(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	plain, e := m.CreateWallet(&Options{Label: "plain", Seed: "plain seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+
+	var buf bytes.Buffer
+	require.Nil(t, m.Backup(&buf, "backup pw"), "failed to backup")
+	archive := buf.Bytes()
+
+	// Restoring onto an empty directory restores everything.
+	require.Nil(t, deleteWallet(m, "plain"), "failed to delete wallet")
+	require.Nil(t, deleteWallet(m, "secret"), "failed to delete wallet")
+	_, e = m.Restore(bytes.NewReader(archive), "wrong", RestoreAbort)
+	require.Equal(t, ErrInvalidPassword, e, "wrong passwords should fail")
+	res, e := m.Restore(bytes.NewReader(archive), "backup pw", RestoreAbort)
+	require.Nil(t, e, "failed to restore")
+	require.Equal(t, []string{"plain", "secret"}, res.Restored, "every wallet should be restored")
+
+	got, e := m.GetWallet("plain")
+	require.Nil(t, e, "failed to get restored wallet")
+	require.Equal(t, plain.Entries, got.Entries, "restored wallet should have the same entries")
+	_, e = m.GetWallet("secret")
+	require.Equal(t, ErrWalletLocked, e, "restored encrypted wallets should be locked")
+	_, e = m.DisplayWallet("secret", "pw")
+	require.Nil(t, e, "restored encrypted wallets should unlock with their password")
+
+	// Collisions are handled according to policy.
+	_, e = m.SetWalletName("plain", "changed")
+	require.Nil(t, e, "failed to set name")
+	_, e = m.CreateWallet(&Options{Label: "other", Seed: "other seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.Restore(bytes.NewReader(archive), "backup pw", RestoreAbort)
+	require.NotNil(t, e, "collisions should abort")
+	require.Contains(t, e.Error(), "plain", "collisions should be listed")
+
+	require.Nil(t, deleteWallet(m, "secret"), "failed to delete wallet")
+	res, e = m.Restore(bytes.NewReader(archive), "backup pw", RestoreMerge)
+	require.Nil(t, e, "failed to merge")
+	require.Equal(t, &RestoreResult{Restored: []string{"secret"}, Skipped: []string{"plain"}}, res)
+	got, _ = m.GetWallet("plain")
+	require.Equal(t, "changed", got.Meta.Name, "merge should keep existing wallets")
+
+	res, e = m.Restore(bytes.NewReader(archive), "backup pw", RestoreReplace)
+	require.Nil(t, e, "failed to replace")
+	require.Equal(t, &RestoreResult{Replaced: []string{"plain", "secret"}}, res)
+	got, _ = m.GetWallet("plain")
+	require.Equal(t, "", got.Meta.Name, "replace should restore the backed up wallet")
+	require.Len(t, m.ListWallets(), 3, "wallets not in the backup should remain")
+
+	_, e = m.Restore(bytes.NewReader(archive), "backup pw", RestoreReplace+1)
+	require.NotNil(t, e, "invalid policies should fail")
+}
+
+func TestManager_Export(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	fw, e := m.CreateWallet(&Options{Label: "one", Name: "One", Seed: "one seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "two", Seed: "two seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	var buf bytes.Buffer
+	require.Nil(t, m.Export(&buf, []string{"one"}, ExportOptions{Format: ExportCSV}), "failed to export")
+	require.Equal(t,
+		"label,name,index,address,public_key,imported,key_label,account\n"+
+			"one,One,0,"+fw.Entries[0].Address+","+fw.Entries[0].PubKey+",false,,\n"+
+			"one,One,1,"+fw.Entries[1].Address+","+fw.Entries[1].PubKey+",false,,\n",
+		buf.String(), "csv should have the documented layout")
+
+	buf.Reset()
+	require.Nil(t, m.Export(&buf, nil, ExportOptions{Format: ExportJSON}), "failed to export")
+	require.Equal(t, 3, strings.Count(buf.String(), `"address"`), "every wallet should be exported")
+	require.NotContains(t, buf.String(), "secret_key", "secret keys should not be exported")
+
+	e = m.Export(&buf, nil, ExportOptions{Format: ExportJSON, SecretKeys: true})
+	require.Equal(t, ErrExportNotConfirmed, e, "secret keys need confirmation")
+
+	buf.Reset()
+	e = m.Export(&buf, []string{"one"}, ExportOptions{
+		Format:     ExportCSV,
+		SecretKeys: true,
+		Confirm:    ExportSecretKeysConfirmation,
+	})
+	require.Nil(t, e, "failed to export with secret keys")
+	require.Contains(t, buf.String(), ",secret_key\n", "header should have secret keys")
+	require.Contains(t, buf.String(), fw.Entries[0].SecKey, "secret keys should be exported")
+
+	// Public-only exports have the same rows as exports without secret keys.
+	_, e = m.ImportWalletKey("one", strings.Repeat("07080910", 8), "old")
+	require.Nil(t, e, "failed to import key")
+	_, e = m.AddWalletAccount("one", "trading")
+	require.Nil(t, e, "failed to add account")
+	_, e = m.NewWalletAccountAddress("one", "trading")
+	require.Nil(t, e, "failed to derive address")
+	fw, e = m.GetWallet("one")
+	require.Nil(t, e, "failed to get wallet")
+	e = m.Export(&buf, nil, ExportOptions{Format: ExportJSON, PublicOnly: true, SecretKeys: true,
+		Confirm: ExportSecretKeysConfirmation})
+	require.Equal(t, ErrExportPublicOnly, e, "public-only exports should not have secret keys")
+	for _, format := range []ExportFormat{ExportJSON, ExportCSV} {
+		var public, plain bytes.Buffer
+		require.Nil(t, m.Export(&public, []string{"one"}, ExportOptions{Format: format, PublicOnly: true}),
+			"failed to export public keys")
+		require.Nil(t, m.Export(&plain, []string{"one"}, ExportOptions{Format: format}), "failed to export")
+		require.Equal(t, plain.String(), public.String(), "public-only exports should have every address")
+		for _, fe := range append(append(fw.Entries, fw.Imported...), fw.Accounts[0].Entries...) {
+			require.NotContains(t, public.String(), fe.SecKey, "secret keys should not be exported")
+		}
+	}
+
+	e = m.Export(&buf, []string{"missing"}, ExportOptions{Format: ExportJSON})
+	require.True(t, errors.Is(e, ErrWalletNotFound), "unknown labels should fail")
+	require.NotNil(t, m.Export(&buf, nil, ExportOptions{Format: "xml"}), "invalid formats should fail")
+}
+
+// testSecKey and testSecKey2 are the keys of the addresses in the tests.
+var (
+	testSecKey = cipher.SecKey([32]byte{
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+		3, 4, 5, 6,
+	})
+	testSecKey2 = cipher.SecKey([32]byte{
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+		7, 8, 9, 10,
+	})
+)
+
+func TestWallet_ImportKey(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	sk := testSecKey
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.CreateWallet(&Options{Label: "import", Seed: "import seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	fw, e := m.ImportWalletKey("import", sk.Hex(), "old wallet")
+	require.Nil(t, e, "failed to import key")
+	require.Len(t, fw.Imported, 1, "key should be imported")
+	require.Equal(t, cipher.AddressFromSecKey(sk).String(), fw.Imported[0].Address, "address should match the key")
+	require.Equal(t, "old wallet", fw.Imported[0].Label, "key should have its label")
+
+	_, e = m.ImportWalletKey("import", sk.Hex(), "again")
+	require.NotNil(t, e, "duplicate keys should fail")
+	_, e = m.ImportWalletKey("import", fw.Entries[0].SecKey, "")
+	require.NotNil(t, e, "keys for derived entries should fail")
+	_, e = m.ImportWalletKey("import", "not hex", "")
+	require.NotNil(t, e, "invalid keys should fail")
+	_, e = m.ImportWalletKey("import", cipher.SecKey{}.Hex(), "")
+	require.NotNil(t, e, "zero keys should fail")
+
+	// Imported keys persist, and do not change derived addresses.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.EnsureWalletEntries("import", 2)
+	require.Nil(t, e, "failed to ensure entries")
+	got, e := m.GetWallet("import")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, fw.Imported, got.Imported, "imported keys should persist")
+	require.Equal(t, fw.Entries[0], got.Entries[0], "derived entries should be unchanged")
+
+	_, e = m.CreateWallet(&Options{Label: "watch", WatchOnly: true})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.ImportWalletKey("watch", sk.Hex(), "")
+	require.Equal(t, ErrWatchOnly, e, "watch-only wallets can not import keys")
+}
+
+func TestAddressBook(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	alice := cipher.AddressFromSecKey(testSecKey)
+	bob := cipher.AddressFromSecKey(testSecKey2)
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	ab := m.AddressBook()
+
+	require.Nil(t, ab.Put(Contact{Name: "Bob", Address: bob.String()}), "failed to add contact")
+	require.Nil(t, ab.Put(Contact{Name: "alice", Address: bob.String(), Note: "wrong"}), "failed to add contact")
+	require.Nil(t, ab.Put(Contact{Name: "Alice", Address: alice.String(), Note: "friend"}),
+		"contacts with the same name (in any case) should be replaced")
+	require.NotNil(t, ab.Put(Contact{Name: "Eve", Address: "invalid"}), "invalid addresses should fail")
+	require.NotNil(t, ab.Put(Contact{Name: " ", Address: alice.String()}), "empty names should fail")
+
+	addr, e := ab.Resolve("ALICE")
+	require.Nil(t, e, "failed to resolve name")
+	require.Equal(t, alice, addr, "name should resolve to its address")
+	addr, e = ab.Resolve(bob.String())
+	require.Nil(t, e, "failed to resolve address")
+	require.Equal(t, bob, addr, "addresses should resolve to themselves")
+	_, e = ab.Resolve("Eve")
+	require.True(t, errors.Is(e, ErrContactNotFound), "unknown names should fail")
+
+	// The address book persists alongside the wallets.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	require.Equal(t, []Contact{
+		{Name: "Alice", Address: alice.String(), Note: "friend"},
+		{Name: "Bob", Address: bob.String()},
+	}, m.AddressBook().List(), "contacts should persist, sorted by name")
+
+	require.Nil(t, m.AddressBook().Remove("bob"), "failed to remove contact")
+	require.Equal(t, ErrContactNotFound, m.AddressBook().Remove("bob"), "removing twice should fail")
+	require.Len(t, m.ListWallets(), 0, "the address book should not be listed as a wallet")
+}
+
+func TestManager_Lock(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	fw, e := m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "plain seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	addr := cipher.MustDecodeBase58Address(fw.Entries[0].Address)
+	hash := cipher.SumSHA256([]byte("message"))
+
+	sig, e := m.SignHash("secret", addr, hash)
+	require.Nil(t, e, "failed to sign with unlocked wallet")
+	require.Nil(t, cipher.VerifySignature(cipher.MustPubKeyFromHex(fw.Entries[0].PubKey), sig, hash),
+		"signature should be from the address")
+
+	require.Nil(t, m.Lock("secret"), "failed to lock")
+	require.NotNil(t, m.Lock("plain"), "unencrypted wallets can not be locked")
+	_, e = m.SignHash("secret", addr, hash)
+	require.Equal(t, ErrWalletLocked, e, "locked wallets should fail to sign")
+
+	require.Equal(t, ErrInvalidPassword, m.Unlock("secret", "wrong", 0), "wrong passwords should fail")
+	require.Nil(t, m.Unlock("secret", "pw", 100*time.Millisecond), "failed to unlock")
+	require.Equal(t, ErrInvalidPassword, m.Unlock("secret", "wrong", 0),
+		"wrong passwords should fail for unlocked wallets")
+	require.Nil(t, m.Unlock("secret", "pw", 100*time.Millisecond), "failed to unlock")
+
+	// Activity keeps the wallet unlocked, and inactivity locks it.
+	for i := 0; i < 4; i++ {
+		time.Sleep(40 * time.Millisecond)
+		_, e = m.SignHash("secret", addr, hash)
+		require.Nil(t, e, "active wallets should stay unlocked")
+	}
+	time.Sleep(250 * time.Millisecond)
+	_, e = m.SignHash("secret", addr, hash)
+	require.Equal(t, ErrWalletLocked, e, "inactive wallets should be locked")
+	require.True(t, *m.ListWallets()[1].Locked, "wallet should be listed as locked")
+
+	_, e = m.SignHash("plain", addr, hash)
+	require.NotNil(t, e, "addresses of other wallets should fail")
+}
+
+func TestManager_ChangeWalletPassword(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	fw, e := m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "old", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, m.Lock("secret"), "failed to lock")
+
+	require.Equal(t, ErrInvalidPassword, m.ChangeWalletPassword("secret", "wrong", "new"), "wrong passwords should fail")
+	require.Equal(t, ErrPasswordRequired, m.ChangeWalletPassword("secret", "old", ""), "new passwords are required")
+	require.Nil(t, m.ChangeWalletPassword("secret", "old", "new"), "failed to change password")
+
+	open := func(label, password string) (*Wallet, error) {
+		f, e := os.Open(LabelPath(label))
+		require.Nil(t, e, "failed to open wallet file")
+		defer f.Close()
+		return LoadFloatingWallet(f, label, password)
+	}
+	_, e = open("secret", "old")
+	require.Equal(t, ErrInvalidPassword, e, "old password should no longer open the file")
+	w, e := open("secret", "new")
+	require.Nil(t, e, "new password should open the file")
+	require.Equal(t, fw.Entries[0].SecKey, w.Entries[0].SecKey.Hex(), "keys should be kept")
+	_, e = os.Stat(LabelPath("secret") + ".bak")
+	require.True(t, os.IsNotExist(e), "no copy with the old password should be kept")
+
+	// A file that fails to be replaced keeps the old password.
+	require.Nil(t, os.Mkdir(LabelPath("secret")+".tmp", 0700), "failed to block temporary file")
+	require.NotNil(t, m.ChangeWalletPassword("secret", "new", "newer"), "failed saves should fail")
+	require.Nil(t, os.Remove(LabelPath("secret")+".tmp"), "failed to unblock temporary file")
+	require.Nil(t, m.ChangeWalletPassword("secret", "new", "newer"), "the old password should be kept")
+
+	// Unencrypted wallets are encrypted.
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "plain seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.NotNil(t, m.ChangeWalletPassword("plain", "old", "new"), "unencrypted wallets have no old password")
+	require.Nil(t, m.ChangeWalletPassword("plain", "", "new"), "failed to encrypt wallet")
+	_, e = open("plain", "new")
+	require.Nil(t, e, "wallet should be encrypted with the new password")
+}
+
+// memSigner is a signer with keys that are held in memory, in place of a
+// hardware wallet.
+type memSigner struct {
+	label string
+	sks   []cipher.SecKey
+}
+
+func (s *memSigner) Label() string { return s.label }
+
+func (s *memSigner) Entries() ([]Entry, error) {
+	out := make([]Entry, len(s.sks))
+	for i, sk := range s.sks {
+		out[i] = Entry{Address: cipher.AddressFromSecKey(sk), PubKey: cipher.PubKeyFromSecKey(sk)}
+	}
+	return out, nil
+}
+
+func (s *memSigner) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	for _, sk := range s.sks {
+		if cipher.AddressFromSecKey(sk) == addr {
+			return cipher.SignHash(hash, sk), nil
+		}
+	}
+	return cipher.Sig{}, errors.New("address does not belong to signer")
+}
+
+func TestManager_Signer(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	sk := testSecKey
+	addr := cipher.AddressFromSecKey(sk)
+	to := cipher.AddressFromSecKey(testSecKey2)
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	require.Nil(t, m.AddSigner(&memSigner{label: "device", sks: []cipher.SecKey{sk}}), "failed to add signer")
+	require.Equal(t, ErrLabelAlreadyExists, m.AddSigner(&memSigner{label: "device"}), "labels should be unique")
+	_, e = m.CreateWallet(&Options{Label: "device", Seed: "seed"})
+	require.Equal(t, ErrLabelAlreadyExists, e, "wallets can not have labels of signers")
+
+	fw, e := m.CreateWallet(&Options{Label: "file", Seed: "file seed", Encrypted: true, Password: "pw", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	// Addresses of the device are listed as those in files.
+	require.Equal(t, []Stat{
+		{Label: "device", Hardware: true},
+		{Label: "file", Encrypted: true, Locked: new(bool)},
+	}, m.ListWallets(), "signers should be listed as wallets")
+	hw, e := m.GetWallet("device")
+	require.Nil(t, e, "failed to get wallet for signer")
+	require.True(t, hw.Hardware, "wallet should be hardware")
+	require.Equal(t, addr.String(), hw.Entries[0].Address, "address should be from the device")
+	require.Empty(t, hw.Entries[0].SecKey, "secret keys should not be from the device")
+
+	// Transactions are signed the same way with either signer.
+	prev := iko.NewGenTx(nil, 1, sk)
+	for _, c := range []struct {
+		label string
+		addr  cipher.Address
+	}{
+		{"device", addr},
+		{"file", cipher.MustDecodeBase58Address(fw.Entries[0].Address)},
+	} {
+		s, e := m.Signer(c.label)
+		require.Nil(t, e, "failed to get signer")
+		tx := iko.NewUnsignedTransfer(prev, iko.KittyIDs{1}, c.addr, to, 1)
+		require.Nil(t, SignTx(s, c.addr, tx), "failed to sign tx")
+		require.Nil(t, tx.Verify(prev), "tx should be signed")
+	}
+
+	s, e := m.Signer("file")
+	require.Nil(t, e, "failed to get signer")
+	require.Nil(t, m.Lock("file"), "failed to lock")
+	tx := iko.NewUnsignedTransfer(prev, iko.KittyIDs{1}, cipher.MustDecodeBase58Address(fw.Entries[0].Address), to, 1)
+	require.Equal(t, ErrWalletLocked, SignTx(s, tx.From, tx), "signers for locked wallets should fail")
+	_, e = m.Signer("file")
+	require.Equal(t, ErrWalletLocked, e, "locked wallets should have no signer")
+
+	require.Nil(t, m.RemoveSigner("device"), "failed to remove signer")
+	_, e = m.GetWallet("device")
+	require.Equal(t, ErrWalletNotFound, e, "removed signers should not be found")
+}
+
+// ledgerDevice stands in for the app on a Ledger device, with keys held in
+// memory. Replies are queued as the packets of the HID transport.
+type ledgerDevice struct {
+	sks     []cipher.SecKey
+	reject  bool
+	replies bytes.Buffer
+}
+
+func (d *ledgerDevice) Write(packet []byte) (int, error) {
+	if len(packet) != 64 || !bytes.Equal(packet[:5], []byte{0x01, 0x01, 0x05, 0, 0}) {
+		return 0, errors.New("invalid packet")
+	}
+	size := int(packet[5])<<8 | int(packet[6])
+	apdu := packet[7 : 7+size]
+	if apdu[0] != 0xe0 || int(apdu[4]) != len(apdu)-5 {
+		return 0, errors.New("invalid apdu")
+	}
+	data := apdu[5:]
+	index := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+
+	var reply []byte
+	switch {
+	case index >= len(d.sks):
+		reply = []byte{0x6a, 0x80}
+	case apdu[1] == 0x02:
+		pk := cipher.PubKeyFromSecKey(d.sks[index])
+		reply = append(pk[:], 0x90, 0x00)
+	case apdu[1] == 0x04 && d.reject:
+		reply = []byte{0x69, 0x85}
+	case apdu[1] == 0x04:
+		var hash cipher.SHA256
+		copy(hash[:], data[4:])
+		sig := cipher.SignHash(hash, d.sks[index])
+		reply = append(sig[:], 0x90, 0x00)
+	default:
+		reply = []byte{0x6d, 0x00}
+	}
+
+	body := append([]byte{byte(len(reply) >> 8), byte(len(reply))}, reply...)
+	for i := 0; len(body) > 0; i++ {
+		out := make([]byte, 64)
+		copy(out, []byte{0x01, 0x01, 0x05, byte(i >> 8), byte(i)})
+		body = body[copy(out[5:], body):]
+		d.replies.Write(out)
+	}
+	return len(packet), nil
+}
+
+func (d *ledgerDevice) Read(p []byte) (int, error) {
+	return d.replies.Read(p)
+}
+
+func TestLedgerSigner(t *testing.T) {
+	dev := &ledgerDevice{sks: []cipher.SecKey{
+		testSecKey,
+		testSecKey2,
+	}}
+	_, e := NewLedgerSigner("ledger", dev, 3)
+	require.NotNil(t, e, "keys that the device does not have should fail")
+
+	s, e := NewLedgerSigner("ledger", dev, 2)
+	require.Nil(t, e, "failed to create ledger signer")
+	entries, e := s.Entries()
+	require.Nil(t, e)
+	require.Len(t, entries, 2)
+	for i, entry := range entries {
+		require.Equal(t, cipher.AddressFromSecKey(dev.sks[i]), entry.Address, "addresses should be those of the device")
+		require.Equal(t, cipher.SecKey{}, entry.SecKey, "secret keys should not leave the device")
+	}
+
+	prev := iko.NewGenTx(nil, 1, dev.sks[0])
+	tx := iko.NewUnsignedTransfer(prev, iko.KittyIDs{1}, entries[1].Address, entries[0].Address, 1)
+	require.Nil(t, SignTx(s, tx.From, tx), "failed to sign tx on the device")
+	require.Nil(t, tx.Verify(prev), "tx should be signed by the second key")
+
+	_, other := cipher.GenerateKeyPair()
+	_, e = s.SignHash(cipher.AddressFromSecKey(other), cipher.SHA256{})
+	require.NotNil(t, e, "addresses that are not on the device should fail")
+	dev.reject = true
+	_, e = s.SignHash(entries[0].Address, cipher.SHA256{})
+	require.Equal(t, ErrLedgerRejected, e, "declined signatures should fail")
+}
+
+func TestNewPaperWallet(t *testing.T) {
+	pw, e := NewPaperWallet()
+	require.Nil(t, e, "failed to generate paper wallet")
+
+	sk, e := cipher.SecKeyFromHex(pw.SecKey)
+	require.Nil(t, e, "secret key should be valid")
+	require.Equal(t, pw.Address, cipher.AddressFromSecKey(sk).String(),
+		"address should match the secret key")
+	require.Equal(t, pw.PubKey, cipher.PubKeyFromSecKey(sk).Hex(),
+		"public key should match the secret key")
+
+	for _, c := range []struct {
+		qr  *QRCode
+		img []byte
+	}{
+		{pw.AddressQRCode(), pw.AddressQR},
+		{pw.SecKeyQRCode(), pw.SecKeyQR},
+	} {
+		img, e := png.Decode(bytes.NewReader(c.img))
+		require.Nil(t, e, "qr code should be a png")
+		require.Equal(t, (c.qr.Size+8)*PaperQRScale, img.Bounds().Dx(),
+			"qr code should have the quiet zone")
+	}
+	require.Equal(t, 29, pw.AddressQRCode().Size, "address should fit version 3")
+	require.Equal(t, 37, pw.SecKeyQRCode().Size, "secret key should fit version 5")
+
+	var buf bytes.Buffer
+	require.Nil(t, pw.HTML(&buf), "failed to render html")
+	require.Contains(t, buf.String(), pw.SecKey, "html should have the secret key")
+	require.Contains(t, buf.String(), "data:image/png;base64,", "html should embed qr codes")
+}
+
+func TestNewQRCode(t *testing.T) {
+	q, e := NewQRCode([]byte("A"))
+	require.Nil(t, e, "failed to encode qr code")
+	require.Equal(t, 21, q.Size, "short data should be version 1")
+
+	// Finder patterns, timing patterns and the dark module.
+	for _, xy := range [][2]int{{0, 0}, {6, 6}, {20, 0}, {0, 20}, {8, 6}, {8, 13}} {
+		require.True(t, q.Dark(xy[0], xy[1]), "module %v should be dark", xy)
+	}
+	for _, xy := range [][2]int{{7, 7}, {1, 1}, {9, 6}, {-1, 0}, {21, 21}} {
+		require.False(t, q.Dark(xy[0], xy[1]), "module %v should be light", xy)
+	}
+
+	q, e = NewQRCode(bytes.Repeat([]byte{'k'}, 180))
+	require.Nil(t, e, "failed to encode qr code")
+	require.Equal(t, 53, q.Size, "long data should be version 9")
+
+	_, e = NewQRCode(bytes.Repeat([]byte{'k'}, 181))
+	require.NotNil(t, e, "data should be too long")
+}
+
+// The matrices in testdata/qr_v*.txt are from the QRCode encoder by Kazuhiko
+// Arase (as vendored by qrcode-terminal 0.12.0), at level M, for each of the
+// 8 masks in order.
+func TestNewQRCode_Reference(t *testing.T) {
+	seed := "abandon ability able about above absent absorb abstract absurd abuse access accident"
+	seeds := seed + " " + seed + " " + seed
+	for _, c := range []struct {
+		version int
+		data    string
+	}{
+		{1, "KittyCash"},
+		{5, "kittycash:2GdL5Q6f7Y8hE3afXbT3w8kVurU5zJ9bNGw?kitty=42&memo=a+kitty+of+the+IKO"},
+		{7, seeds[:115]}, // Carries the version information.
+		{9, seeds[:180]},
+	} {
+		raw, e := ioutil.ReadFile(filepath.Join("testdata", fmt.Sprintf("qr_v%d.txt", c.version)))
+		require.Nil(t, e, "failed to read reference matrices")
+		matrices := strings.Split(strings.TrimSpace(string(raw)), "\n\n")
+		require.Len(t, matrices, 8)
+		for mask, matrix := range matrices {
+			q, e := newQRCode([]byte(c.data), mask)
+			require.Nil(t, e, "failed to encode qr code")
+			require.Equal(t, 4*c.version+17, q.Size, "data should fit version %d", c.version)
+			var buf bytes.Buffer
+			for y := 0; y < q.Size; y++ {
+				if y > 0 {
+					buf.WriteByte('\n')
+				}
+				for x := 0; x < q.Size; x++ {
+					if q.Dark(x, y) {
+						buf.WriteByte('#')
+					} else {
+						buf.WriteByte('.')
+					}
+				}
+			}
+			require.Equal(t, matrix, buf.String(), "version %d with mask %d should match the reference", c.version, mask)
+		}
+	}
+}
+
+func TestManager_DirLock(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	m2, e := NewManager()
+	require.Nil(t, e, "managers in the same process should share the lock")
+
+	// Locks from another open file are as from another process.
+	f, e := os.OpenFile(filepath.Join(rootDir, LockFileName), os.O_RDWR, 0600)
+	require.Nil(t, e, "failed to open lock file")
+	defer f.Close()
+	require.Equal(t, errLockHeld, lockFile(f), "lock should be held")
+
+	m.Close()
+	m.Close()
+	require.Equal(t, errLockHeld, lockFile(f), "lock should be held until every manager is closed")
+	m2.Close()
+	require.Nil(t, lockFile(f), "lock should be released")
+
+	_, e = NewManager()
+	require.True(t, errors.Is(e, ErrDirLocked), "managers should fail while another process holds the lock")
+	require.Nil(t, unlockFile(f), "failed to unlock")
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	m.Close()
+}
+
+func TestManager_ScanHoldings(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	fw, e := m.CreateWallet(&Options{Label: "seed", Seed: "holdings seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, m.Lock("secret"), "failed to lock")
+	addr := cipher.MustDecodeBase58Address(fw.Entries[1].Address)
+
+	bc, tx := newTestChain(t, addr)
+	defer bc.Close()
+
+	h, e := m.Holdings("seed")
+	require.Nil(t, e, "failed to get holdings")
+	require.Nil(t, h, "wallets should not be scanned yet")
+
+	out, e := m.ScanHoldings(bc)
+	require.Nil(t, e, "failed to scan holdings")
+	require.Len(t, out, 1, "only unlocked wallets should be scanned")
+	require.Equal(t, "seed", out[0].Label)
+	require.Equal(t, tx.Seq, out[0].Seq, "scan should be at the head")
+	require.Equal(t, 1, out[0].Total)
+	require.Equal(t, []AddressHoldings{
+		{Address: addr.String(), Kitties: iko.KittyIDs{1}},
+	}, out[0].Addresses, "only addresses with kitties should be listed")
+
+	h, e = m.Holdings("seed")
+	require.Nil(t, e, "failed to get holdings")
+	require.Equal(t, out[0], *h, "holdings should be cached")
+	_, e = m.Holdings("secret")
+	require.Equal(t, ErrWalletLocked, e, "holdings for locked wallets should fail")
+
+	require.Nil(t, deleteWallet(m, "seed"), "failed to delete wallet")
+	_, e = m.Holdings("seed")
+	require.Equal(t, ErrWalletNotFound, e, "holdings for deleted wallets should be gone")
+}
+
+func TestWallet_SignTransfer(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	fw, e := m.CreateWallet(&Options{Label: "owner", Seed: "owner seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "other", Seed: "other seed"})
+	require.Nil(t, e, "failed to create wallet")
+	owner := cipher.MustDecodeBase58Address(fw.Entries[1].Address)
+	to := cipher.AddressFromSecKey(testSecKey2)
+
+	bc, _ := newTestChain(t, owner)
+	defer bc.Close()
+
+	_, e = m.SignTransfer(bc, "other", 1, to)
+	require.True(t, errors.Is(e, iko.ErrNotOwner), "kitties of other wallets should not be signed")
+	_, e = m.SignTransfer(bc, "owner", 2, to)
+	require.True(t, errors.Is(e, iko.ErrKittyUnknown), "unknown kitties should not be signed")
+
+	tx, e := m.SignTransfer(bc, "owner", 1, to)
+	require.Nil(t, e, "failed to sign transfer")
+	require.Equal(t, owner, tx.From, "transfer should be from the owner")
+	require.Nil(t, bc.InjectTx(tx), "signed transfer should be ready to inject")
+	state, _ := bc.GetKittyState(1)
+	require.Equal(t, to, state.Address, "kitty should be transferred")
+
+	// Wallets sign with their own keys alone.
+	w, e := NewFloatingWallet(&Options{Label: "copy", Seed: "owner seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, w.EnsureEntries(2), "failed to ensure entries")
+	_, e = w.SignTransfer(bc, 1, owner)
+	require.True(t, errors.Is(e, iko.ErrNotOwner), "transferred kitties should no longer be signed")
+}
+
+// newTestBlockChain creates a blockchain in memory, whose creator is
+// 'testSecKey'.
+func newTestBlockChain(t *testing.T, config iko.BlockChainConfig) *iko.BlockChain {
+	config.CreatorPK = cipher.PubKeyFromSecKey(testSecKey)
+	bc, e := iko.NewBlockChain(&config, iko.NewMemoryChain(10), iko.NewMemoryState())
+	require.Nil(t, e, "failed to create blockchain")
+	return bc
+}
+
+// newTestChain creates a chain with one kitty, transferred to an address.
+func newTestChain(t *testing.T, to cipher.Address) (*iko.BlockChain, *iko.Transaction) {
+	sk := testSecKey
+	bc := newTestBlockChain(t, iko.BlockChainConfig{})
+
+	prev := iko.NewGenTx(nil, 1, sk)
+	require.Nil(t, bc.InjectTx(prev), "failed to inject gen tx")
+	tx := iko.NewTransferTx(prev, 1, to, bc.NextNonce(cipher.AddressFromSecKey(sk)), sk)
+	require.Nil(t, bc.InjectTx(tx), "failed to inject transfer tx")
+	return bc, tx
+}
+
+func TestManager_SetKittyNote(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "notes seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.SetKittyNote("secret", 3, "Whiskers")
+	require.Nil(t, e, "failed to set note")
+	_, e = m.SetKittyNote("secret", 5, "bought for 2 SKY")
+	require.Nil(t, e, "failed to set note")
+	fw, e := m.SetKittyNote("secret", 1, "Tom")
+	require.Nil(t, e, "failed to set note")
+	require.Equal(t, KittyNotes{
+		{KittyID: 1, Note: "Tom"},
+		{KittyID: 3, Note: "Whiskers"},
+		{KittyID: 5, Note: "bought for 2 SKY"},
+	}, fw.Notes, "notes should be sorted by kitty")
+	fw, e = m.SetKittyNote("secret", 3, "")
+	require.Nil(t, e, "failed to remove note")
+	require.Len(t, fw.Notes, 2, "empty notes should remove the note")
+	_, e = m.SetKittyNote("secret", 1, strings.Repeat("x", MaxKittyNoteSize+1))
+	require.NotNil(t, e, "oversized notes should fail")
+
+	raw, e := ioutil.ReadFile(LabelPath("secret"))
+	require.Nil(t, e, "failed to read wallet file")
+	require.False(t, bytes.Contains(raw, []byte("bought for")), "notes should be encrypted")
+
+	m.Close()
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.SetKittyNote("secret", 1, "Jerry")
+	require.Equal(t, ErrWalletLocked, e, "notes for locked wallets should fail")
+	fw, e = m.DisplayWallet("secret", "pw")
+	require.Nil(t, e, "failed to unlock")
+	note, _ := fw.Notes.Get(5)
+	require.Equal(t, "bought for 2 SKY", note, "notes should persist")
+}
+
+func TestSetRootDir(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		t.Setenv("XDG_DATA_HOME", rootDir)
+		dir, e := DefaultRootDir()
+		require.Nil(t, e, "failed to obtain default root dir")
+		require.Equal(t, filepath.Join(rootDir, "kittycash", "wallet"), dir,
+			"default root dir should be in the data dir")
+	}
+
+	dir := filepath.Join(rootDir, "nested", "wallet")
+	require.Nil(t, SetRootDir(dir), "failed to set root dir")
+	require.Nil(t, os.RemoveAll(dir), "failed to remove root dir")
+	m, e := NewManager()
+	require.Nil(t, e, "managers should create the root dir")
+	defer m.Close()
+	info, e := os.Stat(dir)
+	require.Nil(t, e, "root dir should exist")
+	if runtime.GOOS != "windows" {
+		require.Equal(t, os.FileMode(0700), info.Mode().Perm(), "root dir should be for the user alone")
+	}
+}
+
+func TestManager_Accounts(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	fw, e := m.CreateWallet(&Options{Label: "one", Seed: "one seed", Addresses: 1, Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "watch", WatchOnly: true})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.AddWalletAccount("one", DefaultAccountName)
+	require.NotNil(t, e, "default account name should be reserved")
+	_, e = m.AddWalletAccount("watch", "personal")
+	require.Equal(t, ErrWatchOnly, e, "watch-only wallets should not have accounts")
+	for _, name := range []string{"personal", "trading"} {
+		_, e = m.AddWalletAccount("one", name)
+		require.Nil(t, e, "failed to add account")
+	}
+	_, e = m.AddWalletAccount("one", "personal")
+	require.NotNil(t, e, "account names should be unique")
+	_, e = m.NewWalletAccountAddress("one", "savings")
+	require.True(t, errors.Is(e, ErrAccountNotFound), "unknown accounts should fail")
+
+	personal, e := m.NewWalletAccountAddress("one", "personal")
+	require.Nil(t, e, "failed to derive address")
+	trading, e := m.NewWalletAccountAddress("one", "trading")
+	require.Nil(t, e, "failed to derive address")
+	sk, e := DeriveSecKey(AccountSeed("one seed", 1), 0)
+	require.Nil(t, e, "failed to derive key")
+	require.Equal(t, cipher.AddressFromSecKey(sk), personal, "accounts should derive from their index")
+	require.NotEqual(t, personal, trading, "accounts should not share addresses")
+	require.NotEqual(t, fw.Entries[0].Address, personal.String(), "accounts should not share addresses")
+
+	// Accounts persist in the wallet file, and are wiped by locks.
+	require.Nil(t, m.Lock("one"), "failed to lock wallet")
+	require.Nil(t, m.Unlock("one", "pw", 0), "failed to unlock wallet")
+	got, e := m.GetWallet("one")
+	require.Nil(t, e, "failed to get wallet")
+	require.Len(t, got.Accounts, 2, "accounts should be saved")
+	require.Equal(t, "personal", got.Accounts[0].Name, "account name should be saved")
+	require.Equal(t, "m/1", got.Accounts[0].Path, "account path should be its index")
+	require.Equal(t, personal.String(), got.Accounts[0].Entries[0].Address, "account entries should be saved")
+	require.Equal(t, "m/2", got.Accounts[1].Path, "account path should be its index")
+
+	s, e := m.Signer("one")
+	require.Nil(t, e, "failed to obtain signer")
+	entries, e := s.Entries()
+	require.Nil(t, e, "failed to obtain entries")
+	require.Len(t, entries, 3, "entries of accounts should be in the signer")
+	_, e = m.SignHash("one", trading, cipher.SumSHA256([]byte("hash")))
+	require.Nil(t, e, "addresses of accounts should sign")
+}
+
+func TestManager_WatchChain(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	one, e := m.CreateWallet(&Options{Label: "one", Seed: "one seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	two, e := m.CreateWallet(&Options{Label: "two", Seed: "two seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	oneAddr, e := cipher.DecodeBase58Address(one.Entries[0].Address)
+	require.Nil(t, e, "failed to decode address")
+	twoAddr, e := cipher.DecodeBase58Address(two.Entries[0].Address)
+	require.Nil(t, e, "failed to decode address")
+
+	bc, _ := newTestChain(t, oneAddr)
+	defer bc.Close()
+	m.WatchChain(bc)
+	sub := m.SubscribeEvents(10)
+
+	tx, e := m.SignTransfer(bc, "one", 1, twoAddr)
+	require.Nil(t, e, "failed to sign transfer")
+	require.Nil(t, bc.InjectTx(tx), "failed to inject transfer")
+
+	expected := []Event{
+		{Kind: EventKittySent, Label: "one", Address: oneAddr.String(), KittyID: 1, Counterparty: twoAddr.String()},
+		{Kind: EventKittyReceived, Label: "two", Address: twoAddr.String(), KittyID: 1, Counterparty: oneAddr.String()},
+	}
+	for _, want := range expected {
+		var got Event
+		select {
+		case got = <-sub.C():
+			// The transactions of the test chain may be broadcast after the watch.
+			for got.TxHash != tx.Hash().Hex() {
+				got = <-sub.C()
+			}
+			require.Equal(t, want.Kind, got.Kind, "events should have the change")
+			require.Equal(t, want.Label, got.Label, "events should have the wallet")
+			require.Equal(t, want.Address, got.Address, "events should have the address")
+			require.Equal(t, want.Counterparty, got.Counterparty, "events should have the other address")
+			require.Equal(t, want.KittyID, got.KittyID, "events should have the kitty")
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for wallet event")
+		}
+	}
+	require.Equal(t, "address "+twoAddr.String()+" received kitty 1", expected[1].String(),
+		"events should describe themselves")
+
+	m.Close()
+	_, ok := <-sub.C()
+	require.False(t, ok, "subscriptions should be closed with the manager")
+}
+
+func TestManager_DiscoverAddresses(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	sk := testSecKey
+	derive := func(seed string, i int) cipher.Address {
+		dsk, e := DeriveSecKey(seed, i)
+		require.Nil(t, e, "failed to derive key")
+		return cipher.AddressFromSecKey(dsk)
+	}
+
+	// Kitties are at the addresses with index 0 and 3, and with index 1 in the
+	// account.
+	bc, prev := newTestChain(t, derive("restore seed", 0))
+	defer bc.Close()
+	for i, to := range []cipher.Address{
+		derive("restore seed", 3),
+		derive(AccountSeed("restore seed", 1), 1),
+	} {
+		kittyID := iko.KittyID(i + 2)
+		gen := iko.NewGenTx(prev, kittyID, sk)
+		require.Nil(t, bc.InjectTx(gen), "failed to inject gen tx")
+		prev = iko.NewTransferTx(gen, kittyID, to, bc.NextNonce(cipher.AddressFromSecKey(sk)), sk)
+		require.Nil(t, bc.InjectTx(prev), "failed to inject transfer tx")
+	}
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "restored", Seed: "restore seed"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.AddWalletAccount("restored", "trading")
+	require.Nil(t, e, "failed to add account")
+	_, e = m.CreateWallet(&Options{Label: "watch", WatchOnly: true})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.DiscoverAddresses(bc, "restored", MaxGapLimit+1)
+	require.NotNil(t, e, "gap limit should be bounded")
+	_, e = m.DiscoverAddresses(bc, "watch", 0)
+	require.Equal(t, ErrWatchOnly, e, "watch-only wallets have no seed to discover")
+
+	fw, e := m.DiscoverAddresses(bc, "restored", 2)
+	require.Nil(t, e, "failed to discover addresses")
+	require.Len(t, fw.Entries, 1, "addresses after the gap should not be discovered")
+	require.Len(t, fw.Accounts[0].Entries, 2, "addresses of accounts should be discovered")
+
+	fw, e = m.DiscoverAddresses(bc, "restored", 3)
+	require.Nil(t, e, "failed to discover addresses")
+	require.Len(t, fw.Entries, 4, "addresses up to the last used should be discovered")
+	require.Equal(t, derive("restore seed", 3).String(), fw.Entries[3].Address,
+		"discovered addresses should be from the seed")
+	require.Len(t, loadWallet(t, "restored", "").Entries, 4, "discovered addresses should be saved")
+}
+
+func TestManager_RequireEncrypted(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "plain seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "secret", Seed: "secret seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	var buf bytes.Buffer
+	require.Nil(t, m.Backup(&buf, "backup pw"), "failed to backup")
+	require.Nil(t, deleteWallet(m, "plain"), "failed to delete wallet")
+	m.Close()
+
+	m, e = NewManagerWithConfig(ManagerConfig{RequireEncrypted: true})
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "other", Seed: "other seed"})
+	require.Equal(t, ErrUnencryptedWallet, e, "unencrypted wallets should not be created")
+	_, e = m.CreateWallet(&Options{Label: "other", Seed: "other seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "encrypted wallets should be created")
+
+	_, e = m.Restore(bytes.NewReader(buf.Bytes()), "backup pw", RestoreMerge)
+	require.True(t, errors.Is(e, ErrUnencryptedWallet), "unencrypted wallets should not be restored")
+	trash, e := m.ListTrash()
+	require.Nil(t, e, "failed to list trash")
+	_, e = m.RecoverWallet(trash[0].ID)
+	require.Equal(t, ErrUnencryptedWallet, e, "unencrypted wallets should not be recovered")
+
+	// Unencrypted files are skipped, rather than removed.
+	w, e := NewFloatingWallet(&Options{Label: "plain", Seed: "plain seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, w.Save(), "failed to save wallet")
+	require.Nil(t, m.Refresh(), "failed to refresh")
+	for _, stat := range m.ListWallets() {
+		require.True(t, stat.Encrypted, "unencrypted wallets should not be loaded")
+	}
+	_, e = os.Stat(LabelPath("plain"))
+	require.Nil(t, e, "unencrypted files should be kept")
+}
+
+func TestManager_Duplicates(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	one, e := m.CreateWallet(&Options{Label: "one", Seed: "dup seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "two", WatchOnly: true, WatchAddresses: []string{one.Entries[0].Address}})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "three", Seed: "three seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	require.Equal(t, []DuplicateAddress{{Address: one.Entries[0].Address, Labels: []string{"one", "two"}}},
+		m.FindDuplicates(), "watched addresses of other wallets should be duplicates")
+
+	_, e = m.ImportWalletKey("three", one.Entries[0].SecKey, "")
+	require.True(t, errors.Is(e, ErrDuplicateAddress), "duplicate keys should be rejected")
+	var dupErr *DuplicateError
+	require.True(t, errors.As(e, &dupErr), "duplicates should be structured")
+	require.Equal(t, []string{"one", "two"}, dupErr.Duplicates[0].Labels, "duplicates should have the other wallets")
+	res, e := m.ImportWalletKeyWithPolicy("three", one.Entries[0].SecKey, "", DuplicateMerge)
+	require.Nil(t, e, "failed to merge key")
+	require.False(t, res.Imported, "merged keys should not be imported")
+	require.Len(t, res.Duplicates, 1, "merged keys should be reported")
+	require.Empty(t, res.Wallet.Imported, "merged keys should not be imported")
+	res, e = m.ImportWalletKeyWithPolicy("three", one.Entries[0].SecKey, "", DuplicateAllow)
+	require.Nil(t, e, "failed to import key")
+	require.True(t, res.Imported, "allowed keys should be imported")
+	require.Len(t, res.Duplicates, 1, "allowed keys should be reported")
+	require.Len(t, m.FindDuplicates()[0].Labels, 3, "allowed keys should be duplicates")
+
+	// Restores of wallets with addresses of other wallets.
+	var buf bytes.Buffer
+	require.Nil(t, m.Backup(&buf, "backup pw"), "failed to backup")
+	for _, label := range []string{"two", "three"} {
+		require.Nil(t, deleteWallet(m, label), "failed to delete wallet")
+	}
+	require.Nil(t, deleteWallet(m, "one"), "failed to delete wallet")
+	_, e = m.CreateWallet(&Options{Label: "four", Seed: "dup seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	_, e = m.Restore(bytes.NewReader(buf.Bytes()), "backup pw", RestoreAbort)
+	require.True(t, errors.Is(e, ErrDuplicateAddress), "duplicate wallets should not be restored")
+	require.Len(t, m.ListWallets(), 1, "nothing should be restored")
+	restored, e := m.RestoreWithPolicy(bytes.NewReader(buf.Bytes()), "backup pw", RestoreAbort, DuplicateMerge)
+	require.Nil(t, e, "failed to restore")
+	require.Equal(t, []string{"one", "three", "two"}, restored.Skipped, "duplicate wallets should be skipped")
+	require.NotEmpty(t, restored.Duplicates, "duplicates should be reported")
+	restored, e = m.RestoreWithPolicy(bytes.NewReader(buf.Bytes()), "backup pw", RestoreAbort, DuplicateAllow)
+	require.Nil(t, e, "failed to restore")
+	require.Len(t, restored.Restored, 3, "duplicate wallets should be restored")
+}
+
+func TestManager_Reload(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "kept", Seed: "kept seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "gone", Seed: "gone seed"})
+	require.Nil(t, e, "failed to create wallet")
+
+	// Wallet files that are changed by other tools.
+	data, e := ioutil.ReadFile(LabelPath("gone"))
+	require.Nil(t, e, "failed to read wallet file")
+	require.Nil(t, ioutil.WriteFile(LabelPath("added"), data, 0600), "failed to add wallet file")
+	require.Nil(t, ioutil.WriteFile(LabelPath("bad"), []byte("bad"), 0600), "failed to add wallet file")
+	require.Nil(t, os.Remove(LabelPath("gone")), "failed to remove wallet file")
+
+	res, e := m.Reload()
+	require.Nil(t, e, "failed to reload")
+	require.Equal(t, []string{"added"}, res.Added, "added wallet files should be loaded")
+	require.Equal(t, []string{"gone"}, res.Removed, "removed wallet files should be removed")
+	fw, e := m.GetWallet("kept")
+	require.Nil(t, e, "listed wallets should stay unlocked")
+	require.Equal(t, "kept seed", fw.Meta.Seed, "listed wallets should be kept")
+	fw, e = m.GetWallet("added")
+	require.Nil(t, e, "added wallet should be loaded")
+	require.Equal(t, "gone seed", fw.Meta.Seed, "added wallet should be from its file")
+	_, e = m.GetWallet("gone")
+	require.Equal(t, ErrWalletNotFound, e, "removed wallet should not be found")
+
+	res, e = m.Reload()
+	require.Nil(t, e, "failed to reload")
+	require.Empty(t, res.Added, "nothing should be added")
+	require.Empty(t, res.Removed, "nothing should be removed")
+
+	// The directory watch.
+	m.WatchDir(10 * time.Millisecond)
+	require.Nil(t, os.Remove(LabelPath("added")), "failed to remove wallet file")
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if _, e = m.GetWallet("added"); e == ErrWalletNotFound {
+			break
+		}
+	}
+	require.Equal(t, ErrWalletNotFound, e, "watched removals should be removed")
+
+	// Changes are notified on linux, rather than polled.
+	if runtime.GOOS == "linux" {
+		m.WatchDir(time.Hour)
+		require.Nil(t, ioutil.WriteFile(LabelPath("notified"), data, 0600), "failed to add wallet file")
+		for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+			if _, e = m.GetWallet("notified"); e == nil {
+				break
+			}
+		}
+		require.Nil(t, e, "notified wallet files should be loaded")
+	}
+}
+
+func TestManager_MemoryStore(t *testing.T) {
+	SetStore(NewMemoryStore())
+	defer SetStore(DirStore{})
+	dir, e := ioutil.TempDir("", "kittycash_test")
+	require.Nil(t, e, "failed to create temp dir")
+	require.Nil(t, os.Remove(dir), "failed to remove temp dir")
+	require.Nil(t, SetRootDir(dir), "failed to set root dir")
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	fw, e := m.CreateWallet(&Options{Label: "secret", Seed: "memory seed", Encrypted: true, Password: "pw", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.CreateWallet(&Options{Label: "trashed", Seed: "trashed seed"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, m.AddressBook().Put(Contact{Name: "alice", Address: fw.Entries[0].Address}),
+		"failed to put contact")
+	require.Nil(t, deleteWallet(m, "trashed"), "failed to delete wallet")
+	trash, e := m.ListTrash()
+	require.Nil(t, e, "failed to list trash")
+	require.Len(t, trash, 1, "deleted wallet should be in the trash")
+	purged, e := m.PurgeTrash(time.Now().Add(time.Hour), true)
+	require.Nil(t, e, "failed to purge trash")
+	require.Len(t, purged, 1, "deleted wallet should be purged")
+	m.Close()
+
+	// Managers with the same store share its files.
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	require.Len(t, m.ListWallets(), 1, "wallets should be in the store")
+	require.Nil(t, m.Unlock("secret", "pw", 0), "failed to unlock wallet")
+	fw, e = m.GetWallet("secret")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, "memory seed", fw.Meta.Seed, "wallet should be from the store")
+	require.Len(t, m.AddressBook().List(), 1, "address book should be in the store")
+
+	_, e = os.Stat(dir)
+	require.True(t, os.IsNotExist(e), "nothing should be written to disk")
+}
+
+func TestManager_Keystores(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	// A fake vault with the paths of its secrets.
+	secrets := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		key := filepath.Base(r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := secrets[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data":`))
+			w.Write(data)
+			w.Write([]byte(`}`))
+		case http.MethodPost:
+			secrets[key], _ = ioutil.ReadAll(r.Body)
+		case http.MethodDelete:
+			delete(secrets, key)
+		}
+	}))
+	defer srv.Close()
+	RegisterKeystore("test-vault", NewVaultKeystore(srv.URL, "token", ""))
+	require.Contains(t, Keystores(), "test-vault", "keystore should be registered")
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "vaulted", Seed: "vault seed", Keystore: "unknown"})
+	require.True(t, errors.Is(e, ErrKeystoreNotFound), "keystores should be registered")
+	_, e = m.CreateWallet(&Options{Label: "vaulted", Seed: "vault seed", Keystore: "test-vault", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	raw, e := ioutil.ReadFile(LabelPath("vaulted"))
+	require.Nil(t, e, "failed to read wallet file")
+	require.True(t, bytes.HasPrefix(raw, keystoreMagic), "wallet file should reference the keystore")
+	require.NotContains(t, string(raw), "vault seed", "wallet file should not have the data")
+	require.Len(t, secrets, 1, "data should be in the keystore")
+
+	require.Nil(t, m.Refresh(), "failed to refresh")
+	fw, e := m.GetWallet("vaulted")
+	require.Nil(t, e, "failed to get wallet")
+	require.Equal(t, "test-vault", fw.Meta.Keystore, "wallet should be in the keystore")
+	require.Equal(t, "vault seed", fw.Meta.Seed, "wallet should be loaded from the keystore")
+	var buf bytes.Buffer
+	require.Nil(t, m.Backup(&buf, "backup pw"), "failed to backup")
+	require.Nil(t, VerifyBackup(bytes.NewReader(buf.Bytes())), "backups should have the data")
+
+	// Moving between keystores.
+	fw, e = m.SetWalletKeystore("vaulted", "")
+	require.Nil(t, e, "failed to set keystore")
+	require.Equal(t, KeystoreFile, fw.Meta.Keystore, "wallet should be in the file")
+	require.Empty(t, secrets, "data should be removed from the previous keystore")
+	_, e = m.SetWalletKeystore("vaulted", "test-vault")
+	require.Nil(t, e, "failed to set keystore")
+	require.Len(t, secrets, 1, "data should be in the keystore")
+
+	// Deleted wallets keep their data until purged.
+	require.Nil(t, deleteWallet(m, "vaulted"), "failed to delete wallet")
+	require.Len(t, secrets, 1, "data in the trash should be kept")
+	_, e = m.PurgeTrash(time.Now().Add(time.Hour), false)
+	require.Nil(t, e, "failed to purge trash")
+	require.Empty(t, secrets, "data should be purged from the keystore")
+}
+
+func TestSplitSeed(t *testing.T) {
+	seed := "split seed with many bytes"
+	shares, e := SplitSeed(seed, 5, 3)
+	require.Nil(t, e, "failed to split seed")
+	require.Len(t, shares, 5, "seed should be in every share")
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var picked []string
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		combined, e := CombineSeed(picked)
+		require.Nil(t, e, "failed to combine shares %v", subset)
+		require.Equal(t, seed, combined, "shares %v should reassemble the seed", subset)
+	}
+
+	_, e = CombineSeed(shares[:2])
+	require.Equal(t, ErrTooFewSeedShares, e, "shares below the threshold should fail")
+	_, e = CombineSeed([]string{shares[0], shares[0], shares[1]})
+	require.Equal(t, ErrTooFewSeedShares, e, "duplicate shares should be counted once")
+	_, e = CombineSeed([]string{"bad", shares[0], shares[1]})
+	require.True(t, errors.Is(e, ErrInvalidSeedShare), "bad shares should fail")
+	_, e = CombineSeed([]string{"01000100000000aa"})
+	require.True(t, errors.Is(e, ErrInvalidSeedShare), "shares with a threshold below 2 should fail")
+	other, e := SplitSeed("other seed with many bytes", 5, 3)
+	require.Nil(t, e, "failed to split seed")
+	_, e = CombineSeed([]string{shares[0], shares[1], other[2]})
+	require.Equal(t, ErrSeedSharesMismatch, e, "shares from other seeds should fail")
+
+	// Tampering is caught by the checksum.
+	raw, _ := hex.DecodeString(shares[2])
+	raw[len(raw)-1] ^= 1
+	_, e = CombineSeed([]string{shares[0], shares[1], hex.EncodeToString(raw)})
+	require.True(t, errors.Is(e, ErrInvalidSeedShare), "tampered shares should fail")
+
+	for _, nk := range [][2]int{{1, 1}, {3, 1}, {3, 4}, {256, 2}} {
+		_, e = SplitSeed(seed, nk[0], nk[1])
+		require.NotNil(t, e, "shares of %d with threshold %d should be invalid", nk[0], nk[1])
+	}
+
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "split", Seed: seed})
+	require.Nil(t, e, "failed to create wallet")
+	shares, e = m.SplitWalletSeed("split", 3, 2)
+	require.Nil(t, e, "failed to split wallet seed")
+	combined, e := CombineSeed(shares[1:])
+	require.Nil(t, e, "failed to combine shares")
+	require.Equal(t, seed, combined, "shares should reassemble the seed of the wallet")
+}
+
+func TestManager_Skycoin(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+
+	// A Skycoin wallet with 2 entries from the seed, and an imported key.
+	sw := SkycoinWallet{
+		Meta: map[string]string{"coin": "skycoin", "type": "deterministic", "label": "sky", "seed": "sky seed"},
+	}
+	next := []byte("sky seed")
+	for i := 0; i < 2; i++ {
+		var sk cipher.SecKey
+		next, _, sk = cipher.DeterministicKeyPairIterator(next)
+		sw.Entries = append(sw.Entries, skycoinEntry(sk))
+	}
+	_, extra := cipher.GenerateKeyPair()
+	sw.Entries = append(sw.Entries, skycoinEntry(extra))
+	raw, e := json.Marshal(sw)
+	require.Nil(t, e, "failed to encode skycoin wallet")
+
+	parsed, e := ParseSkycoinWallet(bytes.NewReader(raw))
+	require.Nil(t, e, "failed to parse skycoin wallet")
+	res, e := m.CreateSkycoinWallet(parsed, &Options{Label: "sky"})
+	require.Nil(t, e, "failed to create wallet from skycoin wallet")
+	require.Equal(t, 2, res.Derived, "entries from the seed should be derived")
+	require.Equal(t, 1, res.Imported, "other entries should be imported")
+	require.Equal(t, sw.Entries[1].Address, res.Wallet.Entries[1].Address, "entries should have the skycoin addresses")
+	_, e = m.CreateSkycoinWallet(parsed, &Options{Label: "sky", Seed: "other"})
+	require.NotNil(t, e, "wallets from skycoin wallets should use the skycoin seed")
+
+	// Imports into existing wallets.
+	_, e = m.CreateWallet(&Options{Label: "kitty", Seed: "kitty seed"})
+	require.Nil(t, e, "failed to create wallet")
+	_, e = m.ImportSkycoinKeys("kitty", parsed, DuplicateReject)
+	require.True(t, errors.Is(e, ErrDuplicateAddress), "keys of other wallets should be rejected")
+	res, e = m.ImportSkycoinKeys("kitty", parsed, DuplicateMerge)
+	require.Nil(t, e, "failed to merge keys")
+	require.Equal(t, 0, res.Imported, "keys of other wallets should be merged")
+	require.Len(t, res.Skipped, 3, "merged keys should be skipped")
+	res, e = m.ImportSkycoinKeys("kitty", parsed, DuplicateAllow)
+	require.Nil(t, e, "failed to import keys")
+	require.Equal(t, 3, res.Imported, "allowed keys should be imported")
+	require.Equal(t, "sky", res.Wallet.Imported[0].Label, "keys should have the skycoin label")
+	res, e = m.ImportSkycoinKeys("kitty", parsed, DuplicateAllow)
+	require.Nil(t, e, "failed to import keys")
+	require.Equal(t, 0, res.Imported, "keys already in the wallet should be skipped")
+
+	// Invalid Skycoin wallets.
+	sw.Entries[0].Address = sw.Entries[2].Address
+	raw, _ = json.Marshal(sw)
+	_, e = ParseSkycoinWallet(bytes.NewReader(raw))
+	require.NotNil(t, e, "entries with keys of other addresses should be rejected")
+	sw.Meta["encrypted"] = "true"
+	raw, _ = json.Marshal(sw)
+	_, e = ParseSkycoinWallet(bytes.NewReader(raw))
+	require.True(t, errors.Is(e, ErrSkycoinEncrypted), "encrypted skycoin wallets should be rejected")
+}
+
+func skycoinEntry(sk cipher.SecKey) SkycoinEntry {
+	return SkycoinEntry{
+		Address: cipher.AddressFromSecKey(sk).String(),
+		PubKey:  cipher.PubKeyFromSecKey(sk).Hex(),
+		SecKey:  sk.Hex(),
+	}
+}
+
+func TestManager_ListWalletsPage(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	for _, label := range []string{"cat", "Catnip", "dog", "kitten"} {
+		_, e = m.CreateWallet(&Options{Label: label, Seed: label + " seed"})
+		require.Nil(t, e, "failed to create wallet")
+	}
+	_, e = m.CreateWallet(&Options{Label: "locked", Seed: "locked seed", Encrypted: true, Password: "pw"})
+	require.Nil(t, e, "failed to create wallet")
+	require.Nil(t, m.Lock("locked"), "failed to lock wallet")
+	m.holdings["dog"] = &Holdings{Label: "dog", Total: 3}
+	m.holdings["kitten"] = &Holdings{Label: "kitten", Total: 5}
+
+	labels := func(page *WalletPage) []string {
+		out := make([]string, len(page.Wallets))
+		for i, s := range page.Wallets {
+			out[i] = s.Label
+		}
+		return out
+	}
+	page, e := m.ListWalletsPage(ListOptions{Filter: "CAT"})
+	require.Nil(t, e, "failed to list wallets")
+	require.Equal(t, []string{"Catnip", "cat"}, labels(page), "labels should be filtered in any case")
+
+	page, e = m.ListWalletsPage(ListOptions{PerPage: 2, Page: 2})
+	require.Nil(t, e, "failed to list wallets")
+	require.Equal(t, 5, page.Total, "every wallet should be counted")
+	require.Equal(t, uint64(3), page.TotalPageCount, "pages should have per page")
+	require.Equal(t, []string{"locked"}, labels(page), "the last page should have the rest")
+	page, e = m.ListWalletsPage(ListOptions{PerPage: 2, Page: 3})
+	require.Nil(t, e, "failed to list wallets")
+	require.Empty(t, page.Wallets, "pages after the last should be empty")
+
+	page, e = m.ListWalletsPage(ListOptions{Sort: SortByHoldings, Desc: true})
+	require.Nil(t, e, "failed to list wallets")
+	require.Equal(t, []string{"kitten", "dog", "Catnip", "cat", "locked"}, labels(page),
+		"wallets should be by holdings, and then by labels")
+	page, e = m.ListWalletsPage(ListOptions{Sort: SortByCreated, Desc: true})
+	require.Nil(t, e, "failed to list wallets")
+	require.Equal(t, []string{"kitten", "dog", "Catnip", "cat", "locked"}, labels(page),
+		"locked wallets should be listed last")
+
+	_, e = m.ListWalletsPage(ListOptions{Sort: "size"})
+	require.NotNil(t, e, "invalid sorts should fail")
+}
+
+func TestWallet_NewAddresses(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.CreateWallet(&Options{Label: "deposits", Seed: "deposit seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+
+	addrs, e := m.NewWalletAddresses("deposits", 50)
+	require.Nil(t, e, "failed to derive addresses")
+	require.Len(t, addrs, 50, "every address should be derived")
+	_, sks := cipher.GenerateDeterministicKeyPairsSeed([]byte("deposit seed"), 51)
+	require.Equal(t, cipher.AddressFromSecKey(sks[50]), addrs[49], "addresses should be at the next indexes")
+
+	for _, n := range []int{0, -1, MaxNewAddresses + 1} {
+		_, e = m.NewWalletAddresses("deposits", n)
+		require.NotNil(t, e, "%d addresses should be invalid", n)
+	}
+
+	// Every address is in the wallet file.
+	require.Nil(t, m.Refresh(), "failed to refresh")
+	fw, e := m.GetWallet("deposits")
+	require.Nil(t, e, "failed to get wallet")
+	require.Len(t, fw.Entries, 51, "addresses should be saved")
+}
+
+func TestManager_Hold(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	until := time.Now().Add(time.Hour)
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "seed", HoldUntil: until.UnixNano()})
+	require.Equal(t, ErrHoldUnencrypted, e, "only encrypted wallets should be put on hold")
+	fw, e := m.CreateWallet(&Options{
+		Label: "gift", Seed: "gift seed", Encrypted: true, Password: "pw", Addresses: 1, HoldUntil: until.UnixNano(),
+	})
+	require.Nil(t, e, "failed to create wallet")
+	require.Empty(t, fw.Meta.Seed, "seeds of held wallets should not be obtainable")
+	require.Empty(t, fw.Entries[0].SecKey, "secret keys of held wallets should not be obtainable")
+	addr, e := cipher.DecodeBase58Address(fw.Entries[0].Address)
+	require.Nil(t, e, "failed to decode address")
+
+	_, e = m.SignHash("gift", addr, cipher.SumSHA256([]byte("hash")))
+	require.True(t, errors.Is(e, ErrWalletHeld), "held wallets should not sign")
+	_, e = m.SplitWalletSeed("gift", 3, 2)
+	require.True(t, errors.Is(e, ErrWalletHeld), "seeds of held wallets should not be split")
+	e = m.Export(ioutil.Discard, []string{"gift"}, ExportOptions{SecretKeys: true, Confirm: ExportSecretKeysConfirmation})
+	require.True(t, errors.Is(e, ErrWalletHeld), "secret keys of held wallets should not be exported")
+
+	// Holds are saved in the wallet file, and can only be extended.
+	_, e = m.SetWalletHold("gift", until.Add(-time.Minute))
+	require.NotNil(t, e, "holds should not be brought forward")
+	_, e = m.SetWalletHold("gift", until.Add(time.Hour))
+	require.Nil(t, e, "failed to extend hold")
+	require.Nil(t, m.Refresh(), "failed to refresh")
+	fw, e = m.DisplayWallet("gift", "pw")
+	require.Nil(t, e, "failed to unlock wallet")
+	require.Equal(t, until.Add(time.Hour).UnixNano(), fw.Meta.HoldUntil, "holds should be saved")
+
+	// Wallets sign once their holds are due.
+	fw, e = m.CreateWallet(&Options{
+		Label: "due", Seed: "due seed", Encrypted: true, Password: "pw", Addresses: 1,
+		HoldUntil: time.Now().Add(50 * time.Millisecond).UnixNano(),
+	})
+	require.Nil(t, e, "failed to create wallet")
+	time.Sleep(100 * time.Millisecond)
+	addr, _ = cipher.DecodeBase58Address(fw.Entries[0].Address)
+	_, e = m.SignHash("due", addr, cipher.SumSHA256([]byte("hash")))
+	require.Nil(t, e, "wallets should sign once holds are due")
+	fw, e = m.GetWallet("due")
+	require.Nil(t, e, "failed to get wallet")
+	require.NotEmpty(t, fw.Entries[0].SecKey, "secret keys should be obtainable once holds are due")
+}
+
+func TestManager_AuditLog(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	fw, e := m.CreateWallet(&Options{Label: "audited", Seed: "audit seed", Encrypted: true, Password: "pw", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	addr, _ := cipher.DecodeBase58Address(fw.Entries[0].Address)
+	require.Nil(t, m.Lock("audited"), "failed to lock wallet")
+
+	require.Equal(t, ErrInvalidPassword, m.Unlock("audited", "wrong", 0), "wrong passwords should fail")
+	require.Nil(t, m.Unlock("audited", "pw", 0), "failed to unlock wallet")
+	_, e = m.SignHash("audited", addr, cipher.SumSHA256([]byte("hash")))
+	require.Nil(t, e, "failed to sign")
+	require.Nil(t, m.Export(ioutil.Discard, nil, ExportOptions{Format: ExportCSV}), "failed to export")
+
+	entries, e := m.AuditLog(AuditQuery{})
+	require.Nil(t, e, "failed to read audit log")
+	actions := make([]AuditAction, len(entries))
+	for i, entry := range entries {
+		actions[i] = entry.Action
+	}
+	require.Equal(t, []AuditAction{AuditPasswordFailed, AuditUnlock, AuditSign, AuditExport}, actions,
+		"actions should be recorded in order")
+	require.Equal(t, addr.String(), entries[2].Detail, "signatures should have their addresses")
+
+	entries, e = m.AuditLog(AuditQuery{Label: "audited", Action: AuditSign})
+	require.Nil(t, e, "failed to query audit log")
+	require.Len(t, entries, 1, "entries should match the query")
+	entries, e = m.AuditLog(AuditQuery{Limit: 2})
+	require.Nil(t, e, "failed to query audit log")
+	require.Equal(t, AuditExport, entries[1].Action, "limits should keep the latest entries")
+
+	// The log is continued by new managers, and is tamper-evident.
+	head, e := m.VerifyAuditLog()
+	require.Nil(t, e, "failed to verify audit log")
+	m.Close()
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	require.Nil(t, m.Unlock("audited", "pw", 0), "failed to unlock wallet")
+	entries, e = m.AuditLog(AuditQuery{})
+	require.Nil(t, e, "failed to read audit log")
+	require.Len(t, entries, 5, "the log should be continued")
+	require.Equal(t, head, entries[3].MAC, "the chain should be continued")
+
+	raw, e := ioutil.ReadFile(AuditLogPath())
+	require.Nil(t, e, "failed to read audit log")
+	tampered := bytes.Replace(raw, []byte(`"action":"password_failed"`), []byte(`"action":"unlock"`), 1)
+	require.Nil(t, ioutil.WriteFile(AuditLogPath(), tampered, 0600), "failed to tamper audit log")
+	_, e = m.AuditLog(AuditQuery{})
+	require.True(t, errors.Is(e, ErrAuditTampered), "changed entries should be detected")
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	require.Nil(t, ioutil.WriteFile(AuditLogPath(), bytes.Join(lines[1:], nil), 0600), "failed to tamper audit log")
+	_, e = m.VerifyAuditLog()
+	require.True(t, errors.Is(e, ErrAuditTampered), "removed entries should be detected")
+
+	// Recording goes on in a new log, which names the tampered log.
+	require.Nil(t, m.Lock("audited"), "failed to lock wallet")
+	require.Nil(t, m.Unlock("audited", "pw", 0), "failed to unlock wallet")
+	entries, e = m.AuditLog(AuditQuery{})
+	require.Nil(t, e, "failed to read new audit log")
+	require.Len(t, entries, 2, "the new log should be started")
+	require.Equal(t, AuditTampered, entries[0].Action)
+	require.Equal(t, AuditUnlock, entries[1].Action, "entries should be recorded after tampering")
+	moved, e := ioutil.ReadFile(filepath.Join(rootDir, entries[0].Detail))
+	require.Nil(t, e, "the tampered log should be kept")
+	require.Equal(t, bytes.Join(lines[1:], nil), moved)
+	m.Close()
+
+	// Entries can not be rewritten without the key of the log.
+	require.Nil(t, os.Remove(filepath.Join(rootDir, AuditKeyFile)), "failed to remove audit key")
+	m, e = NewManager()
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.VerifyAuditLog()
+	require.True(t, errors.Is(e, ErrAuditTampered), "entries with other keys should be rejected")
+	m.Close()
+
+	m, e = NewManagerWithConfig(ManagerConfig{AuditSecret: []byte("secret")})
+	require.Nil(t, e, "failed to create manager")
+	require.Nil(t, os.Remove(AuditLogPath()), "failed to remove audit log")
+	require.Nil(t, m.Unlock("audited", "pw", 0), "failed to unlock wallet")
+	m.Close()
+	m, e = NewManagerWithConfig(ManagerConfig{AuditSecret: []byte("other")})
+	require.Nil(t, e, "failed to create manager")
+	_, e = m.VerifyAuditLog()
+	require.True(t, errors.Is(e, ErrAuditTampered), "entries with other secrets should be rejected")
+	m.Close()
+	m, e = NewManagerWithConfig(ManagerConfig{AuditSecret: []byte("secret")})
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	_, e = m.VerifyAuditLog()
+	require.Nil(t, e, "entries with the secret should be accepted")
+}
+
+func TestEstimatePassword(t *testing.T) {
+	for _, c := range []struct {
+		password string
+		max, min int
+	}{
+		{"", 0, 0},
+		{"password", 0, 0},
+		{"P@ssw0rd", 0, 0},
+		{"qwertyuiop", 0, 0},
+		{"abcdefgh", 1, 0},
+		{"aaaaaaaaaa", 1, 0},
+		{"savings", 0, 0}, // From the user inputs.
+		{"correct horse battery staple", 4, 4},
+		{"hG7#kd92!xPq", 4, 4},
+	} {
+		s := EstimatePassword(c.password, "savings")
+		require.True(t, s.Score >= c.min && s.Score <= c.max,
+			"score of '%s' should be between %d and %d, not %d", c.password, c.min, c.max, s.Score)
+		if s.Score <= 2 {
+			require.NotEmpty(t, s.Suggestions, "weak passwords should have suggestions")
+		}
+	}
+	require.NotEmpty(t, EstimatePassword("P@ssw0rd").Warning, "common passwords should be warned about")
+	require.True(t, estimatePassword("2026", 2026).GuessesLog10 < estimatePassword("2026", 1950).GuessesLog10,
+		"years should be guessed from the current year")
+
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+	_, e := NewManagerWithConfig(ManagerConfig{MinPasswordScore: MaxPasswordScore + 1})
+	require.NotNil(t, e, "invalid minimum scores should fail")
+	m, e := NewManagerWithConfig(ManagerConfig{MinPasswordScore: 3})
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	require.False(t, m.CheckPassword("password").Acceptable, "weak passwords should not be acceptable")
+
+	_, e = m.CreateWallet(&Options{Label: "weak", Seed: "seed", Encrypted: true, Password: "letmein1"})
+	require.True(t, errors.Is(e, ErrWeakPassword), "weak passwords should be refused")
+	_, e = m.CreateWallet(&Options{Label: "plain", Seed: "seed"})
+	require.Nil(t, e, "unencrypted wallets should not need passwords")
+	_, e = m.CreateWallet(&Options{Label: "strong", Seed: "strong seed", Encrypted: true, Password: "hG7#kd92!xPq"})
+	require.Nil(t, e, "failed to create wallet with strong password")
+	e = m.ChangeWalletPassword("strong", "hG7#kd92!xPq", "strong1")
+	require.True(t, errors.Is(e, ErrWeakPassword), "weak passwords should be refused for password changes")
+}
+
+func TestManager_History(t *testing.T) {
+	rmTemp := initTempDir(t)
+	defer rmTemp()
+
+	m, e := NewManager()
+	require.Nil(t, e, "failed to create manager")
+	defer m.Close()
+	one, e := m.CreateWallet(&Options{Label: "one", Seed: "one seed", Addresses: 2})
+	require.Nil(t, e, "failed to create wallet")
+	two, e := m.CreateWallet(&Options{Label: "two", Seed: "two seed", Addresses: 1})
+	require.Nil(t, e, "failed to create wallet")
+	a0 := cipher.MustDecodeBase58Address(one.Entries[0].Address)
+	a1 := cipher.MustDecodeBase58Address(one.Entries[1].Address)
+	b0 := cipher.MustDecodeBase58Address(two.Entries[0].Address)
+
+	bc, _ := newTestChain(t, a0)
+	defer bc.Close()
+	for _, to := range []cipher.Address{a1, b0} {
+		tx, e := m.SignTransfer(bc, "one", 1, to)
+		require.Nil(t, e, "failed to sign transfer")
+		require.Nil(t, bc.InjectTx(tx), "failed to inject transfer")
+	}
+
+	h, e := m.History(bc, "one", 0, 0)
+	require.Nil(t, e, "failed to get history")
+	require.Equal(t, 4, h.Total, "internal transfers should be both a send and a receive")
+	require.Equal(t, uint64(1), h.TotalPageCount)
+	expected := []struct {
+		kind EventKind
+		addr cipher.Address
+	}{
+		{EventKittySent, a1},
+		{EventKittySent, a0},
+		{EventKittyReceived, a1},
+		{EventKittyReceived, a0},
+	}
+	require.Len(t, h.Entries, len(expected))
+	for i, want := range expected {
+		require.Equal(t, want.kind, h.Entries[i].Kind, "history should be newest first")
+		require.Equal(t, want.addr.String(), h.Entries[i].Address, "history should have the address")
+		require.Equal(t, "one", h.Entries[i].Label)
+	}
+	require.Equal(t, b0.String(), h.Entries[0].Counterparty, "sends should have the recipient")
+
+	h, e = m.History(bc, "two", 0, 0)
+	require.Nil(t, e, "failed to get history")
+	require.Len(t, h.Entries, 1, "history should only have the addresses of the wallet")
+	require.Equal(t, EventKittyReceived, h.Entries[0].Kind)
+
+	h, e = m.History(bc, "one", 1, 3)
+	require.Nil(t, e, "failed to get history page")
+	require.Equal(t, uint64(2), h.TotalPageCount)
+	require.Len(t, h.Entries, 1, "pages should have the page size")
+	require.Equal(t, a0.String(), h.Entries[0].Address)
+
+	h, e = m.History(bc, "one", 2, 3)
+	require.Nil(t, e, "failed to get history page")
+	require.Empty(t, h.Entries, "pages past the last should be empty")
+
+	_, e = m.History(bc, "none", 0, 0)
+	require.True(t, errors.Is(e, ErrWalletNotFound), "history should be for existing wallets")
+}